@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/bench"
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/spf13/cobra"
+)
+
+func newBenchCmd() *cobra.Command {
+	var workers, batchSizes []int
+	var maxChunks int
+	var mockLatency time.Duration
+	var realEmbed, realStore, jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "bench <path>",
+		Short: "Measure indexing throughput per stage",
+		Long: `Measure walk, chunk, embed and upsert throughput separately on the files
+under a path, trying each worker count for chunking and each batch size for
+embedding and upserting, and print a comparison table. Use the results to
+tune SWARM_INDEXER_WORKERS and SWARM_INDEXER_BATCH_SIZE. Files are chunked
+at SWARM_INDEXER_CHUNK_SIZE, or --chunk-size.
+
+By default embeddings are mocked with a fixed latency per call and upserts
+are discarded, so no API quota is spent and the index is untouched.
+--real-embed calls Gemini; --real-store upserts into a temporary
+"<collection>-bench" collection that is dropped afterwards.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireDirs(args); err != nil {
+				return err
+			}
+			for _, n := range append(append([]int{}, workers...), batchSizes...) {
+				if n <= 0 {
+					return fmt.Errorf("worker counts and batch sizes must be positive, got %d", n)
+				}
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			local, err := config.LoadLocal()
+			if err != nil {
+				return configError(err)
+			}
+			opts := bench.Options{
+				Workers:    workers,
+				BatchSizes: batchSizes,
+				MaxChunks:  maxChunks,
+				ChunkSize:  local.ChunkSize,
+				Embedder:   &bench.MockEmbedder{Latency: mockLatency, Dim: indexer.EmbeddingDim},
+				Store:      bench.DiscardStore{},
+			}
+
+			if realEmbed || realStore {
+				cfg, err := config.Load()
+				if err != nil {
+					return configError(err)
+				}
+				if realEmbed {
+					gemini, err := newGeminiClient(cfg)
+					if err != nil {
+						return err
+					}
+					opts.Embedder = gemini
+				}
+				if realStore {
+					store, err := newTypesenseClient(cfg, cfg.TypesenseCollection+"-bench")
+					if err != nil {
+						return err
+					}
+					if err := store.EnsureCollection(ctx); err != nil {
+						return fmt.Errorf("creating bench collection: %w", err)
+					}
+					defer store.DropCollection(context.Background())
+					opts.Store = store
+				}
+			}
+
+			results, err := bench.Run(ctx, args[0], opts)
+
+			out := cmd.OutOrStdout()
+			if jsonOutput {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if encErr := enc.Encode(results); encErr != nil {
+					return encErr
+				}
+			} else {
+				bench.WriteTable(out, results)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().IntSliceVar(&workers, "workers", []int{1, 2, 4, 8}, "Worker counts to try for the chunk stage")
+	cmd.Flags().IntSliceVar(&batchSizes, "batch-sizes", []int{25, 50, 100}, "Batch sizes to try for the embed and upsert stages")
+	cmd.Flags().IntVar(&maxChunks, "max-chunks", 500, "Maximum chunks to embed and upsert (0 for all)")
+	cmd.Flags().DurationVar(&mockLatency, "mock-latency", 200*time.Millisecond, "Simulated latency per mocked embedding call")
+	cmd.Flags().BoolVar(&realEmbed, "real-embed", false, "Embed with the Gemini API instead of the mock")
+	cmd.Flags().BoolVar(&realStore, "real-store", false, "Upsert into a temporary Typesense collection")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings)
+
+	return cmd
+}
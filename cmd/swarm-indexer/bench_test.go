@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBenchCommand_Mocked(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"bench", dir, "--workers", "1,2", "--batch-sizes", "10", "--mock-latency", "0s"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("bench failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, stage := range []string{"walk", "chunk", "embed", "upsert"} {
+		if !strings.Contains(out, stage) {
+			t.Errorf("expected %s stage in output, got:\n%s", stage, out)
+		}
+	}
+}
+
+func TestBenchCommand_RequiresDirectory(t *testing.T) {
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"bench", "/nonexistent/path/for/test"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/chunker"
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/detector"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds network lookups made while completing
+const completionTimeout = 2 * time.Second
+
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// flagCompletions maps flag names to their dynamic completions. They are
+// attached to every command defining a flag of that name.
+var flagCompletions = map[string]completionFunc{
+	"language":   completeValues(detector.KnownLanguages),
+	"chunk-type": completeValues(func() []string { return chunker.ChunkTypes }),
+	"project":    completeRegisteredPaths,
+	"collection": completeCollections,
+}
+
+// registerFlagCompletions walks the command tree and attaches dynamic
+// completions to flags listed in flagCompletions.
+func registerFlagCompletions(cmd *cobra.Command) {
+	for name, fn := range flagCompletions {
+		if cmd.Flags().Lookup(name) != nil {
+			_ = cmd.RegisterFlagCompletionFunc(name, fn)
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		registerFlagCompletions(sub)
+	}
+}
+
+func completeValues(values func() []string) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return filterPrefix(values(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeRegisteredPaths offers registered project paths, falling back to
+// directory completion when none match.
+func completeRegisteredPaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_, reg, err := loadRegistry()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	matches := filterPrefix(reg.Paths(), toComplete)
+	if len(matches) == 0 {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	return matches, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRegisteredOnly offers registered project paths and nothing else.
+func completeRegisteredOnly(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_, reg, err := loadRegistry()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return filterPrefix(reg.Paths(), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	profiles, err := config.LoadProfiles(dir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return filterPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeCollections(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	client, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	names, err := client.ListCollections(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return filterPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func filterPrefix(values []string, prefix string) []string {
+	var out []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/registry"
+)
+
+// complete runs cobra's hidden completion command and returns the
+// suggested values
+func complete(t *testing.T, args ...string) []string {
+	t.Helper()
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs(append([]string{"__complete"}, args...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("completion failed: %v", err)
+	}
+
+	var values []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line != "" && !strings.HasPrefix(line, ":") {
+			values = append(values, line)
+		}
+	}
+	return values
+}
+
+func TestCompletion_Language(t *testing.T) {
+	values := complete(t, "search", "--language", "py")
+	if len(values) != 1 || values[0] != "python" {
+		t.Errorf("expected [python], got %v", values)
+	}
+}
+
+func TestCompletion_ChunkType(t *testing.T) {
+	values := complete(t, "search", "--chunk-type", "f")
+	if len(values) != 1 || values[0] != "function" {
+		t.Errorf("expected [function], got %v", values)
+	}
+}
+
+func TestCompletion_RegisteredPaths(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", configDir)
+	project := t.TempDir()
+
+	reg := &registry.Registry{}
+	if _, err := reg.Add(project); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Save(configDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"unregister", ""},
+		{"status", ""},
+		{"search", "--project", ""},
+	} {
+		values := complete(t, args...)
+		if len(values) != 1 || values[0] != project {
+			t.Errorf("%v: expected [%s], got %v", args, project, values)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "View, change and validate configuration",
+		Long: `View, change and validate configuration.
+
+Values are resolved from command-line flags first (e.g. --collection on
+index), then the selected profile, then environment variables, then the
+config file (config.yaml in the config dir), then defaults.
+
+Profiles bundle settings under a name in the config file's profiles
+section and are selected with --profile or SWARM_INDEXER_PROFILE:
+
+  swarm-indexer --profile work config set typesense_url https://ts.work.example
+  swarm-indexer --profile work index ~/work`,
+	}
+
+	cmd.AddCommand(newConfigViewCmd())
+	cmd.AddCommand(newConfigSetCmd())
+	cmd.AddCommand(newConfigValidateCmd())
+	cmd.AddCommand(newConfigProfilesCmd())
+	cmd.AddCommand(newConfigSetSecretCmd())
+
+	return cmd
+}
+
+func newConfigViewCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "Show the resolved configuration",
+		Long:  "Show every setting's effective value and where it came from. API keys are masked.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			values, err := config.Resolve()
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if jsonOutput {
+				type entry struct {
+					Key    string `json:"key"`
+					Env    string `json:"env"`
+					Value  string `json:"value"`
+					Source string `json:"source,omitempty"`
+				}
+				entries := make([]entry, len(values))
+				for i, v := range values {
+					entries[i] = entry{Key: v.Key, Env: v.Env, Value: v.Display(), Source: v.Source}
+				}
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+
+			if dir, err := config.Dir(); err == nil {
+				fmt.Fprintf(out, "Config file: %s\n", filepath.Join(dir, config.FileName))
+			}
+			if profile := config.ActiveProfile(); profile != "" {
+				fmt.Fprintf(out, "Profile:     %s\n", profile)
+			}
+			fmt.Fprintln(out)
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tENV")
+			for _, v := range values {
+				value, source := v.Display(), v.Source
+				if source == "" {
+					value, source = "(unset)", "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Key, value, source, v.Env)
+			}
+			return tw.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output configuration as JSON")
+	addConfigFlags(cmd, allSettings())
+	return cmd
+}
+
+func newConfigSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Store a value in the config file",
+		Long: `Validate and store a value in the config file. The key may be given as
+the config key (workers) or its environment variable (SWARM_INDEXER_WORKERS).
+An empty value removes the key from the file. With --profile, the value is
+stored in that profile instead, creating it if needed.
+
+Environment variables still take precedence over the file's top-level
+values.`,
+		Args: cobra.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			var keys []string
+			for _, s := range config.Settings {
+				keys = append(keys, s.Key)
+			}
+			return filterPrefix(keys, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := config.Dir()
+			if err != nil {
+				return fmt.Errorf("resolving config dir: %w", err)
+			}
+			profile := config.ActiveProfile()
+			if err := config.SetInProfile(dir, profile, args[0], args[1]); err != nil {
+				return err
+			}
+
+			s, _ := config.Lookup(args[0])
+			out := cmd.OutOrStdout()
+			where := filepath.Join(dir, config.FileName)
+			if profile != "" {
+				where = fmt.Sprintf("profile %q of %s", profile, where)
+			}
+			if args[1] == "" {
+				fmt.Fprintf(out, "Removed %s from %s\n", s.Key, where)
+			} else {
+				fmt.Fprintf(out, "Set %s in %s\n", s.Key, where)
+			}
+			if profile == "" && os.Getenv(s.Env) != "" {
+				fmt.Fprintf(out, "note: %s is set in the environment and takes precedence\n", s.Env)
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the resolved configuration for errors",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			values, err := config.Resolve()
+			if err != nil {
+				return withExitCode(exitConfig, err)
+			}
+			if err := config.Validate(values); err != nil {
+				cmd.SilenceUsage = true
+				return withExitCode(exitConfig, fmt.Errorf("invalid configuration:\n%w", err))
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Configuration is valid")
+			return nil
+		},
+	}
+	addConfigFlags(cmd, allSettings())
+	return cmd
+}
+
+func newConfigProfilesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "profiles",
+		Short: "List the profiles in the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := config.Dir()
+			if err != nil {
+				return fmt.Errorf("resolving config dir: %w", err)
+			}
+			profiles, err := config.LoadProfiles(dir)
+			if err != nil {
+				return withExitCode(exitConfig, err)
+			}
+
+			names := make([]string, 0, len(profiles))
+			for name := range profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			active := config.ActiveProfile()
+			out := cmd.OutOrStdout()
+			for _, name := range names {
+				marker := " "
+				if name == active {
+					marker = "*"
+				}
+				fmt.Fprintf(out, "%s %s\t%d settings\n", marker, name, len(profiles[name]))
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigSetSecretCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-secret <key>",
+		Short: "Store an API key, webhook or queue URL in the OS keychain",
+		Long: `Read an API key from stdin, store it in the OS keychain (macOS Keychain or
+libsecret's secret-tool on Linux) and point the config file at it with a
+keychain: reference, so the key never appears in shell history or in
+plaintext on disk:
+
+  swarm-indexer config set-secret gemini_api_key < ~/gemini.key
+
+With --profile, the reference is stored in that profile.`,
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			var keys []string
+			for _, s := range config.Settings {
+				if s.Secret {
+					keys = append(keys, s.Key)
+				}
+			}
+			return filterPrefix(keys, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, ok := config.Lookup(args[0])
+			if !ok || !s.Secret {
+				return fmt.Errorf("%q is not a secret setting", args[0])
+			}
+			dir, err := config.Dir()
+			if err != nil {
+				return fmt.Errorf("resolving config dir: %w", err)
+			}
+
+			secret, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("reading secret: %w", err)
+			}
+			secret = strings.TrimSpace(secret)
+			if secret == "" {
+				return errors.New("no secret given on stdin")
+			}
+
+			profile := config.ActiveProfile()
+			name := s.Key
+			if profile != "" {
+				name = profile + "/" + s.Key
+			}
+			if err := config.DefaultKeychain.Set(name, secret); err != nil {
+				return fmt.Errorf("storing in keychain: %w", err)
+			}
+			if err := config.SetInProfile(dir, profile, s.Key, config.KeychainPrefix+name); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Stored %s in the keychain as %q\n", s.Key, name)
+			return nil
+		},
+	}
+}
+
+// settingAnnotation marks flags that override a config setting; its value
+// is the setting's key.
+const settingAnnotation = "swarm-indexer/setting"
+
+// Settings with flags, grouped by what reads them. Commands add flags only
+// for the groups they use.
+var (
+	// typesenseSettings are read by every command that talks to Typesense
+	typesenseSettings = []string{"typesense_url", "typesense_collection", "typesense_timeout", "proxy", "otlp_endpoint"}
+	// geminiSettings are read by commands that embed with Gemini
+	geminiSettings = []string{"gemini_model", "gemini_rate_limit", "gemini_timeout", "proxy", "otlp_endpoint"}
+	// matchingSettings tune the keyword matching of searches
+	matchingSettings = []string{"search_num_typos", "search_prefix", "search_drop_tokens_threshold"}
+	// scanSettings are read by the secret scanner
+	scanSettings = []string{"skip_files", "entropy_enabled", "entropy_threshold", "entropy_min_length", "entropy_charset", "redaction"}
+	// pipelineSettings size the stages that embed and upsert chunks
+	pipelineSettings = []string{"workers", "embed_workers", "upsert_workers", "batch_size", "max_queued_batches", "max_embed_tokens"}
+	// indexSettings are read by index runs, on top of the pipeline and
+	// scanner settings
+	indexSettings = []string{"summary_model", "webhook_on", "max_queued_jobs", "max_file_size", "chunk_size", "reindex_after", "delete_grace", "skip_generated", "languages"}
+)
+
+// addConfigFlags adds a flag for every setting in groups that has one, so
+// one-off runs can override env and config file values. Flags the command
+// already defines for other purposes (e.g. bench --workers) are left out.
+func addConfigFlags(cmd *cobra.Command, groups ...[]string) {
+	flags := cmd.Flags()
+	for _, keys := range groups {
+		for _, key := range keys {
+			s, ok := config.Lookup(key)
+			if !ok || s.Flag == "" || flags.Lookup(s.Flag) != nil {
+				continue
+			}
+			usage := fmt.Sprintf("Override %s (%s)", s.Key, s.Env)
+			switch {
+			case s.Int:
+				flags.Int(s.Flag, 0, usage)
+			case s.Float:
+				flags.Float64(s.Flag, 0, usage)
+			case s.Duration:
+				flags.Duration(s.Flag, 0, usage)
+			case slices.Equal(s.Choices, []string{"true", "false"}):
+				flags.Bool(s.Flag, false, usage)
+			default:
+				flags.String(s.Flag, "", usage)
+			}
+			flags.SetAnnotation(s.Flag, settingAnnotation, []string{s.Key})
+		}
+	}
+}
+
+// allSettings lists every setting, for commands that show or check the
+// whole configuration.
+func allSettings() []string {
+	keys := make([]string, len(config.Settings))
+	for i, s := range config.Settings {
+		keys[i] = s.Key
+	}
+	return keys
+}
+
+// configFlagOverrides returns the values of config flags set on the
+// command line, keyed by setting key.
+func configFlagOverrides(cmd *cobra.Command) map[string]string {
+	overrides := map[string]string{}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if key, ok := f.Annotations[settingAnnotation]; ok {
+			overrides[key[0]] = f.Value.String()
+		}
+	})
+	return overrides
+}
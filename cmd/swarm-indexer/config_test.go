@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+)
+
+func TestConfigCommand_SetAndView(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("SWARM_INDEXER_WORKERS", "")
+
+	for _, args := range [][]string{
+		{"config", "set", "workers", "4"},
+		{"config", "set", "GEMINI_API_KEY", "secret-gemini-key-1234"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+	}
+
+	values, err := config.LoadFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["workers"] != "4" || values["gemini_api_key"] != "secret-gemini-key-1234" {
+		t.Errorf("unexpected config file values: %v", values)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"config", "view"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config view failed: %v", err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "secret-gemini-key-1234") {
+		t.Errorf("expected API key to be masked, got:\n%s", out)
+	}
+	if !strings.Contains(out, "****1234") {
+		t.Errorf("expected masked key suffix, got:\n%s", out)
+	}
+	if !strings.Contains(out, "workers") || !strings.Contains(out, "file") {
+		t.Errorf("expected workers from file, got:\n%s", out)
+	}
+}
+
+func TestConfigCommand_SetRejectsInvalid(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"config", "set", "batch_size", "lots"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for invalid batch size")
+	}
+}
+
+func TestConfigCommand_Validate(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "key")
+	t.Setenv("GEMINI_API_KEY", "key")
+	t.Setenv("SWARM_INDEXER_WORKERS", "many")
+
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"config", "validate"})
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("expected validation to fail")
+	}
+	if !strings.Contains(err.Error(), "workers") {
+		t.Errorf("expected workers error, got %v", err)
+	}
+
+	t.Setenv("SWARM_INDEXER_WORKERS", "")
+	cmd = newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"config", "validate"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+}
+
+func TestConfigFlags_OverrideEnv(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_COLLECTION", "from-env")
+	t.Setenv("SWARM_INDEXER_WORKERS", "")
+	defer config.SetFlagOverrides(nil)
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"config", "view", "--collection", "one-off", "--workers", "3"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config view failed: %v", err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[0] {
+		case "typesense_collection":
+			if fields[1] != "one-off" || fields[2] != config.SourceFlag {
+				t.Errorf("expected collection from flag, got %q", line)
+			}
+		case "workers":
+			if fields[1] != "3" || fields[2] != config.SourceFlag {
+				t.Errorf("expected workers from flag, got %q", line)
+			}
+		case "gemini_model":
+			if fields[2] != config.SourceDefault {
+				t.Errorf("expected unset flags not to override, got %q", line)
+			}
+		}
+	}
+}
+
+func TestConfigFlags_KeepCommandFlags(t *testing.T) {
+	bench, _, err := newRootCmd().Find([]string{"bench"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := bench.Flags().Lookup("workers"); f == nil || f.Value.Type() != "intSlice" {
+		t.Error("expected bench to keep its own --workers list")
+	}
+	if bench.Flags().Lookup("collection") == nil {
+		t.Error("expected bench to accept --collection")
+	}
+	if idx, _, _ := newRootCmd().Find([]string{"index"}); idx.Flags().Lookup("gemini-api-key") != nil {
+		t.Error("API keys must not be accepted as flags")
+	}
+}
+
+func TestConfigFlags_OnlyCommandsReadingThem(t *testing.T) {
+	root := newRootCmd()
+	for _, tc := range []struct {
+		args []string
+		flag string
+		want bool
+	}{
+		{[]string{"index"}, "chunk-size", true},
+		{[]string{"search"}, "prefix", true},
+		{[]string{"search"}, "chunk-size", false},
+		{[]string{"index"}, "prefix", false},
+		{[]string{"delete"}, "gemini-model", false},
+		{[]string{"secrets", "scan"}, "entropy-enabled", true},
+		{[]string{"secrets", "scan"}, "collection", false},
+	} {
+		cmd, _, err := root.Find(tc.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cmd.Flags().Lookup(tc.flag) != nil; got != tc.want {
+			t.Errorf("%v --%s registered: %v, want %v", tc.args, tc.flag, got, tc.want)
+		}
+	}
+}
+
+func TestConfigFlags_Typed(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("SWARM_INDEXER_ENTROPY_ENABLED", "")
+	t.Setenv("SWARM_INDEXER_DELETE_GRACE", "")
+	defer config.SetFlagOverrides(nil)
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"config", "view", "--entropy-enabled", "--delete-grace", "90m"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config view failed: %v", err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[0] {
+		case "entropy_enabled":
+			if fields[1] != "true" || fields[2] != config.SourceFlag {
+				t.Errorf("expected a bare --entropy-enabled to enable it, got %q", line)
+			}
+		case "delete_grace":
+			if fields[1] != "1h30m0s" || fields[2] != config.SourceFlag {
+				t.Errorf("expected delete grace from flag, got %q", line)
+			}
+		}
+	}
+}
+
+func TestConfigCommand_Profiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("SWARM_INDEXER_PROFILE", "")
+	t.Setenv("TYPESENSE_COLLECTION", "from-env")
+	defer config.UseProfile("")
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := newRootCmd()
+		buf := new(bytes.Buffer)
+		cmd.SetOut(buf)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+		return buf.String()
+	}
+
+	run("--profile", "work", "config", "set", "typesense_collection", "work-index")
+
+	out := run("--profile", "work", "config", "view")
+	if !strings.Contains(out, "Profile:     work") {
+		t.Errorf("expected active profile in output, got:\n%s", out)
+	}
+	if !strings.Contains(out, "work-index") || !strings.Contains(out, config.SourceProfile) {
+		t.Errorf("expected collection from profile, got:\n%s", out)
+	}
+
+	if out := run("config", "view"); !strings.Contains(out, "from-env") {
+		t.Errorf("expected env value without a profile, got:\n%s", out)
+	}
+
+	if out := run("--profile", "work", "config", "profiles"); !strings.Contains(out, "* work") {
+		t.Errorf("expected work profile marked active, got:\n%s", out)
+	}
+}
+
+type memKeychain map[string]string
+
+func (k memKeychain) Get(name string) (string, error) { return k[name], nil }
+
+func (k memKeychain) Set(name, secret string) error {
+	k[name] = secret
+	return nil
+}
+
+func TestConfigCommand_SetSecret(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("SWARM_INDEXER_PROFILE", "")
+	keychain := memKeychain{}
+	orig := config.DefaultKeychain
+	config.DefaultKeychain = keychain
+	defer func() { config.DefaultKeychain = orig }()
+	defer config.UseProfile("")
+
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetIn(strings.NewReader("gm-secret\n"))
+	cmd.SetArgs([]string{"--profile", "work", "config", "set-secret", "GEMINI_API_KEY"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("set-secret failed: %v", err)
+	}
+
+	if keychain["work/gemini_api_key"] != "gm-secret" {
+		t.Errorf("expected secret in keychain, got %v", keychain)
+	}
+	profiles, err := config.LoadProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := profiles["work"]["gemini_api_key"]; got != "keychain:work/gemini_api_key" {
+		t.Errorf("expected keychain reference in profile, got %q", got)
+	}
+
+	cmd = newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetArgs([]string{"config", "set-secret", "workers"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for a non-secret setting")
+	}
+}
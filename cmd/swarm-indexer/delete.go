@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/remote"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+	"github.com/spf13/cobra"
+)
+
+func newDeleteCmd() *cobra.Command {
+	var project string
+	var all, yes bool
+
+	cmd := &cobra.Command{
+		Use:   "delete [path]... | --project <root> | --all",
+		Short: "Remove content from the index",
+		Long: `Remove indexed documents from Typesense along with the local metadata.
+
+A directory argument removes everything indexed under that project; a file
+argument removes just that file's chunks. --project removes a project by
+its indexed path, even if the directory no longer exists, or by the URL
+of a remote repository or crawled site; --project doc: removes every
+document indexed with index-doc. --all drops the whole collection.
+
+Registered paths stay registered; use unregister to stop indexing them.`,
+		ValidArgsFunction: completeRegisteredPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modes := 0
+			if len(args) > 0 {
+				modes++
+			}
+			if project != "" {
+				modes++
+			}
+			if all {
+				modes++
+			}
+			if modes != 1 {
+				return fmt.Errorf("specify exactly one of: paths, --project or --all")
+			}
+
+			ctx := context.Background()
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			client, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+			if err != nil {
+				return err
+			}
+
+			var prompt string
+			switch {
+			case all:
+				prompt = fmt.Sprintf("Delete the entire %q collection and all local metadata?", cfg.TypesenseCollection)
+			case project != "":
+				// Remote repositories, crawled sites and documents are
+				// indexed under their URL or doc: path as is
+				if !remote.IsURL(project) && !strings.HasPrefix(project, indexer.DocProjectPath) {
+					abs, err := walker.Abs(project)
+					if err != nil {
+						return err
+					}
+					project = abs
+				}
+				prompt = fmt.Sprintf("Delete all indexed documents for %s?", project)
+			default:
+				for _, path := range args {
+					if _, err := os.Stat(path); err != nil {
+						return err
+					}
+				}
+				prompt = fmt.Sprintf("Delete indexed documents for %s?", strings.Join(args, ", "))
+			}
+
+			out := cmd.OutOrStdout()
+			if !yes {
+				ok, err := confirm(cmd.InOrStdin(), out, prompt)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(out, "Aborted")
+					return nil
+				}
+			}
+
+			switch {
+			case all:
+				return deleteAll(ctx, client, out)
+			case project != "":
+				return deleteProject(ctx, client, project, out)
+			default:
+				for _, path := range args {
+					if err := deletePath(ctx, client, path, out); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&project, "project", "", "Delete all documents indexed under this project path")
+	cmd.Flags().BoolVar(&all, "all", false, "Delete the entire collection")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Don't ask for confirmation")
+	addConfigFlags(cmd, typesenseSettings)
+
+	return cmd
+}
+
+// deletePath removes a project directory, or a single file within its
+// project, from the index
+func deletePath(ctx context.Context, client *indexer.TypesenseClient, path string, out io.Writer) error {
+	abs, err := walker.Abs(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return deleteProject(ctx, client, abs, out)
+	}
+
+	root := indexer.ProjectRoot(abs)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return err
+	}
+	n, err := client.DeleteByFile(ctx, root, filepath.ToSlash(rel))
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", abs, err)
+	}
+
+	meta, err := metadata.Load(root)
+	if err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	if _, ok := meta.Files[rel]; ok {
+		delete(meta.Files, rel)
+		if err := meta.Save(root); err != nil {
+			return fmt.Errorf("saving metadata: %w", err)
+		}
+	}
+
+	fmt.Fprintf(out, "Deleted %d documents for %s\n", n, abs)
+	return nil
+}
+
+func deleteProject(ctx context.Context, client *indexer.TypesenseClient, root string, out io.Writer) error {
+	n, err := client.DeleteByProject(ctx, root)
+	if err != nil {
+		return fmt.Errorf("deleting %s: %w", root, err)
+	}
+	if err := metadata.Remove(root); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Deleted %d documents for %s\n", n, root)
+	return nil
+}
+
+// deleteAll drops the collection and removes the metadata of every project
+// known to the registry or the collection
+func deleteAll(ctx context.Context, client *indexer.TypesenseClient, out io.Writer) error {
+	roots := map[string]bool{}
+	if _, reg, err := loadRegistry(); err == nil {
+		for _, path := range reg.Paths() {
+			roots[path] = true
+		}
+	}
+	if indexed, err := client.ProjectPaths(ctx); err == nil {
+		for path := range indexed {
+			roots[path] = true
+		}
+	}
+
+	if err := client.DropCollection(ctx); err != nil {
+		return fmt.Errorf("dropping collection: %w", err)
+	}
+
+	paths := make([]string, 0, len(roots))
+	for path := range roots {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := metadata.Remove(path); err != nil {
+			slog.Warn("failed to remove metadata", "project", path, "err", err)
+		}
+	}
+
+	fmt.Fprintf(out, "Deleted collection and metadata for %d projects\n", len(paths))
+	return nil
+}
+
+// confirm asks a yes/no question, defaulting to no
+func confirm(in io.Reader, out io.Writer, prompt string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
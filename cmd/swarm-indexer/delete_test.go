@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+)
+
+// fakeTypesense records the delete filters it receives
+type fakeTypesense struct {
+	mu      sync.Mutex
+	filters []string
+	dropped bool
+}
+
+func (f *fakeTypesense) serve(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch {
+		case r.Method == "DELETE" && strings.HasSuffix(r.URL.Path, "/documents"):
+			f.filters = append(f.filters, r.URL.Query().Get("filter_by"))
+			w.Write([]byte(`{"num_deleted": 3}`))
+		case r.Method == "DELETE":
+			f.dropped = true
+			w.Write([]byte(`{}`))
+		default:
+			w.Write([]byte(`{"facet_counts": []}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("TYPESENSE_URL", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+}
+
+func TestDeleteCommand_ProjectWithYes(t *testing.T) {
+	ts := &fakeTypesense{}
+	ts.serve(t)
+
+	project := t.TempDir()
+	if err := (&metadata.Metadata{FileCount: 1}).Save(project); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"delete", project, "--yes"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	if len(ts.filters) != 1 || ts.filters[0] != "project_path:=`"+project+"`" {
+		t.Errorf("unexpected delete filters: %v", ts.filters)
+	}
+	if metadata.Exists(project) {
+		t.Error("expected metadata to be removed")
+	}
+	if !strings.Contains(buf.String(), "Deleted 3 documents") {
+		t.Errorf("expected deletion summary, got %q", buf.String())
+	}
+}
+
+func TestDeleteCommand_File(t *testing.T) {
+	ts := &fakeTypesense{}
+	ts.serve(t)
+
+	project := t.TempDir()
+	file := filepath.Join(project, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta := &metadata.Metadata{Files: map[string]metadata.FileState{"main.go": {Size: 13}, "other.go": {Size: 1}}}
+	if err := meta.Save(project); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetArgs([]string{"delete", "-y", file})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	want := "project_path:=`" + project + "` && rel_path:=`main.go`"
+	if len(ts.filters) != 1 || ts.filters[0] != want {
+		t.Errorf("expected filter %q, got %v", want, ts.filters)
+	}
+	meta, err := metadata.Load(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := meta.Files["main.go"]; ok {
+		t.Error("expected main.go to be dropped from metadata")
+	}
+	if _, ok := meta.Files["other.go"]; !ok {
+		t.Error("expected other files to stay in metadata")
+	}
+}
+
+func TestDeleteCommand_PromptDeclined(t *testing.T) {
+	ts := &fakeTypesense{}
+	ts.serve(t)
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetIn(strings.NewReader("n\n"))
+	cmd.SetArgs([]string{"delete", "--project", "/gone/project"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	if len(ts.filters) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", ts.filters)
+	}
+	if !strings.Contains(buf.String(), "Aborted") {
+		t.Errorf("expected abort message, got %q", buf.String())
+	}
+}
+
+func TestDeleteCommand_AllConfirmed(t *testing.T) {
+	ts := &fakeTypesense{}
+	ts.serve(t)
+
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetIn(strings.NewReader("yes\n"))
+	cmd.SetArgs([]string{"delete", "--all"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if !ts.dropped {
+		t.Error("expected collection to be dropped")
+	}
+}
+
+func TestDeleteCommand_RequiresOneMode(t *testing.T) {
+	for _, args := range [][]string{
+		{"delete"},
+		{"delete", "--all", "--project", "/x"},
+		{"delete", ".", "--all"},
+	} {
+		cmd := newRootCmd()
+		cmd.SetOut(new(bytes.Buffer))
+		cmd.SetErr(new(bytes.Buffer))
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/doctor"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check configuration and connectivity",
+		Long: `Validate the configuration and environment, check that Typesense and
+Gemini are reachable with the configured keys (embedding a short probe
+text), and verify the collection's embedding dimension matches the model.
+
+Each failed check is followed by a suggested fix. Exits non-zero if any
+check fails.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			opts := doctor.Options{}
+			opts.ConfigDir, _ = config.Dir()
+			opts.Config, opts.ConfigErr = config.Load()
+			if opts.Config != nil {
+				cfg := opts.Config
+				if client, err := newTypesenseClient(cfg, cfg.TypesenseCollection); err == nil {
+					opts.Store = client
+				}
+				if gemini, err := newGeminiClient(cfg); err == nil {
+					opts.Embedder = gemini
+				}
+			}
+
+			report := doctor.Run(ctx, opts)
+
+			out := cmd.OutOrStdout()
+			if jsonOutput {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				doctor.Write(out, report)
+			}
+
+			if report.Failed() {
+				cmd.SilenceUsage = true
+				return errors.New("one or more checks failed")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output check results as JSON")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings)
+	return cmd
+}
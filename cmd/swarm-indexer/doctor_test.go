@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDoctorCommand_ReportsMissingConfig(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"doctor"})
+
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected doctor to fail without API keys")
+	}
+	if !strings.Contains(buf.String(), "TYPESENSE_API_KEY") {
+		t.Errorf("expected output to mention the missing key, got:\n%s", buf.String())
+	}
+}
+
+func TestDoctorCommand_JSON(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"doctor", "--json"})
+	_ = cmd.Execute()
+
+	var report struct {
+		Checks []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"checks"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("expected JSON output, got %v:\n%s", err, buf.String())
+	}
+	if len(report.Checks) == 0 || report.Checks[0].Name != "Config" || report.Checks[0].Status != "fail" {
+		t.Errorf("expected failed Config check first, got %+v", report.Checks)
+	}
+}
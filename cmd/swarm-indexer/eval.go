@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/embeddings"
+	"github.com/dvaida/swarm-indexer/internal/eval"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/spf13/cobra"
+)
+
+func newEvalCmd() *cobra.Command {
+	var casesPath string
+	var k int
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "eval --cases eval.yaml",
+		Short: "Score retrieval quality on labeled queries",
+		Long: `Run labeled queries against the index and report how high the files
+expected for each rank: recall@k (the share of expected files among the
+top k files of the results) and MRR (the mean of 1 / the rank of the
+first expected file). Run it before and after changing chunking or the
+embedding model to compare them on the same cases.
+
+The cases file lists queries and the files a good answer comes from,
+absolute or as path suffixes:
+
+  k: 10
+  cases:
+    - query: where are unchanged chunks skipped
+      expected: [internal/indexer/indexer.go]
+    - query: how are API keys kept out of the index
+      expected: [internal/secrets/scanner.go, README.md]
+
+Each query is embedded with the configured model and run as a hybrid
+search, like the index is searched. Queries whose search fails score 0;
+the command then exits with code 1 after the report.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if casesPath == "" {
+				return withExitCode(exitUsage, errors.New("--cases is required"))
+			}
+			suite, err := eval.Load(casesPath)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("k") {
+				if k <= 0 {
+					return withExitCode(exitUsage, fmt.Errorf("--k must be positive, got %d", k))
+				}
+				suite.K = k
+			}
+
+			ctx, stop := interruptContext()
+			defer stop()
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			store, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+			if err != nil {
+				return err
+			}
+			gemini, err := newGeminiClient(cfg)
+			if err != nil {
+				return err
+			}
+			// Query vectors are only comparable with documents of their model
+			store.SetEmbeddingModel(gemini.Model())
+
+			report, err := eval.Run(ctx, &indexSearcher{store: store, embedder: gemini}, suite)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if jsonOutput {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				eval.WriteTable(out, report)
+			}
+			if report.Failed > 0 {
+				return fmt.Errorf("%d of %d searches failed", report.Failed, len(report.Cases))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&casesPath, "cases", "", "YAML file of queries and the files expected for them")
+	cmd.Flags().IntVar(&k, "k", eval.DefaultK, "Number of top files scored per query (overrides k in the cases file)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output the report as JSON")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings, matchingSettings)
+	return cmd
+}
+
+// indexSearcher runs eval queries as hybrid searches of the index
+type indexSearcher struct {
+	store    *indexer.TypesenseClient
+	embedder *embeddings.GeminiClient
+}
+
+func (s *indexSearcher) Search(ctx context.Context, query string, limit int) ([]string, error) {
+	embedding, err := s.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	chunks, err := s.store.Search(ctx, query, embedding, limit)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(chunks))
+	for i, c := range chunks {
+		files[i] = c.RelPath
+	}
+	return files, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit codes let scripts and CI branch on the class of failure.
+const (
+	exitOK           = 0
+	exitFailure      = 1   // any other failure
+	exitUsage        = 2   // unknown command, invalid flags or arguments
+	exitConfig       = 3   // missing or invalid configuration, rejected credentials
+	exitConnectivity = 4   // Typesense or Gemini unreachable
+	exitPartial      = 5   // indexing ran but some paths or files failed
+	exitChanges      = 6   // status --check found paths that need re-indexing
+	exitSecrets      = 7   // secrets scan found secrets
+	exitInterrupted  = 130 // cancelled by Ctrl-C or SIGTERM
+)
+
+// exitError attaches an exit code to an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// usageErrors makes argument errors of cmd and its subcommands exit with
+// exitUsage: their Args validators' and unknown subcommands'. Commands
+// that only group subcommands are made to reject unknown ones, as cobra
+// only does so for the root, and to print their help otherwise.
+func usageErrors(cmd *cobra.Command) {
+	if !cmd.Runnable() && cmd.HasSubCommands() {
+		cmd.Args = unknownCommand
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		}
+	} else if validate := cmd.Args; validate != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			return withExitCode(exitUsage, validate(cmd, args))
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		usageErrors(sub)
+	}
+}
+
+// unknownCommand rejects the arguments of a command that only groups
+// subcommands, suggesting the subcommands they may have meant.
+func unknownCommand(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("unknown command %q for %q", args[0], cmd.CommandPath())
+	if suggestions := cmd.SuggestionsFor(args[0]); len(suggestions) > 0 {
+		msg += "\n\nDid you mean this?\n\t" + strings.Join(suggestions, "\n\t")
+	}
+	return withExitCode(exitUsage, errors.New(msg))
+}
+
+// configError marks a failure to load the configuration.
+func configError(err error) error {
+	return withExitCode(exitConfig, fmt.Errorf("loading config: %w", err))
+}
+
+// authError is implemented by API errors that can tell rejected credentials apart
+type authError interface {
+	IsAuthError() bool
+}
+
+// exitCode maps err to the process exit code. An explicit code wins;
+// otherwise interrupts, rejected credentials and network failures are
+// recognised anywhere in the error chain.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	if errors.Is(err, context.Canceled) {
+		return exitInterrupted
+	}
+	var ae authError
+	if errors.As(err, &ae) && ae.IsAuthError() {
+		return exitConfig
+	}
+	if isConnectivityError(err) {
+		return exitConnectivity
+	}
+	return exitFailure
+}
+
+// isConnectivityError reports whether err came from a failed HTTP round
+// trip (connection refused, DNS, timeout) rather than an API response.
+func isConnectivityError(err error) bool {
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
+
+// indexingError classifies an indexing failure: connectivity and
+// interrupts keep their own codes, anything else is a partial failure.
+func indexingError(err error) error {
+	switch code := exitCode(err); code {
+	case exitConnectivity, exitInterrupted, exitConfig:
+		return withExitCode(code, err)
+	}
+	return withExitCode(exitPartial, err)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+)
+
+func TestExitCode(t *testing.T) {
+	netErr := fmt.Errorf("connecting to Typesense: %w", &url.Error{Op: "Get", URL: "http://localhost:8108", Err: errors.New("connection refused")})
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, exitOK},
+		{"plain", errors.New("boom"), exitFailure},
+		{"explicit", withExitCode(exitChanges, errors.New("stale")), exitChanges},
+		{"config", configError(errors.New("TYPESENSE_API_KEY is required")), exitConfig},
+		{"connectivity", netErr, exitConnectivity},
+		{"auth", fmt.Errorf("ensuring collection: %w", &indexer.APIError{StatusCode: 401}), exitConfig},
+		{"interrupted", fmt.Errorf("indexing failed: %w", context.Canceled), exitInterrupted},
+		{"partial", indexingError(errors.New("/a: walking: no such dir")), exitPartial},
+		{"indexing connectivity", indexingError(netErr), exitConnectivity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCode_Commands(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	release := filepath.Join(t.TempDir(), "release.zip")
+	if err := os.WriteFile(release, []byte("PK"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases := filepath.Join(t.TempDir(), "eval.yaml")
+	if err := os.WriteFile(cases, []byte("cases:\n  - query: main\n    expected: [main.go]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &metadata.Metadata{LastIndexed: time.Now().Unix()}
+	if err := m.Save(project); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"missing config", []string{"index", project}, exitConfig},
+		{"unknown flag", []string{"index", "--no-such-flag"}, exitUsage},
+		{"unknown command", []string{"idnex", project}, exitUsage},
+		{"unknown subcommand", []string{"config", "nope"}, exitUsage},
+		{"too many arguments", []string{"move", project}, exitUsage},
+		{"unexpected argument", []string{"worker", "extra"}, exitUsage},
+		{"command group without arguments", []string{"config"}, exitOK},
+		{"plan of a repository URL", []string{"index", "--plan", "https://github.com/org/repo.git"}, exitUsage},
+		{"plan of an archive", []string{"index", "--plan", release}, exitUsage},
+		{"ref without a repository URL", []string{"index", "--ref", "v1.0.0", project}, exitUsage},
+		{"index-url of a path", []string{"index-url", project}, exitUsage},
+		{"index-url with a negative depth", []string{"index-url", "--depth", "-1", "https://docs.example.com/"}, exitUsage},
+		{"index-url without config", []string{"index-url", "https://docs.example.com/"}, exitConfig},
+		{"worker without config", []string{"worker"}, exitConfig},
+		{"eval without cases", []string{"eval"}, exitUsage},
+		{"eval with a zero k", []string{"eval", "--cases", cases, "--k", "0"}, exitUsage},
+		{"eval without config", []string{"eval", "--cases", cases}, exitConfig},
+		{"changes detected", []string{"status", "--check", project}, exitChanges},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newRootCmd()
+			cmd.SetOut(new(bytes.Buffer))
+			cmd.SetErr(new(bytes.Buffer))
+			cmd.SetArgs(tt.args)
+			if got := exitCode(cmd.Execute()); got != tt.want {
+				t.Errorf("exit code %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExitCode_DistributeWithoutQueue(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "ts-key")
+	t.Setenv("GEMINI_API_KEY", "gemini-key")
+	t.Setenv("SWARM_INDEXER_QUEUE_URL", "")
+
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"index", "--distribute", t.TempDir()})
+	err := cmd.Execute()
+	if got := exitCode(err); got != exitConfig {
+		t.Fatalf("exit code %d, want %d", got, exitConfig)
+	}
+	if !strings.Contains(err.Error(), "queue_url") {
+		t.Errorf("expected the missing queue_url named, got %v", err)
+	}
+}
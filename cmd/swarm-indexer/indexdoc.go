@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/detector"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/spf13/cobra"
+)
+
+// maxDocSize bounds the content index-doc reads
+const maxDocSize = 10 << 20
+
+func newIndexDocCmd() *cobra.Command {
+	var id, language string
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "index-doc [--id <id>] [--language <lang>] <file|->",
+		Short: "Index a single document, such as notes piped via stdin",
+		Long: `Index one document that isn't part of an indexed directory, such as
+meeting notes or ticket text. "-" reads it from stdin:
+
+  pbpaste | swarm-indexer index-doc --id standup-2024-05-02 --language md -
+
+The document is indexed under the project path "doc:" with its ID as the
+file path, so search can filter on it. Indexing the same ID again replaces
+the document. --id defaults to the file's name and is required for stdin.
+--language takes a language name or a file extension (markdown or md); by
+default it is detected from the ID and content. Documents are scanned for
+secrets like files; delete --project doc: removes every document.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if id == "" {
+				if name == "-" {
+					return withExitCode(exitUsage, errors.New("--id is required when reading from stdin"))
+				}
+				id = filepath.Base(name)
+			}
+			lang, err := docLanguage(language)
+			if err != nil {
+				return withExitCode(exitUsage, err)
+			}
+
+			ctx, stop := interruptContext()
+			defer stop()
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+
+			content, err := readDoc(cmd.InOrStdin(), name)
+			if err != nil {
+				return err
+			}
+
+			start := time.Now()
+			idx, err := newIndexer(ctx, cmd, cfg)
+			if err != nil {
+				notifyStartFailure(cmd, cfg, start, []string{indexer.DocProjectPath + id}, err)
+				return err
+			}
+
+			runErr := idx.IndexDocument(ctx, id, lang, content)
+			run := recordRun(ctx, idx, start, []string{indexer.DocProjectPath + id})
+			notifyRun(cmd, cfg, run, runErr)
+			if err := reportRun(cmd, idx, jsonOutput, runErr); err != nil {
+				return err
+			}
+			if runErr != nil {
+				return indexingError(fmt.Errorf("indexing %s failed: %w", id, runErr))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&id, "id", "", "ID of the document, used as its file path (default the file's name)")
+	cmd.Flags().StringVar(&language, "language", "", "Language of the document, by name or extension (default detected)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print a summary of the run as JSON")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings, pipelineSettings, scanSettings, indexSettings)
+	return cmd
+}
+
+// docLanguage resolves index-doc's --language, a language name or a file
+// extension such as md, to a language name
+func docLanguage(language string) (string, error) {
+	if language == "" {
+		return "", nil
+	}
+	lang := strings.ToLower(language)
+	if slices.Contains(detector.KnownLanguages(), lang) {
+		return lang, nil
+	}
+	if lang := detector.DetectLanguage("doc." + strings.TrimPrefix(lang, ".")); lang != "unknown" {
+		return lang, nil
+	}
+	return "", fmt.Errorf("unknown language %q", language)
+}
+
+// readDoc reads the document named by name, or stdin when name is "-",
+// up to maxDocSize
+func readDoc(stdin io.Reader, name string) (string, error) {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxDocSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxDocSize {
+		return "", fmt.Errorf("document is larger than %d MB", maxDocSize>>20)
+	}
+	return string(data), nil
+}
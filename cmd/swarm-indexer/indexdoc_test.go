@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDocLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":         "",
+		"markdown": "markdown",
+		"Markdown": "markdown",
+		"md":       "markdown",
+		".py":      "python",
+	}
+	for in, want := range tests {
+		got, err := docLanguage(in)
+		if err != nil || got != want {
+			t.Errorf("docLanguage(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := docLanguage("klingon"); err == nil {
+		t.Error("expected an error for an unknown language")
+	}
+}
+
+func TestReadDoc(t *testing.T) {
+	content, err := readDoc(strings.NewReader("# Notes\n"), "-")
+	if err != nil || content != "# Notes\n" {
+		t.Errorf("expected stdin to be read, got %q, %v", content, err)
+	}
+
+	_, err = readDoc(strings.NewReader(strings.Repeat("x", maxDocSize+1)), "-")
+	if err == nil {
+		t.Error("expected an error for an oversized document")
+	}
+}
+
+func TestIndexDocCommand_RequiresIDForStdin(t *testing.T) {
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetIn(strings.NewReader("notes"))
+	cmd.SetArgs([]string{"index-doc", "-"})
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--id") {
+		t.Fatalf("expected an error asking for --id, got %v", err)
+	}
+	if code := exitCode(err); code != exitUsage {
+		t.Errorf("expected exit code %d, got %d", exitUsage, code)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/crawl"
+	"github.com/spf13/cobra"
+)
+
+// pageTimeout bounds fetching a single page of a crawl
+const pageTimeout = 30 * time.Second
+
+func newIndexURLCmd() *cobra.Command {
+	var depth, maxPages int
+	var wait, jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "index-url <url>...",
+		Short: "Crawl a website and index its pages",
+		Long: `Crawl each website from the given URL and index its pages, so external
+documentation can be searched alongside code.
+
+Pages are fetched breadth first, following links up to --depth links away
+from the start page and at most --max-pages pages per site. Only pages on
+the same host and under the start URL's directory are followed: crawling
+https://docs.example.com/guide/ stays within /guide/. Each page's HTML is
+converted to markdown (navigation, headers and footers left out) and
+indexed with its URL as the file path and the start URL as the project
+path.
+
+Crawling a site again re-indexes only the pages whose text changed and
+removes the pages no longer found. Pages that can't be fetched are listed
+at the end and the run exits with code 5. Crawled sites aren't added to
+the registry.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, arg := range args {
+				if u, err := neturl.Parse(arg); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return withExitCode(exitUsage, fmt.Errorf("%q is not an http or https URL", arg))
+				}
+			}
+			if depth < 0 || maxPages < 1 {
+				return withExitCode(exitUsage, errors.New("--depth must be 0 or more and --max-pages at least 1"))
+			}
+
+			ctx, stop := interruptContext()
+			defer stop()
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			client, err := newHTTPClient(pageTimeout, cfg.Proxy)
+			if err != nil {
+				return configError(err)
+			}
+
+			lock, err := lockProjects(ctx, args, wait)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			start := time.Now()
+			idx, err := newIndexer(ctx, cmd, cfg)
+			if err != nil {
+				notifyStartFailure(cmd, cfg, start, args, err)
+				return err
+			}
+
+			runErr := idx.IndexSites(ctx, client, args, crawl.Options{Depth: depth, MaxPages: maxPages})
+			run := recordRun(ctx, idx, start, args)
+			notifyRun(cmd, cfg, run, runErr)
+			if err := reportRun(cmd, idx, jsonOutput, runErr); err != nil {
+				return err
+			}
+			if runErr != nil {
+				return indexingError(fmt.Errorf("indexing failed: %w", runErr))
+			}
+			return failedFilesError(idx)
+		},
+	}
+
+	cmd.Flags().IntVar(&depth, "depth", crawl.DefaultDepth, "How many links away from the start page to follow")
+	cmd.Flags().IntVar(&maxPages, "max-pages", crawl.DefaultMaxPages, "Most pages to fetch per site")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for another index run to finish instead of failing")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print a summary of the run as JSON, including every failed page")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings, pipelineSettings, scanSettings, indexSettings)
+	return cmd
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+// logOptions holds the global verbosity flags.
+type logOptions struct {
+	verbose bool
+	quiet   bool
+	format  string
+}
+
+func addLogFlags(cmd *cobra.Command, opts *logOptions) {
+	flags := cmd.PersistentFlags()
+	flags.BoolVarP(&opts.verbose, "verbose", "v", false, "Log debug output")
+	flags.BoolVarP(&opts.quiet, "quiet", "q", false, "Only log warnings and errors")
+	flags.StringVar(&opts.format, "log-format", "text", "Log format: text or json")
+}
+
+// newLogger builds the logger selected by the global flags. Logs go to w
+// (stderr) so they never mix with command output.
+func newLogger(w io.Writer, opts logOptions) (*slog.Logger, error) {
+	if opts.verbose && opts.quiet {
+		return nil, fmt.Errorf("--verbose and --quiet are mutually exclusive")
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case opts.verbose:
+		level = slog.LevelDebug
+	case opts.quiet:
+		level = slog.LevelWarn
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	switch opts.format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("invalid --log-format %q: must be text or json", opts.format)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger_Levels(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      logOptions
+		wantDebug bool
+		wantInfo  bool
+	}{
+		{"default", logOptions{}, false, true},
+		{"verbose", logOptions{verbose: true}, true, true},
+		{"quiet", logOptions{quiet: true}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := newLogger(&buf, tt.opts)
+			if err != nil {
+				t.Fatalf("newLogger failed: %v", err)
+			}
+			logger.Debug("debug line")
+			logger.Info("info line")
+			logger.Warn("warn line")
+
+			out := buf.String()
+			if got := strings.Contains(out, "debug line"); got != tt.wantDebug {
+				t.Errorf("debug logged = %v, want %v", got, tt.wantDebug)
+			}
+			if got := strings.Contains(out, "info line"); got != tt.wantInfo {
+				t.Errorf("info logged = %v, want %v", got, tt.wantInfo)
+			}
+			if !strings.Contains(out, "warn line") {
+				t.Error("expected warnings to always be logged")
+			}
+		})
+	}
+}
+
+func TestNewLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, logOptions{format: "json"})
+	if err != nil {
+		t.Fatalf("newLogger failed: %v", err)
+	}
+	logger.Warn("error processing file", "file", "main.go")
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("expected JSON log line, got %q", buf.String())
+	}
+	if rec["msg"] != "error processing file" || rec["file"] != "main.go" {
+		t.Errorf("unexpected record: %v", rec)
+	}
+}
+
+func TestNewLogger_InvalidOptions(t *testing.T) {
+	if _, err := newLogger(&bytes.Buffer{}, logOptions{format: "xml"}); err == nil {
+		t.Error("expected error for unknown log format")
+	}
+	if _, err := newLogger(&bytes.Buffer{}, logOptions{verbose: true, quiet: true}); err == nil {
+		t.Error("expected error for --verbose with --quiet")
+	}
+}
+
+func TestRootCmd_LogFormatFlag(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	errBuf := new(bytes.Buffer)
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(errBuf)
+	cmd.SetArgs([]string{"--log-format", "json", "register"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	slog.Info("probe")
+
+	if !strings.HasPrefix(strings.TrimSpace(errBuf.String()), `{"time"`) {
+		t.Errorf("expected JSON logs on stderr, got %q", errBuf.String())
+	}
+}
@@ -1,68 +1,944 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/embeddings"
+	"github.com/dvaida/swarm-indexer/internal/httpclient"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/manifest"
+	"github.com/dvaida/swarm-indexer/internal/notify"
+	"github.com/dvaida/swarm-indexer/internal/progress"
+	"github.com/dvaida/swarm-indexer/internal/queue"
+	"github.com/dvaida/swarm-indexer/internal/remote"
 	"github.com/dvaida/swarm-indexer/internal/search"
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/dvaida/swarm-indexer/internal/status"
+	"github.com/dvaida/swarm-indexer/internal/tracing"
+	"github.com/dvaida/swarm-indexer/internal/usage"
+	"github.com/dvaida/swarm-indexer/internal/walker"
 	"github.com/spf13/cobra"
 )
 
+// tracingShutdownTimeout bounds how long exiting waits for buffered spans
+// to be exported
+const tracingShutdownTimeout = 5 * time.Second
+
 func main() {
-	if err := newRootCmd().Execute(); err != nil {
+	err := newRootCmd().Execute()
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	if shutdownErr := tracing.Shutdown(ctx); shutdownErr != nil {
+		slog.Warn("exporting traces", "err", shutdownErr)
+	}
+	cancel()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func newRootCmd() *cobra.Command {
+	var logOpts logOptions
+	var profile string
+
 	rootCmd := &cobra.Command{
 		Use:   "swarm-indexer",
 		Short: "Index text files for AI context retrieval",
 		Long:  "A CLI tool that indexes text files from registered paths into Typesense for AI context retrieval (RAG), using semantic chunking and Gemini embeddings for hybrid search.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			logger, err := newLogger(cmd.ErrOrStderr(), logOpts)
+			if err != nil {
+				return err
+			}
+			slog.SetDefault(logger)
+			config.UseProfile(profile)
+			config.SetFlagOverrides(configFlagOverrides(cmd))
+			return startTracing()
+		},
 	}
+	addLogFlags(rootCmd, &logOpts)
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Use a named profile from the config file (overrides SWARM_INDEXER_PROFILE)")
+	_ = rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return withExitCode(exitUsage, err)
+	})
 
 	rootCmd.AddCommand(newIndexCmd())
+	rootCmd.AddCommand(newIndexURLCmd())
+	rootCmd.AddCommand(newIndexDocCmd())
 	rootCmd.AddCommand(newSearchCmd())
 	rootCmd.AddCommand(newStatusCmd())
+	rootCmd.AddCommand(newReindexCmd())
+	rootCmd.AddCommand(newReembedCmd())
+	rootCmd.AddCommand(newDeleteCmd())
+	rootCmd.AddCommand(newUndeleteCmd())
+	rootCmd.AddCommand(newMoveCmd())
+	rootCmd.AddCommand(newDoctorCmd())
+	rootCmd.AddCommand(newPruneCmd())
+	rootCmd.AddCommand(newStatsCmd())
+	rootCmd.AddCommand(newBenchCmd())
+	rootCmd.AddCommand(newEvalCmd())
+	rootCmd.AddCommand(newSynonymsCmd())
+	rootCmd.AddCommand(newOverridesCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newSecretsCmd())
+	rootCmd.AddCommand(newRegisterCmd())
+	rootCmd.AddCommand(newUnregisterCmd())
+	rootCmd.AddCommand(newStateCmd())
+	rootCmd.AddCommand(newWorkerCmd())
+	usageErrors(rootCmd)
+
+	registerFlagCompletions(rootCmd)
 
 	return rootCmd
 }
 
 func newIndexCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "index [path]",
-		Short: "Index files from a path",
-		Long:  "Index text files from the specified path into Typesense.",
-		Args:  cobra.ExactArgs(1),
+	var filesFrom, ref, reportPath, manifestPath, sampleSpec string
+	var wait, plan, jsonOutput, withHistory, distribute, showSkipped bool
+	var tags []string
+	var prof profiling
+
+	cmd := &cobra.Command{
+		Use:   "index [path|url|archive]...",
+		Short: "Index files from one or more paths",
+		Long: `Index text files from the specified paths into Typesense. Without
+arguments, every registered path is indexed; indexed paths are added to
+the registry.
+
+Besides local directories, a path can be a git URL (https://, ssh://,
+git@host:org/repo.git), which is shallow-cloned into the data dir, or a
+.zip, .tar.gz or .tar archive, which is unpacked there. Neither is added to
+the registry.
+
+Runs are incremental: only files added or changed since the last run are
+processed, and the documents of deleted files are removed. With
+reindex_after set, a path last indexed longer ago is reindexed from
+scratch. Ctrl-C or SIGTERM stops the run once the files in flight are
+finished and saves the progress; a second signal quits at once. After the
+run, the expired documents of paths registered with --ttl are deleted, as
+are tombstones past delete_grace.
+
+Only one run can write a project's state at a time; see --wait. Files that
+can't be read, scanned, chunked or upserted are listed at the end and the
+run exits with code 5.
+
+  git diff --name-only | swarm-indexer index --files-from -
+  swarm-indexer index --plan ~/src/api
+  swarm-indexer index --manifest repos.yaml`,
+		ValidArgsFunction: completeRegisteredPaths,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return nil
+			var repos *manifest.Manifest
+			if manifestPath != "" {
+				if len(args) > 0 {
+					return withExitCode(exitUsage, errors.New("--manifest takes no paths; list them in the manifest"))
+				}
+				var err error
+				if repos, err = manifest.Load(manifestPath); err != nil {
+					return withExitCode(exitUsage, err)
+				}
+			} else if len(args) == 0 && filesFrom == "" {
+				var err error
+				args, err = registeredPaths()
+				if err != nil {
+					return err
+				}
+			}
+
+			args, urls := splitRemotes(args)
+			args, archives := splitArchives(args)
+			if repos != nil {
+				args, urls = manifestTargets(repos)
+			}
+			if plan && (len(urls) > 0 || len(archives) > 0) {
+				return withExitCode(exitUsage, errors.New("--plan works on local directories only"))
+			}
+			if ref != "" && len(urls) == 0 {
+				return withExitCode(exitUsage, errors.New("--ref needs a repository URL"))
+			}
+			var sample indexer.Sample
+			if sampleSpec != "" {
+				var err error
+				if sample, err = indexer.ParseSample(sampleSpec); err != nil {
+					return withExitCode(exitUsage, err)
+				}
+			}
+
+			if plan {
+				if err := requireDirs(args); err != nil {
+					return err
+				}
+				return planIndex(cmd.OutOrStdout(), args, jsonOutput)
+			}
+
+			stopProfiling, err := prof.start()
+			if err != nil {
+				return err
+			}
+			defer stopProfiling()
+
+			ctx, stop := interruptContext()
+			defer stop()
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+
+			if err := requireDirs(args); err != nil {
+				return err
+			}
+
+			var files []string
+			if filesFrom != "" {
+				files, err = readFilesFrom(cmd.InOrStdin(), filesFrom)
+				if err != nil {
+					return err
+				}
+			}
+
+			var q queue.Queue
+			if distribute {
+				if q, err = openQueue(cfg); err != nil {
+					return err
+				}
+				defer q.Close()
+			}
+
+			projects, err := runProjects(args, urls, archives, files)
+			if err != nil {
+				return err
+			}
+			lock, err := lockProjects(ctx, projects, wait)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			start := time.Now()
+			idx, err := newIndexer(ctx, cmd, cfg)
+			if err != nil {
+				notifyStartFailure(cmd, cfg, start, slices.Concat(args, urls, archives), err)
+				return err
+			}
+			if q != nil {
+				idx.SetQueue(q)
+			}
+			idx.SetListSkipped(showSkipped)
+			idx.SetSample(sample)
+			if cmd.Flags().Changed("tag") {
+				idx.SetTags(tags)
+			}
+
+			var indexErrs []error
+			if repos != nil {
+				// Each repo is indexed with its own settings
+				indexErrs = append(indexErrs, indexManifest(ctx, cmd.ErrOrStderr(), idx, repos, withHistory))
+			} else {
+				if len(args) > 0 {
+					indexErrs = append(indexErrs, idx.IndexPaths(ctx, args))
+					if withHistory {
+						indexErrs = append(indexErrs, idx.IndexHistory(ctx, args))
+					}
+				}
+				if len(urls) > 0 {
+					indexErrs = append(indexErrs, idx.IndexRemotes(ctx, urls, ref))
+				}
+			}
+			if len(archives) > 0 {
+				indexErrs = append(indexErrs, idx.IndexArchives(ctx, archives))
+			}
+			if len(files) > 0 {
+				indexErrs = append(indexErrs, idx.IndexFiles(ctx, files))
+			}
+			run := recordRun(ctx, idx, start, slices.Concat(args, urls, archives))
+
+			if len(args) > 0 {
+				if err := registerPaths(args); err != nil {
+					slog.Warn("failed to update registry", "err", err)
+				}
+			}
+			// Documents expire without a worker running too
+			if ctx.Err() == nil {
+				expirePass(ctx, cfg)
+			}
+
+			runErr := errors.Join(indexErrs...)
+			notifyRun(cmd, cfg, run, runErr)
+			if reportPath != "" {
+				if err := writeRunReport(reportPath, idx, runErr); err != nil {
+					return err
+				}
+			}
+			if showSkipped && !jsonOutput {
+				reportSkipped(cmd.ErrOrStderr(), idx.Report())
+			}
+			if err := reportRun(cmd, idx, jsonOutput, runErr); err != nil {
+				return err
+			}
+			if runErr != nil {
+				return indexingError(fmt.Errorf("indexing failed: %w", runErr))
+			}
+			return failedFilesError(idx)
 		},
 	}
+
+	cmd.Flags().StringVar(&filesFrom, "files-from", "", `Read a newline-delimited list of files to index ("-" for stdin), e.g. from git diff --name-only; each is attributed to its nearest indexed or git project`)
+	cmd.Flags().StringVar(&manifestPath, "manifest", "", "Index the local paths and repository URLs listed in this YAML file (repos: - path: or - url:, each with optional tags, ref and exclude globs; relative paths are to the manifest), printing a line per repo. A repo without tags keeps its own; newly excluded files are removed")
+	cmd.MarkFlagsMutuallyExclusive("manifest", "files-from")
+	cmd.Flags().BoolVar(&wait, "wait", false, `Wait for another index or reindex run on the same project to finish instead of failing with "another indexer is running"`)
+	cmd.Flags().BoolVar(&plan, "plan", false, "List the files each path would add, update and delete without writing anything; API keys aren't needed")
+	cmd.MarkFlagsMutuallyExclusive("plan", "files-from")
+	cmd.Flags().StringVar(&ref, "ref", "", "Branch, tag or commit of the repository URLs to index (default: their default branch); indexing again fetches it and re-indexes only what changed")
+	cmd.Flags().BoolVar(&withHistory, "with-history", false, `Also index each path's git history: commit messages and changed files as "commit" chunks and diff hunks as "diff_hunk" chunks. The first run reads up to 1000 commits, later runs only the new ones`)
+	cmd.MarkFlagsMutuallyExclusive("plan", "with-history")
+	cmd.Flags().BoolVar(&distribute, "distribute", false, "Hand the files of local paths to swarm-indexer worker processes through the queue at queue_url; workers must see the paths at the same location, and files none reports on within 10 minutes fail")
+	cmd.MarkFlagsMutuallyExclusive("plan", "distribute")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print a summary of the run (or the plan) as JSON, including every failed file, so scripts can retry them with --files-from")
+	cmd.Flags().StringVar(&reportPath, "report", "", "Write a JSON report of the run to this file: the --json summary plus, per path, files walked, added, modified, deleted, indexed, skipped by reason and failed, chunks embedded, unchanged, moved and removed, failed batches and stage durations")
+	cmd.MarkFlagsMutuallyExclusive("plan", "report")
+	cmd.Flags().BoolVar(&showSkipped, "show-skipped", false, "List every file left out and why (gitignored, hidden, generated, vendored, binary, too large, encoding, pattern, rule, hook), also in --json and --report; unchanged files are only counted")
+	cmd.MarkFlagsMutuallyExclusive("plan", "show-skipped")
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Tag every document of the paths indexed, for search --tag (repeatable). Tags replace the project's and are kept by later runs; changing them updates documents without re-embedding. --tag= removes them")
+	cmd.MarkFlagsMutuallyExclusive("plan", "tag")
+	cmd.Flags().StringVar(&sampleSpec, "sample", "", "Only index a sample of each path's new files, spread across directories and languages: a percentage (5%) or a number of files (2000). The rest are left for later runs")
+	cmd.MarkFlagsMutuallyExclusive("plan", "sample")
+	cmd.MarkFlagsMutuallyExclusive("manifest", "plan")
+	cmd.MarkFlagsMutuallyExclusive("manifest", "tag")
+	cmd.MarkFlagsMutuallyExclusive("manifest", "ref")
+	prof.addFlags(cmd)
+	addConfigFlags(cmd, typesenseSettings, geminiSettings, pipelineSettings, scanSettings, indexSettings)
+	return cmd
+}
+
+// planIndex prints what indexing paths would change, without writing
+// anything
+func planIndex(w io.Writer, paths []string, jsonOutput bool) error {
+	cfg, err := config.LoadLocal()
+	if err != nil {
+		return configError(err)
+	}
+	plans, planErr := indexer.NewIndexer(cfg, nil, nil).Plan(paths)
+	if jsonOutput {
+		if plans == nil {
+			plans = []indexer.Plan{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plans); err != nil {
+			return err
+		}
+		if planErr != nil {
+			return fmt.Errorf("planning failed: %w", planErr)
+		}
+		return nil
+	}
+
+	var added, updated, deleted int
+	for _, p := range plans {
+		added += len(p.Added)
+		updated += len(p.Updated)
+		deleted += len(p.Deleted)
+		if p.Empty() {
+			fmt.Fprintf(w, "%s: no changes\n", p.Project)
+			continue
+		}
+		fmt.Fprintf(w, "%s: %d to add, %d to update, %d to delete\n", p.Project, len(p.Added), len(p.Updated), len(p.Deleted))
+		for _, f := range p.Added {
+			fmt.Fprintf(w, "  + %s\n", f)
+		}
+		for _, f := range p.Updated {
+			fmt.Fprintf(w, "  ~ %s\n", f)
+		}
+		for _, f := range p.Deleted {
+			fmt.Fprintf(w, "  - %s\n", f)
+		}
+	}
+	fmt.Fprintf(w, "\nPlan: %d to add, %d to update, %d to delete.", added, updated, deleted)
+	if added+updated+deleted > 0 {
+		fmt.Fprint(w, " Run without --plan to apply.")
+	}
+	fmt.Fprintln(w)
+	if planErr != nil {
+		return fmt.Errorf("planning failed: %w", planErr)
+	}
+	return nil
+}
+
+// lockIndexRun takes the whole index lock in the data dir for a command
+// that writes every project's state. When another run holds it or a
+// project lock, lockIndexRun fails, or with wait blocks until they are
+// free or ctx is done.
+func lockIndexRun(ctx context.Context, wait bool) (*state.Lock, error) {
+	return takeLock(wait, func(dataDir string, wait bool) (*state.Lock, error) {
+		return state.AcquireLock(ctx, dataDir, wait)
+	})
+}
+
+// lockProjects takes the locks of the projects a run writes, so runs on
+// other projects can go on alongside it. Like lockIndexRun, it fails while
+// another run holds one of them unless wait is set.
+func lockProjects(ctx context.Context, projects []string, wait bool) (*state.Lock, error) {
+	return takeLock(wait, func(dataDir string, wait bool) (*state.Lock, error) {
+		return state.LockProjects(ctx, dataDir, projects, wait)
+	})
+}
+
+func takeLock(wait bool, acquire func(dataDir string, wait bool) (*state.Lock, error)) (*state.Lock, error) {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return nil, err
+	}
+	lock, err := acquire(dataDir, false)
+	var locked *state.LockedError
+	if !errors.As(err, &locked) {
+		return lock, err
+	}
+	if !wait {
+		return nil, fmt.Errorf("%w; use --wait to wait for it to finish", err)
+	}
+	slog.Info("waiting for another run to finish", "pid", locked.PID, "project", locked.Project)
+	return acquire(dataDir, true)
+}
+
+// runProjects returns the project paths a run indexes, to lock them: those
+// of its directories, repository URLs, archives and files
+func runProjects(paths, urls, archives, files []string) ([]string, error) {
+	var projects []string
+	for _, path := range slices.Concat(paths, archives) {
+		abs, err := walker.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, abs)
+	}
+	for _, url := range urls {
+		projects = append(projects, remote.ProjectPath(url))
+	}
+	finder := indexer.NewRootFinder()
+	for _, file := range files {
+		abs, err := walker.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, finder.Root(abs))
+	}
+	return projects, nil
+}
+
+// interruptContext returns a context cancelled by the first Ctrl-C or
+// SIGTERM, upon which runs stop starting files, finish and flush the ones
+// in flight and save their progress. A second signal kills the process.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+			signal.Stop(sigs)
+			slog.Warn("interrupted: finishing files in flight and saving progress; interrupt again to quit now")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+	}
+}
+
+// newIndexer connects to Typesense and Gemini and returns an indexer
+// ready to write to the configured collection. Progress is drawn as a bar
+// when stderr is a terminal and logged periodically otherwise.
+func newIndexer(ctx context.Context, cmd *cobra.Command, cfg *config.Config) (*indexer.Indexer, error) {
+	store, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.EnsureCollection(ctx); err != nil {
+		return nil, fmt.Errorf("ensuring collection: %w", err)
+	}
+
+	embedder, err := newGeminiClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	idx := indexer.NewIndexer(cfg, store, embedder)
+	if cfg.SummaryModel != "" {
+		embedder.SetSummaryModel(cfg.SummaryModel)
+		idx.SetSummarizer(embedder)
+	}
+	if showProgressBar(cmd) {
+		idx.SetProgress(progress.NewBar(cmd.ErrOrStderr()))
+	}
+	return idx, nil
+}
+
+// newTypesenseClient returns a client for collection using the configured
+// URL, key, timeout, proxy and search matching.
+func newTypesenseClient(cfg *config.Config, collection string) (*indexer.TypesenseClient, error) {
+	client, err := indexer.NewTypesenseClient(cfg.TypesenseURL, cfg.TypesenseAPIKey, collection)
+	if err != nil {
+		return nil, err
+	}
+	hc, err := newHTTPClient(cfg.TypesenseTimeout, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHTTPClient(hc)
+	client.SetMatching(indexer.Matching{
+		NumTypos:            cfg.NumTypos,
+		Prefix:              cfg.Prefix,
+		DropTokensThreshold: cfg.DropTokensThreshold,
+	})
+	if cfg.ContentKey != "" {
+		// Config validation has checked the key's encoding and length
+		key, _ := base64.StdEncoding.DecodeString(cfg.ContentKey)
+		if err := client.SetContentKey(key); err != nil {
+			return nil, configError(fmt.Errorf("SWARM_INDEXER_CONTENT_KEY: %w", err))
+		}
+	}
+	return client, nil
+}
+
+// newGeminiClient returns an embeddings client using the configured key,
+// model, rate limit, timeout and proxy.
+func newGeminiClient(cfg *config.Config) (*embeddings.GeminiClient, error) {
+	client := embeddings.NewGeminiClient(cfg.GeminiAPIKey, cfg.GeminiModel, cfg.GeminiRateLimit)
+	hc, err := newHTTPClient(cfg.GeminiTimeout, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHTTPClient(hc)
+	return client, nil
+}
+
+// newHTTPClient returns a client for outgoing requests whose spans are
+// traced when tracing is on; see httpclient.New for timeout and proxy.
+func newHTTPClient(timeout time.Duration, proxy string) (*http.Client, error) {
+	client, err := httpclient.New(timeout, proxy)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = tracing.Transport(client.Transport)
+	return client, nil
+}
+
+// startTracing exports spans to the configured OTLP endpoint, if any.
+// Settings that can't be resolved leave tracing off: the command reports
+// them when it loads its config.
+func startTracing() error {
+	values, err := config.Resolve()
+	if err != nil {
+		return nil
+	}
+	for _, v := range values {
+		if v.Key == "otlp_endpoint" {
+			if err := tracing.Start(context.Background(), v.Value); err != nil {
+				return configError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// showProgressBar reports whether a live progress bar should be drawn:
+// only on a terminal, and not when logs are quiet or meant for machines.
+func showProgressBar(cmd *cobra.Command) bool {
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	format, _ := cmd.Flags().GetString("log-format")
+	return !quiet && format != "json" && progress.IsTerminal(cmd.ErrOrStderr())
+}
+
+// runSummary is what index --json and reindex --json print, and index
+// --report writes
+type runSummary struct {
+	Files  int                 `json:"files"` // files indexed
+	Chunks int64               `json:"chunks"`
+	Tokens int64               `json:"tokens"` // estimated
+	Failed []indexer.FileError `json:"failed"`
+	// Remaining lists the files left for the next run once the embedding
+	// budget was reached
+	Remaining []indexer.Remaining `json:"remaining,omitempty"`
+	// Projects reports what the run did in each project indexed from a
+	// directory or a list of files
+	Projects []indexer.PathReport `json:"projects,omitempty"`
+	Error    string               `json:"error,omitempty"` // why the run failed, if it did
+}
+
+func newRunSummary(idx *indexer.Indexer, runErr error) runSummary {
+	u := idx.Usage()
+	summary := runSummary{
+		Files:     idx.IndexedFiles(),
+		Chunks:    u.Chunks,
+		Tokens:    u.Tokens,
+		Failed:    idx.Failures(),
+		Remaining: idx.Remaining(),
+		Projects:  idx.Report(),
+	}
+	if summary.Failed == nil {
+		summary.Failed = []indexer.FileError{}
+	}
+	if runErr != nil {
+		summary.Error = runErr.Error()
+	}
+	return summary
+}
+
+// writeRunReport writes the run's summary as JSON to the file at path
+func writeRunReport(path string, idx *indexer.Indexer, runErr error) error {
+	data, err := json.MarshalIndent(newRunSummary(idx, runErr), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// reportRun prints the run's summary as JSON to stdout, or else lists the
+// files that failed and why, and how many files each project has left
+// once the embedding budget was reached, on stderr
+func reportRun(cmd *cobra.Command, idx *indexer.Indexer, jsonOutput bool, runErr error) error {
+	if jsonOutput {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(newRunSummary(idx, runErr))
+	}
+	failures := idx.Failures()
+	remaining := idx.Remaining()
+
+	w := cmd.ErrOrStderr()
+	if len(failures) > 0 {
+		fmt.Fprintf(w, "%d files failed to index:\n", len(failures))
+		for _, f := range failures {
+			path := filepath.Join(f.Project, f.Path)
+			if strings.Contains(f.Path, "://") {
+				path = f.Path
+			}
+			fmt.Fprintf(w, "  %s (%s): %s\n", path, f.Op, f.Error)
+		}
+	}
+	if len(remaining) > 0 {
+		fmt.Fprintf(w, "Embedding budget reached; %d files left to index (run again to continue):\n", remainingFiles(remaining))
+		for _, r := range remaining {
+			fmt.Fprintf(w, "  %s: %d files\n", r.Project, len(r.Files))
+		}
+	}
+	if n := unsampledFiles(idx.Report()); n > 0 {
+		fmt.Fprintf(w, "Indexed a sample; %d new files left out (run again without --sample to index them)\n", n)
+	}
+	return nil
+}
+
+// unsampledFiles counts the files a sample left out of every project
+func unsampledFiles(reports []indexer.PathReport) int {
+	n := 0
+	for _, r := range reports {
+		n += r.Skipped["not sampled"]
+	}
+	return n
+}
+
+// reportSkipped lists the files each project left out and why, and how
+// many were unchanged
+func reportSkipped(w io.Writer, reports []indexer.PathReport) {
+	for _, r := range reports {
+		if len(r.SkippedFiles) > 0 {
+			fmt.Fprintf(w, "%s: %d files skipped:\n", r.Project, len(r.SkippedFiles))
+			for _, f := range r.SkippedFiles {
+				fmt.Fprintf(w, "  %s (%s)\n", f.Path, f.Reason)
+			}
+		}
+		if n := r.Skipped["unchanged"]; n > 0 {
+			fmt.Fprintf(w, "%s: %d files unchanged since the last run\n", r.Project, n)
+		}
+	}
+}
+
+// remainingFiles counts the files left in every project
+func remainingFiles(remaining []indexer.Remaining) int {
+	n := 0
+	for _, r := range remaining {
+		n += len(r.Files)
+	}
+	return n
+}
+
+// failedFilesError reports files the indexer had to skip because they
+// couldn't be processed, or left once the embedding budget was reached,
+// so the run exits with the partial-failure code.
+func failedFilesError(idx *indexer.Indexer) error {
+	var errs []error
+	if n := idx.FailedFiles(); n > 0 {
+		errs = append(errs, fmt.Errorf("%d files failed to index", n))
+	}
+	if n := remainingFiles(idx.Remaining()); n > 0 {
+		errs = append(errs, fmt.Errorf("embedding budget reached with %d files left to index", n))
+	}
+	return withExitCode(exitPartial, errors.Join(errs...))
+}
+
+// recordRun adds the run's embedding usage to the persisted totals shown
+// by the stats command and records the run in the state database's run
+// history. A run whose ctx was cancelled is recorded as aborted. It
+// returns the run as recorded.
+func recordRun(ctx context.Context, idx *indexer.Indexer, start time.Time, paths []string) *state.Run {
+	u := idx.Usage()
+	u.Runs = 1
+
+	run := &state.Run{
+		Start:   start.Unix(),
+		End:     time.Now().Unix(),
+		Paths:   runPaths(paths),
+		Files:   int64(idx.IndexedFiles()),
+		Chunks:  u.Chunks,
+		Tokens:  u.Tokens,
+		Failed:  int64(idx.FailedFiles()),
+		Aborted: ctx.Err() != nil,
+	}
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		slog.Warn("failed to record usage", "err", err)
+		return run
+	}
+	if err := usage.Record(dataDir, u); err != nil {
+		slog.Warn("failed to record usage", "err", err)
+	}
+	db, err := state.Open(dataDir)
+	if err == nil {
+		err = db.AddRun(run)
+		db.Close()
+	}
+	if err != nil {
+		slog.Warn("failed to record run history", "err", err)
+	}
+	return run
+}
+
+// runPaths returns the project paths of a run's arguments as recorded
+func runPaths(paths []string) []string {
+	abs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		switch {
+		case remote.IsURL(p):
+			p = remote.ProjectPath(p)
+		case strings.HasPrefix(p, indexer.DocProjectPath):
+			// documents are recorded as doc:<id>
+		default:
+			if a, err := filepath.Abs(p); err == nil {
+				p = a
+			}
+		}
+		abs = append(abs, p)
+	}
+	return abs
+}
+
+// notifyStartFailure tells the webhook about a run over paths that
+// couldn't start, such as when Typesense is unreachable
+func notifyStartFailure(cmd *cobra.Command, cfg *config.Config, start time.Time, paths []string, err error) {
+	run := &state.Run{Start: start.Unix(), End: time.Now().Unix(), Paths: runPaths(paths)}
+	notifyRun(cmd, cfg, run, err)
+}
+
+// webhookTimeout bounds how long a run waits for its webhook
+const webhookTimeout = 10 * time.Second
+
+// notifyRun posts run, which ended with runErr, to the configured webhook
+// unless it only wants failures and the run succeeded. Delivery failures
+// are logged; they never fail the run.
+func notifyRun(cmd *cobra.Command, cfg *config.Config, run *state.Run, runErr error) {
+	if cfg.WebhookURL == "" {
+		return
+	}
+	status := notify.Status(run, runErr)
+	if cfg.WebhookOn == config.WebhookFailure && !notify.Failure(status) {
+		return
+	}
+	host, _ := os.Hostname()
+	event := notify.Event{Command: cmd.CommandPath(), Status: status, Host: host, Run: *run}
+	if runErr != nil {
+		event.Error = runErr.Error()
+	}
+
+	client, err := newHTTPClient(webhookTimeout, cfg.Proxy)
+	if err == nil {
+		// The run's context may be cancelled; the webhook should hear of it
+		// anyway
+		err = notify.Send(context.Background(), client, cfg.WebhookURL, event)
+	}
+	if err != nil {
+		slog.Warn("failed to notify webhook", "err", err)
+	}
+}
+
+// readFilesFrom reads a newline-delimited file list from name, or from
+// stdin when name is "-". Blank lines are ignored.
+func readFilesFrom(stdin io.Reader, name string) ([]string, error) {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var files []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading file list: %w", err)
+	}
+	return files, nil
+}
+
+// registerPaths records indexed paths in the registry
+func registerPaths(paths []string) error {
+	configDir, reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, path := range paths {
+		added, err := reg.Add(path)
+		if err != nil {
+			return err
+		}
+		changed = changed || added
+	}
+	if !changed {
+		return nil
+	}
+	return reg.Save(configDir)
 }
 
 func newSearchCmd() *cobra.Command {
-	var limit int
+	var opts search.Options
 	var jsonOutput bool
+	var saveName, savedName string
+	var listSaved bool
+	var boostSpecs []string
 
 	cmd := &cobra.Command{
 		Use:   "search <query>",
 		Short: "Search indexed content",
-		Long:  "Search indexed content using hybrid text and vector search.",
-		Args:  cobra.ExactArgs(1),
+		Long: `Search indexed content using hybrid text and vector search.
+
+Queries can be stored with --save and re-run later with --saved, which
+restores the stored query, filters and output flags. Flags given on the
+command line override the stored values.
+
+Results under a project or path prefix can be ranked higher with
+--boost prefix=weight (repeatable) or SWARM_INDEXER_BOOST. Relative
+prefixes are resolved against the working directory, so --boost .=2
+favours the repository you are working in.
+
+Keyword matching tolerates typos and matches the last word as a prefix,
+which helps prose but can match unrelated code identifiers. Tighten it
+with --num-typos 0, --prefix=false and --drop-tokens-threshold 0 (never
+retry the query with words dropped), or the search_* settings.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if savedName != "" || listSaved {
+				return cobra.MaximumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			query := args[0]
 			ctx := context.Background()
 
-			// TODO: Create real Typesense searcher when indexer is implemented
-			// For now, return empty results
-			searcher := &search.MockSearcher{
-				Results:    []search.SearchResult{},
-				EmptyIndex: true,
+			configDir, err := config.Dir()
+			if err != nil {
+				return fmt.Errorf("resolving config dir: %w", err)
+			}
+
+			if listSaved {
+				saved, err := search.LoadSaved(configDir)
+				if err != nil {
+					return err
+				}
+				for _, name := range search.SavedNames(saved) {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", name, saved[name].Query)
+				}
+				return nil
+			}
+
+			if !cmd.Flags().Changed("boost") {
+				if env := os.Getenv("SWARM_INDEXER_BOOST"); env != "" {
+					boostSpecs = strings.Split(env, ",")
+				}
+			}
+			boosts, err := parseBoostFlags(boostSpecs)
+			if err != nil {
+				return err
+			}
+			opts.Boosts = boosts
+			// Remote repositories, crawled sites and documents are
+			// indexed under their URL or doc: path as is
+			if p := opts.ProjectPath; p != "" && !remote.IsURL(p) && !strings.HasPrefix(p, indexer.DocProjectPath) {
+				abs, err := walker.Abs(p)
+				if err != nil {
+					return fmt.Errorf("resolving project %q: %w", p, err)
+				}
+				opts.ProjectPath = abs
+			}
+
+			var query string
+			if savedName != "" {
+				saved, err := search.GetSaved(configDir, savedName)
+				if err != nil {
+					return err
+				}
+				query = saved.Query
+				applySavedSearch(cmd, saved, &opts, &jsonOutput)
+			}
+			if len(args) > 0 {
+				query = args[0]
+			}
+
+			if saveName != "" {
+				saved := search.SavedSearch{Query: query, Options: opts, JSON: jsonOutput}
+				if err := search.SaveSearch(configDir, saveName, saved); err != nil {
+					return fmt.Errorf("saving search: %w", err)
+				}
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			searcher, err := newSearcher(cfg)
+			if err != nil {
+				return err
 			}
 
-			results, err := search.Search(ctx, searcher, query, limit)
+			results, err := search.Search(ctx, searcher, query, opts)
 			if err != nil {
 				return fmt.Errorf("search failed: %w", err)
 			}
@@ -74,19 +950,149 @@ func newSearchCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().IntVar(&limit, "limit", 10, "Maximum number of results to return")
+	cmd.Flags().IntVar(&opts.Limit, "limit", 10, "Maximum number of results to return")
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	cmd.Flags().StringVar(&opts.Language, "language", "", "Only return results in this language")
+	cmd.Flags().StringVar(&opts.ChunkType, "chunk-type", "", "Only return results of this chunk type")
+	cmd.Flags().StringVar(&opts.ProjectPath, "project", "", "Only return results from this project: a path, relative to the working directory, or a repository URL")
+	cmd.Flags().StringArrayVar(&opts.Tags, "tag", nil, "Only return results with this tag, set by index --tag (repeatable; results must have all)")
+	cmd.Flags().StringVar(&saveName, "save", "", "Save this query and its flags under a name")
+	cmd.Flags().StringVar(&savedName, "saved", "", "Run a previously saved search")
+	cmd.Flags().BoolVar(&listSaved, "list-saved", false, "List saved searches")
+	cmd.Flags().StringArrayVar(&boostSpecs, "boost", nil, "Boost results under a path prefix (prefix=weight, repeatable)")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings, matchingSettings)
 
 	return cmd
 }
 
+// applySavedSearch copies stored values into opts and jsonOutput for every
+// flag the user didn't set explicitly.
+func applySavedSearch(cmd *cobra.Command, saved search.SavedSearch, opts *search.Options, jsonOutput *bool) {
+	flags := cmd.Flags()
+	if !flags.Changed("limit") && saved.Options.Limit > 0 {
+		opts.Limit = saved.Options.Limit
+	}
+	if !flags.Changed("language") {
+		opts.Language = saved.Options.Language
+	}
+	if !flags.Changed("chunk-type") {
+		opts.ChunkType = saved.Options.ChunkType
+	}
+	if !flags.Changed("project") {
+		opts.ProjectPath = saved.Options.ProjectPath
+	}
+	if !flags.Changed("tag") {
+		opts.Tags = saved.Options.Tags
+	}
+	if !flags.Changed("json") {
+		*jsonOutput = saved.JSON
+	}
+	if !flags.Changed("boost") && len(saved.Options.Boosts) > 0 {
+		opts.Boosts = saved.Options.Boosts
+	}
+}
+
+// parseBoostFlags parses --boost values, resolving relative prefixes
+// against the working directory.
+func parseBoostFlags(specs []string) ([]search.Boost, error) {
+	boosts, err := search.ParseBoosts(specs)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range boosts {
+		if filepath.IsAbs(b.Prefix) {
+			continue
+		}
+		abs, err := filepath.Abs(b.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("resolving boost prefix %q: %w", b.Prefix, err)
+		}
+		boosts[i].Prefix = abs
+	}
+	return boosts, nil
+}
+
 func newStatusCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "status",
+	var jsonOutput, showChanges, check bool
+
+	cmd := &cobra.Command{
+		Use:   "status [path...]",
 		Short: "Show indexer status",
-		Long:  "Show the current status of the swarm-indexer.",
+		Long: `Show the current status of the swarm-indexer: per-path metadata, pending
+changes, Typesense collection stats and connectivity to Typesense and
+Gemini (reachability, credentials and latency).
+
+Without arguments, status reports on every registered path.
+
+With reindex_after set, paths last indexed longer ago than it are flagged
+as due a re-index, which the next index run does from scratch.
+
+With --check, status exits with code 6 when any path has changes that
+need re-indexing or is due one, so CI can detect a stale index.`,
+		ValidArgsFunction: completeRegisteredPaths,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			_, reg, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+
+			paths := args
+			if len(paths) == 0 {
+				paths = reg.Paths()
+			}
+
+			opts := status.Options{JSON: jsonOutput, ShowChanges: showChanges, Registered: reg.Paths()}
+			if local, err := config.LoadLocal(); err == nil {
+				opts.ReindexAfter = local.ReindexAfter
+			}
+			cfg, err := config.Load()
+			if err != nil {
+				opts.StoreErr = err
+			} else {
+				opts.Collection = cfg.TypesenseCollection
+				opts.CollectionURL = cfg.TypesenseURL
+				client, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+				if err != nil {
+					opts.StoreErr = err
+				} else {
+					opts.Store = client
+					opts.Services = append(opts.Services, status.Service{Name: "Typesense", Pinger: client})
+				}
+
+				if gemini, err := newGeminiClient(cfg); err == nil {
+					opts.Services = append(opts.Services, status.Service{
+						Name:      "Gemini",
+						Pinger:    gemini,
+						QuotaHint: fmt.Sprintf("%d requests/min configured (GEMINI_RATE_LIMIT)", gemini.RateLimit()),
+					})
+				}
+			}
+
+			report := status.Collect(ctx, paths, opts)
+			if jsonOutput {
+				if err := status.WriteJSON(cmd.OutOrStdout(), report); err != nil {
+					return err
+				}
+			} else {
+				status.WriteText(cmd.OutOrStdout(), report)
+			}
+
+			if check {
+				if n := report.ChangedPaths(); n > 0 {
+					cmd.SilenceUsage = true
+					return withExitCode(exitChanges, fmt.Errorf("%d paths have changes that need re-indexing", n))
+				}
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output status as JSON")
+	cmd.Flags().BoolVar(&showChanges, "changes", false, "List new, modified and deleted files for paths with changes")
+	cmd.Flags().BoolVar(&check, "check", false, "Exit with code 6 if any path needs re-indexing")
+	addConfigFlags(cmd, typesenseSettings, []string{"reindex_after"})
+
+	return cmd
 }
@@ -2,10 +2,31 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/notify"
+	"github.com/dvaida/swarm-indexer/internal/registry"
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/dvaida/swarm-indexer/internal/testenv"
+	"github.com/spf13/cobra"
 )
 
+// TestMain keeps metadata written by the tests out of the user's data dir.
+func TestMain(m *testing.M) {
+	testenv.Main(m)
+}
+
 func TestRootCommand_Help(t *testing.T) {
 	cmd := newRootCmd()
 	buf := new(bytes.Buffer)
@@ -50,6 +71,11 @@ func TestIndexCommand_Help(t *testing.T) {
 	if !strings.Contains(output, "index") {
 		t.Errorf("expected help output to contain 'index', got:\n%s", output)
 	}
+	// Per-flag detail belongs in the flags' usage, not the summary
+	summary, _, _ := strings.Cut(output, "Usage:")
+	if n := strings.Count(summary, "\n"); n > 30 {
+		t.Errorf("expected a summary of at most 30 lines before the usage, got %d", n)
+	}
 }
 
 func TestSearchCommand_Help(t *testing.T) {
@@ -93,15 +119,257 @@ func TestStatusCommand_Help(t *testing.T) {
 	}
 }
 
-func TestIndexCommand_Runs(t *testing.T) {
+func TestIndexCommand_RequiresConfig(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+
 	cmd := newRootCmd()
 	buf := new(bytes.Buffer)
 	cmd.SetOut(buf)
-	cmd.SetArgs([]string{"index", "/tmp"})
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"index", t.TempDir()})
 
 	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("expected error when API keys are missing")
+	}
+	if !strings.Contains(err.Error(), "TYPESENSE_API_KEY") {
+		t.Errorf("expected config error, got %v", err)
+	}
+}
+
+func TestIndexCommand_RequiresPath(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"index"})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("expected error when no path provided and none registered")
+	}
+	if !strings.Contains(err.Error(), "register") {
+		t.Errorf("expected error to suggest registering, got %v", err)
+	}
+}
+
+func TestIndexCommand_RejectsMissingPath(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"index", t.TempDir(), "/nonexistent/path/for/test"})
+
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
+
+func TestIndexCommand_RejectsInvalidSample(t *testing.T) {
+	_, err := runRoot(t, "index", "--sample", "200%", t.TempDir())
+	if code := exitCode(err); code != exitUsage || !strings.Contains(err.Error(), "invalid sample") {
+		t.Errorf("expected a usage error, got %d (%v)", code, err)
+	}
+}
+
+func TestIndexCommand_FailsWhileLocked(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
+
+	lock, err := state.AcquireLock(context.Background(), dataDir, false)
 	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"index", t.TempDir()})
+
+	err = cmd.Execute()
+	if err == nil {
+		t.Fatal("expected an error while another run holds the lock")
+	}
+	if !strings.Contains(err.Error(), "--wait") {
+		t.Errorf("expected the error to mention --wait, got %v", err)
+	}
+}
+
+func TestIndexCommand_Plan(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("SWARM_INDEXER_DATA_DIR", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"index", "--plan", dir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("index --plan failed: %v\n%s", err, buf)
+	}
+	for _, want := range []string{dir + ": 1 to add", "  + a.go", "Plan: 1 to add, 0 to update, 0 to delete."} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf)
+		}
+	}
+}
+
+func TestIndexCommand_PlanJSON(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("SWARM_INDEXER_DATA_DIR", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"index", "--plan", "--json", dir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("index --plan --json failed: %v", err)
+	}
+	var plans []indexer.Plan
+	if err := json.Unmarshal(buf.Bytes(), &plans); err != nil {
+		t.Fatalf("expected JSON output, got %v:\n%s", err, buf)
+	}
+	if len(plans) != 1 || len(plans[0].Added) != 1 || plans[0].Added[0] != "a.go" {
+		t.Errorf("unexpected plans %+v", plans)
+	}
+}
+
+func TestReportRun(t *testing.T) {
+	cmd := &cobra.Command{}
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	idx := indexer.NewIndexer(&config.Config{}, nil, nil)
+
+	if err := reportRun(cmd, idx, true, errors.New("store unreachable")); err != nil {
+		t.Fatal(err)
+	}
+	var summary runSummary
+	if err := json.Unmarshal(stdout.Bytes(), &summary); err != nil {
+		t.Fatalf("expected JSON output, got %v:\n%s", err, stdout)
+	}
+	if summary.Failed == nil || summary.Error != "store unreachable" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if !strings.Contains(stdout.String(), `"failed": []`) {
+		t.Errorf("expected an empty failed list, got:\n%s", stdout)
+	}
+
+	stdout.Reset()
+	if err := reportRun(cmd, idx, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() != 0 || stderr.Len() != 0 {
+		t.Errorf("expected no output without failures, got %q, %q", stdout, stderr)
+	}
+}
+
+func TestReportSkipped(t *testing.T) {
+	var buf bytes.Buffer
+	reportSkipped(&buf, []indexer.PathReport{
+		{Project: "/repo", Skipped: map[string]int{"unchanged": 40, "binary": 1}, SkippedFiles: []indexer.SkippedFile{{Path: "logo.png", Reason: "binary"}}},
+		{Project: "/other", Skipped: map[string]int{}},
+	})
+	want := "/repo: 1 files skipped:\n  logo.png (binary)\n/repo: 40 files unchanged since the last run\n"
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteRunReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	idx := indexer.NewIndexer(&config.Config{}, nil, nil)
+	if err := writeRunReport(path, idx, errors.New("store unreachable")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary runSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("expected a JSON report, got %v:\n%s", err, data)
+	}
+	if summary.Failed == nil || summary.Error != "store unreachable" {
+		t.Errorf("unexpected report %+v", summary)
+	}
+}
+
+func TestNotifyRun(t *testing.T) {
+	var statuses []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e notify.Event
+		json.NewDecoder(r.Body).Decode(&e)
+		statuses = append(statuses, e.Status)
+	}))
+	defer server.Close()
+
+	cmd := &cobra.Command{Use: "index"}
+	cfg := &config.Config{WebhookURL: server.URL, WebhookOn: config.WebhookAlways}
+	notifyRun(cmd, cfg, &state.Run{Files: 3}, nil)
+	notifyRun(cmd, cfg, &state.Run{}, errors.New("store unreachable"))
+
+	cfg.WebhookOn = config.WebhookFailure
+	notifyRun(cmd, cfg, &state.Run{Files: 3}, nil)
+	notifyRun(cmd, cfg, &state.Run{Files: 3, Failed: 1}, nil)
+
+	want := []string{notify.StatusSucceeded, notify.StatusFailed, notify.StatusPartial}
+	if strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Errorf("expected notifications %v, got %v", want, statuses)
+	}
+}
+
+func TestReadFilesFrom(t *testing.T) {
+	input := strings.NewReader("a.go\n\n  docs/b.md  \nc.txt\n")
+
+	files, err := readFilesFrom(input, "-")
+	if err != nil {
+		t.Fatalf("readFilesFrom failed: %v", err)
+	}
+
+	want := []string{"a.go", "docs/b.md", "c.txt"}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
+
+func TestReadFilesFrom_File(t *testing.T) {
+	listPath := filepath.Join(t.TempDir(), "files.txt")
+	if err := os.WriteFile(listPath, []byte("one.go\ntwo.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := readFilesFrom(strings.NewReader("ignored\n"), listPath)
+	if err != nil {
+		t.Fatalf("readFilesFrom failed: %v", err)
+	}
+	if len(files) != 2 || files[0] != "one.go" || files[1] != "two.go" {
+		t.Errorf("expected [one.go two.go], got %v", files)
 	}
 }
 
@@ -164,3 +432,155 @@ func TestStatusCommand_Runs(t *testing.T) {
 		t.Fatalf("expected no error, got %v", err)
 	}
 }
+
+func TestSearchCommand_SaveAndRunSaved(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	ts := &fakeSearch{}
+	ts.serve(t)
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"search", "auth flows", "--language", "go", "--json", "--save", "my-auth-flows"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cmd = newRootCmd()
+	buf.Reset()
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"search", "--saved", "my-auth-flows"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// Saved --json flag should be restored
+	if !strings.HasPrefix(strings.TrimSpace(buf.String()), "[") {
+		t.Errorf("expected JSON output from saved search, got:\n%s", buf.String())
+	}
+
+	cmd = newRootCmd()
+	buf.Reset()
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"search", "--list-saved"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "my-auth-flows") {
+		t.Errorf("expected saved search in list, got:\n%s", buf.String())
+	}
+}
+
+func TestSearchCommand_UnknownSaved(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"search", "--saved", "missing"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for unknown saved search")
+	}
+}
+
+func TestSearchCommand_InvalidBoost(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"search", "query", "--boost", "/work=notanumber"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for invalid boost")
+	}
+}
+
+func TestStatusCommand_JSONFlag(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "")
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"status", "--json", t.TempDir()})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var report map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("expected JSON output, got %v:\n%s", err, buf.String())
+	}
+	if _, ok := report["paths"]; !ok {
+		t.Error("expected 'paths' key in JSON output")
+	}
+	if _, ok := report["collection"]; !ok {
+		t.Error("expected 'collection' key in JSON output")
+	}
+}
+
+func TestStatusCommand_NoArgsUsesRegistry(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", configDir)
+	t.Setenv("TYPESENSE_API_KEY", "")
+
+	project := t.TempDir()
+	reg := &registry.Registry{}
+	if _, err := reg.Add(project); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Save(configDir); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"status"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), project) {
+		t.Errorf("expected registered path %s in output, got:\n%s", project, buf.String())
+	}
+}
+
+func TestShowProgressBar_NotOnPipes(t *testing.T) {
+	root := newRootCmd()
+	root.SetErr(new(bytes.Buffer))
+	cmd, _, err := root.Find([]string{"index"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if showProgressBar(cmd) {
+		t.Error("expected no progress bar when stderr is not a terminal")
+	}
+}
+
+func TestIndexCommand_FailsWhileProjectLocked(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
+
+	project := t.TempDir()
+	lock, err := state.LockProjects(context.Background(), dataDir, []string{project}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"index", project})
+
+	err = cmd.Execute()
+	want := fmt.Sprintf("another indexer is running (pid %d) on %s", os.Getpid(), project)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
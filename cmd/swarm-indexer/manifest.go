@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/manifest"
+)
+
+// manifestTargets returns the local paths and the URLs the manifest's
+// repos are indexed from
+func manifestTargets(m *manifest.Manifest) (paths, urls []string) {
+	for _, r := range m.Repos {
+		if r.URL != "" {
+			urls = append(urls, r.URL)
+		} else {
+			paths = append(paths, r.Path)
+		}
+	}
+	return paths, urls
+}
+
+// indexManifest indexes the manifest's repos in turn, each with its own
+// tags, ref and excludes, and prints a line on w saying what became of
+// each. A failure on one repo doesn't stop the others; all failures are
+// returned together.
+func indexManifest(ctx context.Context, w io.Writer, idx *indexer.Indexer, m *manifest.Manifest, withHistory bool) error {
+	defer idx.SetExcludes(nil)
+	defer idx.KeepTags()
+
+	var errs []error
+	for _, r := range m.Repos {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if r.Tags != nil {
+			idx.SetTags(r.Tags)
+		} else {
+			idx.KeepTags()
+		}
+		idx.SetExcludes(r.Excludes())
+
+		start := time.Now()
+		files, failed, chunks := idx.IndexedFiles(), idx.FailedFiles(), idx.Usage().Chunks
+		var err error
+		if r.URL != "" {
+			err = idx.IndexRemotes(ctx, []string{r.URL}, r.Ref)
+		} else {
+			err = idx.IndexPaths(ctx, []string{r.Path})
+			if err == nil && withHistory {
+				err = idx.IndexHistory(ctx, []string{r.Path})
+			}
+		}
+
+		summary := fmt.Sprintf("%d files indexed, %d chunks embedded", idx.IndexedFiles()-files, idx.Usage().Chunks-chunks)
+		if n := idx.FailedFiles() - failed; n > 0 {
+			summary += fmt.Sprintf(", %d files failed", n)
+		}
+		elapsed := time.Since(start).Round(100 * time.Millisecond)
+		if err != nil {
+			errs = append(errs, err)
+			fmt.Fprintf(w, "✗ %v (%s, %s)\n", err, summary, elapsed)
+			continue
+		}
+		fmt.Fprintf(w, "✓ %s: %s (%s)\n", r.Target(), summary, elapsed)
+	}
+	return errors.Join(errs...)
+}
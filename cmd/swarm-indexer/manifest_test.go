@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/bench"
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/manifest"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+)
+
+func TestIndexManifest(t *testing.T) {
+	api, web := t.TempDir(), t.TempDir()
+	for _, f := range []string{filepath.Join(api, "main.go"), filepath.Join(api, "gen.pb.go"), filepath.Join(web, "app.go")} {
+		if err := os.WriteFile(f, []byte("package main\n\nfunc main() {\n}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &manifest.Manifest{Repos: []manifest.Repo{
+		{Path: api, Tags: []string{"payments"}, Exclude: []string{"*.pb.go"}},
+		{Path: web},
+		{Path: filepath.Join(t.TempDir(), "missing")},
+	}}
+
+	idx := indexer.NewIndexer(&config.Config{}, bench.DiscardStore{}, &bench.MockEmbedder{Dim: 2})
+	var buf bytes.Buffer
+	err := indexManifest(context.Background(), &buf, idx, m, false)
+	if err == nil {
+		t.Error("expected the missing repo to fail")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a line per repo, got:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "✓ "+api+": 1 files indexed") {
+		t.Errorf("expected the excluded file left out, got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "✓ "+web+": 1 files indexed") {
+		t.Errorf("unexpected line %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "✗ ") {
+		t.Errorf("expected the missing repo to fail, got %q", lines[2])
+	}
+
+	// Tags and excludes belong to their repo only
+	apiMeta, _ := metadata.Load(api)
+	webMeta, _ := metadata.Load(web)
+	if !slices.Equal(apiMeta.Tags, []string{"payments"}) || len(webMeta.Tags) != 0 {
+		t.Errorf("unexpected tags %v / %v", apiMeta.Tags, webMeta.Tags)
+	}
+}
+
+func TestIndexCommand_ManifestTakesNoPaths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repos.yaml")
+	if err := os.WriteFile(path, []byte("repos:\n  - path: .\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := runRoot(t, "index", "--manifest", path, t.TempDir())
+	if code := exitCode(err); code != exitUsage {
+		t.Errorf("expected exit code %d, got %d (%v)", exitUsage, code, err)
+	}
+}
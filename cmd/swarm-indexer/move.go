@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/remote"
+	"github.com/spf13/cobra"
+)
+
+func newMoveCmd() *cobra.Command {
+	var wait bool
+
+	cmd := &cobra.Command{
+		Use:   "move OLD NEW",
+		Short: "Move an indexed project to the directory it was renamed to",
+		Long: `Tell the index that the project indexed at OLD now lives at NEW, after
+renaming or moving its directory. Its documents are rewritten under the
+new path, keeping their embeddings, and its metadata and registration
+follow it, so the next index of NEW only re-embeds what changed. Without
+it, indexing NEW embeds everything again and OLD's documents linger until
+pruned.
+
+NEW must exist and not be indexed yet. Remote repositories and archives
+can't be moved.`,
+		Example: `  mv ~/src/api ~/src/payments-api
+  swarm-indexer move ~/src/api ~/src/payments-api`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, to := args[0], args[1]
+			if remote.IsURL(from) || remote.IsURL(to) {
+				return withExitCode(exitUsage, errors.New("only local directories can be moved"))
+			}
+
+			ctx, stop := interruptContext()
+			defer stop()
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			if err := requireDirs([]string{to}); err != nil {
+				return err
+			}
+
+			projects, err := runProjects([]string{from, to}, nil, nil, nil)
+			if err != nil {
+				return err
+			}
+			lock, err := lockProjects(ctx, projects, wait)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			store, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+			if err != nil {
+				return err
+			}
+			// Nothing is embedded: the documents keep their embeddings
+			n, err := indexer.NewIndexer(cfg, store, nil).Move(ctx, from, to)
+			if err != nil {
+				return fmt.Errorf("moving %s: %w", from, err)
+			}
+
+			configDir, reg, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			removed, err := reg.Remove(from)
+			if err != nil {
+				return err
+			}
+			if removed {
+				if _, err := reg.Add(to); err != nil {
+					return err
+				}
+				if err := reg.Save(configDir); err != nil {
+					return err
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Moved %d documents from %s to %s\n", n, from, to)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for another index run to finish instead of failing")
+	addConfigFlags(cmd, typesenseSettings)
+	return cmd
+}
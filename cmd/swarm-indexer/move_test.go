@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+)
+
+func TestMoveCommand(t *testing.T) {
+	from := t.TempDir()
+	to := filepath.Join(t.TempDir(), "payments-api")
+	var imported, deleted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/documents/export"):
+			w.Write([]byte(`{"id":"a","rel_path":"main.go","abs_path":"` + from + `/main.go","file_path":"main.go","project_path":"` + from + `","project_root":"` + from + `","embedding":[1,0]}` + "\n"))
+		case strings.HasSuffix(r.URL.Path, "/documents/import"):
+			body, _ := io.ReadAll(r.Body)
+			imported = string(body)
+			w.Write([]byte(`{"success":true}`))
+		case r.Method == "DELETE":
+			deleted = r.URL.Query().Get("filter_by")
+			w.Write([]byte(`{"num_deleted": 1}`))
+		}
+	}))
+	defer server.Close()
+	t.Setenv("TYPESENSE_URL", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	if err := (&metadata.Metadata{LastIndexed: 1, FileCount: 1}).Save(from); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runRoot(t, "register", from); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runRoot(t, "move", from, to)
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if !strings.Contains(out, "Moved 1 documents") {
+		t.Errorf("expected a summary, got %q", out)
+	}
+	if !strings.Contains(imported, `"project_path":"`+to+`"`) || !strings.Contains(imported, `"embedding":[1,0]`) {
+		t.Errorf("expected the document upserted under %s with its embedding, got %s", to, imported)
+	}
+	if !strings.Contains(imported, `"abs_path":"`+to+`/main.go"`) {
+		t.Errorf("expected the document's absolute path moved, got %s", imported)
+	}
+	if deleted != "project_path:=`"+from+"`" {
+		t.Errorf("expected the old documents deleted, got filter %q", deleted)
+	}
+	if metadata.Exists(from) || !metadata.Exists(to) {
+		t.Error("expected the metadata moved")
+	}
+	if out, _ := runRoot(t, "register"); strings.TrimSpace(out) != to {
+		t.Errorf("expected only %s registered, got %q", to, out)
+	}
+
+	if _, err := runRoot(t, "move", from, to); err == nil {
+		t.Error("expected moving again to fail")
+	}
+}
+
+func runRoot(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return buf.String(), err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+	"github.com/spf13/cobra"
+)
+
+func newOverridesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "overrides",
+		Short: "Pin or hide documents in the results of given queries",
+		Long: `Manage the collection's overrides, which curate the results of common
+questions: a search for "deployment process" can be made to return the
+canonical runbook first, and to leave out an outdated one. Overrides apply
+from the next query on; nothing needs re-indexing.
+
+Overrides refer to documents, which are identified by their file and
+first line: add an override again after its files are moved, or edited
+so that their chunks start on other lines. "overrides list" shows
+documents no longer indexed as gone.`,
+	}
+	cmd.AddCommand(newOverridesAddCmd())
+	cmd.AddCommand(newOverridesListCmd())
+	cmd.AddCommand(newOverridesRmCmd())
+	return cmd
+}
+
+func newOverridesAddCmd() *cobra.Command {
+	var id string
+	var pins, hides []string
+	var contains bool
+
+	cmd := &cobra.Command{
+		Use:   "add QUERY",
+		Short: "Pin or hide files in the results of a query",
+		Long: `Add an override for searches of QUERY: the files given with --pin are
+placed at the top of the results, in order, starting with their first
+chunk, and every chunk of the files given with --hide is left out. Files
+are indexed paths on disk, resolved to their project like delete does.
+
+QUERY matches searches for exactly it, or with --contains, any search that
+contains it. The override's ID defaults to the query's words joined by
+underscores ("deployment_process"); adding an override with an existing ID
+replaces it.`,
+		Example: `  swarm-indexer overrides add "deployment process" --pin ~/src/ops/docs/deploy.md
+  swarm-indexer overrides add --contains deploy --hide ~/src/ops/docs/old-deploy.md`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := strings.TrimSpace(args[0])
+			if query == "" {
+				return withExitCode(exitUsage, errors.New("query cannot be empty"))
+			}
+			if len(pins) == 0 && len(hides) == 0 {
+				return withExitCode(exitUsage, errors.New("at least one --pin or --hide is required"))
+			}
+			o := indexer.Override{ID: id, Query: query, Contains: contains}
+			if o.ID == "" {
+				o.ID = synonymID([]string{query})
+			}
+
+			ctx, stop := interruptContext()
+			defer stop()
+
+			client, err := newCollectionClient()
+			if err != nil {
+				return err
+			}
+			for _, f := range pins {
+				ids, err := fileChunkIDs(ctx, client, f)
+				if err != nil {
+					return err
+				}
+				o.Pinned = append(o.Pinned, ids[0])
+			}
+			for _, f := range hides {
+				ids, err := fileChunkIDs(ctx, client, f)
+				if err != nil {
+					return err
+				}
+				o.Hidden = append(o.Hidden, ids...)
+			}
+			if err := client.UpsertOverride(ctx, o); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Added override %s: %d pinned, %d hidden documents\n", o.ID, len(o.Pinned), len(o.Hidden))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&id, "id", "", "ID of the override (default: the query's words joined by underscores)")
+	cmd.Flags().StringArrayVar(&pins, "pin", nil, "Place this file at the top of the results (repeatable, in order)")
+	cmd.Flags().StringArrayVar(&hides, "hide", nil, "Leave this file out of the results (repeatable)")
+	cmd.Flags().BoolVar(&contains, "contains", false, "Match any search containing the query, not only the exact query")
+	addConfigFlags(cmd, typesenseSettings)
+	return cmd
+}
+
+func newOverridesListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the overrides",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := interruptContext()
+			defer stop()
+
+			client, err := newCollectionClient()
+			if err != nil {
+				return err
+			}
+			overrides, err := client.ListOverrides(ctx)
+			if err != nil {
+				return err
+			}
+			var ids []string
+			for _, o := range overrides {
+				ids = append(ids, o.Pinned...)
+				ids = append(ids, o.Hidden...)
+			}
+			files, err := client.DocumentFiles(ctx, ids)
+			if err != nil {
+				return err
+			}
+			writeOverrides(cmd.OutOrStdout(), overrides, files)
+			return nil
+		},
+	}
+	addConfigFlags(cmd, typesenseSettings)
+	return cmd
+}
+
+func newOverridesRmCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rm ID...",
+		Short: "Remove overrides",
+		Long:  `Remove the overrides with the given IDs, as shown by "overrides list".`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := interruptContext()
+			defer stop()
+
+			client, err := newCollectionClient()
+			if err != nil {
+				return err
+			}
+			for _, id := range args {
+				if err := client.DeleteOverride(ctx, id); err != nil {
+					var apiErr *indexer.APIError
+					if errors.As(err, &apiErr) && apiErr.IsNotFound() {
+						return fmt.Errorf("no override %q", id)
+					}
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Removed override %s\n", id)
+			}
+			return nil
+		},
+	}
+	addConfigFlags(cmd, typesenseSettings)
+	return cmd
+}
+
+// fileChunkIDs returns the IDs of the indexed chunks of the file at path,
+// failing if it has none
+func fileChunkIDs(ctx context.Context, client *indexer.TypesenseClient, path string) ([]string, error) {
+	abs, err := walker.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	root := indexer.ProjectRoot(abs)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := client.FileChunkIDs(ctx, root, filepath.ToSlash(rel))
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s: %w", abs, err)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%s is not indexed", abs)
+	}
+	return ids, nil
+}
+
+// writeOverrides lists the overrides, showing their documents as the files
+// they belong to, each file once
+func writeOverrides(w io.Writer, overrides []indexer.Override, files map[string]string) {
+	if len(overrides) == 0 {
+		fmt.Fprintln(w, "No overrides defined")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tQUERY\tPINNED\tHIDDEN")
+	for _, o := range overrides {
+		query := fmt.Sprintf("%q", o.Query)
+		if o.Contains {
+			query = "*" + query + "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", o.ID, query, overrideFiles(o.Pinned, files), overrideFiles(o.Hidden, files))
+	}
+	tw.Flush()
+}
+
+// overrideFiles joins the files of the documents, in order, falling back
+// to the ID of documents no longer indexed
+func overrideFiles(ids []string, files map[string]string) string {
+	if len(ids) == 0 {
+		return "-"
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		name, ok := files[id]
+		if !ok {
+			name = id + " (gone)"
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
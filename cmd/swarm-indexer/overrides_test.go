@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeOverrides serves the overrides API of a Typesense collection, and
+// exports two chunks of every file
+func fakeOverrides(t *testing.T) map[string]map[string]interface{} {
+	t.Helper()
+	overrides := map[string]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/documents/export") {
+			filter := r.URL.Query().Get("filter_by")
+			if strings.HasPrefix(filter, "id:") {
+				fmt.Fprintln(w, `{"id": "docs/deploy.md-1", "abs_path": "/src/ops/docs/deploy.md"}`)
+				return
+			}
+			file := filter[strings.Index(filter, "rel_path:=`")+len("rel_path:=`"):]
+			file = file[:strings.Index(file, "`")]
+			if file == "missing.md" {
+				return
+			}
+			fmt.Fprintf(w, "{\"id\": %q, \"start_line\": 20}\n{\"id\": %q, \"start_line\": 1}\n", file+"-20", file+"-1")
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/collections/swarm-index/overrides/")
+		switch r.Method {
+		case "PUT":
+			var o map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&o)
+			o["id"] = id
+			overrides[id] = o
+			_ = json.NewEncoder(w).Encode(o)
+		case "GET":
+			var list []map[string]interface{}
+			for _, o := range overrides {
+				list = append(list, o)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"overrides": list})
+		case "DELETE":
+			if _, ok := overrides[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(overrides, id)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("TYPESENSE_URL", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("TYPESENSE_COLLECTION", "swarm-index")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	return overrides
+}
+
+func TestOverridesCommands(t *testing.T) {
+	overrides := fakeOverrides(t)
+	project := t.TempDir()
+	if err := os.Mkdir(filepath.Join(project, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	deploy := filepath.Join(project, "docs", "deploy.md")
+	old := filepath.Join(project, "docs", "old.md")
+
+	if _, err := runRoot(t, "overrides", "add", "Deployment process", "--pin", deploy, "--hide", old); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	o, ok := overrides["deployment_process"]
+	if !ok {
+		t.Fatalf("expected an override deployment_process, got %v", overrides)
+	}
+	includes, _ := json.Marshal(o["includes"])
+	excludes, _ := json.Marshal(o["excludes"])
+	if string(includes) != `[{"id":"docs/deploy.md-1","position":1}]` {
+		t.Errorf("expected the file's first chunk pinned, got %s", includes)
+	}
+	if string(excludes) != `[{"id":"docs/old.md-1"},{"id":"docs/old.md-20"}]` {
+		t.Errorf("expected every chunk of the file hidden, got %s", excludes)
+	}
+
+	if _, err := runRoot(t, "overrides", "add", "deploy", "--pin", filepath.Join(project, "missing.md")); err == nil || !strings.Contains(err.Error(), "is not indexed") {
+		t.Errorf("expected pinning a file that isn't indexed to fail, got %v", err)
+	}
+	if _, err := runRoot(t, "overrides", "add", "deploy"); exitCode(err) != exitUsage {
+		t.Errorf("expected a usage error without --pin or --hide, got %v", err)
+	}
+
+	out, err := runRoot(t, "overrides", "list")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	for _, want := range []string{`"Deployment process"`, "/src/ops/docs/deploy.md", "docs/old.md-1 (gone)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	if _, err := runRoot(t, "overrides", "rm", "deployment_process"); err != nil {
+		t.Fatalf("rm failed: %v", err)
+	}
+	if _, err := runRoot(t, "overrides", "rm", "deployment_process"); err == nil || !strings.Contains(err.Error(), `no override "deployment_process"`) {
+		t.Errorf("expected removing a missing override to fail, got %v", err)
+	}
+}
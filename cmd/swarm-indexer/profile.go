@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// profiling holds the index command's profiling flags
+type profiling struct {
+	pprofAddr  string
+	cpuProfile string
+	memProfile string
+}
+
+func (p *profiling) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&p.pprofAddr, "pprof", "", "Serve runtime profiles over HTTP at this address during the run, e.g. :6060 for http://localhost:6060/debug/pprof/")
+	cmd.Flags().StringVar(&p.cpuProfile, "cpu-profile", "", "Write a CPU profile of the run to this file, for go tool pprof")
+	cmd.Flags().StringVar(&p.memProfile, "mem-profile", "", "Write a heap profile to this file at the end of the run")
+}
+
+// start starts the profiling the flags ask for. The returned function
+// stops it and writes the heap profile; it must be called even when the
+// run fails.
+func (p *profiling) start() (func(), error) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if p.pprofAddr != "" {
+		shutdown, err := servePprof(p.pprofAddr)
+		if err != nil {
+			return nil, err
+		}
+		stops = append(stops, shutdown)
+	}
+	if p.cpuProfile != "" {
+		f, err := os.Create(p.cpuProfile)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("creating CPU profile: %w", err)
+		}
+		if err := rpprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			stop()
+			return nil, fmt.Errorf("starting CPU profile: %w", err)
+		}
+		stops = append(stops, func() {
+			rpprof.StopCPUProfile()
+			if err := f.Close(); err != nil {
+				slog.Warn("failed to write CPU profile", "file", p.cpuProfile, "err", err)
+			}
+		})
+	}
+	if p.memProfile != "" {
+		stops = append(stops, func() {
+			if err := writeHeapProfile(p.memProfile); err != nil {
+				slog.Warn("failed to write heap profile", "file", p.memProfile, "err", err)
+			}
+		})
+	}
+	return stop, nil
+}
+
+// servePprof serves the net/http/pprof handlers at addr until the
+// returned function is called
+func servePprof(addr string) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("starting pprof server: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("pprof server failed", "err", err)
+		}
+	}()
+	slog.Info("serving pprof", "url", "http://"+ln.Addr().String()+"/debug/pprof/")
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	// Up-to-date statistics of what is still live
+	runtime.GC()
+	if err := rpprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndex_WritesProfilesOnFailure(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+
+	dir := t.TempDir()
+	cpu, heap := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "heap.pprof")
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"index", "--cpu-profile", cpu, "--mem-profile", heap, t.TempDir()})
+	if got := exitCode(cmd.Execute()); got != exitConfig {
+		t.Fatalf("exit code %d, want %d", got, exitConfig)
+	}
+
+	for _, path := range []string{cpu, heap} {
+		info, err := os.Stat(path)
+		if err != nil || info.Size() == 0 {
+			t.Errorf("expected a profile at %s, got %v", path, err)
+		}
+	}
+}
+
+func TestIndex_PprofAddressInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	p := profiling{pprofAddr: ln.Addr().String()}
+	if _, err := p.start(); err == nil {
+		t.Error("expected --pprof on a port in use to fail")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/prune"
+	"github.com/spf13/cobra"
+)
+
+func newPruneCmd() *cobra.Command {
+	var dryRun, jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete documents whose source no longer exists",
+		Long: `Delete indexed documents that no longer correspond to anything on disk:
+projects whose directory is gone or that are no longer registered, and
+files that were removed from a registered project. The documents of
+projects registered with a TTL (register --ttl) that were last indexed
+longer than the TTL ago are deleted too, as are the tombstones of deleted
+files once delete_grace is over (at once without it).
+
+Use --dry-run to list what would be deleted without deleting it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			_, reg, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+			client, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+			if err != nil {
+				return err
+			}
+
+			targets, err := prune.Find(ctx, client, reg.Paths())
+			if err != nil {
+				return err
+			}
+			expired, err := prune.FindExpired(ctx, client, reg.TTLs(), time.Now())
+			if err != nil {
+				return err
+			}
+			tombstones, err := prune.FindTombstones(ctx, client, cfg.DeleteGrace, time.Now())
+			if err != nil {
+				return err
+			}
+			targets = append(append(targets, expired...), tombstones...)
+
+			out := cmd.OutOrStdout()
+			if jsonOutput {
+				if targets == nil {
+					targets = []prune.Target{}
+				}
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(targets); err != nil {
+					return err
+				}
+			} else {
+				writePruneTargets(out, targets)
+			}
+
+			if dryRun || len(targets) == 0 {
+				return nil
+			}
+
+			n, err := prune.Apply(ctx, client, targets)
+			if err != nil {
+				return err
+			}
+			if !jsonOutput {
+				fmt.Fprintf(out, "Deleted %d documents\n", n)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List stale documents without deleting them")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output the stale targets as JSON")
+	addConfigFlags(cmd, typesenseSettings, []string{"delete_grace"})
+	return cmd
+}
+
+func writePruneTargets(w io.Writer, targets []prune.Target) {
+	if len(targets) == 0 {
+		fmt.Fprintln(w, "Nothing to prune")
+		return
+	}
+
+	var docs int64
+	for _, t := range targets {
+		docs += t.NumDocuments
+		switch t.Reason {
+		case prune.ReasonMissing:
+			fmt.Fprintf(w, "%s (%d documents, path no longer exists)\n", t.ProjectPath, t.NumDocuments)
+		case prune.ReasonUnregistered:
+			fmt.Fprintf(w, "%s (%d documents, path not registered)\n", t.ProjectPath, t.NumDocuments)
+		case prune.ReasonTombstoned:
+			fmt.Fprintf(w, "%s (%d documents, tombstones of files deleted before %s)\n", t.ProjectPath, t.NumDocuments,
+				time.Unix(t.DeletedBefore, 0).Format(time.RFC3339))
+		case prune.ReasonExpired:
+			fmt.Fprintf(w, "%s (%d documents, expired: last indexed before %s)\n", t.ProjectPath, t.NumDocuments,
+				time.Unix(t.IndexedBefore, 0).Format(time.RFC3339))
+		default:
+			fmt.Fprintf(w, "%s (%d documents, file deleted)\n", filepath.Join(t.ProjectPath, t.FilePath), t.NumDocuments)
+		}
+	}
+	fmt.Fprintf(w, "%d stale documents in %d targets\n", docs, len(targets))
+}
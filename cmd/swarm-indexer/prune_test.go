@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/registry"
+)
+
+func TestPruneCommand(t *testing.T) {
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "kept.go"), []byte("package x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var deletes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "DELETE":
+			deletes = append(deletes, r.URL.Query().Get("filter_by"))
+			w.Write([]byte(`{"num_deleted": 2}`))
+		case strings.Contains(r.URL.Query().Get("filter_by"), "deleted:=true"):
+			w.Write([]byte(`{"facet_counts": [{"field_name": "project_path", "counts": []}]}`))
+		case r.URL.Query().Get("facet_by") == "project_path":
+			fmt.Fprintf(w, `{"facet_counts": [{"field_name": "project_path", "counts": [{"count": 4, "value": %q}]}]}`, project)
+		default:
+			w.Write([]byte(`{"facet_counts": [{"field_name": "rel_path", "counts": [{"count": 2, "value": "kept.go"}, {"count": 2, "value": "gone.go"}]}]}`))
+		}
+	}))
+	defer server.Close()
+
+	configDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", configDir)
+	t.Setenv("TYPESENSE_URL", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+
+	reg := &registry.Registry{}
+	if _, err := reg.Add(project); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Save(configDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dry run lists but doesn't delete
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"prune", "--dry-run"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("prune --dry-run failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), filepath.Join(project, "gone.go")) {
+		t.Errorf("expected gone.go to be listed, got:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "kept.go") {
+		t.Errorf("expected kept.go not to be listed, got:\n%s", buf.String())
+	}
+	if len(deletes) != 0 {
+		t.Fatalf("dry run should not delete, got %v", deletes)
+	}
+
+	cmd = newRootCmd()
+	buf.Reset()
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"prune"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	want := "project_path:=`" + project + "` && rel_path:=[`gone.go`]"
+	if len(deletes) != 1 || deletes[0] != want {
+		t.Errorf("expected delete filter %q, got %v", want, deletes)
+	}
+	if !strings.Contains(buf.String(), "Deleted 2 documents") {
+		t.Errorf("expected summary, got:\n%s", buf.String())
+	}
+}
+
+func TestExpirePass(t *testing.T) {
+	notes := t.TempDir()
+	var deletes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deletes = append(deletes, r.URL.Query().Get("filter_by"))
+			w.Write([]byte(`{"num_deleted": 3}`))
+			return
+		}
+		fmt.Fprintf(w, `{"facet_counts": [{"field_name": "project_path", "counts": [{"count": 3, "value": %q}]}]}`, notes)
+	}))
+	defer server.Close()
+
+	configDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", configDir)
+	reg := &registry.Registry{}
+	if _, err := reg.Add(notes); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.SetTTL(notes, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Save(configDir); err != nil {
+		t.Fatal(err)
+	}
+
+	expirePass(context.Background(), &config.Config{TypesenseURL: server.URL, TypesenseAPIKey: "test-key", TypesenseCollection: "test"})
+	if len(deletes) != 1 || !strings.HasPrefix(deletes[0], "project_path:=`"+notes+"` && last_indexed:<") {
+		t.Errorf("expected the notes' old documents deleted, got %v", deletes)
+	}
+}
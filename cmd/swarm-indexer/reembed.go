@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/spf13/cobra"
+)
+
+func newReembedCmd() *cobra.Command {
+	var projects []string
+	var wait bool
+
+	cmd := &cobra.Command{
+		Use:   "reembed",
+		Short: "Embed the indexed documents again with the configured model",
+		Long: `Compute the embedding of every indexed document again with the
+configured GEMINI_MODEL and update the documents in place, after changing
+the model. The documents are streamed from Typesense: nothing is read from
+disk or chunked again, so it costs only the embedding requests, and
+projects whose source is gone are re-embedded too.
+
+With --project, only the given projects (paths or repository URLs) are
+re-embedded; otherwise every project in the collection is. Like index,
+reembed fails while another run is indexing one of them unless --wait is
+given.`,
+		Example: `  swarm-indexer config set gemini_model text-embedding-005
+  swarm-indexer reembed
+  swarm-indexer reembed --project ~/src/api`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := interruptContext()
+			defer stop()
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			store, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+			if err != nil {
+				return err
+			}
+			embedder, err := newGeminiClient(cfg)
+			if err != nil {
+				return err
+			}
+
+			// Re-embedding every project takes the whole index lock, as the
+			// projects are only known once it is held
+			all := len(projects) == 0
+			var lock *state.Lock
+			if all {
+				lock, err = lockIndexRun(ctx, wait)
+			} else {
+				paths, urls := splitRemotes(projects)
+				if projects, err = runProjects(paths, urls, nil, nil); err != nil {
+					return err
+				}
+				lock, err = lockProjects(ctx, projects, wait)
+			}
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+			if all {
+				if projects, err = collectionProjects(ctx, store); err != nil {
+					return err
+				}
+			}
+
+			start := time.Now()
+			idx := indexer.NewIndexer(cfg, store, embedder)
+			n, runErr := idx.Reembed(ctx, projects)
+			recordRun(ctx, idx, start, projects)
+			if runErr != nil {
+				return indexingError(fmt.Errorf("re-embedding failed after %d documents: %w", n, runErr))
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Re-embedded %d documents in %d projects with %s\n", n, len(projects), cfg.GeminiModel)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&projects, "project", nil, "Only re-embed this project, a path or repository URL (repeatable)")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for another index run to finish instead of failing")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings, pipelineSettings)
+	return cmd
+}
+
+// collectionProjects returns the project paths with documents in the
+// store's collection, sorted
+func collectionProjects(ctx context.Context, store *indexer.TypesenseClient) ([]string, error) {
+	counts, err := store.ProjectPaths(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+	projects := make([]string, 0, len(counts))
+	for p := range counts {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/archive"
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/registry"
+	"github.com/dvaida/swarm-indexer/internal/remote"
+	"github.com/spf13/cobra"
+)
+
+func newRegisterCmd() *cobra.Command {
+	var ttl time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "register [path]...",
+		Short: "Register paths for indexing",
+		Long: `Add paths to the project registry. Commands such as index and status
+operate on every registered path when run without arguments.
+
+--ttl makes the documents of the paths expire, for scratch and notes
+directories: documents last indexed longer than the TTL ago are deleted by
+index and prune runs, and periodically by worker. A chunk is re-indexed, renewing it,
+only when it changes. Registering a path again with --ttl changes its TTL;
+--ttl 0 removes it.
+
+Without arguments, register lists the registered paths.`,
+		Example: `  swarm-indexer register ~/src/api
+  swarm-indexer register --ttl 168h ~/notes/scratch`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configDir, reg, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if len(args) == 0 {
+				if len(reg.Entries) == 0 {
+					fmt.Fprintln(out, "No paths registered")
+					return nil
+				}
+				for _, e := range reg.Entries {
+					if e.TTL > 0 {
+						fmt.Fprintf(out, "%s (ttl %s)\n", e.Path, e.TTL)
+					} else {
+						fmt.Fprintln(out, e.Path)
+					}
+				}
+				return nil
+			}
+			if ttl < 0 {
+				return withExitCode(exitUsage, fmt.Errorf("--ttl cannot be negative, got %s", ttl))
+			}
+
+			if err := requireDirs(args); err != nil {
+				return err
+			}
+
+			for _, path := range args {
+				added, err := reg.Add(path)
+				if err != nil {
+					return err
+				}
+				if added {
+					fmt.Fprintf(out, "Registered %s\n", path)
+				} else if !cmd.Flags().Changed("ttl") {
+					fmt.Fprintf(out, "%s is already registered\n", path)
+				}
+				if !cmd.Flags().Changed("ttl") {
+					continue
+				}
+				if _, err := reg.SetTTL(path, ttl); err != nil {
+					return err
+				}
+				if ttl > 0 {
+					fmt.Fprintf(out, "Documents of %s expire %s after they were last indexed\n", path, ttl)
+				} else if !added {
+					fmt.Fprintf(out, "Documents of %s no longer expire\n", path)
+				}
+			}
+			return reg.Save(configDir)
+		},
+	}
+
+	cmd.Flags().DurationVar(&ttl, "ttl", 0, "Expire the documents of the paths this long after they were last indexed (0 never)")
+	return cmd
+}
+
+func newUnregisterCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unregister <path>...",
+		Short: "Remove paths from the registry",
+		Long: `Remove paths from the project registry. Indexed documents are left in
+Typesense; status reports them as orphaned until they are pruned.`,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeRegisteredOnly,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configDir, reg, err := loadRegistry()
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			for _, path := range args {
+				removed, err := reg.Remove(path)
+				if err != nil {
+					return err
+				}
+				if removed {
+					fmt.Fprintf(out, "Unregistered %s\n", path)
+				} else {
+					fmt.Fprintf(out, "%s is not registered\n", path)
+				}
+			}
+			return reg.Save(configDir)
+		},
+	}
+}
+
+// loadRegistry resolves the config dir and loads the registry from it
+func loadRegistry() (string, *registry.Registry, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", nil, fmt.Errorf("resolving config dir: %w", err)
+	}
+	reg, err := registry.Load(configDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("loading registry: %w", err)
+	}
+	return configDir, reg, nil
+}
+
+// registeredPaths returns the registered paths, or an error telling the
+// user to register one when there are none.
+func registeredPaths() ([]string, error) {
+	_, reg, err := loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	paths := reg.Paths()
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths given and none registered; run 'swarm-indexer register <path>' first")
+	}
+	return paths, nil
+}
+
+// splitRemotes separates remote repository URLs from local paths
+func splitRemotes(args []string) (paths, urls []string) {
+	for _, arg := range args {
+		if remote.IsURL(arg) {
+			urls = append(urls, arg)
+		} else {
+			paths = append(paths, arg)
+		}
+	}
+	return paths, urls
+}
+
+// splitArchives separates archive files from directories
+func splitArchives(args []string) (paths, archives []string) {
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && info.Mode().IsRegular() && archive.IsArchive(arg) {
+			archives = append(archives, arg)
+		} else {
+			paths = append(paths, arg)
+		}
+	}
+	return paths, archives
+}
+
+// requireDirs checks that every path exists and is a directory
+func requireDirs(paths []string) error {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", path)
+		}
+	}
+	return nil
+}
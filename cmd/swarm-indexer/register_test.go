@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/registry"
+)
+
+func TestRegisterCommand(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", configDir)
+	project := t.TempDir()
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"register", project})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	reg, err := registry.Load(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reg.Contains(project) {
+		t.Errorf("expected %s to be registered, got %v", project, reg.Paths())
+	}
+
+	// Registering again is a no-op
+	cmd = newRootCmd()
+	buf.Reset()
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"register", project})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("second register failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "already registered") {
+		t.Errorf("expected already registered message, got %q", buf.String())
+	}
+
+	// No arguments lists registered paths
+	cmd = newRootCmd()
+	buf.Reset()
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"register"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("register list failed: %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != project {
+		t.Errorf("expected listing to contain %s, got %q", project, buf.String())
+	}
+}
+
+func TestRegisterCommand_TTL(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", configDir)
+	notes := t.TempDir()
+
+	if _, err := runRoot(t, "register", "--ttl", "168h", notes); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	out, err := runRoot(t, "register")
+	if err != nil {
+		t.Fatalf("register list failed: %v", err)
+	}
+	if strings.TrimSpace(out) != notes+" (ttl 168h0m0s)" {
+		t.Errorf("expected the TTL listed, got %q", out)
+	}
+
+	// Registering again changes the TTL
+	if _, err := runRoot(t, "register", "--ttl", "0", notes); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	reg, err := registry.Load(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reg.Contains(notes) || len(reg.TTLs()) != 0 {
+		t.Errorf("expected the TTL removed, got %+v", reg.Entries)
+	}
+
+	if _, err := runRoot(t, "register", "--ttl", "-1h", notes); exitCode(err) != exitUsage {
+		t.Errorf("expected a negative TTL to be a usage error, got %v", err)
+	}
+}
+
+func TestRegisterCommand_RejectsMissingPath(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"register", "/nonexistent/path/for/test"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
+
+func TestUnregisterCommand(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", configDir)
+	project := t.TempDir()
+
+	reg := &registry.Registry{}
+	if _, err := reg.Add(project); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Save(configDir); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"unregister", project, "/not/registered"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unregister failed: %v", err)
+	}
+
+	reg, err := registry.Load(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.Contains(project) {
+		t.Error("expected path to be unregistered")
+	}
+	if !strings.Contains(buf.String(), "is not registered") {
+		t.Errorf("expected note about unknown path, got %q", buf.String())
+	}
+}
+
+func TestSplitArchives(t *testing.T) {
+	dir := t.TempDir()
+	release := filepath.Join(dir, "release.tar.gz")
+	if err := os.WriteFile(release, []byte{0x1f, 0x8b}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A directory named like an archive is still a directory
+	unpacked := filepath.Join(dir, "vendor.zip")
+	if err := os.Mkdir(unpacked, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, archives := splitArchives([]string{dir, release, unpacked})
+	if len(archives) != 1 || archives[0] != release {
+		t.Errorf("expected only %s to be an archive, got %v", release, archives)
+	}
+	if len(paths) != 2 || paths[0] != dir || paths[1] != unpacked {
+		t.Errorf("expected the directories to be paths, got %v", paths)
+	}
+}
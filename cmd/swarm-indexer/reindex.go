@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newReindexCmd() *cobra.Command {
+	var force, wait, jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "reindex [path]...",
+		Short: "Index paths from scratch, ignoring change detection",
+		Long: `Clear the stored metadata for each path and index every file again,
+even if nothing has changed since the last run. The documents of files
+deleted since the last run are removed, as are the chunks the files no
+longer have once indexed again.
+
+With --force, the path's documents are first deleted from Typesense so
+chunks from removed files or an old schema or embedding model don't
+linger. Use it after upgrading the schema; after changing GEMINI_MODEL,
+reembed updates the documents without reading or chunking them again.
+
+Without arguments, every registered path is reindexed. Like index, reindex
+fails while another run is indexing one of its paths unless --wait is
+given.`,
+		ValidArgsFunction: completeRegisteredPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				var err error
+				args, err = registeredPaths()
+				if err != nil {
+					return err
+				}
+			}
+
+			ctx, stop := interruptContext()
+			defer stop()
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+
+			if err := requireDirs(args); err != nil {
+				return err
+			}
+
+			projects, err := runProjects(args, nil, nil, nil)
+			if err != nil {
+				return err
+			}
+			lock, err := lockProjects(ctx, projects, wait)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			start := time.Now()
+			idx, err := newIndexer(ctx, cmd, cfg)
+			if err != nil {
+				notifyStartFailure(cmd, cfg, start, args, err)
+				return err
+			}
+
+			runErr := idx.Reindex(ctx, args, force)
+			run := recordRun(ctx, idx, start, args)
+			notifyRun(cmd, cfg, run, runErr)
+			if err := reportRun(cmd, idx, jsonOutput, runErr); err != nil {
+				return err
+			}
+			if runErr != nil {
+				return indexingError(fmt.Errorf("reindexing failed: %w", runErr))
+			}
+			return failedFilesError(idx)
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Delete the path's documents from Typesense before reindexing")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for another index run to finish instead of failing")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print a summary of the run as JSON, including every failed file")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings, pipelineSettings, scanSettings, indexSettings)
+	return cmd
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReindexCommand_RequiresRegisteredPaths(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"reindex", "--force"})
+
+	err := cmd.Execute()
+	if err == nil {
+		t.Fatal("expected error when no path provided and none registered")
+	}
+	if !strings.Contains(err.Error(), "register") {
+		t.Errorf("expected error to suggest registering, got %v", err)
+	}
+}
+
+func TestReindexCommand_RequiresConfig(t *testing.T) {
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs([]string{"reindex", t.TempDir()})
+
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error when API keys are missing")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/search"
+)
+
+// queryEmbedder embeds search queries with the model the index was built
+// with
+type queryEmbedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+	Model() string
+}
+
+// newQueryEmbedder returns the embedder of the search command's queries;
+// tests replace it to search without Gemini
+var newQueryEmbedder = func(cfg *config.Config) (queryEmbedder, error) {
+	return newGeminiClient(cfg)
+}
+
+// newSearcher returns a searcher of the configured collection.
+func newSearcher(cfg *config.Config) (search.Searcher, error) {
+	store, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+	if err != nil {
+		return nil, err
+	}
+	embedder, err := newQueryEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Query vectors are only comparable with documents of their model
+	store.SetEmbeddingModel(embedder.Model())
+	return &typesenseSearcher{store: store, embedder: embedder}, nil
+}
+
+// typesenseSearcher runs hybrid searches of the index, filtered in
+// Typesense by the search options
+type typesenseSearcher struct {
+	store    *indexer.TypesenseClient
+	embedder queryEmbedder
+}
+
+func (s *typesenseSearcher) Search(ctx context.Context, query string, opts search.Options) ([]search.SearchResult, error) {
+	embedding, err := s.embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embedding query: %w", err)
+	}
+	hits, err := s.store.SearchHits(ctx, query, embedding, opts.Limit, indexer.SearchFilter{
+		ProjectPath: opts.ProjectPath,
+		Language:    opts.Language,
+		ChunkType:   opts.ChunkType,
+		Tags:        opts.Tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]search.SearchResult, len(hits))
+	for i, h := range hits {
+		results[i] = search.SearchResult{
+			FilePath:    h.RelPath,
+			AbsPath:     h.AbsPath,
+			ProjectPath: h.ProjectPath,
+			Language:    h.Language,
+			ChunkType:   h.ChunkType,
+			HeadingPath: h.HeadingPath,
+			Tags:        h.Tags,
+			Content:     h.Content,
+			StartLine:   h.StartLine,
+			EndLine:     h.EndLine,
+			Score:       h.Score,
+		}
+	}
+	return results, nil
+}
+
+func (s *typesenseSearcher) IsEmpty(ctx context.Context) (bool, error) {
+	stats, err := s.store.CollectionStats(ctx)
+	if err != nil {
+		return false, err
+	}
+	return stats.NumDocuments == 0, nil
+}
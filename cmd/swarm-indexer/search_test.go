@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+)
+
+// stubEmbedder embeds every query as the same vector
+type stubEmbedder struct{}
+
+func (stubEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return []float32{0.1, 0.2}, nil
+}
+
+func (stubEmbedder) Model() string { return "text-embedding-004" }
+
+// fakeSearch records the searches it receives and answers them with hits
+type fakeSearch struct {
+	mu       sync.Mutex
+	searches []map[string]any
+	hits     string
+}
+
+// serve points the search command at f, embedding queries with a stub
+func (f *fakeSearch) serve(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.URL.Path != "/multi_search" {
+			w.Write([]byte(`{"facet_counts": []}`))
+			return
+		}
+		var req struct {
+			Searches []map[string]any `json:"searches"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.searches = append(f.searches, req.Searches...)
+		hits := f.hits
+		if hits == "" {
+			hits = "[]"
+		}
+		w.Write([]byte(`{"results": [{"hits": ` + hits + `}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("TYPESENSE_URL", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("SWARM_INDEXER_BOOST", "")
+
+	orig := newQueryEmbedder
+	newQueryEmbedder = func(*config.Config) (queryEmbedder, error) { return stubEmbedder{}, nil }
+	t.Cleanup(func() { newQueryEmbedder = orig })
+}
+
+func TestSearchCommand_Tags(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	ts := &fakeSearch{}
+	ts.serve(t)
+
+	if _, err := runRoot(t, "search", "refund", "--tag", "backend", "--tag", "payments"); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	want := "deleted:!=true && tags:=[`backend`] && tags:=[`payments`]"
+	if len(ts.searches) != 1 || ts.searches[0]["filter_by"] != want {
+		t.Errorf("expected filter %q, got %v", want, ts.searches)
+	}
+}
+
+func TestSearchCommand_Results(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	ts := &fakeSearch{hits: `[{"document": {"rel_path": "pay.go", "abs_path": "/work/api/pay.go", "project_path": "/work/api", "chunk_type": "function", "content": "func Pay() {}", "start_line": 3, "end_line": 5}, "hybrid_search_info": {"rank_fusion_score": 0.5}}]`}
+	ts.serve(t)
+
+	out, err := runRoot(t, "search", "pay")
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if !strings.Contains(out, "[1] pay.go:3-5 (function) score: 0.50") {
+		t.Errorf("expected the Typesense hit, got:\n%s", out)
+	}
+}
+
+func TestSearchCommand_Matching(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	ts := &fakeSearch{}
+	ts.serve(t)
+
+	if _, err := runRoot(t, "search", "getUser", "--num-typos", "0", "--prefix=false", "--drop-tokens-threshold", "0"); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(ts.searches) != 1 {
+		t.Fatalf("expected one search, got %v", ts.searches)
+	}
+	s := ts.searches[0]
+	if s["num_typos"] != 0.0 || s["prefix"] != false || s["drop_tokens_threshold"] != 0.0 {
+		t.Errorf("expected strict matching parameters, got %v", s)
+	}
+}
+
+func TestSearchCommand_Boost(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	ts := &fakeSearch{hits: `[
+		{"document": {"rel_path": "dep.go", "abs_path": "/work/dep/dep.go", "project_path": "/work/dep"}, "hybrid_search_info": {"rank_fusion_score": 0.8}},
+		{"document": {"rel_path": "app.go", "abs_path": "/work/app/app.go", "project_path": "/work/app"}, "hybrid_search_info": {"rank_fusion_score": 0.5}}
+	]`}
+	ts.serve(t)
+
+	out, err := runRoot(t, "search", "handler", "--limit", "1", "--boost", "/work/app=2")
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if !strings.Contains(out, "[1] app.go") || strings.Contains(out, "dep.go") {
+		t.Errorf("expected the boosted project's result alone, got:\n%s", out)
+	}
+	// Candidates beyond the limit are fetched for boosts to promote
+	if ts.searches[0]["per_page"] != 3.0 {
+		t.Errorf("expected 3 candidates fetched, got %v", ts.searches[0]["per_page"])
+	}
+}
+
+func TestSearchCommand_SavedFilters(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	ts := &fakeSearch{}
+	ts.serve(t)
+
+	if _, err := runRoot(t, "search", "auth", "--language", "go", "--project", "/work/api", "--save", "auth"); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if _, err := runRoot(t, "search", "--saved", "auth"); err != nil {
+		t.Fatalf("saved search failed: %v", err)
+	}
+	want := "deleted:!=true && project_path:=`/work/api` && language:=`go`"
+	if len(ts.searches) != 2 || ts.searches[1]["filter_by"] != want || ts.searches[1]["q"] != "auth" {
+		t.Errorf("expected the saved query and filter %q, got %v", want, ts.searches)
+	}
+}
+
+func TestSearchCommand_RelativeProject(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	ts := &fakeSearch{}
+	ts.serve(t)
+	dir, err := filepath.Abs("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runRoot(t, "search", "auth", "--project", "api"); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if _, err := runRoot(t, "search", "auth", "--project", "https://github.com/org/repo.git"); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	want := []string{
+		"deleted:!=true && project_path:=`" + dir + "`",
+		"deleted:!=true && project_path:=`https://github.com/org/repo.git`",
+	}
+	if len(ts.searches) != 2 || ts.searches[0]["filter_by"] != want[0] || ts.searches[1]["filter_by"] != want[1] {
+		t.Errorf("expected filters %q, got %v", want, ts.searches)
+	}
+}
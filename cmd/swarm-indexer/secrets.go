@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+func newSecretsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Inspect and manage secret detection",
+		Long: `Inspect and manage secret detection. Secrets found while indexing are
+redacted before anything is embedded or stored.`,
+	}
+	cmd.AddCommand(newSecretsScanCmd())
+	cmd.AddCommand(newSecretsBaselineCmd())
+	return cmd
+}
+
+// secretFinding is a finding as reported by secrets scan
+type secretFinding struct {
+	Project string `json:"project"`
+	Path    string `json:"path"` // relative to Project
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Secret  string `json:"secret"` // masked
+	// SkipFile is set when a skip-file rule matched, so indexing leaves
+	// the whole file out
+	SkipFile bool `json:"skip_file,omitempty"`
+}
+
+func newSecretsScanCmd() *cobra.Command {
+	var jsonOutput, includeBaseline bool
+
+	cmd := &cobra.Command{
+		Use:   "scan [path]...",
+		Short: "Report secrets without indexing anything",
+		Long: `Scan each path with the same rules, entropy detection (if enabled) and
+skip patterns indexing uses and report every finding. Nothing is indexed or
+sent anywhere, and API keys aren't required.
+
+Secrets are masked in the output. Findings accepted in a project's
+` + secrets.BaselineFileName + ` are left out unless --include-baseline is
+given. Exits with code 7 when anything is found.
+
+Without arguments, every registered path is scanned.`,
+		ValidArgsFunction: completeRegisteredPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				var err error
+				args, err = registeredPaths()
+				if err != nil {
+					return err
+				}
+			}
+			if err := requireDirs(args); err != nil {
+				return err
+			}
+
+			cfg, err := config.LoadLocal()
+			if err != nil {
+				return configError(err)
+			}
+			scanner := secrets.NewFromConfig(cfg)
+
+			findings := []secretFinding{}
+			for _, path := range args {
+				root, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				found, err := scanProject(scanner, root, includeBaseline)
+				if err != nil {
+					return fmt.Errorf("%s: %w", root, err)
+				}
+				findings = append(findings, found...)
+			}
+
+			out := cmd.OutOrStdout()
+			if jsonOutput {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(findings); err != nil {
+					return err
+				}
+			} else {
+				writeSecretFindings(out, findings)
+			}
+
+			if len(findings) > 0 {
+				cmd.SilenceUsage = true
+				return withExitCode(exitSecrets, fmt.Errorf("found %d secrets", len(findings)))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output findings as JSON")
+	cmd.Flags().BoolVar(&includeBaseline, "include-baseline", false, "Also report findings accepted in the secrets baseline")
+	addConfigFlags(cmd, scanSettings)
+	return cmd
+}
+
+// scanProject scans root, leaving out findings its baseline accepts
+// unless includeBaseline is set
+func scanProject(scanner *secrets.Scanner, root string, includeBaseline bool) ([]secretFinding, error) {
+	baseline := &secrets.Baseline{}
+	if !includeBaseline {
+		var err error
+		if baseline, err = secrets.LoadBaseline(root); err != nil {
+			return nil, err
+		}
+	}
+	files, err := scanner.ScanDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []secretFinding
+	for _, ff := range files {
+		result := scanner.Filter(&secrets.ScanResult{Findings: ff.Findings, ShouldSkip: ff.Skip}, baseline, ff.Path)
+		for _, f := range result.Findings {
+			findings = append(findings, secretFinding{
+				Project:  root,
+				Path:     ff.Path,
+				Line:     f.Line,
+				Column:   f.Column,
+				Rule:     f.Type,
+				Secret:   config.Mask(f.Match),
+				SkipFile: result.ShouldSkip,
+			})
+		}
+	}
+	return findings, nil
+}
+
+func writeSecretFindings(out io.Writer, findings []secretFinding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(out, "No secrets found")
+		return
+	}
+	project := ""
+	files := 0
+	for i, f := range findings {
+		if f.Project != project {
+			project = f.Project
+			fmt.Fprintln(out, project)
+		}
+		if i == 0 || f.Project != findings[i-1].Project || f.Path != findings[i-1].Path {
+			files++
+		}
+		fmt.Fprintf(out, "  %s:%d:%d  %s  %s\n", f.Path, f.Line, f.Column, f.Rule, f.Secret)
+	}
+	fmt.Fprintf(out, "%d secrets in %d files\n", len(findings), files)
+}
+
+func newSecretsBaselineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "baseline [path]...",
+		Short: "Accept current secret findings as known false positives",
+		Long: `Scan each project and write every finding to its
+` + secrets.BaselineFileName + ` file. Findings in the baseline, such as fake
+keys in test fixtures, are no longer redacted or reported.
+
+Running baseline again updates the file: findings that have gone away are
+dropped and new ones are added. Review the listed new findings first, as
+anything in the baseline is indexed verbatim.
+
+Without arguments, every registered path is processed.`,
+		ValidArgsFunction: completeRegisteredPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				var err error
+				args, err = registeredPaths()
+				if err != nil {
+					return err
+				}
+			}
+			if err := requireDirs(args); err != nil {
+				return err
+			}
+
+			cfg, err := config.LoadLocal()
+			if err != nil {
+				return configError(err)
+			}
+			scanner := secrets.NewFromConfig(cfg)
+
+			out := cmd.OutOrStdout()
+			for _, path := range args {
+				root, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				if err := writeBaseline(out, scanner, root); err != nil {
+					return fmt.Errorf("%s: %w", root, err)
+				}
+			}
+			return nil
+		},
+	}
+	addConfigFlags(cmd, scanSettings)
+	return cmd
+}
+
+// writeBaseline regenerates the baseline of root, listing the findings it
+// didn't accept before
+func writeBaseline(out io.Writer, scanner *secrets.Scanner, root string) error {
+	old, err := secrets.LoadBaseline(root)
+	if err != nil {
+		return err
+	}
+	files, err := scanner.ScanDir(root)
+	if err != nil {
+		return err
+	}
+
+	baseline := secrets.NewBaseline(files)
+	for _, ff := range files {
+		for _, f := range ff.Findings {
+			if !old.Contains(secrets.NewFingerprint(ff.Path, f)) {
+				fmt.Fprintf(out, "  new: %s:%d:%d %s\n", ff.Path, f.Line, f.Column, f.Type)
+			}
+		}
+	}
+	removed := 0
+	for _, e := range old.Entries {
+		if !baseline.Contains(e) {
+			removed++
+		}
+	}
+
+	if err := baseline.Save(root); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %s: %d findings (%d removed)\n",
+		filepath.Join(root, secrets.BaselineFileName), len(baseline.Entries), removed)
+	return nil
+}
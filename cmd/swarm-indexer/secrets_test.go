@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+)
+
+func TestSecretsBaselineCommand(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	project := t.TempDir()
+	fixture := filepath.Join(project, "testdata", "keys.txt")
+	if err := os.MkdirAll(filepath.Dir(fixture), 0755); err != nil {
+		t.Fatal(err)
+	}
+	token := "ghp_" + strings.Repeat("eF5", 12)
+	if err := os.WriteFile(fixture, []byte("token "+token+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func() string {
+		cmd := newRootCmd()
+		buf := new(bytes.Buffer)
+		cmd.SetOut(buf)
+		cmd.SetArgs([]string{"secrets", "baseline", project})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("secrets baseline failed: %v", err)
+		}
+		return buf.String()
+	}
+
+	// API keys aren't needed to scan local files
+	out := run()
+	if !strings.Contains(out, "new: testdata/keys.txt:1:7 github-pat") || !strings.Contains(out, "1 findings (0 removed)") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	b, err := secrets.LoadBaseline(project)
+	if err != nil || len(b.Entries) != 1 {
+		t.Fatalf("expected 1 baseline entry, got %+v (err %v)", b, err)
+	}
+
+	// Updating drops findings that are gone
+	if err := os.WriteFile(fixture, []byte("no secrets here\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out := run(); !strings.Contains(out, "0 findings (1 removed)") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestSecretsScanCommand(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	project := t.TempDir()
+	token := "ghp_" + strings.Repeat("gH6", 12)
+	if err := os.WriteFile(filepath.Join(project, "deploy.sh"), []byte("#!/bin/sh\nexport TOKEN="+token+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func(args ...string) (string, error) {
+		cmd := newRootCmd()
+		buf := new(bytes.Buffer)
+		cmd.SetOut(buf)
+		cmd.SetErr(new(bytes.Buffer))
+		cmd.SetArgs(append([]string{"secrets", "scan"}, args...))
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	out, err := scan(project)
+	if exitCode(err) != exitSecrets {
+		t.Fatalf("expected exit code %d, got %d (%v)", exitSecrets, exitCode(err), err)
+	}
+	if !strings.Contains(out, "deploy.sh:2:14  github-pat  ****"+token[len(token)-4:]) || strings.Contains(out, token) {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	out, _ = scan("--json", project)
+	var findings []secretFinding
+	if err := json.Unmarshal([]byte(out), &findings); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if len(findings) != 1 || findings[0].Path != "deploy.sh" || findings[0].Rule != "github-pat" || findings[0].Line != 2 {
+		t.Errorf("unexpected findings %+v", findings)
+	}
+
+	// Baselined findings are only reported on request
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetArgs([]string{"secrets", "baseline", project})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := scan(project); err != nil || !strings.Contains(out, "No secrets found") {
+		t.Errorf("expected no findings after baselining, got %v:\n%s", err, out)
+	}
+	if _, err := scan("--include-baseline", project); exitCode(err) != exitSecrets {
+		t.Errorf("expected --include-baseline to report the finding, got %v", err)
+	}
+}
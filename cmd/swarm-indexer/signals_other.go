@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// reloadSignal and statusSignal don't exist here; workers reload their
+// configuration by being restarted
+var reloadSignal, statusSignal os.Signal
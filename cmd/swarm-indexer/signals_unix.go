@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadSignal makes a worker reload its configuration, and statusSignal
+// makes it log what it is doing
+var (
+	reloadSignal os.Signal = syscall.SIGHUP
+	statusSignal os.Signal = syscall.SIGUSR1
+)
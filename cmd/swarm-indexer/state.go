@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/spf13/cobra"
+)
+
+func newStateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Maintain the local index state database",
+		Long: `Maintain the state database (` + state.FileName + ` in the data dir) where
+swarm-indexer records what it indexed. The database is versioned and
+upgraded automatically when a newer swarm-indexer opens it.`,
+	}
+	cmd.AddCommand(newStateRepairCmd())
+	return cmd
+}
+
+func newStateRepairCmd() *cobra.Command {
+	var wait bool
+
+	cmd := &cobra.Command{
+		Use:   "repair",
+		Short: "Remove corrupt records from the state database",
+		Long: `Check the state database and remove the records that can't be read.
+Projects that lose their records are indexed from scratch on their next
+run; nothing in Typesense is touched.
+
+A database that can't be opened at all is moved aside (to
+` + state.FileName + `.corrupt-<time>) and replaced by an empty one.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lock, err := lockIndexRun(context.Background(), wait)
+			if err != nil {
+				return err
+			}
+			defer lock.Release()
+
+			dataDir, err := config.DataDir()
+			if err != nil {
+				return err
+			}
+			report, err := state.Repair(dataDir)
+			if err != nil {
+				return err
+			}
+			writeRepairReport(cmd.OutOrStdout(), report)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for a running index to finish instead of failing")
+	return cmd
+}
+
+func writeRepairReport(w io.Writer, r *state.RepairReport) {
+	fmt.Fprintf(w, "State database: %s (schema version %d)\n", r.Path, r.Version)
+	if r.Rebuilt {
+		fmt.Fprintf(w, "It could not be read (%s).\n", r.Reason)
+		fmt.Fprintf(w, "Moved it to %s and started an empty one; all projects will be indexed from scratch.\n", r.Backup)
+		return
+	}
+	if !r.Repaired() {
+		fmt.Fprintln(w, "No problems found.")
+		return
+	}
+	fmt.Fprintln(w, "Removed corrupt records:")
+	for _, c := range []struct {
+		n    int
+		what string
+	}{
+		{r.Projects, "projects (will be indexed from scratch)"},
+		{r.Files, "file records"},
+		{r.Runs, "runs"},
+	} {
+		if c.n > 0 {
+			fmt.Fprintf(w, "  %d %s\n", c.n, c.what)
+		}
+	}
+}
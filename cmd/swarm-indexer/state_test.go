@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/state"
+)
+
+func TestStateRepairCommand(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
+	if err := os.WriteFile(filepath.Join(dataDir, state.FileName), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func() string {
+		t.Helper()
+		cmd := newRootCmd()
+		buf := new(bytes.Buffer)
+		cmd.SetOut(buf)
+		cmd.SetErr(buf)
+		cmd.SetArgs([]string{"state", "repair"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("state repair failed: %v\n%s", err, buf)
+		}
+		return buf.String()
+	}
+
+	if out := run(); !strings.Contains(out, "could not be read") || !strings.Contains(out, ".corrupt-") {
+		t.Errorf("expected the database to be replaced, got:\n%s", out)
+	}
+	if out := run(); !strings.Contains(out, "No problems found") {
+		t.Errorf("expected a clean database, got:\n%s", out)
+	}
+}
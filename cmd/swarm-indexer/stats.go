@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/dvaida/swarm-indexer/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+// statsReport is the output of the stats command
+type statsReport struct {
+	Usage           usage.Stats      `json:"usage"`
+	PricePerMillion float64          `json:"price_per_million_tokens"`
+	EstimatedCost   float64          `json:"estimated_cost_usd"`
+	AvgChunkBytes   float64          `json:"avg_chunk_bytes"`
+	Projects        map[string]int64 `json:"projects,omitempty"`
+	ProjectsError   string           `json:"projects_error,omitempty"`
+	Runs            []state.Run      `json:"runs"` // newest first
+	RunsError       string           `json:"runs_error,omitempty"`
+}
+
+func newStatsCmd() *cobra.Command {
+	var jsonOutput, reset bool
+	var price float64
+	var runs int
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show cumulative embedding usage and estimated cost",
+		Long: `Show embedding usage accumulated across index runs (calls, chunks,
+estimated tokens and cost), the most recent index and reindex runs and
+the number of documents per project in Typesense. Comparing runs shows
+regressions such as a jump in tokens or failed files.
+
+Tokens are estimated at four characters per token. The price defaults to
+SWARM_INDEXER_PRICE_PER_MTOK, or the Gemini list price if unset.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dataDir, err := config.DataDir()
+			if err != nil {
+				return fmt.Errorf("resolving data dir: %w", err)
+			}
+
+			out := cmd.OutOrStdout()
+			if reset {
+				if err := (&usage.Stats{}).Save(dataDir); err != nil {
+					return err
+				}
+				fmt.Fprintln(out, "Usage counters reset")
+				return nil
+			}
+
+			if !cmd.Flags().Changed("price") {
+				if v := os.Getenv("SWARM_INDEXER_PRICE_PER_MTOK"); v != "" {
+					price, err = strconv.ParseFloat(v, 64)
+					if err != nil {
+						return fmt.Errorf("invalid SWARM_INDEXER_PRICE_PER_MTOK %q: %w", v, err)
+					}
+				}
+			}
+
+			u, err := usage.Load(dataDir)
+			if err != nil {
+				return err
+			}
+
+			report := statsReport{
+				Usage:           *u,
+				PricePerMillion: price,
+				EstimatedCost:   u.Cost(price),
+				AvgChunkBytes:   u.AvgChunkBytes(),
+			}
+
+			report.Runs, err = loadRuns(dataDir, runs)
+			if err != nil {
+				report.RunsError = err.Error()
+			}
+
+			cfg, err := config.Load()
+			if err == nil {
+				var client *indexer.TypesenseClient
+				client, err = newTypesenseClient(cfg, cfg.TypesenseCollection)
+				if err == nil {
+					report.Projects, err = client.ProjectPaths(context.Background())
+				}
+			}
+			if err != nil {
+				report.ProjectsError = err.Error()
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+			writeStats(out, report)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output stats as JSON")
+	cmd.Flags().BoolVar(&reset, "reset", false, "Reset the usage counters")
+	cmd.Flags().IntVar(&runs, "runs", 10, "Number of recent runs to show (0 for all)")
+	cmd.Flags().Float64Var(&price, "price", usage.DefaultPricePerMillion, "Embedding price in USD per million tokens")
+	addConfigFlags(cmd, typesenseSettings)
+
+	return cmd
+}
+
+func writeStats(w io.Writer, r statsReport) {
+	u := r.Usage
+	if u.Since != 0 {
+		fmt.Fprintf(w, "Embedding usage since %s\n", time.Unix(u.Since, 0).Format("2006-01-02"))
+	} else {
+		fmt.Fprintln(w, "Embedding usage")
+	}
+	fmt.Fprintf(w, "  Index runs:        %d\n", u.Runs)
+	fmt.Fprintf(w, "  Embedding calls:   %d\n", u.EmbedCalls)
+	fmt.Fprintf(w, "  Chunks embedded:   %d\n", u.Chunks)
+	fmt.Fprintf(w, "  Avg chunk size:    %.0f bytes\n", r.AvgChunkBytes)
+	fmt.Fprintf(w, "  Estimated tokens:  %d\n", u.Tokens)
+	fmt.Fprintf(w, "  Estimated cost:    $%.2f (at $%.2f per 1M tokens)\n", r.EstimatedCost, r.PricePerMillion)
+
+	fmt.Fprintln(w)
+	writeRuns(w, r.Runs, r.RunsError)
+
+	fmt.Fprintln(w)
+	if r.ProjectsError != "" {
+		fmt.Fprintf(w, "Documents per project: unavailable (%s)\n", r.ProjectsError)
+		return
+	}
+	if len(r.Projects) == 0 {
+		fmt.Fprintln(w, "Documents per project: none indexed")
+		return
+	}
+	fmt.Fprintln(w, "Documents per project:")
+	paths := make([]string, 0, len(r.Projects))
+	for p := range r.Projects {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fmt.Fprintf(w, "  %8d  %s\n", r.Projects[p], p)
+	}
+}
+
+// loadRuns returns up to limit runs from the state database in dataDir,
+// newest first
+func loadRuns(dataDir string, limit int) ([]state.Run, error) {
+	db, err := state.Open(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	runs, err := db.Runs(limit)
+	if runs == nil {
+		runs = []state.Run{}
+	}
+	return runs, err
+}
+
+func writeRuns(w io.Writer, runs []state.Run, runsErr string) {
+	if runsErr != "" {
+		fmt.Fprintf(w, "Recent runs: unavailable (%s)\n", runsErr)
+		return
+	}
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "Recent runs: none recorded")
+		return
+	}
+	fmt.Fprintln(w, "Recent runs:")
+	fmt.Fprintf(w, "  %-16s  %8s  %7s  %7s  %10s  %6s  %s\n", "Started", "Duration", "Files", "Chunks", "Tokens", "Failed", "Paths")
+	for _, run := range runs {
+		paths := strings.Join(run.Paths, ", ")
+		if paths == "" {
+			paths = "(file list)"
+		}
+		if run.Aborted {
+			paths += " [interrupted]"
+		}
+		fmt.Fprintf(w, "  %-16s  %8s  %7d  %7d  %10d  %6d  %s\n",
+			time.Unix(run.Start, 0).Format("2006-01-02 15:04"),
+			(time.Duration(run.End-run.Start) * time.Second).String(),
+			run.Files, run.Chunks, run.Tokens, run.Failed, paths)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/dvaida/swarm-indexer/internal/usage"
+)
+
+func TestStatsCommand(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"facet_counts": [{"field_name": "project_path", "counts": [{"count": 12, "value": "/repo"}]}]}`))
+	}))
+	defer server.Close()
+
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
+	t.Setenv("SWARM_INDEXER_PRICE_PER_MTOK", "")
+	t.Setenv("TYPESENSE_URL", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+
+	if err := usage.Record(dataDir, usage.Stats{Runs: 1, EmbedCalls: 3, Chunks: 10, ChunkBytes: 5000, Tokens: 2_000_000}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := state.Open(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddRun(&state.Run{Start: 1700000000, End: 1700000090, Paths: []string{"/repo"}, Files: 7, Chunks: 10, Tokens: 2_000_000, Failed: 1})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"stats"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"Embedding calls:   3", "Avg chunk size:    500 bytes", "$0.30", "/repo", "Recent runs:", "1m30s", "2000000       1  /repo"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestStatsCommand_JSONWithPrice(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
+	t.Setenv("TYPESENSE_API_KEY", "")
+
+	if err := usage.Record(dataDir, usage.Stats{Tokens: 1_000_000}); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"stats", "--json", "--price", "1.5"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+
+	var report statsReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if report.EstimatedCost != 1.5 {
+		t.Errorf("expected cost 1.5, got %f", report.EstimatedCost)
+	}
+	if report.ProjectsError == "" {
+		t.Error("expected project counts to be unavailable without config")
+	}
+	if report.Runs == nil || len(report.Runs) != 0 || report.RunsError != "" {
+		t.Errorf("expected an empty run history, got %v (%s)", report.Runs, report.RunsError)
+	}
+}
+
+func TestStatsCommand_Reset(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
+
+	if err := usage.Record(dataDir, usage.Stats{Runs: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := newRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetArgs([]string{"stats", "--reset"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("stats --reset failed: %v", err)
+	}
+
+	u, err := usage.Load(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Runs != 0 {
+		t.Errorf("expected counters to be reset, got %+v", u)
+	}
+}
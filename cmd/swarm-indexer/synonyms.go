@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/spf13/cobra"
+)
+
+func newSynonymsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "synonyms",
+		Short: "Manage the synonyms keyword search expands queries with",
+		Long: `Manage the collection's synonym sets, so keyword search matches the
+team's vocabulary: a search for "k8s" also finds "kubernetes", and "auth"
+finds "authentication". Synonyms apply to the keyword half of hybrid
+searches from the next query on; nothing needs re-indexing.`,
+	}
+	cmd.AddCommand(newSynonymsAddCmd())
+	cmd.AddCommand(newSynonymsListCmd())
+	cmd.AddCommand(newSynonymsRmCmd())
+	return cmd
+}
+
+func newSynonymsAddCmd() *cobra.Command {
+	var id string
+	var oneWay bool
+
+	cmd := &cobra.Command{
+		Use:   "add WORD WORD...",
+		Short: "Add a set of words searched as one",
+		Long: `Add a synonym set: a search for any of the words matches all of them.
+With --one-way, only a search for the first word matches the others.
+
+The set's ID defaults to its words joined by dashes ("auth-authentication");
+adding a set with an existing ID replaces it.`,
+		Example: `  swarm-indexer synonyms add auth authentication
+  swarm-indexer synonyms add k8s kubernetes
+  swarm-indexer synonyms add --one-way db postgres mysql sqlite`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			words := make([]string, len(args))
+			for i, arg := range args {
+				words[i] = strings.TrimSpace(arg)
+				if words[i] == "" {
+					return withExitCode(exitUsage, errors.New("synonyms cannot be empty"))
+				}
+			}
+			s := indexer.Synonym{ID: id, Synonyms: words}
+			if oneWay {
+				s.Root, s.Synonyms = words[0], words[1:]
+			}
+			if s.ID == "" {
+				s.ID = synonymID(words)
+			}
+
+			ctx, stop := interruptContext()
+			defer stop()
+
+			client, err := newCollectionClient()
+			if err != nil {
+				return err
+			}
+			if err := client.UpsertSynonym(ctx, s); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Added synonym set %s: %s\n", s.ID, describeSynonym(s))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&id, "id", "", "ID of the set (default: the words joined by dashes)")
+	cmd.Flags().BoolVar(&oneWay, "one-way", false, "Only a search for the first word matches the others")
+	addConfigFlags(cmd, typesenseSettings)
+	return cmd
+}
+
+func newSynonymsListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the synonym sets",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := interruptContext()
+			defer stop()
+
+			client, err := newCollectionClient()
+			if err != nil {
+				return err
+			}
+			synonyms, err := client.ListSynonyms(ctx)
+			if err != nil {
+				return err
+			}
+			writeSynonyms(cmd.OutOrStdout(), synonyms)
+			return nil
+		},
+	}
+	addConfigFlags(cmd, typesenseSettings)
+	return cmd
+}
+
+func newSynonymsRmCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rm ID...",
+		Short: "Remove synonym sets",
+		Long:  `Remove the synonym sets with the given IDs, as shown by "synonyms list".`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := interruptContext()
+			defer stop()
+
+			client, err := newCollectionClient()
+			if err != nil {
+				return err
+			}
+			for _, id := range args {
+				if err := client.DeleteSynonym(ctx, id); err != nil {
+					var apiErr *indexer.APIError
+					if errors.As(err, &apiErr) && apiErr.IsNotFound() {
+						return fmt.Errorf("no synonym set %q", id)
+					}
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Removed synonym set %s\n", id)
+			}
+			return nil
+		},
+	}
+	addConfigFlags(cmd, typesenseSettings)
+	return cmd
+}
+
+// newCollectionClient returns a client of the configured collection
+func newCollectionClient() (*indexer.TypesenseClient, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, configError(err)
+	}
+	return newTypesenseClient(cfg, cfg.TypesenseCollection)
+}
+
+// synonymID derives a set's ID from its words
+func synonymID(words []string) string {
+	parts := make([]string, len(words))
+	for i, w := range words {
+		parts[i] = strings.Join(strings.Fields(strings.ToLower(w)), "_")
+	}
+	return strings.Join(parts, "-")
+}
+
+// describeSynonym shows a set as "a = b = c", or "a -> b, c" if one-way
+func describeSynonym(s indexer.Synonym) string {
+	if s.Root != "" {
+		return s.Root + " -> " + strings.Join(s.Synonyms, ", ")
+	}
+	return strings.Join(s.Synonyms, " = ")
+}
+
+func writeSynonyms(w io.Writer, synonyms []indexer.Synonym) {
+	if len(synonyms) == 0 {
+		fmt.Fprintln(w, "No synonyms defined")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSYNONYMS")
+	for _, s := range synonyms {
+		fmt.Fprintf(tw, "%s\t%s\n", s.ID, describeSynonym(s))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+)
+
+// fakeSynonyms serves the synonyms API of a Typesense collection
+func fakeSynonyms(t *testing.T) map[string]indexer.Synonym {
+	t.Helper()
+	sets := map[string]indexer.Synonym{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/collections/swarm-index/synonyms/")
+		switch r.Method {
+		case "PUT":
+			var s indexer.Synonym
+			_ = json.NewDecoder(r.Body).Decode(&s)
+			s.ID = id
+			sets[id] = s
+			_ = json.NewEncoder(w).Encode(s)
+		case "GET":
+			var list []indexer.Synonym
+			for _, s := range sets {
+				list = append(list, s)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"synonyms": list})
+		case "DELETE":
+			if _, ok := sets[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(sets, id)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("TYPESENSE_URL", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("TYPESENSE_COLLECTION", "swarm-index")
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	return sets
+}
+
+func runSynonyms(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newRootCmd()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs(append([]string{"synonyms"}, args...))
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestSynonymsCommands(t *testing.T) {
+	sets := fakeSynonyms(t)
+
+	if _, err := runSynonyms(t, "add", "K8s", "kubernetes"); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if _, err := runSynonyms(t, "add", "--one-way", "--id", "databases", "db", "postgres", "mysql"); err != nil {
+		t.Fatalf("add --one-way failed: %v", err)
+	}
+	if s := sets["k8s-kubernetes"]; s.Root != "" || len(s.Synonyms) != 2 {
+		t.Errorf("expected a multi-way set k8s-kubernetes, got %+v", sets)
+	}
+	if s := sets["databases"]; s.Root != "db" || strings.Join(s.Synonyms, ",") != "postgres,mysql" {
+		t.Errorf("expected a one-way set rooted at db, got %+v", s)
+	}
+
+	out, err := runSynonyms(t, "list")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	for _, want := range []string{"K8s = kubernetes", "db -> postgres, mysql"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	if _, err := runSynonyms(t, "rm", "databases"); err != nil {
+		t.Fatalf("rm failed: %v", err)
+	}
+	if _, ok := sets["databases"]; ok {
+		t.Error("expected the set removed")
+	}
+	if _, err := runSynonyms(t, "rm", "databases"); err == nil || !strings.Contains(err.Error(), `no synonym set "databases"`) {
+		t.Errorf("expected removing a missing set to fail, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+	"github.com/spf13/cobra"
+)
+
+func newUndeleteCmd() *cobra.Command {
+	var files []string
+
+	cmd := &cobra.Command{
+		Use:   "undelete [project]...",
+		Short: "Restore the tombstoned documents of deleted files",
+		Long: `Restore the documents of files an index run found deleted, while
+delete_grace (SWARM_INDEXER_DELETE_GRACE) keeps them as tombstones, e.g.
+after a bad walk missed files that are still there. Restored documents
+show up in searches again at once; files still on disk are indexed again
+by the next index run.
+
+With --file, only the tombstones of the given files, relative to the
+project, are restored. Without arguments, undelete lists the projects
+with tombstones.`,
+		Example: `  swarm-indexer undelete
+  swarm-indexer undelete ~/src/api
+  swarm-indexer undelete ~/src/api --file internal/auth/token.go`,
+		ValidArgsFunction: completeRegisteredPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(files) > 0 && len(args) != 1 {
+				return withExitCode(exitUsage, fmt.Errorf("--file needs exactly one project"))
+			}
+
+			ctx := context.Background()
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			client, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if len(args) == 0 {
+				return listTombstones(ctx, client, out)
+			}
+			for _, path := range args {
+				project, err := walker.Abs(path)
+				if err != nil {
+					return err
+				}
+				rels := make([]string, len(files))
+				for i, f := range files {
+					rels[i] = filepath.ToSlash(filepath.Clean(f))
+				}
+				n, err := client.Undelete(ctx, project, rels)
+				if err != nil {
+					return fmt.Errorf("restoring %s: %w", project, err)
+				}
+				fmt.Fprintf(out, "Restored %d documents of %s\n", n, project)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&files, "file", nil, "Only restore this file, relative to the project (repeatable)")
+	addConfigFlags(cmd, typesenseSettings)
+	return cmd
+}
+
+// listTombstones prints the number of tombstones in each project
+func listTombstones(ctx context.Context, client *indexer.TypesenseClient, out io.Writer) error {
+	counts, err := client.Tombstones(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("listing tombstones: %w", err)
+	}
+	if len(counts) == 0 {
+		fmt.Fprintln(out, "No tombstones")
+		return nil
+	}
+	projects := make([]string, 0, len(counts))
+	for p := range counts {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+	for _, p := range projects {
+		fmt.Fprintf(out, "%s (%d documents)\n", p, counts[p])
+	}
+	return nil
+}
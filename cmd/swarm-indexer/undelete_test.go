@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUndeleteCommand(t *testing.T) {
+	var updates []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PATCH" {
+			updates = append(updates, r.URL.Query().Get("filter_by"))
+			w.Write([]byte(`{"num_updated": 2}`))
+			return
+		}
+		w.Write([]byte(`{"facet_counts": [{"field_name": "project_path", "counts": [{"count": 2, "value": "/repo"}]}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("TYPESENSE_URL", server.URL)
+	t.Setenv("TYPESENSE_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+
+	out, err := runRoot(t, "undelete")
+	if err != nil {
+		t.Fatalf("undelete failed: %v", err)
+	}
+	if strings.TrimSpace(out) != "/repo (2 documents)" {
+		t.Errorf("expected the projects with tombstones listed, got %q", out)
+	}
+
+	out, err = runRoot(t, "undelete", "/repo", "--file", "./internal/auth.go")
+	if err != nil {
+		t.Fatalf("undelete failed: %v", err)
+	}
+	want := "project_path:=`/repo` && deleted:=true && rel_path:=[`internal/auth.go`]"
+	if len(updates) != 1 || updates[0] != want {
+		t.Errorf("expected filter %q, got %v", want, updates)
+	}
+	if !strings.Contains(out, "Restored 2 documents of /repo") {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	if _, err := runRoot(t, "undelete", "--file", "a.go"); exitCode(err) != exitUsage {
+		t.Errorf("expected --file without a project to be a usage error, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/prune"
+	"github.com/dvaida/swarm-indexer/internal/queue"
+	"github.com/spf13/cobra"
+)
+
+func newWorkerCmd() *cobra.Command {
+	var expireEvery time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "worker",
+		Short: "Process files handed out by index --distribute runs",
+		Long: `Take files from the queue at queue_url (SWARM_INDEXER_QUEUE_URL), index
+them into Typesense and report back to the index --distribute run that
+queued them, until interrupted. Start workers on as many machines as
+needed; each processes up to --workers files at a time.
+
+Files are read at the path they were queued with, so every worker must
+see the projects at the same paths as the machine running index, e.g. on
+shared storage. Workers use their own Typesense and Gemini settings, which
+should point at the same collection and embedding model as the run's.
+
+Ctrl-C or SIGTERM stops taking files; the files already taken are
+finished and reported first.
+
+SIGHUP reloads the configuration once the files already taken are
+finished, picking up changed settings such as secret patterns, hooks and
+workers without dropping any file; a configuration that fails to load is
+logged and the current one kept. SIGUSR1 logs what the worker is doing:
+the files it is chunking and for how long, and those waiting to be
+embedded and upserted.
+
+Every --expire-every, the worker also deletes the expired documents of
+projects registered with a TTL (register --ttl) and, with delete_grace
+set, the tombstones older than it, as prune does.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := interruptContext()
+			defer stop()
+			reload := notifySignal(reloadSignal)
+			status := notifySignal(statusSignal)
+
+			cfg, err := config.Load()
+			if err != nil {
+				return configError(err)
+			}
+			if expireEvery < 0 {
+				return withExitCode(exitUsage, fmt.Errorf("--expire-every cannot be negative, got %s", expireEvery))
+			}
+			var current atomic.Pointer[config.Config]
+			current.Store(cfg)
+			if expireEvery > 0 {
+				go expireDocuments(ctx, &current, expireEvery)
+			}
+			for {
+				if err := work(ctx, cmd, cfg, reload, status); err != nil {
+					return err
+				}
+				if ctx.Err() != nil {
+					return nil
+				}
+				reloaded, err := config.Load()
+				if err != nil {
+					slog.Error("failed to reload configuration; keeping the current one", "err", err)
+					continue
+				}
+				cfg = reloaded
+				current.Store(cfg)
+				slog.Info("reloaded configuration")
+			}
+		},
+	}
+	cmd.Flags().DurationVar(&expireEvery, "expire-every", time.Hour, "How often to delete expired documents of projects with a TTL (0 never)")
+	addConfigFlags(cmd, typesenseSettings, geminiSettings, pipelineSettings, scanSettings, indexSettings)
+	return cmd
+}
+
+// expireDocuments runs an expiry pass every interval, and once at the
+// start, until ctx is done. Each pass reads the registry afresh.
+func expireDocuments(ctx context.Context, cfg *atomic.Pointer[config.Config], every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		expirePass(ctx, cfg.Load())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expirePass deletes the documents of the registered projects last
+// indexed longer than their TTL ago, and the tombstones older than the
+// delete grace period, logging the outcome. Workers run it periodically
+// and index runs once they're done.
+func expirePass(ctx context.Context, cfg *config.Config) {
+	_, reg, err := loadRegistry()
+	if err != nil {
+		slog.Error("expiry pass failed", "err", err)
+		return
+	}
+	ttls := reg.TTLs()
+	if len(ttls) == 0 && cfg.DeleteGrace == 0 {
+		return
+	}
+	client, err := newTypesenseClient(cfg, cfg.TypesenseCollection)
+	if err != nil {
+		slog.Error("expiry pass failed", "err", err)
+		return
+	}
+	now := time.Now()
+	if len(ttls) > 0 {
+		n, err := prune.Expire(ctx, client, ttls, now)
+		if err != nil {
+			slog.Error("expiry pass failed", "documents", n, "err", err)
+			return
+		}
+		if n > 0 {
+			slog.Info("expired documents", "documents", n, "projects", len(ttls))
+		}
+	}
+	if cfg.DeleteGrace > 0 {
+		targets, err := prune.FindTombstones(ctx, client, cfg.DeleteGrace, now)
+		if err == nil {
+			var n int
+			n, err = prune.Apply(ctx, client, targets)
+			if n > 0 {
+				slog.Info("purged tombstones", "documents", n, "grace", cfg.DeleteGrace)
+			}
+		}
+		if err != nil {
+			slog.Error("purging tombstones failed", "err", err)
+		}
+	}
+}
+
+// work processes files with cfg until ctx is done or reload receives a
+// signal, logging the worker's status whenever status receives one
+func work(ctx context.Context, cmd *cobra.Command, cfg *config.Config, reload, status <-chan os.Signal) error {
+	q, err := openQueue(cfg)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	idx, err := newIndexer(ctx, cmd, cfg)
+	if err != nil {
+		return err
+	}
+
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		for {
+			select {
+			case <-reload:
+				slog.Info("reloading configuration once the files taken are finished")
+				cancel()
+				return
+			case <-status:
+				logWorkStatus(idx)
+			case <-workCtx.Done():
+				return
+			}
+		}
+	}()
+
+	slog.Info("waiting for files", "workers", cfg.Workers)
+	idx.Work(workCtx, q)
+	return nil
+}
+
+// logWorkStatus logs what the worker is doing
+func logWorkStatus(idx *indexer.Indexer) {
+	st, ok := idx.WorkStatus()
+	if !ok {
+		slog.Info("worker status: not working")
+		return
+	}
+	slog.Info("worker status", "active_files", len(st.Active), "pending_files", st.PendingFiles,
+		"pending_chunks", st.PendingChunks, "flushing_files", st.FlushingFiles,
+		"files_reported", st.Reported, "roots", st.Roots)
+	for _, f := range st.Active {
+		slog.Info("processing file", "file", f.Path, "for", time.Since(f.Since).Round(time.Millisecond))
+	}
+}
+
+// notifySignal returns a channel receiving sig, or nil, which never
+// receives, if sig is nil
+func notifySignal(sig os.Signal) <-chan os.Signal {
+	if sig == nil {
+		return nil
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig)
+	return ch
+}
+
+// openQueue connects to the configured work queue.
+func openQueue(cfg *config.Config) (queue.Queue, error) {
+	if cfg.QueueURL == "" {
+		return nil, configError(errors.New("queue_url (SWARM_INDEXER_QUEUE_URL) is not set"))
+	}
+	q, err := queue.Open(cfg.QueueURL)
+	if err != nil {
+		return nil, configError(fmt.Errorf("SWARM_INDEXER_QUEUE_URL: %w", err))
+	}
+	return q, nil
+}
@@ -0,0 +1,211 @@
+// Package archive unpacks .zip and .tar.gz archives under the data dir, so
+// their contents can be indexed like a directory.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+)
+
+// archivesDir is the directory under the data dir archives are unpacked in
+const archivesDir = "archives"
+
+// Limits on what an archive may unpack to, so a zip bomb can't fill the
+// disk
+const (
+	MaxSize  = 1 << 30 // bytes of all files together
+	MaxFiles = 50000
+)
+
+// ErrTooLarge is returned when an archive exceeds MaxSize or MaxFiles.
+var ErrTooLarge = errors.New("archive exceeds the unpack limits")
+
+// extensions are the archive formats Extract can unpack
+var extensions = []string{".zip", ".tar.gz", ".tgz", ".tar"}
+
+// IsArchive reports whether path names an archive, by its extension.
+func IsArchive(path string) bool {
+	lower := strings.ToLower(path)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prefix returns the prefix of the file paths of an archive's documents:
+// its name followed by "!/", as in release.zip!/docs/readme.md.
+func Prefix(path string) string {
+	return filepath.Base(path) + "!/"
+}
+
+// Dir returns where the archive at path, an absolute path, is unpacked:
+// $DATA_DIR/archives/<sha256 of path>.
+func Dir(path string) (string, error) {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving data dir: %w", err)
+	}
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(dataDir, archivesDir, hex.EncodeToString(sum[:8])), nil
+}
+
+// Extract unpacks the archive at path into dir, which must not exist yet.
+// Only regular files and directories are unpacked; entries that would land
+// outside dir are an error. It fails with ErrTooLarge once the files
+// exceed MaxSize or MaxFiles.
+func Extract(path, dir string) error {
+	return extract(path, &unpacker{dir: dir, maxSize: MaxSize, maxFiles: MaxFiles})
+}
+
+func extract(path string, u *unpacker) error {
+	if err := os.MkdirAll(u.dir, 0755); err != nil {
+		return err
+	}
+	lower := strings.ToLower(path)
+	if strings.HasSuffix(lower, ".zip") {
+		return u.zip(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(lower, ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return u.tar(r)
+}
+
+// unpacker writes an archive's entries into dir, keeping count against
+// the limits
+type unpacker struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	size     int64
+	files    int
+}
+
+func (u *unpacker) zip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		mode := f.Mode()
+		if mode.IsDir() {
+			if err := u.mkdir(f.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		err = u.file(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unpacker) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := u.mkdir(hdr.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := u.file(hdr.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// target returns where the entry called name goes in dir
+func (u *unpacker) target(name string) (string, error) {
+	rel := filepath.FromSlash(strings.TrimPrefix(name, "./"))
+	if rel == "" || filepath.IsAbs(rel) || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s: entry outside the archive", name)
+	}
+	return filepath.Join(u.dir, rel), nil
+}
+
+func (u *unpacker) mkdir(name string) error {
+	path, err := u.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0755)
+}
+
+// file writes the entry called name from r
+func (u *unpacker) file(name string, r io.Reader) error {
+	path, err := u.target(name)
+	if err != nil {
+		return err
+	}
+	u.files++
+	if u.files > u.maxFiles {
+		return fmt.Errorf("%w: more than %d files", ErrTooLarge, u.maxFiles)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	// Read one byte past what's left so an overrun is noticed
+	n, err := io.Copy(f, io.LimitReader(r, u.maxSize-u.size+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	u.size += n
+	if u.size > u.maxSize {
+		return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, u.maxSize)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeZip creates a zip archive holding files, by name
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTarGz creates a gzipped tar archive holding files, by name
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.WriteHeader(&tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink})
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	files := map[string]string{"docs/readme.md": "# Release\n", "main.go": "package main\n"}
+	for _, name := range []string{"release.zip", "release.tar.gz"} {
+		t.Run(name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), name)
+			if name == "release.zip" {
+				writeZip(t, src, files)
+			} else {
+				writeTarGz(t, src, files)
+			}
+
+			dir := filepath.Join(t.TempDir(), "out")
+			if err := Extract(src, dir); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			for rel, want := range files {
+				got, err := os.ReadFile(filepath.Join(dir, rel))
+				if err != nil || string(got) != want {
+					t.Errorf("expected %s to hold %q, got %q, %v", rel, want, got, err)
+				}
+			}
+			if _, err := os.Lstat(filepath.Join(dir, "link")); err == nil {
+				t.Errorf("expected symlinks to be skipped")
+			}
+		})
+	}
+}
+
+func TestExtract_OutsideEntry(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "evil.zip")
+	writeZip(t, src, map[string]string{"../../escaped.txt": "gotcha"})
+
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := Extract(src, dir); err == nil {
+		t.Fatal("expected an error for an entry outside the archive")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "..", "escaped.txt")); err == nil {
+		t.Errorf("expected nothing written outside %s", dir)
+	}
+}
+
+func TestExtract_Limits(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "big.zip")
+	writeZip(t, src, map[string]string{"a.txt": "0123456789", "b.txt": "0123456789"})
+
+	u := &unpacker{dir: filepath.Join(t.TempDir(), "size"), maxSize: 15, maxFiles: 10}
+	if err := extract(src, u); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for the size limit, got %v", err)
+	}
+	u = &unpacker{dir: filepath.Join(t.TempDir(), "files"), maxSize: 100, maxFiles: 1}
+	if err := extract(src, u); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for the file limit, got %v", err)
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	tests := map[string]bool{
+		"release.zip":    true,
+		"docs.TAR.GZ":    true,
+		"src.tgz":        true,
+		"vendor.tar":     true,
+		"notes.md":       false,
+		"/path/to/dir":   false,
+		"archive.zip.md": false,
+	}
+	for path, want := range tests {
+		if got := IsArchive(path); got != want {
+			t.Errorf("IsArchive(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if got := Prefix("/tmp/release.zip"); got != "release.zip!/" {
+		t.Errorf("unexpected prefix %q", got)
+	}
+}
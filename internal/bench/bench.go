@@ -0,0 +1,282 @@
+// Package bench measures the throughput of each indexing stage separately
+// so worker counts and batch sizes can be tuned empirically.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/chunker"
+	"github.com/dvaida/swarm-indexer/internal/detector"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+)
+
+// Options configures a benchmark run. Each worker count is tried for the
+// chunk stage and each batch size for the embed and upsert stages.
+type Options struct {
+	Workers    []int
+	BatchSizes []int
+	MaxChunks  int // caps chunks sent to embed/upsert; 0 means all
+	ChunkSize  int // most characters per chunk; 0 means chunker.DefaultMaxChunkSize
+	Embedder   indexer.Embedder
+	Store      indexer.Store
+}
+
+// Result is the measurement of one stage configuration.
+type Result struct {
+	Stage     string        `json:"stage"`
+	Workers   int           `json:"workers,omitempty"`
+	BatchSize int           `json:"batch_size,omitempty"`
+	Items     int           `json:"items"`
+	Unit      string        `json:"unit"`
+	Duration  time.Duration `json:"duration_ns"`
+	Errors    int           `json:"errors,omitempty"`
+}
+
+// Rate returns items processed per second.
+func (r Result) Rate() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Items) / r.Duration.Seconds()
+}
+
+// Run benchmarks walk, chunk, embed and upsert on the files under root.
+func Run(ctx context.Context, root string, opts Options) ([]Result, error) {
+	var results []Result
+
+	start := time.Now()
+	ch, err := walker.Walk(root)
+	if err != nil {
+		return nil, fmt.Errorf("walking: %w", err)
+	}
+	var files []string
+	for fi := range ch {
+		if filepath.Base(fi.Path) != metadata.MetadataFileName {
+			files = append(files, fi.Path)
+		}
+	}
+	results = append(results, Result{Stage: "walk", Items: len(files), Unit: "files", Duration: time.Since(start)})
+
+	var chunks []indexer.IndexedChunk
+	for _, workers := range opts.Workers {
+		r, c := chunkFiles(root, files, workers, chunker.New(opts.ChunkSize))
+		results = append(results, r)
+		chunks = c
+	}
+	if opts.MaxChunks > 0 && len(chunks) > opts.MaxChunks {
+		chunks = chunks[:opts.MaxChunks]
+	}
+	if len(chunks) == 0 {
+		return results, nil
+	}
+
+	for _, size := range opts.BatchSizes {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		r, err := embedChunks(ctx, opts.Embedder, chunks, size)
+		results = append(results, r)
+		if err != nil {
+			return results, err
+		}
+	}
+
+	for _, size := range opts.BatchSizes {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		r, err := upsertChunks(ctx, opts.Store, chunks, size)
+		results = append(results, r)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// chunkFiles reads, detects and chunks files with a pool of workers
+func chunkFiles(root string, files []string, workers int, ch *chunker.Chunker) (Result, []indexer.IndexedChunk) {
+	start := time.Now()
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var chunks []indexer.IndexedChunk
+	errs := 0
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				c, err := chunkFile(root, path, ch)
+				mu.Lock()
+				if err != nil {
+					errs++
+				}
+				chunks = append(chunks, c...)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, f := range files {
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+
+	return Result{Stage: "chunk", Workers: workers, Items: len(files), Unit: "files", Duration: time.Since(start), Errors: errs}, chunks
+}
+
+func chunkFile(root, path string, ch *chunker.Chunker) ([]indexer.IndexedChunk, error) {
+	binary, err := walker.IsBinary(path)
+	if err != nil || binary {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	language := detector.SniffLanguage(path, string(data))
+	chunks, err := ch.ChunkFile(path, string(data), language)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, _ := filepath.Rel(root, path)
+	out := make([]indexer.IndexedChunk, len(chunks))
+	for i, c := range chunks {
+		out[i] = indexer.IndexedChunk{
+			ID:          fmt.Sprintf("bench-%s-%d", rel, c.StartLine),
+			FilePath:    rel,
+			ProjectPath: root,
+			ProjectType: "unknown",
+			Language:    language,
+			ChunkType:   c.ChunkType,
+			Content:     c.Content,
+			StartLine:   c.StartLine,
+			EndLine:     c.EndLine,
+		}
+	}
+	return out, nil
+}
+
+func embedChunks(ctx context.Context, embedder indexer.Embedder, chunks []indexer.IndexedChunk, batchSize int) (Result, error) {
+	start := time.Now()
+	for i := 0; i < len(chunks); i += batchSize {
+		end := min(i+batchSize, len(chunks))
+		texts := make([]string, end-i)
+		for j, c := range chunks[i:end] {
+			texts[j] = c.Content
+		}
+		vectors, err := embedder.EmbedBatch(ctx, texts)
+		if err != nil {
+			return Result{Stage: "embed", BatchSize: batchSize, Items: i, Unit: "chunks", Duration: time.Since(start), Errors: 1}, fmt.Errorf("embedding: %w", err)
+		}
+		for j := range vectors {
+			chunks[i+j].Embedding = vectors[j]
+		}
+	}
+	return Result{Stage: "embed", BatchSize: batchSize, Items: len(chunks), Unit: "chunks", Duration: time.Since(start)}, nil
+}
+
+func upsertChunks(ctx context.Context, store indexer.Store, chunks []indexer.IndexedChunk, batchSize int) (Result, error) {
+	start := time.Now()
+	for i := 0; i < len(chunks); i += batchSize {
+		end := min(i+batchSize, len(chunks))
+		if err := store.UpsertChunks(ctx, chunks[i:end]); err != nil {
+			return Result{Stage: "upsert", BatchSize: batchSize, Items: i, Unit: "chunks", Duration: time.Since(start), Errors: 1}, fmt.Errorf("upserting: %w", err)
+		}
+	}
+	return Result{Stage: "upsert", BatchSize: batchSize, Items: len(chunks), Unit: "chunks", Duration: time.Since(start)}, nil
+}
+
+// MockEmbedder returns zero vectors after a fixed per-call latency,
+// standing in for the embedding API without spending quota.
+type MockEmbedder struct {
+	Latency time.Duration
+	Dim     int
+}
+
+// EmbedBatch implements indexer.Embedder.
+func (m *MockEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	select {
+	case <-time.After(m.Latency):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	vectors := make([][]float32, len(texts))
+	for i := range vectors {
+		vectors[i] = make([]float32, m.Dim)
+	}
+	return vectors, nil
+}
+
+// DiscardStore accepts and drops every chunk.
+type DiscardStore struct{}
+
+// UpsertChunks implements indexer.Store.
+func (DiscardStore) UpsertChunks(ctx context.Context, chunks []indexer.IndexedChunk) error {
+	return nil
+}
+
+// DeleteByProject implements indexer.Store.
+func (DiscardStore) DeleteByProject(ctx context.Context, projectPath string) (int, error) {
+	return 0, nil
+}
+
+// DeleteFiles implements indexer.Store.
+func (DiscardStore) DeleteFiles(ctx context.Context, projectPath string, relPaths []string) (int, error) {
+	return 0, nil
+}
+
+// DeleteChunks implements indexer.Store.
+func (DiscardStore) DeleteChunks(ctx context.Context, ids []string) (int, error) {
+	return 0, nil
+}
+
+// UpdateLines implements indexer.Store.
+func (DiscardStore) UpdateLines(ctx context.Context, chunks []indexer.IndexedChunk) error {
+	return nil
+}
+
+// UpdateTags implements indexer.Store.
+func (DiscardStore) UpdateTags(ctx context.Context, projectPath string, tags []string) (int, error) {
+	return 0, nil
+}
+
+// TombstoneFiles implements indexer.Store.
+func (DiscardStore) TombstoneFiles(ctx context.Context, projectPath string, relPaths []string, deletedAt int64) (int, error) {
+	return 0, nil
+}
+
+// ExportProject implements indexer.Store; nothing is ever stored.
+func (DiscardStore) ExportProject(ctx context.Context, projectPath string, fn func(indexer.IndexedChunk) error) error {
+	return nil
+}
+
+// WriteTable prints results as an aligned comparison table.
+func WriteTable(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tWORKERS\tBATCH\tITEMS\tDURATION\tRATE\tERRORS")
+	for _, r := range results {
+		workers, batch := "-", "-"
+		if r.Workers > 0 {
+			workers = fmt.Sprint(r.Workers)
+		}
+		if r.BatchSize > 0 {
+			batch = fmt.Sprint(r.BatchSize)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d %s\t%s\t%.1f/s\t%d\n",
+			r.Stage, workers, batch, r.Items, r.Unit, r.Duration.Round(time.Millisecond), r.Rate(), r.Errors)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,133 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+)
+
+type countingStore struct {
+	DiscardStore
+	calls int
+}
+
+func (s *countingStore) UpsertChunks(ctx context.Context, chunks []indexer.IndexedChunk) error {
+	s.calls++
+	return nil
+}
+
+type failingEmbedder struct{}
+
+func (failingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, errors.New("quota exceeded")
+}
+
+func benchProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":   "package main\n\nfunc a() {\n}\n\nfunc b() {\n}\n\nfunc c() {\n}\n",
+		"README.md": "# Title\n\nIntro\n\n## Usage\n\nRun it\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRun_MeasuresEachStage(t *testing.T) {
+	dir := benchProject(t)
+	store := &countingStore{}
+
+	results, err := Run(context.Background(), dir, Options{
+		Workers:    []int{1, 2},
+		BatchSizes: []int{1, 10},
+		Embedder:   &MockEmbedder{Dim: 4},
+		Store:      store,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var stages []string
+	for _, r := range results {
+		stages = append(stages, r.Stage)
+	}
+	want := "walk,chunk,chunk,embed,embed,upsert,upsert"
+	if strings.Join(stages, ",") != want {
+		t.Fatalf("expected stages %s, got %s", want, strings.Join(stages, ","))
+	}
+
+	if results[0].Items != 2 {
+		t.Errorf("expected 2 files walked, got %d", results[0].Items)
+	}
+	chunks := results[3].Items
+	if chunks == 0 {
+		t.Fatal("expected chunks to be embedded")
+	}
+	// One upsert per chunk at batch size 1, then one for everything at 10
+	if store.calls != chunks+1 {
+		t.Errorf("expected %d upsert calls, got %d", chunks+1, store.calls)
+	}
+}
+
+func TestRun_MaxChunks(t *testing.T) {
+	dir := benchProject(t)
+
+	results, err := Run(context.Background(), dir, Options{
+		Workers:    []int{1},
+		BatchSizes: []int{10},
+		MaxChunks:  2,
+		Embedder:   &MockEmbedder{},
+		Store:      DiscardStore{},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, r := range results {
+		if (r.Stage == "embed" || r.Stage == "upsert") && r.Items != 2 {
+			t.Errorf("expected %s to be capped at 2 chunks, got %d", r.Stage, r.Items)
+		}
+	}
+}
+
+func TestRun_EmbedError(t *testing.T) {
+	dir := benchProject(t)
+
+	results, err := Run(context.Background(), dir, Options{
+		Workers:    []int{1},
+		BatchSizes: []int{10},
+		Embedder:   failingEmbedder{},
+		Store:      DiscardStore{},
+	})
+	if err == nil {
+		t.Fatal("expected embedding error")
+	}
+	last := results[len(results)-1]
+	if last.Stage != "embed" || last.Errors != 1 {
+		t.Errorf("expected failed embed result, got %+v", last)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	WriteTable(&buf, []Result{
+		{Stage: "walk", Items: 10, Unit: "files", Duration: 1e9},
+		{Stage: "chunk", Workers: 4, Items: 10, Unit: "files", Duration: 5e8},
+	})
+
+	out := buf.String()
+	for _, want := range []string{"STAGE", "walk", "10.0/s", "20.0/s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected table to contain %q, got:\n%s", want, out)
+		}
+	}
+}
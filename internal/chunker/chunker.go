@@ -1,73 +1,100 @@
 package chunker
 
 import (
-	"path/filepath"
 	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/detector"
 )
 
-const maxChunkSize = 4000
+// DefaultMaxChunkSize is the most characters a chunk holds unless New is
+// given another maximum; larger sections are split at line boundaries.
+const DefaultMaxChunkSize = 4000
+
+// ChunkTypes lists every ChunkType the chunkers produce, along with the
+// commit and diff_hunk types of indexed git history.
+var ChunkTypes = []string{"class", "code", "commit", "config_key", "diff_hunk", "function", "header", "paragraph", "preamble"}
 
 // Chunk represents a semantic chunk of content from a file
 type Chunk struct {
 	Content   string
 	StartLine int
 	EndLine   int
-	ChunkType string // function, class, paragraph, header, config_key
+	ChunkType string   // function, class, paragraph, header, config_key
+	Symbols   []string // names declared in code chunks, e.g. functions and classes
+	// HeadingPath is the markdown heading of the chunk's section with the
+	// headings enclosing it, e.g. "Getting Started > Installation"
+	HeadingPath string
 }
 
-// ChunkFile splits a file into semantic chunks based on its language
+// Chunker splits files into semantic chunks of at most a maximum size.
+// Code search favors small chunks around a single declaration, while
+// prose-heavy documentation reads better in larger sections.
+type Chunker struct {
+	maxSize int
+}
+
+// New returns a Chunker whose chunks hold at most maxSize characters, or
+// DefaultMaxChunkSize if maxSize isn't positive.
+func New(maxSize int) *Chunker {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxChunkSize
+	}
+	return &Chunker{maxSize: maxSize}
+}
+
+var defaultChunker = New(DefaultMaxChunkSize)
+
+// ChunkFile splits a file into semantic chunks based on its language, of at
+// most DefaultMaxChunkSize characters
 func ChunkFile(path string, content string, language string) ([]Chunk, error) {
+	return defaultChunker.ChunkFile(path, content, language)
+}
+
+// ChunkText is Chunker.ChunkText with chunks of at most DefaultMaxChunkSize
+// characters
+func ChunkText(content string, isMarkdown bool) ([]Chunk, error) {
+	return defaultChunker.ChunkText(content, isMarkdown)
+}
+
+// ChunkCode is Chunker.ChunkCode with chunks of at most DefaultMaxChunkSize
+// characters
+func ChunkCode(content string, language string) ([]Chunk, error) {
+	return defaultChunker.ChunkCode(content, language)
+}
+
+// ChunkFile splits a file into semantic chunks based on its language
+func (ch *Chunker) ChunkFile(path string, content string, language string) ([]Chunk, error) {
 	if strings.TrimSpace(content) == "" {
 		return []Chunk{}, nil
 	}
 
-	// Determine language from extension if not provided or unknown
+	// Determine language from the file name or shebang if not provided or
+	// unknown
 	lang := strings.ToLower(language)
 	if lang == "" || lang == "unknown" {
-		ext := strings.ToLower(filepath.Ext(path))
-		switch ext {
-		case ".go":
-			lang = "go"
-		case ".py":
-			lang = "python"
-		case ".js":
-			lang = "javascript"
-		case ".ts":
-			lang = "typescript"
-		case ".java":
-			lang = "java"
-		case ".md", ".markdown":
-			lang = "markdown"
-		case ".yaml", ".yml":
-			lang = "yaml"
-		case ".json":
-			lang = "json"
-		case ".toml":
-			lang = "toml"
-		default:
-			lang = "text"
-		}
+		lang = detector.SniffLanguage(path, content)
 	}
 
 	switch lang {
-	case "go", "python", "javascript", "typescript", "java":
-		return ChunkCode(content, lang)
+	case "go", "python", "javascript", "typescript", "java", "csharp", "fsharp":
+		return ch.ChunkCode(content, lang)
 	case "markdown":
-		return ChunkText(content, true)
+		return ch.ChunkText(content, true)
 	case "yaml":
-		return chunkYAML(content)
+		return ch.chunkYAML(content)
 	case "json":
-		return chunkJSON(content)
+		return ch.chunkJSON(content)
 	case "toml":
-		return chunkTOML(content)
+		return ch.chunkTOML(content)
 	default:
-		return ChunkText(content, false)
+		return ch.ChunkText(content, false)
 	}
 }
 
-// splitLargeChunk splits a chunk into sub-chunks if it exceeds maxChunkSize
-func splitLargeChunk(chunk Chunk) []Chunk {
-	if len(chunk.Content) <= maxChunkSize {
+// splitLargeChunk splits a chunk into sub-chunks if it exceeds the
+// maximum size
+func (ch *Chunker) splitLargeChunk(chunk Chunk) []Chunk {
+	if len(chunk.Content) <= ch.maxSize {
 		return []Chunk{chunk}
 	}
 
@@ -78,7 +105,7 @@ func splitLargeChunk(chunk Chunk) []Chunk {
 	currentLine := chunk.StartLine
 
 	for i, line := range lines {
-		if currentContent.Len()+len(line)+1 > maxChunkSize && currentContent.Len() > 0 {
+		if currentContent.Len()+len(line)+1 > ch.maxSize && currentContent.Len() > 0 {
 			result = append(result, Chunk{
 				Content:   currentContent.String(),
 				StartLine: currentStart,
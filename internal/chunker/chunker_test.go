@@ -323,6 +323,116 @@ public class Calculator {
 	}
 }
 
+// Files without a language use the detector's extension table
+func TestChunkFile_DetectsLanguageFromExtension(t *testing.T) {
+	content := "function a() {\n}\n\nfunction b() {\n}\n"
+	chunks, err := ChunkFile("App.jsx", content, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].ChunkType != "function" {
+		t.Errorf("expected .jsx to be chunked as JavaScript functions, got %+v", chunks)
+	}
+}
+
+func TestChunkFile_CSharp(t *testing.T) {
+	content := `using System;
+
+namespace Demo
+{
+    public class Calculator
+    {
+        private int result;
+
+        public int Add(int a, int b)
+        {
+            return a + b;
+        }
+
+        public static async Task<int> FetchAsync(string url)
+        {
+            return await Get(url);
+        }
+    }
+}`
+
+	chunks, err := ChunkFile("Calculator.cs", content, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	types := map[string]string{}
+	for _, chunk := range chunks {
+		firstLine, _, _ := strings.Cut(strings.TrimSpace(chunk.Content), "\n")
+		types[firstLine] = chunk.ChunkType
+	}
+	for line, want := range map[string]string{
+		"public class Calculator":                              "class",
+		"public int Add(int a, int b)":                         "function",
+		"public static async Task<int> FetchAsync(string url)": "function",
+	} {
+		if types[line] != want {
+			t.Errorf("expected a %q chunk starting with %q, got chunks %v", want, line, types)
+		}
+	}
+}
+
+func TestChunkFile_Symbols(t *testing.T) {
+	content := `package auth
+
+const (
+	DefaultTTL = 3600
+	maxRetries = 3
+)
+
+type Session struct {
+	ID string
+}
+
+func (s *Session) Valid() bool {
+	return s.ID != ""
+}
+
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	parseForm(r)
+}`
+
+	chunks, err := ChunkFile("auth.go", content, "go")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var symbols []string
+	for _, chunk := range chunks {
+		symbols = append(symbols, chunk.Symbols...)
+	}
+	want := []string{"DefaultTTL", "Session", "ID", "Valid", "handleLogin"}
+	if strings.Join(symbols, ",") != strings.Join(want, ",") {
+		t.Errorf("expected symbols %v, got %v", want, symbols)
+	}
+	for _, chunk := range chunks {
+		if strings.Contains(chunk.Content, "func handleLogin") && (len(chunk.Symbols) != 1 || chunk.Symbols[0] != "handleLogin") {
+			t.Errorf("expected the handleLogin chunk to declare only handleLogin, got %v", chunk.Symbols)
+		}
+	}
+}
+
+func TestExtractSymbols(t *testing.T) {
+	for _, tc := range []struct {
+		language, content string
+		want              []string
+	}{
+		{"python", "MAX_SIZE = 10\n\nclass Cache:\n    async def get(self, key):\n        pass\n", []string{"MAX_SIZE", "Cache", "get"}},
+		{"typescript", "export interface User {}\nexport type ID = string\nexport const login = async () => {}\nfunction logout() {}\n", []string{"User", "ID", "login", "logout"}},
+		{"java", "public class Account {\n    public void deposit(int amount) {\n        save(amount);\n    }\n}\n", []string{"Account", "deposit"}},
+		{"markdown", "# Title\n", nil},
+	} {
+		got := extractSymbols(tc.content, tc.language)
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: expected symbols %v, got %v", tc.language, tc.want, got)
+		}
+	}
+}
+
 // Test Markdown split at headers
 func TestChunkFile_Markdown(t *testing.T) {
 	content := `# Main Title
@@ -605,6 +715,44 @@ func TestChunkFile_LargeFunction(t *testing.T) {
 	}
 }
 
+// Test a smaller maximum splits sections the default keeps whole
+func TestNew_ChunkSize(t *testing.T) {
+	var builder strings.Builder
+	builder.WriteString("# Guide\n\n")
+	for i := 0; i < 30; i++ {
+		builder.WriteString("Every deployment starts from a tagged release.\n")
+	}
+	content := builder.String()
+
+	chunks, err := ChunkFile("guide.md", content, "markdown")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) != 1 {
+		t.Fatalf("expected one chunk at the default size, got %d", len(chunks))
+	}
+
+	chunks, err = New(500).ChunkFile("guide.md", content, "markdown")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("expected the section split into chunks of 500 characters, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if len(chunk.Content) > 500 {
+			t.Errorf("chunk %d exceeds 500 characters: %d", i, len(chunk.Content))
+		}
+		if chunk.HeadingPath != "Guide" {
+			t.Errorf("chunk %d: expected heading path Guide, got %q", i, chunk.HeadingPath)
+		}
+	}
+
+	if New(0).maxSize != DefaultMaxChunkSize {
+		t.Errorf("expected 0 to mean %d, got %d", DefaultMaxChunkSize, New(0).maxSize)
+	}
+}
+
 // Test empty file returns empty slice
 func TestChunkFile_EmptyFile(t *testing.T) {
 	chunks, err := ChunkFile("empty.go", "", "go")
@@ -732,6 +880,66 @@ More content.`
 	}
 }
 
+func TestChunkText_MarkdownHeadingPath(t *testing.T) {
+	content := `# Getting Started
+
+Intro.
+
+## Installation ##
+
+Run go install.
+
+### From source
+
+Clone it.
+
+## Usage
+
+Run it.
+
+# FAQ
+
+Questions.`
+
+	chunks, err := ChunkText(content, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"Getting Started",
+		"Getting Started > Installation",
+		"Getting Started > Installation > From source",
+		"Getting Started > Usage",
+		"FAQ",
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %d", len(want), len(chunks))
+	}
+	for i, c := range chunks {
+		if c.HeadingPath != want[i] {
+			t.Errorf("chunk %d: expected heading path %q, got %q", i, want[i], c.HeadingPath)
+		}
+	}
+}
+
+func TestChunkText_MarkdownHeadingPathOnSplitChunks(t *testing.T) {
+	content := "# Guide\n\n## Reference\n\n" + strings.Repeat("A long line of reference text.\n", 300)
+
+	chunks, err := ChunkText(content, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chunks) < 3 {
+		t.Fatalf("expected the long section to be split, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks[1:] {
+		if c.HeadingPath != "Guide > Reference" {
+			t.Errorf("expected every part to keep the heading path, got %q", c.HeadingPath)
+		}
+	}
+}
+
 // Test ChunkText directly for plain text
 func TestChunkText_PlainText(t *testing.T) {
 	content := `Paragraph one.
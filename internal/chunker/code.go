@@ -11,10 +11,16 @@ var (
 	pythonDefClassPattern = regexp.MustCompile(`(?m)^(class|def)\s+\w+`)
 	jsFuncPattern         = regexp.MustCompile(`(?m)^(async\s+)?function\s+\w+|^(export\s+)?(async\s+)?function\s+\w+|^class\s+\w+`)
 	javaMethodPattern     = regexp.MustCompile(`(?m)^\s*(public|private|protected)?\s*(static)?\s*\w+\s+\w+\s*\(`)
+	// C# members are nested in namespaces and types, so declarations are
+	// matched at any indentation; methods need an access modifier to tell
+	// them from calls
+	csharpDeclPattern = regexp.MustCompile(`(?m)^\s*((public|private|protected|internal|static|abstract|sealed|partial|readonly)\s+)*(class|struct|interface|record|enum)\s+\w+|^\s*(public|private|protected|internal)\s+((static|async|override|virtual|abstract|sealed|new)\s+)*[\w<>\[\],.?]+\s+\w+\s*[(<]`)
+	fsharpDeclPattern = regexp.MustCompile(`(?m)^(let|type|module)\s+|^\s+(member|override|abstract|static member)\s+`)
+	csharpTypePattern = regexp.MustCompile(`\b(class|struct|interface|record|enum)\s+\w+`)
 )
 
 // ChunkCode splits code content into semantic chunks based on language
-func ChunkCode(content string, language string) ([]Chunk, error) {
+func (ch *Chunker) ChunkCode(content string, language string) ([]Chunk, error) {
 	if strings.TrimSpace(content) == "" {
 		return []Chunk{}, nil
 	}
@@ -29,6 +35,10 @@ func ChunkCode(content string, language string) ([]Chunk, error) {
 		pattern = jsFuncPattern
 	case "java":
 		pattern = javaMethodPattern
+	case "csharp":
+		pattern = csharpDeclPattern
+	case "fsharp":
+		pattern = fsharpDeclPattern
 	default:
 		// For unknown languages, return as single chunk
 		return []Chunk{{
@@ -39,11 +49,18 @@ func ChunkCode(content string, language string) ([]Chunk, error) {
 		}}, nil
 	}
 
-	return chunkByPattern(content, pattern, language)
+	chunks, err := ch.chunkByPattern(content, pattern, language)
+	if err != nil {
+		return nil, err
+	}
+	for i := range chunks {
+		chunks[i].Symbols = extractSymbols(chunks[i].Content, language)
+	}
+	return chunks, nil
 }
 
 // chunkByPattern splits content at pattern matches
-func chunkByPattern(content string, pattern *regexp.Regexp, language string) ([]Chunk, error) {
+func (ch *Chunker) chunkByPattern(content string, pattern *regexp.Regexp, language string) ([]Chunk, error) {
 	lines := strings.Split(content, "\n")
 	matches := pattern.FindAllStringIndex(content, -1)
 
@@ -104,7 +121,7 @@ func chunkByPattern(content string, pattern *regexp.Regexp, language string) ([]
 		}
 
 		// Split large chunks
-		chunks = append(chunks, splitLargeChunk(chunk)...)
+		chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 	}
 
 	return chunks, nil
@@ -124,6 +141,17 @@ func determineChunkType(content string, language string) string {
 			return "class"
 		}
 		return "function"
+	case "csharp":
+		firstLine, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+		if csharpTypePattern.MatchString(firstLine) {
+			return "class"
+		}
+		return "function"
+	case "fsharp":
+		if strings.HasPrefix(strings.TrimSpace(content), "type ") {
+			return "class"
+		}
+		return "function"
 	default:
 		return "function"
 	}
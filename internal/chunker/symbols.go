@@ -0,0 +1,77 @@
+package chunker
+
+import (
+	"regexp"
+	"sort"
+)
+
+// symbolPatterns find the names code declares, by language. The first
+// submatch of each pattern is the name.
+var symbolPatterns = map[string][]*regexp.Regexp{
+	"go": {
+		regexp.MustCompile(`(?m)^func\s+(?:\([^)]*\)\s*)?(\w+)`),
+		regexp.MustCompile(`(?m)^(?:type|const|var)\s+(\w+)`),
+		// Exported names in type, const and var blocks (and struct fields)
+		regexp.MustCompile(`(?m)^\t([A-Z]\w*)\s`),
+	},
+	"python": {
+		regexp.MustCompile(`(?m)^\s*(?:async\s+)?def\s+(\w+)`),
+		regexp.MustCompile(`(?m)^\s*class\s+(\w+)`),
+		regexp.MustCompile(`(?m)^([A-Z][A-Z0-9_]*)\s*(?::[^=\n]+)?=`),
+	},
+	"javascript": {
+		regexp.MustCompile(`(?m)\bfunction\s*\*?\s*(\w+)`),
+		regexp.MustCompile(`(?m)\bclass\s+(\w+)`),
+		regexp.MustCompile(`(?m)^(?:export\s+)?(?:const|let|var)\s+(\w+)\s*=`),
+	},
+	"typescript": {
+		regexp.MustCompile(`(?m)\bfunction\s*\*?\s*(\w+)`),
+		regexp.MustCompile(`(?m)\b(?:class|interface|enum)\s+(\w+)`),
+		regexp.MustCompile(`(?m)^(?:export\s+)?(?:declare\s+)?type\s+(\w+)`),
+		regexp.MustCompile(`(?m)^(?:export\s+)?(?:const|let|var)\s+(\w+)\s*[:=]`),
+	},
+	"java": {
+		regexp.MustCompile(`(?m)\b(?:class|interface|enum|record)\s+(\w+)`),
+		regexp.MustCompile(`(?m)^\s*(?:(?:public|private|protected|static|final|abstract|synchronized)\s+)+[\w<>\[\],.?]+\s+(\w+)\s*\(`),
+	},
+	"csharp": {
+		regexp.MustCompile(`(?m)\b(?:class|struct|interface|record|enum)\s+(\w+)`),
+		regexp.MustCompile(`(?m)^\s*(?:public|private|protected|internal)\s+(?:(?:static|async|override|virtual|abstract|sealed|new)\s+)*[\w<>\[\],.?]+\s+(\w+)\s*[(<]`),
+	},
+	"fsharp": {
+		regexp.MustCompile(`(?m)^\s*(?:let|type|module)\s+(?:(?:rec|inline|private|internal|mutable)\s+)*(\w+)`),
+		regexp.MustCompile(`(?m)^\s+(?:member|override|abstract)\s+(?:\w+\.)?(\w+)`),
+	},
+}
+
+// extractSymbols returns the distinct names content declares in the
+// language, in order of appearance
+func extractSymbols(content, language string) []string {
+	patterns := symbolPatterns[language]
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	type match struct {
+		pos  int
+		name string
+	}
+	var matches []match
+	for _, p := range patterns {
+		for _, m := range p.FindAllStringSubmatchIndex(content, -1) {
+			matches = append(matches, match{m[2], content[m[2]:m[3]]})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].pos < matches[j].pos })
+
+	var symbols []string
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if !seen[m.name] {
+			seen[m.name] = true
+			symbols = append(symbols, m.name)
+		}
+	}
+	return symbols
+}
@@ -9,19 +9,19 @@ var markdownHeaderPattern = regexp.MustCompile(`(?m)^#{1,6}\s+`)
 
 // ChunkText splits text content into semantic chunks
 // If isMarkdown is true, splits at headers; otherwise splits at paragraph breaks
-func ChunkText(content string, isMarkdown bool) ([]Chunk, error) {
+func (ch *Chunker) ChunkText(content string, isMarkdown bool) ([]Chunk, error) {
 	if strings.TrimSpace(content) == "" {
 		return []Chunk{}, nil
 	}
 
 	if isMarkdown {
-		return chunkMarkdown(content)
+		return ch.chunkMarkdown(content)
 	}
-	return chunkPlainText(content)
+	return ch.chunkPlainText(content)
 }
 
 // chunkMarkdown splits markdown content at header boundaries
-func chunkMarkdown(content string) ([]Chunk, error) {
+func (ch *Chunker) chunkMarkdown(content string) ([]Chunk, error) {
 	lines := strings.Split(content, "\n")
 	matches := markdownHeaderPattern.FindAllStringIndex(content, -1)
 
@@ -42,6 +42,7 @@ func chunkMarkdown(content string) ([]Chunk, error) {
 	}
 
 	var chunks []Chunk
+	var headings []heading // enclosing the current section, outermost first
 
 	// Process each header section
 	for i := 0; i < len(matchLines); i++ {
@@ -60,6 +61,12 @@ func chunkMarkdown(content string) ([]Chunk, error) {
 
 		chunkContent := strings.Join(lines[startLine-1:endLine], "\n")
 
+		h := parseHeading(lines[startLine-1])
+		for len(headings) > 0 && headings[len(headings)-1].level >= h.level {
+			headings = headings[:len(headings)-1]
+		}
+		headings = append(headings, h)
+
 		chunk := Chunk{
 			Content:   chunkContent,
 			StartLine: startLine,
@@ -67,14 +74,46 @@ func chunkMarkdown(content string) ([]Chunk, error) {
 			ChunkType: "header",
 		}
 
-		chunks = append(chunks, splitLargeChunk(chunk)...)
+		path := headingPath(headings)
+		for _, c := range ch.splitLargeChunk(chunk) {
+			c.HeadingPath = path
+			chunks = append(chunks, c)
+		}
 	}
 
 	return chunks, nil
 }
 
+// heading is a markdown ATX heading
+type heading struct {
+	level int
+	title string
+}
+
+// parseHeading parses a heading line such as "## Installation ##"
+func parseHeading(line string) heading {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	title := strings.TrimSpace(line[level:])
+	// An optional closing sequence of #s must follow a space
+	if t := strings.TrimRight(title, "#"); t != title && (t == "" || strings.HasSuffix(t, " ")) {
+		title = strings.TrimSpace(t)
+	}
+	return heading{level: level, title: title}
+}
+
+// headingPath joins heading titles, outermost first, with " > "
+func headingPath(headings []heading) string {
+	titles := make([]string, 0, len(headings))
+	for _, h := range headings {
+		if h.title != "" {
+			titles = append(titles, h.title)
+		}
+	}
+	return strings.Join(titles, " > ")
+}
+
 // chunkPlainText splits plain text at paragraph breaks (blank lines)
-func chunkPlainText(content string) ([]Chunk, error) {
+func (ch *Chunker) chunkPlainText(content string) ([]Chunk, error) {
 	lines := strings.Split(content, "\n")
 
 	var chunks []Chunk
@@ -93,7 +132,7 @@ func chunkPlainText(content string) ([]Chunk, error) {
 					EndLine:   lineNum - 1,
 					ChunkType: "paragraph",
 				}
-				chunks = append(chunks, splitLargeChunk(chunk)...)
+				chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 				currentChunk = nil
 			}
 			currentStart = lineNum + 1
@@ -110,7 +149,7 @@ func chunkPlainText(content string) ([]Chunk, error) {
 			EndLine:   len(lines),
 			ChunkType: "paragraph",
 		}
-		chunks = append(chunks, splitLargeChunk(chunk)...)
+		chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 	}
 
 	// If no chunks were created but content exists, return it as one chunk
@@ -127,7 +166,7 @@ func chunkPlainText(content string) ([]Chunk, error) {
 }
 
 // chunkYAML splits YAML content by top-level keys
-func chunkYAML(content string) ([]Chunk, error) {
+func (ch *Chunker) chunkYAML(content string) ([]Chunk, error) {
 	lines := strings.Split(content, "\n")
 	topLevelKeyPattern := regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*:`)
 
@@ -147,7 +186,7 @@ func chunkYAML(content string) ([]Chunk, error) {
 					EndLine:   lineNum - 1,
 					ChunkType: "config_key",
 				}
-				chunks = append(chunks, splitLargeChunk(chunk)...)
+				chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 			}
 			currentChunk = []string{line}
 			currentStart = lineNum
@@ -169,14 +208,14 @@ func chunkYAML(content string) ([]Chunk, error) {
 			EndLine:   endLine,
 			ChunkType: "config_key",
 		}
-		chunks = append(chunks, splitLargeChunk(chunk)...)
+		chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 	}
 
 	return chunks, nil
 }
 
 // chunkJSON splits JSON content by top-level keys
-func chunkJSON(content string) ([]Chunk, error) {
+func (ch *Chunker) chunkJSON(content string) ([]Chunk, error) {
 	lines := strings.Split(content, "\n")
 	topLevelKeyPattern := regexp.MustCompile(`^\s*"[^"]+"\s*:`)
 
@@ -217,7 +256,7 @@ func chunkJSON(content string) ([]Chunk, error) {
 					EndLine:   lineNum - 1,
 					ChunkType: "config_key",
 				}
-				chunks = append(chunks, splitLargeChunk(chunk)...)
+				chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 			}
 			currentChunk = []string{line}
 			currentStart = lineNum
@@ -246,14 +285,14 @@ func chunkJSON(content string) ([]Chunk, error) {
 			EndLine:   endLine,
 			ChunkType: "config_key",
 		}
-		chunks = append(chunks, splitLargeChunk(chunk)...)
+		chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 	}
 
 	return chunks, nil
 }
 
 // chunkTOML splits TOML content by sections
-func chunkTOML(content string) ([]Chunk, error) {
+func (ch *Chunker) chunkTOML(content string) ([]Chunk, error) {
 	lines := strings.Split(content, "\n")
 	sectionPattern := regexp.MustCompile(`^\s*\[[^\]]+\]`)
 
@@ -279,7 +318,7 @@ func chunkTOML(content string) ([]Chunk, error) {
 					EndLine:   endLine,
 					ChunkType: "config_key",
 				}
-				chunks = append(chunks, splitLargeChunk(chunk)...)
+				chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 			}
 			currentChunk = []string{line}
 			currentStart = lineNum
@@ -308,7 +347,7 @@ func chunkTOML(content string) ([]Chunk, error) {
 			EndLine:   endLine,
 			ChunkType: "config_key",
 		}
-		chunks = append(chunks, splitLargeChunk(chunk)...)
+		chunks = append(chunks, ch.splitLargeChunk(chunk)...)
 	}
 
 	return chunks, nil
@@ -2,66 +2,300 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// appName is the directory name used under the user's config and data dirs
+const appName = "swarm-indexer"
+
 // Config holds all configuration for swarm-indexer
 type Config struct {
 	// Typesense settings
 	TypesenseURL        string
 	TypesenseAPIKey     string
 	TypesenseCollection string
+	TypesenseTimeout    time.Duration
 
 	// Gemini settings
 	GeminiAPIKey    string
 	GeminiModel     string
 	GeminiRateLimit int
+	GeminiTimeout   time.Duration
+
+	// SummaryModel is the Gemini model that summarizes code chunks before
+	// they are embedded; empty disables summaries, which cost a request
+	// per chunk
+	SummaryModel string
+
+	// Proxy for Typesense and Gemini requests; empty means HTTP(S)_PROXY
+	// from the environment
+	Proxy string
+
+	// OTLPEndpoint is the OTLP/HTTP collector traces are exported to;
+	// empty disables tracing
+	OTLPEndpoint string
+
+	// WebhookURL is notified when an index run finishes, as set by
+	// WebhookOn (WebhookAlways or WebhookFailure); empty disables it
+	WebhookURL string
+	WebhookOn  string
+
+	// QueueURL is the Redis server index --distribute hands files to
+	// workers through; it may hold a password
+	QueueURL string
+
+	// ContentKey is the base64 AES-256 key document content is encrypted
+	// with before it is stored; empty stores it in plain text
+	ContentKey string
 
-	// Worker settings
-	Workers   int
-	BatchSize int
+	// Concurrency of each stage: Workers process files (CPU-bound),
+	// EmbedWorkers call the embedding API and UpsertWorkers write batches
+	// to Typesense
+	Workers       int
+	EmbedWorkers  int
+	UpsertWorkers int
+	BatchSize     int
+	// Bounds of the queues between stages: batches waiting to be embedded,
+	// and files a distributed run has queued for workers
+	MaxQueuedBatches int
+	MaxQueuedJobs    int
+
+	// MaxEmbedTokens is the estimated number of tokens a run may embed
+	// before it stops; 0 means no budget
+	MaxEmbedTokens int
+
+	// MaxFileSize is the size in bytes above which files are skipped; 0
+	// means no limit
+	MaxFileSize int
+
+	// ChunkSize is the most characters a chunk holds; larger sections of
+	// a file are split
+	ChunkSize int
+
+	// ReindexAfter is how old a project's last index may get before an
+	// index run reindexes it from scratch, even with no change detected;
+	// 0 means never
+	ReindexAfter time.Duration
+
+	// DeleteGrace is how long the documents of removed files are kept as
+	// tombstones, hidden from searches, before they are deleted; 0 deletes
+	// them at once
+	DeleteGrace time.Duration
 
 	// Skip files pattern
 	SkipFiles string
+
+	// SkipGenerated leaves out files that look generated: marked by a
+	// "Code generated ... DO NOT EDIT" comment, referencing a source map,
+	// or minified
+	SkipGenerated bool
+
+	// Languages maps file extensions (".gotmpl") to the language their
+	// files are chunked as, adding to or overriding the built-in table
+	Languages map[string]string
+
+	// Entropy-based secret detection, off unless EntropyEnabled; a zero
+	// threshold means the charset's default
+	EntropyEnabled   bool
+	EntropyThreshold float64
+	EntropyMinLength int
+	EntropyCharset   string
+
+	// Redaction style, RedactionMarker or RedactionPadded
+	Redaction string
+
+	// Keyword matching of searches: the typos tolerated per word (0-2),
+	// whether the last word matches as a prefix, and how few results make
+	// Typesense retry the query with words dropped (0 never drops any)
+	NumTypos            int
+	Prefix              bool
+	DropTokensThreshold int
+
+	// Custom secret detection rules from the config file
+	SecretRules []SecretRule
+
+	// Pipeline hooks from the config file
+	Hooks []Hook
 }
 
-// Load loads configuration from environment variables
+// Load resolves configuration from flag overrides, then environment
+// variables, then the config file, then defaults
 func Load() (*Config, error) {
-	cfg := &Config{
-		TypesenseURL:        getEnv("TYPESENSE_URL", "http://localhost:8108"),
-		TypesenseAPIKey:     os.Getenv("TYPESENSE_API_KEY"),
-		TypesenseCollection: getEnv("TYPESENSE_COLLECTION", "swarm-index"),
-		GeminiAPIKey:        os.Getenv("GEMINI_API_KEY"),
-		GeminiModel:         getEnv("GEMINI_MODEL", "gemini-embedding-001"),
-		GeminiRateLimit:     getEnvInt("GEMINI_RATE_LIMIT", 60),
-		Workers:             getEnvInt("SWARM_INDEXER_WORKERS", 8),
-		BatchSize:           getEnvInt("SWARM_INDEXER_BATCH_SIZE", 100),
-		SkipFiles:           getEnv("SWARM_INDEXER_SKIP_FILES", ".env,.setenv,*.pem,*.key,credentials.*"),
+	cfg, err := LoadLocal()
+	if err != nil {
+		return nil, err
 	}
-
 	if cfg.TypesenseAPIKey == "" {
 		return nil, errors.New("TYPESENSE_API_KEY is required")
 	}
 	if cfg.GeminiAPIKey == "" {
 		return nil, errors.New("GEMINI_API_KEY is required")
 	}
+	return cfg, nil
+}
+
+// LoadLocal is like Load but doesn't require the API keys, for commands
+// that only work on local files.
+func LoadLocal() (*Config, error) {
+	f, err := readConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	values, err := resolve(f)
+	if err != nil {
+		return nil, err
+	}
+	get := func(key string) string { return find(values, key).Value }
+
+	cfg := &Config{
+		TypesenseURL:        get("typesense_url"),
+		TypesenseAPIKey:     get("typesense_api_key"),
+		TypesenseCollection: get("typesense_collection"),
+		TypesenseTimeout:    getDuration(values, "typesense_timeout"),
+		GeminiAPIKey:        get("gemini_api_key"),
+		GeminiModel:         get("gemini_model"),
+		GeminiRateLimit:     getInt(values, "gemini_rate_limit"),
+		GeminiTimeout:       getDuration(values, "gemini_timeout"),
+		SummaryModel:        get("summary_model"),
+		Proxy:               get("proxy"),
+		OTLPEndpoint:        get("otlp_endpoint"),
+		WebhookURL:          get("webhook_url"),
+		WebhookOn:           get("webhook_on"),
+		QueueURL:            get("queue_url"),
+		ContentKey:          get("content_key"),
+		Workers:             getInt(values, "workers"),
+		EmbedWorkers:        getInt(values, "embed_workers"),
+		UpsertWorkers:       getInt(values, "upsert_workers"),
+		BatchSize:           getInt(values, "batch_size"),
+		MaxQueuedBatches:    getInt(values, "max_queued_batches"),
+		MaxQueuedJobs:       getInt(values, "max_queued_jobs"),
+		MaxEmbedTokens:      getInt(values, "max_embed_tokens"),
+		MaxFileSize:         getInt(values, "max_file_size"),
+		ChunkSize:           getInt(values, "chunk_size"),
+		ReindexAfter:        getDuration(values, "reindex_after"),
+		DeleteGrace:         getDuration(values, "delete_grace"),
+		SkipFiles:           get("skip_files"),
+		SkipGenerated:       get("skip_generated") != "false",
+		EntropyEnabled:      get("entropy_enabled") == "true",
+		EntropyThreshold:    getFloat(values, "entropy_threshold"),
+		EntropyMinLength:    getInt(values, "entropy_min_length"),
+		EntropyCharset:      get("entropy_charset"),
+		Redaction:           get("redaction"),
+		NumTypos:            getInt(values, "search_num_typos"),
+		Prefix:              get("search_prefix") != "false",
+		DropTokensThreshold: getInt(values, "search_drop_tokens_threshold"),
+	}
+	if f.secrets != nil {
+		cfg.SecretRules = f.secrets.Rules
+	}
+	cfg.Hooks = f.hooks
+
+	for _, v := range values {
+		if v.Value == "" || (v.Key != "proxy" && v.Key != "otlp_endpoint" && v.Key != "webhook_url" && v.Key != "queue_url" && v.Key != "content_key" && v.Key != "reindex_after" && v.Key != "delete_grace" && v.Key != "skip_files" && v.Key != "languages" && v.Key != "search_drop_tokens_threshold" && len(v.Choices) == 0) {
+			continue
+		}
+		if err := validateValue(v.Setting, v.Value); err != nil {
+			return nil, fmt.Errorf("%s: %w", v.Env, err)
+		}
+	}
+	cfg.Languages, _ = ParseLanguages(get("languages"))
 
 	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Dir returns the directory holding user-level swarm-indexer files (saved
+// searches, registry, config). SWARM_INDEXER_CONFIG_DIR overrides the
+// default of $XDG_CONFIG_HOME/swarm-indexer.
+func Dir() (string, error) {
+	if dir := os.Getenv("SWARM_INDEXER_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, appName), nil
+}
+
+// DataDir returns the directory holding machine-written state such as usage
+// counters. SWARM_INDEXER_DATA_DIR overrides the default of
+// $XDG_DATA_HOME/swarm-indexer (~/.local/share/swarm-indexer).
+func DataDir() (string, error) {
+	if dir := os.Getenv("SWARM_INDEXER_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+	if base := os.Getenv("XDG_DATA_HOME"); base != "" {
+		return filepath.Join(base, appName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", appName), nil
+}
+
+// ParseLanguages parses extension to language mappings such as
+// ".gotmpl=go,jsonl=json" into a map keyed by lower-case extension with
+// its leading dot.
+func ParseLanguages(s string) (map[string]string, error) {
+	langs := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		ext, lang, ok := strings.Cut(entry, "=")
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if !ok || strings.Trim(ext, ".") == "" || lang == "" {
+			return nil, fmt.Errorf("%q is not an extension=language mapping (e.g. .gotmpl=go)", entry)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		langs[ext] = lang
+	}
+	return langs, nil
+}
+
+// getInt parses an integer setting, falling back to its default when the
+// value doesn't parse
+func getInt(values []Value, key string) int {
+	v := find(values, key)
+	if n, err := strconv.Atoi(v.Value); err == nil {
+		return n
+	}
+	n, _ := strconv.Atoi(v.Default)
+	return n
+}
+
+// getFloat parses a number setting, returning 0 when it is unset or
+// doesn't parse
+func getFloat(values []Value, key string) float64 {
+	f, _ := strconv.ParseFloat(find(values, key).Value, 64)
+	return f
+}
+
+// getDuration parses a duration setting, falling back to its default when
+// the value doesn't parse
+func getDuration(values []Value, key string) time.Duration {
+	v := find(values, key)
+	if d, err := time.ParseDuration(v.Value); err == nil {
+		return d
 	}
-	return defaultValue
+	d, _ := time.ParseDuration(v.Default)
+	return d
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+func find(values []Value, key string) Value {
+	for _, v := range values {
+		if v.Key == key {
+			return v
 		}
 	}
-	return defaultValue
+	return Value{}
 }
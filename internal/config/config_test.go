@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -39,6 +41,9 @@ func TestLoadConfig_Defaults(t *testing.T) {
 	if cfg.Workers != 8 {
 		t.Errorf("expected Workers to be 8, got %d", cfg.Workers)
 	}
+	if cfg.EmbedWorkers != 4 || cfg.UpsertWorkers != 2 {
+		t.Errorf("expected EmbedWorkers 4 and UpsertWorkers 2, got %d and %d", cfg.EmbedWorkers, cfg.UpsertWorkers)
+	}
 	if cfg.BatchSize != 100 {
 		t.Errorf("expected BatchSize to be 100, got %d", cfg.BatchSize)
 	}
@@ -59,6 +64,8 @@ func TestLoadConfig_CustomValues(t *testing.T) {
 	os.Setenv("GEMINI_MODEL", "custom-model")
 	os.Setenv("GEMINI_RATE_LIMIT", "120")
 	os.Setenv("SWARM_INDEXER_WORKERS", "16")
+	os.Setenv("SWARM_INDEXER_EMBED_WORKERS", "6")
+	os.Setenv("SWARM_INDEXER_UPSERT_WORKERS", "3")
 	os.Setenv("SWARM_INDEXER_BATCH_SIZE", "200")
 	os.Setenv("SWARM_INDEXER_SKIP_FILES", "*.txt,*.log")
 	defer func() {
@@ -69,6 +76,8 @@ func TestLoadConfig_CustomValues(t *testing.T) {
 		os.Unsetenv("GEMINI_MODEL")
 		os.Unsetenv("GEMINI_RATE_LIMIT")
 		os.Unsetenv("SWARM_INDEXER_WORKERS")
+		os.Unsetenv("SWARM_INDEXER_EMBED_WORKERS")
+		os.Unsetenv("SWARM_INDEXER_UPSERT_WORKERS")
 		os.Unsetenv("SWARM_INDEXER_BATCH_SIZE")
 		os.Unsetenv("SWARM_INDEXER_SKIP_FILES")
 	}()
@@ -99,6 +108,9 @@ func TestLoadConfig_CustomValues(t *testing.T) {
 	if cfg.Workers != 16 {
 		t.Errorf("expected Workers to be 16, got %d", cfg.Workers)
 	}
+	if cfg.EmbedWorkers != 6 || cfg.UpsertWorkers != 3 {
+		t.Errorf("expected EmbedWorkers 6 and UpsertWorkers 3, got %d and %d", cfg.EmbedWorkers, cfg.UpsertWorkers)
+	}
 	if cfg.BatchSize != 200 {
 		t.Errorf("expected BatchSize to be 200, got %d", cfg.BatchSize)
 	}
@@ -107,6 +119,25 @@ func TestLoadConfig_CustomValues(t *testing.T) {
 	}
 }
 
+func TestLoad_Languages(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("SWARM_INDEXER_LANGUAGES", ".gotmpl=go,JSONL=json")
+
+	cfg, err := LoadLocal()
+	if err != nil {
+		t.Fatalf("LoadLocal failed: %v", err)
+	}
+	want := map[string]string{".gotmpl": "go", ".jsonl": "json"}
+	if !reflect.DeepEqual(cfg.Languages, want) {
+		t.Errorf("expected languages %v, got %v", want, cfg.Languages)
+	}
+
+	t.Setenv("SWARM_INDEXER_LANGUAGES", "gotmpl")
+	if _, err := LoadLocal(); err == nil || !strings.Contains(err.Error(), "SWARM_INDEXER_LANGUAGES") {
+		t.Errorf("expected an error naming SWARM_INDEXER_LANGUAGES, got %v", err)
+	}
+}
+
 func TestLoadConfig_MissingTypesenseAPIKey(t *testing.T) {
 	// Unset required variables
 	os.Unsetenv("TYPESENSE_API_KEY")
@@ -130,3 +161,67 @@ func TestLoadConfig_MissingGeminiAPIKey(t *testing.T) {
 		t.Fatal("expected error when GEMINI_API_KEY is missing")
 	}
 }
+
+func TestDir_Override(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", "/tmp/custom-config")
+
+	dir, err := Dir()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if dir != "/tmp/custom-config" {
+		t.Errorf("expected dir to be '/tmp/custom-config', got '%s'", dir)
+	}
+}
+
+func TestDir_XDGConfigHome(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", "")
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+
+	dir, err := Dir()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if dir != "/tmp/xdg/swarm-indexer" {
+		t.Errorf("expected dir to be '/tmp/xdg/swarm-indexer', got '%s'", dir)
+	}
+}
+
+func TestDataDir_Override(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_DATA_DIR", "/tmp/custom-data")
+
+	dir, err := DataDir()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if dir != "/tmp/custom-data" {
+		t.Errorf("expected dir to be '/tmp/custom-data', got '%s'", dir)
+	}
+}
+
+func TestDataDir_XDGDataHome(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_DATA_DIR", "")
+	t.Setenv("XDG_DATA_HOME", "/tmp/xdg-data")
+
+	dir, err := DataDir()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if dir != "/tmp/xdg-data/swarm-indexer" {
+		t.Errorf("expected dir to be '/tmp/xdg-data/swarm-indexer', got '%s'", dir)
+	}
+}
+
+func TestDataDir_HomeFallback(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_DATA_DIR", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("HOME", "/tmp/home")
+
+	dir, err := DataDir()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if dir != "/tmp/home/.local/share/swarm-indexer" {
+		t.Errorf("expected dir under ~/.local/share, got '%s'", dir)
+	}
+}
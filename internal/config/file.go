@@ -0,0 +1,553 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the config file in the config dir.
+const FileName = "config.yaml"
+
+// Config file sections besides top-level settings
+const (
+	profilesKey = "profiles" // named profiles
+	secretsKey  = "secrets"  // secret detection, see Secrets
+	hooksKey    = "hooks"    // pipeline hooks, see Hook
+)
+
+// Sources a resolved value can come from
+const (
+	SourceFlag    = "flag"
+	SourceProfile = "profile"
+	SourceEnv     = "env"
+	SourceEnvFile = "env-file" // secret read from the file named by <ENV>_FILE
+	SourceFile    = "file"
+	SourceDefault = "default"
+)
+
+// Redaction styles
+const (
+	RedactionMarker = "marker" // [REDACTED:<rule>] in place of the secret
+	RedactionPadded = "padded" // the marker padded to the secret's length
+)
+
+// When the webhook is notified of a run
+const (
+	WebhookAlways  = "always"
+	WebhookFailure = "failure" // only runs that failed, partly or wholly
+)
+
+// Setting describes one configuration value: its config file key, the
+// environment variable that overrides it, the command-line flag that
+// overrides both and its default.
+type Setting struct {
+	Key      string
+	Env      string
+	Flag     string // empty for secrets, which shouldn't end up in shell history
+	Default  string
+	Secret   bool     // masked when displayed
+	Int      bool     // must be a positive integer
+	Zero     bool     // Int settings may also be 0
+	Float    bool     // must be a positive number
+	Duration bool     // must be a positive duration such as 30s
+	Choices  []string // the only values allowed, if set
+	Required bool
+}
+
+// Settings lists every configuration value in display order.
+var Settings = []Setting{
+	{Key: "typesense_url", Env: "TYPESENSE_URL", Flag: "typesense-url", Default: "http://localhost:8108"},
+	{Key: "typesense_api_key", Env: "TYPESENSE_API_KEY", Secret: true, Required: true},
+	{Key: "typesense_collection", Env: "TYPESENSE_COLLECTION", Flag: "collection", Default: "swarm-index"},
+	{Key: "typesense_timeout", Env: "TYPESENSE_TIMEOUT", Flag: "typesense-timeout", Default: "60s", Duration: true},
+	{Key: "gemini_api_key", Env: "GEMINI_API_KEY", Secret: true, Required: true},
+	{Key: "gemini_model", Env: "GEMINI_MODEL", Flag: "gemini-model", Default: "gemini-embedding-001"},
+	{Key: "gemini_rate_limit", Env: "GEMINI_RATE_LIMIT", Flag: "gemini-rate-limit", Default: "60", Int: true},
+	{Key: "gemini_timeout", Env: "GEMINI_TIMEOUT", Flag: "gemini-timeout", Default: "30s", Duration: true},
+	{Key: "summary_model", Env: "GEMINI_SUMMARY_MODEL", Flag: "summary-model"}, // empty disables summaries
+	{Key: "proxy", Env: "SWARM_INDEXER_PROXY", Flag: "proxy"},
+	{Key: "otlp_endpoint", Env: "OTEL_EXPORTER_OTLP_ENDPOINT", Flag: "otlp-endpoint"}, // empty disables tracing
+	{Key: "webhook_url", Env: "SWARM_INDEXER_WEBHOOK_URL", Secret: true},
+	{Key: "webhook_on", Env: "SWARM_INDEXER_WEBHOOK_ON", Flag: "webhook-on", Default: WebhookAlways, Choices: []string{WebhookAlways, WebhookFailure}},
+	{Key: "queue_url", Env: "SWARM_INDEXER_QUEUE_URL", Secret: true},
+	{Key: "content_key", Env: "SWARM_INDEXER_CONTENT_KEY", Secret: true}, // empty stores content in plain text
+	{Key: "workers", Env: "SWARM_INDEXER_WORKERS", Flag: "workers", Default: "8", Int: true},
+	{Key: "embed_workers", Env: "SWARM_INDEXER_EMBED_WORKERS", Flag: "embed-workers", Default: "4", Int: true},
+	{Key: "upsert_workers", Env: "SWARM_INDEXER_UPSERT_WORKERS", Flag: "upsert-workers", Default: "2", Int: true},
+	{Key: "batch_size", Env: "SWARM_INDEXER_BATCH_SIZE", Flag: "batch-size", Default: "100", Int: true},
+	{Key: "max_queued_batches", Env: "SWARM_INDEXER_MAX_QUEUED_BATCHES", Flag: "max-queued-batches", Default: "8", Int: true},
+	{Key: "max_queued_jobs", Env: "SWARM_INDEXER_MAX_QUEUED_JOBS", Flag: "max-queued-jobs", Default: "1000", Int: true},
+	{Key: "max_embed_tokens", Env: "SWARM_INDEXER_MAX_EMBED_TOKENS", Flag: "max-embed-tokens", Int: true}, // empty means no budget
+	{Key: "max_file_size", Env: "SWARM_INDEXER_MAX_FILE_SIZE", Flag: "max-file-size", Int: true},          // bytes; empty means no limit
+	{Key: "chunk_size", Env: "SWARM_INDEXER_CHUNK_SIZE", Flag: "chunk-size", Default: "4000", Int: true},  // characters
+	{Key: "reindex_after", Env: "SWARM_INDEXER_REINDEX_AFTER", Flag: "reindex-after", Duration: true},     // empty never reindexes by age
+	{Key: "delete_grace", Env: "SWARM_INDEXER_DELETE_GRACE", Flag: "delete-grace", Duration: true},        // empty deletes removed files' documents at once
+	{Key: "skip_files", Env: "SWARM_INDEXER_SKIP_FILES", Flag: "skip-files", Default: ".env,.setenv,*.pem,*.key,credentials.*"},
+	{Key: "skip_generated", Env: "SWARM_INDEXER_SKIP_GENERATED", Flag: "skip-generated", Default: "true", Choices: []string{"true", "false"}},
+	{Key: "languages", Env: "SWARM_INDEXER_LANGUAGES", Flag: "languages"},
+	{Key: "entropy_enabled", Env: "SWARM_INDEXER_ENTROPY_ENABLED", Flag: "entropy-enabled", Default: "false", Choices: []string{"true", "false"}},
+	{Key: "entropy_threshold", Env: "SWARM_INDEXER_ENTROPY_THRESHOLD", Flag: "entropy-threshold", Float: true}, // default depends on the charset
+	{Key: "entropy_min_length", Env: "SWARM_INDEXER_ENTROPY_MIN_LENGTH", Flag: "entropy-min-length", Default: "20", Int: true},
+	{Key: "entropy_charset", Env: "SWARM_INDEXER_ENTROPY_CHARSET", Flag: "entropy-charset", Default: "base64", Choices: []string{"base64", "hex", "alphanumeric"}},
+	{Key: "redaction", Env: "SWARM_INDEXER_REDACTION", Flag: "redaction", Default: RedactionMarker, Choices: []string{RedactionMarker, RedactionPadded}},
+	{Key: "search_num_typos", Env: "SWARM_INDEXER_SEARCH_NUM_TYPOS", Flag: "num-typos", Default: "2", Choices: []string{"0", "1", "2"}},
+	{Key: "search_prefix", Env: "SWARM_INDEXER_SEARCH_PREFIX", Flag: "prefix", Default: "true", Choices: []string{"true", "false"}},
+	{Key: "search_drop_tokens_threshold", Env: "SWARM_INDEXER_SEARCH_DROP_TOKENS_THRESHOLD", Flag: "drop-tokens-threshold", Default: "1", Int: true, Zero: true},
+}
+
+// Lookup returns the setting with the given config file key or
+// environment variable name.
+func Lookup(name string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == name || s.Env == name {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Value is a resolved setting and where its value came from. Source is
+// empty when an unset setting has no default.
+type Value struct {
+	Setting
+	Value  string
+	Source string
+}
+
+// Display returns the value for showing to the user, masking secrets.
+func (v Value) Display() string {
+	if v.Secret {
+		return Mask(v.Value)
+	}
+	return v.Value
+}
+
+// Mask hides all but the last four characters of a secret.
+func Mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
+
+// flagOverrides holds values given on the command line, by config key
+var flagOverrides map[string]string
+
+// SetFlagOverrides sets values given as command-line flags, keyed by
+// config key. They take precedence over everything else in Resolve and
+// Load. Passing nil clears them.
+func SetFlagOverrides(values map[string]string) {
+	flagOverrides = values
+}
+
+// profileOverride is the profile selected on the command line
+var profileOverride string
+
+// UseProfile selects a profile from the config file for Resolve and Load,
+// taking precedence over SWARM_INDEXER_PROFILE. An empty name clears it.
+func UseProfile(name string) {
+	profileOverride = name
+}
+
+// ActiveProfile returns the selected profile: the one set by UseProfile,
+// else SWARM_INDEXER_PROFILE. Empty means no profile.
+func ActiveProfile() string {
+	if profileOverride != "" {
+		return profileOverride
+	}
+	return os.Getenv("SWARM_INDEXER_PROFILE")
+}
+
+// Resolve returns every setting's effective value. Flags take precedence
+// over the active profile, then environment variables, then the config
+// file's top-level values, then defaults.
+func Resolve() ([]Value, error) {
+	f, err := readConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return resolve(f)
+}
+
+// readConfigFile reads the config file in Dir
+func readConfigFile() (*fileContents, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return readFile(dir)
+}
+
+func resolve(f *fileContents) ([]Value, error) {
+	var profile map[string]string
+	if name := ActiveProfile(); name != "" {
+		var ok bool
+		if profile, ok = f.profiles[name]; !ok {
+			return nil, fmt.Errorf("unknown profile %q (known: %s)", name, strings.Join(sortedKeys(f.profiles), ", "))
+		}
+	}
+
+	values := make([]Value, len(Settings))
+	for i, s := range Settings {
+		v := Value{Setting: s}
+		if fv, ok := flagOverrides[s.Key]; ok {
+			v.Value, v.Source = fv, SourceFlag
+		} else if pv, ok := profile[s.Key]; ok {
+			v.Value, v.Source = pv, SourceProfile
+		} else if env := os.Getenv(s.Env); env != "" {
+			v.Value, v.Source = env, SourceEnv
+		} else if path := os.Getenv(s.FileEnv()); s.Secret && path != "" {
+			secret, err := readSecretFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", s.FileEnv(), err)
+			}
+			v.Value, v.Source = secret, SourceEnvFile
+		} else if fv, ok := f.values[s.Key]; ok {
+			v.Value, v.Source = fv, SourceFile
+		} else if s.Default != "" {
+			v.Value, v.Source = s.Default, SourceDefault
+		}
+		if s.Secret {
+			secret, err := resolveSecret(v.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", s.Key, err)
+			}
+			v.Value = secret
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// Validate checks resolved values, returning every problem found.
+func Validate(values []Value) error {
+	var errs []error
+	for _, v := range values {
+		switch {
+		case v.Required && v.Value == "":
+			errs = append(errs, fmt.Errorf("%s is required (set %s or run 'swarm-indexer config set %s <value>')", v.Key, v.Env, v.Key))
+		case v.Value == "":
+		default:
+			if err := validateValue(v.Setting, v.Value); err != nil {
+				errs = append(errs, fmt.Errorf("%s (from %s): %w", v.Key, v.Source, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateValue(s Setting, value string) error {
+	if s.Int && s.Zero {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%q is not a non-negative integer", value)
+		}
+	} else if s.Int {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%q is not a positive integer", value)
+		}
+	}
+	if s.Float {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f <= 0 {
+			return fmt.Errorf("%q is not a positive number", value)
+		}
+	}
+	if len(s.Choices) > 0 && !slices.Contains(s.Choices, value) {
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(s.Choices, ", "))
+	}
+	if s.Duration {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("%q is not a positive duration (e.g. 30s, 2m)", value)
+		}
+	}
+	if s.Key == "proxy" {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+			return fmt.Errorf("%q is not an http(s) or socks5 proxy URL", value)
+		}
+	}
+	if s.Key == "skip_files" {
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" && !doublestar.ValidatePattern(strings.TrimPrefix(p, "/")) {
+				return fmt.Errorf("%q is not a valid glob pattern", p)
+			}
+		}
+	}
+	if s.Key == "languages" {
+		if _, err := ParseLanguages(value); err != nil {
+			return err
+		}
+	}
+	if s.Key == "queue_url" && !strings.HasPrefix(value, KeychainPrefix) {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			return fmt.Errorf("%q is not a redis:// or rediss:// URL", value)
+		}
+	}
+	if s.Key == "content_key" && !strings.HasPrefix(value, KeychainPrefix) {
+		if key, err := base64.StdEncoding.DecodeString(value); err != nil || len(key) != 32 {
+			return errors.New("not a base64-encoded 32-byte key (e.g. openssl rand -base64 32)")
+		}
+	}
+	// A secret webhook URL may be a keychain reference
+	isURL := s.Key == "typesense_url" || s.Key == "otlp_endpoint" ||
+		(s.Key == "webhook_url" && !strings.HasPrefix(value, KeychainPrefix))
+	if isURL {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%q is not an http(s) URL", value)
+		}
+	}
+	return nil
+}
+
+// fileContents is the parsed config file: top-level values and named
+// profiles, each a map of config key to value, and the secrets and hooks
+// sections.
+type fileContents struct {
+	values   map[string]string
+	profiles map[string]map[string]string
+	secrets  *Secrets
+	hooks    []Hook
+}
+
+// LoadFile reads the top-level key/value pairs of the config file in dir.
+// Returns an empty map if the file doesn't exist.
+func LoadFile(dir string) (map[string]string, error) {
+	f, err := readFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	return f.values, nil
+}
+
+// LoadProfiles reads the named profiles of the config file in dir.
+func LoadProfiles(dir string) (map[string]map[string]string, error) {
+	f, err := readFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	return f.profiles, nil
+}
+
+func readFile(dir string) (*fileContents, error) {
+	f := &fileContents{values: map[string]string{}, profiles: map[string]map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return f, nil
+		}
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+
+	for key, v := range raw {
+		if key == secretsKey {
+			if f.secrets, err = parseSecrets(v); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", FileName, secretsKey, err)
+			}
+			continue
+		}
+		if key == hooksKey {
+			if f.hooks, err = parseHooks(v); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", FileName, hooksKey, err)
+			}
+			continue
+		}
+		if key == profilesKey {
+			profiles, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: %s must be a mapping of profile names to settings", FileName, profilesKey)
+			}
+			for name, pv := range profiles {
+				settings, ok := pv.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%s: profile %q must be a mapping of settings", FileName, name)
+				}
+				values, err := parseValues(settings)
+				if err != nil {
+					return nil, fmt.Errorf("%s: profile %q: %w", FileName, name, err)
+				}
+				f.profiles[name] = values
+			}
+			continue
+		}
+		values, err := parseValues(map[string]interface{}{key: v})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", FileName, err)
+		}
+		f.values[key] = values[key]
+	}
+	return f, nil
+}
+
+func parseValues(raw map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string, len(raw))
+	for key, v := range raw {
+		if _, ok := Lookup(key); !ok {
+			return nil, fmt.Errorf("unknown setting %q", key)
+		}
+		values[key] = fmt.Sprint(v)
+	}
+	return values, nil
+}
+
+// SaveFile writes top-level key/value pairs to the config file in dir
+// atomically, keeping any profiles and secrets section already in the file. The file may hold
+// API keys, so it is only readable by the owner.
+func SaveFile(dir string, values map[string]string) error {
+	f, err := readFile(dir)
+	if err != nil {
+		return err
+	}
+	f.values = values
+	return writeFile(dir, f)
+}
+
+func writeFile(dir string, f *fileContents) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	doc := valuesNode(f.values)
+	if len(f.profiles) > 0 {
+		profiles := &yaml.Node{Kind: yaml.MappingNode}
+		for _, name := range sortedKeys(f.profiles) {
+			profiles.Content = append(profiles.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+				valuesNode(f.profiles[name]),
+			)
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: profilesKey}, profiles)
+	}
+	if f.secrets != nil {
+		secrets := &yaml.Node{}
+		if err := secrets.Encode(f.secrets); err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", secretsKey, err)
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: secretsKey}, secrets)
+	}
+	if len(f.hooks) > 0 {
+		hooks := &yaml.Node{}
+		if err := hooks.Encode(f.hooks); err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", hooksKey, err)
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: hooksKey}, hooks)
+	}
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	path := filepath.Join(dir, FileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write temp config file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename config file: %w", err)
+	}
+	return nil
+}
+
+// valuesNode builds a YAML mapping with sorted keys, tagging numeric
+// settings so they aren't quoted.
+func valuesNode(values map[string]string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range sortedKeys(values) {
+		tag := "!!str"
+		switch s, _ := Lookup(k); {
+		case s.Int:
+			tag = "!!int"
+		case s.Float:
+			tag = "!!float"
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: k},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: values[k]},
+		)
+	}
+	return node
+}
+
+// Set validates and stores a value in the config file in dir. name may be
+// the config key or its environment variable; an empty value removes the
+// key from the file.
+func Set(dir, name, value string) error {
+	return SetInProfile(dir, "", name, value)
+}
+
+// SetInProfile is like Set but stores the value in the named profile,
+// creating it if needed. An empty profile means the top-level values.
+func SetInProfile(dir, profile, name, value string) error {
+	s, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown setting %q (known: %s)", name, strings.Join(keys(), ", "))
+	}
+
+	f, err := readFile(dir)
+	if err != nil {
+		return err
+	}
+	values := f.values
+	if profile != "" {
+		if f.profiles[profile] == nil {
+			f.profiles[profile] = map[string]string{}
+		}
+		values = f.profiles[profile]
+	}
+
+	if value == "" {
+		delete(values, s.Key)
+		if profile != "" && len(values) == 0 {
+			delete(f.profiles, profile)
+		}
+	} else {
+		if err := validateValue(s, value); err != nil {
+			return fmt.Errorf("%s: %w", s.Key, err)
+		}
+		values[s.Key] = value
+	}
+	return writeFile(dir, f)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func keys() []string {
+	out := make([]string, len(Settings))
+	for i, s := range Settings {
+		out[i] = s.Key
+	}
+	return out
+}
@@ -0,0 +1,446 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolve_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("TYPESENSE_COLLECTION", "from-env")
+	t.Setenv("GEMINI_MODEL", "")
+	t.Setenv("SWARM_INDEXER_WORKERS", "")
+
+	if err := SaveFile(dir, map[string]string{
+		"typesense_collection": "from-file",
+		"gemini_model":         "file-model",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := Resolve()
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	cases := map[string][2]string{
+		"typesense_collection": {"from-env", SourceEnv},
+		"gemini_model":         {"file-model", SourceFile},
+		"workers":              {"8", SourceDefault},
+	}
+	for key, want := range cases {
+		v := find(values, key)
+		if v.Value != want[0] || v.Source != want[1] {
+			t.Errorf("%s: expected %q from %s, got %q from %s", key, want[0], want[1], v.Value, v.Source)
+		}
+	}
+}
+
+func TestResolve_FlagOverrides(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_COLLECTION", "from-env")
+	SetFlagOverrides(map[string]string{"typesense_collection": "from-flag", "workers": "3"})
+	defer SetFlagOverrides(nil)
+
+	values, err := Resolve()
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if v := find(values, "typesense_collection"); v.Value != "from-flag" || v.Source != SourceFlag {
+		t.Errorf("expected flag to beat env, got %q from %s", v.Value, v.Source)
+	}
+	if v := find(values, "workers"); v.Value != "3" || v.Source != SourceFlag {
+		t.Errorf("expected workers from flag, got %q from %s", v.Value, v.Source)
+	}
+}
+
+func TestSettings_NoFlagsForSecrets(t *testing.T) {
+	for _, s := range Settings {
+		if s.Secret && s.Flag != "" {
+			t.Errorf("%s is a secret and must not have a flag", s.Key)
+		}
+	}
+}
+
+func TestLoad_FromFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("SWARM_INDEXER_BATCH_SIZE", "")
+
+	if err := SaveFile(dir, map[string]string{
+		"typesense_api_key": "ts-key",
+		"gemini_api_key":    "gm-key",
+		"batch_size":        "250",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.TypesenseAPIKey != "ts-key" || cfg.GeminiAPIKey != "gm-key" {
+		t.Errorf("expected API keys from file, got %q / %q", cfg.TypesenseAPIKey, cfg.GeminiAPIKey)
+	}
+	if cfg.BatchSize != 250 {
+		t.Errorf("expected batch size 250, got %d", cfg.BatchSize)
+	}
+}
+
+func TestSaveFile_WritesYAML(t *testing.T) {
+	dir := t.TempDir()
+	if err := SaveFile(dir, map[string]string{"workers": "4", "gemini_model": "m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "gemini_model: m\nworkers: 4\n" {
+		t.Errorf("unexpected file contents:\n%s", data)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected config file mode 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestLoadFile_UnknownKey(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("wokers: 4\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(dir); err == nil || !strings.Contains(err.Error(), "wokers") {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := Set(dir, "SWARM_INDEXER_WORKERS", "12"); err != nil {
+		t.Fatalf("Set by env name failed: %v", err)
+	}
+	if err := Set(dir, "workers", "zero"); err == nil {
+		t.Error("expected error for non-numeric workers")
+	}
+	if err := Set(dir, "typesense_url", "localhost"); err == nil {
+		t.Error("expected error for URL without scheme")
+	}
+	if err := Set(dir, "nope", "1"); err == nil {
+		t.Error("expected error for unknown setting")
+	}
+
+	values, err := LoadFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["workers"] != "12" {
+		t.Errorf("expected workers=12, got %v", values)
+	}
+
+	if err := Set(dir, "workers", ""); err != nil {
+		t.Fatalf("unset failed: %v", err)
+	}
+	values, _ = LoadFile(dir)
+	if _, ok := values["workers"]; ok {
+		t.Error("expected empty value to remove the key")
+	}
+}
+
+func TestResolve_Profile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("SWARM_INDEXER_PROFILE", "")
+	t.Setenv("TYPESENSE_COLLECTION", "from-env")
+	t.Setenv("TYPESENSE_API_KEY", "env-key")
+	t.Setenv("GEMINI_MODEL", "")
+
+	if err := Set(dir, "gemini_model", "top-model"); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{
+		"typesense_collection": "work-index",
+		"typesense_api_key":    "work-key",
+	} {
+		if err := SetInProfile(dir, "work", key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values, err := Resolve()
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if v := find(values, "typesense_collection"); v.Value != "from-env" {
+		t.Errorf("expected no profile without a selection, got %q from %s", v.Value, v.Source)
+	}
+
+	t.Setenv("SWARM_INDEXER_PROFILE", "work")
+	values, err = Resolve()
+	if err != nil {
+		t.Fatalf("Resolve with profile failed: %v", err)
+	}
+	cases := map[string][2]string{
+		"typesense_collection": {"work-index", SourceProfile},
+		"typesense_api_key":    {"work-key", SourceProfile},
+		"gemini_model":         {"top-model", SourceFile},
+	}
+	for key, want := range cases {
+		v := find(values, key)
+		if v.Value != want[0] || v.Source != want[1] {
+			t.Errorf("%s: expected %q from %s, got %q from %s", key, want[0], want[1], v.Value, v.Source)
+		}
+	}
+
+	UseProfile("personal")
+	defer UseProfile("")
+	if _, err := Resolve(); err == nil || !strings.Contains(err.Error(), `unknown profile "personal"`) {
+		t.Errorf("expected unknown profile error, got %v", err)
+	}
+}
+
+func TestSaveFile_KeepsProfiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := SetInProfile(dir, "work", "workers", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveFile(dir, map[string]string{"workers": "4"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "workers: 4\nprofiles:\n    work:\n        workers: 2\n" {
+		t.Errorf("unexpected file contents:\n%s", data)
+	}
+
+	if err := SetInProfile(dir, "work", "workers", ""); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := LoadProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 0 {
+		t.Errorf("expected emptied profile to be removed, got %v", profiles)
+	}
+}
+
+func TestLoadFile_UnknownProfileKey(t *testing.T) {
+	dir := t.TempDir()
+	data := "profiles:\n  work:\n    colection: x\n"
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(dir); err == nil || !strings.Contains(err.Error(), "colection") {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	values := []Value{
+		{Setting: Setting{Key: "typesense_api_key", Env: "TYPESENSE_API_KEY", Required: true}},
+		{Setting: Setting{Key: "workers", Int: true}, Value: "-1", Source: SourceEnv},
+		{Setting: Setting{Key: "batch_size", Int: true}, Value: "100", Source: SourceDefault},
+	}
+
+	err := Validate(values)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "typesense_api_key is required") || !strings.Contains(msg, "workers (from env)") {
+		t.Errorf("expected both problems reported, got:\n%s", msg)
+	}
+	if strings.Contains(msg, "batch_size") {
+		t.Errorf("valid value reported as a problem:\n%s", msg)
+	}
+}
+
+func TestSet_DurationAndProxy(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		key, value string
+		ok         bool
+	}{
+		{"gemini_timeout", "2m", true},
+		{"typesense_timeout", "30", false},
+		{"typesense_timeout", "-1s", false},
+		{"reindex_after", "168h", true},
+		{"reindex_after", "7d", false},
+		{"delete_grace", "72h", true},
+		{"delete_grace", "-1h", false},
+		{"proxy", "http://proxy.corp:3128", true},
+		{"proxy", "socks5://127.0.0.1:1080", true},
+		{"proxy", "proxy.corp:3128", false},
+		{"otlp_endpoint", "http://localhost:4318", true},
+		{"otlp_endpoint", "localhost:4318", false},
+		{"webhook_url", "https://hooks.slack.com/services/T0/B0/x", true},
+		{"webhook_url", "keychain:webhook_url", true},
+		{"webhook_url", "hooks.slack.com", false},
+		{"webhook_on", "failure", true},
+		{"queue_url", "redis://:pw@queue.internal:6379/0", true},
+		{"queue_url", "nats://queue.internal:4222", false},
+		{"content_key", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=", true},
+		{"content_key", "keychain:content_key", true},
+		{"content_key", "c2hvcnQ=", false},
+		{"chunk_size", "1500", true},
+		{"chunk_size", "0", false},
+		{"skip_generated", "false", true},
+		{"skip_generated", "no", false},
+		{"webhook_on", "never", false},
+	} {
+		err := Set(dir, tc.key, tc.value)
+		if (err == nil) != tc.ok {
+			t.Errorf("Set(%s, %q): got err %v, want ok=%v", tc.key, tc.value, err, tc.ok)
+		}
+	}
+}
+
+func TestSet_SecretSettings(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		key, value string
+		ok         bool
+	}{
+		{"entropy_enabled", "true", true},
+		{"entropy_enabled", "yes", false},
+		{"entropy_threshold", "3.5", true},
+		{"entropy_threshold", "0", false},
+		{"entropy_threshold", "high", false},
+		{"entropy_min_length", "32", true},
+		{"entropy_charset", "hex", true},
+		{"entropy_charset", "base32", false},
+		{"redaction", RedactionPadded, true},
+		{"redaction", "blank", false},
+		{"skip_files", ".env,**/secrets/*.yaml", true},
+		{"skip_files", "*.pem,[unclosed", false},
+		{"languages", ".gotmpl=go, jsonl=json", true},
+		{"languages", ".gotmpl", false},
+		{"languages", "=go", false},
+	} {
+		err := Set(dir, tc.key, tc.value)
+		if (err == nil) != tc.ok {
+			t.Errorf("Set(%s, %q): got err %v, want ok=%v", tc.key, tc.value, err, tc.ok)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "entropy_threshold: 3.5\n") {
+		t.Errorf("expected an unquoted float, got:\n%s", data)
+	}
+}
+
+func TestLoad_Entropy(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "ts-key")
+	t.Setenv("GEMINI_API_KEY", "gm-key")
+	t.Setenv("SWARM_INDEXER_ENTROPY_ENABLED", "")
+	t.Setenv("SWARM_INDEXER_ENTROPY_THRESHOLD", "")
+	t.Setenv("SWARM_INDEXER_ENTROPY_CHARSET", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.EntropyEnabled || cfg.EntropyThreshold != 0 || cfg.EntropyMinLength != 20 || cfg.EntropyCharset != "base64" {
+		t.Errorf("unexpected entropy defaults %v / %v / %d / %q", cfg.EntropyEnabled, cfg.EntropyThreshold, cfg.EntropyMinLength, cfg.EntropyCharset)
+	}
+
+	t.Setenv("SWARM_INDEXER_ENTROPY_ENABLED", "true")
+	t.Setenv("SWARM_INDEXER_ENTROPY_THRESHOLD", "3.2")
+	t.Setenv("SWARM_INDEXER_ENTROPY_CHARSET", "hex")
+	if cfg, err = Load(); err != nil || !cfg.EntropyEnabled || cfg.EntropyThreshold != 3.2 || cfg.EntropyCharset != "hex" {
+		t.Errorf("unexpected entropy settings %+v (err %v)", cfg, err)
+	}
+
+	t.Setenv("SWARM_INDEXER_ENTROPY_CHARSET", "base32")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "SWARM_INDEXER_ENTROPY_CHARSET") {
+		t.Errorf("expected invalid charset error, got %v", err)
+	}
+}
+
+func TestLoad_Timeouts(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "ts-key")
+	t.Setenv("GEMINI_API_KEY", "gm-key")
+	t.Setenv("TYPESENSE_TIMEOUT", "")
+	t.Setenv("GEMINI_TIMEOUT", "90s")
+	t.Setenv("SWARM_INDEXER_PROXY", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.TypesenseTimeout != 60*time.Second || cfg.GeminiTimeout != 90*time.Second {
+		t.Errorf("unexpected timeouts %v / %v", cfg.TypesenseTimeout, cfg.GeminiTimeout)
+	}
+
+	t.Setenv("SWARM_INDEXER_PROXY", "not a proxy")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "SWARM_INDEXER_PROXY") {
+		t.Errorf("expected invalid proxy error, got %v", err)
+	}
+}
+
+func TestLoad_SearchMatching(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", t.TempDir())
+	t.Setenv("TYPESENSE_API_KEY", "ts-key")
+	t.Setenv("GEMINI_API_KEY", "gm-key")
+	t.Setenv("SWARM_INDEXER_SEARCH_NUM_TYPOS", "")
+	t.Setenv("SWARM_INDEXER_SEARCH_PREFIX", "")
+	t.Setenv("SWARM_INDEXER_SEARCH_DROP_TOKENS_THRESHOLD", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.NumTypos != 2 || !cfg.Prefix || cfg.DropTokensThreshold != 1 {
+		t.Errorf("expected Typesense's defaults, got %d / %v / %d", cfg.NumTypos, cfg.Prefix, cfg.DropTokensThreshold)
+	}
+
+	t.Setenv("SWARM_INDEXER_SEARCH_NUM_TYPOS", "0")
+	t.Setenv("SWARM_INDEXER_SEARCH_PREFIX", "false")
+	t.Setenv("SWARM_INDEXER_SEARCH_DROP_TOKENS_THRESHOLD", "0")
+	if cfg, err = Load(); err != nil || cfg.NumTypos != 0 || cfg.Prefix || cfg.DropTokensThreshold != 0 {
+		t.Errorf("unexpected matching settings %+v (err %v)", cfg, err)
+	}
+
+	for _, c := range []struct{ env, bad, good string }{
+		{"SWARM_INDEXER_SEARCH_NUM_TYPOS", "3", "0"},
+		{"SWARM_INDEXER_SEARCH_PREFIX", "yes", "false"},
+		{"SWARM_INDEXER_SEARCH_DROP_TOKENS_THRESHOLD", "-1", "0"},
+	} {
+		t.Setenv(c.env, c.bad)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), c.env) {
+			t.Errorf("expected invalid %s error, got %v", c.env, err)
+		}
+		t.Setenv(c.env, c.good)
+	}
+}
+
+func TestMask(t *testing.T) {
+	cases := map[string]string{"": "", "short": "****", "abcdefghijkl": "****ijkl"}
+	for in, want := range cases {
+		if got := Mask(in); got != want {
+			t.Errorf("Mask(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// Pipeline stages a hook can run at
+const (
+	HookPreChunk  = "pre-chunk"  // a file's content, before it is chunked
+	HookPostChunk = "post-chunk" // a file's chunks
+	HookPreUpsert = "pre-upsert" // a batch of chunks, before it is embedded and upserted
+)
+
+// DefaultHookTimeout bounds a hook run whose timeout isn't set.
+const DefaultHookTimeout = 30 * time.Second
+
+// Hook is an external command from the hooks section of the config file
+// that transforms or vetoes what the pipeline indexes at a stage. It reads
+// a JSON request on stdin and writes its JSON response to stdout.
+type Hook struct {
+	ID      string   `yaml:"id,omitempty"` // names the hook; default its program's name
+	Stage   string   `yaml:"stage"`
+	Command []string `yaml:"command"` // program and arguments, run without a shell
+	// Projects are globs of the project paths the hook applies to, e.g.
+	// /home/me/work/**; empty means every project
+	Projects []string `yaml:"projects,omitempty"`
+	Timeout  string   `yaml:"timeout,omitempty"` // per run; default DefaultHookTimeout
+}
+
+// Name identifies the hook in logs and skip reasons: its ID, else its
+// program's name.
+func (h Hook) Name() string {
+	if h.ID != "" {
+		return h.ID
+	}
+	name := h.Command[0]
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// Matches reports whether the hook applies to the project at path.
+func (h Hook) Matches(path string) bool {
+	if len(h.Projects) == 0 {
+		return true
+	}
+	for _, p := range h.Projects {
+		if ok, _ := doublestar.Match(p, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeoutDuration returns how long a run of the hook may take.
+func (h Hook) TimeoutDuration() time.Duration {
+	if d, err := time.ParseDuration(h.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultHookTimeout
+}
+
+// LoadHooks reads the hooks section of the config file in dir.
+func LoadHooks(dir string) ([]Hook, error) {
+	f, err := readFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	return f.hooks, nil
+}
+
+// parseHooks decodes and validates the hooks section, rejecting unknown
+// fields so that typos don't silently disable a hook.
+func parseHooks(raw interface{}) ([]Hook, error) {
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var hooks []Hook
+	if err := dec.Decode(&hooks); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for i, h := range hooks {
+		if err := h.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("hook %d: %w", i+1, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (h Hook) validate() error {
+	switch h.Stage {
+	case HookPreChunk, HookPostChunk, HookPreUpsert:
+	case "":
+		return errors.New("stage is required")
+	default:
+		return fmt.Errorf("unknown stage %q (use %s, %s or %s)", h.Stage, HookPreChunk, HookPostChunk, HookPreUpsert)
+	}
+	if len(h.Command) == 0 || h.Command[0] == "" {
+		return errors.New("command is required")
+	}
+	for _, p := range h.Projects {
+		if !doublestar.ValidatePattern(p) {
+			return fmt.Errorf("%q is not a valid glob pattern", p)
+		}
+	}
+	if h.Timeout != "" {
+		if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("timeout %q is not a positive duration (e.g. 30s, 2m)", h.Timeout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const hooksYAML = `workers: 4
+hooks:
+  - stage: pre-chunk
+    command: [/usr/local/bin/scrub-pii, --strict]
+    projects: ["/home/me/work/**"]
+    timeout: 5s
+  - id: license-filter
+    stage: pre-upsert
+    command: [sh, -c, "license-filter --deny gpl"]
+`
+
+func TestLoadHooks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(hooksYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hooks, err := LoadHooks(dir)
+	if err != nil {
+		t.Fatalf("LoadHooks failed: %v", err)
+	}
+	if len(hooks) != 2 {
+		t.Fatalf("expected 2 hooks, got %+v", hooks)
+	}
+	h := hooks[0]
+	if h.Stage != HookPreChunk || h.Name() != "scrub-pii" || h.TimeoutDuration() != 5*time.Second {
+		t.Errorf("unexpected hook %+v", h)
+	}
+	if !h.Matches("/home/me/work/api") || h.Matches("/home/me/oss/lib") {
+		t.Error("expected the hook to apply to work projects only")
+	}
+	if hooks[1].Name() != "license-filter" {
+		t.Errorf("expected the hook's ID as its name, got %q", hooks[1].Name())
+	}
+	if !hooks[1].Matches("https://github.com/org/repo") || hooks[1].TimeoutDuration() != DefaultHookTimeout {
+		t.Errorf("expected a hook without projects to apply everywhere with the default timeout, got %+v", hooks[1])
+	}
+
+	// Setting a value keeps the section
+	if err := Set(dir, "workers", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if hooks, err = LoadHooks(dir); err != nil || len(hooks) != 2 {
+		t.Errorf("hooks section not preserved: %+v, %v", hooks, err)
+	}
+}
+
+func TestLoadHooks_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"missing stage":   "hooks:\n  - command: [x]\n",
+		"unknown stage":   "hooks:\n  - stage: post-upsert\n    command: [x]\n",
+		"missing command": "hooks:\n  - stage: pre-chunk\n",
+		"bad timeout":     "hooks:\n  - stage: pre-chunk\n    command: [x]\n    timeout: 5\n",
+		"bad pattern":     "hooks:\n  - stage: pre-chunk\n    command: [x]\n    projects: ['[']\n",
+		"unknown field":   "hooks:\n  - stage: pre-chunk\n    cmd: [x]\n",
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadHooks(dir); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const (
+	// KeychainPrefix marks a secret value as a reference to an entry in the
+	// OS keychain, e.g. "keychain:gemini_api_key".
+	KeychainPrefix = "keychain:"
+
+	// KeychainService is the service name keychain entries are stored under.
+	KeychainService = "swarm-indexer"
+
+	// fileEnvSuffix is appended to a secret's env var to name a variable
+	// holding the path of a file with the secret, e.g. GEMINI_API_KEY_FILE.
+	fileEnvSuffix = "_FILE"
+)
+
+// Keychain stores secrets in the operating system's credential store.
+type Keychain interface {
+	Get(name string) (string, error)
+	Set(name, secret string) error
+}
+
+// DefaultKeychain is the keychain keychain: references are resolved with.
+// It shells out to security on macOS and secret-tool (libsecret) on Linux.
+var DefaultKeychain Keychain = osKeychain{}
+
+// FileEnv returns the variable naming a file that holds the setting's
+// value, or "" for settings that aren't secrets.
+func (s Setting) FileEnv() string {
+	if !s.Secret {
+		return ""
+	}
+	return s.Env + fileEnvSuffix
+}
+
+// readSecretFile reads a secret from path, dropping surrounding whitespace
+// such as the trailing newline editors add.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return secret, nil
+}
+
+// resolveSecret replaces a keychain: reference with the stored secret.
+// Other values are returned unchanged.
+func resolveSecret(value string) (string, error) {
+	name, ok := strings.CutPrefix(value, KeychainPrefix)
+	if !ok {
+		return value, nil
+	}
+	if name == "" {
+		return "", errors.New("keychain reference without an entry name")
+	}
+	secret, err := DefaultKeychain.Get(name)
+	if err != nil {
+		return "", fmt.Errorf("reading %q from keychain: %w", name, err)
+	}
+	return secret, nil
+}
+
+type osKeychain struct{}
+
+func (osKeychain) Get(name string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", KeychainService, "-a", name, "-w")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", KeychainService, "account", name)
+	default:
+		return "", fmt.Errorf("keychain not supported on %s", runtime.GOOS)
+	}
+	out, err := runKeychain(cmd)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimRight(out, "\r\n")
+	if secret == "" {
+		return "", errors.New("no such entry")
+	}
+	return secret, nil
+}
+
+func (osKeychain) Set(name, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		// -U updates an existing entry instead of failing. security only
+		// accepts the password as an argument.
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", KeychainService, "-a", name, "-w", secret)
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "store", "--label", KeychainService+" "+name, "service", KeychainService, "account", name)
+		cmd.Stdin = strings.NewReader(secret)
+	default:
+		return fmt.Errorf("keychain not supported on %s", runtime.GOOS)
+	}
+	_, err := runKeychain(cmd)
+	return err
+}
+
+func runKeychain(cmd *exec.Cmd) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", cmd.Args[0], msg)
+		}
+		return "", fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeKeychain map[string]string
+
+func (k fakeKeychain) Get(name string) (string, error) {
+	if secret, ok := k[name]; ok {
+		return secret, nil
+	}
+	return "", errors.New("no such entry")
+}
+
+func (k fakeKeychain) Set(name, secret string) error {
+	k[name] = secret
+	return nil
+}
+
+func useFakeKeychain(t *testing.T, k fakeKeychain) {
+	t.Helper()
+	orig := DefaultKeychain
+	DefaultKeychain = k
+	t.Cleanup(func() { DefaultKeychain = orig })
+}
+
+func TestResolve_SecretFromFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("GEMINI_API_KEY", "")
+	keyFile := filepath.Join(dir, "gemini.key")
+	if err := os.WriteFile(keyFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GEMINI_API_KEY_FILE", keyFile)
+
+	values, err := Resolve()
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if v := find(values, "gemini_api_key"); v.Value != "file-secret" || v.Source != SourceEnvFile {
+		t.Errorf("expected key from file, got %q from %s", v.Value, v.Source)
+	}
+
+	t.Setenv("GEMINI_API_KEY", "env-secret")
+	values, _ = Resolve()
+	if v := find(values, "gemini_api_key"); v.Value != "env-secret" {
+		t.Errorf("expected env var to beat the file, got %q", v.Value)
+	}
+
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("GEMINI_API_KEY_FILE", filepath.Join(dir, "missing.key"))
+	if _, err := Resolve(); err == nil || !strings.Contains(err.Error(), "GEMINI_API_KEY_FILE") {
+		t.Errorf("expected error naming the file variable, got %v", err)
+	}
+}
+
+func TestResolve_KeychainReference(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("TYPESENSE_API_KEY", "")
+	t.Setenv("TYPESENSE_API_KEY_FILE", "")
+	useFakeKeychain(t, fakeKeychain{"typesense_api_key": "from-keychain"})
+
+	if err := Set(dir, "typesense_api_key", KeychainPrefix+"typesense_api_key"); err != nil {
+		t.Fatal(err)
+	}
+	values, err := Resolve()
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if v := find(values, "typesense_api_key"); v.Value != "from-keychain" || v.Source != SourceFile {
+		t.Errorf("expected keychain secret, got %q from %s", v.Value, v.Source)
+	}
+
+	if err := Set(dir, "typesense_api_key", KeychainPrefix+"missing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve(); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected keychain lookup error, got %v", err)
+	}
+}
+
+func TestSetting_FileEnv(t *testing.T) {
+	s, _ := Lookup("gemini_api_key")
+	if s.FileEnv() != "GEMINI_API_KEY_FILE" {
+		t.Errorf("unexpected file env %q", s.FileEnv())
+	}
+	if s, _ := Lookup("workers"); s.FileEnv() != "" {
+		t.Error("expected no file env for non-secret settings")
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Actions a custom secret rule can take on a match
+const (
+	RuleActionRedact   = "redact"    // replace the secret in the indexed content
+	RuleActionSkipFile = "skip-file" // leave the whole file out of the index
+)
+
+// Secrets is the secrets section of the config file.
+type Secrets struct {
+	Rules []SecretRule `yaml:"rules,omitempty"`
+}
+
+// SecretRule is a user-defined secret detection rule, merged with the
+// built-in rules. A rule with the ID of a built-in rule replaces it.
+type SecretRule struct {
+	ID          string   `yaml:"id"`
+	Description string   `yaml:"description,omitempty"`
+	Regex       string   `yaml:"regex"`
+	SecretGroup int      `yaml:"secret_group,omitempty"` // capture group holding the secret; 0 is the whole match
+	Keywords    []string `yaml:"keywords,omitempty"`     // only run the regex on content containing one of these
+	Entropy     float64  `yaml:"entropy,omitempty"`      // minimum Shannon entropy of a match
+	Action      string   `yaml:"action,omitempty"`       // RuleActionRedact (default) or RuleActionSkipFile
+	Replacement string   `yaml:"replacement,omitempty"`  // text replacing the secret; default [REDACTED:<id>]
+}
+
+// LoadSecrets reads the secrets section of the config file in dir. Returns
+// an empty section if there is none.
+func LoadSecrets(dir string) (*Secrets, error) {
+	f, err := readFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if f.secrets == nil {
+		return &Secrets{}, nil
+	}
+	return f.secrets, nil
+}
+
+// parseSecrets decodes and validates the secrets section, rejecting
+// unknown fields so that typos don't silently disable a rule.
+func parseSecrets(raw interface{}) (*Secrets, error) {
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var s Secrets
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for i, r := range s.Rules {
+		if r.ID == "" {
+			errs = append(errs, fmt.Errorf("rule %d: id is required", i+1))
+			continue
+		}
+		if seen[r.ID] {
+			errs = append(errs, fmt.Errorf("rule %q: duplicate id", r.ID))
+		}
+		seen[r.ID] = true
+		if err := r.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: %w", r.ID, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r SecretRule) validate() error {
+	if r.Regex == "" {
+		return errors.New("regex is required")
+	}
+	re, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	if r.SecretGroup < 0 || r.SecretGroup > re.NumSubexp() {
+		return fmt.Errorf("secret_group %d out of range (regex has %d groups)", r.SecretGroup, re.NumSubexp())
+	}
+	if r.Entropy < 0 {
+		return fmt.Errorf("entropy %v is negative", r.Entropy)
+	}
+	switch r.Action {
+	case "", RuleActionRedact, RuleActionSkipFile:
+	default:
+		return fmt.Errorf("unknown action %q (use %s or %s)", r.Action, RuleActionRedact, RuleActionSkipFile)
+	}
+	return nil
+}
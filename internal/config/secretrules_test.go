@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const secretRulesYAML = `workers: 4
+secrets:
+  rules:
+    - id: ticket-token
+      description: Internal ticket system token
+      regex: '\bTKT-([A-Z0-9]{24})\b'
+      secret_group: 1
+      keywords: [tkt-]
+      replacement: "[TICKET]"
+    - id: customer-export
+      regex: 'CUST-\d{8}'
+      action: skip-file
+`
+
+func TestLoadSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(secretRulesYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadSecrets(dir)
+	if err != nil {
+		t.Fatalf("LoadSecrets failed: %v", err)
+	}
+	if len(s.Rules) != 2 {
+		t.Fatalf("expected 2 rules, got %+v", s.Rules)
+	}
+	r := s.Rules[0]
+	if r.ID != "ticket-token" || r.SecretGroup != 1 || r.Replacement != "[TICKET]" || len(r.Keywords) != 1 {
+		t.Errorf("unexpected rule %+v", r)
+	}
+	if s.Rules[1].Action != RuleActionSkipFile {
+		t.Errorf("unexpected action %q", s.Rules[1].Action)
+	}
+
+	// Top-level settings still load alongside the section
+	values, err := LoadFile(dir)
+	if err != nil || values["workers"] != "4" {
+		t.Errorf("unexpected values %v (err %v)", values, err)
+	}
+}
+
+func TestLoadSecrets_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"missing id":    "secrets:\n  rules:\n    - regex: 'x'\n",
+		"bad regex":     "secrets:\n  rules:\n    - id: a\n      regex: '(x'\n",
+		"bad group":     "secrets:\n  rules:\n    - id: a\n      regex: 'x'\n      secret_group: 1\n",
+		"bad action":    "secrets:\n  rules:\n    - id: a\n      regex: 'x'\n      action: delete\n",
+		"duplicate id":  "secrets:\n  rules:\n    - id: a\n      regex: 'x'\n    - id: a\n      regex: 'y'\n",
+		"unknown field": "secrets:\n  rules:\n    - id: a\n      regexp: 'x'\n",
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadSecrets(dir); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSaveFile_KeepsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(secretRulesYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(dir, "workers", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := LoadSecrets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Rules) != 2 || s.Rules[0].Regex != `\bTKT-([A-Z0-9]{24})\b` {
+		t.Errorf("secrets section not preserved: %+v", s.Rules)
+	}
+}
+
+func TestLoad_SecretRules(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_CONFIG_DIR", dir)
+	t.Setenv("TYPESENSE_API_KEY", "ts-key")
+	t.Setenv("GEMINI_API_KEY", "gm-key")
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(secretRulesYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.SecretRules) != 2 || cfg.SecretRules[1].ID != "customer-export" {
+		t.Errorf("unexpected rules %+v", cfg.SecretRules)
+	}
+
+	data := strings.Replace(secretRulesYAML, "action: skip-file", "action: nuke", 1)
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "customer-export") {
+		t.Errorf("expected invalid rule error, got %v", err)
+	}
+}
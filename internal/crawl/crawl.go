@@ -0,0 +1,160 @@
+// Package crawl fetches the pages of a documentation site, following links
+// within it up to a depth, and converts them to markdown for indexing.
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// Default bounds of a crawl
+const (
+	DefaultDepth    = 2
+	DefaultMaxPages = 200
+)
+
+// maxPageSize bounds how much of a page is read; the rest is ignored
+const maxPageSize = 5 << 20
+
+// userAgent identifies the crawler to the sites it fetches
+const userAgent = "swarm-indexer (+https://github.com/dvaida/swarm-indexer)"
+
+// Options bound a crawl.
+type Options struct {
+	// Depth is how many links away from the start page pages are
+	// fetched; 0 fetches the start page only
+	Depth int
+	// MaxPages is the most pages fetched; zero means DefaultMaxPages
+	MaxPages int
+}
+
+// Page is a fetched page.
+type Page struct {
+	URL   string // after redirects, without a fragment
+	Title string
+	Text  string // the page converted to markdown
+	Depth int    // links away from the start page
+	// Err is why the page couldn't be fetched or read; the other fields
+	// but URL and Depth are empty when it is set
+	Err error
+}
+
+// Crawl fetches the page at start and, breadth first, the pages it links
+// to that are within its scope, up to opts.Depth links away. A page is in
+// scope if it is on the same host, under the directory of start's path:
+// crawling https://example.com/docs/guide/ stays in /docs/guide/. Pages
+// that aren't HTML and pages that don't exist are left out. The error is
+// only set for an invalid start URL or when ctx is done.
+func Crawl(ctx context.Context, client *http.Client, start string, opts Options) ([]Page, error) {
+	root, err := neturl.Parse(start)
+	if err != nil || (root.Scheme != "http" && root.Scheme != "https") || root.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: must be an http or https URL", start)
+	}
+	root.Fragment, root.RawFragment = "", ""
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = DefaultMaxPages
+	}
+	scope := Scope(root)
+
+	type target struct {
+		url   string
+		depth int
+	}
+	queue := []target{{url: root.String()}}
+	seen := map[string]bool{root.String(): true}
+	var pages []Page
+	for len(queue) > 0 && len(pages) < opts.MaxPages {
+		if err := ctx.Err(); err != nil {
+			return pages, err
+		}
+		t := queue[0]
+		queue = queue[1:]
+
+		page, links, err := fetch(ctx, client, t.url)
+		if ctx.Err() != nil {
+			return pages, ctx.Err()
+		}
+		if err != nil {
+			pages = append(pages, Page{URL: t.url, Depth: t.depth, Err: err})
+			continue
+		}
+		if page == nil {
+			continue
+		}
+		// Redirects may lead out of scope or to a page already fetched
+		if page.URL != t.url {
+			if seen[page.URL] || !strings.HasPrefix(page.URL, scope) {
+				continue
+			}
+			seen[page.URL] = true
+		}
+		page.Depth = t.depth
+		pages = append(pages, *page)
+
+		if t.depth == opts.Depth {
+			continue
+		}
+		for _, link := range links {
+			if !seen[link] && strings.HasPrefix(link, scope) {
+				seen[link] = true
+				queue = append(queue, target{url: link, depth: t.depth + 1})
+			}
+		}
+	}
+	return pages, nil
+}
+
+// Scope returns the URL prefix of the pages a crawl from u stays within:
+// u up to the last slash of its path, without its query.
+func Scope(u *neturl.URL) string {
+	s := *u
+	s.RawQuery, s.Fragment, s.RawFragment = "", "", ""
+	if i := strings.LastIndexByte(s.Path, '/'); i >= 0 {
+		s.Path = s.Path[:i+1]
+	} else {
+		s.Path = "/"
+	}
+	s.RawPath = ""
+	return s.String()
+}
+
+// fetch gets and converts the page at url. It returns no page and no
+// error for pages that aren't HTML and for 404 and 410 responses.
+func fetch(ctx context.Context, client *http.Client, url string) (*Page, []string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, nil, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, errors.New(resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, nil, nil
+	}
+
+	final := *resp.Request.URL
+	final.Fragment, final.RawFragment = "", ""
+	title, text, links, err := Markdown(io.LimitReader(resp.Body, maxPageSize), &final)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing page: %w", err)
+	}
+	return &Page{URL: final.String(), Title: title, Text: text}, links, nil
+}
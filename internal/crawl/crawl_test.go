@@ -0,0 +1,147 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testSite serves a small documentation site under /docs/
+func testSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	pages := map[string]string{
+		"/docs/":        `<title>Home</title><a href="guide">Guide</a> <a href="/docs/api#auth">API</a> <a href="/blog/">Blog</a> <a href="https://other.example.com/">Other</a> <a href="logo.png">Logo</a> <a href="missing">Missing</a> <a href="broken">Broken</a>`,
+		"/docs/guide":   `<title>Guide</title><p>Read the <a href="api">API</a> and <a href="deep">deep dive</a>.</p>`,
+		"/docs/api":     `<title>API</title><h1>API</h1><p>Authenticate first.</p>`,
+		"/docs/deep":    `<title>Deep</title><a href="deeper">Deeper</a>`,
+		"/docs/deeper":  `<title>Deeper</title>`,
+		"/blog/":        `<title>Blog</title>`,
+		"/docs/old-api": ``,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G'})
+			return
+		case "/docs/broken":
+			http.Error(w, "oops", http.StatusInternalServerError)
+			return
+		case "/docs/old-api":
+			http.Redirect(w, r, "/docs/api", http.StatusMovedPermanently)
+			return
+		}
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	}))
+}
+
+func urls(pages []Page) []string {
+	var out []string
+	for _, p := range pages {
+		out = append(out, p.URL)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestCrawl(t *testing.T) {
+	srv := testSite(t)
+	defer srv.Close()
+
+	pages, err := Crawl(context.Background(), srv.Client(), srv.URL+"/docs/", Options{Depth: 1})
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	want := []string{srv.URL + "/docs/", srv.URL + "/docs/api", srv.URL + "/docs/broken", srv.URL + "/docs/guide"}
+	if got := urls(pages); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected pages %v, got %v", want, got)
+	}
+	for _, p := range pages {
+		switch {
+		case strings.HasSuffix(p.URL, "/broken"):
+			if p.Err == nil {
+				t.Errorf("expected an error for %s", p.URL)
+			}
+		case p.Err != nil:
+			t.Errorf("unexpected error for %s: %v", p.URL, p.Err)
+		case strings.HasSuffix(p.URL, "/api"):
+			if p.Title != "API" || p.Depth != 1 || !strings.Contains(p.Text, "# API") {
+				t.Errorf("unexpected page %+v", p)
+			}
+		}
+	}
+}
+
+func TestCrawl_Depth(t *testing.T) {
+	srv := testSite(t)
+	defer srv.Close()
+
+	// deeper is two links away from the guide
+	pages, err := Crawl(context.Background(), srv.Client(), srv.URL+"/docs/guide", Options{Depth: 1})
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	want := []string{srv.URL + "/docs/api", srv.URL + "/docs/deep", srv.URL + "/docs/guide"}
+	if got := urls(pages); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected pages %v, got %v", want, got)
+	}
+}
+
+func TestCrawl_MaxPages(t *testing.T) {
+	srv := testSite(t)
+	defer srv.Close()
+
+	pages, err := Crawl(context.Background(), srv.Client(), srv.URL+"/docs/", Options{Depth: 3, MaxPages: 2})
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if len(pages) != 2 || pages[0].URL != srv.URL+"/docs/" {
+		t.Errorf("expected the start page and one more, got %v", urls(pages))
+	}
+}
+
+func TestCrawl_RedirectDeduplicated(t *testing.T) {
+	srv := testSite(t)
+	defer srv.Close()
+
+	pages, err := Crawl(context.Background(), srv.Client(), srv.URL+"/docs/old-api", Options{})
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if len(pages) != 1 || pages[0].URL != srv.URL+"/docs/api" {
+		t.Errorf("expected the redirect target, got %v", urls(pages))
+	}
+}
+
+func TestCrawl_InvalidURL(t *testing.T) {
+	for _, start := range []string{"ftp://example.com/", "/docs", "not a url"} {
+		if _, err := Crawl(context.Background(), http.DefaultClient, start, Options{}); err == nil {
+			t.Errorf("expected an error for %q", start)
+		}
+	}
+}
+
+func TestScope(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com":                  "https://example.com/",
+		"https://example.com/docs/":            "https://example.com/docs/",
+		"https://example.com/docs/guide?v=2#x": "https://example.com/docs/",
+	}
+	for in, want := range tests {
+		u, _ := neturl.Parse(in)
+		if got := Scope(u); got != want {
+			t.Errorf("Scope(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package crawl
+
+import (
+	"io"
+	neturl "net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skipped are elements whose content is never page text: scripts and
+// styles, and the navigation chrome repeated on every page of a site
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Iframe: true, atom.Form: true, atom.Button: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+}
+
+// blocks are elements that start and end a paragraph
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Main: true, atom.Blockquote: true, atom.Ul: true, atom.Ol: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Table: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Details: true,
+	atom.Summary: true, atom.Address: true, atom.Hr: true,
+}
+
+// headings maps heading elements to their level
+var headings = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// Markdown parses an HTML page and returns its title, its text as
+// markdown (headings as #, list items as -, preformatted blocks fenced)
+// and the absolute URLs of the links on it, resolved against base. Only
+// the page's <main> or <article> is converted when it has one; links are
+// collected from the whole page.
+func Markdown(r io.Reader, base *neturl.URL) (title, text string, links []string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var content *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Title:
+				if title == "" {
+					title = collapse(textContent(n))
+				}
+			case atom.Main, atom.Article:
+				if content == nil {
+					content = n
+				}
+			case atom.A:
+				if link := resolve(base, attr(n, "href")); link != "" {
+					links = append(links, link)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if content == nil {
+		content = doc
+	}
+
+	var w writer
+	w.node(content)
+	return title, strings.TrimSpace(w.sb.String()), links, nil
+}
+
+// writer writes the markdown of a node tree, collapsing whitespace the
+// way a browser renders it
+type writer struct {
+	sb       strings.Builder
+	newlines int  // line breaks owed before the next text
+	space    bool // a space is owed before the next text
+	marker   bool // a list marker was just written
+	lists    int  // depth of nested lists
+}
+
+// lineBreak ends the current line, with a blank line after it when n is 2
+func (w *writer) lineBreak(n int) {
+	if w.sb.Len() == 0 || w.marker {
+		return
+	}
+	w.newlines = max(w.newlines, n)
+	w.space = false
+}
+
+func (w *writer) write(s string) {
+	switch {
+	case w.newlines > 0:
+		w.sb.WriteString(strings.Repeat("\n", w.newlines))
+	case w.space && w.sb.Len() > 0 && !w.marker:
+		w.sb.WriteByte(' ')
+	}
+	w.newlines, w.space, w.marker = 0, false, false
+	w.sb.WriteString(s)
+}
+
+func (w *writer) text(s string) {
+	if s == "" {
+		return
+	}
+	text := collapse(s)
+	if text == "" || isSpace(s[0]) {
+		w.space = true
+	}
+	if text == "" {
+		return
+	}
+	w.write(text)
+	w.space = isSpace(s[len(s)-1])
+}
+
+func (w *writer) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+
+	a := n.DataAtom
+	switch {
+	case skipped[a]:
+	case headings[a] > 0:
+		if t := collapse(textContent(n)); t != "" {
+			w.lineBreak(2)
+			w.write(strings.Repeat("#", headings[a]) + " " + t)
+			w.lineBreak(2)
+		}
+	case a == atom.Pre:
+		w.lineBreak(2)
+		w.write("```" + codeLanguage(n) + "\n" + strings.Trim(textContent(n), "\n") + "\n```")
+		w.lineBreak(2)
+	case a == atom.Code:
+		if t := collapse(textContent(n)); t != "" {
+			w.write("`" + t + "`")
+		}
+	case a == atom.Br:
+		w.lineBreak(1)
+	case a == atom.Ul || a == atom.Ol:
+		w.lineBreak(w.blockBreak())
+		w.lists++
+		w.children(n)
+		w.lists--
+		w.lineBreak(w.blockBreak())
+	case a == atom.Li:
+		w.lineBreak(1)
+		w.write(strings.Repeat("  ", max(w.lists-1, 0)) + "- ")
+		w.marker = true
+		w.children(n)
+		w.marker = false
+		w.lineBreak(1)
+	case a == atom.Tr:
+		w.lineBreak(1)
+		w.children(n)
+		w.lineBreak(1)
+	case a == atom.Td || a == atom.Th:
+		if n.PrevSibling != nil {
+			w.write(" |")
+			w.space = true
+		}
+		w.children(n)
+	case blocks[a]:
+		w.lineBreak(w.blockBreak())
+		w.children(n)
+		w.lineBreak(w.blockBreak())
+	default:
+		w.children(n)
+	}
+}
+
+// blockBreak is the break around a block: a blank line, or a line break
+// within a list so its items stay together
+func (w *writer) blockBreak() int {
+	if w.lists > 0 {
+		return 1
+	}
+	return 2
+}
+
+func (w *writer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+// codeLanguage returns the language of a preformatted block from a
+// language-* or lang-* class on it or its <code>, as highlighters set
+func codeLanguage(pre *html.Node) string {
+	nodes := []*html.Node{pre}
+	if c := pre.FirstChild; c != nil && c.DataAtom == atom.Code {
+		nodes = append(nodes, c)
+	}
+	for _, n := range nodes {
+		for _, class := range strings.Fields(attr(n, "class")) {
+			for _, prefix := range []string{"language-", "lang-"} {
+				if lang, ok := strings.CutPrefix(class, prefix); ok {
+					return lang
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// textContent returns the text of a node and its descendants as is
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// resolve returns href as an absolute http(s) URL without its fragment,
+// or "" for other links such as mailto: and javascript:
+func resolve(base *neturl.URL, href string) string {
+	u, err := base.Parse(strings.TrimSpace(href))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
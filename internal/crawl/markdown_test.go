@@ -0,0 +1,71 @@
+package crawl
+
+import (
+	neturl "net/url"
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head><title> Install  guide </title><style>p { color: red }</style></head>
+<body>
+<nav><a href="/docs/">Docs</a> <a href="mailto:team@example.com">Mail</a></nav>
+<main>
+  <h1>Installing</h1>
+  <p>Run   the <code>install</code>
+     script &amp; wait.</p>
+  <h2 id="steps">Steps <a href="#steps">¶</a></h2>
+  <ul>
+    <li>Download it</li>
+    <li><p>Unpack it</p>
+      <ul><li>with tar</li></ul>
+    </li>
+  </ul>
+  <pre><code class="language-sh">tar xzf pkg.tgz
+./install
+</code></pre>
+  <table><tr><th>Flag</th><th>Meaning</th></tr><tr><td>-v</td><td>verbose</td></tr></table>
+  <script>track()</script>
+</main>
+<footer><a href="https://example.com/legal">Legal</a></footer>
+</body></html>`
+
+	base, _ := neturl.Parse("https://example.com/docs/install")
+	title, text, links, err := Markdown(strings.NewReader(page), base)
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	if title != "Install guide" {
+		t.Errorf("unexpected title %q", title)
+	}
+
+	want := "# Installing\n\n" +
+		"Run the `install` script & wait.\n\n" +
+		"## Steps ¶\n\n" +
+		"- Download it\n" +
+		"- Unpack it\n" +
+		"  - with tar\n\n" +
+		"```sh\ntar xzf pkg.tgz\n./install\n```\n\n" +
+		"Flag | Meaning\n" +
+		"-v | verbose"
+	if text != want {
+		t.Errorf("unexpected text:\n%s\n\nwant:\n%s", text, want)
+	}
+
+	wantLinks := []string{"https://example.com/docs/", "https://example.com/docs/install", "https://example.com/legal"}
+	if strings.Join(links, " ") != strings.Join(wantLinks, " ") {
+		t.Errorf("expected links %v, got %v", wantLinks, links)
+	}
+}
+
+func TestMarkdown_NoMain(t *testing.T) {
+	base, _ := neturl.Parse("https://example.com/")
+	_, text, _, err := Markdown(strings.NewReader(`<body><header>Site</header><div>One</div><div>Two<br>Three</div></body>`), base)
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	if text != "One\n\nTwo\nThree" {
+		t.Errorf("unexpected text %q", text)
+	}
+}
@@ -0,0 +1,251 @@
+package detector
+
+import (
+	"bufio"
+	"encoding/xml"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// requirementNameEnd finds where the name of a PEP 508 requirement ends
+var requirementNameEnd = regexp.MustCompile(`[\s\[=<>!~;@(]`)
+
+// parseRequirement splits a PEP 508 requirement such as
+// "requests[socks]>=2.31; python_version>'3.8'" into its name and version
+// specifier. Extras and environment markers are dropped.
+func parseRequirement(req string) (name, version string) {
+	req, _, _ = strings.Cut(req, ";")
+	req = strings.TrimSpace(req)
+	loc := requirementNameEnd.FindStringIndex(req)
+	if loc == nil {
+		return req, ""
+	}
+	name, rest := req[:loc[0]], req[loc[0]:]
+	if strings.HasPrefix(rest, "[") {
+		if _, after, ok := strings.Cut(rest, "]"); ok {
+			rest = after
+		}
+	}
+	rest = strings.Trim(strings.TrimSpace(rest), "()")
+	return name, strings.TrimSpace(rest)
+}
+
+// parseRequirementsTxtDependencies extracts dependencies from a
+// requirements.txt file. Options (-r, -e, --index-url) are skipped.
+func parseRequirementsTxtDependencies(requirementsPath string, deps map[string]string) {
+	file, err := os.Open(requirementsPath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), " #")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if name, version := parseRequirement(line); name != "" {
+			deps[name] = version
+		}
+	}
+}
+
+// scanTOML calls fn with the table, key and value of each key/value line
+// of a TOML file; multi-line arrays are joined into one value. It
+// understands just enough TOML to read dependency tables.
+func scanTOML(path string, fn func(table, key, value string)) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	var table, key, value string
+	inArray := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if inArray {
+			if !strings.HasPrefix(line, "#") {
+				value += " " + line
+			}
+			if strings.HasSuffix(line, "]") {
+				inArray = false
+				fn(table, key, value)
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Check for a table header
+		if strings.HasPrefix(line, "[") {
+			table = strings.Trim(line, "[] ")
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.Trim(strings.TrimSpace(k), `"'`), strings.TrimSpace(v)
+		if strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") {
+			inArray = true
+			continue
+		}
+		fn(table, key, value)
+	}
+}
+
+// tomlStringPattern matches a basic or literal TOML string
+var tomlStringPattern = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+
+// tomlStrings returns the strings in a TOML value, e.g. the elements of an
+// array
+func tomlStrings(value string) []string {
+	var strs []string
+	for _, m := range tomlStringPattern.FindAllStringSubmatch(value, -1) {
+		strs = append(strs, m[1]+m[2])
+	}
+	return strs
+}
+
+// tomlInlineVersionPattern matches the version of an inline table such as
+// { version = "1.0", features = ["derive"] }
+var tomlInlineVersionPattern = regexp.MustCompile(`\bversion\s*=\s*["']([^"']*)["']`)
+
+// tomlDependencyVersion returns the version of a dependency given either
+// as a string or as an inline table. It is empty for git and path
+// dependencies.
+func tomlDependencyVersion(value string) string {
+	if strings.HasPrefix(value, "{") {
+		if m := tomlInlineVersionPattern.FindStringSubmatch(value); m != nil {
+			return m[1]
+		}
+		return ""
+	}
+	if strs := tomlStrings(value); len(strs) > 0 {
+		return strs[0]
+	}
+	return ""
+}
+
+// poetryDependencyTable matches Poetry's dependency tables, including
+// dependency groups
+var poetryDependencyTable = regexp.MustCompile(`^tool\.poetry\.(dependencies|dev-dependencies|group\.[^.]+\.dependencies)$`)
+
+// parsePyprojectDependencies extracts dependencies from a pyproject.toml
+// file, from the PEP 621 [project] table and Poetry's tables
+func parsePyprojectDependencies(pyprojectPath string, deps map[string]string) {
+	scanTOML(pyprojectPath, func(table, key, value string) {
+		switch {
+		case table == "project" && key == "dependencies":
+			for _, req := range tomlStrings(value) {
+				if name, version := parseRequirement(req); name != "" {
+					deps[name] = version
+				}
+			}
+		case poetryDependencyTable.MatchString(table) && key != "python":
+			deps[key] = tomlDependencyVersion(value)
+		}
+	})
+}
+
+// cargoDependencyTables are the Cargo.toml tables listing dependencies
+var cargoDependencyTables = map[string]bool{
+	"dependencies":           true,
+	"dev-dependencies":       true,
+	"build-dependencies":     true,
+	"workspace.dependencies": true,
+}
+
+// parseCargoTomlDependencies extracts dependencies from a Cargo.toml file,
+// given inline ([dependencies] serde = "1.0") or as their own table
+// ([dependencies.serde] version = "1.0")
+func parseCargoTomlDependencies(cargoTomlPath string, deps map[string]string) {
+	scanTOML(cargoTomlPath, func(table, key, value string) {
+		if cargoDependencyTables[table] {
+			deps[key] = tomlDependencyVersion(value)
+			return
+		}
+		for t := range cargoDependencyTables {
+			if name, ok := strings.CutPrefix(table, t+"."); ok && key == "version" {
+				deps[name] = tomlDependencyVersion(value)
+				return
+			}
+		}
+	})
+}
+
+// pomPropertyPattern matches a property reference such as ${spring.version}
+var pomPropertyPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// parsePomXmlDependencies extracts dependencies from a pom.xml file as
+// groupId:artifactId. Versions referring to the pom's own properties are
+// resolved.
+func parsePomXmlDependencies(pomXmlPath string, deps map[string]string) {
+	data, err := os.ReadFile(pomXmlPath)
+	if err != nil {
+		return
+	}
+
+	var pom struct {
+		Properties struct {
+			Entries []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"properties"`
+		Dependencies []struct {
+			GroupID    string `xml:"groupId"`
+			ArtifactID string `xml:"artifactId"`
+			Version    string `xml:"version"`
+		} `xml:"dependencies>dependency"`
+	}
+
+	if err := xml.Unmarshal(data, &pom); err != nil {
+		return
+	}
+
+	props := map[string]string{}
+	for _, p := range pom.Properties.Entries {
+		props[p.XMLName.Local] = strings.TrimSpace(p.Value)
+	}
+	for _, d := range pom.Dependencies {
+		if d.ArtifactID == "" {
+			continue
+		}
+		version := pomPropertyPattern.ReplaceAllStringFunc(strings.TrimSpace(d.Version), func(ref string) string {
+			if v, ok := props[ref[2:len(ref)-1]]; ok {
+				return v
+			}
+			return ref
+		})
+		deps[strings.TrimSpace(d.GroupID)+":"+strings.TrimSpace(d.ArtifactID)] = version
+	}
+}
+
+// gradleDependencyPattern matches dependency declarations in string
+// notation, e.g. implementation 'com.google.guava:guava:33.0.0-jre'
+var gradleDependencyPattern = regexp.MustCompile(`^\s*\w+\s*\(?\s*["']([^"':\s]+):([^"':\s]+)(?::([^"'\s]+))?["']`)
+
+// parseGradleDependencies extracts dependencies from a build.gradle file as
+// group:name
+func parseGradleDependencies(buildGradlePath string, deps map[string]string) {
+	file, err := os.Open(buildGradlePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if m := gradleDependencyPattern.FindStringSubmatch(scanner.Text()); m != nil {
+			deps[m[1]+":"+m[2]] = m[3]
+		}
+	}
+}
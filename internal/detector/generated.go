@@ -0,0 +1,72 @@
+package detector
+
+import "strings"
+
+const (
+	// generatedHeaderLines is how many lines from the top are searched for
+	// a generated-code marker
+	generatedHeaderLines = 20
+	// minifiedMinLine and minifiedMinAverage are the longest line and the
+	// average line length from which a file counts as minified
+	minifiedMinLine    = 5000
+	minifiedMinAverage = 500
+)
+
+// generatedMarkers are phrases that, in a comment near the top of a file,
+// mark it as written by a tool, e.g. Go's "// Code generated by protoc-gen-go.
+// DO NOT EDIT." or "# @generated by Buck"
+var generatedMarkers = []string{"code generated", "do not edit", "@generated", "autogenerated", "auto-generated"}
+
+// commentPrefixes start the comment lines markers are looked for in
+var commentPrefixes = []string{"//", "#", "/*", "*", "<!--", "--", ";"}
+
+// DetectGenerated reports whether content looks written by a tool rather
+// than by hand, and why: "marker" for a generated-code comment near the
+// top, "source map" for a bundle referencing its source map, or
+// "minified" for minified code, whose lines run to thousands of characters.
+func DetectGenerated(content string) (string, bool) {
+	header := strings.SplitN(content, "\n", generatedHeaderLines+1)
+	for _, line := range header[:min(len(header), generatedHeaderLines)] {
+		line = strings.TrimSpace(line)
+		if !hasCommentPrefix(line) {
+			continue
+		}
+		lower := strings.ToLower(line)
+		for _, m := range generatedMarkers {
+			if strings.Contains(lower, m) {
+				return "marker", true
+			}
+		}
+	}
+
+	// Source maps are referenced from the last line of a bundle
+	tail := strings.TrimRight(content, "\n\r\t ")
+	if i := strings.LastIndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+	if strings.HasPrefix(tail, "//# sourceMappingURL=") || strings.HasPrefix(tail, "/*# sourceMappingURL=") {
+		return "source map", true
+	}
+
+	lines, longest, start := 0, 0, 0
+	for i := 0; i <= len(content); i++ {
+		if i == len(content) || content[i] == '\n' {
+			lines++
+			longest = max(longest, i-start)
+			start = i + 1
+		}
+	}
+	if longest >= minifiedMinLine && len(content)/lines >= minifiedMinAverage {
+		return "minified", true
+	}
+	return "", false
+}
+
+func hasCommentPrefix(line string) bool {
+	for _, p := range commentPrefixes {
+		if strings.HasPrefix(line, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package detector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectGenerated(t *testing.T) {
+	minified := "!function(){" + strings.Repeat("var a=1;", 1000) + "}();\n"
+	for _, tc := range []struct {
+		name, content, want string
+	}{
+		{"go marker", "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage api\n", "marker"},
+		{"python marker", "# -*- coding: utf-8 -*-\n# Generated by the protocol buffer compiler.  DO NOT EDIT!\nimport sys\n", "marker"},
+		{"block marker", "/**\n * @generated\n */\nexport const x = 1;\n", "marker"},
+		{"source map", "var a = 1;\nvar b = 2;\n//# sourceMappingURL=app.js.map\n", "source map"},
+		{"minified", minified, "minified"},
+		{"hand-written", "package main\n\n// Run does not edit files\nfunc Run() {}\n", ""},
+		{"marker outside comments", "package main\n\nconst help = \"Code generated by tools must not be edited\"\n", ""},
+		{"marker too far down", strings.Repeat("\n", 30) + "// Code generated. DO NOT EDIT.\n", ""},
+		{"long prose", strings.Repeat("A paragraph of prose on a single line. ", 100) + "\n\nAnother one.\n", ""},
+	} {
+		why, ok := DetectGenerated(tc.content)
+		if why != tc.want || ok != (tc.want != "") {
+			t.Errorf("%s: got %q, %v, want %q", tc.name, why, ok, tc.want)
+		}
+	}
+}
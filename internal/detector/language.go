@@ -2,37 +2,152 @@ package detector
 
 import (
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 var extensionToLanguage = map[string]string{
-	".go":   "go",
-	".py":   "python",
-	".js":   "javascript",
-	".jsx":  "javascript",
-	".ts":   "typescript",
-	".tsx":  "typescript",
-	".java": "java",
-	".rs":   "rust",
-	".rb":   "ruby",
-	".c":    "c",
-	".h":    "c",
-	".cpp":  "cpp",
-	".cc":   "cpp",
-	".cxx":  "cpp",
-	".hpp":  "cpp",
-	".md":   "markdown",
-	".json": "json",
-	".yaml": "yaml",
-	".yml":  "yaml",
-	".toml": "toml",
+	".go":       "go",
+	".py":       "python",
+	".js":       "javascript",
+	".jsx":      "javascript",
+	".ts":       "typescript",
+	".tsx":      "typescript",
+	".java":     "java",
+	".rs":       "rust",
+	".rb":       "ruby",
+	".c":        "c",
+	".h":        "c",
+	".cpp":      "cpp",
+	".cc":       "cpp",
+	".cxx":      "cpp",
+	".hpp":      "cpp",
+	".cs":       "csharp",
+	".fs":       "fsharp",
+	".fsi":      "fsharp",
+	".fsx":      "fsharp",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".json":     "json",
+	".yaml":     "yaml",
+	".yml":      "yaml",
+	".toml":     "toml",
+	".sh":       "shell",
+	".bash":     "shell",
+	".groovy":   "groovy",
+	".bzl":      "starlark",
+	".bazel":    "starlark",
 }
 
-// DetectLanguage returns the programming language of a file based on its extension.
+// filenameToLanguage maps well-known file names without a telling
+// extension to their language
+var filenameToLanguage = map[string]string{
+	"Dockerfile":    "dockerfile",
+	"Containerfile": "dockerfile",
+	"Jenkinsfile":   "groovy",
+	"Makefile":      "makefile",
+	"GNUmakefile":   "makefile",
+	"Rakefile":      "ruby",
+	"Gemfile":       "ruby",
+	"Vagrantfile":   "ruby",
+	"Brewfile":      "ruby",
+	"BUILD":         "starlark",
+	"WORKSPACE":     "starlark",
+}
+
+// interpreterToLanguage maps shebang interpreters, without version
+// suffixes, to languages
+var interpreterToLanguage = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"dash":    "shell",
+	"ksh":     "shell",
+	"python":  "python",
+	"node":    "javascript",
+	"deno":    "typescript",
+	"bun":     "javascript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"groovy":  "groovy",
+	"make":    "makefile",
+	"ts-node": "typescript",
+}
+
+// DetectLanguage returns the programming language of a file based on its
+// extension or, for files like Dockerfile, its name.
 func DetectLanguage(filePath string) string {
 	ext := strings.ToLower(filepath.Ext(filePath))
 	if lang, ok := extensionToLanguage[ext]; ok {
 		return lang
 	}
+	base := filepath.Base(filePath)
+	if lang, ok := filenameToLanguage[base]; ok {
+		return lang
+	}
+	// Variants such as Dockerfile.dev or api.Dockerfile
+	for _, name := range []string{"Dockerfile", "Containerfile"} {
+		if strings.HasPrefix(base, name+".") || strings.HasSuffix(base, "."+name) {
+			return "dockerfile"
+		}
+	}
+	return "unknown"
+}
+
+// SniffLanguage is DetectLanguage for a file whose content is at hand:
+// when the name doesn't tell, the interpreter of a shebang line does, so
+// extensionless scripts get a language too.
+func SniffLanguage(filePath, content string) string {
+	if lang := DetectLanguage(filePath); lang != "unknown" {
+		return lang
+	}
+	return shebangLanguage(content)
+}
+
+// shebangLanguage returns the language of the interpreter named by
+// content's shebang line, e.g. "#!/usr/bin/env python3"
+func shebangLanguage(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	line, ok := strings.CutPrefix(strings.TrimSpace(line), "#!")
+	if !ok {
+		return "unknown"
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env's options and variable assignments
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interpreter = f
+				break
+			}
+		}
+	}
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	if lang, ok := interpreterToLanguage[interpreter]; ok {
+		return lang
+	}
 	return "unknown"
 }
+
+// KnownLanguages returns every language DetectLanguage and SniffLanguage
+// can report, sorted.
+func KnownLanguages() []string {
+	seen := map[string]bool{}
+	var langs []string
+	for _, table := range []map[string]string{extensionToLanguage, filenameToLanguage, interpreterToLanguage} {
+		for _, lang := range table {
+			if !seen[lang] {
+				seen[lang] = true
+				langs = append(langs, lang)
+			}
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
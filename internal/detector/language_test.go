@@ -57,6 +57,58 @@ func TestDetectLanguage_Ruby(t *testing.T) {
 	}
 }
 
+func TestDetectLanguage_DotNet(t *testing.T) {
+	for file, want := range map[string]string{
+		"Program.cs":  "csharp",
+		"Library.fs":  "fsharp",
+		"Library.fsi": "fsharp",
+		"build.fsx":   "fsharp",
+	} {
+		if lang := DetectLanguage(file); lang != want {
+			t.Errorf("expected %q for %s, got %q", want, file, lang)
+		}
+	}
+}
+
+func TestDetectLanguage_WellKnownFilenames(t *testing.T) {
+	for file, want := range map[string]string{
+		"Dockerfile":             "dockerfile",
+		"deploy/Dockerfile.prod": "dockerfile",
+		"api.Dockerfile":         "dockerfile",
+		"Jenkinsfile":            "groovy",
+		"Makefile":               "makefile",
+		"Vagrantfile":            "ruby",
+		"BUILD.bazel":            "starlark",
+		"Gemfile.lock":           "unknown",
+	} {
+		if lang := DetectLanguage(file); lang != want {
+			t.Errorf("expected %q for %s, got %q", want, file, lang)
+		}
+	}
+}
+
+func TestSniffLanguage_Shebang(t *testing.T) {
+	for content, want := range map[string]string{
+		"#!/bin/bash\necho hi\n":                 "shell",
+		"#!/usr/bin/env python3\nprint(1)\n":     "python",
+		"#!/usr/bin/env -S node --no-warnings\n": "javascript",
+		"#!/usr/bin/python3.11 -u\n":             "python",
+		"#!/usr/bin/env FOO=1 ruby\n":            "ruby",
+		"#!/usr/local/bin/unknown-interp\n":      "unknown",
+		"no shebang here\n":                      "unknown",
+		"":                                       "unknown",
+	} {
+		if lang := SniffLanguage("bin/tool", content); lang != want {
+			t.Errorf("expected %q for %q, got %q", want, content, lang)
+		}
+	}
+
+	// The extension wins over the shebang
+	if lang := SniffLanguage("run.py", "#!/bin/sh\n"); lang != "python" {
+		t.Errorf("expected the extension to win, got %q", lang)
+	}
+}
+
 func TestDetectLanguage_C(t *testing.T) {
 	lang := DetectLanguage("main.c")
 	if lang != "c" {
@@ -153,3 +205,25 @@ func TestDetectLanguage_FullPath(t *testing.T) {
 		t.Errorf("expected 'go', got '%s'", lang)
 	}
 }
+
+func TestKnownLanguages(t *testing.T) {
+	langs := KnownLanguages()
+	if len(langs) == 0 {
+		t.Fatal("expected known languages")
+	}
+	seen := map[string]bool{}
+	for i, lang := range langs {
+		if seen[lang] {
+			t.Errorf("duplicate language %q", lang)
+		}
+		seen[lang] = true
+		if i > 0 && langs[i-1] > lang {
+			t.Errorf("expected sorted languages, got %v", langs)
+		}
+	}
+	for _, want := range []string{"go", "python", "markdown"} {
+		if !seen[want] {
+			t.Errorf("expected %q in known languages", want)
+		}
+	}
+}
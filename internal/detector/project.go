@@ -3,14 +3,20 @@ package detector
 import (
 	"bufio"
 	"encoding/json"
+	"encoding/xml"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/walker"
 )
 
 // ProjectInfo contains information about a detected software project.
 type ProjectInfo struct {
-	Type         string            // go, node, python, rust, java, ruby, unknown
+	Dir          string            // relative to the root passed to DetectProjects; "." for the root
+	Type         string            // go, node, python, rust, java, ruby, php, elixir, dotnet, unknown
 	HasVCS       bool              // whether the project has version control
 	VCSType      string            // git, svn, hg
 	HasIDEConfig bool              // whether the project has IDE configuration
@@ -28,6 +34,16 @@ var projectMarkers = map[string]string{
 	"pom.xml":          "java",
 	"build.gradle":     "java",
 	"Gemfile":          "ruby",
+	"composer.json":    "php",
+	"mix.exs":          "elixir",
+}
+
+// projectExtensions maps extensions of marker files whose names vary by
+// project (e.g. MyApp.csproj) to project types
+var projectExtensions = map[string]string{
+	".sln":    "dotnet",
+	".csproj": "dotnet",
+	".fsproj": "dotnet",
 }
 
 // vcsMarkers maps VCS directories to VCS types
@@ -48,6 +64,7 @@ func DetectProject(dirPath string) (*ProjectInfo, error) {
 	}
 
 	info := &ProjectInfo{
+		Dir:          ".",
 		Type:         "unknown",
 		Dependencies: make(map[string]string),
 	}
@@ -57,16 +74,25 @@ func DetectProject(dirPath string) (*ProjectInfo, error) {
 		markerPath := filepath.Join(dirPath, marker)
 		if _, err := os.Stat(markerPath); err == nil {
 			info.Type = projectType
-			// Parse dependencies based on project type
-			switch marker {
-			case "go.mod":
-				parseGoModDependencies(markerPath, info.Dependencies)
-			case "package.json":
-				parsePackageJsonDependencies(markerPath, info.Dependencies)
-			}
 			break
 		}
 	}
+	// Parse dependencies from every marker of the project's type, e.g. both
+	// requirements.txt and pyproject.toml; markers that don't exist are
+	// skipped by the parsers
+	markers := make([]string, 0, len(projectMarkers))
+	for marker, projectType := range projectMarkers {
+		if projectType == info.Type {
+			markers = append(markers, marker)
+		}
+	}
+	sort.Strings(markers)
+	for _, marker := range markers {
+		parseDependencies(marker, filepath.Join(dirPath, marker), info.Dependencies)
+	}
+	if info.Type == "unknown" {
+		detectByExtension(dirPath, info)
+	}
 
 	// Detect VCS
 	for marker, vcsType := range vcsMarkers {
@@ -90,6 +116,97 @@ func DetectProject(dirPath string) (*ProjectInfo, error) {
 	return info, nil
 }
 
+// dependencyDirs are directories holding other projects' code, whose
+// markers don't make nested projects
+var dependencyDirs = map[string]bool{"node_modules": true, "vendor": true}
+
+// DetectProjects detects the project at root and the projects nested in
+// it, such as the services and frontend of a monorepo. The project at root
+// comes first, even when its type is unknown; nested projects follow,
+// sorted by Dir. Hidden, gitignored and dependency directories aren't
+// searched.
+func DetectProjects(root string) ([]*ProjectInfo, error) {
+	rootInfo, err := DetectProject(root)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := walker.Walk(root)
+	if err != nil {
+		return nil, err
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]bool{}
+	for fi := range ch {
+		name := filepath.Base(fi.Path)
+		_, marker := projectMarkers[name]
+		_, markerExt := projectExtensions[strings.ToLower(filepath.Ext(name))]
+		if !marker && !markerExt {
+			continue
+		}
+		dir, err := filepath.Rel(absRoot, filepath.Dir(fi.Path))
+		if err != nil || dir == "." || inDependencyDir(dir) {
+			continue
+		}
+		dirs[dir] = true
+	}
+
+	nested := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		nested = append(nested, dir)
+	}
+	sort.Strings(nested)
+
+	projects := []*ProjectInfo{rootInfo}
+	for _, dir := range nested {
+		info, err := DetectProject(filepath.Join(root, dir))
+		if err != nil || info.Type == "unknown" {
+			continue
+		}
+		info.Dir = dir
+		projects = append(projects, info)
+	}
+	return projects, nil
+}
+
+// inDependencyDir reports whether the relative directory dir is inside a
+// dependency directory
+func inDependencyDir(dir string) bool {
+	for _, part := range strings.Split(dir, string(filepath.Separator)) {
+		if dependencyDirs[part] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDependencies extracts dependencies from the marker file at path
+func parseDependencies(marker, path string, deps map[string]string) {
+	switch marker {
+	case "go.mod":
+		parseGoModDependencies(path, deps)
+	case "package.json":
+		parsePackageJsonDependencies(path, deps)
+	case "requirements.txt":
+		parseRequirementsTxtDependencies(path, deps)
+	case "pyproject.toml":
+		parsePyprojectDependencies(path, deps)
+	case "Cargo.toml":
+		parseCargoTomlDependencies(path, deps)
+	case "pom.xml":
+		parsePomXmlDependencies(path, deps)
+	case "build.gradle":
+		parseGradleDependencies(path, deps)
+	case "composer.json":
+		parseComposerJsonDependencies(path, deps)
+	case "mix.exs":
+		parseMixExsDependencies(path, deps)
+	}
+}
+
 // parseGoModDependencies extracts dependencies from a go.mod file
 func parseGoModDependencies(goModPath string, deps map[string]string) {
 	file, err := os.Open(goModPath)
@@ -160,3 +277,153 @@ func parsePackageJsonDependencies(packageJsonPath string, deps map[string]string
 		deps[name] = version
 	}
 }
+
+// parseComposerJsonDependencies extracts dependencies from a composer.json
+// file. Platform requirements (php, ext-*) are included as listed.
+func parseComposerJsonDependencies(composerJsonPath string, deps map[string]string) {
+	data, err := os.ReadFile(composerJsonPath)
+	if err != nil {
+		return
+	}
+
+	var pkg struct {
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}
+
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return
+	}
+
+	for name, version := range pkg.Require {
+		deps[name] = version
+	}
+	for name, version := range pkg.RequireDev {
+		deps[name] = version
+	}
+}
+
+// mixDepPattern matches dependency tuples in mix.exs, e.g.
+// {:phoenix, "~> 1.7"} or {:dep, github: "org/dep"}
+var mixDepPattern = regexp.MustCompile(`\{\s*:(\w+)\s*,\s*(?:"([^"]*)")?`)
+
+// parseMixExsDependencies extracts dependencies from the deps function of
+// a mix.exs file. Dependencies without a version requirement (git or path
+// dependencies) get an empty version.
+func parseMixExsDependencies(mixExsPath string, deps map[string]string) {
+	file, err := os.Open(mixExsPath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	inDeps := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Check for start of the deps function
+		if strings.HasPrefix(line, "defp deps") || strings.HasPrefix(line, "def deps") {
+			inDeps = true
+			continue
+		}
+		if !inDeps {
+			continue
+		}
+		if line == "end" {
+			inDeps = false
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for _, m := range mixDepPattern.FindAllStringSubmatch(line, -1) {
+			deps[m[1]] = m[2]
+		}
+	}
+}
+
+// detectByExtension sets info.Type from marker files matched by extension
+// and parses their dependencies
+func detectByExtension(dirPath string, info *ProjectInfo) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		projectType, ok := projectExtensions[ext]
+		if !ok {
+			continue
+		}
+		info.Type = projectType
+		path := filepath.Join(dirPath, e.Name())
+		switch ext {
+		case ".csproj", ".fsproj":
+			parsePackageReferences(path, info.Dependencies)
+		case ".sln":
+			for _, proj := range solutionProjects(path) {
+				parsePackageReferences(proj, info.Dependencies)
+			}
+		}
+	}
+}
+
+// parsePackageReferences extracts the PackageReference items of a .csproj
+// or .fsproj file. The version may be an attribute or a child element; it
+// is empty when versions are managed centrally.
+func parsePackageReferences(projPath string, deps map[string]string) {
+	data, err := os.ReadFile(projPath)
+	if err != nil {
+		return
+	}
+
+	var proj struct {
+		ItemGroups []struct {
+			PackageReferences []struct {
+				Include        string `xml:"Include,attr"`
+				Version        string `xml:"Version,attr"`
+				VersionElement string `xml:"Version"`
+			} `xml:"PackageReference"`
+		} `xml:"ItemGroup"`
+	}
+	if err := xml.Unmarshal(data, &proj); err != nil {
+		return
+	}
+
+	for _, group := range proj.ItemGroups {
+		for _, ref := range group.PackageReferences {
+			if ref.Include == "" {
+				continue
+			}
+			version := ref.Version
+			if version == "" {
+				version = strings.TrimSpace(ref.VersionElement)
+			}
+			deps[ref.Include] = version
+		}
+	}
+}
+
+// slnProjectPattern matches the project entries of a .sln file, e.g.
+// Project("{...}") = "App", "src\App\App.csproj", "{...}"
+var slnProjectPattern = regexp.MustCompile(`(?m)^Project\("[^"]*"\)\s*=\s*"[^"]*",\s*"([^"]+\.(?:cs|fs)proj)"`)
+
+// solutionProjects returns the paths of the C# and F# projects listed in a
+// .sln file
+func solutionProjects(slnPath string) []string {
+	data, err := os.ReadFile(slnPath)
+	if err != nil {
+		return nil
+	}
+	var projects []string
+	for _, m := range slnProjectPattern.FindAllStringSubmatch(string(data), -1) {
+		rel := filepath.FromSlash(strings.ReplaceAll(m[1], `\`, "/"))
+		projects = append(projects, filepath.Join(filepath.Dir(slnPath), rel))
+	}
+	return projects
+}
@@ -3,6 +3,7 @@ package detector
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -307,6 +308,327 @@ func TestDetectProject_PackageJsonDependencies(t *testing.T) {
 	}
 }
 
+func TestDetectProject_PHPProject(t *testing.T) {
+	dir := t.TempDir()
+	composerJsonContent := `{
+  "name": "acme/app",
+  "require": {
+    "php": ">=8.1",
+    "laravel/framework": "^10.0"
+  },
+  "require-dev": {
+    "phpunit/phpunit": "^10.5"
+  }
+}`
+	if err := os.WriteFile(filepath.Join(dir, "composer.json"), []byte(composerJsonContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := DetectProject(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Type != "php" {
+		t.Errorf("expected Type='php', got '%s'", info.Type)
+	}
+	if info.Dependencies["laravel/framework"] != "^10.0" {
+		t.Errorf("expected laravel/framework ^10.0, got '%s'", info.Dependencies["laravel/framework"])
+	}
+	if info.Dependencies["phpunit/phpunit"] != "^10.5" {
+		t.Errorf("expected phpunit/phpunit ^10.5, got '%s'", info.Dependencies["phpunit/phpunit"])
+	}
+}
+
+func TestDetectProject_ElixirProject(t *testing.T) {
+	dir := t.TempDir()
+	mixExsContent := `defmodule App.MixProject do
+  use Mix.Project
+
+  def project do
+    [app: :app, version: "0.1.0", deps: deps()]
+  end
+
+  defp deps do
+    [
+      {:phoenix, "~> 1.7.10"},
+      {:jason, "~> 1.2", only: :test},
+      # {:commented, "1.0"},
+      {:plug_cowboy, github: "elixir-plug/plug_cowboy"}
+    ]
+  end
+end
+`
+	if err := os.WriteFile(filepath.Join(dir, "mix.exs"), []byte(mixExsContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := DetectProject(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Type != "elixir" {
+		t.Errorf("expected Type='elixir', got '%s'", info.Type)
+	}
+	want := map[string]string{"phoenix": "~> 1.7.10", "jason": "~> 1.2", "plug_cowboy": ""}
+	if len(info.Dependencies) != len(want) {
+		t.Errorf("expected dependencies %v, got %v", want, info.Dependencies)
+	}
+	for name, version := range want {
+		if got, ok := info.Dependencies[name]; !ok || got != version {
+			t.Errorf("expected %s %q, got %q", name, version, got)
+		}
+	}
+}
+
+func TestDetectProject_DotNetProject(t *testing.T) {
+	dir := t.TempDir()
+	csproj := `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Newtonsoft.Json" Version="13.0.3" />
+    <PackageReference Include="Serilog">
+      <Version>3.1.1</Version>
+    </PackageReference>
+  </ItemGroup>
+</Project>`
+	if err := os.WriteFile(filepath.Join(dir, "App.csproj"), []byte(csproj), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := DetectProject(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Type != "dotnet" {
+		t.Errorf("expected Type='dotnet', got '%s'", info.Type)
+	}
+	if info.Dependencies["Newtonsoft.Json"] != "13.0.3" {
+		t.Errorf("expected Newtonsoft.Json 13.0.3, got '%s'", info.Dependencies["Newtonsoft.Json"])
+	}
+	if info.Dependencies["Serilog"] != "3.1.1" {
+		t.Errorf("expected Serilog 3.1.1, got '%s'", info.Dependencies["Serilog"])
+	}
+}
+
+func TestDetectProject_DotNetSolution(t *testing.T) {
+	dir := t.TempDir()
+	sln := "Microsoft Visual Studio Solution File, Format Version 12.00\r\n" +
+		`Project("{F2A71F9B-5D33-465A-A702-920D77279786}") = "Lib", "src\Lib\Lib.fsproj", "{11111111-1111-1111-1111-111111111111}"` + "\r\n" +
+		"EndProject\r\n"
+	if err := os.WriteFile(filepath.Join(dir, "All.sln"), []byte(sln), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "src", "Lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fsproj := `<Project Sdk="Microsoft.NET.Sdk"><ItemGroup><PackageReference Include="FSharp.Core" Version="8.0.100" /></ItemGroup></Project>`
+	if err := os.WriteFile(filepath.Join(dir, "src", "Lib", "Lib.fsproj"), []byte(fsproj), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := DetectProject(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Type != "dotnet" {
+		t.Errorf("expected Type='dotnet', got '%s'", info.Type)
+	}
+	if info.Dependencies["FSharp.Core"] != "8.0.100" {
+		t.Errorf("expected FSharp.Core 8.0.100, got '%s'", info.Dependencies["FSharp.Core"])
+	}
+}
+
+func TestDetectProjects_Nested(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"go.mod":                              "module example.com/mono",
+		"services/api/go.mod":                 "module example.com/api",
+		"web/package.json":                    `{"dependencies": {"react": "^18.2.0"}}`,
+		"web/node_modules/react/package.json": "{}",
+		"web/src/index.js":                    "",
+		".hidden/Cargo.toml":                  "",
+		"tools/lib/App/App.csproj":            "<Project></Project>",
+	} {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	projects, err := DetectProjects(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, p := range projects {
+		got = append(got, filepath.ToSlash(p.Dir)+"="+p.Type)
+	}
+	want := []string{".=go", "services/api=go", "tools/lib/App=dotnet", "web=node"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected projects %v, got %v", want, got)
+	}
+	if projects[3].Dependencies["react"] != "^18.2.0" {
+		t.Errorf("expected the nested project's dependencies, got %v", projects[3].Dependencies)
+	}
+}
+
+func TestDetectProjects_UnknownRoot(t *testing.T) {
+	dir := t.TempDir()
+	projects, err := DetectProjects(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Dir != "." || projects[0].Type != "unknown" {
+		t.Errorf("expected only the unknown root project, got %+v", projects)
+	}
+}
+
+// detectDeps writes files into a temp dir and returns the detected project
+func detectDeps(t *testing.T, files map[string]string) *ProjectInfo {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := DetectProject(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return info
+}
+
+// checkDeps compares deps with want exactly
+func checkDeps(t *testing.T, deps, want map[string]string) {
+	t.Helper()
+	if len(deps) != len(want) {
+		t.Errorf("expected dependencies %v, got %v", want, deps)
+	}
+	for name, version := range want {
+		if got, ok := deps[name]; !ok || got != version {
+			t.Errorf("expected %s %q, got %q", name, version, got)
+		}
+	}
+}
+
+func TestDetectProject_PythonDependencies(t *testing.T) {
+	info := detectDeps(t, map[string]string{
+		"requirements.txt": `# web
+flask==3.0.0
+requests[socks]>=2.31 ; python_version > "3.8"
+-r dev-requirements.txt
+-e .
+numpy  # no pin
+`,
+		"pyproject.toml": `[project]
+name = "app"
+dependencies = [
+    "pydantic>=2,<3",
+    # comment
+    "rich",
+]
+
+[tool.poetry.dependencies]
+python = "^3.11"
+httpx = "^0.27"
+uvicorn = { version = "^0.29", extras = ["standard"] }
+
+[tool.poetry.group.dev.dependencies]
+pytest = "^8.0"
+`,
+	})
+	if info.Type != "python" {
+		t.Errorf("expected Type='python', got '%s'", info.Type)
+	}
+	checkDeps(t, info.Dependencies, map[string]string{
+		"flask":    "==3.0.0",
+		"requests": ">=2.31",
+		"numpy":    "",
+		"pydantic": ">=2,<3",
+		"rich":     "",
+		"httpx":    "^0.27",
+		"uvicorn":  "^0.29",
+		"pytest":   "^8.0",
+	})
+}
+
+func TestDetectProject_CargoDependencies(t *testing.T) {
+	info := detectDeps(t, map[string]string{
+		"Cargo.toml": `[package]
+name = "app"
+version = "0.1.0"
+
+[dependencies]
+serde = { version = "1.0", features = ["derive"] }
+anyhow = "1"
+local = { path = "../local" }
+
+[dependencies.tokio]
+version = "1.37"
+features = ["full"]
+
+[dev-dependencies]
+proptest = "1.4"
+`,
+	})
+	if info.Type != "rust" {
+		t.Errorf("expected Type='rust', got '%s'", info.Type)
+	}
+	checkDeps(t, info.Dependencies, map[string]string{
+		"serde":    "1.0",
+		"anyhow":   "1",
+		"local":    "",
+		"tokio":    "1.37",
+		"proptest": "1.4",
+	})
+}
+
+func TestDetectProject_JavaDependencies(t *testing.T) {
+	info := detectDeps(t, map[string]string{
+		"pom.xml": `<project>
+  <properties>
+    <junit.version>5.10.2</junit.version>
+  </properties>
+  <dependencies>
+    <dependency>
+      <groupId>org.junit.jupiter</groupId>
+      <artifactId>junit-jupiter</artifactId>
+      <version>${junit.version}</version>
+    </dependency>
+    <dependency>
+      <groupId>com.google.guava</groupId>
+      <artifactId>guava</artifactId>
+      <version>33.0.0-jre</version>
+    </dependency>
+  </dependencies>
+</project>`,
+		"build.gradle": `plugins {
+    id 'java'
+}
+
+dependencies {
+    implementation 'org.slf4j:slf4j-api:2.0.12'
+    testImplementation("org.mockito:mockito-core:5.11.0")
+    implementation platform('org.springframework.boot:spring-boot-dependencies:3.2.4')
+    compileOnly "org.projectlombok:lombok"
+}
+`,
+	})
+	if info.Type != "java" {
+		t.Errorf("expected Type='java', got '%s'", info.Type)
+	}
+	checkDeps(t, info.Dependencies, map[string]string{
+		"org.junit.jupiter:junit-jupiter": "5.10.2",
+		"com.google.guava:guava":          "33.0.0-jre",
+		"org.slf4j:slf4j-api":             "2.0.12",
+		"org.mockito:mockito-core":        "5.11.0",
+		"org.projectlombok:lombok":        "",
+	})
+}
+
 func TestDetectProject_NonExistentDir(t *testing.T) {
 	_, err := DetectProject("/non/existent/path/that/does/not/exist")
 	if err == nil {
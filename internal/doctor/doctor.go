@@ -0,0 +1,297 @@
+// Package doctor runs environment and connectivity checks and suggests
+// fixes for anything that would stop indexing from working.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/registry"
+)
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Check is the result of one diagnostic, with a suggested fix when it
+// didn't pass.
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// Report is the result of all checks in the order they ran.
+type Report struct {
+	Checks []Check `json:"checks"`
+}
+
+// Failed reports whether any check failed.
+func (r *Report) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Store is the search backend being diagnosed
+type Store interface {
+	Ping(ctx context.Context) error
+	CollectionStats(ctx context.Context) (*indexer.CollectionStats, error)
+}
+
+// Embedder is the embedding service being diagnosed
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// Options configures a doctor run. Store and Embedder are nil when the
+// config couldn't be loaded.
+type Options struct {
+	Config    *config.Config
+	ConfigErr error
+	ConfigDir string
+	Store     Store
+	Embedder  Embedder
+}
+
+// intEnvVars are numeric settings that silently fall back to defaults
+// when they don't parse
+var intEnvVars = []string{"GEMINI_RATE_LIMIT", "SWARM_INDEXER_WORKERS", "SWARM_INDEXER_BATCH_SIZE"}
+
+// probeText is embedded to check the Gemini key and model dimension
+const probeText = "swarm-indexer doctor"
+
+// Run performs all checks. Checks that depend on a failed one are skipped.
+func Run(ctx context.Context, opts Options) *Report {
+	r := &Report{}
+	r.Checks = append(r.Checks, checkConfig(opts))
+	r.Checks = append(r.Checks, checkEnv()...)
+	r.Checks = append(r.Checks, checkRegistry(opts.ConfigDir))
+
+	if opts.Config == nil {
+		return r
+	}
+
+	storeOK := true
+	if opts.Store != nil {
+		c := checkTypesense(ctx, opts.Store, opts.Config.TypesenseURL)
+		storeOK = c.Status == StatusOK
+		r.Checks = append(r.Checks, c)
+	}
+
+	dim := 0
+	if opts.Embedder != nil {
+		var c Check
+		c, dim = checkGemini(ctx, opts.Embedder, opts.Config.GeminiModel)
+		r.Checks = append(r.Checks, c)
+	}
+
+	if opts.Store != nil && storeOK {
+		r.Checks = append(r.Checks, checkSchema(ctx, opts.Store, opts.Config, dim))
+	}
+	return r
+}
+
+func checkConfig(opts Options) Check {
+	c := Check{Name: "Config"}
+	if opts.ConfigErr != nil {
+		c.Status = StatusFail
+		c.Detail = opts.ConfigErr.Error()
+		c.Fix = "Export the missing variable, e.g. export TYPESENSE_API_KEY=... and export GEMINI_API_KEY=..."
+		return c
+	}
+
+	u, err := url.Parse(opts.Config.TypesenseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.Status = StatusFail
+		c.Detail = fmt.Sprintf("TYPESENSE_URL %q is not an http(s) URL", opts.Config.TypesenseURL)
+		c.Fix = "Set TYPESENSE_URL to the server address, e.g. http://localhost:8108"
+		return c
+	}
+
+	c.Status = StatusOK
+	c.Detail = fmt.Sprintf("Typesense %s, collection %q, model %s", opts.Config.TypesenseURL, opts.Config.TypesenseCollection, opts.Config.GeminiModel)
+	return c
+}
+
+func checkEnv() []Check {
+	var checks []Check
+	for _, name := range intEnvVars {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			checks = append(checks, Check{
+				Name:   name,
+				Status: StatusWarn,
+				Detail: fmt.Sprintf("%q is not a positive integer; the default is used instead", value),
+				Fix:    fmt.Sprintf("Set %s to a positive integer or unset it", name),
+			})
+		}
+	}
+	return checks
+}
+
+func checkRegistry(configDir string) Check {
+	c := Check{Name: "Registry"}
+	if configDir == "" {
+		c.Status = StatusWarn
+		c.Detail = "could not resolve the config directory"
+		c.Fix = "Set SWARM_INDEXER_CONFIG_DIR to a writable directory"
+		return c
+	}
+
+	reg, err := registry.Load(configDir)
+	if err != nil {
+		c.Status = StatusFail
+		c.Detail = err.Error()
+		c.Fix = fmt.Sprintf("Fix or remove %s/%s", configDir, registry.FileName)
+		return c
+	}
+
+	c.Status = StatusOK
+	c.Detail = fmt.Sprintf("%d registered paths in %s", len(reg.Entries), configDir)
+	return c
+}
+
+func checkTypesense(ctx context.Context, store Store, serverURL string) Check {
+	c := Check{Name: "Typesense"}
+	start := time.Now()
+	err := store.Ping(ctx)
+	latency := time.Since(start).Milliseconds()
+
+	var authErr interface{ IsAuthError() bool }
+	switch {
+	case err == nil:
+		c.Status = StatusOK
+		c.Detail = fmt.Sprintf("reachable at %s (%dms)", serverURL, latency)
+	case errors.As(err, &authErr) && authErr.IsAuthError():
+		c.Status = StatusFail
+		c.Detail = "API key rejected"
+		c.Fix = "Check TYPESENSE_API_KEY matches the server's --api-key"
+	default:
+		c.Status = StatusFail
+		c.Detail = err.Error()
+		c.Fix = fmt.Sprintf("Start Typesense at %s (e.g. docker run -p 8108:8108 typesense/typesense) or set TYPESENSE_URL", serverURL)
+	}
+	return c
+}
+
+// checkGemini embeds a short probe text, returning the embedding dimension
+// on success
+func checkGemini(ctx context.Context, embedder Embedder, model string) (Check, int) {
+	c := Check{Name: "Gemini"}
+	start := time.Now()
+	vec, err := embedder.Embed(ctx, probeText)
+	latency := time.Since(start).Milliseconds()
+
+	var apiErr interface {
+		IsAuthError() bool
+		IsQuotaError() bool
+	}
+	switch {
+	case err == nil:
+		c.Status = StatusOK
+		c.Detail = fmt.Sprintf("%s returned a %d-dimension embedding (%dms)", model, len(vec), latency)
+		return c, len(vec)
+	case errors.As(err, &apiErr) && apiErr.IsAuthError():
+		c.Status = StatusFail
+		c.Detail = "API key rejected"
+		c.Fix = "Check GEMINI_API_KEY; keys can be created at https://aistudio.google.com/apikey"
+	case errors.As(err, &apiErr) && apiErr.IsQuotaError():
+		c.Status = StatusWarn
+		c.Detail = "quota or rate limit exceeded"
+		c.Fix = "Wait for the quota to reset or lower GEMINI_RATE_LIMIT"
+	default:
+		c.Status = StatusFail
+		c.Detail = err.Error()
+		c.Fix = fmt.Sprintf("Check network access to the Gemini API and that GEMINI_MODEL %q exists", model)
+	}
+	return c, 0
+}
+
+// checkSchema compares the collection's embedding dimension with the
+// model's. dim is 0 when the model couldn't be probed.
+func checkSchema(ctx context.Context, store Store, cfg *config.Config, dim int) Check {
+	c := Check{Name: "Schema"}
+
+	stats, err := store.CollectionStats(ctx)
+	var collectionDim int
+	var notFound interface{ IsNotFound() bool }
+	switch {
+	case err == nil:
+		collectionDim = stats.EmbeddingDim
+	case errors.As(err, &notFound) && notFound.IsNotFound():
+		if dim == 0 || dim == indexer.EmbeddingDim {
+			c.Status = StatusOK
+			c.Detail = fmt.Sprintf("collection %q doesn't exist yet; it will be created on the first index", cfg.TypesenseCollection)
+			return c
+		}
+		c.Status = StatusFail
+		c.Detail = fmt.Sprintf("new collections use %d dimensions but %s returns %d", indexer.EmbeddingDim, cfg.GeminiModel, dim)
+		c.Fix = fmt.Sprintf("Set GEMINI_MODEL to a %d-dimension model", indexer.EmbeddingDim)
+		return c
+	default:
+		c.Status = StatusFail
+		c.Detail = err.Error()
+		c.Fix = "Check the Typesense server logs"
+		return c
+	}
+
+	if dim == 0 {
+		c.Status = StatusWarn
+		c.Detail = fmt.Sprintf("collection embeds %d dimensions; model dimension unknown", collectionDim)
+		return c
+	}
+	if dim != collectionDim {
+		c.Status = StatusFail
+		c.Detail = fmt.Sprintf("collection expects %d dimensions but %s returns %d", collectionDim, cfg.GeminiModel, dim)
+		c.Fix = fmt.Sprintf("Set GEMINI_MODEL to a %d-dimension model, or use a new TYPESENSE_COLLECTION and reindex", collectionDim)
+		return c
+	}
+
+	c.Status = StatusOK
+	c.Detail = fmt.Sprintf("collection and model agree on %d dimensions", dim)
+	return c
+}
+
+// Write prints the report as a checklist with fixes under failed checks.
+func Write(w io.Writer, r *Report) {
+	for _, c := range r.Checks {
+		mark := "✓"
+		switch c.Status {
+		case StatusWarn:
+			mark = "⚠"
+		case StatusFail:
+			mark = "✗"
+		}
+		fmt.Fprintf(w, "%s %-10s %s\n", mark, c.Name, c.Detail)
+		if c.Fix != "" {
+			fmt.Fprintf(w, "  → %s\n", c.Fix)
+		}
+	}
+
+	if r.Failed() {
+		fmt.Fprintln(w, "\nSome checks failed; fix the issues above and run doctor again.")
+	} else {
+		fmt.Fprintln(w, "\nAll checks passed.")
+	}
+}
@@ -0,0 +1,201 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+)
+
+type fakeStore struct {
+	pingErr  error
+	stats    *indexer.CollectionStats
+	statsErr error
+}
+
+func (f *fakeStore) Ping(ctx context.Context) error { return f.pingErr }
+
+func (f *fakeStore) CollectionStats(ctx context.Context) (*indexer.CollectionStats, error) {
+	return f.stats, f.statsErr
+}
+
+type fakeEmbedder struct {
+	dim int
+	err error
+}
+
+func (f *fakeEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return make([]float32, f.dim), nil
+}
+
+type fakeAPIError struct {
+	auth, quota bool
+}
+
+func (e *fakeAPIError) Error() string      { return "api error" }
+func (e *fakeAPIError) IsAuthError() bool  { return e.auth }
+func (e *fakeAPIError) IsQuotaError() bool { return e.quota }
+
+func testConfig() *config.Config {
+	return &config.Config{
+		TypesenseURL:        "http://localhost:8108",
+		TypesenseCollection: "swarm-index",
+		GeminiModel:         "gemini-embedding-001",
+	}
+}
+
+func findCheck(t *testing.T, r *Report, name string) Check {
+	t.Helper()
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no %s check in report: %+v", name, r.Checks)
+	return Check{}
+}
+
+func TestRun_AllPass(t *testing.T) {
+	r := Run(context.Background(), Options{
+		Config:    testConfig(),
+		ConfigDir: t.TempDir(),
+		Store:     &fakeStore{stats: &indexer.CollectionStats{EmbeddingDim: 768}},
+		Embedder:  &fakeEmbedder{dim: 768},
+	})
+
+	if r.Failed() {
+		t.Fatalf("expected all checks to pass, got %+v", r.Checks)
+	}
+	for _, name := range []string{"Config", "Registry", "Typesense", "Gemini", "Schema"} {
+		if c := findCheck(t, r, name); c.Status != StatusOK {
+			t.Errorf("expected %s to be ok, got %s: %s", name, c.Status, c.Detail)
+		}
+	}
+}
+
+func TestRun_ConfigError(t *testing.T) {
+	r := Run(context.Background(), Options{ConfigErr: errors.New("TYPESENSE_API_KEY is required")})
+
+	c := findCheck(t, r, "Config")
+	if c.Status != StatusFail || c.Fix == "" {
+		t.Errorf("expected config failure with a fix, got %+v", c)
+	}
+	for _, check := range r.Checks {
+		if check.Name == "Typesense" || check.Name == "Gemini" {
+			t.Errorf("expected connectivity checks to be skipped, got %s", check.Name)
+		}
+	}
+}
+
+func TestRun_BadTypesenseURL(t *testing.T) {
+	cfg := testConfig()
+	cfg.TypesenseURL = "localhost:8108"
+
+	r := Run(context.Background(), Options{Config: cfg})
+	if c := findCheck(t, r, "Config"); c.Status != StatusFail {
+		t.Errorf("expected scheme-less URL to fail, got %+v", c)
+	}
+}
+
+func TestRun_InvalidIntEnv(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_WORKERS", "eight")
+
+	r := Run(context.Background(), Options{Config: testConfig()})
+	if c := findCheck(t, r, "SWARM_INDEXER_WORKERS"); c.Status != StatusWarn {
+		t.Errorf("expected warning for non-numeric workers, got %+v", c)
+	}
+}
+
+func TestRun_AuthFailures(t *testing.T) {
+	r := Run(context.Background(), Options{
+		Config:   testConfig(),
+		Store:    &fakeStore{pingErr: &fakeAPIError{auth: true}},
+		Embedder: &fakeEmbedder{err: &fakeAPIError{auth: true}},
+	})
+
+	ts := findCheck(t, r, "Typesense")
+	if ts.Status != StatusFail || !strings.Contains(ts.Fix, "TYPESENSE_API_KEY") {
+		t.Errorf("expected Typesense auth failure pointing at the key, got %+v", ts)
+	}
+	gem := findCheck(t, r, "Gemini")
+	if gem.Status != StatusFail || !strings.Contains(gem.Fix, "GEMINI_API_KEY") {
+		t.Errorf("expected Gemini auth failure pointing at the key, got %+v", gem)
+	}
+	for _, c := range r.Checks {
+		if c.Name == "Schema" {
+			t.Error("expected schema check to be skipped when Typesense is unavailable")
+		}
+	}
+}
+
+func TestRun_GeminiQuotaIsWarning(t *testing.T) {
+	r := Run(context.Background(), Options{
+		Config:   testConfig(),
+		Embedder: &fakeEmbedder{err: &fakeAPIError{quota: true}},
+	})
+	if c := findCheck(t, r, "Gemini"); c.Status != StatusWarn {
+		t.Errorf("expected quota error to be a warning, got %+v", c)
+	}
+}
+
+func TestRun_DimensionMismatch(t *testing.T) {
+	r := Run(context.Background(), Options{
+		Config:   testConfig(),
+		Store:    &fakeStore{stats: &indexer.CollectionStats{EmbeddingDim: 768}},
+		Embedder: &fakeEmbedder{dim: 3072},
+	})
+
+	c := findCheck(t, r, "Schema")
+	if c.Status != StatusFail {
+		t.Fatalf("expected schema mismatch to fail, got %+v", c)
+	}
+	if !strings.Contains(c.Detail, "768") || !strings.Contains(c.Detail, "3072") {
+		t.Errorf("expected both dimensions in detail, got %q", c.Detail)
+	}
+}
+
+func TestRun_MissingCollection(t *testing.T) {
+	notFound := &indexer.APIError{StatusCode: 404, Message: "Not Found"}
+
+	r := Run(context.Background(), Options{
+		Config:   testConfig(),
+		Store:    &fakeStore{statsErr: notFound},
+		Embedder: &fakeEmbedder{dim: indexer.EmbeddingDim},
+	})
+	if c := findCheck(t, r, "Schema"); c.Status != StatusOK {
+		t.Errorf("expected missing collection with matching model to be ok, got %+v", c)
+	}
+
+	r = Run(context.Background(), Options{
+		Config:   testConfig(),
+		Store:    &fakeStore{statsErr: notFound},
+		Embedder: &fakeEmbedder{dim: 3072},
+	})
+	if c := findCheck(t, r, "Schema"); c.Status != StatusFail {
+		t.Errorf("expected missing collection with mismatched model to fail, got %+v", c)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := &Report{Checks: []Check{
+		{Name: "Config", Status: StatusOK, Detail: "fine"},
+		{Name: "Gemini", Status: StatusFail, Detail: "API key rejected", Fix: "Check GEMINI_API_KEY"},
+	}}
+
+	var buf bytes.Buffer
+	Write(&buf, r)
+	out := buf.String()
+
+	for _, want := range []string{"✓ Config", "✗ Gemini", "→ Check GEMINI_API_KEY", "Some checks failed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
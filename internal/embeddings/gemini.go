@@ -8,28 +8,31 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
 const (
-	defaultModel     = "gemini-embedding-001"
-	defaultRateLimit = 60
-	defaultBaseURL   = "https://generativelanguage.googleapis.com/v1beta"
-	maxRetries       = 3
-	initialBackoff   = 1 * time.Second
+	defaultModel      = "gemini-embedding-001"
+	defaultRateLimit  = 60
+	defaultBaseURL    = "https://generativelanguage.googleapis.com/v1beta"
+	defaultTimeout    = 30 * time.Second
+	maxRetries        = 3
+	initialBackoff    = 1 * time.Second
 	backoffMultiplier = 2
 )
 
 // GeminiClient is a client for generating embeddings via Gemini API.
 type GeminiClient struct {
-	apiKey     string
-	model      string
-	rateLimit  int
-	limiter    *rate.Limiter
-	httpClient *http.Client
-	baseURL    string
+	apiKey       string
+	model        string
+	summaryModel string // generative model for Summarize; empty disables it
+	rateLimit    int
+	limiter      *rate.Limiter
+	httpClient   *http.Client
+	baseURL      string
 }
 
 // Request/Response types for Gemini API
@@ -83,11 +86,17 @@ func NewGeminiClient(apiKey, model string, rateLimit int) *GeminiClient {
 		model:      model,
 		rateLimit:  rateLimit,
 		limiter:    limiter,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: &http.Client{Timeout: defaultTimeout},
 		baseURL:    defaultBaseURL,
 	}
 }
 
+// SetHTTPClient replaces the HTTP client, e.g. to change the timeout or
+// route requests through a proxy.
+func (c *GeminiClient) SetHTTPClient(hc *http.Client) {
+	c.httpClient = hc
+}
+
 // Embed generates an embedding for a single text.
 func (c *GeminiClient) Embed(ctx context.Context, text string) ([]float32, error) {
 	if text == "" {
@@ -210,19 +219,7 @@ func (c *GeminiClient) doRequest(ctx context.Context, url string, body interface
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		var apiErr apiError
-		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
-			return &APIError{
-				StatusCode: resp.StatusCode,
-				Code:       apiErr.Error.Code,
-				Message:    apiErr.Error.Message,
-				Status:     apiErr.Error.Status,
-			}
-		}
-		return &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    string(respBody),
-		}
+		return parseAPIError(resp.StatusCode, respBody)
 	}
 
 	if err := json.Unmarshal(respBody, result); err != nil {
@@ -232,6 +229,54 @@ func (c *GeminiClient) doRequest(ctx context.Context, url string, body interface
 	return nil
 }
 
+// Ping checks that the API is reachable and the key is accepted by fetching
+// the configured model's metadata. It does not consume embedding quota.
+func (c *GeminiClient) Ping(ctx context.Context) error {
+	url := fmt.Sprintf("%s/models/%s?key=%s", c.baseURL, c.model, c.apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return parseAPIError(resp.StatusCode, body)
+	}
+	return nil
+}
+
+// Model returns the name of the embedding model.
+func (c *GeminiClient) Model() string {
+	return c.model
+}
+
+// RateLimit returns the configured request rate limit in requests/minute.
+func (c *GeminiClient) RateLimit() int {
+	return c.rateLimit
+}
+
+func parseAPIError(statusCode int, body []byte) *APIError {
+	var apiErr apiError
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		return &APIError{
+			StatusCode: statusCode,
+			Code:       apiErr.Error.Code,
+			Message:    apiErr.Error.Message,
+			Status:     apiErr.Error.Status,
+		}
+	}
+	return &APIError{
+		StatusCode: statusCode,
+		Message:    string(body),
+	}
+}
+
 // APIError represents an error from the Gemini API.
 type APIError struct {
 	StatusCode int
@@ -259,3 +304,20 @@ func (e *APIError) IsRetryable() bool {
 	}
 	return false
 }
+
+// IsAuthError returns true if the API rejected the credentials.
+func (e *APIError) IsAuthError() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	case http.StatusBadRequest:
+		// Gemini reports invalid keys as 400 INVALID_ARGUMENT
+		return strings.Contains(e.Message, "API key")
+	}
+	return false
+}
+
+// IsQuotaError returns true if the request was rejected for rate or quota limits.
+func (e *APIError) IsQuotaError() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
@@ -431,3 +431,53 @@ func TestEmbed_APIKeyInHeader(t *testing.T) {
 		t.Errorf("expected API key 'my-secret-key' in query param, got '%s'", receivedAPIKey)
 	}
 }
+
+func TestPing_Success(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if r.Method != http.MethodGet {
+			t.Errorf("expected GET, got %s", r.Method)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"name":"models/gemini-embedding-001"}`))
+	}))
+	defer server.Close()
+
+	client := NewGeminiClient("test-api-key", "gemini-embedding-001", 60)
+	client.baseURL = server.URL
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if gotPath != "/models/gemini-embedding-001" {
+		t.Errorf("expected model metadata path, got %s", gotPath)
+	}
+}
+
+func TestPing_InvalidAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		resp := mockErrorResponse{}
+		resp.Error.Code = 400
+		resp.Error.Message = "API key not valid. Please pass a valid API key."
+		resp.Error.Status = "INVALID_ARGUMENT"
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewGeminiClient("bad-key", "", 60)
+	client.baseURL = server.URL
+
+	err := client.Ping(context.Background())
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if !apiErr.IsAuthError() {
+		t.Error("expected invalid key to be an auth error")
+	}
+	if apiErr.IsQuotaError() {
+		t.Error("expected invalid key not to be a quota error")
+	}
+}
@@ -0,0 +1,84 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// summaryPrompt asks for a summary phrased the way someone searching for
+// the code would put it
+const summaryPrompt = `Summarize in one sentence what the following %s code does, in plain words someone searching a codebase would use. Reply with the sentence only.
+
+%s`
+
+// maxSummaryTokens bounds the length of a summary
+const maxSummaryTokens = 96
+
+type part struct {
+	Text string `json:"text"`
+}
+
+type generateRequest struct {
+	Contents []struct {
+		Parts []part `json:"parts"`
+	} `json:"contents"`
+	GenerationConfig struct {
+		Temperature     float64 `json:"temperature"`
+		MaxOutputTokens int     `json:"maxOutputTokens"`
+	} `json:"generationConfig"`
+}
+
+type generateResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []part `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+}
+
+// SetSummaryModel sets the generative model Summarize uses, e.g.
+// gemini-2.0-flash.
+func (c *GeminiClient) SetSummaryModel(model string) {
+	c.summaryModel = model
+}
+
+// Summarize asks the summary model for a one-sentence summary of a chunk
+// of code in the given language. Requests share the client's rate limit
+// with embeddings.
+func (c *GeminiClient) Summarize(ctx context.Context, language, content string) (string, error) {
+	if c.summaryModel == "" {
+		return "", errors.New("no summary model set")
+	}
+	if content == "" {
+		return "", errors.New("content cannot be empty")
+	}
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		return "", fmt.Errorf("rate limiter error: %w", err)
+	}
+
+	req := generateRequest{}
+	req.Contents = make([]struct {
+		Parts []part `json:"parts"`
+	}, 1)
+	req.Contents[0].Parts = []part{{Text: fmt.Sprintf(summaryPrompt, language, content)}}
+	req.GenerationConfig.MaxOutputTokens = maxSummaryTokens
+
+	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", c.baseURL, c.summaryModel, c.apiKey)
+
+	var resp generateResponse
+	if err := c.doRequestWithRetry(ctx, url, req, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Candidates) == 0 {
+		return "", errors.New("no summary returned")
+	}
+
+	var sb strings.Builder
+	for _, p := range resp.Candidates[0].Content.Parts {
+		sb.WriteString(p.Text)
+	}
+	return strings.Join(strings.Fields(sb.String()), " "), nil
+}
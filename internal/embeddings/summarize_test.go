@@ -0,0 +1,61 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSummarize_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/models/gemini-2.0-flash:generateContent") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req generateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Contents) != 1 {
+			t.Fatalf("unexpected request: %v", err)
+		}
+		if prompt := req.Contents[0].Parts[0].Text; !strings.Contains(prompt, "go code") || !strings.Contains(prompt, "func retry()") {
+			t.Errorf("expected the prompt to hold the language and code, got %q", prompt)
+		}
+		w.Write([]byte(`{"candidates": [{"content": {"parts": [{"text": "Retries a request\n with backoff.\n"}]}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewGeminiClient("test-api-key", "", 60)
+	client.baseURL = server.URL
+	client.SetSummaryModel("gemini-2.0-flash")
+
+	summary, err := client.Summarize(context.Background(), "go", "func retry() {}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary != "Retries a request with backoff." {
+		t.Errorf("unexpected summary %q", summary)
+	}
+}
+
+func TestSummarize_NoModel(t *testing.T) {
+	client := NewGeminiClient("test-api-key", "", 60)
+	if _, err := client.Summarize(context.Background(), "go", "func retry() {}"); err == nil {
+		t.Error("expected an error without a summary model")
+	}
+}
+
+func TestSummarize_NoCandidates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"candidates": []}`))
+	}))
+	defer server.Close()
+
+	client := NewGeminiClient("test-api-key", "", 60)
+	client.baseURL = server.URL
+	client.SetSummaryModel("gemini-2.0-flash")
+
+	if _, err := client.Summarize(context.Background(), "go", "func retry() {}"); err == nil {
+		t.Error("expected an error when no summary is returned")
+	}
+}
@@ -0,0 +1,210 @@
+// Package eval measures retrieval quality: it runs labeled queries against
+// the index and scores how high the files expected for each rank, so
+// chunking and embedding model changes can be compared on the same cases.
+package eval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultK is how many files of each query's results are scored when the
+// cases file doesn't say.
+const DefaultK = 10
+
+// Case is a query and the files a good answer to it comes from.
+type Case struct {
+	Query string `yaml:"query" json:"query"`
+	// Expected are the files, absolute or as path suffixes such as
+	// internal/indexer/indexer.go, that should rank high
+	Expected []string `yaml:"expected" json:"expected"`
+}
+
+// Suite is a cases file:
+//
+//	k: 10
+//	cases:
+//	  - query: where are unchanged chunks skipped
+//	    expected: [internal/indexer/indexer.go]
+type Suite struct {
+	K     int    `yaml:"k,omitempty"`
+	Cases []Case `yaml:"cases"`
+}
+
+// Load reads and validates the cases file at path.
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var s Suite
+	if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(s.Cases) == 0 {
+		return nil, fmt.Errorf("%s has no cases", path)
+	}
+	if s.K < 0 {
+		return nil, fmt.Errorf("%s: k must be positive, got %d", path, s.K)
+	}
+	var errs []error
+	for i, c := range s.Cases {
+		if strings.TrimSpace(c.Query) == "" {
+			errs = append(errs, fmt.Errorf("case %d: query is required", i+1))
+		}
+		if len(c.Expected) == 0 {
+			errs = append(errs, fmt.Errorf("case %d: expected files are required", i+1))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Searcher returns the files of a query's best matching chunks, best
+// first. Files may repeat.
+type Searcher interface {
+	Search(ctx context.Context, query string, limit int) ([]string, error)
+}
+
+// CaseResult is how a case scored.
+type CaseResult struct {
+	Query string `json:"query"`
+	// Ranks holds the 1-based rank of each expected file among the
+	// query's top k files, 0 if it wasn't among them
+	Ranks  []int    `json:"ranks"`
+	Recall float64  `json:"recall"`          // share of expected files in the top k
+	RR     float64  `json:"reciprocal_rank"` // 1 / rank of the first expected file, 0 if none
+	Top    []string `json:"top"`             // the top k files
+	Error  string   `json:"error,omitempty"`
+}
+
+// Report is how a suite scored: the mean recall@k and reciprocal rank
+// over its cases. Cases whose search failed score 0.
+type Report struct {
+	K      int          `json:"k"`
+	Recall float64      `json:"recall_at_k"`
+	MRR    float64      `json:"mrr"`
+	Failed int          `json:"failed"`
+	Cases  []CaseResult `json:"cases"`
+}
+
+// chunksPerFile is how many chunks are fetched per file scored, as a
+// query's best chunks often come from the same few files
+const chunksPerFile = 5
+
+// maxChunks is the most chunks Typesense returns per page
+const maxChunks = 250
+
+// Run scores every case of s against searcher.
+func Run(ctx context.Context, searcher Searcher, s *Suite) (*Report, error) {
+	k := s.K
+	if k == 0 {
+		k = DefaultK
+	}
+	report := &Report{K: k}
+	for _, c := range s.Cases {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r := CaseResult{Query: c.Query, Ranks: make([]int, len(c.Expected))}
+		files, err := searcher.Search(ctx, c.Query, min(k*chunksPerFile, maxChunks))
+		if err != nil {
+			r.Error = err.Error()
+			report.Failed++
+		}
+		r.Top = topFiles(files, k)
+		first := 0
+		found := 0
+		for i, want := range c.Expected {
+			for rank, file := range r.Top {
+				if matches(file, want) {
+					r.Ranks[i] = rank + 1
+					found++
+					if first == 0 || rank+1 < first {
+						first = rank + 1
+					}
+					break
+				}
+			}
+		}
+		r.Recall = float64(found) / float64(len(c.Expected))
+		if first > 0 {
+			r.RR = 1 / float64(first)
+		}
+		report.Recall += r.Recall
+		report.MRR += r.RR
+		report.Cases = append(report.Cases, r)
+	}
+	report.Recall /= float64(len(report.Cases))
+	report.MRR /= float64(len(report.Cases))
+	return report, nil
+}
+
+// topFiles returns the first k distinct files
+func topFiles(files []string, k int) []string {
+	seen := map[string]bool{}
+	var top []string
+	for _, f := range files {
+		if len(top) == k {
+			break
+		}
+		if !seen[f] {
+			seen[f] = true
+			top = append(top, f)
+		}
+	}
+	return top
+}
+
+// matches reports whether file is the expected file want: the same path,
+// or one ending in want at a path separator
+func matches(file, want string) bool {
+	file, want = filepath.ToSlash(file), strings.TrimPrefix(filepath.ToSlash(want), "./")
+	return file == want || strings.HasSuffix(file, "/"+want)
+}
+
+// WriteTable prints the report as a table of cases followed by the totals.
+func WriteTable(w io.Writer, r *Report) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "QUERY\tRECALL@%d\tRR\tRANKS\n", r.K)
+	for _, c := range r.Cases {
+		ranks := make([]string, len(c.Ranks))
+		for i, rank := range c.Ranks {
+			ranks[i] = "-"
+			if rank > 0 {
+				ranks[i] = fmt.Sprint(rank)
+			}
+		}
+		status := strings.Join(ranks, ",")
+		if c.Error != "" {
+			status = "error: " + c.Error
+		}
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%s\n", shorten(c.Query, 60), c.Recall, c.RR, status)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d cases  recall@%d %.3f  MRR %.3f\n", len(r.Cases), r.K, r.Recall, r.MRR)
+	if r.Failed > 0 {
+		fmt.Fprintf(w, "%d searches failed and scored 0\n", r.Failed)
+	}
+}
+
+func shorten(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
@@ -0,0 +1,108 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeSearcher map[string][]string // files by query
+
+func (f fakeSearcher) Search(ctx context.Context, query string, limit int) ([]string, error) {
+	files, ok := f[query]
+	if !ok {
+		return nil, errors.New("unavailable")
+	}
+	return files, nil
+}
+
+func writeCases(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "eval.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	s, err := Load(writeCases(t, "k: 5\ncases:\n  - query: hashing\n    expected: [a.go, b.go]\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if s.K != 5 || len(s.Cases) != 1 || len(s.Cases[0].Expected) != 2 {
+		t.Errorf("unexpected suite %+v", s)
+	}
+
+	for name, content := range map[string]string{
+		"empty":         "",
+		"no expected":   "cases:\n  - query: hashing\n",
+		"no query":      "cases:\n  - expected: [a.go]\n",
+		"unknown field": "cases:\n  - query: q\n    expect: [a.go]\n",
+		"negative k":    "k: -1\ncases:\n  - query: q\n    expected: [a.go]\n",
+	} {
+		if _, err := Load(writeCases(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	searcher := fakeSearcher{
+		// Chunks of a file repeat; the expected files rank 2nd and 3rd
+		"hashing": {"/repo/x.go", "/repo/x.go", "/repo/internal/a.go", "/repo/b.go"},
+		"nothing": {"/repo/x.go"},
+	}
+	s := &Suite{K: 2, Cases: []Case{
+		{Query: "hashing", Expected: []string{"internal/a.go", "b.go"}},
+		{Query: "nothing", Expected: []string{"a.go"}},
+		{Query: "down", Expected: []string{"a.go"}},
+	}}
+	r, err := Run(context.Background(), searcher, s)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	first := r.Cases[0]
+	if first.Ranks[0] != 2 || first.Ranks[1] != 0 || first.Recall != 0.5 || first.RR != 0.5 {
+		t.Errorf("unexpected result %+v", first)
+	}
+	if r.Cases[1].Recall != 0 || r.Cases[1].RR != 0 {
+		t.Errorf("expected a miss, got %+v", r.Cases[1])
+	}
+	if r.Failed != 1 || r.Cases[2].Error == "" {
+		t.Errorf("expected the failed search reported, got %+v", r.Cases[2])
+	}
+	if r.Recall != 0.5/3 || r.MRR != 0.5/3 {
+		t.Errorf("expected recall and MRR of %.3f, got %.3f and %.3f", 0.5/3, r.Recall, r.MRR)
+	}
+
+	var buf bytes.Buffer
+	WriteTable(&buf, r)
+	for _, want := range []string{"RECALL@2", "2,-", "error: unavailable", "recall@2 0.167", "MRR 0.167", "1 searches failed"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		file, want string
+		match      bool
+	}{
+		{"/repo/internal/a.go", "internal/a.go", true},
+		{"/repo/internal/a.go", "/repo/internal/a.go", true},
+		{"/repo/internal/a.go", "./internal/a.go", true},
+		{"/repo/internal/data.go", "a.go", false},
+	}
+	for _, tt := range tests {
+		if got := matches(tt.file, tt.want); got != tt.match {
+			t.Errorf("matches(%q, %q) = %v, want %v", tt.file, tt.want, got, tt.match)
+		}
+	}
+}
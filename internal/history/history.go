@@ -0,0 +1,204 @@
+// Package history reads a git repository's commits, with the hunks of
+// their diffs, so they can be indexed next to the code they changed.
+package history
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Separators in the git log format: a record per commit, fields within it
+const (
+	recordSep = "\x1e"
+	fieldSep  = "\x1f"
+)
+
+// logFormat prints hash, author, author time and message, followed by the
+// patch git appends
+const logFormat = recordSep + "%H" + fieldSep + "%an" + fieldSep + "%at" + fieldSep + "%B" + fieldSep
+
+// ErrNotRepository is returned for a directory outside any git work tree.
+var ErrNotRepository = errors.New("not a git repository")
+
+// Commit is one commit touching the directory it was read from.
+type Commit struct {
+	Hash    string
+	Author  string
+	Date    time.Time
+	Message string // subject and body
+	Files   []File
+}
+
+// Subject returns the first line of the commit message.
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
+
+// File is a file changed by a commit, relative to the directory the log
+// was read from.
+type File struct {
+	Path   string
+	Binary bool
+	Hunks  []Hunk
+}
+
+// Hunk is one hunk of a file's diff.
+type Hunk struct {
+	Header    string // e.g. "@@ -10,6 +10,8 @@ func main() {"
+	StartLine int    // first line of the hunk in the new file
+	Content   string // the hunk's lines, with their +, - and space prefixes
+}
+
+// Log returns the commits that changed dir, newest first, with their
+// diffs limited to dir. since, if set, is the newest commit already read:
+// only commits after it are returned. limit bounds how many commits are
+// read; 0 reads all.
+func Log(ctx context.Context, dir, since string, limit int) ([]Commit, error) {
+	if err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--is-inside-work-tree").Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrNotRepository
+	}
+
+	args := []string{"-C", dir, "log", "--format=" + logFormat, "--patch", "--relative",
+		"--no-color", "--no-ext-diff", "--no-renames", "--unified=3"}
+	if limit > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(limit))
+	}
+	rev := "HEAD"
+	if since != "" {
+		rev = since + "..HEAD"
+	}
+	args = append(args, rev, "--", ".")
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("git log: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseLog(string(out))
+}
+
+// Head returns the hash of dir's HEAD commit, or "" for a repository
+// without commits.
+func Head(ctx context.Context, dir string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--verify", "--quiet", "HEAD").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", nil
+		}
+		return "", fmt.Errorf("git rev-parse: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsAncestor reports whether commit is an ancestor of dir's HEAD, so a
+// log since it is meaningful; it isn't after a history rewrite.
+func IsAncestor(ctx context.Context, dir, commit string) bool {
+	return exec.CommandContext(ctx, "git", "-C", dir, "merge-base", "--is-ancestor", commit, "HEAD").Run() == nil
+}
+
+// parseLog parses the output of git log with logFormat and --patch
+func parseLog(out string) ([]Commit, error) {
+	var commits []Commit
+	for _, record := range strings.Split(out, recordSep) {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		fields := strings.SplitN(record, fieldSep, 5)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected git log record %.40q", record)
+		}
+		at, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: bad time %q", fields[0], fields[2])
+		}
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    time.Unix(at, 0).UTC(),
+			Message: strings.TrimSpace(fields[3]),
+			Files:   parsePatch(fields[4]),
+		})
+	}
+	return commits, nil
+}
+
+// parsePatch splits a patch into files and hunks
+func parsePatch(patch string) []File {
+	var files []File
+	var file *File
+	var hunk *Hunk
+	var lines []string
+
+	endHunk := func() {
+		if hunk != nil {
+			hunk.Content = strings.Join(lines, "\n")
+			file.Hunks = append(file.Hunks, *hunk)
+		}
+		hunk, lines = nil, nil
+	}
+
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			endHunk()
+			files = append(files, File{Path: diffPath(line)})
+			file = &files[len(files)-1]
+		case file == nil:
+			continue
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			if p := strings.TrimPrefix(line, "+++ "); p != "/dev/null" {
+				file.Path = strings.TrimPrefix(p, "b/")
+			}
+		case hunk == nil && strings.HasPrefix(line, "Binary files "):
+			file.Binary = true
+		case strings.HasPrefix(line, "@@ "):
+			endHunk()
+			hunk = &Hunk{Header: line, StartLine: hunkStart(line)}
+		case hunk != nil && line != "" && strings.ContainsRune(" +-\\", rune(line[0])):
+			lines = append(lines, line)
+		}
+	}
+	endHunk()
+	return files
+}
+
+// diffPath returns the path of a "diff --git a/x b/x" line, used until the
+// +++ line gives it unambiguously
+func diffPath(line string) string {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if i := strings.LastIndex(rest, " b/"); i >= 0 {
+		return rest[i+len(" b/"):]
+	}
+	return rest
+}
+
+// hunkStart returns the first new-file line of a hunk header such as
+// "@@ -10,6 +12,8 @@"
+func hunkStart(header string) int {
+	_, after, ok := strings.Cut(header, " +")
+	if !ok {
+		return 0
+	}
+	end := strings.IndexAny(after, ", ")
+	if end < 0 {
+		end = len(after)
+	}
+	n, _ := strconv.Atoi(after[:end])
+	return n
+}
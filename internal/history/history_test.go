@@ -0,0 +1,125 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// git runs git in dir, failing the test on error
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Ada", "GIT_AUTHOR_EMAIL=ada@example.com",
+		"GIT_COMMITTER_NAME=Ada", "GIT_COMMITTER_EMAIL=ada@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// testRepo creates a repository with two commits
+func testRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "--quiet")
+
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0644)
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "--quiet", "-m", "Add main")
+
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tretry()\n}\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "logo.png"), []byte{0x89, 'P', 'N', 'G', 0, 0, 1}, 0644)
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "--quiet", "-m", "Retry on startup\n\nThe server isn't always up yet.")
+	return dir
+}
+
+func TestLog(t *testing.T) {
+	dir := testRepo(t)
+
+	commits, err := Log(context.Background(), dir, "", 0)
+	if err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(commits))
+	}
+
+	c := commits[0]
+	if c.Subject() != "Retry on startup" || !strings.Contains(c.Message, "isn't always up") {
+		t.Errorf("unexpected message %q", c.Message)
+	}
+	if c.Author != "Ada" || c.Date.IsZero() || len(c.Hash) != 40 {
+		t.Errorf("unexpected commit %+v", c)
+	}
+	if len(c.Files) != 2 {
+		t.Fatalf("expected 2 files, got %+v", c.Files)
+	}
+	byPath := map[string]File{}
+	for _, f := range c.Files {
+		byPath[f.Path] = f
+	}
+	if !byPath["logo.png"].Binary {
+		t.Errorf("expected logo.png to be binary")
+	}
+	hunks := byPath["main.go"].Hunks
+	if len(hunks) != 1 || hunks[0].StartLine != 1 || !strings.Contains(hunks[0].Content, "+\tretry()") {
+		t.Errorf("unexpected hunks %+v", hunks)
+	}
+}
+
+func TestLog_Since(t *testing.T) {
+	dir := testRepo(t)
+	first := git(t, dir, "rev-parse", "HEAD~1")
+
+	commits, err := Log(context.Background(), dir, first, 0)
+	if err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Subject() != "Retry on startup" {
+		t.Errorf("expected only the second commit, got %+v", commits)
+	}
+
+	head, err := Head(context.Background(), dir)
+	if err != nil || head == "" {
+		t.Fatalf("Head failed: %q %v", head, err)
+	}
+	if !IsAncestor(context.Background(), dir, first) {
+		t.Errorf("expected the first commit to be an ancestor of HEAD")
+	}
+}
+
+func TestLog_NotRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	_, err := Log(context.Background(), t.TempDir(), "", 0)
+	if !errors.Is(err, ErrNotRepository) {
+		t.Errorf("expected ErrNotRepository, got %v", err)
+	}
+}
+
+func TestHunkStart(t *testing.T) {
+	tests := map[string]int{
+		"@@ -10,6 +12,8 @@ func main() {": 12,
+		"@@ -1 +1 @@":                     1,
+		"@@ -0,0 +1,3 @@":                 1,
+		"garbage":                         0,
+	}
+	for header, want := range tests {
+		if got := hunkStart(header); got != want {
+			t.Errorf("hunkStart(%q) = %d, want %d", header, got, want)
+		}
+	}
+}
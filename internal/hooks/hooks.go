@@ -0,0 +1,58 @@
+// Package hooks runs the external commands configured to transform or
+// veto what the indexing pipeline indexes. A hook reads a JSON request on
+// stdin and writes a JSON response to stdout; anything it writes to
+// stderr is reported when it fails.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+)
+
+// maxStderr bounds how much of a failing hook's stderr is reported
+const maxStderr = 1 << 10
+
+// Run runs h with req encoded as JSON on its stdin and decodes its stdout
+// into resp. The hook fails if it exits non-zero, outlives its timeout or
+// writes something other than a JSON object. SWARM_INDEXER_HOOK_STAGE is
+// set to h's stage, so one program can serve several stages.
+func Run(ctx context.Context, h config.Hook, req, resp any) error {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.TimeoutDuration())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Env = append(os.Environ(), "SWARM_INDEXER_HOOK_STAGE="+h.Stage)
+	cmd.Stdin = bytes.NewReader(in)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("hook %s timed out after %s", h.Name(), h.TimeoutDuration())
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			if len(msg) > maxStderr {
+				msg = msg[:maxStderr] + "..."
+			}
+			return fmt.Errorf("hook %s: %w: %s", h.Name(), err, msg)
+		}
+		return fmt.Errorf("hook %s: %w", h.Name(), err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return fmt.Errorf("hook %s: invalid response: %w", h.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package hooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+)
+
+type message struct {
+	Stage   string `json:"stage"`
+	Content string `json:"content"`
+}
+
+func shell(script string) config.Hook {
+	return config.Hook{Stage: config.HookPreChunk, Command: []string{"sh", "-c", script}}
+}
+
+func TestRun(t *testing.T) {
+	h := shell(`sed "s/secret/[PII]/; s/\"stage\":\"[a-z-]*\"/\"stage\":\"$SWARM_INDEXER_HOOK_STAGE\"/"`)
+	var resp message
+	if err := Run(context.Background(), h, message{Stage: "x", Content: "a secret"}, &resp); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if resp.Content != "a [PII]" || resp.Stage != config.HookPreChunk {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestRun_Failures(t *testing.T) {
+	for name, tc := range map[string]struct {
+		hook config.Hook
+		want string
+	}{
+		"exit status": {shell("echo 'license check failed' >&2; exit 3"), "license check failed"},
+		"not JSON":    {shell("echo ok"), "invalid response"},
+		"missing":     {config.Hook{Stage: config.HookPreChunk, Command: []string{"/nonexistent/hook"}}, "hook hook"},
+		"timed out":   {config.Hook{Stage: config.HookPreChunk, Command: []string{"sleep", "5"}, Timeout: "50ms"}, "timed out"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var resp message
+			err := Run(context.Background(), tc.hook, message{}, &resp)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
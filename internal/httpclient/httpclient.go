@@ -0,0 +1,26 @@
+// Package httpclient builds the HTTP clients used to talk to Typesense and
+// Gemini, with a request timeout and proxy support for corporate networks.
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// New returns a client whose requests time out after timeout (zero means
+// no timeout) and go through proxy. With an empty proxy, HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY from the environment are honoured.
+func New(timeout time.Duration, proxy string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
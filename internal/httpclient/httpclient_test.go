@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNew_UsesProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	client, err := New(5*time.Second, proxy.URL)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	resp, err := client.Get("http://typesense.internal:8108/health")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if proxied != "http://typesense.internal:8108/health" {
+		t.Errorf("expected request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestNew_EnvironmentProxy(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://proxy.example:3128")
+	t.Setenv("NO_PROXY", "")
+
+	client, err := New(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://typesense.internal:8108", nil)
+	u, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u == nil || u.Host != "proxy.example:3128" {
+		t.Errorf("expected HTTP_PROXY to be honoured, got %v", u)
+	}
+}
+
+func TestNew_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client, err := New(20*time.Millisecond, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("expected timeout error")
+	}
+}
+
+func TestNew_InvalidProxy(t *testing.T) {
+	if _, err := New(time.Second, "not a url"); err == nil {
+		t.Error("expected error for invalid proxy")
+	}
+}
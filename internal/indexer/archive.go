@@ -0,0 +1,89 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dvaida/swarm-indexer/internal/archive"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+)
+
+// IndexArchives indexes the contents of .zip and .tar.gz archives. Each is
+// unpacked into the data dir within archive.MaxSize and archive.MaxFiles
+// and indexed like a directory, with the archive's path as the project
+// path and file paths like release.zip!/docs/readme.md. The unpacked files
+// are removed afterwards. Nothing is re-indexed while an archive is
+// unchanged and its last run indexed every file, and only changed files
+// are when it changes.
+func (idx *Indexer) IndexArchives(ctx context.Context, paths []string) error {
+	var errs []error
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := idx.indexArchive(ctx, path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (idx *Indexer) indexArchive(ctx context.Context, path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	hash, err := metadata.HashFile(abs)
+	if err != nil {
+		return err
+	}
+	dir, err := archive.Dir(abs)
+	if err != nil {
+		return err
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	if meta.Commit == hash {
+		idx.logger.Info("archive unchanged since last index, skipping", "project", abs)
+		return nil
+	}
+
+	// Start from an empty directory, and leave none behind
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := archive.Extract(abs, dir); err != nil {
+		return fmt.Errorf("unpacking archive: %w", err)
+	}
+
+	idx.logger.Info("indexing archive", "project", abs)
+	idx.mounts[dir] = mount{project: abs, prefix: archive.Prefix(abs)}
+	defer delete(idx.mounts, dir)
+	unfinished := idx.unfinished()
+	if err := idx.indexPath(ctx, dir); err != nil {
+		return err
+	}
+	if idx.unfinished() > unfinished {
+		// The next run of this archive must pick up what this one left
+		idx.logger.Info("files left to index, not recording the archive", "project", abs)
+		return nil
+	}
+
+	// indexPath saved the archive's files; record the archive they are from
+	if meta, err = metadata.Load(dir); err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	meta.Commit = hash
+	if err := meta.Save(dir); err != nil {
+		return fmt.Errorf("saving metadata: %w", err)
+	}
+	return nil
+}
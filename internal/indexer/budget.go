@@ -0,0 +1,68 @@
+package indexer
+
+import (
+	"sort"
+
+	"github.com/dvaida/swarm-indexer/internal/usage"
+)
+
+// Remaining lists the files of a project a run left unprocessed because
+// it reached its embedding budget. They keep the state recorded before
+// the run, so the next run picks them up.
+type Remaining struct {
+	Project string   `json:"project"`
+	Files   []string `json:"files"` // relative to Project
+}
+
+// Remaining returns the files left unprocessed by reaching the embedding
+// budget, by project in the order they were indexed.
+func (idx *Indexer) Remaining() []Remaining {
+	idx.remainingMu.Lock()
+	defer idx.remainingMu.Unlock()
+	return append([]Remaining(nil), idx.remaining...)
+}
+
+// unfinished counts the files the run has failed or left to the next run
+// so far. A project indexed while it grew has files to retry, so it isn't
+// recorded as done.
+func (idx *Indexer) unfinished() int {
+	n := idx.FailedFiles()
+	for _, r := range idx.Remaining() {
+		n += len(r.Files)
+	}
+	return n
+}
+
+// overBudget reports whether the chunks queued for embedding so far have
+// used up the embedding budget, if there is one
+func (idx *Indexer) overBudget() bool {
+	return idx.budget > 0 && idx.queued.Load() >= idx.budget
+}
+
+// queueTokens counts the estimated tokens of chunks queued for embedding
+// against the budget. They are counted when queued rather than embedded,
+// so the files processed while a batch fills don't overshoot it.
+func (idx *Indexer) queueTokens(chunks []IndexedChunk) {
+	if idx.budget <= 0 {
+		return
+	}
+	var tokens int64
+	for _, c := range chunks {
+		tokens += usage.EstimateTokens(embedText(c))
+	}
+	idx.queued.Add(tokens)
+}
+
+// recordRemaining records the files of the project at root, relative to
+// it, that were left unprocessed
+func (idx *Indexer) recordRemaining(root string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+	files = idx.filePaths(root, files)
+	sort.Strings(files)
+
+	idx.remainingMu.Lock()
+	defer idx.remainingMu.Unlock()
+	idx.remaining = append(idx.remaining, Remaining{Project: idx.projectPath(root), Files: files})
+}
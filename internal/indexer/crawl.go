@@ -0,0 +1,43 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dvaida/swarm-indexer/internal/crawl"
+	"github.com/dvaida/swarm-indexer/internal/source"
+)
+
+// IndexSites crawls each site from its URL within opts' bounds (see
+// crawl.Crawl) and indexes the pages found as markdown, with the site's
+// URL as their project path and each page's URL as its file path. Only
+// pages whose text changed since the last crawl are re-indexed, and pages
+// no longer found are removed. Pages that couldn't be fetched are reported
+// as failures and keep their documents until they can be.
+func (idx *Indexer) IndexSites(ctx context.Context, client *http.Client, sites []string, opts crawl.Options) error {
+	var errs []error
+	for _, site := range sites {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := idx.indexSite(ctx, client, site, opts); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", site, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (idx *Indexer) indexSite(ctx context.Context, client *http.Client, site string, opts crawl.Options) error {
+	idx.logger.Info("crawling site", "project", site, "depth", opts.Depth)
+	pages, err := crawl.Crawl(ctx, client, site, opts)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		return errors.New("no HTML page found")
+	}
+	return idx.IndexSource(ctx, source.NewPages(site, pages))
+}
@@ -0,0 +1,498 @@
+package indexer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/progress"
+	"github.com/dvaida/swarm-indexer/internal/queue"
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+	"github.com/dvaida/swarm-indexer/internal/tracing"
+	"github.com/dvaida/swarm-indexer/internal/usage"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// defaultResultTimeout is how long a distributed run waits for the
+	// next result before giving up on the files no worker reported on
+	defaultResultTimeout = 10 * time.Minute
+	// workPoll is how long a worker waits for a job, and a coordinator
+	// for a result, before checking whether to stop
+	workPoll = time.Second
+	// defaultMaxQueuedJobs bounds the files a distributed run has queued
+	// and not heard back about
+	defaultMaxQueuedJobs = 1000
+)
+
+// SetQueue makes runs hand the files they index to workers through q
+// instead of processing them here (see Work). Remote repositories and
+// archives are still processed here, as only this machine has them.
+func (idx *Indexer) SetQueue(q queue.Queue) {
+	idx.queue = q
+}
+
+// distributeFiles is indexFiles for a run whose files are processed by
+// workers: it queues a job per file and collects the workers' results.
+// At most idx.maxQueuedJobs files are queued and not yet reported on, so
+// a large run doesn't flood the queue ahead of the workers; the next file
+// is queued as each result comes in. Files no worker reports on within
+// idx.resultTimeout of the last result are failed, so the next run
+// retries them. The workers' stage durations aren't reported back, so
+// rep only counts files and chunks.
+func (idx *Indexer) distributeFiles(ctx context.Context, root string, files []string, prev map[string]map[string]string, rep *PathReport) (*filesResult, error) {
+	run, err := newRunID()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]queue.Job, len(files))
+	waiting := make(map[string]bool, len(files))
+	for i, path := range files {
+		jobs[i] = queue.Job{Run: run, Root: root, Path: path, Prev: prev[path], Tags: idx.projectTags[root]}
+		waiting[path] = true
+	}
+
+	idx.logger.Info("distributing files", "project", root, "files", len(files), "run", run)
+
+	p := idx.progress
+	if p == nil {
+		p = progress.NewLog(idx.logger, progress.DefaultLogInterval)
+	}
+	p.Start(root, len(files))
+	defer p.Finish()
+
+	queued := min(idx.maxQueuedJobs, len(jobs)) // jobs[:queued] are pushed
+	if err := idx.queue.Push(ctx, jobs[:queued]); err != nil {
+		return nil, fmt.Errorf("queueing files: %w", err)
+	}
+	p.QueueDepth("jobs", queued, idx.maxQueuedJobs)
+
+	res := &filesResult{languages: map[string]string{}, chunks: map[string]map[string]string{}}
+	skipped := rep.Skipped // by reason
+	var reset []string     // reconciled files that failed
+	var chunks int64
+	timeout := idx.resultTimeout
+	if timeout <= 0 {
+		timeout = defaultResultTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for len(waiting) > 0 && time.Now().Before(deadline) {
+		r, err := idx.queue.Result(ctx, run, workPoll)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("collecting results: %w", err)
+		}
+		if r == nil || !waiting[r.Path] {
+			continue
+		}
+		delete(waiting, r.Path)
+		deadline = time.Now().Add(timeout)
+		if queued < len(jobs) {
+			if err := idx.queue.Push(ctx, jobs[queued:queued+1]); err != nil {
+				return nil, fmt.Errorf("queueing files: %w", err)
+			}
+			queued++
+		}
+		p.QueueDepth("jobs", queued-(len(files)-len(waiting)), idx.maxQueuedJobs)
+
+		var fileErr error
+		if r.Error != "" {
+			fileErr = &opError{op: r.Op, err: errors.New(r.Error)}
+		}
+		p.FileStarted(r.Path)
+		rep.Processed++
+		switch {
+		case fileErr != nil:
+			res.failed = append(res.failed, r.Path)
+			idx.recordFailure(root, r.Path, fileErr)
+			idx.logger.Warn("error processing file", "project", root, "file", r.Path, "err", fileErr)
+			if prev[r.Path] != nil {
+				reset = append(reset, r.Path)
+			}
+		case r.SkipReason != "":
+			res.chunks[r.Path] = r.Chunks
+			rel, _ := filepath.Rel(root, r.Path)
+			idx.skip(rep, rel, r.SkipReason)
+		default:
+			res.chunks[r.Path] = r.Chunks
+			res.languages[r.Path] = r.Language
+			idx.indexed.Add(1)
+			rep.Indexed++
+			rep.Chunks += len(r.Chunks)
+		}
+		p.FileDone(r.Path, fileErr)
+
+		idx.usageMu.Lock()
+		idx.usage.Add(r.Usage)
+		idx.usageMu.Unlock()
+		if r.Usage.Chunks > 0 {
+			chunks += r.Usage.Chunks
+			p.ChunksEmbedded(int(r.Usage.Chunks))
+		}
+	}
+
+	for i, path := range files {
+		if !waiting[path] {
+			continue
+		}
+		err := fmt.Errorf("no worker reported on the file within %s", timeout)
+		if i >= queued {
+			err = fmt.Errorf("not queued: no worker reported on the queued files within %s", timeout)
+		}
+		res.failed = append(res.failed, path)
+		idx.recordFailure(root, path, err)
+		if prev[path] != nil {
+			reset = append(reset, path)
+		}
+	}
+	rep.Failed = len(res.failed)
+	rep.Embedded = int(chunks)
+	if len(waiting) > 0 {
+		idx.logger.Warn("gave up waiting for workers", "project", root, "files", len(waiting), "run", run)
+	}
+	// Failed files are retried from scratch, so their old chunks go now
+	if len(reset) > 0 {
+		rels := make([]string, 0, len(reset))
+		for _, path := range reset {
+			if rel, err := filepath.Rel(root, path); err == nil {
+				rels = append(rels, rel)
+			}
+		}
+		if _, err := idx.store.DeleteFiles(ctx, idx.projectPath(root), idx.filePaths(root, rels)); err != nil {
+			return nil, fmt.Errorf("deleting documents: %w", err)
+		}
+	}
+
+	idx.logger.Info("done", "project", root, "processed", len(files)-len(waiting), "failed", len(res.failed),
+		"chunks", chunks, skippedAttr(skipped))
+	return res, nil
+}
+
+// newRunID returns a random ID naming a distributed run's results
+func newRunID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Work processes the files that runs on this or other machines hand out
+// through q (see SetQueue) until ctx is done, then finishes the files it
+// has taken. Files are read at the path they were queued with, so every
+// machine must see the projects at the same paths, e.g. on shared storage.
+// A file's result is reported once its chunks are upserted.
+func (idx *Indexer) Work(ctx context.Context, q queue.Queue) {
+	w := &worker{idx: idx, q: q, roots: map[workRoot]*rootState{}, active: map[string]time.Time{}}
+	idx.workMu.Lock()
+	idx.work = w
+	idx.workMu.Unlock()
+	defer func() {
+		idx.workMu.Lock()
+		idx.work = nil
+		idx.workMu.Unlock()
+	}()
+	// Taken files are finished even when ctx is done
+	drainCtx, cancel := drainContext(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < idx.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				job, err := q.Pop(ctx, workPoll)
+				if ctx.Err() != nil {
+					break
+				}
+				if err != nil {
+					idx.logger.Warn("error taking a job", "err", err)
+					time.Sleep(workPoll)
+					continue
+				}
+				if job == nil {
+					// Idle: report what's pending, and forget roots so the
+					// next run's project changes are picked up
+					w.flush(drainCtx)
+					w.forget()
+					continue
+				}
+				if w.process(drainCtx, job) {
+					w.flush(drainCtx)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	w.flush(drainCtx)
+}
+
+// WorkStatus is what Work is doing, for finding out why a worker is stuck.
+type WorkStatus struct {
+	// Active lists the files being chunked, longest running first
+	Active []ActiveFile
+	// PendingFiles are processed and wait for their chunks, of which
+	// there are PendingChunks, to fill a batch; FlushingFiles wait for
+	// their batch to be embedded and upserted
+	PendingFiles  int
+	PendingChunks int
+	FlushingFiles int
+	Reported      int64 // files reported since Work started
+	Roots         int   // project roots loaded
+}
+
+// ActiveFile is a file a worker is chunking, since Since.
+type ActiveFile struct {
+	Path  string
+	Since time.Time
+}
+
+// WorkStatus returns what Work is doing; false if it isn't running.
+func (idx *Indexer) WorkStatus() (WorkStatus, bool) {
+	idx.workMu.Lock()
+	w := idx.work
+	idx.workMu.Unlock()
+	if w == nil {
+		return WorkStatus{}, false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := WorkStatus{
+		PendingFiles:  len(w.pending),
+		PendingChunks: w.pendingChunks,
+		FlushingFiles: w.flushing,
+		Reported:      w.reported,
+		Roots:         len(w.roots),
+	}
+	for path, since := range w.active {
+		st.Active = append(st.Active, ActiveFile{Path: path, Since: since})
+	}
+	sort.Slice(st.Active, func(i, j int) bool { return st.Active[i].Since.Before(st.Active[j].Since) })
+	return st, true
+}
+
+// workRoot identifies a project root of a run
+type workRoot struct {
+	run, root string
+}
+
+// rootState is what a worker loads once per project root of a run
+type rootState struct {
+	once     sync.Once
+	projects projectTree
+	baseline *secrets.Baseline
+	attrs    *walker.Attributes
+	err      error
+}
+
+// workedFile is a processed file awaiting its chunks' upsert
+type workedFile struct {
+	run     string
+	result  queue.Result
+	chunks  []IndexedChunk // new and changed, to embed and upsert
+	moved   []IndexedChunk // unchanged but on other lines
+	removed []string       // IDs of chunks the file no longer has
+}
+
+// worker gathers the chunks of the files its goroutines process into
+// batches and reports the files once their batch is upserted
+type worker struct {
+	idx   *Indexer
+	q     queue.Queue
+	mu    sync.Mutex
+	roots map[workRoot]*rootState
+	// pending holds processed files not yet reported, and pendingChunks
+	// how many chunks they have to upsert
+	pending       []workedFile
+	pendingChunks int
+	flushMu       sync.Mutex
+	// active maps the files being processed to when they were taken;
+	// flushing counts the files being flushed, and reported those
+	// reported (see WorkStatus)
+	active   map[string]time.Time
+	flushing int
+	reported int64
+}
+
+// root returns the project tree and secrets baseline of the job's root,
+// loading them on first use
+func (w *worker) root(job *queue.Job) *rootState {
+	key := workRoot{job.Run, job.Root}
+	w.mu.Lock()
+	rs, ok := w.roots[key]
+	if !ok {
+		rs = &rootState{}
+		w.roots[key] = rs
+	}
+	w.mu.Unlock()
+
+	rs.once.Do(func() {
+		rs.projects = w.idx.detectProjects(job.Root)
+		rs.attrs = walker.NewAttributes(job.Root)
+		rs.baseline, rs.err = secrets.LoadBaseline(job.Root)
+		if rs.err != nil {
+			rs.err = fmt.Errorf("loading secrets baseline: %w", rs.err)
+		}
+	})
+	return rs
+}
+
+func (w *worker) forget() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	clear(w.roots)
+}
+
+// process chunks the job's file and queues the result, reporting whether
+// enough chunks are pending to fill a batch
+func (w *worker) process(ctx context.Context, job *queue.Job) bool {
+	idx := w.idx
+	wf := workedFile{run: job.Run, result: queue.Result{Path: job.Path}}
+	w.mu.Lock()
+	w.active[job.Path] = time.Now()
+	w.mu.Unlock()
+	rs := w.root(job)
+	err := rs.err
+	if err == nil {
+		var chunks []IndexedChunk
+		var language, skipReason string
+		_, span := tracing.StartSpan(ctx, "chunk", attribute.String("file", job.Path))
+		chunks, language, skipReason, err = idx.processFile(ctx, job.Root, rs.projects, job.Path, rs.baseline, rs.attrs)
+		tagChunks(chunks, job.Tags)
+		span.SetAttributes(attribute.Int("chunks", len(chunks)))
+		tracing.End(span, err)
+		if err == nil {
+			hashes := chunkHashes(chunks)
+			wf.chunks, wf.moved, wf.removed = reconcileChunks(chunks, hashes, job.Prev)
+			wf.result.Language, wf.result.SkipReason, wf.result.Chunks = language, skipReason, hashes
+			idx.summarize(ctx, wf.chunks)
+		}
+	}
+	if err != nil {
+		idx.logger.Warn("error processing file", "project", job.Root, "file", job.Path, "err", err)
+		setError(&wf.result, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.active, job.Path)
+	w.pending = append(w.pending, wf)
+	w.pendingChunks += len(wf.chunks)
+	return w.pendingChunks >= idx.batchSize
+}
+
+// flush embeds and upserts the pending chunks, deletes the chunks their
+// files no longer have, and reports the files. If that fails, every
+// pending file is reported as failed; files with chunks the store
+// rejected are failed at the upsert stage. The usage of embedding the
+// chunks is reported with the first file.
+func (w *worker) flush(ctx context.Context) {
+	w.mu.Lock()
+	files := w.pending
+	w.pending, w.pendingChunks = nil, 0
+	w.mu.Unlock()
+	if len(files) == 0 {
+		return
+	}
+
+	// One flush at a time, so the usage it adds is its own
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.setFlushing(len(files))
+	defer w.setFlushing(0)
+	idx := w.idx
+	before := idx.Usage()
+
+	var chunks, moved []IndexedChunk
+	var removed []string
+	for _, f := range files {
+		chunks = append(chunks, f.chunks...)
+		moved = append(moved, f.moved...)
+		removed = append(removed, f.removed...)
+	}
+	b := newBatcher(ctx, idx, progress.NewLog(idx.logger, progress.DefaultLogInterval))
+	b.add(chunks)
+	b.flush()
+	err := b.firstErr()
+	if err != nil {
+		err = &opError{op: OpProcess, err: fmt.Errorf("upserting chunks: %w", err)}
+	} else if len(removed) > 0 {
+		if _, derr := idx.store.DeleteChunks(ctx, removed); derr != nil {
+			err = &opError{op: OpProcess, err: fmt.Errorf("deleting chunks: %w", derr)}
+		}
+	}
+	if err == nil && len(moved) > 0 {
+		if uerr := idx.store.UpdateLines(ctx, moved); uerr != nil {
+			err = &opError{op: OpProcess, err: fmt.Errorf("updating chunk lines: %w", uerr)}
+		}
+	}
+	if err != nil {
+		idx.logger.Error("error flushing batch", "files", len(files), "err", err)
+	}
+	rejected := b.rejectedChunks()
+
+	after := idx.Usage()
+	used := usage.Stats{
+		EmbedCalls: after.EmbedCalls - before.EmbedCalls,
+		Chunks:     after.Chunks - before.Chunks,
+		ChunkBytes: after.ChunkBytes - before.ChunkBytes,
+		Tokens:     after.Tokens - before.Tokens,
+	}
+	w.mu.Lock()
+	w.reported += int64(len(files))
+	w.mu.Unlock()
+	for i, f := range files {
+		if err != nil && f.result.Error == "" {
+			setError(&f.result, err)
+		}
+		// A file with rejected chunks fails, so the run retries it
+		if n, msg := rejectedOf(f.chunks, rejected); n > 0 && f.result.Error == "" {
+			setError(&f.result, &opError{op: OpUpsert, err: fmt.Errorf("%d chunks rejected: %s", n, msg)})
+		}
+		if i == 0 {
+			f.result.Usage = used
+		}
+		if rerr := w.q.Report(ctx, f.run, f.result); rerr != nil {
+			idx.logger.Error("error reporting result", "file", f.result.Path, "err", rerr)
+		}
+	}
+}
+
+// rejectedOf returns how many of chunks were rejected, and the reason
+// one of them was
+func rejectedOf(chunks []IndexedChunk, rejected map[string]string) (int, string) {
+	n, reason := 0, ""
+	for _, c := range chunks {
+		if msg, ok := rejected[c.ID]; ok {
+			n, reason = n+1, msg
+		}
+	}
+	return n, reason
+}
+
+func (w *worker) setFlushing(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushing = n
+}
+
+// setError marks r as failed with err
+func setError(r *queue.Result, err error) {
+	r.Op, r.Error = OpProcess, err.Error()
+	var oe *opError
+	if errors.As(err, &oe) {
+		r.Op, r.Error = oe.op, oe.err.Error()
+	}
+	r.Language, r.SkipReason, r.Chunks = "", "", nil
+}
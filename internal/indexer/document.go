@@ -0,0 +1,101 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/progress"
+)
+
+// DocProjectPath is the project path of documents indexed with
+// IndexDocument, whose file path is their ID. Nothing on disk backs them.
+const DocProjectPath = "doc:"
+
+// IndexDocument indexes content that doesn't come from a file, such as
+// meeting notes or ticket text piped in, under DocProjectPath with id as
+// its file path. Indexing an ID again replaces its document. An empty
+// language is detected from the ID and content. Content flagged by a
+// SkipFile secrets rule or vetoed by a hook isn't indexed.
+func (idx *Indexer) IndexDocument(ctx context.Context, id, language, content string) error {
+	if id == "" {
+		return errors.New("document ID cannot be empty")
+	}
+	if strings.TrimSpace(content) == "" {
+		return errors.New("document is empty")
+	}
+
+	content, skip, err := idx.scanText(content, id, nil)
+	if err != nil {
+		return &opError{op: OpScan, err: err}
+	}
+	if skip {
+		return errors.New("document matches a secrets rule that skips files, not indexing it")
+	}
+	if language == "" {
+		language = idx.detectLanguage(id, content)
+	}
+	content, vetoed, err := idx.preChunk(ctx, DocProjectPath, id, language, content)
+	if err != nil {
+		return err
+	}
+	if vetoed != "" {
+		return fmt.Errorf("document vetoed by hook %s, not indexing it", vetoed)
+	}
+	chunks, err := idx.chunker.ChunkFile(id, content, language)
+	if err != nil {
+		return &opError{op: OpChunk, err: err}
+	}
+
+	now := time.Now().Unix()
+	indexed := make([]IndexedChunk, 0, len(chunks))
+	for _, c := range chunks {
+		indexed = append(indexed, IndexedChunk{
+			ID:          chunkID(DocProjectPath, id, c.StartLine),
+			RelPath:     id,
+			AbsPath:     absPath(DocProjectPath, id),
+			FilePath:    id,
+			ProjectPath: DocProjectPath,
+			ProjectRoot: DocProjectPath,
+			Language:    language,
+			ChunkType:   c.ChunkType,
+			Content:     c.Content,
+			Symbols:     c.Symbols,
+			HeadingPath: c.HeadingPath,
+			StartLine:   c.StartLine,
+			EndLine:     c.EndLine,
+			LastIndexed: now,
+		})
+	}
+	indexed, err = idx.postChunk(ctx, DocProjectPath, id, indexed)
+	if err != nil {
+		return err
+	}
+
+	// The previous version may have had chunks this one doesn't
+	if _, err := idx.store.DeleteFiles(ctx, DocProjectPath, []string{id}); err != nil {
+		return fmt.Errorf("deleting previous document: %w", err)
+	}
+
+	p := idx.progress
+	if p == nil {
+		p = progress.NewLog(idx.logger, progress.DefaultLogInterval)
+	}
+	p.Start(DocProjectPath, 1)
+	p.FileStarted(id)
+	idx.summarize(ctx, indexed)
+	b := newBatcher(ctx, idx, p)
+	b.add(indexed)
+	b.flush()
+	err = b.firstErr()
+	p.FileDone(id, err)
+	p.Finish()
+	if err != nil {
+		return err
+	}
+	idx.indexed.Add(1)
+	idx.logger.Info("done", "project", DocProjectPath, "document", id, "language", language, "chunks", b.total)
+	return nil
+}
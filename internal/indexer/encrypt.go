@@ -0,0 +1,103 @@
+package indexer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks content encrypted with the content key: the rest
+// is the base64 of the AES-GCM nonce followed by the sealed content
+const encryptedPrefix = "enc:v1:"
+
+// SetContentKey makes the client encrypt the content and summary of the
+// documents it upserts with key, an AES-256 key, and decrypt them in the
+// documents searches and exports return, so the Typesense server only ever
+// holds ciphertext. Embeddings and the other fields are stored as they are, so
+// vector search works unchanged; keyword search no longer matches content.
+// Documents stored in plain text are returned as they are.
+func (c *TypesenseClient) SetContentKey(key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("content key is %d bytes, want 32", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	c.contentCipher = gcm
+	return nil
+}
+
+// sealContent returns a copy of chunks with their content and summary
+// encrypted, or chunks itself without a content key
+func (c *TypesenseClient) sealContent(chunks []IndexedChunk) ([]IndexedChunk, error) {
+	if c.contentCipher == nil {
+		return chunks, nil
+	}
+	sealed := make([]IndexedChunk, len(chunks))
+	for i, chunk := range chunks {
+		var err error
+		if chunk.Content, err = c.seal(chunk.Content, chunk.ID); err != nil {
+			return nil, err
+		}
+		if chunk.Summary != "" {
+			if chunk.Summary, err = c.seal(chunk.Summary, chunk.ID); err != nil {
+				return nil, err
+			}
+		}
+		sealed[i] = chunk
+	}
+	return sealed, nil
+}
+
+// seal encrypts text of the document with the given ID
+func (c *TypesenseClient) seal(text, id string) (string, error) {
+	nonce := make([]byte, c.contentCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("encrypting content: %w", err)
+	}
+	// The ID is authenticated too, so text can't be moved to another
+	// document
+	data := c.contentCipher.Seal(nonce, nonce, []byte(text), []byte(id))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// openContent decrypts the content and summary of doc in place if they
+// are encrypted
+func (c *TypesenseClient) openContent(doc *IndexedChunk) error {
+	var err error
+	if doc.Content, err = c.open(doc.Content, doc.ID); err != nil {
+		return err
+	}
+	doc.Summary, err = c.open(doc.Summary, doc.ID)
+	return err
+}
+
+// open decrypts text of the document with the given ID, returning text
+// as it is if it isn't encrypted
+func (c *TypesenseClient) open(text, id string) (string, error) {
+	encoded, ok := strings.CutPrefix(text, encryptedPrefix)
+	if !ok {
+		return text, nil
+	}
+	if c.contentCipher == nil {
+		return "", fmt.Errorf("content of document %s is encrypted and no content key is set", id)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < c.contentCipher.NonceSize() {
+		return "", fmt.Errorf("content of document %s is malformed", id)
+	}
+	nonce, sealed := data[:c.contentCipher.NonceSize()], data[c.contentCipher.NonceSize():]
+	plain, err := c.contentCipher.Open(nil, nonce, sealed, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("decrypting document %s: wrong content key or corrupted content", id)
+	}
+	return string(plain), nil
+}
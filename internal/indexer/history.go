@@ -0,0 +1,264 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/history"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/progress"
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+)
+
+// Chunk types of indexed commit history
+const (
+	ChunkTypeCommit   = "commit"    // a commit's message and the files it changed
+	ChunkTypeDiffHunk = "diff_hunk" // one hunk of a commit's diff
+)
+
+// HistoryPathPrefix starts the file path of every history document:
+// git:<hash> for a commit and git:<hash>:<path> for its hunks. It keeps
+// them apart from the documents of files on disk.
+const HistoryPathPrefix = "git:"
+
+// maxHistoryCommits bounds how many commits the first history index of a
+// project reads; later runs read only the commits since
+const maxHistoryCommits = 1000
+
+// maxCommitHunks bounds the hunks indexed per commit, so a commit that
+// vendors or reformats a tree doesn't flood the index
+const maxCommitHunks = 50
+
+// maxHunkSize bounds the content of a hunk chunk; longer hunks are cut at
+// a line boundary
+const maxHunkSize = 4000
+
+// IndexHistory indexes the git history of each path: every commit's
+// message and changed files as a commit chunk, and the hunks of its diff
+// as diff_hunk chunks. Only commits since the last history index are
+// read. Paths outside a git repository are skipped with a warning.
+func (idx *Indexer) IndexHistory(ctx context.Context, paths []string) error {
+	var errs []error
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := idx.indexHistory(ctx, path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (idx *Indexer) indexHistory(ctx context.Context, path string) error {
+	root, err := walker.Abs(path)
+	if err != nil {
+		return err
+	}
+	meta, err := metadata.Load(root)
+	if err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+
+	head, err := history.Head(ctx, root)
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		idx.logger.Warn("not a git repository or no commits yet, skipping history", "project", root)
+		return nil
+	}
+	if head == meta.HistoryHead {
+		idx.logger.Info("history unchanged since last index, skipping", "project", root)
+		return nil
+	}
+	since, limit := meta.HistoryHead, 0
+	if since != "" && !history.IsAncestor(ctx, root, since) {
+		idx.logger.Info("history was rewritten, indexing it from scratch", "project", root, "previous_head", since)
+		since = ""
+	}
+	if since == "" {
+		limit = maxHistoryCommits
+	}
+
+	commits, err := history.Log(ctx, root, since, limit)
+	if errors.Is(err, history.ErrNotRepository) {
+		idx.logger.Warn("not a git repository, skipping history", "project", root)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading history: %w", err)
+	}
+
+	baseline, err := secrets.LoadBaseline(root)
+	if err != nil {
+		return fmt.Errorf("loading secrets baseline: %w", err)
+	}
+	projects := idx.detectProjects(root)
+
+	idx.logger.Info("indexing history", "project", root, "commits", len(commits))
+
+	// Commits stand in for files in progress reports
+	p := idx.progress
+	if p == nil {
+		p = progress.NewLog(idx.logger, progress.DefaultLogInterval)
+	}
+	p.Start(root, len(commits))
+
+	b := newBatcher(ctx, idx, p)
+	defer b.flush()
+	for i := range commits {
+		c := &commits[i]
+		name := HistoryPathPrefix + c.Hash
+		p.FileStarted(name)
+		chunks, err := idx.commitChunks(root, projects, c, baseline)
+		if err == nil {
+			tagChunks(chunks, meta.Tags)
+			err = b.add(chunks)
+		}
+		p.FileDone(name, err)
+		if err != nil {
+			p.Finish()
+			return err
+		}
+	}
+	b.flush()
+	p.Finish()
+	if err := b.firstErr(); err != nil {
+		return fmt.Errorf("upserting chunks: %w", err)
+	}
+
+	meta.HistoryHead = head
+	if err := meta.Save(root); err != nil {
+		return fmt.Errorf("saving metadata: %w", err)
+	}
+	idx.logger.Info("done", "project", root, "commits", len(commits), "chunks", b.total)
+	return nil
+}
+
+// commitChunks returns the chunks of a commit: the commit itself, then
+// its hunks. Hunks of binary files and of files matching the secrets skip
+// patterns are left out; secrets in the rest are redacted like in files.
+func (idx *Indexer) commitChunks(root string, projects projectTree, c *history.Commit, baseline *secrets.Baseline) ([]IndexedChunk, error) {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "commit %s\nAuthor: %s\nDate: %s\n\n%s\n", c.Hash, c.Author, c.Date.Format(time.RFC3339), c.Message)
+	if len(c.Files) > 0 {
+		msg.WriteString("\nFiles:\n")
+		for _, f := range c.Files {
+			msg.WriteString("  " + f.Path + "\n")
+		}
+	}
+
+	commitPath := HistoryPathPrefix + c.Hash
+	content, skip, err := idx.scanText(msg.String(), commitPath, baseline)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	var chunks []IndexedChunk
+	if !skip {
+		chunks = append(chunks, IndexedChunk{
+			ID:          chunkID(root, commitPath, 0),
+			RelPath:     commitPath,
+			AbsPath:     absPath(root, commitPath),
+			FilePath:    commitPath,
+			ProjectPath: root,
+			ProjectRoot: idx.projectRoot(root, projects[0].Dir),
+			ProjectType: projects[0].Type,
+			ChunkType:   ChunkTypeCommit,
+			Content:     content,
+			LastIndexed: now,
+		})
+	}
+
+	hunks := 0
+	for _, f := range c.Files {
+		if f.Binary {
+			continue
+		}
+		if _, skip := idx.scanner.ShouldSkipFile(f.Path); skip {
+			continue
+		}
+		project := projects.nearest(f.Path)
+		hunkPath := commitPath + ":" + f.Path
+		for _, h := range f.Hunks {
+			if hunks == maxCommitHunks {
+				idx.logger.Info("commit has too many hunks, indexing the first", "project", root, "commit", c.Hash, "hunks", maxCommitHunks)
+				return chunks, nil
+			}
+			hunks++
+
+			text := fmt.Sprintf("commit %.12s %s\n%s\n%s\n%s", c.Hash, c.Subject(), f.Path, h.Header, truncateLines(h.Content, maxHunkSize))
+			content, skip, err := idx.scanText(text, f.Path, baseline)
+			if err != nil {
+				return nil, err
+			}
+			if skip {
+				continue
+			}
+			chunks = append(chunks, IndexedChunk{
+				ID:          chunkID(root, hunkPath, h.StartLine),
+				RelPath:     hunkPath,
+				AbsPath:     absPath(root, hunkPath),
+				FilePath:    hunkPath,
+				ProjectPath: root,
+				ProjectRoot: idx.projectRoot(root, project.Dir),
+				ProjectType: project.Type,
+				Language:    idx.detectLanguage(f.Path, ""),
+				ChunkType:   ChunkTypeDiffHunk,
+				Content:     content,
+				StartLine:   h.StartLine,
+				EndLine:     h.StartLine + max(newLines(h.Content)-1, 0),
+				LastIndexed: now,
+			})
+		}
+	}
+	return chunks, nil
+}
+
+// scanText scans text not read from a file, such as history or a crawled
+// page, for secrets, returning it redacted, or skip if a SkipFile rule
+// matched. relPath is what the baseline is matched against.
+func (idx *Indexer) scanText(text, relPath string, baseline *secrets.Baseline) (string, bool, error) {
+	scan, err := idx.scanner.ScanContent(text)
+	if err != nil {
+		return "", false, err
+	}
+	scan = idx.scanner.Filter(scan, baseline, relPath)
+	if scan.ShouldSkip {
+		return "", true, nil
+	}
+	if len(scan.Findings) > 0 {
+		text = idx.scanner.Redact(text, scan.Findings)
+	}
+	return text, false, nil
+}
+
+// truncateLines cuts s to at most n bytes, at the end of a line when
+// there is one
+func truncateLines(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	if i := strings.LastIndexByte(s, '\n'); i > 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// newLines counts the lines of a hunk present in the new file
+func newLines(content string) int {
+	n := 0
+	for _, line := range strings.Split(content, "\n") {
+		if line != "" && (line[0] == ' ' || line[0] == '+') {
+			n++
+		}
+	}
+	return n
+}
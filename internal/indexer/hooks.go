@@ -0,0 +1,182 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/hooks"
+)
+
+// OpHook is the stage of files a hook failed on.
+const OpHook = "hook"
+
+// hookChunk is a chunk as hooks see it. Hooks may change its content,
+// symbols and heading path; the other fields are kept as they were.
+type hookChunk struct {
+	ID          string   `json:"id"`
+	FilePath    string   `json:"file_path"`
+	ProjectPath string   `json:"project_path"`
+	Language    string   `json:"language"`
+	ChunkType   string   `json:"chunk_type"`
+	Content     string   `json:"content"`
+	Symbols     []string `json:"symbols,omitempty"`
+	HeadingPath string   `json:"heading_path,omitempty"`
+	StartLine   int      `json:"start_line"`
+	EndLine     int      `json:"end_line"`
+}
+
+// preChunkRequest is what a pre-chunk hook reads: a file's content after
+// secrets were redacted
+type preChunkRequest struct {
+	Stage    string `json:"stage"`
+	Project  string `json:"project"`
+	File     string `json:"file"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// preChunkResponse is what a pre-chunk hook writes: the content to chunk
+// instead, if it changed it, or whether to leave the file out
+type preChunkResponse struct {
+	Content *string `json:"content"`
+	Skip    bool    `json:"skip"`
+}
+
+// chunksRequest is what post-chunk and pre-upsert hooks read. File is
+// only set for post-chunk hooks: a pre-upsert batch may span files.
+type chunksRequest struct {
+	Stage   string      `json:"stage"`
+	Project string      `json:"project"`
+	File    string      `json:"file,omitempty"`
+	Chunks  []hookChunk `json:"chunks"`
+}
+
+// chunksResponse is what post-chunk and pre-upsert hooks write: the
+// chunks to keep, changed or not. Chunks left out are vetoed.
+type chunksResponse struct {
+	Chunks []hookChunk `json:"chunks"`
+}
+
+// hooksFor returns the hooks configured for stage that apply to project
+func (idx *Indexer) hooksFor(stage, project string) []config.Hook {
+	var matched []config.Hook
+	for _, h := range idx.hooks {
+		if h.Stage == stage && h.Matches(project) {
+			matched = append(matched, h)
+		}
+	}
+	return matched
+}
+
+// preChunk runs the project's pre-chunk hooks on a file's content in
+// turn. It returns the content to chunk, or the name of the hook that
+// vetoed the file.
+func (idx *Indexer) preChunk(ctx context.Context, project, file, language, content string) (string, string, error) {
+	for _, h := range idx.hooksFor(config.HookPreChunk, project) {
+		req := preChunkRequest{Stage: h.Stage, Project: project, File: file, Language: language, Content: content}
+		var resp preChunkResponse
+		if err := hooks.Run(ctx, h, req, &resp); err != nil {
+			return "", "", &opError{op: OpHook, err: err}
+		}
+		if resp.Skip {
+			return "", h.Name(), nil
+		}
+		if resp.Content != nil {
+			content = *resp.Content
+		}
+	}
+	return content, "", nil
+}
+
+// postChunk runs the project's post-chunk hooks on a file's chunks in
+// turn, returning the chunks they kept.
+func (idx *Indexer) postChunk(ctx context.Context, project, file string, chunks []IndexedChunk) ([]IndexedChunk, error) {
+	for _, h := range idx.hooksFor(config.HookPostChunk, project) {
+		if len(chunks) == 0 {
+			break
+		}
+		var err error
+		chunks, err = runChunkHook(ctx, h, project, file, chunks)
+		if err != nil {
+			return nil, &opError{op: OpHook, err: err}
+		}
+	}
+	return chunks, nil
+}
+
+// preUpsert runs the pre-upsert hooks of each project in a batch on its
+// chunks, returning the chunks they kept in their order.
+func (idx *Indexer) preUpsert(ctx context.Context, batch []IndexedChunk) ([]IndexedChunk, error) {
+	if len(idx.hooks) == 0 {
+		return batch, nil
+	}
+	var projects []string
+	byProject := map[string][]IndexedChunk{}
+	for _, c := range batch {
+		if _, ok := byProject[c.ProjectPath]; !ok {
+			projects = append(projects, c.ProjectPath)
+		}
+		byProject[c.ProjectPath] = append(byProject[c.ProjectPath], c)
+	}
+
+	kept := make([]IndexedChunk, 0, len(batch))
+	for _, project := range projects {
+		chunks := byProject[project]
+		for _, h := range idx.hooksFor(config.HookPreUpsert, project) {
+			if len(chunks) == 0 {
+				break
+			}
+			var err error
+			chunks, err = runChunkHook(ctx, h, project, "", chunks)
+			if err != nil {
+				return nil, err
+			}
+		}
+		kept = append(kept, chunks...)
+	}
+	return kept, nil
+}
+
+// runChunkHook runs h on chunks and applies its response
+func runChunkHook(ctx context.Context, h config.Hook, project, file string, chunks []IndexedChunk) ([]IndexedChunk, error) {
+	req := chunksRequest{Stage: h.Stage, Project: project, File: file, Chunks: make([]hookChunk, len(chunks))}
+	byID := make(map[string]IndexedChunk, len(chunks))
+	for i, c := range chunks {
+		req.Chunks[i] = hookChunk{
+			ID:          c.ID,
+			FilePath:    c.FilePath,
+			ProjectPath: c.ProjectPath,
+			Language:    c.Language,
+			ChunkType:   c.ChunkType,
+			Content:     c.Content,
+			Symbols:     c.Symbols,
+			HeadingPath: c.HeadingPath,
+			StartLine:   c.StartLine,
+			EndLine:     c.EndLine,
+		}
+		byID[c.ID] = c
+	}
+
+	var resp chunksResponse
+	if err := hooks.Run(ctx, h, req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Chunks == nil {
+		return nil, fmt.Errorf("hook %s: response has no chunks; return an empty list to drop them all", h.Name())
+	}
+	kept := make([]IndexedChunk, 0, len(resp.Chunks))
+	for _, hc := range resp.Chunks {
+		c, ok := byID[hc.ID]
+		if !ok {
+			return nil, fmt.Errorf("hook %s: unknown or repeated chunk %q; hooks can change and drop chunks, not add them", h.Name(), hc.ID)
+		}
+		if hc.Content == "" {
+			return nil, fmt.Errorf("hook %s: chunk %q has no content", h.Name(), hc.ID)
+		}
+		delete(byID, hc.ID)
+		c.Content, c.Symbols, c.HeadingPath = hc.Content, hc.Symbols, hc.HeadingPath
+		kept = append(kept, c)
+	}
+	return kept, nil
+}
@@ -0,0 +1,1934 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dvaida/swarm-indexer/internal/chunker"
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/detector"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/progress"
+	"github.com/dvaida/swarm-indexer/internal/queue"
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+	"github.com/dvaida/swarm-indexer/internal/tracing"
+	"github.com/dvaida/swarm-indexer/internal/usage"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Default concurrency of each stage: processing files, embedding batches
+// and upserting them
+const (
+	defaultWorkers       = 8
+	defaultEmbedWorkers  = 4
+	defaultUpsertWorkers = 2
+)
+
+// defaultMaxQueuedBatches bounds the batches waiting to be embedded
+const defaultMaxQueuedBatches = 8
+
+// drainTimeout bounds how long an interrupted run or a stopping worker
+// spends finishing the files it has taken
+const drainTimeout = time.Minute
+
+// Store is the document store the indexer writes chunks to.
+type Store interface {
+	UpsertChunks(ctx context.Context, chunks []IndexedChunk) error
+	DeleteByProject(ctx context.Context, projectPath string) (int, error)
+	DeleteFiles(ctx context.Context, projectPath string, relPaths []string) (int, error)
+	DeleteChunks(ctx context.Context, ids []string) (int, error)
+	// UpdateLines sets the lines and last indexed time of the documents
+	// of chunks that moved within their file
+	UpdateLines(ctx context.Context, chunks []IndexedChunk) error
+	UpdateTags(ctx context.Context, projectPath string, tags []string) (int, error)
+	TombstoneFiles(ctx context.Context, projectPath string, relPaths []string, deletedAt int64) (int, error)
+	ExportProject(ctx context.Context, projectPath string, fn func(IndexedChunk) error) error
+}
+
+// Embedder generates embeddings for chunk content.
+type Embedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// modelNamer is implemented by embedders that can name their model.
+type modelNamer interface {
+	Model() string
+}
+
+// Summarizer writes a one-sentence summary of a chunk of code.
+type Summarizer interface {
+	Summarize(ctx context.Context, language, content string) (string, error)
+}
+
+// Progress receives updates while a project's files are indexed.
+// Implementations must be safe for concurrent use.
+type Progress interface {
+	Start(project string, files int)
+	FileStarted(path string)
+	FileDone(path string, err error)
+	ChunksEmbedded(n int)
+	// QueueDepth reports how many items the named queue holds, out of
+	// capacity
+	QueueDepth(name string, n, capacity int)
+	Finish()
+}
+
+// Stages a file can fail at, reported in FileError.Op.
+const (
+	OpRead    = "read"    // reading the file or detecting binary content
+	OpScan    = "scan"    // scanning for secrets
+	OpChunk   = "chunk"   // splitting into chunks
+	OpUpsert  = "upsert"  // Typesense rejected some of its chunks
+	OpProcess = "process" // any other step
+)
+
+// FileError describes a file that couldn't be indexed. The file is left
+// out of the recorded state, so the next run retries it.
+type FileError struct {
+	Project string `json:"project"`
+	Path    string `json:"path"` // relative to Project
+	Op      string `json:"op"`
+	Error   string `json:"error"`
+}
+
+// opError tags an error with the stage it happened at
+type opError struct {
+	op  string
+	err error
+}
+
+func (e *opError) Error() string { return e.op + ": " + e.err.Error() }
+
+func (e *opError) Unwrap() error { return e.err }
+
+// Indexer runs the walk → secrets → chunk → embed → upsert pipeline.
+type Indexer struct {
+	store    Store
+	embedder Embedder
+	// embeddingModel is recorded on the documents embedded, so searches
+	// can leave out those of another model
+	embeddingModel string
+	summarizer     Summarizer // nil unless summaries are enabled
+	scanner        *secrets.Scanner
+	// workers process files; embedWorkers and upsertWorkers embed and
+	// upsert their chunks' batches
+	workers       int
+	embedWorkers  int
+	upsertWorkers int
+	batchSize     int
+	// maxQueuedBatches bounds each queue of batches, and maxQueuedJobs the
+	// files a distributed run has queued for workers
+	maxQueuedBatches int
+	maxQueuedJobs    int
+	languages        map[string]string // extension → language, from the config
+	hooks            []config.Hook
+	// mounts maps the directories being indexed in place of something
+	// else to where their documents are stored
+	mounts   map[string]mount
+	logger   *slog.Logger
+	progress Progress
+	// queue hands files to workers when set; resultTimeout bounds the
+	// wait for each of their results
+	queue         queue.Queue
+	resultTimeout time.Duration
+
+	// indexed counts files that were chunked across all runs; failures
+	// lists the files that couldn't be processed
+	indexed    atomic.Int64
+	failuresMu sync.Mutex
+	failures   []FileError
+
+	usageMu sync.Mutex
+	usage   usage.Stats
+
+	// budget bounds the estimated tokens queued for embedding across
+	// runs, counted in queued; 0 means no budget. remaining lists the
+	// files left unprocessed once it was reached.
+	budget      int64
+	queued      atomic.Int64
+	remainingMu sync.Mutex
+	remaining   []Remaining
+
+	// reports holds what runs did in each project (see Report)
+	reportsMu   sync.Mutex
+	reports     []PathReport
+	listSkipped bool
+	// maxFileSize is the size in bytes above which files are skipped; 0
+	// means no limit
+	maxFileSize int64
+	// chunker splits files into chunks of at most cfg.ChunkSize
+	chunker *chunker.Chunker
+	// reindexAfter is how old a project's last index may get before
+	// IndexPaths reindexes it from scratch; 0 means never
+	reindexAfter time.Duration
+	// deleteGrace, when set, makes the documents of deleted files
+	// tombstones instead of deleting them
+	deleteGrace time.Duration
+	// skipGeneratedFiles leaves out files detector.DetectGenerated flags,
+	// unless .gitattributes sets linguist-generated=false on them
+	skipGeneratedFiles bool
+	// sample, when set, bounds the added files indexed in each project
+	sample Sample
+	// excludes match files left out of the projects indexed as if they
+	// weren't there
+	excludes secrets.SkipPatterns
+
+	// tags replace the tags of the projects indexed when tagsSet;
+	// projectTags holds the tags of the projects being indexed, by root
+	tags        []string
+	tagsSet     bool
+	projectTags map[string][]string
+
+	// work is the worker of a running Work, for WorkStatus
+	workMu sync.Mutex
+	work   *worker
+}
+
+// NewIndexer creates an indexer using the concurrency and batch settings
+// from cfg.
+func NewIndexer(cfg *config.Config, store Store, embedder Embedder) *Indexer {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	embedWorkers := cfg.EmbedWorkers
+	if embedWorkers <= 0 {
+		embedWorkers = defaultEmbedWorkers
+	}
+	upsertWorkers := cfg.UpsertWorkers
+	if upsertWorkers <= 0 {
+		upsertWorkers = defaultUpsertWorkers
+	}
+	maxQueuedBatches := cfg.MaxQueuedBatches
+	if maxQueuedBatches <= 0 {
+		maxQueuedBatches = defaultMaxQueuedBatches
+	}
+	maxQueuedJobs := cfg.MaxQueuedJobs
+	if maxQueuedJobs <= 0 {
+		maxQueuedJobs = defaultMaxQueuedJobs
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	model := cfg.GeminiModel
+	if m, ok := embedder.(modelNamer); ok {
+		model = m.Model()
+	}
+
+	return &Indexer{
+		store:              store,
+		embedder:           embedder,
+		embeddingModel:     model,
+		scanner:            secrets.NewFromConfig(cfg),
+		workers:            workers,
+		embedWorkers:       embedWorkers,
+		upsertWorkers:      upsertWorkers,
+		batchSize:          batchSize,
+		maxQueuedBatches:   maxQueuedBatches,
+		maxQueuedJobs:      maxQueuedJobs,
+		languages:          cfg.Languages,
+		hooks:              cfg.Hooks,
+		mounts:             map[string]mount{},
+		projectTags:        map[string][]string{},
+		logger:             slog.Default(),
+		budget:             int64(cfg.MaxEmbedTokens),
+		maxFileSize:        int64(cfg.MaxFileSize),
+		chunker:            chunker.New(cfg.ChunkSize),
+		reindexAfter:       cfg.ReindexAfter,
+		deleteGrace:        cfg.DeleteGrace,
+		skipGeneratedFiles: cfg.SkipGenerated,
+	}
+}
+
+// SetLogger replaces the logger used for progress and per-file errors.
+func (idx *Indexer) SetLogger(logger *slog.Logger) {
+	idx.logger = logger
+}
+
+// SetSummarizer enables the enrichment stage: each new or changed code
+// chunk is summarized, and the summary embedded along with the content.
+func (idx *Indexer) SetSummarizer(s Summarizer) {
+	idx.summarizer = s
+}
+
+// SetProgress replaces the progress reporter. Without one, progress is
+// logged periodically through the indexer's logger.
+func (idx *Indexer) SetProgress(p Progress) {
+	idx.progress = p
+}
+
+// SetExcludes leaves the files matching patterns, like skip_files, out of
+// the projects indexed from then on. Files already indexed are removed.
+func (idx *Indexer) SetExcludes(patterns secrets.SkipPatterns) {
+	idx.excludes = patterns
+}
+
+// Usage returns the embedding usage accumulated by this indexer.
+func (idx *Indexer) Usage() usage.Stats {
+	idx.usageMu.Lock()
+	defer idx.usageMu.Unlock()
+	return idx.usage
+}
+
+// IndexedFiles returns how many files were chunked and sent to the
+// store. Skipped and failed files aren't counted.
+func (idx *Indexer) IndexedFiles() int {
+	return int(idx.indexed.Load())
+}
+
+// FailedFiles returns how many files couldn't be processed. Such files are
+// logged and skipped without failing the run.
+func (idx *Indexer) FailedFiles() int {
+	idx.failuresMu.Lock()
+	defer idx.failuresMu.Unlock()
+	return len(idx.failures)
+}
+
+// Failures returns the files that couldn't be processed and why, in the
+// order they failed.
+func (idx *Indexer) Failures() []FileError {
+	idx.failuresMu.Lock()
+	defer idx.failuresMu.Unlock()
+	return append([]FileError(nil), idx.failures...)
+}
+
+func (idx *Indexer) recordFailure(root, path string, err error) {
+	fe := FileError{Project: root, Path: path, Op: OpProcess, Error: err.Error()}
+	// Crawled pages are reported by their URL
+	if rel, relErr := filepath.Rel(root, path); relErr == nil && !strings.Contains(path, "://") {
+		fe.Path = rel
+	}
+	var oe *opError
+	if errors.As(err, &oe) {
+		fe.Op, fe.Error = oe.op, oe.err.Error()
+	}
+
+	idx.failuresMu.Lock()
+	defer idx.failuresMu.Unlock()
+	idx.failures = append(idx.failures, fe)
+}
+
+func (idx *Indexer) recordEmbed(texts []string) {
+	var bytes, tokens int64
+	for _, t := range texts {
+		bytes += int64(len(t))
+		tokens += usage.EstimateTokens(t)
+	}
+
+	idx.usageMu.Lock()
+	defer idx.usageMu.Unlock()
+	idx.usage.EmbedCalls++
+	idx.usage.Chunks += int64(len(texts))
+	idx.usage.ChunkBytes += bytes
+	idx.usage.Tokens += tokens
+}
+
+// setEmbeddings sets the chunks' embeddings to vectors, recording the
+// model they came from.
+func (idx *Indexer) setEmbeddings(chunks []IndexedChunk, vectors [][]float32) {
+	for i := range chunks {
+		chunks[i].Embedding = vectors[i]
+		chunks[i].EmbeddingModel = idx.embeddingModel
+		chunks[i].EmbeddingDim = len(vectors[i])
+	}
+}
+
+// IndexPaths indexes each path in turn. A failure on one path doesn't
+// stop the others; all failures are returned together. Once the embedding
+// budget is reached, the files left are listed by Remaining. Paths last
+// indexed longer than reindex_after ago are reindexed from scratch.
+func (idx *Indexer) IndexPaths(ctx context.Context, paths []string) error {
+	var errs []error
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		var err error
+		if idx.dueForReindex(path) {
+			err = idx.reindexPath(ctx, path, false)
+		} else {
+			err = idx.indexPath(ctx, path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dueForReindex reports whether the project at path was last indexed
+// longer than reindexAfter ago, so it is indexed from scratch in case
+// change detection missed an update
+func (idx *Indexer) dueForReindex(path string) bool {
+	if idx.reindexAfter <= 0 {
+		return false
+	}
+	meta, err := metadata.Load(path)
+	if err != nil || !ReindexDue(meta.LastIndexed, idx.reindexAfter) {
+		return false
+	}
+	idx.logger.Info("last indexed longer ago than reindex_after, reindexing", "project", path,
+		"last_indexed", time.Unix(meta.LastIndexed, 0).Format(time.RFC3339))
+	return true
+}
+
+// ReindexDue reports whether a project last indexed at lastIndexed (Unix
+// seconds) is older than reindexAfter. Projects never indexed and a
+// reindexAfter of 0 are never due.
+func ReindexDue(lastIndexed int64, reindexAfter time.Duration) bool {
+	return reindexAfter > 0 && lastIndexed > 0 && time.Since(time.Unix(lastIndexed, 0)) > reindexAfter
+}
+
+// Reindex clears the stored metadata for each path and indexes it from
+// scratch. With purge, the path's documents are deleted from the store
+// first so chunks from removed files or an old schema don't linger.
+// Without it, the documents of recorded files that are gone are deleted
+// first, and the recorded chunks the files no longer have once indexed.
+func (idx *Indexer) Reindex(ctx context.Context, paths []string, purge bool) error {
+	var errs []error
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := idx.reindexPath(ctx, path, purge); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (idx *Indexer) reindexPath(ctx context.Context, path string, purge bool) error {
+	root, err := walker.Abs(path)
+	if err != nil {
+		return err
+	}
+	// Left as it is, rather than cleared and not indexed again
+	if idx.overBudget() {
+		indexable, err := idx.walkIndexable(root)
+		if err != nil {
+			return err
+		}
+		files := make([]string, 0, len(indexable))
+		for rel := range indexable {
+			files = append(files, rel)
+		}
+		idx.recordRemaining(root, files)
+		return nil
+	}
+
+	if purge {
+		n, err := idx.store.DeleteByProject(ctx, root)
+		if err != nil {
+			return fmt.Errorf("deleting documents: %w", err)
+		}
+		idx.logger.Info("deleted documents", "project", root, "documents", n)
+	}
+	meta, err := metadata.Load(root)
+	if err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	// Once the state is cleared the run can't tell which files are gone,
+	// so without a purge their documents go now
+	if !purge {
+		if err := idx.removeGone(ctx, root, meta.Files); err != nil {
+			return err
+		}
+	}
+	if err := metadata.Remove(root); err != nil {
+		return err
+	}
+	// The project keeps its tags
+	if len(meta.Tags) > 0 {
+		if err := (&metadata.Metadata{Tags: meta.Tags}).Save(root); err != nil {
+			return fmt.Errorf("saving metadata: %w", err)
+		}
+	}
+
+	if err := idx.indexPath(ctx, root); err != nil {
+		return err
+	}
+	if !purge {
+		return idx.removeReplaced(ctx, root, meta.Files)
+	}
+	return nil
+}
+
+// removeGone removes the documents of the files recorded for the project
+// at root that are gone or now excluded.
+func (idx *Indexer) removeGone(ctx context.Context, root string, recorded map[string]metadata.FileState) error {
+	files, err := metadata.ScanFiles(root)
+	if err != nil {
+		return err
+	}
+	var gone []string
+	for rel := range recorded {
+		_, excluded := idx.excludes.Match(rel)
+		if _, ok := files[rel]; !ok || excluded {
+			gone = append(gone, rel)
+		}
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+	sort.Strings(gone)
+	return idx.removeFiles(ctx, root, gone, gone)
+}
+
+// removeReplaced deletes the chunks recorded for the project at root
+// before a reindex that its files no longer have: the files were indexed
+// again from scratch, so their chunks that changed have new IDs. Files
+// the reindex didn't record, e.g. left by the embedding budget, keep
+// theirs.
+func (idx *Indexer) removeReplaced(ctx context.Context, root string, recorded map[string]metadata.FileState) error {
+	meta, err := metadata.Load(root)
+	if err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	var replaced []string
+	for rel, old := range recorded {
+		cur, ok := meta.Files[rel]
+		if !ok || cur.Chunks == nil {
+			continue
+		}
+		for id := range old.Chunks {
+			if _, kept := cur.Chunks[id]; !kept {
+				replaced = append(replaced, id)
+			}
+		}
+	}
+	if len(replaced) == 0 {
+		return nil
+	}
+	sort.Strings(replaced)
+	if _, err := idx.store.DeleteChunks(ctx, replaced); err != nil {
+		return fmt.Errorf("deleting chunks: %w", err)
+	}
+	idx.logger.Info("deleted replaced chunks", "project", root, "chunks", len(replaced))
+	return nil
+}
+
+// pathPlan is what indexing a project changes, worked out from its
+// recorded state without writing anything.
+type pathPlan struct {
+	root      string
+	meta      *metadata.Metadata
+	files     map[string]metadata.FileState // the file state to record
+	indexable map[string]bool               // relative paths the walker yields, less excluded ones
+	// unwalked lists the files the walker leaves out, relative, when
+	// skipped files are listed
+	unwalked []string
+	// linguist holds the files .gitattributes marks generated or vendored,
+	// left out of files, with the reason
+	linguist map[string]string
+	changes  metadata.Changes
+	added    []string // added files to process, relative
+	updated  []string // modified files whose contents changed, relative
+	// stale lists files whose chunks are removed before indexing; prev
+	// holds the recorded chunks of modified files reconciled chunk by
+	// chunk instead, by absolute path
+	stale []string
+	prev  map[string]map[string]string
+}
+
+// toIndex returns the files to process, relative to the root.
+func (pp *pathPlan) toIndex() []string {
+	return append(append([]string(nil), pp.added...), pp.updated...)
+}
+
+// removeFiles removes the documents of the stale files of the project at
+// root before they are indexed again. With a delete grace period, those
+// of the deleted files among them are made tombstones instead, hidden
+// from searches but restorable by undelete until they are purged, in case
+// the files were only missed by a bad walk.
+func (idx *Indexer) removeFiles(ctx context.Context, root string, stale, deleted []string) error {
+	if idx.deleteGrace > 0 && len(deleted) > 0 {
+		gone := make(map[string]bool, len(deleted))
+		for _, rel := range deleted {
+			gone[rel] = true
+		}
+		var kept []string
+		for _, rel := range stale {
+			if !gone[rel] {
+				kept = append(kept, rel)
+			}
+		}
+		stale = kept
+		n, err := idx.store.TombstoneFiles(ctx, idx.projectPath(root), idx.filePaths(root, deleted), time.Now().Unix())
+		if err != nil {
+			return fmt.Errorf("tombstoning documents: %w", err)
+		}
+		idx.logger.Info("tombstoned documents", "project", root, "files", len(deleted), "documents", n, "grace", idx.deleteGrace)
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	n, err := idx.store.DeleteFiles(ctx, idx.projectPath(root), idx.filePaths(root, stale))
+	if err != nil {
+		return fmt.Errorf("deleting documents: %w", err)
+	}
+	idx.logger.Info("deleted documents", "project", root, "files", len(stale), "documents", n)
+	return nil
+}
+
+// planPath compares the project at root against its recorded state. Only
+// added files and modified files whose contents differ are processed.
+// Modified files with recorded chunks are reconciled chunk by chunk; the
+// chunks of other modified files and of deleted files are removed first
+// so none are left behind when a file shrinks.
+func (idx *Indexer) planPath(root string) (*pathPlan, error) {
+	meta, err := metadata.Load(root)
+	if err != nil {
+		return nil, fmt.Errorf("loading metadata: %w", err)
+	}
+
+	files, err := metadata.ScanFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	var unwalked []string
+	if idx.listSkipped {
+		walked := make(map[string]bool, len(files))
+		for rel := range files {
+			walked[rel] = true
+		}
+		if unwalked, err = unwalkedFiles(root, walked); err != nil {
+			return nil, err
+		}
+	}
+	// Excluded files, and files .gitattributes marks generated or vendored,
+	// count as deleted, so their documents go
+	attrs := walker.NewAttributes(root)
+	indexable := map[string]bool{}
+	linguist := map[string]string{}
+	for rel := range files {
+		if _, ok := idx.excludes.Match(rel); ok {
+			delete(files, rel)
+			continue
+		}
+		if !isOwnFile(rel) {
+			indexable[rel] = true
+		}
+		if reason := linguistSkipReason(attrs, rel); reason != "" {
+			delete(files, rel)
+			linguist[rel] = reason
+		}
+	}
+	pp := &pathPlan{root: root, meta: meta, files: files, indexable: indexable, linguist: linguist, unwalked: unwalked, prev: map[string]map[string]string{}}
+	pp.changes = metadata.DiffFiles(meta.Files, files)
+	if pp.changes.Empty() {
+		return pp, nil
+	}
+	// Unchanged files keep their recorded hash
+	for rel, cur := range files {
+		if old, ok := meta.Files[rel]; ok && old.ModTime == cur.ModTime && old.Size == cur.Size {
+			files[rel] = old
+		}
+	}
+
+	for _, rel := range pp.changes.Added {
+		if pp.indexable[rel] {
+			pp.added = append(pp.added, rel)
+		}
+	}
+	for _, rel := range pp.changes.Modified {
+		if !pp.indexable[rel] {
+			continue
+		}
+		old := meta.Files[rel]
+		if hash, err := metadata.HashFile(filepath.Join(root, rel)); err == nil && hash == old.Hash {
+			old.ModTime, old.Size = files[rel].ModTime, files[rel].Size
+			files[rel] = old
+			continue
+		}
+		pp.updated = append(pp.updated, rel)
+		if old.Chunks != nil {
+			pp.prev[filepath.Join(root, rel)] = old.Chunks
+		} else {
+			pp.stale = append(pp.stale, rel)
+		}
+	}
+	pp.stale = append(pp.stale, pp.changes.Deleted...)
+	return pp, nil
+}
+
+// skipUnindexed records in rep the files of the plan the walker left out,
+// when they are listed, those .gitattributes marks generated or vendored,
+// and the files the walker yielded that aren't indexed as unchanged.
+func (idx *Indexer) skipUnindexed(rep *PathReport, pp *pathPlan, toIndex []string) {
+	indexing := make(map[string]bool, len(toIndex))
+	for _, rel := range toIndex {
+		indexing[rel] = true
+	}
+	for rel := range pp.files {
+		if !isOwnFile(rel) && !indexing[rel] {
+			idx.skip(rep, rel, skipUnchanged)
+		}
+	}
+	for rel, reason := range pp.linguist {
+		idx.skip(rep, rel, reason)
+	}
+	for _, rel := range pp.unwalked {
+		idx.skip(rep, rel, walkSkipReason(rel))
+	}
+}
+
+// walkIndexable returns the relative paths of the files the walker yields
+// under root, less the excluded ones
+func (idx *Indexer) walkIndexable(root string) (map[string]bool, error) {
+	ch, err := walker.Walk(root)
+	if err != nil {
+		return nil, fmt.Errorf("walking: %w", err)
+	}
+	indexable := map[string]bool{}
+	for fi := range ch {
+		if isOwnFile(fi.Path) {
+			continue
+		}
+		rel, err := filepath.Rel(root, fi.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := idx.excludes.Match(rel); !ok {
+			indexable[rel] = true
+		}
+	}
+	return indexable, nil
+}
+
+// unwalkedFiles returns the files under root the walker leaves out, those
+// in gitignored or hidden directories included, relative to root and
+// sorted. walked holds the files it yields. Each directory is entered
+// once: on Windows a junction can list as a directory and lead back up
+// the tree.
+func unwalkedFiles(root string, walked map[string]bool) ([]string, error) {
+	var unwalked []string
+	visited := make(map[walker.FileID]bool)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			id, err := walker.ID(path)
+			if err != nil {
+				return err
+			}
+			if visited[id] {
+				return fs.SkipDir
+			}
+			visited[id] = true
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if !walked[rel] && !isOwnFile(rel) {
+			unwalked = append(unwalked, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing skipped files: %w", err)
+	}
+	return unwalked, nil
+}
+
+// linguistSkipReason returns why the file at rel is left out by the
+// linguist attributes .gitattributes gives it, or "" if it isn't
+func linguistSkipReason(attrs *walker.Attributes, rel string) string {
+	switch {
+	case attrs.Get(rel, "linguist-generated") == "true":
+		return skipGenerated
+	case attrs.Get(rel, "linguist-vendored") == "true":
+		return skipVendored
+	}
+	return ""
+}
+
+func (idx *Indexer) indexPath(ctx context.Context, path string) (err error) {
+	root, err := walker.Abs(path)
+	if err != nil {
+		return err
+	}
+	ctx, span := tracing.StartSpan(ctx, "index.project", attribute.String("project", root))
+	defer func() { tracing.End(span, err) }()
+	rep := idx.startReport(root)
+	defer func() { idx.finishReport(rep, err) }()
+
+	_, walkSpan := tracing.StartSpan(ctx, "walk")
+	pp, err := idx.planPath(root)
+	if err == nil {
+		walkSpan.SetAttributes(attribute.Int("files", len(pp.files)))
+	}
+	tracing.End(walkSpan, err)
+	rep.Durations.Walk = time.Since(rep.start)
+	if err != nil {
+		return err
+	}
+	rep.Walked = len(pp.files)
+	rep.Added, rep.Modified, rep.Deleted = len(pp.changes.Added), len(pp.changes.Modified), len(pp.changes.Deleted)
+	idx.samplePlan(rep, pp)
+	toIndex := pp.toIndex()
+	idx.skipUnindexed(rep, pp, toIndex)
+	retagged, err := idx.applyTags(ctx, root, pp.meta)
+	if err != nil {
+		return err
+	}
+	if pp.changes.Empty() {
+		idx.logger.Info("unchanged since last index, skipping", "project", root)
+		if retagged {
+			if err := pp.meta.Save(root); err != nil {
+				return fmt.Errorf("saving metadata: %w", err)
+			}
+		}
+		return nil
+	}
+	meta, files, changes, stale := pp.meta, pp.files, pp.changes, pp.stale
+
+	idx.logger.Info("changes since last index", "project", root,
+		"added", len(changes.Added), "modified", len(changes.Modified), "deleted", len(changes.Deleted), "to_index", len(toIndex))
+	if idx.overBudget() {
+		idx.logger.Warn("embedding budget reached, skipping", "project", root, "files", len(toIndex))
+		idx.recordRemaining(root, toIndex)
+		rep.Remaining = len(toIndex)
+		return nil
+	}
+
+	if err := idx.removeFiles(ctx, root, stale, changes.Deleted); err != nil {
+		return err
+	}
+
+	projects := idx.detectProjects(root)
+	project := projects[0]
+	paths := make([]string, len(toIndex))
+	for i, rel := range toIndex {
+		paths[i] = filepath.Join(root, rel)
+		hashState(files, root, rel)
+	}
+	// An interrupted run still records the files it finished
+	var interrupted error
+	if len(paths) > 0 {
+		res, err := idx.indexFiles(ctx, root, projects, paths, pp.prev, rep)
+		if res == nil {
+			return err
+		}
+		interrupted = err
+		res.apply(files, meta.Files, root)
+	}
+
+	meta.LastIndexed = time.Now().Unix()
+	meta.FileCount = len(pp.indexable)
+	meta.ProjectType = project.Type
+	meta.Dependencies = project.Dependencies
+	meta.Languages = languages(files)
+	meta.Files = files
+	if err := meta.Save(root); err != nil {
+		return fmt.Errorf("saving metadata: %w", err)
+	}
+
+	return interrupted
+}
+
+// projectTree is the project at a root followed by the projects nested in
+// it, as returned by detector.DetectProjects
+type projectTree []*detector.ProjectInfo
+
+// nearest returns the innermost project enclosing the file at rel
+func (t projectTree) nearest(rel string) *detector.ProjectInfo {
+	best := t[0]
+	for _, p := range t[1:] {
+		if strings.HasPrefix(rel, p.Dir+string(filepath.Separator)) && len(p.Dir) > len(best.Dir) {
+			best = p
+		}
+	}
+	return best
+}
+
+// detectProjects returns the projects at and under root, falling back to
+// an unknown project when detection fails
+func (idx *Indexer) detectProjects(root string) projectTree {
+	projects, err := detector.DetectProjects(root)
+	if err != nil {
+		idx.logger.Warn("detecting project type", "project", root, "err", err)
+		return projectTree{{Dir: ".", Type: "unknown"}}
+	}
+	if len(projects) > 1 {
+		idx.logger.Debug("detected nested projects", "project", root, "nested", len(projects)-1)
+	}
+	return projects
+}
+
+// languages returns the sorted, distinct languages of the files in state
+func languages(files map[string]metadata.FileState) []string {
+	seen := map[string]bool{}
+	var langs []string
+	for _, f := range files {
+		if f.Language != "" && f.Language != "unknown" && !seen[f.Language] {
+			seen[f.Language] = true
+			langs = append(langs, f.Language)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// hashState records the content hash of rel in files. It is taken before
+// the file is processed, so a change made while indexing shows up as a
+// change on the next run.
+func hashState(files map[string]metadata.FileState, root, rel string) {
+	state := files[rel]
+	hash, err := metadata.HashFile(filepath.Join(root, rel))
+	if err != nil {
+		// Processing the file fails as well, which drops it from the state
+		return
+	}
+	state.Hash = hash
+	files[rel] = state
+}
+
+// IndexFiles indexes individual files, e.g. a list of changed files from
+// git. Each file is attributed to its project root (see ProjectRoot) and
+// that project's metadata is updated for the given files only. Files that
+// no longer exist are skipped.
+func (idx *Indexer) IndexFiles(ctx context.Context, files []string) error {
+	byRoot := map[string][]string{}
+	var roots []string
+	finder := NewRootFinder()
+	for _, f := range files {
+		abs, err := walker.Abs(f)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				idx.logger.Warn("skipping file that no longer exists", "file", abs)
+				continue
+			}
+			return err
+		}
+		if info.IsDir() || isOwnFile(abs) {
+			continue
+		}
+		root := finder.Root(abs)
+		if _, ok := byRoot[root]; !ok {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], abs)
+	}
+
+	var errs []error
+	for _, root := range roots {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := idx.indexRootFiles(ctx, root, byRoot[root]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", root, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (idx *Indexer) indexRootFiles(ctx context.Context, root string, files []string) (err error) {
+	rep := idx.startReport(root)
+	defer func() { idx.finishReport(rep, err) }()
+	meta, err := metadata.Load(root)
+	if err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	if meta.Files == nil {
+		meta.Files = map[string]metadata.FileState{}
+	}
+	if _, err := idx.applyTags(ctx, root, meta); err != nil {
+		return err
+	}
+
+	// Previously indexed files are reconciled chunk by chunk, or lose
+	// their old chunks first when none were recorded
+	var stale []string
+	prev := map[string]map[string]string{}
+	rels := make(map[string]string, len(files))
+	for _, path := range files {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rels[path] = rel
+		if old, ok := meta.Files[rel]; ok {
+			if old.Chunks != nil {
+				prev[path] = old.Chunks
+			} else {
+				stale = append(stale, rel)
+			}
+		}
+	}
+	if len(stale) > 0 {
+		if _, err := idx.store.DeleteFiles(ctx, idx.projectPath(root), idx.filePaths(root, stale)); err != nil {
+			return fmt.Errorf("deleting documents: %w", err)
+		}
+	}
+
+	recorded := map[string]metadata.FileState{}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if old, ok := meta.Files[rels[path]]; ok {
+			recorded[rels[path]] = old
+		}
+		meta.Files[rels[path]] = metadata.FileState{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+		hashState(meta.Files, root, rels[path])
+	}
+
+	projects := idx.detectProjects(root)
+	project := projects[0]
+	res, interrupted := idx.indexFiles(ctx, root, projects, files, prev, rep)
+	if res == nil {
+		return interrupted
+	}
+	res.apply(meta.Files, recorded, root)
+
+	meta.LastIndexed = time.Now().Unix()
+	meta.FileCount = len(meta.Files)
+	meta.ProjectType = project.Type
+	meta.Dependencies = project.Dependencies
+	meta.Languages = languages(meta.Files)
+	if err := meta.Save(root); err != nil {
+		return fmt.Errorf("saving metadata: %w", err)
+	}
+	return interrupted
+}
+
+// isOwnFile reports whether path is one of the files swarm-indexer keeps in
+// a project root, which are never indexed
+func isOwnFile(path string) bool {
+	name := filepath.Base(path)
+	return name == metadata.MetadataFileName || name == secrets.BaselineFileName
+}
+
+// ProjectRoot returns the project directory a file belongs to: the nearest
+// ancestor holding index metadata, else the nearest one holding a .git
+// entry, else the file's own directory. To find the roots of many files,
+// use a RootFinder.
+func ProjectRoot(path string) string {
+	return NewRootFinder().Root(path)
+}
+
+// RootFinder finds the project roots of files like ProjectRoot, reading
+// the recorded projects once and looking each directory up once, so the
+// files of a run don't each open the state database for every ancestor.
+type RootFinder struct {
+	index *metadata.Index // nil if the state database couldn't be read
+	// indexed and git map each directory looked up to its nearest
+	// ancestor, itself included, with metadata or a .git entry; "" if
+	// there is none
+	indexed map[string]string
+	git     map[string]string
+}
+
+// NewRootFinder returns a RootFinder of the projects recorded now.
+func NewRootFinder() *RootFinder {
+	index, err := metadata.OpenIndex()
+	if err != nil {
+		slog.Debug("reading recorded projects", "err", err)
+	}
+	return &RootFinder{index: index, indexed: map[string]string{}, git: map[string]string{}}
+}
+
+// Root returns the project directory the file at path belongs to.
+func (f *RootFinder) Root(path string) string {
+	dir := filepath.Dir(path)
+	if root := f.nearest(f.indexed, dir, f.hasMetadata); root != "" {
+		return root
+	}
+	if root := f.nearest(f.git, dir, hasGit); root != "" {
+		return root
+	}
+	return dir
+}
+
+// nearest returns the nearest ancestor of dir, itself included, for which
+// has is true, remembering the answer for every directory on the way in
+// known
+func (f *RootFinder) nearest(known map[string]string, dir string, has func(string) bool) string {
+	if root, ok := known[dir]; ok {
+		return root
+	}
+	root := ""
+	if has(dir) {
+		root = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		root = f.nearest(known, parent, has)
+	}
+	known[dir] = root
+	return root
+}
+
+func (f *RootFinder) hasMetadata(dir string) bool {
+	return f.index != nil && f.index.Exists(dir)
+}
+
+func hasGit(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// filesResult is what indexFiles found out about the files it processed.
+type filesResult struct {
+	failed    []string          // files that couldn't be processed
+	languages map[string]string // language of each file indexed, not skipped
+	// chunks holds the chunk hashes by ID of each file processed without
+	// error; empty for skipped files
+	chunks map[string]map[string]string
+	// remaining lists the files left unprocessed once the embedding
+	// budget was reached
+	remaining []string
+	// rejected lists the files with chunks the store rejected, whose
+	// hashes are left out of chunks
+	rejected []string
+}
+
+// apply records the result in the file state of the project at root.
+// Failed files are left out of the state so the next run retries them;
+// unprocessed files keep their state in recorded, the state before the
+// run, so the next run picks them up. Files with rejected chunks keep the
+// hashes of the rest but not their modification time or content hash, so
+// the next run reconciles them and upserts the rejected chunks again.
+func (r *filesResult) apply(files, recorded map[string]metadata.FileState, root string) {
+	for _, path := range r.failed {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			delete(files, rel)
+		}
+	}
+	for _, path := range r.remaining {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if f, ok := recorded[rel]; ok {
+			files[rel] = f
+		} else {
+			delete(files, rel)
+		}
+	}
+	for path, lang := range r.languages {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if f, ok := files[rel]; ok {
+			f.Language = lang
+			files[rel] = f
+		}
+	}
+	for path, chunks := range r.chunks {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if f, ok := files[rel]; ok {
+			f.Chunks = chunks
+			files[rel] = f
+		}
+	}
+	for _, path := range r.rejected {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if f, ok := files[rel]; ok {
+			f.ModTime, f.Hash = 0, ""
+			files[rel] = f
+		}
+	}
+}
+
+// indexFiles runs files through the worker pool and flushes all batches.
+// Chunks are tagged with their nearest project in projects. prev holds
+// the chunk hashes recorded for files indexed before, by absolute path:
+// only their new and changed chunks are embedded and upserted, and chunks
+// they no longer have are deleted. When ctx is done, no more files are
+// started; the files in flight are finished and flushed, and the result
+// is returned along with ctx's error so the progress can be saved. A
+// batch that fails to be sent stops the pool the same way, and the run
+// fails.
+func (idx *Indexer) indexFiles(ctx context.Context, root string, projects projectTree, files []string, prev map[string]map[string]string, rep *PathReport) (*filesResult, error) {
+	if _, mounted := idx.mounts[root]; idx.queue != nil && !mounted {
+		return idx.distributeFiles(ctx, root, files, prev, rep)
+	}
+	baseline, err := secrets.LoadBaseline(root)
+	if err != nil {
+		return nil, fmt.Errorf("loading secrets baseline: %w", err)
+	}
+	attrs := walker.NewAttributes(root)
+
+	idx.logger.Info("indexing files", "project", root, "files", len(files),
+		"workers", idx.workers, "embed_workers", idx.embedWorkers, "upsert_workers", idx.upsertWorkers)
+
+	p := idx.progress
+	if p == nil {
+		p = progress.NewLog(idx.logger, progress.DefaultLogInterval)
+	}
+	p.Start(root, len(files))
+
+	// Files in flight when ctx is done are finished with drainCtx
+	drainCtx, cancelDrain := drainContext(ctx)
+	defer cancelDrain()
+
+	b := newBatcher(drainCtx, idx, p)
+	defer b.flush()
+	// The pool stops taking files when ctx is done or a batch fails
+	poolCtx, stopPool := context.WithCancel(ctx)
+	defer stopPool()
+	jobs := make(chan string)
+	res := &filesResult{languages: map[string]string{}, chunks: map[string]map[string]string{}}
+	skipped := rep.Skipped   // by reason
+	var moved []IndexedChunk // chunks of reconciled files that only moved
+	var removed []string     // IDs of chunks reconciled files no longer have
+	var reset []string       // reconciled files that failed, relative to root
+	var countMu sync.Mutex   // guards res and rep
+	var wg sync.WaitGroup
+
+	for i := 0; i < idx.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				if idx.overBudget() {
+					countMu.Lock()
+					res.remaining = append(res.remaining, path)
+					countMu.Unlock()
+					continue
+				}
+				p.FileStarted(path)
+				start := time.Now()
+				_, span := tracing.StartSpan(ctx, "chunk", attribute.String("file", path))
+				chunks, language, skipReason, err := idx.processFile(drainCtx, root, projects, path, baseline, attrs)
+				tagChunks(chunks, idx.projectTags[root])
+				span.SetAttributes(attribute.Int("chunks", len(chunks)))
+				tracing.End(span, err)
+				took := time.Since(start)
+				var hashes map[string]string
+				var shifted []IndexedChunk
+				var gone []string
+				kept := len(chunks)
+				if err == nil {
+					hashes = chunkHashes(chunks)
+					chunks, shifted, gone = reconcileChunks(chunks, hashes, prev[path])
+					kept -= len(chunks) + len(shifted)
+				}
+
+				countMu.Lock()
+				rep.Processed++
+				rep.Durations.Chunk += took
+				if err == nil {
+					res.chunks[path] = hashes
+					moved = append(moved, shifted...)
+					removed = append(removed, gone...)
+					rep.Chunks += len(hashes)
+					rep.Unchanged += kept
+				} else if prev[path] != nil {
+					if rel, relErr := filepath.Rel(root, path); relErr == nil {
+						reset = append(reset, rel)
+					}
+				}
+				if skipReason != "" {
+					rel, _ := filepath.Rel(root, path)
+					idx.skip(rep, rel, skipReason)
+				}
+				if err == nil && skipReason == "" {
+					res.languages[path] = language
+					idx.indexed.Add(1)
+					rep.Indexed++
+				}
+				if err != nil {
+					res.failed = append(res.failed, path)
+					idx.recordFailure(root, path, err)
+					idx.logger.Warn("error processing file", "project", root, "file", path, "err", err)
+				}
+				countMu.Unlock()
+				p.FileDone(path, err)
+
+				if err == nil && len(chunks) > 0 {
+					idx.summarize(drainCtx, chunks)
+					if err := b.add(chunks); err != nil {
+						stopPool()
+					}
+				}
+			}
+		}()
+	}
+
+	sent := 0
+	for _, path := range files {
+		if poolCtx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- path:
+			sent++
+		case <-poolCtx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	interrupted := ctx.Err()
+	if interrupted != nil {
+		// Not started, so kept as recorded for the next run
+		res.remaining = append(res.remaining, files[sent:]...)
+	}
+	b.flush()
+	p.Finish()
+	rep.Failed, rep.Remaining = len(res.failed), len(res.remaining)
+	b.addTo(rep)
+	if err := b.firstErr(); err != nil {
+		return nil, fmt.Errorf("upserting chunks: %w", err)
+	}
+	res.rejected = idx.rejectFiles(root, b.rejectedChunks(), res.chunks)
+	if len(removed) > 0 {
+		if _, err := idx.store.DeleteChunks(drainCtx, removed); err != nil {
+			return nil, fmt.Errorf("deleting chunks: %w", err)
+		}
+		rep.Removed = len(removed)
+	}
+	if len(moved) > 0 {
+		if err := idx.store.UpdateLines(drainCtx, moved); err != nil {
+			return nil, fmt.Errorf("updating chunk lines: %w", err)
+		}
+		rep.Moved = len(moved)
+	}
+	// Failed files are retried from scratch, so their old chunks go now
+	if len(reset) > 0 {
+		if _, err := idx.store.DeleteFiles(drainCtx, idx.projectPath(root), idx.filePaths(root, reset)); err != nil {
+			return nil, fmt.Errorf("deleting documents: %w", err)
+		}
+	}
+
+	if interrupted != nil {
+		idx.logger.Warn("interrupted, saving progress", "project", root, "processed", rep.Processed, "left", len(res.remaining))
+		return res, interrupted
+	}
+	if len(res.remaining) > 0 {
+		rels := make([]string, 0, len(res.remaining))
+		for _, path := range res.remaining {
+			if rel, err := filepath.Rel(root, path); err == nil {
+				rels = append(rels, rel)
+			}
+		}
+		idx.logger.Warn("embedding budget reached", "project", root, "remaining", len(rels))
+		idx.recordRemaining(root, rels)
+	}
+
+	idx.logger.Info("done", "project", root, "processed", rep.Processed, "failed", len(res.failed), "chunks", b.total,
+		"unchanged_chunks", rep.Unchanged, "moved_chunks", len(moved), "removed_chunks", len(removed), skippedAttr(skipped))
+	return res, nil
+}
+
+// drainContext returns a context that outlives ctx by drainTimeout, so
+// the work in flight when a run is interrupted can finish and be recorded.
+func drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(drainTimeout, cancel)
+	})
+	return drainCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// skippedAttr groups skip counts by reason for the summary log, e.g.
+// skipped.total=3 skipped.binary=2 "skipped.pattern *.pem"=1
+func skippedAttr(skipped map[string]int) slog.Attr {
+	reasons := make([]string, 0, len(skipped))
+	total := 0
+	for reason, n := range skipped {
+		reasons = append(reasons, reason)
+		total += n
+	}
+	sort.Strings(reasons)
+
+	attrs := []any{slog.Int("total", total)}
+	for _, reason := range reasons {
+		attrs = append(attrs, slog.Int(reason, skipped[reason]))
+	}
+	return slog.Group("skipped", attrs...)
+}
+
+// processFile reads, redacts and chunks a single file, returning its chunks
+// and language. Files over the size limit, binary files, files that aren't
+// UTF-8 and files flagged by the secrets scanner yield no chunks, only the
+// reason they were skipped ("too large", "binary", "encoding", "pattern
+// <pattern>", "rule <id>" or "hook <name>"). Findings accepted by the
+// project's secrets baseline are left as they are. The project's hooks
+// run on the content before it is chunked and on the chunks. Files
+// .gitattributes marks linguist-documentation are chunked as prose,
+// whatever their language. Files that look generated are skipped as
+// "generated" when skipGeneratedFiles is set.
+func (idx *Indexer) processFile(ctx context.Context, root string, projects projectTree, path string, baseline *secrets.Baseline, attrs *walker.Attributes) ([]IndexedChunk, string, string, error) {
+	relPath, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if pattern, skip := idx.scanner.ShouldSkipFile(relPath); skip {
+		return nil, "", "pattern " + pattern, nil
+	}
+	if idx.maxFileSize > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", "", &opError{op: OpRead, err: err}
+		}
+		if info.Size() > idx.maxFileSize {
+			return nil, "", skipTooLarge, nil
+		}
+	}
+
+	binary, err := walker.IsBinary(path)
+	if err != nil {
+		return nil, "", "", &opError{op: OpRead, err: err}
+	}
+	if binary {
+		return nil, "", skipBinary, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", "", &opError{op: OpRead, err: err}
+	}
+	if !utf8.Valid(data) {
+		return nil, "", skipEncoding, nil
+	}
+	content := string(data)
+	if idx.skipGeneratedFiles && attrs.Get(relPath, "linguist-generated") != "false" {
+		if why, ok := detector.DetectGenerated(content); ok {
+			idx.logger.Debug("skipping generated file", "file", path, "why", why)
+			return nil, "", skipGenerated, nil
+		}
+	}
+
+	contentScan, err := idx.scanner.ScanContent(content)
+	if err != nil {
+		return nil, "", "", &opError{op: OpScan, err: err}
+	}
+	contentScan = idx.scanner.Filter(contentScan, baseline, relPath)
+	if contentScan.ShouldSkip {
+		idx.logger.Info("skipping file with secrets", "file", path, "rule", contentScan.SkipRule)
+		return nil, "", "rule " + contentScan.SkipRule, nil
+	}
+	if len(contentScan.Findings) > 0 {
+		content = idx.scanner.Redact(content, contentScan.Findings)
+		idx.logger.Info("redacted secrets", "file", path, "findings", len(contentScan.Findings))
+	}
+
+	language := idx.detectLanguage(path, content)
+	content, vetoed, err := idx.preChunk(ctx, idx.projectPath(root), idx.filePath(root, relPath), language, content)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if vetoed != "" {
+		return nil, "", "hook " + vetoed, nil
+	}
+	var chunks []chunker.Chunk
+	if attrs.Get(relPath, "linguist-documentation") == "true" {
+		chunks, err = idx.chunker.ChunkText(content, language == "markdown")
+	} else {
+		chunks, err = idx.chunker.ChunkFile(path, content, language)
+	}
+	if err != nil {
+		return nil, "", "", &opError{op: OpChunk, err: err}
+	}
+
+	project := projects.nearest(relPath)
+	projectPath, filePath := idx.projectPath(root), idx.filePath(root, relPath)
+	now := time.Now().Unix()
+	indexed := make([]IndexedChunk, 0, len(chunks))
+	for _, c := range chunks {
+		indexed = append(indexed, IndexedChunk{
+			// Hooks refer to chunks by ID; setChunkIDs replaces it after
+			ID:          chunkID(projectPath, filePath, c.StartLine),
+			RelPath:     filePath,
+			AbsPath:     absPath(projectPath, filePath),
+			FilePath:    filePath,
+			ProjectPath: projectPath,
+			ProjectRoot: idx.projectRoot(root, project.Dir),
+			ProjectType: project.Type,
+			Language:    language,
+			ChunkType:   c.ChunkType,
+			Content:     c.Content,
+			Symbols:     c.Symbols,
+			HeadingPath: c.HeadingPath,
+			StartLine:   c.StartLine,
+			EndLine:     c.EndLine,
+			LastIndexed: now,
+		})
+	}
+	indexed, err = idx.postChunk(ctx, projectPath, filePath, indexed)
+	if err != nil {
+		return nil, "", "", err
+	}
+	setChunkIDs(indexed)
+	return indexed, language, "", nil
+}
+
+// mount is where the documents of a directory indexed in place of
+// something else are stored: the clone of a remote repository, or an
+// unpacked archive
+type mount struct {
+	project string // project path: the repository URL or archive path
+	prefix  string // prepended to file paths, e.g. "release.zip!/"
+}
+
+// projectPath returns the project path of the documents of the directory
+// at root: the project of its mount, else root itself
+func (idx *Indexer) projectPath(root string) string {
+	if m, ok := idx.mounts[root]; ok {
+		return m.project
+	}
+	return root
+}
+
+// filePath returns the file path of the documents of the file at relPath
+// in the directory at root. Separators are always slashes, so projects
+// indexed on Windows are searched like any other.
+func (idx *Indexer) filePath(root, relPath string) string {
+	if m, ok := idx.mounts[root]; ok && m.prefix != "" {
+		return m.prefix + filepath.ToSlash(relPath)
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// filePaths is filePath for several files
+func (idx *Indexer) filePaths(root string, relPaths []string) []string {
+	paths := make([]string, len(relPaths))
+	for i, rel := range relPaths {
+		paths[i] = idx.filePath(root, rel)
+	}
+	return paths
+}
+
+// projectRoot returns the ProjectRoot of documents in the project at dir,
+// relative to root
+func (idx *Indexer) projectRoot(root, dir string) string {
+	m, ok := idx.mounts[root]
+	switch {
+	case !ok:
+		return filepath.Join(root, dir)
+	case dir == ".":
+		return m.project
+	default:
+		return m.project + "/" + filepath.ToSlash(dir)
+	}
+}
+
+// detectLanguage returns the language of the file at path, preferring the
+// configured extension mappings to the detector's
+func (idx *Indexer) detectLanguage(path, content string) string {
+	if lang, ok := idx.languages[strings.ToLower(filepath.Ext(path))]; ok {
+		return lang
+	}
+	return detector.SniffLanguage(path, content)
+}
+
+// chunkHash identifies what a chunk's document holds apart from its
+// lines, embedding and timestamp. The chunks of files are identified by
+// it, so a chunk that only moved keeps its document and embedding.
+func chunkHash(c IndexedChunk) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s\x00%s",
+		c.ProjectRoot, c.ProjectType, c.Language, c.ChunkType, c.HeadingPath, c.Content)))
+	return hex.EncodeToString(h[:16])
+}
+
+// linesHash is the hash recorded for a chunk: its chunkHash and lines
+// together, so a chunk whose lines changed is told apart
+func linesHash(c IndexedChunk) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", chunkHash(c), c.StartLine, c.EndLine)))
+	return hex.EncodeToString(h[:16])
+}
+
+// chunkHashes returns the recorded hash of each chunk by ID
+func chunkHashes(chunks []IndexedChunk) map[string]string {
+	hashes := make(map[string]string, len(chunks))
+	for _, c := range chunks {
+		hashes[c.ID] = linesHash(c)
+	}
+	return hashes
+}
+
+// reconcileChunks compares a file's new chunks, whose hashes are given,
+// against the hashes recorded when it was last indexed. It returns the
+// chunks that are new or changed, those that only moved to other lines,
+// and the IDs of recorded chunks that are gone. Without a record every
+// chunk is returned as changed.
+func reconcileChunks(chunks []IndexedChunk, hashes, prev map[string]string) (changed, moved []IndexedChunk, removed []string) {
+	if prev == nil {
+		return chunks, nil, nil
+	}
+	for _, c := range chunks {
+		old, ok := prev[c.ID]
+		switch {
+		case !ok:
+			changed = append(changed, c)
+		case old != hashes[c.ID]:
+			moved = append(moved, c)
+		}
+	}
+	for id := range prev {
+		if _, ok := hashes[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+	return changed, moved, removed
+}
+
+// chunkID derives a stable document ID from the chunk's location, for
+// documents that are replaced as a whole
+func chunkID(root, relPath string, startLine int) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", root, relPath, startLine)))
+	return hex.EncodeToString(h[:16])
+}
+
+// contentChunkID derives the document ID of the nth chunk with the given
+// chunkHash in a file
+func contentChunkID(root, relPath, hash string, n int) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d", root, relPath, hash, n)))
+	return hex.EncodeToString(h[:16])
+}
+
+// setChunkIDs identifies each of a file's chunks by its contents,
+// numbering chunks with the same chunkHash in order, so a chunk keeps its
+// ID when lines are added or removed around it
+func setChunkIDs(chunks []IndexedChunk) {
+	seen := map[string]int{}
+	for i := range chunks {
+		h := chunkHash(chunks[i])
+		chunks[i].ID = contentChunkID(chunks[i].ProjectPath, chunks[i].RelPath, h, seen[h])
+		seen[h]++
+	}
+}
+
+// embedText is the text a chunk's embedding is computed from: its content,
+// after its heading path and summary if it has them, so sections deep in
+// a document match queries about their parent topics and dense code
+// matches plain-language queries
+func embedText(c IndexedChunk) string {
+	text := c.Content
+	if c.Summary != "" {
+		text = c.Summary + "\n\n" + text
+	}
+	if c.HeadingPath != "" {
+		text = c.HeadingPath + "\n\n" + text
+	}
+	return text
+}
+
+// summarizedTypes are the chunk types worth a summary: code with a body
+var summarizedTypes = map[string]bool{"function": true, "class": true, "code": true}
+
+// summarize fills in the summaries of code chunks when a summarizer is
+// set. A chunk whose summary fails is embedded without one.
+func (idx *Indexer) summarize(ctx context.Context, chunks []IndexedChunk) {
+	if idx.summarizer == nil {
+		return
+	}
+	for i := range chunks {
+		c := &chunks[i]
+		if !summarizedTypes[c.ChunkType] {
+			continue
+		}
+		summary, err := idx.summarizer.Summarize(ctx, c.Language, c.Content)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			idx.logger.Warn("error summarizing chunk", "file", c.FilePath, "line", c.StartLine, "err", err)
+			continue
+		}
+		c.Summary = summary
+	}
+}
+
+// batcher streams the chunks of processed files to the store: an
+// assembler cuts them into batches, a pool of embedders embeds the
+// batches and a pool of upserters upserts them, concurrently with the
+// files still being processed. The stages are connected by queues
+// of at most idx.maxQueuedBatches batches, so once a stage falls behind,
+// the ones before it block and memory stays bounded however large the
+// project. The queues' depths are reported to the progress as "embed"
+// and "upsert".
+type batcher struct {
+	idx      *Indexer
+	progress Progress
+	in       chan []IndexedChunk // chunks of a file, to cut into batches
+	batches  chan []IndexedChunk // full batches, to embed
+	embedded chan []IndexedChunk // embedded batches, to upsert
+	done     sync.WaitGroup      // every stage
+	closed   sync.Once
+
+	mu    sync.Mutex
+	total int // chunks upserted
+	err   error
+	// rejected holds the chunks the store rejected, by ID, with its
+	// reason; the rest of their batches were upserted
+	rejected map[string]string
+	// failed counts the batches not upserted; embedTime and upsertTime
+	// add up the time batches took in each stage
+	failed     int
+	embedTime  time.Duration
+	upsertTime time.Duration
+}
+
+// newBatcher starts the stages of a batcher. Its batches are embedded
+// and upserted with ctx. flush must be called to stop them.
+func newBatcher(ctx context.Context, idx *Indexer, p Progress) *batcher {
+	b := &batcher{
+		idx:      idx,
+		progress: p,
+		in:       make(chan []IndexedChunk, idx.workers),
+		batches:  make(chan []IndexedChunk, idx.maxQueuedBatches),
+		embedded: make(chan []IndexedChunk, idx.maxQueuedBatches),
+	}
+	b.done.Add(1)
+	go func() {
+		defer b.done.Done()
+		b.assemble()
+	}()
+
+	// After a failure the rest of the batches are dropped: the run fails
+	// anyway
+	var embedders sync.WaitGroup
+	for i := 0; i < idx.embedWorkers; i++ {
+		embedders.Add(1)
+		go func() {
+			defer embedders.Done()
+			for batch := range b.batches {
+				b.reportDepths()
+				if b.firstErr() != nil {
+					b.fail(nil, batch)
+					continue
+				}
+				if batch, err := b.embed(ctx, batch); err != nil {
+					b.fail(err, batch)
+				} else if len(batch) > 0 {
+					b.embedded <- batch
+					b.reportDepths()
+				}
+			}
+		}()
+	}
+	b.done.Add(1)
+	go func() {
+		defer b.done.Done()
+		embedders.Wait()
+		close(b.embedded)
+	}()
+
+	for i := 0; i < idx.upsertWorkers; i++ {
+		b.done.Add(1)
+		go func() {
+			defer b.done.Done()
+			for batch := range b.embedded {
+				b.reportDepths()
+				if b.firstErr() != nil {
+					b.fail(nil, batch)
+					continue
+				}
+				if err := b.upsert(ctx, batch); err != nil {
+					b.fail(err, batch)
+				}
+			}
+		}()
+	}
+	return b
+}
+
+// assemble cuts the chunks coming in into batches of the batch size,
+// sending what is left once the input is closed
+func (b *batcher) assemble() {
+	size := b.idx.batchSize
+	var pending []IndexedChunk
+	for chunks := range b.in {
+		pending = append(pending, chunks...)
+		for len(pending) >= size {
+			b.batches <- pending[:size:size]
+			pending = pending[size:]
+			b.reportDepths()
+		}
+	}
+	if len(pending) > 0 {
+		b.batches <- pending
+		b.reportDepths()
+	}
+	close(b.batches)
+}
+
+// add hands chunks to be batched, embedded and upserted, blocking while
+// the queues are full. It returns the first error sending a batch so far,
+// so callers can stop early.
+func (b *batcher) add(chunks []IndexedChunk) error {
+	if len(chunks) > 0 {
+		b.idx.queueTokens(chunks)
+		b.in <- chunks
+	}
+	return b.firstErr()
+}
+
+// flush sends the chunks not yet sent and waits for every batch; any
+// error is reported by firstErr. Calls after the first do nothing.
+func (b *batcher) flush() {
+	b.closed.Do(func() {
+		close(b.in)
+		b.done.Wait()
+	})
+}
+
+// reportDepths reports how many batches wait to be embedded and upserted
+func (b *batcher) reportDepths() {
+	b.progress.QueueDepth("embed", len(b.batches), cap(b.batches))
+	b.progress.QueueDepth("upsert", len(b.embedded), cap(b.embedded))
+}
+
+// addTo adds what the batcher sent, and how long it took, to rep
+func (b *batcher) addTo(rep *PathReport) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rep.Embedded += b.total
+	rep.FailedBatches += b.failed
+	rep.Durations.Embed += b.embedTime
+	rep.Durations.Upsert += b.upsertTime
+}
+
+func (b *batcher) firstErr() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// fail records a batch that wasn't upserted, and the error sending it if
+// it is the first. Batches dropped after the first error have none.
+func (b *batcher) fail(err error, batch []IndexedChunk) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed++
+	if err != nil && b.err == nil {
+		b.idx.logger.Error("error sending batch", "chunks", len(batch), "err", err)
+		b.err = err
+	}
+}
+
+// embed runs the pre-upsert hooks on a batch, then embeds the chunks they
+// kept
+func (b *batcher) embed(ctx context.Context, batch []IndexedChunk) ([]IndexedChunk, error) {
+	batch, err := b.idx.preUpsert(ctx, batch)
+	if err != nil || len(batch) == 0 {
+		return batch, err
+	}
+	texts := make([]string, len(batch))
+	for i, c := range batch {
+		texts[i] = embedText(c)
+	}
+
+	start := time.Now()
+	embedCtx, span := tracing.StartSpan(ctx, "embed", attribute.Int("chunks", len(batch)))
+	vectors, err := b.idx.embedder.EmbedBatch(embedCtx, texts)
+	tracing.End(span, err)
+	b.mu.Lock()
+	b.embedTime += time.Since(start)
+	b.mu.Unlock()
+	if err != nil {
+		return batch, fmt.Errorf("embedding batch: %w", err)
+	}
+	b.idx.recordEmbed(texts)
+	if len(vectors) != len(batch) {
+		return batch, fmt.Errorf("embedding batch: got %d embeddings for %d chunks", len(vectors), len(batch))
+	}
+	b.idx.setEmbeddings(batch, vectors)
+	return batch, nil
+}
+
+func (b *batcher) upsert(ctx context.Context, batch []IndexedChunk) error {
+	start := time.Now()
+	upsertCtx, span := tracing.StartSpan(ctx, "upsert", attribute.Int("chunks", len(batch)))
+	err := b.idx.store.UpsertChunks(upsertCtx, batch)
+	tracing.End(span, err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.upsertTime += time.Since(start)
+	var ie *ImportError
+	if errors.As(err, &ie) {
+		if b.rejected == nil {
+			b.rejected = map[string]string{}
+		}
+		for id, msg := range ie.Failed {
+			b.rejected[id] = msg
+		}
+	} else if err != nil {
+		return err
+	}
+	upserted := len(batch)
+	if ie != nil {
+		upserted -= len(ie.Failed)
+	}
+	b.total += upserted
+	b.progress.ChunksEmbedded(upserted)
+	return nil
+}
+
+// rejectedChunks returns the chunks the store rejected, by ID, with its
+// reason. Call it once the batcher is flushed.
+func (b *batcher) rejectedChunks() map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rejected
+}
+
+// rejectFiles fails, at the upsert stage, the files of root with chunks
+// the store rejected. files holds the chunk hashes of each file by ID;
+// the hashes of the rejected chunks are dropped so they are upserted
+// again. It returns the files failed.
+func (idx *Indexer) rejectFiles(root string, rejected map[string]string, files map[string]map[string]string) []string {
+	if len(rejected) == 0 {
+		return nil
+	}
+	var failed []string
+	for path, hashes := range files {
+		var reasons []string
+		for id := range hashes {
+			if msg, ok := rejected[id]; ok {
+				reasons = append(reasons, msg)
+				delete(hashes, id)
+			}
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		failed = append(failed, path)
+		err := fmt.Errorf("%d chunks rejected: %s", len(reasons), reasons[0])
+		idx.recordFailure(root, path, &opError{op: OpUpsert, err: err})
+		idx.logger.Warn("chunks rejected", "project", root, "file", path, "chunks", len(reasons), "err", reasons[0])
+	}
+	sort.Strings(failed)
+	return failed
+}
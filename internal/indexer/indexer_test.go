@@ -0,0 +1,2760 @@
+package indexer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dvaida/swarm-indexer/internal/archive"
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/crawl"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/progress"
+	"github.com/dvaida/swarm-indexer/internal/queue"
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+	"github.com/dvaida/swarm-indexer/internal/source"
+	"github.com/dvaida/swarm-indexer/internal/testenv"
+	"github.com/dvaida/swarm-indexer/internal/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestMain keeps metadata written by the tests out of the user's data dir.
+func TestMain(m *testing.M) {
+	testenv.Main(m)
+}
+
+type fakeStore struct {
+	mu           sync.Mutex
+	batches      [][]IndexedChunk
+	deleted      []string
+	deletedFiles []string
+	deletedIDs   []string
+	movedIDs     []string
+	err          error
+	// reject makes upserts reject the chunks whose content contains it
+	reject string
+}
+
+func (f *fakeStore) DeleteByProject(ctx context.Context, projectPath string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, projectPath)
+	n := 0
+	for i, b := range f.batches {
+		kept := b[:0]
+		for _, c := range b {
+			if c.ProjectPath == projectPath {
+				n++
+				continue
+			}
+			kept = append(kept, c)
+		}
+		f.batches[i] = kept
+	}
+	return n, nil
+}
+
+func (f *fakeStore) DeleteFiles(ctx context.Context, projectPath string, relPaths []string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deletedFiles = append(f.deletedFiles, relPaths...)
+	remove := map[string]bool{}
+	for _, p := range relPaths {
+		remove[p] = true
+	}
+	n := 0
+	for i, b := range f.batches {
+		kept := b[:0]
+		for _, c := range b {
+			if c.ProjectPath == projectPath && remove[c.FilePath] {
+				n++
+				continue
+			}
+			kept = append(kept, c)
+		}
+		f.batches[i] = kept
+	}
+	return n, nil
+}
+
+func (f *fakeStore) DeleteChunks(ctx context.Context, ids []string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deletedIDs = append(f.deletedIDs, ids...)
+	remove := map[string]bool{}
+	for _, id := range ids {
+		remove[id] = true
+	}
+	n := 0
+	for i, b := range f.batches {
+		kept := b[:0]
+		for _, c := range b {
+			if remove[c.ID] {
+				n++
+				continue
+			}
+			kept = append(kept, c)
+		}
+		f.batches[i] = kept
+	}
+	return n, nil
+}
+
+func (f *fakeStore) UpdateLines(ctx context.Context, chunks []IndexedChunk) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, moved := range chunks {
+		f.movedIDs = append(f.movedIDs, moved.ID)
+		for _, b := range f.batches {
+			for i := range b {
+				if b[i].ID == moved.ID {
+					b[i].StartLine, b[i].EndLine, b[i].LastIndexed = moved.StartLine, moved.EndLine, moved.LastIndexed
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) UpdateTags(ctx context.Context, projectPath string, tags []string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, b := range f.batches {
+		for i := range b {
+			if b[i].ProjectPath == projectPath {
+				b[i].Tags = tags
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeStore) TombstoneFiles(ctx context.Context, projectPath string, relPaths []string, deletedAt int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	gone := map[string]bool{}
+	for _, p := range relPaths {
+		gone[p] = true
+	}
+	n := 0
+	for _, b := range f.batches {
+		for i := range b {
+			if b[i].ProjectPath == projectPath && gone[b[i].FilePath] {
+				b[i].Deleted, b[i].DeletedAt = true, deletedAt
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+func (f *fakeStore) ExportProject(ctx context.Context, projectPath string, fn func(IndexedChunk) error) error {
+	for _, c := range f.chunks() {
+		if c.ProjectPath != projectPath {
+			continue
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) UpsertChunks(ctx context.Context, chunks []IndexedChunk) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	var batch []IndexedChunk
+	rejected := &ImportError{Failed: map[string]string{}}
+	for _, c := range chunks {
+		if f.reject != "" && strings.Contains(c.Content, f.reject) {
+			rejected.Failed[c.ID] = "Bad JSON."
+			continue
+		}
+		batch = append(batch, c)
+	}
+	f.batches = append(f.batches, batch)
+	if len(rejected.Failed) > 0 {
+		return rejected
+	}
+	return nil
+}
+
+func (f *fakeStore) chunks() []IndexedChunk {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Like Typesense, an upsert replaces the document with the same ID
+	var all []IndexedChunk
+	pos := map[string]int{}
+	for _, b := range f.batches {
+		for _, c := range b {
+			if i, ok := pos[c.ID]; ok {
+				all[i] = c
+				continue
+			}
+			pos[c.ID] = len(all)
+			all = append(all, c)
+		}
+	}
+	return all
+}
+
+type fakeEmbedder struct {
+	err     error
+	onEmbed func() // called before each batch is embedded, if set
+}
+
+func (f *fakeEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if f.onEmbed != nil {
+		f.onEmbed()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i := range texts {
+		vectors[i] = []float32{float32(len(texts[i])), 1}
+	}
+	return vectors, nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n\nfunc helper() {\n}\n")
+	writeFile(t, filepath.Join(dir, "docs", "README.md"), "# Title\n\nIntro\n\n## Usage\n\nRun it\n")
+	writeFile(t, filepath.Join(dir, "image.bin"), "\x00\x01\x02binary")
+	return dir
+}
+
+func TestIndexPaths_FullPipeline(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{Workers: 2, BatchSize: 100}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	chunks := store.chunks()
+	if len(chunks) == 0 {
+		t.Fatal("expected chunks to be upserted")
+	}
+
+	files := map[string]bool{}
+	for _, c := range chunks {
+		files[c.FilePath] = true
+		if c.ProjectPath != dir {
+			t.Errorf("expected project path %s, got %s", dir, c.ProjectPath)
+		}
+		if c.RelPath != c.FilePath || c.AbsPath != dir+"/"+c.RelPath {
+			t.Errorf("expected relative and absolute paths of %s, got %q and %q", c.FilePath, c.RelPath, c.AbsPath)
+		}
+		if len(c.Embedding) == 0 {
+			t.Errorf("expected embedding on chunk %s:%d", c.FilePath, c.StartLine)
+		}
+		if c.ID == "" {
+			t.Error("expected chunk ID to be set")
+		}
+	}
+	if !files["main.go"] || !files[filepath.Join("docs", "README.md")] {
+		t.Errorf("expected main.go and docs/README.md to be indexed, got %v", files)
+	}
+	if files["image.bin"] {
+		t.Error("binary file should not be indexed")
+	}
+	if files[metadata.MetadataFileName] {
+		t.Error("metadata file should not be indexed")
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatalf("loading metadata: %v", err)
+	}
+	if meta.LastIndexed == 0 {
+		t.Error("expected LastIndexed to be set")
+	}
+	if meta.FileCount != 3 {
+		t.Errorf("expected FileCount 3, got %d", meta.FileCount)
+	}
+	if len(meta.Files) != 3 {
+		t.Errorf("expected file-level state for 3 files, got %d", len(meta.Files))
+	}
+	for rel, state := range meta.Files {
+		if state.Hash == "" {
+			t.Errorf("expected a content hash for %s", rel)
+		}
+	}
+}
+
+func TestIndexPaths_SkipsUnchanged(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("first IndexPaths failed: %v", err)
+	}
+	first := len(store.chunks())
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+	if len(store.chunks()) != first {
+		t.Errorf("expected unchanged directory to be skipped, got %d new chunks", len(store.chunks())-first)
+	}
+}
+
+func TestIndexPaths_ReindexAfter(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{ReindexAfter: time.Hour}, store, &fakeEmbedder{})
+	upserted := func() int {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		n := 0
+		for _, b := range store.batches {
+			n += len(b)
+		}
+		return n
+	}
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("first IndexPaths failed: %v", err)
+	}
+	first := upserted()
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+	if upserted() != first {
+		t.Fatalf("expected a recent index to be left alone, got %d more chunks", upserted()-first)
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.LastIndexed = time.Now().Add(-2 * time.Hour).Unix()
+	if err := meta.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("third IndexPaths failed: %v", err)
+	}
+	if upserted() != 2*first {
+		t.Errorf("expected an old index to be redone with no changes, got %d chunks after %d", upserted(), first)
+	}
+	if meta, _ := metadata.Load(dir); time.Since(time.Unix(meta.LastIndexed, 0)) > time.Minute {
+		t.Errorf("expected LastIndexed to be updated, got %d", meta.LastIndexed)
+	}
+}
+
+func TestIndexPaths_DeleteGrace(t *testing.T) {
+	dir := testProject(t)
+	writeFile(t, filepath.Join(dir, "old.go"), "package main\n\nfunc old() {\n}\n")
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{DeleteGrace: 72 * time.Hour}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("first IndexPaths failed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "old.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+
+	if len(store.deletedFiles) != 0 {
+		t.Errorf("expected no documents deleted, got %v", store.deletedFiles)
+	}
+	var tombstones int
+	for _, c := range store.chunks() {
+		if c.Deleted != (c.FilePath == "old.go") {
+			t.Errorf("%s: unexpected tombstone state %v", c.FilePath, c.Deleted)
+		}
+		if c.Deleted {
+			tombstones++
+			if c.DeletedAt == 0 {
+				t.Errorf("%s: expected the deletion time recorded", c.FilePath)
+			}
+		}
+	}
+	if tombstones == 0 {
+		t.Error("expected the removed file's documents to be tombstoned")
+	}
+}
+
+func TestIndexPaths_Excludes(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	hasFile := func(rel string) bool {
+		for _, c := range store.chunks() {
+			if c.FilePath == rel {
+				return true
+			}
+		}
+		return false
+	}
+
+	idx.SetExcludes(secrets.SkipPatterns{"docs/**"})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if hasFile("docs/README.md") || !hasFile("main.go") {
+		t.Fatalf("expected only docs/ left out, got %+v", store.chunks())
+	}
+
+	idx.SetExcludes(nil)
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if !hasFile("docs/README.md") {
+		t.Fatal("expected docs/README.md indexed once no longer excluded")
+	}
+
+	// Excluding a file already indexed removes it
+	idx.SetExcludes(secrets.SkipPatterns{"*.md"})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if hasFile("docs/README.md") || !hasFile("main.go") {
+		t.Errorf("expected docs/README.md removed, got %+v", store.chunks())
+	}
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := meta.Files[filepath.Join("docs", "README.md")]; ok {
+		t.Error("expected the excluded file dropped from the metadata")
+	}
+}
+
+func TestIndexPaths_LinguistAttributes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n")
+	writeFile(t, filepath.Join(dir, "api.pb.go"), "package main\n\nfunc Marshal() {\n}\n")
+	writeFile(t, filepath.Join(dir, "third_party", "lib.go"), "package lib\n")
+	writeFile(t, filepath.Join(dir, "examples", "demo.go"), "package main\n\nfunc demo() {\n}\n")
+	writeFile(t, filepath.Join(dir, ".gitattributes"), "*.pb.go linguist-generated\nthird_party/** linguist-vendored\nexamples/** linguist-documentation\n")
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	idx.SetListSkipped(true)
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	chunkTypes := map[string]string{}
+	for _, c := range store.chunks() {
+		chunkTypes[c.FilePath] = c.ChunkType
+	}
+	if _, ok := chunkTypes["api.pb.go"]; ok {
+		t.Error("expected the generated file left out")
+	}
+	if _, ok := chunkTypes["third_party/lib.go"]; ok {
+		t.Error("expected the vendored file left out")
+	}
+	if chunkTypes["main.go"] != "function" || chunkTypes["examples/demo.go"] != "paragraph" {
+		t.Errorf("expected documentation chunked as prose, got %v", chunkTypes)
+	}
+	r := idx.Report()[0]
+	if r.Skipped["generated"] != 1 || r.Skipped["vendored"] != 1 {
+		t.Errorf("unexpected skip counts %v", r.Skipped)
+	}
+
+	// Unmarking a file indexes it, and marking one removes its documents
+	writeFile(t, filepath.Join(dir, ".gitattributes"), "main.go linguist-generated\n")
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	chunkTypes = map[string]string{}
+	for _, c := range store.chunks() {
+		chunkTypes[c.FilePath] = c.ChunkType
+	}
+	if _, ok := chunkTypes["main.go"]; ok {
+		t.Errorf("expected main.go removed, got %v", chunkTypes)
+	}
+	if _, ok := chunkTypes["api.pb.go"]; !ok {
+		t.Errorf("expected api.pb.go indexed, got %v", chunkTypes)
+	}
+}
+
+func TestIndexPaths_ChunkSize(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "notes.txt"), strings.Repeat("Every deployment starts from a tagged release.\n", 40))
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{ChunkSize: 600}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	chunks := store.chunks()
+	if len(chunks) < 3 {
+		t.Fatalf("expected the file split into chunks of 600 characters, got %d", len(chunks))
+	}
+	for _, c := range chunks {
+		if len(c.Content) > 600 {
+			t.Errorf("chunk at line %d exceeds 600 characters: %d", c.StartLine, len(c.Content))
+		}
+	}
+}
+
+func TestIndexPaths_SkipGenerated(t *testing.T) {
+	for _, skip := range []bool{true, false} {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n")
+		writeFile(t, filepath.Join(dir, "api.pb.go"), "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage main\n")
+		writeFile(t, filepath.Join(dir, "zz_keep.go"), "// Code generated by hand-me-down. DO NOT EDIT.\n\npackage main\n")
+		writeFile(t, filepath.Join(dir, ".gitattributes"), "zz_keep.go -linguist-generated\n")
+
+		store := &fakeStore{}
+		idx := NewIndexer(&config.Config{SkipGenerated: skip}, store, &fakeEmbedder{})
+		if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+			t.Fatalf("IndexPaths failed: %v", err)
+		}
+		files := map[string]bool{}
+		for _, c := range store.chunks() {
+			files[c.FilePath] = true
+		}
+		if files["api.pb.go"] == skip || !files["main.go"] || !files["zz_keep.go"] {
+			t.Errorf("skip_generated=%v: unexpected files indexed %v", skip, files)
+		}
+		if r := idx.Report()[0]; skip && r.Skipped["generated"] != 1 {
+			t.Errorf("expected the generated file counted as skipped, got %v", r.Skipped)
+		}
+	}
+}
+
+func TestIndexPaths_Tags(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	ctx := context.Background()
+	tagsOf := func() map[string]bool {
+		seen := map[string]bool{}
+		for _, c := range store.chunks() {
+			seen[strings.Join(c.Tags, ",")] = true
+		}
+		return seen
+	}
+
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	idx.SetTags([]string{"payments", "backend", " ", "payments"})
+	if err := idx.IndexPaths(ctx, []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if got := tagsOf(); len(got) != 1 || !got["backend,payments"] {
+		t.Errorf("expected every chunk tagged backend,payments, got %v", got)
+	}
+
+	// Later runs without tags keep the project's
+	writeFile(t, filepath.Join(dir, "extra.go"), "package main\n\nfunc extra() {\n}\n")
+	if err := NewIndexer(&config.Config{}, store, &fakeEmbedder{}).IndexPaths(ctx, []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if got := tagsOf(); len(got) != 1 || !got["backend,payments"] {
+		t.Errorf("expected new chunks to keep the project's tags, got %v", got)
+	}
+
+	// New tags on an unchanged project retag its documents in place
+	upserted := len(store.batches)
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	idx.SetTags([]string{"frontend"})
+	if err := idx.IndexPaths(ctx, []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if len(store.batches) != upserted {
+		t.Errorf("expected no chunks re-embedded, got %d new batches", len(store.batches)-upserted)
+	}
+	if got := tagsOf(); len(got) != 1 || !got["frontend"] {
+		t.Errorf("expected every chunk retagged frontend, got %v", got)
+	}
+
+	// Reindexing from scratch keeps them too
+	if err := NewIndexer(&config.Config{}, store, &fakeEmbedder{}).Reindex(ctx, []string{dir}, true); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if got := tagsOf(); len(got) != 1 || !got["frontend"] {
+		t.Errorf("expected reindexed chunks tagged frontend, got %v", got)
+	}
+	meta, err := metadata.Load(dir)
+	if err != nil || strings.Join(meta.Tags, ",") != "frontend" {
+		t.Errorf("expected the tags recorded, got %v (err %v)", meta.Tags, err)
+	}
+}
+
+// modelEmbedder embeds every text as the same vector, standing in for a
+// different model
+type modelEmbedder []float32
+
+func (m modelEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i := range vectors {
+		vectors[i] = m
+	}
+	return vectors, nil
+}
+
+func (m modelEmbedder) Model() string { return "new-model" }
+
+func TestReembed(t *testing.T) {
+	dir, other := testProject(t), testProject(t)
+	store := &fakeStore{}
+	if err := NewIndexer(&config.Config{GeminiModel: "old-model"}, store, &fakeEmbedder{}).IndexPaths(context.Background(), []string{dir, other}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	before := map[string]IndexedChunk{}
+	for _, c := range store.chunks() {
+		before[c.ID] = c
+	}
+
+	idx := NewIndexer(&config.Config{BatchSize: 2}, store, modelEmbedder{7, 7})
+	n, err := idx.Reembed(context.Background(), []string{dir})
+	if err != nil {
+		t.Fatalf("Reembed failed: %v", err)
+	}
+
+	var want int
+	for _, c := range store.chunks() {
+		reembedded := c.Embedding[0] == 7
+		if reembedded != (c.ProjectPath == dir) {
+			t.Errorf("%s in %s: unexpected embedding %v", c.FilePath, c.ProjectPath, c.Embedding)
+		}
+		if c.ProjectPath == dir {
+			want++
+		}
+		model := "old-model"
+		if reembedded {
+			model = "new-model"
+		}
+		if c.EmbeddingModel != model || c.EmbeddingDim != len(c.Embedding) {
+			t.Errorf("%s in %s: expected the embedding recorded as %s, got %s (%d dimensions)", c.FilePath, c.ProjectPath, model, c.EmbeddingModel, c.EmbeddingDim)
+		}
+		old := before[c.ID]
+		old.Embedding, old.EmbeddingModel, old.EmbeddingDim = c.Embedding, c.EmbeddingModel, c.EmbeddingDim
+		if !reflect.DeepEqual(old, c) {
+			t.Errorf("expected only the embedding to change, got %+v, was %+v", c, before[c.ID])
+		}
+	}
+	if n != want || len(store.chunks()) != len(before) {
+		t.Errorf("expected %d documents re-embedded in place, got %d (%d documents, was %d)", want, n, len(store.chunks()), len(before))
+	}
+	if u := idx.Usage(); u.Chunks != int64(want) {
+		t.Errorf("expected the embedding usage recorded, got %+v", u)
+	}
+}
+
+func TestMove(t *testing.T) {
+	from := testProject(t)
+	to := filepath.Join(t.TempDir(), "moved")
+	store := &fakeStore{}
+	ctx := context.Background()
+
+	if err := NewIndexer(&config.Config{}, store, &fakeEmbedder{}).IndexPaths(ctx, []string{from}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	indexed := len(store.chunks())
+	if err := os.Rename(from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewIndexer(&config.Config{}, store, &fakeEmbedder{}).Move(ctx, from, to)
+	if err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if n != indexed {
+		t.Errorf("expected %d documents moved, got %d", indexed, n)
+	}
+	for _, c := range store.chunks() {
+		if c.ProjectPath != to || c.ProjectRoot != to || c.AbsPath != to+"/"+c.RelPath || c.ID != contentChunkID(to, c.RelPath, chunkHash(c), 0) || c.Embedding == nil {
+			t.Errorf("expected chunk moved to %s with its embedding, got %+v", to, c)
+		}
+	}
+	if metadata.Exists(from) || !metadata.Exists(to) {
+		t.Error("expected the metadata moved")
+	}
+
+	// Nothing is re-embedded at the new path
+	embedded := 0
+	embedder := &fakeEmbedder{onEmbed: func() { embedded++ }}
+	writeFile(t, filepath.Join(to, "main.go"), "package main\n\nfunc main() {\n\tprintln(\"moved\")\n}\n")
+	if err := NewIndexer(&config.Config{}, store, embedder).IndexPaths(ctx, []string{to}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if embedded != 1 {
+		t.Errorf("expected only the modified file embedded, got %d batches", embedded)
+	}
+
+	if _, err := NewIndexer(&config.Config{}, store, &fakeEmbedder{}).Move(ctx, from, to); err == nil {
+		t.Error("expected moving a project that isn't indexed to fail")
+	}
+}
+
+func TestIndexPaths_OnlyChangedFiles(t *testing.T) {
+	dir := testProject(t)
+	writeFile(t, filepath.Join(dir, "old.go"), "package main\n\nfunc old() {\n}\n")
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("first IndexPaths failed: %v", err)
+	}
+	upserted := len(store.batches)
+	countMain := func() int {
+		n := 0
+		for _, c := range store.chunks() {
+			if c.FilePath == "main.go" {
+				n++
+			}
+		}
+		return n
+	}
+	before := countMain()
+
+	// One file modified, one touched, one deleted
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "docs", "README.md"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "old.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+
+	// main.go is reconciled chunk by chunk: only the removed function's
+	// chunk is deleted
+	if want := []string{"old.go"}; !reflect.DeepEqual(store.deletedFiles, want) {
+		t.Errorf("expected chunks of %v to be deleted, got %v", want, store.deletedFiles)
+	}
+	if len(store.deletedIDs) != 1 {
+		t.Errorf("expected one chunk of main.go to be deleted, got %v", store.deletedIDs)
+	}
+	for _, b := range store.batches[upserted:] {
+		for _, c := range b {
+			if c.FilePath != "main.go" {
+				t.Errorf("expected only main.go to be re-indexed, got %s", c.FilePath)
+			}
+		}
+	}
+	for _, c := range store.chunks() {
+		if c.FilePath == "old.go" {
+			t.Error("expected chunks of the deleted file to be gone")
+		}
+	}
+	if after := countMain(); after != before-1 {
+		t.Errorf("expected the removed function's chunk to be gone, got %d chunks for main.go, had %d", after, before)
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := meta.Files["old.go"]; ok {
+		t.Error("expected the deleted file to be dropped from the state")
+	}
+	readme := meta.Files[filepath.Join("docs", "README.md")]
+	if readme.ModTime != later.UnixNano() {
+		t.Errorf("expected the touched file's new mtime to be recorded, got %d", readme.ModTime)
+	}
+	if readme.Language == "" || readme.Chunks == nil {
+		t.Errorf("expected the touched file to keep its language and chunks, got %+v", readme)
+	}
+}
+
+func TestIndexPaths_ProjectTypeAndLanguages(t *testing.T) {
+	dir := testProject(t)
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/x\n\ngo 1.22\n\nrequire github.com/spf13/cobra v1.8.0\n")
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	for _, c := range store.chunks() {
+		if c.ProjectType != "go" {
+			t.Errorf("expected project type go on %s, got %q", c.FilePath, c.ProjectType)
+		}
+	}
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.ProjectType != "go" {
+		t.Errorf("expected project type go, got %q", meta.ProjectType)
+	}
+	// The binary file is skipped and go.mod has no known language
+	if want := []string{"go", "markdown"}; !reflect.DeepEqual(meta.Languages, want) {
+		t.Errorf("expected languages %v, got %v", want, meta.Languages)
+	}
+	if meta.Dependencies["github.com/spf13/cobra"] != "v1.8.0" {
+		t.Errorf("expected dependencies from go.mod, got %v", meta.Dependencies)
+	}
+
+	// Languages of deleted files go away on the next run
+	if err := os.RemoveAll(filepath.Join(dir, "docs")); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+	if meta, _ := metadata.Load(dir); !reflect.DeepEqual(meta.Languages, []string{"go"}) {
+		t.Errorf("expected languages [go] after deleting the docs, got %v", meta.Languages)
+	}
+}
+
+func TestIndexPaths_NestedProjects(t *testing.T) {
+	dir := testProject(t)
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/x\n")
+	writeFile(t, filepath.Join(dir, "web", "package.json"), "{}")
+	writeFile(t, filepath.Join(dir, "web", "src", "app.js"), "function app() {\n}\n")
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	want := map[string][2]string{
+		"main.go":                             {dir, "go"},
+		filepath.Join("web", "src", "app.js"): {filepath.Join(dir, "web"), "node"},
+	}
+	for _, c := range store.chunks() {
+		w, ok := want[c.FilePath]
+		if !ok {
+			continue
+		}
+		if c.ProjectRoot != w[0] || c.ProjectType != w[1] {
+			t.Errorf("expected %s to be tagged %s (%s), got %s (%s)", c.FilePath, w[0], w[1], c.ProjectRoot, c.ProjectType)
+		}
+		if c.ProjectPath != dir {
+			t.Errorf("expected project path %s on %s, got %s", dir, c.FilePath, c.ProjectPath)
+		}
+	}
+	if meta, _ := metadata.Load(dir); meta.ProjectType != "go" {
+		t.Errorf("expected the indexed path to keep project type go, got %q", meta.ProjectType)
+	}
+}
+
+func TestIndexPaths_ConfiguredLanguages(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "page.gotmpl"), "func a() {\n}\n\nfunc b() {\n}\n")
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{Languages: map[string]string{".gotmpl": "go"}}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	chunks := store.chunks()
+	if len(chunks) != 2 {
+		t.Fatalf("expected the template to be chunked as Go functions, got %+v", chunks)
+	}
+	for _, c := range chunks {
+		if c.Language != "go" || c.ChunkType != "function" {
+			t.Errorf("expected a go function chunk, got %s %s", c.Language, c.ChunkType)
+		}
+	}
+}
+
+func TestIndexPaths_HeadingPath(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	var found bool
+	for _, c := range store.chunks() {
+		if c.HeadingPath != "Title > Usage" {
+			continue
+		}
+		found = true
+		// The fake embedder encodes the embedded text's length
+		if c.Embedding[0] != float32(len("Title > Usage\n\n"+c.Content)) {
+			t.Errorf("expected the heading path to be embedded with the content")
+		}
+	}
+	if !found {
+		t.Errorf("expected a chunk under Title > Usage, got %+v", store.chunks())
+	}
+}
+
+// fakeSummarizer summarizes a chunk by its first line
+type fakeSummarizer struct {
+	err error
+}
+
+func (f *fakeSummarizer) Summarize(ctx context.Context, language, content string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	first, _, _ := strings.Cut(content, "\n")
+	return "Summary of " + first, nil
+}
+
+func TestIndexPaths_Summaries(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	idx.SetSummarizer(&fakeSummarizer{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	for _, c := range store.chunks() {
+		switch c.ChunkType {
+		case "function":
+			if !strings.HasPrefix(c.Summary, "Summary of func ") {
+				t.Errorf("expected a summary of %s:%d, got %q", c.FilePath, c.StartLine, c.Summary)
+			}
+			// The fake embedder encodes the embedded text's length
+			if c.Embedding[0] != float32(len(c.Summary+"\n\n"+c.Content)) {
+				t.Errorf("expected the summary to be embedded with the content")
+			}
+		default:
+			if c.Summary != "" {
+				t.Errorf("expected no summary for a %s chunk, got %q", c.ChunkType, c.Summary)
+			}
+		}
+	}
+}
+
+func TestIndexPaths_SummaryFailure(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	idx.SetSummarizer(&fakeSummarizer{err: errors.New("quota exceeded")})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("expected chunks to be indexed without summaries, got %v", err)
+	}
+	if len(store.chunks()) == 0 || idx.FailedFiles() != 0 {
+		t.Errorf("expected every file to be indexed, got %d chunks and %d failures", len(store.chunks()), idx.FailedFiles())
+	}
+}
+
+func TestIndexPaths_RespectsBatchSize(t *testing.T) {
+	dir := t.TempDir()
+	var content strings.Builder
+	content.WriteString("package main\n")
+	for i := 0; i < 7; i++ {
+		content.WriteString("\nfunc f() {\n}\n")
+	}
+	writeFile(t, filepath.Join(dir, "many.go"), content.String())
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{Workers: 1, BatchSize: 3}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	if len(store.chunks()) != 8 {
+		t.Fatalf("expected 8 chunks (preamble + 7 functions), got %d", len(store.chunks()))
+	}
+	for i, b := range store.batches {
+		if len(b) > 3 {
+			t.Errorf("batch %d has %d chunks, exceeds batch size 3", i, len(b))
+		}
+	}
+}
+
+// slowEmbedder records how many batches it embeds at the same time
+type slowEmbedder struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (e *slowEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.inFlight++
+	if e.inFlight > e.max {
+		e.max = e.inFlight
+	}
+	e.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	e.mu.Lock()
+	e.inFlight--
+	e.mu.Unlock()
+	return (&fakeEmbedder{}).EmbedBatch(ctx, texts)
+}
+
+func TestIndexPaths_SendsBatchesConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 8; i++ {
+		writeFile(t, filepath.Join(dir, fmt.Sprintf("f%d.md", i)), fmt.Sprintf("# File %d\n\nText\n", i))
+	}
+	store := &fakeStore{}
+	embedder := &slowEmbedder{}
+	idx := NewIndexer(&config.Config{Workers: 4, BatchSize: 1}, store, embedder)
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if len(store.chunks()) != 8 {
+		t.Errorf("expected 8 chunks, got %d", len(store.chunks()))
+	}
+	if embedder.max < 2 {
+		t.Errorf("expected batches to be embedded concurrently, at most %d were", embedder.max)
+	}
+	if embedder.max > 4 {
+		t.Errorf("expected at most 4 batches in flight (one per worker), got %d", embedder.max)
+	}
+}
+
+func TestIndexPaths_RecordsUsage(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{BatchSize: 2}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	u := idx.Usage()
+	chunks := store.chunks()
+	if u.Chunks != int64(len(chunks)) {
+		t.Errorf("expected %d chunks recorded, got %d", len(chunks), u.Chunks)
+	}
+	if u.EmbedCalls != int64(len(store.batches)) {
+		t.Errorf("expected %d embed calls, got %d", len(store.batches), u.EmbedCalls)
+	}
+	var bytes int64
+	for _, c := range chunks {
+		bytes += int64(len(embedText(c)))
+	}
+	if u.ChunkBytes != bytes || u.Tokens == 0 {
+		t.Errorf("expected %d chunk bytes and a token estimate, got %+v", bytes, u)
+	}
+
+	files := map[string]bool{}
+	for _, c := range chunks {
+		files[c.FilePath] = true
+	}
+	if idx.IndexedFiles() != len(files) {
+		t.Errorf("expected %d indexed files, got %d", len(files), idx.IndexedFiles())
+	}
+}
+
+func TestIndexPaths_StructuredLogs(t *testing.T) {
+	dir := testProject(t)
+	var buf bytes.Buffer
+	idx := NewIndexer(&config.Config{}, &fakeStore{}, &fakeEmbedder{})
+	idx.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	missing := filepath.Join(dir, "gone.go")
+	if err := idx.IndexFiles(context.Background(), []string{missing, filepath.Join(dir, "main.go")}); err != nil {
+		t.Fatalf("IndexFiles failed: %v", err)
+	}
+
+	var sawSkip, sawDone bool
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		switch rec["msg"] {
+		case "skipping file that no longer exists":
+			sawSkip = rec["level"] == "WARN" && rec["file"] == missing
+		case "done":
+			sawDone = rec["project"] == dir && rec["chunks"] != nil
+		}
+	}
+	if !sawSkip || !sawDone {
+		t.Errorf("expected a warning for the missing file and a done record, got:\n%s", buf.String())
+	}
+}
+
+type recordingProgress struct {
+	mu       sync.Mutex
+	projects []string
+	total    int
+	done     int
+	chunks   int
+	finished int
+	depths   map[string]int // deepest seen, by queue
+}
+
+func (r *recordingProgress) Start(project string, files int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.projects = append(r.projects, project)
+	r.total += files
+}
+
+func (r *recordingProgress) FileStarted(path string) {}
+
+func (r *recordingProgress) FileDone(path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done++
+}
+
+func (r *recordingProgress) ChunksEmbedded(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chunks += n
+}
+
+func (r *recordingProgress) QueueDepth(name string, n, capacity int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.depths == nil {
+		r.depths = map[string]int{}
+	}
+	r.depths[name] = max(r.depths[name], n)
+}
+
+func (r *recordingProgress) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finished++
+}
+
+func TestIndexPaths_ReportsProgress(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	p := &recordingProgress{}
+	idx := NewIndexer(&config.Config{Workers: 2, BatchSize: 2}, store, &fakeEmbedder{})
+	idx.SetProgress(p)
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	if len(p.projects) != 1 || p.projects[0] != dir {
+		t.Errorf("expected one project %s, got %v", dir, p.projects)
+	}
+	if p.total != 3 || p.done != 3 {
+		t.Errorf("expected 3/3 files, got %d/%d", p.done, p.total)
+	}
+	if p.chunks != len(store.chunks()) {
+		t.Errorf("expected %d chunks reported, got %d", len(store.chunks()), p.chunks)
+	}
+	if p.finished != 1 {
+		t.Errorf("expected Finish once, got %d", p.finished)
+	}
+	if _, ok := p.depths["embed"]; !ok {
+		t.Errorf("expected the embed queue's depth reported, got %v", p.depths)
+	}
+}
+
+func TestIndexPaths_Report(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{Workers: 2}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	reports := idx.Report()
+	if len(reports) != 1 {
+		t.Fatalf("expected one report, got %+v", reports)
+	}
+	r := reports[0]
+	if r.Project != dir || r.Walked != 3 || r.Added != 3 || r.Processed != 3 || r.Indexed != 2 || r.Skipped["binary"] != 1 {
+		t.Errorf("unexpected file counts %+v", r)
+	}
+	if n := len(store.chunks()); r.Chunks != n || r.Embedded != n || r.FailedBatches != 0 {
+		t.Errorf("expected %d chunks created and embedded, got %+v", n, r)
+	}
+	if r.Durations.Total <= 0 || r.Durations.Total < r.Durations.Walk || r.Error != "" {
+		t.Errorf("unexpected durations or error %+v", r)
+	}
+
+	// A failed run is reported with its error and the batches it lost
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n\tprintln()\n}\n")
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{err: errors.New("quota exceeded")})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	r = idx.Report()[0]
+	if r.Modified != 1 || r.Processed != 1 || r.FailedBatches != 1 || !strings.Contains(r.Error, "quota exceeded") {
+		t.Errorf("unexpected report of a failed run %+v", r)
+	}
+}
+
+func TestIndexPaths_SkipReasons(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(dir, ".gitignore"), "build/\n")
+	writeFile(t, filepath.Join(dir, "build", "out.go"), "package build\n")
+	writeFile(t, filepath.Join(dir, ".cache", "tmp.go"), "package cache\n")
+	writeFile(t, filepath.Join(dir, "big.txt"), strings.Repeat("x", 200))
+	writeFile(t, filepath.Join(dir, "latin1.txt"), "caf\xe9\n")
+	writeFile(t, filepath.Join(dir, "image.bin"), "\x00\x01")
+	writeFile(t, filepath.Join(dir, ".env"), "TOKEN=x\n")
+
+	idx := NewIndexer(&config.Config{MaxFileSize: 100, SkipFiles: ".env"}, &fakeStore{}, &fakeEmbedder{})
+	idx.SetListSkipped(true)
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	r := idx.Report()[0]
+	want := []SkippedFile{
+		{".cache/tmp.go", "hidden"},
+		{".env", "pattern .env"},
+		{"big.txt", "too large"},
+		{"build/out.go", "gitignored"},
+		{"image.bin", "binary"},
+		{"latin1.txt", "encoding"},
+	}
+	if !reflect.DeepEqual(r.SkippedFiles, want) {
+		t.Errorf("expected skipped files %v, got %v", want, r.SkippedFiles)
+	}
+	if r.Skipped["gitignored"] != 1 || r.Skipped["binary"] != 1 || r.Indexed != 2 {
+		t.Errorf("unexpected counts %+v", r)
+	}
+
+	// Nothing changed: every file is unchanged, and the walk's skips are
+	// still listed
+	idx = NewIndexer(&config.Config{MaxFileSize: 100, SkipFiles: ".env"}, &fakeStore{}, &fakeEmbedder{})
+	idx.SetListSkipped(true)
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+	r = idx.Report()[0]
+	if r.Skipped["unchanged"] != 6 || r.Skipped["gitignored"] != 1 || r.Skipped["hidden"] != 1 {
+		t.Errorf("unexpected counts for an unchanged project %v", r.Skipped)
+	}
+}
+
+func TestIndexPaths_Traces(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracing.Install(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { tracing.Shutdown(context.Background()) })
+
+	dir := testProject(t)
+	idx := NewIndexer(&config.Config{Workers: 2, BatchSize: 100}, &fakeStore{}, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	counts := map[string]int{}
+	var project sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		counts[s.Name()]++
+		if s.Name() == "index.project" {
+			project = s
+		}
+	}
+	want := map[string]int{"index.project": 1, "walk": 1, "chunk": 3, "embed": 1, "upsert": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("expected spans %v, got %v", want, counts)
+	}
+	for _, s := range spans {
+		if s != project && s.Parent().SpanID() != project.SpanContext().SpanID() {
+			t.Errorf("expected %s to be a child of index.project", s.Name())
+		}
+	}
+}
+
+func TestIndexPaths_Hooks(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	cfg := &config.Config{Workers: 2, Hooks: []config.Hook{
+		{Stage: config.HookPreChunk, Command: []string{"sh", "-c", `grep -q README.md && echo '{"skip": true}' || echo '{}'`}},
+		{Stage: config.HookPostChunk, Command: []string{"sed", "s/func main/func scrubbed/"}},
+		{Stage: config.HookPreUpsert, Command: []string{"false"}, Projects: []string{"/elsewhere/**"}},
+	}}
+	idx := NewIndexer(cfg, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if failures := idx.Failures(); len(failures) > 0 {
+		t.Fatalf("expected no failures, got %+v", failures)
+	}
+	var content strings.Builder
+	for _, c := range store.chunks() {
+		if c.FilePath != "main.go" {
+			t.Errorf("expected only main.go to be indexed, got %s", c.FilePath)
+		}
+		content.WriteString(c.Content)
+	}
+	if !strings.Contains(content.String(), "func scrubbed()") || strings.Contains(content.String(), "func main()") {
+		t.Errorf("expected the post-chunk hook to rewrite the chunks, got %q", content.String())
+	}
+}
+
+func TestIndexPaths_HookFailure(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	cfg := &config.Config{Hooks: []config.Hook{{Stage: config.HookPostChunk, Command: []string{"false"}}}}
+	idx := NewIndexer(cfg, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	failures := idx.Failures()
+	if len(failures) != 2 {
+		t.Fatalf("expected main.go and README.md to fail, got %+v", failures)
+	}
+	for _, f := range failures {
+		if f.Op != OpHook {
+			t.Errorf("expected op %s, got %+v", OpHook, f)
+		}
+	}
+	if len(store.chunks()) != 0 {
+		t.Errorf("expected nothing indexed, got %d chunks", len(store.chunks()))
+	}
+}
+
+func TestIndexPaths_Distributed(t *testing.T) {
+	dir := testProject(t)
+	server := miniredis.RunT(t)
+	openQueue := func() queue.Queue {
+		q, err := queue.Open("redis://" + server.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { q.Close() })
+		return q
+	}
+
+	// The worker writes to the store; the coordinator only records state
+	store := &fakeStore{}
+	worker := NewIndexer(&config.Config{Workers: 2, BatchSize: 1}, store, &fakeEmbedder{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Work(ctx, openQueue())
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// One file queued at a time: each is queued once the last is reported
+	coordStore := &fakeStore{}
+	p := &recordingProgress{}
+	idx := NewIndexer(&config.Config{MaxQueuedJobs: 1}, coordStore, &fakeEmbedder{})
+	idx.SetQueue(openQueue())
+	idx.SetProgress(p)
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if p.depths["jobs"] != 1 {
+		t.Errorf("expected at most 1 job queued at once, got %d", p.depths["jobs"])
+	}
+
+	if idx.IndexedFiles() != 2 || idx.FailedFiles() != 0 {
+		t.Errorf("expected 2 files indexed and none failed, got %d and %d", idx.IndexedFiles(), idx.FailedFiles())
+	}
+	if len(coordStore.chunks()) != 0 {
+		t.Errorf("expected the coordinator to upsert nothing, got %d chunks", len(coordStore.chunks()))
+	}
+	chunks := store.chunks()
+	if len(chunks) == 0 {
+		t.Fatal("expected the worker to upsert chunks")
+	}
+	if u := idx.Usage(); u.Chunks != int64(len(chunks)) || u.EmbedCalls == 0 {
+		t.Errorf("expected the worker's usage to be reported, got %+v for %d chunks", u, len(chunks))
+	}
+	if st, ok := worker.WorkStatus(); !ok || st.Reported < 2 || len(st.Active) != 0 || st.PendingFiles != 0 {
+		t.Errorf("expected an idle worker that reported the files, got %+v (working %v)", st, ok)
+	}
+	if _, ok := idx.WorkStatus(); ok {
+		t.Error("expected no work status from the coordinator")
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil || meta == nil {
+		t.Fatalf("expected metadata to be saved, got %v", err)
+	}
+	main := meta.Files["main.go"]
+	if main.Language != "go" || len(main.Chunks) == 0 {
+		t.Errorf("expected main.go's language and chunks recorded, got %+v", main)
+	}
+	if _, ok := meta.Files["image.bin"]; !ok {
+		t.Error("expected the skipped binary file recorded")
+	}
+
+	// Only the changed chunk is re-embedded; the removed one is deleted
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n\tprintln()\n}\n")
+	before := len(store.batches)
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var upserted int
+	for _, b := range store.batches[before:] {
+		upserted += len(b)
+	}
+	// The changed chunk's old version goes along with the removed one
+	if upserted != 1 || len(store.deletedIDs) != 2 {
+		t.Errorf("expected 1 chunk re-embedded and 2 deleted, got %d and %d", upserted, len(store.deletedIDs))
+	}
+}
+
+func TestIndexPaths_DistributedNoWorkers(t *testing.T) {
+	dir := testProject(t)
+	q, err := queue.Open("redis://" + miniredis.RunT(t).Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	idx := NewIndexer(&config.Config{}, &fakeStore{}, &fakeEmbedder{})
+	idx.SetQueue(q)
+	idx.resultTimeout = 100 * time.Millisecond
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if idx.FailedFiles() != 3 {
+		t.Errorf("expected every file to fail without workers, got %+v", idx.Failures())
+	}
+	meta, err := metadata.Load(dir)
+	if err != nil || meta == nil {
+		t.Fatalf("expected metadata to be saved, got %v", err)
+	}
+	if len(meta.Files) != 0 {
+		t.Errorf("expected failed files left out of the state, got %v", meta.Files)
+	}
+}
+
+func TestIndexPaths_EmbedBudget(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		writeFile(t, filepath.Join(dir, name), "package main\n\nfunc "+strings.TrimSuffix(name, ".go")+"() {\n}\n")
+	}
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{Workers: 1, MaxEmbedTokens: 1}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	if idx.IndexedFiles() != 1 {
+		t.Errorf("expected the run to stop after 1 file, indexed %d", idx.IndexedFiles())
+	}
+	remaining := idx.Remaining()
+	if len(remaining) != 1 || remaining[0].Project != dir || len(remaining[0].Files) != 2 {
+		t.Fatalf("expected 2 files remaining in %s, got %+v", dir, remaining)
+	}
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Files) != 1 {
+		t.Errorf("expected only the indexed file recorded, got %v", meta.Files)
+	}
+	for _, rel := range remaining[0].Files {
+		if _, ok := meta.Files[rel]; ok {
+			t.Errorf("expected remaining file %s left out of the state", rel)
+		}
+	}
+
+	// A later run picks up where the budget stopped this one
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+	if idx.IndexedFiles() != 2 || len(idx.Remaining()) != 0 {
+		t.Errorf("expected the 2 remaining files indexed, got %d indexed and %+v remaining", idx.IndexedFiles(), idx.Remaining())
+	}
+}
+
+func TestIndexPaths_EmbedBudgetKeepsModifiedState(t *testing.T) {
+	dir := testProject(t)
+	writeFile(t, filepath.Join(dir, "util.go"), "package main\n\nfunc util() {\n}\n")
+	store := &fakeStore{}
+	if err := NewIndexer(&config.Config{}, store, &fakeEmbedder{}).IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both files change; the budget runs out after the first
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n\tprintln()\n}\n")
+	writeFile(t, filepath.Join(dir, "util.go"), "package main\n\nfunc util() {\n\tprintln()\n}\n")
+	idx := NewIndexer(&config.Config{Workers: 1, MaxEmbedTokens: 1}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	remaining := idx.Remaining()
+	if len(remaining) != 1 || len(remaining[0].Files) != 1 {
+		t.Fatalf("expected 1 file remaining, got %+v", remaining)
+	}
+	after, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := remaining[0].Files[0]
+	if !reflect.DeepEqual(after.Files[rel], before.Files[rel]) {
+		t.Errorf("expected %s to keep its recorded state, got %+v", rel, after.Files[rel])
+	}
+
+	// Once over budget, later paths aren't touched
+	other := testProject(t)
+	if err := idx.IndexPaths(context.Background(), []string{other}); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := idx.Remaining(); len(remaining) != 2 || remaining[1].Project != other || len(remaining[1].Files) != 3 {
+		t.Errorf("expected every file of %s remaining, got %+v", other, remaining)
+	}
+}
+
+func TestIndexPaths_InterruptSavesProgress(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go"} {
+		writeFile(t, filepath.Join(dir, name), "package main\n\nfunc "+strings.TrimSuffix(name, ".go")+"() {\n}\n")
+	}
+	store := &fakeStore{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Interrupted while the first batch is embedding
+	idx := NewIndexer(&config.Config{Workers: 1, BatchSize: 1}, store, &fakeEmbedder{onEmbed: cancel})
+	err := idx.IndexPaths(ctx, []string{dir})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to be cancelled, got %v", err)
+	}
+
+	// The file in flight is finished and recorded; the rest are left
+	indexed := idx.IndexedFiles()
+	if indexed == 0 || indexed == 4 {
+		t.Fatalf("expected the run to stop part way, indexed %d files", indexed)
+	}
+	upserted := map[string]bool{}
+	for _, c := range store.chunks() {
+		upserted[c.FilePath] = true
+	}
+	if len(upserted) != indexed {
+		t.Errorf("expected the chunks of the %d files indexed upserted, got %v", indexed, upserted)
+	}
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Files) != indexed {
+		t.Errorf("expected the %d files indexed recorded, got %v", indexed, meta.Files)
+	}
+
+	// The next run carries on with the files left
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+	if idx.IndexedFiles() != 4-indexed {
+		t.Errorf("expected the %d files left indexed, got %d", 4-indexed, idx.IndexedFiles())
+	}
+}
+
+func TestIndexPaths_StopsOnFailedBatch(t *testing.T) {
+	dir := t.TempDir()
+	const files = 200
+	for i := 0; i < files; i++ {
+		writeFile(t, filepath.Join(dir, fmt.Sprintf("f%03d.go", i)), fmt.Sprintf("package x\n\nfunc F%d() {}\n", i))
+	}
+	store := &fakeStore{err: errors.New("typesense down")}
+	idx := NewIndexer(&config.Config{Workers: 1, BatchSize: 1}, store, &fakeEmbedder{})
+
+	err := idx.IndexPaths(context.Background(), []string{dir})
+	if err == nil || !strings.Contains(err.Error(), "typesense down") {
+		t.Fatalf("expected the failed upsert reported, got %v", err)
+	}
+	if n := idx.Report()[0].Processed; n == files {
+		t.Errorf("expected the pool to stop taking files once a batch failed, processed all %d", n)
+	}
+}
+
+func TestBatcher_BoundsQueuedChunks(t *testing.T) {
+	release := make(chan struct{})
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{Workers: 1, BatchSize: 1}, store, &fakeEmbedder{onEmbed: func() { <-release }})
+	b := newBatcher(context.Background(), idx, progress.NewLog(slog.Default(), time.Hour))
+
+	const files = 20
+	var added atomic.Int64
+	go func() {
+		for i := 0; i < files; i++ {
+			b.add([]IndexedChunk{{ID: fmt.Sprint(i), Content: "x"}})
+			added.Add(1)
+		}
+	}()
+
+	// With the sender stuck embedding, add blocks once the queues fill
+	time.Sleep(50 * time.Millisecond)
+	if n := added.Load(); n >= files {
+		t.Fatalf("expected add to block while batches back up, all %d returned", n)
+	}
+	close(release)
+	for added.Load() < files {
+		time.Sleep(time.Millisecond)
+	}
+	b.flush()
+	if err := b.firstErr(); err != nil || b.total != files || len(store.chunks()) != files {
+		t.Errorf("expected %d chunks upserted, got %d (%v)", files, len(store.chunks()), err)
+	}
+}
+
+func TestBatcher_EmbedWorkers(t *testing.T) {
+	var running, most atomic.Int64
+	embedder := &fakeEmbedder{onEmbed: func() {
+		n := running.Add(1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+	}}
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{Workers: 1, EmbedWorkers: 3, UpsertWorkers: 1, BatchSize: 1}, store, embedder)
+	b := newBatcher(context.Background(), idx, progress.NewLog(slog.Default(), time.Hour))
+	for i := 0; i < 30; i++ {
+		b.add([]IndexedChunk{{ID: fmt.Sprint(i), Content: "x"}})
+	}
+	b.flush()
+
+	if err := b.firstErr(); err != nil || len(store.chunks()) != 30 {
+		t.Fatalf("expected 30 chunks upserted, got %d (%v)", len(store.chunks()), err)
+	}
+	if n := most.Load(); n < 2 || n > 3 {
+		t.Errorf("expected up to 3 batches embedded at once, got %d", n)
+	}
+}
+
+func TestIndexPaths_RedactsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	token := "ghp_" + strings.Repeat("aB3", 12)
+	writeFile(t, filepath.Join(dir, "config.go"), "package main\n\nvar token = \""+token+"\"\n")
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	chunks := store.chunks()
+	if len(chunks) == 0 {
+		t.Fatal("expected chunks to be upserted")
+	}
+	for _, c := range chunks {
+		if strings.Contains(c.Content, token) {
+			t.Errorf("secret leaked into chunk %s:%d", c.FilePath, c.StartLine)
+		}
+	}
+}
+
+func TestIndexPaths_EmbeddingFailure(t *testing.T) {
+	dir := testProject(t)
+	idx := NewIndexer(&config.Config{}, &fakeStore{}, &fakeEmbedder{err: errors.New("quota exceeded")})
+
+	err := idx.IndexPaths(context.Background(), []string{dir})
+	if err == nil {
+		t.Fatal("expected error when embedding fails")
+	}
+	if !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("expected embedding error, got %v", err)
+	}
+
+	meta, _ := metadata.Load(dir)
+	if meta.LastIndexed != 0 {
+		t.Error("metadata should not be saved after a failed run")
+	}
+}
+
+func TestIndexPaths_ContinuesAfterPathFailure(t *testing.T) {
+	good := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	err := idx.IndexPaths(context.Background(), []string{"/nonexistent/swarm-indexer-test", good})
+	if err == nil {
+		t.Fatal("expected error for missing path")
+	}
+	if len(store.chunks()) == 0 {
+		t.Error("expected the valid path to be indexed despite the earlier failure")
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	idx := NewIndexer(&config.Config{}, &fakeStore{}, &fakeEmbedder{})
+	idx.recordFailure("/p", "/p/src/a.go", &opError{op: OpChunk, err: errors.New("bad syntax")})
+	idx.recordFailure("/p", "/p/b.go", errors.New("boom"))
+
+	want := []FileError{
+		{Project: "/p", Path: filepath.Join("src", "a.go"), Op: OpChunk, Error: "bad syntax"},
+		{Project: "/p", Path: "b.go", Op: OpProcess, Error: "boom"},
+	}
+	if got := idx.Failures(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected failures %+v, got %+v", want, got)
+	}
+	if idx.FailedFiles() != 2 {
+		t.Errorf("expected 2 failed files, got %d", idx.FailedFiles())
+	}
+}
+
+func TestIndexPaths_Cancelled(t *testing.T) {
+	dir := testProject(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	idx := NewIndexer(&config.Config{}, &fakeStore{}, &fakeEmbedder{})
+	err := idx.IndexPaths(ctx, []string{dir})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestChunkID_Stable(t *testing.T) {
+	a := chunkID("/p", "main.go", 1)
+	if a != chunkID("/p", "main.go", 1) {
+		t.Error("expected chunk IDs to be stable")
+	}
+	if a == chunkID("/other", "main.go", 1) {
+		t.Error("expected different projects to yield different IDs")
+	}
+	if a == chunkID("/p", "main.go", 2) {
+		t.Error("expected different offsets to yield different IDs")
+	}
+}
+
+func TestSetChunkIDs(t *testing.T) {
+	chunks := []IndexedChunk{
+		{ProjectPath: "/p", RelPath: "a.go", Content: "x", StartLine: 1},
+		{ProjectPath: "/p", RelPath: "a.go", Content: "y", StartLine: 3},
+		{ProjectPath: "/p", RelPath: "a.go", Content: "x", StartLine: 5},
+	}
+	setChunkIDs(chunks)
+	if chunks[0].ID == chunks[2].ID {
+		t.Error("expected chunks with the same contents to get different IDs")
+	}
+
+	// Moving a chunk keeps its ID
+	moved := []IndexedChunk{chunks[0], chunks[1], chunks[2]}
+	moved[1].StartLine = 10
+	setChunkIDs(moved)
+	if moved[1].ID != chunks[1].ID {
+		t.Error("expected a chunk on other lines to keep its ID")
+	}
+	if linesHash(moved[1]) == linesHash(chunks[1]) {
+		t.Error("expected the recorded hash to change with the lines")
+	}
+}
+
+func TestIndexFiles_OnlyListedFiles(t *testing.T) {
+	dir := testProject(t)
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	files := []string{
+		filepath.Join(dir, "main.go"),
+		filepath.Join(dir, "deleted.go"),
+	}
+	if err := idx.IndexFiles(context.Background(), files); err != nil {
+		t.Fatalf("IndexFiles failed: %v", err)
+	}
+
+	for _, c := range store.chunks() {
+		if c.FilePath != "main.go" {
+			t.Errorf("expected only main.go to be indexed, got %s", c.FilePath)
+		}
+		if c.ProjectPath != dir {
+			t.Errorf("expected project path %s, got %s", dir, c.ProjectPath)
+		}
+	}
+	if len(store.chunks()) == 0 {
+		t.Fatal("expected main.go to be indexed")
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := meta.Files["main.go"]; !ok {
+		t.Error("expected file state for main.go to be recorded")
+	}
+	if meta.Files["main.go"].Hash == "" {
+		t.Error("expected content hash for main.go to be recorded")
+	}
+}
+
+func TestProjectRoot(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "repo", "pkg", "sub")
+	writeFile(t, filepath.Join(nested, "a.go"), "package sub\n")
+
+	// No markers: the file's own directory
+	if got := ProjectRoot(filepath.Join(nested, "a.go")); got != nested {
+		t.Errorf("expected %s, got %s", nested, got)
+	}
+
+	// A .git entry marks the project root
+	if err := os.Mkdir(filepath.Join(dir, "repo", ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := ProjectRoot(filepath.Join(nested, "a.go")); got != filepath.Join(dir, "repo") {
+		t.Errorf("expected git root, got %s", got)
+	}
+
+	// Existing metadata takes precedence over .git
+	if err := (&metadata.Metadata{}).Save(filepath.Join(dir, "repo", "pkg")); err != nil {
+		t.Fatal(err)
+	}
+	if got := ProjectRoot(filepath.Join(nested, "a.go")); got != filepath.Join(dir, "repo", "pkg") {
+		t.Errorf("expected indexed directory, got %s", got)
+	}
+}
+
+func TestRootFinder(t *testing.T) {
+	dir := t.TempDir()
+	pkg := filepath.Join(dir, "repo", "pkg")
+	writeFile(t, filepath.Join(pkg, "sub", "a.go"), "package sub\n")
+	writeFile(t, filepath.Join(pkg, "b.go"), "package pkg\n")
+	writeFile(t, filepath.Join(dir, "repo", "main.go"), "package main\n")
+	if err := os.Mkdir(filepath.Join(dir, "repo", ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&metadata.Metadata{}).Save(pkg); err != nil {
+		t.Fatal(err)
+	}
+
+	finder := NewRootFinder()
+	for file, want := range map[string]string{
+		filepath.Join(pkg, "sub", "a.go"):           pkg,
+		filepath.Join(pkg, "b.go"):                  pkg,
+		filepath.Join(dir, "repo", "main.go"):       filepath.Join(dir, "repo"),
+		filepath.Join(dir, "elsewhere", "c.go"):     filepath.Join(dir, "elsewhere"),
+		filepath.Join(pkg, "sub", "deeper", "d.go"): pkg,
+	} {
+		if got := finder.Root(file); got != want {
+			t.Errorf("%s: expected %s, got %s", file, want, got)
+		}
+		if got := ProjectRoot(file); got != want {
+			t.Errorf("%s: expected ProjectRoot to agree on %s, got %s", file, want, got)
+		}
+	}
+	// Directories are looked up once: the finder keeps its answers
+	if err := metadata.Remove(pkg); err != nil {
+		t.Fatal(err)
+	}
+	if got := finder.Root(filepath.Join(pkg, "b.go")); got != pkg {
+		t.Errorf("expected the answer remembered, got %s", got)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	dir := testProject(t)
+	writeFile(t, filepath.Join(dir, "old.go"), "package main\n")
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	plans, err := idx.Plan([]string{dir})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	want := []string{"docs/README.md", "image.bin", "main.go", "old.go"}
+	if len(plans) != 1 || !reflect.DeepEqual(plans[0].Added, want) {
+		t.Fatalf("expected every file to be added, got %+v", plans)
+	}
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	batches := len(store.batches)
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n")
+	writeFile(t, filepath.Join(dir, "new.go"), "package main\n")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "docs", "README.md"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "old.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	plans, err = idx.Plan([]string{dir})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	p := plans[0]
+	if !reflect.DeepEqual(p.Added, []string{"new.go"}) || !reflect.DeepEqual(p.Updated, []string{"main.go"}) || !reflect.DeepEqual(p.Deleted, []string{"old.go"}) {
+		t.Errorf("unexpected plan %+v", p)
+	}
+	if len(store.batches) != batches || len(store.deletedFiles) != 0 {
+		t.Error("expected Plan to write nothing")
+	}
+	if meta, _ := metadata.Load(dir); meta.Files["old.go"].Hash == "" {
+		t.Error("expected Plan to leave the recorded state alone")
+	}
+}
+
+func TestIndexPaths_ReconcilesChunks(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("first IndexPaths failed: %v", err)
+	}
+	batches := len(store.batches)
+
+	// Only the second function changes; the other chunks keep their lines
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n\nfunc renamed() {\n}\n")
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+
+	var upserted []IndexedChunk
+	for _, b := range store.batches[batches:] {
+		upserted = append(upserted, b...)
+	}
+	if len(upserted) != 1 || !strings.Contains(upserted[0].Content, "renamed") {
+		t.Errorf("expected only the changed chunk to be upserted, got %+v", upserted)
+	}
+	// Its old version is replaced
+	if len(store.deletedFiles) != 0 || len(store.deletedIDs) != 1 || len(store.movedIDs) != 0 {
+		t.Errorf("expected only the old chunk to be deleted, got files %v, chunks %v, moved %v", store.deletedFiles, store.deletedIDs, store.movedIDs)
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range store.chunks() {
+		if c.FilePath == "main.go" {
+			ids = append(ids, c.ID)
+		}
+	}
+	if got := meta.Files["main.go"].Chunks; len(got) != len(ids) || got[upserted[0].ID] != linesHash(upserted[0]) {
+		t.Errorf("expected the chunk hashes of main.go to be recorded, got %v", got)
+	}
+}
+
+func TestIndexPaths_RejectedChunks(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{reject: "helper"}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("first IndexPaths failed: %v", err)
+	}
+	failures := idx.Failures()
+	if len(failures) != 1 || failures[0].Path != "main.go" || failures[0].Op != OpUpsert || !strings.Contains(failures[0].Error, "Bad JSON.") {
+		t.Errorf("expected main.go to fail at the upsert stage, got %+v", failures)
+	}
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upserted := 0
+	for _, c := range store.chunks() {
+		if c.FilePath == "main.go" {
+			upserted++
+			if meta.Files["main.go"].Chunks[c.ID] == "" {
+				t.Errorf("expected the hash of upserted chunk %s to be recorded", c.ID)
+			}
+		}
+	}
+	if n := len(meta.Files["main.go"].Chunks); n != upserted {
+		t.Errorf("expected only the %d upserted chunks of main.go recorded, got %d", upserted, n)
+	}
+
+	// The next run upserts the rejected chunk alone
+	store.reject = ""
+	batches := len(store.batches)
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+	var again []IndexedChunk
+	for _, b := range store.batches[batches:] {
+		again = append(again, b...)
+	}
+	if len(again) != 1 || !strings.Contains(again[0].Content, "helper") {
+		t.Errorf("expected the rejected chunk upserted again, got %+v", again)
+	}
+}
+
+func TestIndexPaths_MovesChunks(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	embedded := 0
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{onEmbed: func() { embedded++ }})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("first IndexPaths failed: %v", err)
+	}
+	embedded = 0
+
+	// Lines added above helper move it without changing it
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n\n\n\nfunc helper() {\n}\n")
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("second IndexPaths failed: %v", err)
+	}
+
+	if embedded != 0 || len(store.deletedIDs) != 0 {
+		t.Errorf("expected nothing re-embedded or deleted, got %d batches, deleted %v", embedded, store.deletedIDs)
+	}
+	var helper IndexedChunk
+	for _, c := range store.chunks() {
+		if strings.Contains(c.Content, "helper") {
+			helper = c
+		}
+	}
+	if len(store.movedIDs) != 1 || store.movedIDs[0] != helper.ID || helper.StartLine != 8 || helper.Embedding == nil {
+		t.Errorf("expected helper's lines updated in place, got moved %v, %+v", store.movedIDs, helper)
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := meta.Files["main.go"].Chunks[helper.ID]; got != linesHash(helper) {
+		t.Errorf("expected helper's new lines recorded, got %q", got)
+	}
+}
+
+func TestReindex_IndexesUnchangedPath(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	first := len(store.chunks())
+	batches := len(store.batches)
+
+	if err := idx.Reindex(context.Background(), []string{dir}, false); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	upserted := 0
+	for _, b := range store.batches[batches:] {
+		upserted += len(b)
+	}
+	if upserted != first {
+		t.Errorf("expected all %d chunks to be re-upserted, got %d", first, upserted)
+	}
+	if len(store.deleted) != 0 {
+		t.Error("expected no documents to be deleted without purge")
+	}
+}
+
+func TestReindex_RemovesGoneDocuments(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "docs", "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n\nfunc renamed() {\n}\n")
+
+	if err := idx.Reindex(context.Background(), []string{dir}, false); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if len(store.deleted) != 0 || !slices.Equal(store.deletedFiles, []string{"docs/README.md"}) {
+		t.Errorf("expected only the deleted file's documents deleted, got projects %v, files %v", store.deleted, store.deletedFiles)
+	}
+	renamed := false
+	for _, c := range store.chunks() {
+		if c.FilePath == "docs/README.md" || strings.Contains(c.Content, "helper") {
+			t.Errorf("expected no documents of deleted files or replaced chunks, got %s: %q", c.FilePath, c.Content)
+		}
+		renamed = renamed || strings.Contains(c.Content, "renamed")
+	}
+	if !renamed {
+		t.Error("expected the changed chunk to be indexed")
+	}
+}
+
+func TestReindex_Purge(t *testing.T) {
+	dir := testProject(t)
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	first := len(store.chunks())
+
+	if err := idx.Reindex(context.Background(), []string{dir}, true); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if len(store.deleted) != 1 || store.deleted[0] != dir {
+		t.Errorf("expected documents for %s to be deleted, got %v", dir, store.deleted)
+	}
+	if len(store.chunks()) != first {
+		t.Errorf("expected %d chunks after purge and reindex, got %d", first, len(store.chunks()))
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Files) == 0 {
+		t.Error("expected metadata to be rewritten after reindex")
+	}
+}
+
+func TestIndexPaths_CustomSecretRules(t *testing.T) {
+	dir := t.TempDir()
+	ticket := "TKT-" + strings.Repeat("B2", 12)
+	writeFile(t, filepath.Join(dir, "notes.md"), "# Notes\n\nUse "+ticket+" for the sandbox.\n")
+	writeFile(t, filepath.Join(dir, "customers.csv"), "id,name\nCUST-00012345,Ada\n")
+
+	cfg := &config.Config{SecretRules: []config.SecretRule{
+		{ID: "ticket-token", Regex: `\bTKT-[A-Z0-9]{24}\b`, Keywords: []string{"TKT-"}, Replacement: "[TICKET]"},
+		{ID: "customer-export", Regex: `CUST-\d{8}`, Action: config.RuleActionSkipFile},
+	}}
+	store := &fakeStore{}
+	idx := NewIndexer(cfg, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	chunks := store.chunks()
+	if len(chunks) == 0 {
+		t.Fatal("expected chunks to be upserted")
+	}
+	for _, c := range chunks {
+		if strings.HasSuffix(c.FilePath, "customers.csv") {
+			t.Errorf("file matched by a skip-file rule was indexed")
+		}
+		if strings.Contains(c.Content, ticket) || !strings.Contains(c.Content, "[TICKET]") {
+			t.Errorf("expected ticket token replaced in %s, got %q", c.FilePath, c.Content)
+		}
+	}
+}
+
+func TestIndexPaths_SecretsBaseline(t *testing.T) {
+	dir := t.TempDir()
+	token := "ghp_" + strings.Repeat("cD4", 12)
+	writeFile(t, filepath.Join(dir, "fixture.txt"), "fake token "+token+"\n")
+
+	b := secrets.NewBaseline([]secrets.FileFindings{{
+		Path:     "fixture.txt",
+		Findings: []secrets.Finding{{Match: token, Type: "github-pat"}},
+	}})
+	if err := b.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	chunks := store.chunks()
+	if len(chunks) != 1 {
+		t.Fatalf("expected only fixture.txt to be indexed, got %d chunks", len(chunks))
+	}
+	if !strings.Contains(chunks[0].Content, token) {
+		t.Errorf("baselined finding was redacted: %q", chunks[0].Content)
+	}
+}
+
+func TestIndexPaths_RedactionKeepsLines(t *testing.T) {
+	dir := t.TempDir()
+	content := "# Setup\n\nBEGIN KEY\nc2VjcmV0\nEND KEY\n\nRun the installer.\n"
+	writeFile(t, filepath.Join(dir, "setup.md"), content)
+
+	cfg := &config.Config{
+		Redaction:   config.RedactionPadded,
+		SecretRules: []config.SecretRule{{ID: "key-block", Regex: `(?s)BEGIN KEY.*?END KEY`}},
+	}
+	store := &fakeStore{}
+	idx := NewIndexer(cfg, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	chunks := store.chunks()
+	if len(chunks) != 1 {
+		t.Fatalf("expected 1 chunk, got %d", len(chunks))
+	}
+	c := chunks[0]
+	if strings.Contains(c.Content, "c2VjcmV0") {
+		t.Errorf("secret leaked: %q", c.Content)
+	}
+	if c.StartLine != 1 || c.EndLine != 7 || len(c.Content) != len(strings.TrimSpace(content)) {
+		t.Errorf("line structure changed: lines %d-%d, content %q", c.StartLine, c.EndLine, c.Content)
+	}
+}
+
+func TestIndexPaths_SkipPatterns(t *testing.T) {
+	dir := testProject(t)
+	writeFile(t, filepath.Join(dir, ".env"), "TOKEN=x\n")
+	writeFile(t, filepath.Join(dir, "deploy", "secrets", "prod.yaml"), "password: x\n")
+	writeFile(t, filepath.Join(dir, "deploy", "values.yaml"), "replicas: 2\n")
+	writeFile(t, filepath.Join(dir, "certs", "server.pem"), "not really a key\n")
+
+	var buf bytes.Buffer
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{SkipFiles: ".env,*.pem,**/secrets/*.yaml"}, store, &fakeEmbedder{})
+	idx.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+
+	indexed := map[string]bool{}
+	for _, c := range store.chunks() {
+		indexed[filepath.ToSlash(c.FilePath)] = true
+	}
+	for _, skipped := range []string{".env", "deploy/secrets/prod.yaml", "certs/server.pem"} {
+		if indexed[skipped] {
+			t.Errorf("%s should have been skipped", skipped)
+		}
+	}
+	if !indexed["deploy/values.yaml"] {
+		t.Errorf("deploy/values.yaml should have been indexed, got %v", indexed)
+	}
+
+	var counts map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		if rec["msg"] == "done" {
+			counts, _ = rec["skipped"].(map[string]interface{})
+		}
+	}
+	want := map[string]float64{"total": 4, "binary": 1, "pattern .env": 1, "pattern *.pem": 1, "pattern **/secrets/*.yaml": 1}
+	for reason, n := range want {
+		if counts[reason] != n {
+			t.Errorf("expected skipped[%q] = %v, got %v", reason, n, counts)
+		}
+	}
+}
+
+// commitAll commits every change in dir, initializing a repository first
+// if needed
+func commitAll(t *testing.T, dir, message string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	for _, args := range [][]string{{"init", "--quiet"}, {"add", "."}, {"commit", "--quiet", "-m", message}} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Ada", "GIT_AUTHOR_EMAIL=ada@example.com",
+			"GIT_COMMITTER_NAME=Ada", "GIT_COMMITTER_EMAIL=ada@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", args[0], err, out)
+		}
+	}
+}
+
+func TestIndexHistory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n}\n")
+	commitAll(t, dir, "Add main")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n\tretry()\n}\n")
+	commitAll(t, dir, "Retry on startup")
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexHistory(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexHistory failed: %v", err)
+	}
+
+	types := map[string]int{}
+	for _, c := range store.chunks() {
+		types[c.ChunkType]++
+		if !strings.HasPrefix(c.FilePath, HistoryPathPrefix) {
+			t.Errorf("expected a history path, got %s", c.FilePath)
+		}
+		if c.ChunkType == ChunkTypeDiffHunk && strings.Contains(c.Content, "retry()") {
+			if !strings.Contains(c.Content, "Retry on startup") || c.Language != "go" || c.StartLine != 1 {
+				t.Errorf("unexpected hunk chunk %+v", c)
+			}
+		}
+	}
+	if types[ChunkTypeCommit] != 2 || types[ChunkTypeDiffHunk] != 2 {
+		t.Fatalf("expected 2 commit and 2 diff_hunk chunks, got %v", types)
+	}
+
+	// Nothing new to read
+	batches := len(store.batches)
+	if err := idx.IndexHistory(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexHistory failed: %v", err)
+	}
+	if len(store.batches) != batches {
+		t.Errorf("expected an unchanged history to be skipped")
+	}
+
+	// Only the new commit is read
+	writeFile(t, filepath.Join(dir, "README.md"), "# Demo\n")
+	commitAll(t, dir, "Add README")
+	if err := idx.IndexHistory(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexHistory failed: %v", err)
+	}
+	var added []IndexedChunk
+	for _, b := range store.batches[batches:] {
+		added = append(added, b...)
+	}
+	if len(added) != 2 || !strings.Contains(added[0].Content, "Add README") {
+		t.Errorf("expected the new commit and its hunk, got %+v", added)
+	}
+}
+
+func TestIndexHistory_NotRepository(t *testing.T) {
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexHistory(context.Background(), []string{t.TempDir()}); err != nil {
+		t.Fatalf("expected a path outside git to be skipped, got %v", err)
+	}
+	if len(store.chunks()) != 0 {
+		t.Errorf("expected no chunks, got %d", len(store.chunks()))
+	}
+}
+
+func TestIndexRemotes(t *testing.T) {
+	upstream := t.TempDir()
+	writeFile(t, filepath.Join(upstream, "main.go"), "package main\n\nfunc main() {\n}\n")
+	commitAll(t, upstream, "Add main")
+	url := "file://" + upstream
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexRemotes(context.Background(), []string{url}, ""); err != nil {
+		t.Fatalf("IndexRemotes failed: %v", err)
+	}
+	chunks := store.chunks()
+	if len(chunks) == 0 {
+		t.Fatal("expected the repository to be indexed")
+	}
+	for _, c := range chunks {
+		if c.ProjectPath != url || c.ProjectRoot != url || c.FilePath != "main.go" {
+			t.Errorf("expected main.go under %s, got %s %s %s", url, c.ProjectPath, c.ProjectRoot, c.FilePath)
+		}
+	}
+
+	// Same commit: nothing to do
+	batches := len(store.batches)
+	if err := idx.IndexRemotes(context.Background(), []string{url}, ""); err != nil {
+		t.Fatalf("IndexRemotes failed: %v", err)
+	}
+	if len(store.batches) != batches {
+		t.Errorf("expected an unchanged repository to be skipped")
+	}
+
+	// A new commit re-indexes only what it changed
+	writeFile(t, filepath.Join(upstream, "README.md"), "# Demo\n")
+	commitAll(t, upstream, "Add README")
+	if err := idx.IndexRemotes(context.Background(), []string{url}, ""); err != nil {
+		t.Fatalf("IndexRemotes failed: %v", err)
+	}
+	var added []IndexedChunk
+	for _, b := range store.batches[batches:] {
+		added = append(added, b...)
+	}
+	if len(added) != 1 || added[0].FilePath != "README.md" || added[0].ProjectPath != url {
+		t.Errorf("expected only README.md to be indexed, got %+v", added)
+	}
+
+	// A run the budget cut short isn't recorded as done at its commit
+	writeFile(t, filepath.Join(upstream, "a.go"), "package main\n\nfunc a() {\n}\n")
+	writeFile(t, filepath.Join(upstream, "b.go"), "package main\n\nfunc b() {\n}\n")
+	commitAll(t, upstream, "Add a and b")
+	idx = NewIndexer(&config.Config{Workers: 1, MaxEmbedTokens: 1}, store, &fakeEmbedder{})
+	if err := idx.IndexRemotes(context.Background(), []string{url}, ""); err != nil {
+		t.Fatalf("IndexRemotes failed: %v", err)
+	}
+	remaining := idx.Remaining()
+	if len(remaining) != 1 || len(remaining[0].Files) != 1 {
+		t.Fatalf("expected 1 file remaining, got %+v", remaining)
+	}
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexRemotes(context.Background(), []string{url}, ""); err != nil {
+		t.Fatalf("IndexRemotes failed: %v", err)
+	}
+	if idx.IndexedFiles() != 1 {
+		t.Errorf("expected the remaining file indexed at the same commit, indexed %d", idx.IndexedFiles())
+	}
+}
+
+func TestIndexSource_Pages(t *testing.T) {
+	site := "https://docs.example.com/guide/"
+	pages := []crawl.Page{
+		{URL: site, Title: "Guide", Text: "Start here.\n\n## Install\n\nRun the installer."},
+		{URL: site + "api", Title: "API", Text: "# API\n\nAuthenticate first."},
+		{URL: site + "faq", Title: "FAQ", Text: "# FAQ\n\nAsk away."},
+	}
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexSource(context.Background(), source.NewPages(site, pages)); err != nil {
+		t.Fatalf("IndexSource failed: %v", err)
+	}
+	chunks := store.chunks()
+	files := map[string]bool{}
+	var install bool
+	for _, c := range chunks {
+		if c.ProjectPath != site || c.ProjectType != "web" || c.Language != "markdown" {
+			t.Errorf("unexpected chunk %+v", c)
+		}
+		files[c.FilePath] = true
+		install = install || (c.FilePath == site && strings.Contains(c.HeadingPath, "Guide") && strings.Contains(c.Content, "installer"))
+	}
+	if !install {
+		t.Errorf("expected the install section under the page title, got %+v", chunks)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected chunks of 3 pages, got %v", files)
+	}
+	if idx.IndexedFiles() != 3 {
+		t.Errorf("expected 3 indexed pages, got %d", idx.IndexedFiles())
+	}
+
+	// The API page changed, the FAQ is gone and the start page failed
+	batches := len(store.batches)
+	pages = []crawl.Page{
+		{URL: site, Err: errors.New("503 Service Unavailable")},
+		{URL: site + "api", Title: "API", Text: "# API\n\nAuthenticate with a token."},
+	}
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexSource(context.Background(), source.NewPages(site, pages)); err != nil {
+		t.Fatalf("IndexSource failed: %v", err)
+	}
+	var added []IndexedChunk
+	for _, b := range store.batches[batches:] {
+		added = append(added, b...)
+	}
+	if len(added) != 1 || !strings.Contains(added[0].Content, "token") {
+		t.Errorf("expected only the changed API chunk, got %+v", added)
+	}
+	if !reflect.DeepEqual(store.deletedFiles, []string{site + "faq"}) {
+		t.Errorf("expected the FAQ to be deleted, got %v", store.deletedFiles)
+	}
+	failures := idx.Failures()
+	if len(failures) != 1 || failures[0].Path != site || failures[0].Op != OpRead {
+		t.Errorf("expected the start page to fail fetching, got %+v", failures)
+	}
+
+	meta, err := metadata.Load(site)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, ok := meta.Files[site]; !ok || len(meta.Files) != 2 || meta.ProjectType != "web" {
+		t.Errorf("expected the failed page to keep its state, got %+v", meta)
+	}
+}
+
+func TestIndexSites(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/docs/":
+			fmt.Fprint(w, `<title>Docs</title><main><h1>Docs</h1><p>See <a href="setup">setup</a>.</p></main>`)
+		case "/docs/setup":
+			fmt.Fprint(w, `<title>Setup</title><main><h1>Setup</h1><p>Install the CLI.</p></main>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	site := srv.URL + "/docs/"
+	if err := idx.IndexSites(context.Background(), srv.Client(), []string{site}, crawl.Options{Depth: 1}); err != nil {
+		t.Fatalf("IndexSites failed: %v", err)
+	}
+	files := map[string]bool{}
+	for _, c := range store.chunks() {
+		files[c.FilePath] = true
+	}
+	if !files[site] || !files[site+"setup"] || len(files) != 2 {
+		t.Errorf("expected both pages indexed, got %v", files)
+	}
+
+	if err := idx.IndexSites(context.Background(), srv.Client(), []string{srv.URL + "/missing"}, crawl.Options{}); err == nil {
+		t.Error("expected an error for a site without pages")
+	}
+}
+
+func TestIndexDocument(t *testing.T) {
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	notes := "# Standup\n\nShip the crawler.\n\n## Blockers\n\nNone.\n"
+	if err := idx.IndexDocument(context.Background(), "standup", "markdown", notes); err != nil {
+		t.Fatalf("IndexDocument failed: %v", err)
+	}
+	chunks := store.chunks()
+	if len(chunks) == 0 {
+		t.Fatal("expected the document to be indexed")
+	}
+	for _, c := range chunks {
+		if c.ProjectPath != DocProjectPath || c.FilePath != "standup" || c.Language != "markdown" {
+			t.Errorf("unexpected chunk %+v", c)
+		}
+	}
+
+	// Indexing the ID again replaces the document
+	if err := idx.IndexDocument(context.Background(), "standup", "", "# Standup\n\nShipped.\n"); err != nil {
+		t.Fatalf("IndexDocument failed: %v", err)
+	}
+	if !reflect.DeepEqual(store.deletedFiles, []string{"standup", "standup"}) {
+		t.Errorf("expected the previous document to be deleted, got %v", store.deletedFiles)
+	}
+	var text strings.Builder
+	for _, c := range store.chunks() {
+		text.WriteString(c.Content + "\n")
+	}
+	if !strings.Contains(text.String(), "Shipped") || strings.Contains(text.String(), "crawler") {
+		t.Errorf("expected only the new document, got %q", text.String())
+	}
+
+	if err := idx.IndexDocument(context.Background(), "empty", "", " \n"); err == nil {
+		t.Error("expected an error for an empty document")
+	}
+}
+
+// writeZip creates a zip archive holding files, by name
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexArchives(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "release.zip")
+	writeZip(t, src, map[string]string{"docs/readme.md": "# Release\n\nNotes.\n", "main.go": "package main\n\nfunc main() {\n}\n"})
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexArchives(context.Background(), []string{src}); err != nil {
+		t.Fatalf("IndexArchives failed: %v", err)
+	}
+	files := map[string]bool{}
+	for _, c := range store.chunks() {
+		if c.ProjectPath != src {
+			t.Errorf("expected project path %s, got %s", src, c.ProjectPath)
+		}
+		files[c.FilePath] = true
+	}
+	if !files["release.zip!/docs/readme.md"] || !files["release.zip!/main.go"] || len(files) != 2 {
+		t.Errorf("expected virtual paths inside the archive, got %v", files)
+	}
+	dir, err := archive.Dir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Errorf("expected the unpacked files to be removed")
+	}
+
+	// Unchanged: nothing to do
+	batches := len(store.batches)
+	if err := idx.IndexArchives(context.Background(), []string{src}); err != nil {
+		t.Fatalf("IndexArchives failed: %v", err)
+	}
+	if len(store.batches) != batches {
+		t.Errorf("expected an unchanged archive to be skipped")
+	}
+
+	// A new version re-indexes what changed and drops what's gone
+	writeZip(t, src, map[string]string{"docs/readme.md": "# Release\n\nNotes.\n", "cmd.go": "package main\n"})
+	if err := idx.IndexArchives(context.Background(), []string{src}); err != nil {
+		t.Fatalf("IndexArchives failed: %v", err)
+	}
+	var added []IndexedChunk
+	for _, b := range store.batches[batches:] {
+		added = append(added, b...)
+	}
+	if len(added) != 1 || added[0].FilePath != "release.zip!/cmd.go" {
+		t.Errorf("expected only cmd.go to be indexed, got %+v", added)
+	}
+	if !reflect.DeepEqual(store.deletedFiles, []string{"release.zip!/main.go"}) {
+		t.Errorf("expected main.go to be deleted, got %v", store.deletedFiles)
+	}
+	// A run whose files failed isn't recorded as done for the archive
+	writeZip(t, src, map[string]string{"docs/readme.md": "# Release\n\nNotes.\n", "cmd.go": "package main\n", "util.go": "package main\n\nfunc util() {\n}\n"})
+	store.reject = "util"
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexArchives(context.Background(), []string{src}); err != nil {
+		t.Fatalf("IndexArchives failed: %v", err)
+	}
+	if idx.FailedFiles() != 1 {
+		t.Fatalf("expected util.go to fail, got %+v", idx.Failures())
+	}
+	store.reject = ""
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexArchives(context.Background(), []string{src}); err != nil {
+		t.Fatalf("IndexArchives failed: %v", err)
+	}
+	if idx.IndexedFiles() != 1 {
+		t.Errorf("expected the failed file indexed from the same archive, indexed %d", idx.IndexedFiles())
+	}
+
+}
+
+func TestIndexSource_Dir(t *testing.T) {
+	dir := testProject(t)
+	src, err := source.NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexSource(context.Background(), src); err != nil {
+		t.Fatalf("IndexSource failed: %v", err)
+	}
+	files := map[string]bool{}
+	for _, c := range store.chunks() {
+		if c.ProjectPath != dir {
+			t.Errorf("expected project path %s, got %s", dir, c.ProjectPath)
+		}
+		files[c.FilePath] = true
+	}
+	if !files["main.go"] || !files["docs/README.md"] || files["image.bin"] {
+		t.Errorf("expected the text files but not the binary one, got %v", files)
+	}
+
+	// Unchanged: nothing is embedded again
+	batches := len(store.batches)
+	if err := idx.IndexSource(context.Background(), src); err != nil {
+		t.Fatalf("IndexSource failed: %v", err)
+	}
+	if len(store.batches) != batches {
+		t.Errorf("expected unchanged files to be skipped")
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Sample
+		ok   bool
+	}{
+		{"5%", Sample{Percent: 5}, true},
+		{"0.5%", Sample{Percent: 0.5}, true},
+		{"2000", Sample{Files: 2000}, true},
+		{"0%", Sample{}, false},
+		{"150%", Sample{}, false},
+		{"0", Sample{}, false},
+		{"ten", Sample{}, false},
+	} {
+		got, err := ParseSample(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParseSample(%q) = %+v, %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestSampleFiles(t *testing.T) {
+	var files []string
+	for i := 0; i < 90; i++ {
+		files = append(files, fmt.Sprintf("services/api/handler%d.go", i))
+	}
+	for i := 0; i < 10; i++ {
+		files = append(files, fmt.Sprintf("web/src/page%d.ts", i))
+	}
+	files = append(files, "README.md", "services/api/schema.sql")
+
+	kept, left := sampleFiles(files, 10)
+	if len(kept) != 10 || len(left) != len(files)-10 {
+		t.Fatalf("expected 10 files kept, got %d and %d left", len(kept), len(left))
+	}
+	groups := map[string]int{}
+	for _, rel := range kept {
+		groups[sampleGroup(rel)]++
+	}
+	// Every group is represented, and the biggest gives the most
+	if len(groups) != 4 || groups["services/api .go"] < 5 {
+		t.Errorf("expected a spread sample, got %v", kept)
+	}
+	again, _ := sampleFiles(files, 10)
+	if !reflect.DeepEqual(kept, again) {
+		t.Errorf("expected a stable sample, got %v and %v", kept, again)
+	}
+}
+
+func TestIndexPaths_Sample(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		writeFile(t, filepath.Join(dir, "pkg", fmt.Sprintf("f%d.go", i)), "package pkg\n")
+	}
+	store := &fakeStore{}
+	idx := NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	idx.SetSample(Sample{Percent: 30})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if r := idx.Report()[0]; r.Indexed != 3 || r.Skipped["not sampled"] != 7 {
+		t.Errorf("expected 3 files indexed and 7 left out, got %+v", r)
+	}
+
+	// A run without a sample indexes the files left out
+	idx = NewIndexer(&config.Config{}, store, &fakeEmbedder{})
+	if err := idx.IndexPaths(context.Background(), []string{dir}); err != nil {
+		t.Fatalf("IndexPaths failed: %v", err)
+	}
+	if r := idx.Report()[0]; r.Indexed != 7 || r.Skipped["unchanged"] != 3 {
+		t.Errorf("expected the other 7 files indexed, got %+v", r)
+	}
+}
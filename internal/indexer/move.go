@@ -0,0 +1,133 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+)
+
+// Move records that the project indexed at from now lives at to: its
+// documents are copied under the new path, embeddings and all, then
+// removed from the old one, and its metadata follows it, so the next run
+// at to only indexes what changed rather than everything. It returns the
+// number of documents moved. Only local directories can be moved.
+func (idx *Indexer) Move(ctx context.Context, from, to string) (int, error) {
+	from, err := walker.Abs(from)
+	if err != nil {
+		return 0, err
+	}
+	if to, err = walker.Abs(to); err != nil {
+		return 0, err
+	}
+	if from == to {
+		return 0, errors.New("the old and new paths are the same")
+	}
+
+	meta, err := metadata.Load(from)
+	if err != nil {
+		return 0, fmt.Errorf("loading metadata: %w", err)
+	}
+	if meta.LastIndexed == 0 {
+		return 0, fmt.Errorf("%s has not been indexed", from)
+	}
+	if metadata.Exists(to) {
+		return 0, fmt.Errorf("%s is already indexed; delete it first", to)
+	}
+
+	// The new documents are upserted before the old ones are deleted, so
+	// an interrupted move leaves a copy rather than nothing
+	type moved struct{ id, hash string }
+	ids := map[string]moved{}
+	batch := make([]IndexedChunk, 0, idx.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := idx.store.UpsertChunks(ctx, batch); err != nil {
+			return fmt.Errorf("upserting documents: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	err = idx.store.ExportProject(ctx, from, func(c IndexedChunk) error {
+		old := c.ID
+		n, found := occurrence(c, len(meta.Files[c.RelPath].Chunks))
+		c.ProjectPath = to
+		c.ProjectRoot = movePath(c.ProjectRoot, from, to)
+		c.AbsPath = absPath(to, c.RelPath)
+		if found {
+			c.ID = contentChunkID(to, c.RelPath, chunkHash(c), n)
+		} else {
+			// Not a file's chunk as setChunkIDs identifies them; the
+			// next run replaces it
+			c.ID = chunkID(to, c.RelPath, c.StartLine)
+		}
+		ids[old] = moved{c.ID, linesHash(c)}
+		batch = append(batch, c)
+		if len(batch) < idx.batchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("copying documents: %w", err)
+	}
+	if _, err := idx.store.DeleteByProject(ctx, from); err != nil {
+		return 0, fmt.Errorf("deleting old documents: %w", err)
+	}
+
+	for rel, fs := range meta.Files {
+		if fs.Chunks == nil {
+			continue
+		}
+		chunks := make(map[string]string, len(fs.Chunks))
+		for id := range fs.Chunks {
+			if m, ok := ids[id]; ok {
+				chunks[m.id] = m.hash
+			}
+		}
+		fs.Chunks = chunks
+		meta.Files[rel] = fs
+	}
+	if err := meta.Save(to); err != nil {
+		return 0, fmt.Errorf("saving metadata: %w", err)
+	}
+	if err := metadata.Remove(from); err != nil {
+		return 0, err
+	}
+
+	idx.logger.Info("moved project", "from", from, "to", to, "documents", len(ids))
+	return len(ids), nil
+}
+
+// occurrence returns which of the chunks with its chunkHash in its file
+// c is, as numbered by setChunkIDs, recovered from its ID. The file has
+// at most max chunks. It reports false for an ID setChunkIDs didn't set.
+func occurrence(c IndexedChunk, max int) (int, bool) {
+	hash := chunkHash(c)
+	for n := 0; n < max; n++ {
+		if contentChunkID(c.ProjectPath, c.RelPath, hash, n) == c.ID {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// movePath rewrites path, at or under from, to be at or under to
+func movePath(path, from, to string) string {
+	if path == from {
+		return to
+	}
+	if rest, ok := strings.CutPrefix(path, from+string(filepath.Separator)); ok {
+		return filepath.Join(to, rest)
+	}
+	return path
+}
@@ -0,0 +1,230 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// Override curates the results of the searches matching a query: pinned
+// documents are placed at the top, in order, and hidden ones left out.
+// Query is matched exactly, or anywhere in the search's query with
+// Contains.
+type Override struct {
+	ID       string   `json:"id"`
+	Query    string   `json:"query"`
+	Contains bool     `json:"contains,omitempty"`
+	Pinned   []string `json:"pinned,omitempty"` // document IDs, top first
+	Hidden   []string `json:"hidden,omitempty"` // document IDs
+}
+
+// typesenseOverride is an Override as the overrides API takes it
+type typesenseOverride struct {
+	ID   string `json:"id,omitempty"`
+	Rule struct {
+		Query string `json:"query"`
+		Match string `json:"match"`
+	} `json:"rule"`
+	Includes []overrideDocument `json:"includes,omitempty"`
+	Excludes []overrideDocument `json:"excludes,omitempty"`
+}
+
+type overrideDocument struct {
+	ID       string `json:"id"`
+	Position int    `json:"position,omitempty"` // 1-based, of includes
+}
+
+func (o Override) typesense() typesenseOverride {
+	var t typesenseOverride
+	t.Rule.Query, t.Rule.Match = o.Query, "exact"
+	if o.Contains {
+		t.Rule.Match = "contains"
+	}
+	for i, id := range o.Pinned {
+		t.Includes = append(t.Includes, overrideDocument{ID: id, Position: i + 1})
+	}
+	for _, id := range o.Hidden {
+		t.Excludes = append(t.Excludes, overrideDocument{ID: id})
+	}
+	return t
+}
+
+func (t typesenseOverride) override() Override {
+	o := Override{ID: t.ID, Query: t.Rule.Query, Contains: t.Rule.Match == "contains"}
+	includes := append([]overrideDocument(nil), t.Includes...)
+	sort.SliceStable(includes, func(i, j int) bool { return includes[i].Position < includes[j].Position })
+	for _, d := range includes {
+		o.Pinned = append(o.Pinned, d.ID)
+	}
+	for _, d := range t.Excludes {
+		o.Hidden = append(o.Hidden, d.ID)
+	}
+	return o
+}
+
+// UpsertOverride creates the override o, or replaces the one with its ID.
+func (c *TypesenseClient) UpsertOverride(ctx context.Context, o Override) error {
+	if o.ID == "" {
+		return errors.New("override ID cannot be empty")
+	}
+	if o.Query == "" {
+		return errors.New("override query cannot be empty")
+	}
+	body, err := json.Marshal(o.typesense())
+	if err != nil {
+		return fmt.Errorf("marshaling override: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.overrideURL(o.ID), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("upserting override: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
+	}
+	return nil
+}
+
+// ListOverrides returns the collection's overrides.
+func (c *TypesenseClient) ListOverrides(ctx context.Context) ([]Override, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url+"/collections/"+c.collection+"/overrides", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing overrides: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	var result struct {
+		Overrides []typesenseOverride `json:"overrides"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	overrides := make([]Override, len(result.Overrides))
+	for i, t := range result.Overrides {
+		overrides[i] = t.override()
+	}
+	return overrides, nil
+}
+
+// DeleteOverride removes the override with the given ID. Deleting one
+// that doesn't exist returns an APIError for which IsNotFound is true.
+func (c *TypesenseClient) DeleteOverride(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("override ID cannot be empty")
+	}
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.overrideURL(id), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("deleting override: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+	return nil
+}
+
+func (c *TypesenseClient) overrideURL(id string) string {
+	return c.url + "/collections/" + c.collection + "/overrides/" + url.PathEscape(id)
+}
+
+// FileChunkIDs returns the IDs of the documents of a file in a project,
+// in the order of their first line, for pinning or hiding the file.
+func (c *TypesenseClient) FileChunkIDs(ctx context.Context, projectPath, relPath string) ([]string, error) {
+	if projectPath == "" || relPath == "" {
+		return nil, errors.New("project path and file path are required")
+	}
+	if err := c.ensurePaths(ctx); err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("filter_by", fmt.Sprintf("project_path:=%s && rel_path:=%s && %s", filterValue(projectPath), filterValue(relPath), notDeletedFilter))
+	params.Set("include_fields", "id,start_line")
+
+	var docs []IndexedChunk
+	if err := c.exportDocs(ctx, params, func(doc IndexedChunk) { docs = append(docs, doc) }); err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].StartLine < docs[j].StartLine })
+	ids := make([]string, len(docs))
+	for i, d := range docs {
+		ids[i] = d.ID
+	}
+	return ids, nil
+}
+
+// DocumentFiles returns the absolute path of the file of each of the
+// documents with the given IDs. Documents that don't exist are left out.
+func (c *TypesenseClient) DocumentFiles(ctx context.Context, ids []string) (map[string]string, error) {
+	if err := c.ensurePaths(ctx); err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(ids))
+	for _, filterBy := range deleteFilters("id", ids) {
+		params := url.Values{}
+		params.Set("filter_by", filterBy)
+		params.Set("include_fields", "id,abs_path")
+		err := c.exportDocs(ctx, params, func(doc IndexedChunk) {
+			files[doc.ID] = doc.AbsPath
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// exportDocs streams the documents the export params select to fn
+func (c *TypesenseClient) exportDocs(ctx context.Context, params url.Values, fn func(IndexedChunk)) error {
+	body, err := c.export(ctx, params)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var doc IndexedChunk
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("decoding export: %w", err)
+		}
+		fn(doc)
+	}
+}
@@ -0,0 +1,118 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// absPath returns the AbsPath of the documents of the file at relPath in
+// the project at projectPath
+func absPath(projectPath, relPath string) string {
+	return strings.TrimSuffix(projectPath, "/") + "/" + relPath
+}
+
+// pathUpdate sets the paths of a document indexed before they existed
+type pathUpdate struct {
+	ID      string `json:"id"`
+	RelPath string `json:"rel_path"`
+	AbsPath string `json:"abs_path"`
+}
+
+// ensurePaths migrates the collection to rel_path and abs_path on first
+// use, if EnsureCollection hasn't, so filters on them match every
+// document. A collection that doesn't exist has nothing to migrate.
+func (c *TypesenseClient) ensurePaths(ctx context.Context) error {
+	c.pathsMu.Lock()
+	defer c.pathsMu.Unlock()
+	if c.pathsChecked {
+		return nil
+	}
+
+	have, found, err := c.existingFields(ctx)
+	if err != nil {
+		return err
+	}
+	if found && len(have) > 0 {
+		if err := c.migratePaths(ctx, have); err != nil {
+			return err
+		}
+		var missing []map[string]interface{}
+		for _, f := range collectionFields {
+			if name := f["name"].(string); (name == "rel_path" || name == "abs_path") && !have[name] {
+				missing = append(missing, f)
+			}
+		}
+		if err := c.addFields(ctx, missing); err != nil {
+			return err
+		}
+	}
+	c.pathsChecked = true
+	return nil
+}
+
+// migratePaths sets rel_path and abs_path on every document from its
+// file_path and project_path, unless the collection's fields, have,
+// already include them. It runs before the fields are added to the
+// schema, which indexes the values of existing documents, so an
+// interrupted migration is started over by the next run.
+func (c *TypesenseClient) migratePaths(ctx context.Context, have map[string]bool) error {
+	if have["rel_path"] && have["abs_path"] {
+		return nil
+	}
+
+	params := url.Values{}
+	params.Set("include_fields", "id,project_path,file_path")
+	body, err := c.export(ctx, params)
+	if err != nil {
+		return fmt.Errorf("migrating document paths: %w", err)
+	}
+	defer body.Close()
+
+	batchSize := c.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	n := 0
+	batch := make([]any, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := c.importDocs(ctx, "update", batch); err != nil {
+			return fmt.Errorf("migrating document paths: %w", err)
+		}
+		n += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	dec := json.NewDecoder(body)
+	for {
+		var doc IndexedChunk
+		if err := dec.Decode(&doc); err != nil {
+			if !errors.Is(err, io.EOF) {
+				return fmt.Errorf("decoding export: %w", err)
+			}
+			break
+		}
+		batch = append(batch, pathUpdate{ID: doc.ID, RelPath: doc.FilePath, AbsPath: absPath(doc.ProjectPath, doc.FilePath)})
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("migrated documents to separate relative and absolute paths", "collection", c.collection, "documents", n)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package indexer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dvaida/swarm-indexer/internal/walker"
+)
+
+// Plan lists what indexing a path would change, by path relative to the
+// project.
+type Plan struct {
+	Project string   `json:"project"`
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"` // modified files whose contents changed
+	Deleted []string `json:"deleted"`
+}
+
+// Empty reports whether indexing the path would change nothing.
+func (p Plan) Empty() bool {
+	return len(p.Added) == 0 && len(p.Updated) == 0 && len(p.Deleted) == 0
+}
+
+// Plan works out what IndexPaths would do for each path without writing
+// anything: no documents, embeddings or metadata. Files that were only
+// touched are left out, as indexing would skip them.
+func (idx *Indexer) Plan(paths []string) ([]Plan, error) {
+	var plans []Plan
+	var errs []error
+	for _, path := range paths {
+		root, err := walker.Abs(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		pp, err := idx.planPath(root)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		plans = append(plans, Plan{
+			Project: root,
+			Added:   nonNil(pp.added),
+			Updated: nonNil(pp.updated),
+			Deleted: nonNil(pp.changes.Deleted),
+		})
+	}
+	return plans, errors.Join(errs...)
+}
+
+// nonNil keeps empty lists as [] rather than null in JSON
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
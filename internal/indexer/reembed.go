@@ -0,0 +1,77 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Reembed computes the embeddings of every stored document of the given
+// projects again with the indexer's embedder, and upserts the documents
+// with them in place, for when the embedding model changes. Nothing is
+// read from disk or chunked again: the text embedded is rebuilt from the
+// stored documents. It returns the number of documents re-embedded, which
+// on error counts those updated before it.
+func (idx *Indexer) Reembed(ctx context.Context, projects []string) (n int, err error) {
+	ctx, span := tracing.StartSpan(ctx, "reembed", attribute.Int("projects", len(projects)))
+	defer func() {
+		span.SetAttributes(attribute.Int("documents", n))
+		tracing.End(span, err)
+	}()
+
+	batch := make([]IndexedChunk, 0, idx.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := idx.reembedBatch(ctx, batch); err != nil {
+			return err
+		}
+		n += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for _, project := range projects {
+		start, before := time.Now(), n
+		err := idx.store.ExportProject(ctx, project, func(c IndexedChunk) error {
+			batch = append(batch, c)
+			if len(batch) < idx.batchSize {
+				return nil
+			}
+			return flush()
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", project, err)
+		}
+		idx.logger.Info("re-embedded project", "project", project, "documents", n-before,
+			"duration", time.Since(start).Round(time.Millisecond))
+	}
+	return n, nil
+}
+
+// reembedBatch embeds the chunks' text again and upserts them
+func (idx *Indexer) reembedBatch(ctx context.Context, batch []IndexedChunk) error {
+	texts := make([]string, len(batch))
+	for i, c := range batch {
+		texts[i] = embedText(c)
+	}
+	vectors, err := idx.embedder.EmbedBatch(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("embedding batch: %w", err)
+	}
+	idx.recordEmbed(texts)
+	if len(vectors) != len(batch) {
+		return fmt.Errorf("embedding batch: got %d embeddings for %d chunks", len(vectors), len(batch))
+	}
+	idx.setEmbeddings(batch, vectors)
+	if err := idx.store.UpsertChunks(ctx, batch); err != nil {
+		return fmt.Errorf("upserting documents: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/remote"
+)
+
+// IndexRemotes indexes remote git repositories at ref (each one's default
+// branch when empty). Each is shallow-cloned into the data dir, or fetched
+// if it was cloned before, and indexed like a local directory with its
+// URL as the project path. Only files changed since the last index are
+// re-indexed, and nothing at all when the commit is the same and the last
+// run indexed every file.
+func (idx *Indexer) IndexRemotes(ctx context.Context, urls []string, ref string) error {
+	var errs []error
+	for _, url := range urls {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := idx.indexRemote(ctx, url, ref); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", remote.ProjectPath(url), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (idx *Indexer) indexRemote(ctx context.Context, url, ref string) error {
+	project := remote.ProjectPath(url)
+	dir, commit, err := remote.Sync(ctx, url, ref)
+	if err != nil {
+		return fmt.Errorf("fetching repository: %w", err)
+	}
+
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	if meta.Commit == commit {
+		idx.logger.Info("commit unchanged since last index, skipping", "project", project, "commit", commit)
+		return nil
+	}
+
+	idx.logger.Info("indexing remote repository", "project", project, "commit", commit, "previous_commit", meta.Commit)
+	idx.mounts[dir] = mount{project: project}
+	defer delete(idx.mounts, dir)
+	unfinished := idx.unfinished()
+	if err := idx.indexPath(ctx, dir); err != nil {
+		return err
+	}
+	if idx.unfinished() > unfinished {
+		// The next run at this commit must pick up what this one left
+		idx.logger.Info("files left to index, not recording the commit", "project", project, "commit", commit)
+		return nil
+	}
+
+	// indexPath saved the clone's files; record the commit they are at
+	if meta, err = metadata.Load(dir); err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	meta.Commit = commit
+	if err := meta.Save(dir); err != nil {
+		return fmt.Errorf("saving metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package indexer
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reasons files are skipped, besides "pattern <glob>" (a secret file name),
+// "rule <id>" (a secret in the content) and "hook <name>" (a pre-chunk
+// hook's veto)
+const (
+	skipBinary     = "binary"
+	skipTooLarge   = "too large"   // over max_file_size
+	skipEncoding   = "encoding"    // not valid UTF-8
+	skipGitignored = "gitignored"  // matched by a .gitignore
+	skipHidden     = "hidden"      // in a directory whose name starts with "."
+	skipUnchanged  = "unchanged"   // indexed by an earlier run; counted, never listed
+	skipGenerated  = "generated"   // linguist-generated in .gitattributes, or looks generated
+	skipVendored   = "vendored"    // linguist-vendored in .gitattributes
+	skipUnsampled  = "not sampled" // left out by a sample; counted, never listed
+)
+
+// SkippedFile is a file a run left out, and why.
+type SkippedFile struct {
+	Path   string `json:"path"` // relative to the project
+	Reason string `json:"reason"`
+}
+
+// PathReport is what a run did in one project: what the walk found, what
+// became of the files processed and their chunks, and where the time went.
+type PathReport struct {
+	Project string `json:"project"`
+	// Walked counts the files found under the project; Added, Modified
+	// and Deleted the changes since the last run
+	Walked   int `json:"files_walked"`
+	Added    int `json:"files_added"`
+	Modified int `json:"files_modified"`
+	Deleted  int `json:"files_deleted"`
+	// Processed counts the files read, scanned and chunked; each was
+	// indexed, skipped or failed
+	Processed int `json:"files_processed"`
+	Indexed   int `json:"files_indexed"`
+	// Skipped counts files by reason, including unchanged files; those the
+	// walker leaves out, gitignored or hidden, only when they are listed
+	Skipped map[string]int `json:"files_skipped"`
+	// SkippedFiles lists the files skipped for any reason but being
+	// unchanged, when the indexer lists them (see SetListSkipped)
+	SkippedFiles []SkippedFile `json:"skipped_files,omitempty"`
+	Failed       int           `json:"files_failed"`
+	Remaining    int           `json:"files_remaining"` // left by the embedding budget or an interrupt
+	// Chunks counts the chunks of the files processed, of which Embedded
+	// were new or changed and were embedded and upserted, Unchanged were
+	// left as they are and Moved only had their lines updated. Removed
+	// counts the chunks deleted because their files no longer have them.
+	Chunks    int `json:"chunks"`
+	Embedded  int `json:"chunks_embedded"`
+	Unchanged int `json:"chunks_unchanged"`
+	Moved     int `json:"chunks_moved"`
+	Removed   int `json:"chunks_removed"`
+	// FailedBatches counts the batches that failed to embed or upsert, or
+	// were dropped after the first failure
+	FailedBatches int            `json:"failed_batches"`
+	Durations     StageDurations `json:"durations"`
+	Error         string         `json:"error,omitempty"`
+
+	start time.Time
+}
+
+// StageDurations is the time a project spent in each stage. Chunk, Embed
+// and Upsert add up the time of every file or batch, so with several
+// workers they can exceed Total.
+type StageDurations struct {
+	Walk   time.Duration
+	Chunk  time.Duration
+	Embed  time.Duration
+	Upsert time.Duration
+	Total  time.Duration
+}
+
+// MarshalJSON writes the durations in seconds.
+func (d StageDurations) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Walk   float64 `json:"walk_seconds"`
+		Chunk  float64 `json:"chunk_seconds"`
+		Embed  float64 `json:"embed_seconds"`
+		Upsert float64 `json:"upsert_seconds"`
+		Total  float64 `json:"total_seconds"`
+	}{d.Walk.Seconds(), d.Chunk.Seconds(), d.Embed.Seconds(), d.Upsert.Seconds(), d.Total.Seconds()})
+}
+
+// Report returns what the run did in each project indexed from a
+// directory or a list of files, in the order they finished.
+func (idx *Indexer) Report() []PathReport {
+	idx.reportsMu.Lock()
+	defer idx.reportsMu.Unlock()
+	return append([]PathReport(nil), idx.reports...)
+}
+
+// SetListSkipped makes the reports list every file skipped and why, not
+// just count them by reason, so users can find out why a file isn't
+// searchable. Unchanged files, and files a sample left out, are only
+// counted.
+func (idx *Indexer) SetListSkipped(list bool) {
+	idx.listSkipped = list
+}
+
+// skip records that the file at rel was skipped for reason. Callers must
+// hold whatever guards rep.
+func (idx *Indexer) skip(rep *PathReport, rel, reason string) {
+	rep.Skipped[reason]++
+	if idx.listSkipped && reason != skipUnchanged && reason != skipUnsampled {
+		rep.SkippedFiles = append(rep.SkippedFiles, SkippedFile{Path: rel, Reason: reason})
+	}
+}
+
+// walkSkipReason returns why the walker left out the file at rel, which
+// it didn't yield
+func walkSkipReason(rel string) string {
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/") {
+		if strings.HasPrefix(dir, ".") && dir != "." {
+			return skipHidden
+		}
+	}
+	return skipGitignored
+}
+
+// startReport starts the report of the project at root
+func (idx *Indexer) startReport(root string) *PathReport {
+	return &PathReport{Project: idx.projectPath(root), Skipped: map[string]int{}, start: time.Now()}
+}
+
+// finishReport adds r to the run's reports, failed with err if not nil
+func (idx *Indexer) finishReport(r *PathReport, err error) {
+	r.Durations.Total = time.Since(r.start)
+	sort.Slice(r.SkippedFiles, func(i, j int) bool { return r.SkippedFiles[i].Path < r.SkippedFiles[j].Path })
+	if err != nil {
+		r.Error = err.Error()
+	}
+	idx.reportsMu.Lock()
+	defer idx.reportsMu.Unlock()
+	idx.reports = append(idx.reports, *r)
+}
@@ -0,0 +1,130 @@
+package indexer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sampleDirDepth is how many directory levels group files when sampling,
+// e.g. services/api
+const sampleDirDepth = 2
+
+// Sample bounds the new files an exploratory run indexes in each project,
+// to a percentage of them or a number of files.
+type Sample struct {
+	Percent float64
+	Files   int
+}
+
+// ParseSample parses a sample given as a percentage ("10%") or a number
+// of files ("500").
+func ParseSample(s string) (Sample, error) {
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		p, err := strconv.ParseFloat(pct, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return Sample{}, fmt.Errorf("invalid sample %q: the percentage must be over 0 and at most 100", s)
+		}
+		return Sample{Percent: p}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return Sample{}, fmt.Errorf("invalid sample %q: want a percentage (10%%) or a number of files (500)", s)
+	}
+	return Sample{Files: n}, nil
+}
+
+// size returns how many of total files the sample takes, at least one
+func (s Sample) size(total int) int {
+	n := s.Files
+	if s.Percent > 0 {
+		n = int(math.Ceil(float64(total) * s.Percent / 100))
+	}
+	return max(min(n, total), 1)
+}
+
+// SetSample makes runs index only a sample of the files each project
+// adds, spread across its directories and languages, to try the setup on
+// a huge tree before indexing all of it. Files left out aren't recorded,
+// so the next run without a sample indexes them; modified and deleted
+// files are handled as usual.
+func (idx *Indexer) SetSample(s Sample) {
+	idx.sample = s
+}
+
+// samplePlan drops the added files the sample doesn't take from the
+// plan, so they are neither indexed nor recorded
+func (idx *Indexer) samplePlan(rep *PathReport, pp *pathPlan) {
+	if idx.sample == (Sample{}) || len(pp.added) == 0 {
+		return
+	}
+	kept, left := sampleFiles(pp.added, idx.sample.size(len(pp.added)))
+	for _, rel := range left {
+		delete(pp.files, rel)
+		idx.skip(rep, rel, skipUnsampled)
+	}
+	idx.logger.Info("sampling files", "project", pp.root, "sampled", len(kept), "added", len(pp.added))
+	pp.added = kept
+}
+
+// sampleFiles returns n of files spread across their directories and
+// languages, and the others. Files are grouped by directory, down to
+// sampleDirDepth levels, and extension; each group gives files in
+// proportion to its size, and every group gives one before any gives two
+// when n allows. The choice within a group is arbitrary but stable.
+func sampleFiles(files []string, n int) (kept, left []string) {
+	groups := map[string][]string{}
+	for _, rel := range files {
+		key := sampleGroup(rel)
+		groups[key] = append(groups[key], rel)
+	}
+
+	type ranked struct {
+		rel, group string
+		position   float64 // in its group, from 0 to 1
+	}
+	all := make([]ranked, 0, len(files))
+	for key, group := range groups {
+		sort.Slice(group, func(i, j int) bool { return fileHash(group[i]) < fileHash(group[j]) })
+		for i, rel := range group {
+			all = append(all, ranked{rel: rel, group: key, position: float64(i) / float64(len(group))})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].position != all[j].position {
+			return all[i].position < all[j].position
+		}
+		return all[i].group < all[j].group
+	})
+
+	for i, r := range all {
+		if i < n {
+			kept = append(kept, r.rel)
+		} else {
+			left = append(left, r.rel)
+		}
+	}
+	sort.Strings(kept)
+	return kept, left
+}
+
+// sampleGroup returns the group of the file at rel when sampling: its
+// directory down to sampleDirDepth levels, and its extension
+func sampleGroup(rel string) string {
+	dir := path.Dir(filepath.ToSlash(rel))
+	if parts := strings.Split(dir, "/"); len(parts) > sampleDirDepth {
+		dir = strings.Join(parts[:sampleDirDepth], "/")
+	}
+	return dir + " " + strings.ToLower(path.Ext(rel))
+}
+
+func fileHash(rel string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(rel))
+	return h.Sum64()
+}
@@ -0,0 +1,262 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/progress"
+	"github.com/dvaida/swarm-indexer/internal/source"
+	"github.com/dvaida/swarm-indexer/internal/tracing"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// IndexSource indexes the files of a source under its project path. Only
+// files whose fingerprint changed since the last index are read, chunked
+// and embedded, and files the source no longer lists are removed. Files
+// that can't be fingerprinted or read are reported as failures and keep
+// their documents until they can be. Binary files and files matching the
+// secrets skip patterns or a SkipFile rule are recorded but not indexed.
+// Files left once the embedding budget is reached or ctx is done keep
+// their documents and recorded state; an interrupted run still flushes
+// and records the files it processed.
+func (idx *Indexer) IndexSource(ctx context.Context, src source.Source) (err error) {
+	project := src.Project()
+	ctx, span := tracing.StartSpan(ctx, "index.project", attribute.String("project", project))
+	defer func() { tracing.End(span, err) }()
+
+	meta, err := metadata.Load(project)
+	if err != nil {
+		return fmt.Errorf("loading metadata: %w", err)
+	}
+	walkCtx, walkSpan := tracing.StartSpan(ctx, "walk")
+	paths, err := src.ListFiles(walkCtx)
+	walkSpan.SetAttributes(attribute.Int("files", len(paths)))
+	tracing.End(walkSpan, err)
+	if err != nil {
+		return fmt.Errorf("listing files: %w", err)
+	}
+	projectType := ""
+	if t, ok := src.(source.Typed); ok {
+		projectType = t.ProjectType()
+	}
+
+	idx.logger.Info("indexing source", "project", project, "files", len(paths))
+
+	p := idx.progress
+	if p == nil {
+		p = progress.NewLog(idx.logger, progress.DefaultLogInterval)
+	}
+	p.Start(project, len(paths))
+
+	// Once ctx is done, the batches queued are flushed with drainCtx
+	drainCtx, cancelDrain := drainContext(ctx)
+	defer cancelDrain()
+
+	b := newBatcher(drainCtx, idx, p)
+	defer b.flush()
+	files := make(map[string]metadata.FileState, len(paths))
+	var moved []IndexedChunk // chunks of changed files that only moved
+	var removed []string     // IDs of chunks changed files no longer have
+	var stale []string       // files whose documents all go
+	var unchanged int        // files left as they are
+	var remaining []string
+	for _, path := range paths {
+		prev, indexed := meta.Files[path]
+		if ctx.Err() != nil || idx.overBudget() {
+			// Kept as recorded, so the next run picks it up
+			if indexed {
+				files[path] = prev
+			}
+			remaining = append(remaining, path)
+			continue
+		}
+		p.FileStarted(path)
+
+		fingerprint, err := src.Fingerprint(ctx, path)
+		if err == nil && indexed && prev.Hash == fingerprint {
+			files[path] = prev
+			unchanged++
+			p.FileDone(path, nil)
+			continue
+		}
+		var data []byte
+		if err == nil {
+			data, err = src.ReadFile(ctx, path)
+		}
+		if err != nil {
+			idx.recordFailure(project, path, &opError{op: OpRead, err: err})
+			idx.logger.Warn("error reading file", "project", project, "file", path, "err", err)
+			if indexed {
+				files[path] = prev
+			}
+			p.FileDone(path, err)
+			continue
+		}
+
+		_, chunkSpan := tracing.StartSpan(ctx, "chunk", attribute.String("file", path))
+		chunks, language, err := idx.sourceChunks(ctx, src, projectType, path, data)
+		chunkSpan.SetAttributes(attribute.Int("chunks", len(chunks)))
+		tracing.End(chunkSpan, err)
+		if err != nil {
+			idx.recordFailure(project, path, err)
+			idx.logger.Warn("error processing file", "project", project, "file", path, "err", err)
+			if indexed {
+				files[path] = prev
+			}
+			p.FileDone(path, err)
+			continue
+		}
+		hashes := chunkHashes(chunks)
+		changed, shifted, gone := reconcileChunks(chunks, hashes, prev.Chunks)
+		moved = append(moved, shifted...)
+		removed = append(removed, gone...)
+		if indexed && prev.Chunks == nil {
+			stale = append(stale, path)
+		}
+		files[path] = metadata.FileState{Hash: fingerprint, Language: language, Chunks: hashes}
+		if language != "" {
+			idx.indexed.Add(1)
+		}
+
+		idx.summarize(ctx, changed)
+		err = b.add(changed)
+		p.FileDone(path, err)
+		if err != nil {
+			p.Finish()
+			return err
+		}
+	}
+	interrupted := ctx.Err()
+	b.flush()
+	p.Finish()
+	if err := b.firstErr(); err != nil {
+		return fmt.Errorf("upserting chunks: %w", err)
+	}
+	// Files with rejected chunks are read again by the next run, which
+	// upserts just those chunks
+	hashes := make(map[string]map[string]string, len(files))
+	for path, f := range files {
+		hashes[path] = f.Chunks
+	}
+	for _, path := range idx.rejectFiles(project, b.rejectedChunks(), hashes) {
+		f := files[path]
+		f.Hash = ""
+		files[path] = f
+	}
+	if len(removed) > 0 {
+		if _, err := idx.store.DeleteChunks(drainCtx, removed); err != nil {
+			return fmt.Errorf("deleting chunks: %w", err)
+		}
+	}
+	if len(moved) > 0 {
+		if err := idx.store.UpdateLines(drainCtx, moved); err != nil {
+			return fmt.Errorf("updating chunk lines: %w", err)
+		}
+	}
+
+	for path := range meta.Files {
+		if _, ok := files[path]; !ok {
+			stale = append(stale, path)
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		if _, err := idx.store.DeleteFiles(drainCtx, project, stale); err != nil {
+			return fmt.Errorf("deleting documents: %w", err)
+		}
+	}
+
+	switch {
+	case interrupted != nil:
+		idx.logger.Warn("interrupted, saving progress", "project", project, "left", len(remaining))
+	case len(remaining) > 0:
+		idx.logger.Warn("embedding budget reached", "project", project, "remaining", len(remaining))
+		idx.recordRemaining(project, remaining)
+	}
+
+	meta.Files = files
+	meta.FileCount = len(files)
+	meta.LastIndexed = time.Now().Unix()
+	meta.ProjectType = projectType
+	meta.Languages = languages(files)
+	if err := meta.Save(project); err != nil {
+		return fmt.Errorf("saving metadata: %w", err)
+	}
+	idx.logger.Info("done", "project", project, "files", len(paths), "chunks", b.total,
+		"unchanged_files", unchanged, "removed_files", len(stale))
+	return interrupted
+}
+
+// sourceChunks redacts and chunks a file of a source, returning its
+// chunks and language. Binary and skipped files, including those a hook
+// vetoed, yield no chunks and no language.
+func (idx *Indexer) sourceChunks(ctx context.Context, src source.Source, projectType, path string, data []byte) ([]IndexedChunk, string, error) {
+	if _, skip := idx.scanner.ShouldSkipFile(path); skip {
+		return nil, "", nil
+	}
+	if walker.IsBinaryContent(data) {
+		return nil, "", nil
+	}
+	content, skip, err := idx.scanText(string(data), path, nil)
+	if err != nil {
+		return nil, "", &opError{op: OpScan, err: err}
+	}
+	if skip {
+		idx.logger.Info("skipping file with secrets", "project", src.Project(), "file", path)
+		return nil, "", nil
+	}
+
+	language := ""
+	if t, ok := src.(source.Typed); ok {
+		language = t.Language(path)
+	}
+	if language == "" {
+		language = idx.detectLanguage(path, content)
+	}
+	project := src.Project()
+	content, vetoed, err := idx.preChunk(ctx, project, path, language, content)
+	if err != nil {
+		return nil, "", err
+	}
+	if vetoed != "" {
+		idx.logger.Info("skipping file vetoed by hook", "project", project, "file", path, "hook", vetoed)
+		return nil, "", nil
+	}
+	chunks, err := idx.chunker.ChunkFile(path, content, language)
+	if err != nil {
+		return nil, "", &opError{op: OpChunk, err: err}
+	}
+
+	now := time.Now().Unix()
+	indexed := make([]IndexedChunk, 0, len(chunks))
+	for _, c := range chunks {
+		indexed = append(indexed, IndexedChunk{
+			// Hooks refer to chunks by ID; setChunkIDs replaces it after
+			ID:          chunkID(project, path, c.StartLine),
+			RelPath:     path,
+			AbsPath:     absPath(project, path),
+			FilePath:    path,
+			ProjectPath: project,
+			ProjectRoot: project,
+			ProjectType: projectType,
+			Language:    language,
+			ChunkType:   c.ChunkType,
+			Content:     c.Content,
+			Symbols:     c.Symbols,
+			HeadingPath: c.HeadingPath,
+			StartLine:   c.StartLine,
+			EndLine:     c.EndLine,
+			LastIndexed: now,
+		})
+	}
+	indexed, err = idx.postChunk(ctx, project, path, indexed)
+	if err != nil {
+		return nil, "", err
+	}
+	setChunkIDs(indexed)
+	return indexed, language, nil
+}
@@ -0,0 +1,111 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Synonym is a set of words keyword search treats as the same. Without a
+// root every word matches the others; with one, a search for the root
+// also matches the synonyms but not the other way around.
+type Synonym struct {
+	ID       string   `json:"id"`
+	Root     string   `json:"root,omitempty"`
+	Synonyms []string `json:"synonyms"`
+}
+
+// UpsertSynonym creates the synonym set s, or replaces the one with its ID.
+func (c *TypesenseClient) UpsertSynonym(ctx context.Context, s Synonym) error {
+	if s.ID == "" {
+		return errors.New("synonym ID cannot be empty")
+	}
+	body, err := json.Marshal(struct {
+		Root     string   `json:"root,omitempty"`
+		Synonyms []string `json:"synonyms"`
+	}{s.Root, s.Synonyms})
+	if err != nil {
+		return fmt.Errorf("marshaling synonym: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.synonymURL(s.ID), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("upserting synonym: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
+	}
+	return nil
+}
+
+// ListSynonyms returns the collection's synonym sets.
+func (c *TypesenseClient) ListSynonyms(ctx context.Context) ([]Synonym, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url+"/collections/"+c.collection+"/synonyms", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing synonyms: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	var result struct {
+		Synonyms []Synonym `json:"synonyms"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return result.Synonyms, nil
+}
+
+// DeleteSynonym removes the synonym set with the given ID. Deleting a set
+// that doesn't exist returns an APIError for which IsNotFound is true.
+func (c *TypesenseClient) DeleteSynonym(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("synonym ID cannot be empty")
+	}
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.synonymURL(id), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("deleting synonym: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+	return nil
+}
+
+func (c *TypesenseClient) synonymURL(id string) string {
+	return c.url + "/collections/" + c.collection + "/synonyms/" + url.PathEscape(id)
+}
@@ -0,0 +1,64 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+)
+
+// SetTags makes runs store tags on every document of the projects they
+// index, replacing the tags the projects had. Without it projects keep
+// the tags they were last indexed with; SetTags(nil) removes them.
+func (idx *Indexer) SetTags(tags []string) {
+	idx.tags = normalizeTags(tags)
+	idx.tagsSet = true
+}
+
+// KeepTags undoes SetTags: projects indexed from then on keep the tags
+// they have.
+func (idx *Indexer) KeepTags() {
+	idx.tags, idx.tagsSet = nil, false
+}
+
+// normalizeTags trims, sorts and dedupes tags, dropping empty ones
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// applyTags settles the tags of the project at root, whose metadata is
+// meta: the run's if set, else the recorded ones. When they change, the
+// documents already indexed are retagged in place rather than re-embedded
+// and meta is updated; it reports whether they changed.
+func (idx *Indexer) applyTags(ctx context.Context, root string, meta *metadata.Metadata) (bool, error) {
+	if !idx.tagsSet || slices.Equal(idx.tags, meta.Tags) {
+		idx.projectTags[root] = meta.Tags
+		return false, nil
+	}
+	if meta.LastIndexed != 0 {
+		n, err := idx.store.UpdateTags(ctx, idx.projectPath(root), idx.tags)
+		if err != nil {
+			return false, fmt.Errorf("retagging documents: %w", err)
+		}
+		idx.logger.Info("retagged documents", "project", root, "tags", idx.tags, "documents", n)
+	}
+	meta.Tags = idx.tags
+	idx.projectTags[root] = idx.tags
+	return true, nil
+}
+
+// tagChunks stores tags on chunks
+func tagChunks(chunks []IndexedChunk, tags []string) {
+	for i := range chunks {
+		chunks[i].Tags = tags
+	}
+}
@@ -3,29 +3,59 @@ package indexer
 import (
 	"bytes"
 	"context"
+	"crypto/cipher"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 const defaultBatchSize = 100
 
+// EmbeddingDim is the embedding dimension of newly created collections.
+const EmbeddingDim = 768
+
 // IndexedChunk represents a chunk of code or text indexed in Typesense.
 type IndexedChunk struct {
-	ID          string    `json:"id"`           // hash of path+offset
+	ID string `json:"id"` // hash of path and content, or of path+offset
+	// RelPath is the file's path within ProjectPath, with slashes, and
+	// AbsPath the two joined by a slash, e.g. /src/api/cmd/main.go; deletes
+	// and lookups of a project's files filter on RelPath, of files in any
+	// project on AbsPath. FilePath is the same as RelPath, kept for clients
+	// reading file_path.
+	RelPath     string    `json:"rel_path"`
+	AbsPath     string    `json:"abs_path"`
 	FilePath    string    `json:"file_path"`
 	ProjectPath string    `json:"project_path"`
-	ProjectType string    `json:"project_type"` // go, node, python, etc.
+	ProjectRoot string    `json:"project_root"` // nearest enclosing project, at or under ProjectPath
+	ProjectType string    `json:"project_type"` // of ProjectRoot: go, node, python, etc.
 	Language    string    `json:"language"`
 	ChunkType   string    `json:"chunk_type"` // function, class, paragraph
 	Content     string    `json:"content"`
-	Embedding   []float32 `json:"embedding"` // Gemini vector
-	StartLine   int       `json:"start_line"`
-	EndLine     int       `json:"end_line"`
-	LastIndexed int64     `json:"last_indexed"` // unix timestamp
+	Symbols     []string  `json:"symbols,omitempty"`      // names the chunk declares
+	HeadingPath string    `json:"heading_path,omitempty"` // enclosing markdown headings, "A > B"
+	Summary     string    `json:"summary,omitempty"`      // of code chunks, when enabled
+	Tags        []string  `json:"tags,omitempty"`         // of the project, set by index --tag
+	Embedding   []float32 `json:"embedding"`              // Gemini vector
+	// EmbeddingModel and EmbeddingDim name the model Embedding came from
+	// and its length; empty on documents indexed before they were recorded
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+	EmbeddingDim   int    `json:"embedding_dim,omitempty"`
+	StartLine      int    `json:"start_line"`
+	EndLine        int    `json:"end_line"`
+	LastIndexed    int64  `json:"last_indexed"` // unix timestamp
+	// Deleted marks a tombstone: the document of a removed file, hidden
+	// from searches until the delete grace period ends. DeletedAt is when
+	// its file was removed.
+	Deleted   bool  `json:"deleted,omitempty"`
+	DeletedAt int64 `json:"deleted_at,omitempty"`
 }
 
 // TypesenseClient wraps the Typesense client for indexing and searching.
@@ -35,6 +65,34 @@ type TypesenseClient struct {
 	collection string
 	batchSize  int
 	httpClient *http.Client
+	matching   *Matching
+
+	// model is the embedding model of query vectors, set by
+	// SetEmbeddingModel; modelFilter restricts vector searches to its
+	// documents once modelChecked
+	model        string
+	modelMu      sync.Mutex
+	modelChecked bool
+	modelFilter  string
+
+	// contentCipher encrypts document content, set by SetContentKey
+	contentCipher cipher.AEAD
+
+	// pathsChecked is set once the collection is known to have rel_path
+	// and abs_path, migrating its documents if it didn't
+	pathsMu      sync.Mutex
+	pathsChecked bool
+}
+
+// Matching tunes how the keyword half of a search matches query words.
+// Code identifiers often match unrelated names under Typesense's default
+// typo tolerance, so it can be tightened.
+type Matching struct {
+	NumTypos int  // typos tolerated per word, 0 to 2
+	Prefix   bool // the last word of the query also matches as a prefix
+	// DropTokensThreshold is how few results make Typesense retry the
+	// query with words dropped from it; 0 never drops any
+	DropTokensThreshold int
 }
 
 // NewTypesenseClient creates a new Typesense client wrapper.
@@ -58,9 +116,35 @@ func NewTypesenseClient(url, apiKey, collection string) (*TypesenseClient, error
 	}, nil
 }
 
-// EnsureCollection creates the collection schema if it doesn't exist.
-func (c *TypesenseClient) EnsureCollection(ctx context.Context) error {
-	// Check if collection exists
+// SetHTTPClient replaces the HTTP client, e.g. to set a timeout or route
+// requests through a proxy.
+func (c *TypesenseClient) SetHTTPClient(hc *http.Client) {
+	c.httpClient = hc
+}
+
+// APIError represents a non-success response from the Typesense API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("typesense API error (status=%d): %s", e.StatusCode, e.Message)
+}
+
+// IsAuthError returns true if the server rejected the API key.
+func (e *APIError) IsAuthError() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// IsNotFound returns true if the collection or document doesn't exist.
+func (e *APIError) IsNotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// Ping checks that the server is reachable and accepts the API key.
+// A missing collection is not an error.
+func (c *TypesenseClient) Ping(ctx context.Context) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.url+"/collections/"+c.collection, nil)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
@@ -69,40 +153,418 @@ func (c *TypesenseClient) EnsureCollection(ctx context.Context) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("checking collection: %w", err)
+		return fmt.Errorf("connecting to Typesense: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Collection exists
-	if resp.StatusCode == http.StatusOK {
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+}
+
+// collectionFields is the collection schema. Fields added after the first
+// release are optional, so EnsureCollection can add them to existing
+// collections whose documents don't have them.
+var collectionFields = []map[string]interface{}{
+	{"name": "id", "type": "string"},
+	{"name": "file_path", "type": "string", "facet": true},
+	// Set on documents indexed before they existed by migratePaths
+	{"name": "rel_path", "type": "string", "facet": true, "optional": true},
+	{"name": "abs_path", "type": "string", "optional": true},
+	{"name": "project_path", "type": "string", "facet": true},
+	{"name": "project_root", "type": "string", "facet": true, "optional": true},
+	{"name": "project_type", "type": "string", "facet": true},
+	{"name": "language", "type": "string", "facet": true},
+	{"name": "chunk_type", "type": "string", "facet": true},
+	{"name": "content", "type": "string"},
+	{"name": "symbols", "type": "string[]", "optional": true},
+	{"name": "heading_path", "type": "string", "optional": true},
+	{"name": "summary", "type": "string", "optional": true},
+	{"name": "tags", "type": "string[]", "facet": true, "optional": true},
+	{"name": "embedding", "type": "float[]", "num_dim": EmbeddingDim},
+	{"name": "embedding_model", "type": "string", "facet": true, "optional": true},
+	{"name": "embedding_dim", "type": "int32", "optional": true},
+	{"name": "start_line", "type": "int32"},
+	{"name": "end_line", "type": "int32"},
+	{"name": "last_indexed", "type": "int64"},
+	// Searches filter on deleted:!=true, which also matches documents
+	// indexed before the field existed; deleted_at dates tombstones
+	{"name": "deleted", "type": "bool", "optional": true},
+	{"name": "deleted_at", "type": "int64", "optional": true},
+}
+
+// EnsureCollection creates the collection schema if it doesn't exist, and
+// adds the optional fields an existing collection lacks, migrating its
+// documents to rel_path and abs_path first if it lacks them.
+func (c *TypesenseClient) EnsureCollection(ctx context.Context) error {
+	have, found, err := c.existingFields(ctx)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if err := c.createCollection(ctx); err != nil {
+			return err
+		}
+		c.pathsMu.Lock()
+		c.pathsChecked = true
+		c.pathsMu.Unlock()
+		return nil
+	}
+	if len(have) == 0 {
 		return nil
 	}
 
-	// Collection doesn't exist, create it
-	if resp.StatusCode == http.StatusNotFound {
-		return c.createCollection(ctx)
+	c.pathsMu.Lock()
+	defer c.pathsMu.Unlock()
+	if err := c.migratePaths(ctx, have); err != nil {
+		return err
+	}
+	var missing []map[string]interface{}
+	for _, f := range collectionFields {
+		if f["optional"] == true && !have[f["name"].(string)] {
+			missing = append(missing, f)
+		}
 	}
+	if err := c.addFields(ctx, missing); err != nil {
+		return err
+	}
+	c.pathsChecked = true
+	return nil
+}
 
-	body, _ := io.ReadAll(resp.Body)
-	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+// existingFields returns the names of the collection's fields, and whether
+// it exists. They are empty if its schema can't be read.
+func (c *TypesenseClient) existingFields(ctx context.Context) (map[string]bool, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url+"/collections/"+c.collection, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("checking collection: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, false, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var existing struct {
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	have := map[string]bool{}
+	if err := json.NewDecoder(resp.Body).Decode(&existing); err == nil {
+		for _, f := range existing.Fields {
+			have[f.Name] = true
+		}
+	}
+	return have, true, nil
+}
+
+// CollectionStats holds summary statistics for the collection.
+type CollectionStats struct {
+	Name         string `json:"name"`
+	NumDocuments int64  `json:"num_documents"`
+	EmbeddingDim int    `json:"embedding_dim"` // num_dim of the embedding field
+}
+
+// ProjectFacets holds a project's document count broken down by facet.
+type ProjectFacets struct {
+	NumDocuments int64            `json:"num_documents"`
+	Languages    map[string]int64 `json:"languages"`
+	ChunkTypes   map[string]int64 `json:"chunk_types"`
+}
+
+// ListCollections returns the names of all collections on the server.
+func (c *TypesenseClient) ListCollections(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url+"/collections", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing collections: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	var collections []struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&collections); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	names := make([]string, len(collections))
+	for i, col := range collections {
+		names[i] = col.Name
+	}
+	return names, nil
+}
+
+// CollectionStats fetches summary statistics for the collection.
+func (c *TypesenseClient) CollectionStats(ctx context.Context) (*CollectionStats, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url+"/collections/"+c.collection, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching collection: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	var collection struct {
+		Name         string `json:"name"`
+		NumDocuments int64  `json:"num_documents"`
+		Fields       []struct {
+			Name   string `json:"name"`
+			NumDim int    `json:"num_dim"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	stats := &CollectionStats{Name: collection.Name, NumDocuments: collection.NumDocuments}
+	for _, f := range collection.Fields {
+		if f.Name == "embedding" {
+			stats.EmbeddingDim = f.NumDim
+		}
+	}
+
+	return stats, nil
+}
+
+// ProjectFacets returns per-language and per-chunk-type document counts
+// for documents belonging to the given project path.
+func (c *TypesenseClient) ProjectFacets(ctx context.Context, projectPath string) (*ProjectFacets, error) {
+	resp, err := c.facetSearch(ctx, "project_path:="+filterValue(projectPath), "language,chunk_type", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := &ProjectFacets{
+		NumDocuments: resp.Found,
+		Languages:    resp.counts("language"),
+		ChunkTypes:   resp.counts("chunk_type"),
+	}
+	return facets, nil
+}
+
+// maxProjectFacetValues bounds how many distinct project or file paths a
+// facet search returns. When a search hits it, the counts are taken from
+// the export endpoint instead. A variable so tests can lower it.
+var maxProjectFacetValues = 10000
+
+// ProjectPaths returns the document count for every project path in the collection.
+func (c *TypesenseClient) ProjectPaths(ctx context.Context) (map[string]int64, error) {
+	return c.countBy(ctx, "", "project_path")
+}
+
+// ProjectFiles returns the document count for each relative file path
+// indexed under the given project. History documents, whose paths name commits
+// rather than files, and tombstones are left out.
+func (c *TypesenseClient) ProjectFiles(ctx context.Context, projectPath string) (map[string]int64, error) {
+	if err := c.ensurePaths(ctx); err != nil {
+		return nil, err
+	}
+	return c.countBy(ctx, fmt.Sprintf("project_path:=%s && chunk_type:!=[%s,%s] && %s", filterValue(projectPath), ChunkTypeCommit, ChunkTypeDiffHunk, notDeletedFilter), "rel_path")
+}
+
+// countBy returns the document count for each value of field among the
+// documents matching filterBy. A facet search answers in one request;
+// when there are more values than it returns, the documents are streamed
+// from the export endpoint so no value is missed.
+func (c *TypesenseClient) countBy(ctx context.Context, filterBy, field string) (map[string]int64, error) {
+	resp, err := c.facetSearch(ctx, filterBy, field, maxProjectFacetValues)
+	if err != nil {
+		return nil, err
+	}
+	counts := resp.counts(field)
+	if len(counts) < maxProjectFacetValues {
+		return counts, nil
+	}
+	return c.exportCounts(ctx, filterBy, field)
+}
+
+// exportCounts streams the documents matching filterBy from the export
+// endpoint, reading only field, and counts them by its value. Memory use
+// grows with the number of distinct values, not of documents.
+func (c *TypesenseClient) exportCounts(ctx context.Context, filterBy, field string) (map[string]int64, error) {
+	params := url.Values{}
+	if filterBy != "" {
+		params.Set("filter_by", filterBy)
+	}
+	params.Set("include_fields", field)
+
+	body, err := c.export(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	counts := make(map[string]int64)
+	dec := json.NewDecoder(body)
+	for {
+		var doc map[string]any
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return counts, nil
+			}
+			return nil, fmt.Errorf("decoding export: %w", err)
+		}
+		if v, ok := doc[field].(string); ok {
+			counts[v]++
+		}
+	}
+}
+
+// ExportProject streams every document of the project at projectPath,
+// embeddings included, to fn, stopping at the first error it returns.
+func (c *TypesenseClient) ExportProject(ctx context.Context, projectPath string, fn func(IndexedChunk) error) error {
+	if projectPath == "" {
+		return errors.New("project path is required")
+	}
+	if err := c.ensurePaths(ctx); err != nil {
+		return err
+	}
+	params := url.Values{}
+	params.Set("filter_by", "project_path:="+filterValue(projectPath))
+
+	body, err := c.export(ctx, params)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var doc IndexedChunk
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("decoding export: %w", err)
+		}
+		if err := c.openContent(&doc); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// export opens the JSONL stream of the export endpoint with params
+func (c *TypesenseClient) export(ctx context.Context, params url.Values) (io.ReadCloser, error) {
+	endpoint := fmt.Sprintf("%s/collections/%s/documents/export?%s", c.url, c.collection, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exporting documents: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("export failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp.Body, nil
+}
+
+type facetResponse struct {
+	Found       int64 `json:"found"`
+	FacetCounts []struct {
+		FieldName string `json:"field_name"`
+		Counts    []struct {
+			Count int64  `json:"count"`
+			Value string `json:"value"`
+		} `json:"counts"`
+	} `json:"facet_counts"`
+}
+
+func (r *facetResponse) counts(field string) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, fc := range r.FacetCounts {
+		if fc.FieldName != field {
+			continue
+		}
+		for _, count := range fc.Counts {
+			counts[count.Value] = count.Count
+		}
+	}
+	return counts
+}
+
+// facetSearch runs a match-all search returning only facet counts.
+func (c *TypesenseClient) facetSearch(ctx context.Context, filterBy, facetBy string, maxValues int) (*facetResponse, error) {
+	params := url.Values{}
+	params.Set("q", "*")
+	params.Set("query_by", "content")
+	if filterBy != "" {
+		params.Set("filter_by", filterBy)
+	}
+	params.Set("facet_by", facetBy)
+	params.Set("max_facet_values", fmt.Sprintf("%d", maxValues))
+	params.Set("per_page", "0")
+
+	endpoint := fmt.Sprintf("%s/collections/%s/documents/search?%s", c.url, c.collection, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing facet search: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("facet search failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var searchResp facetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&searchResp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return &searchResp, nil
 }
 
 func (c *TypesenseClient) createCollection(ctx context.Context) error {
 	schema := map[string]interface{}{
-		"name": c.collection,
-		"fields": []map[string]interface{}{
-			{"name": "id", "type": "string"},
-			{"name": "file_path", "type": "string", "facet": true},
-			{"name": "project_path", "type": "string", "facet": true},
-			{"name": "project_type", "type": "string", "facet": true},
-			{"name": "language", "type": "string", "facet": true},
-			{"name": "chunk_type", "type": "string", "facet": true},
-			{"name": "content", "type": "string"},
-			{"name": "embedding", "type": "float[]", "num_dim": 768},
-			{"name": "start_line", "type": "int32"},
-			{"name": "end_line", "type": "int32"},
-			{"name": "last_indexed", "type": "int64"},
-		},
+		"name":   c.collection,
+		"fields": collectionFields,
 	}
 
 	body, err := json.Marshal(schema)
@@ -131,7 +593,38 @@ func (c *TypesenseClient) createCollection(ctx context.Context) error {
 	return nil
 }
 
-// UpsertChunks inserts or updates chunks in batches.
+// addFields adds fields to the existing collection's schema
+func (c *TypesenseClient) addFields(ctx context.Context, fields []map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{"fields": fields})
+	if err != nil {
+		return fmt.Errorf("marshaling schema update: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PATCH", c.url+"/collections/"+c.collection, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("updating collection schema: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update collection schema: %s", string(respBody))
+	}
+	return nil
+}
+
+// UpsertChunks inserts or updates chunks in batches. Chunks Typesense
+// rejects are returned in an *ImportError once every batch is sent.
 func (c *TypesenseClient) UpsertChunks(ctx context.Context, chunks []IndexedChunk) error {
 	if len(chunks) == 0 {
 		return nil
@@ -142,34 +635,96 @@ func (c *TypesenseClient) UpsertChunks(ctx context.Context, chunks []IndexedChun
 		batchSize = defaultBatchSize
 	}
 
+	var rejected ImportError
 	for i := 0; i < len(chunks); i += batchSize {
 		end := i + batchSize
 		if end > len(chunks) {
 			end = len(chunks)
 		}
-		batch := chunks[i:end]
+		batch, err := c.sealContent(chunks[i:end])
+		if err != nil {
+			return err
+		}
 
-		if err := c.upsertBatch(ctx, batch); err != nil {
+		// Rejected documents don't stop the other batches
+		var ie *ImportError
+		if err := c.upsertBatch(ctx, batch); errors.As(err, &ie) {
+			rejected.add(ie)
+		} else if err != nil {
 			return fmt.Errorf("upserting batch %d: %w", i/batchSize, err)
 		}
 	}
 
+	if len(rejected.Failed) > 0 {
+		return &rejected
+	}
 	return nil
 }
 
 func (c *TypesenseClient) upsertBatch(ctx context.Context, chunks []IndexedChunk) error {
+	docs := make([]any, len(chunks))
+	for i := range chunks {
+		docs[i] = chunks[i]
+	}
+	return c.importDocs(ctx, "upsert", docs)
+}
+
+// ImportError reports the documents of an import that Typesense rejected;
+// the rest were imported.
+type ImportError struct {
+	Failed map[string]string // document ID → Typesense's error
+}
+
+func (e *ImportError) Error() string {
+	ids := make([]string, 0, len(e.Failed))
+	for id := range e.Failed {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return fmt.Sprintf("%d documents rejected, e.g. %s: %s", len(ids), ids[0], e.Failed[ids[0]])
+}
+
+// add adds the documents other rejected to e
+func (e *ImportError) add(other *ImportError) {
+	if e.Failed == nil {
+		e.Failed = map[string]string{}
+	}
+	for id, msg := range other.Failed {
+		e.Failed[id] = msg
+	}
+}
+
+// importResult is a line of the import endpoint's response, one per
+// document in the order they were sent
+type importResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+}
+
+// importDocs sends docs to the import endpoint with the given action:
+// upsert, or update to set some fields of existing documents. The
+// endpoint answers 200 even when documents fail, so each document's
+// result is checked; the documents that failed are returned in an
+// *ImportError.
+func (c *TypesenseClient) importDocs(ctx context.Context, action string, docs []any) error {
 	// Build JSONL body
 	var buf bytes.Buffer
-	for _, chunk := range chunks {
-		data, err := json.Marshal(chunk)
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		data, err := json.Marshal(doc)
 		if err != nil {
-			return fmt.Errorf("marshaling chunk: %w", err)
+			return fmt.Errorf("marshaling document: %w", err)
 		}
+		var id struct {
+			ID string `json:"id"`
+		}
+		_ = json.Unmarshal(data, &id)
+		ids[i] = id.ID
 		buf.Write(data)
 		buf.WriteByte('\n')
 	}
 
-	endpoint := fmt.Sprintf("%s/collections/%s/documents/import?action=upsert", c.url, c.collection)
+	endpoint := fmt.Sprintf("%s/collections/%s/documents/import?action=%s", c.url, c.collection, action)
 	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, &buf)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
@@ -188,26 +743,191 @@ func (c *TypesenseClient) upsertBatch(ctx context.Context, chunks []IndexedChunk
 		return fmt.Errorf("import failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
+	rejected := &ImportError{Failed: map[string]string{}}
+	dec := json.NewDecoder(resp.Body)
+	for i := 0; ; i++ {
+		var r importResult
+		if err := dec.Decode(&r); err != nil {
+			if !errors.Is(err, io.EOF) {
+				return fmt.Errorf("decoding import results: %w", err)
+			}
+			break
+		}
+		if !r.Success && i < len(ids) {
+			rejected.Failed[ids[i]] = r.Error
+		}
+	}
+	if len(rejected.Failed) > 0 {
+		return rejected
+	}
 	return nil
 }
 
+// SetMatching sets how searches match query words; without it Typesense's
+// defaults apply.
+func (c *TypesenseClient) SetMatching(m Matching) {
+	c.matching = &m
+}
+
+// SetEmbeddingModel names the model query embeddings passed to Search
+// come from, so vector searches are kept to the documents embedded with
+// it. Without it, every document is searched whatever its model.
+func (c *TypesenseClient) SetEmbeddingModel(model string) {
+	c.modelMu.Lock()
+	defer c.modelMu.Unlock()
+	c.model = model
+	c.modelChecked = false
+	c.modelFilter = ""
+}
+
+// ModelMismatchError is returned by Search when no document was embedded
+// with the query's model: the vector distances would be meaningless.
+type ModelMismatchError struct {
+	Model   string   // of the query embedding
+	Indexed []string // models the documents were embedded with
+}
+
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("the query is embedded with %s but the index with %s; run `swarm-indexer reembed` after changing the model",
+		e.Model, strings.Join(e.Indexed, ", "))
+}
+
+// EmbeddingModels returns the document count for each embedding model in
+// the collection. Documents indexed before models were recorded aren't
+// counted.
+func (c *TypesenseClient) EmbeddingModels(ctx context.Context) (map[string]int64, error) {
+	return c.countBy(ctx, "", "embedding_model")
+}
+
+// embeddingModelFilter returns the filter keeping a vector search to the
+// documents embedded with the model set by SetEmbeddingModel, checking the
+// collection's models on first use. Documents without a recorded model
+// are searched too while they are the only other ones, as they most
+// likely predate the model being recorded rather than a model switch.
+func (c *TypesenseClient) embeddingModelFilter(ctx context.Context) (string, error) {
+	c.modelMu.Lock()
+	defer c.modelMu.Unlock()
+	if c.model == "" || c.modelChecked {
+		return c.modelFilter, nil
+	}
+
+	counts, err := c.EmbeddingModels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("checking embedding models: %w", err)
+	}
+	var others []string
+	for m := range counts {
+		if m != c.model {
+			others = append(others, m)
+		}
+	}
+	slices.Sort(others)
+	switch {
+	case len(others) == 0:
+	case counts[c.model] == 0:
+		return "", &ModelMismatchError{Model: c.model, Indexed: others}
+	default:
+		slog.Warn("some documents were embedded with another model and are left out of searches; run `swarm-indexer reembed` to include them",
+			"model", c.model, "others", others)
+		c.modelFilter = "embedding_model:=" + filterValue(c.model)
+	}
+	c.modelChecked = true
+	return c.modelFilter, nil
+}
+
+// SearchFilter narrows a search to the documents matching every field
+// that is set.
+type SearchFilter struct {
+	ProjectPath string
+	Language    string
+	ChunkType   string
+	Tags        []string // documents must have all of them
+}
+
+// filters returns the filter_by clauses of f
+func (f SearchFilter) filters() []string {
+	var filters []string
+	for _, field := range []struct{ name, value string }{
+		{"project_path", f.ProjectPath},
+		{"language", f.Language},
+		{"chunk_type", f.ChunkType},
+	} {
+		if field.value != "" {
+			filters = append(filters, field.name+":="+filterValue(field.value))
+		}
+	}
+	for _, tag := range f.Tags {
+		filters = append(filters, "tags:=["+filterValue(tag)+"]")
+	}
+	return filters
+}
+
+// SearchHit is a document found by a search and how well it matched.
+type SearchHit struct {
+	IndexedChunk
+	// Score is the rank fusion score of a hybrid search, from 0 to 1, or
+	// Typesense's text match score of a keyword-only one
+	Score float64
+}
+
 // Search performs hybrid search with both text query and vector embedding.
+// Tombstones are never returned. After SetEmbeddingModel, documents
+// embedded with other models are left out, and it fails with a
+// ModelMismatchError if every document was. After SetContentKey, the
+// content of the results is decrypted.
 func (c *TypesenseClient) Search(ctx context.Context, query string, embedding []float32, limit int) ([]IndexedChunk, error) {
+	hits, err := c.SearchHits(ctx, query, embedding, limit, SearchFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var results []IndexedChunk
+	for _, hit := range hits {
+		results = append(results, hit.IndexedChunk)
+	}
+	return results, nil
+}
+
+// SearchHits searches like Search, only returning documents that match
+// filter, and returns their scores with them.
+func (c *TypesenseClient) SearchHits(ctx context.Context, query string, embedding []float32, limit int, filter SearchFilter) ([]SearchHit, error) {
 	searchRequest := map[string]interface{}{
 		"searches": []map[string]interface{}{
 			{
 				"collection": c.collection,
 				"q":          query,
-				"query_by":   "content",
-				"per_page":   limit,
+				// Declared names and section headings rank above
+				// mentions in the content
+				"query_by":         "symbols,heading_path,content",
+				"query_by_weights": "3,2,1",
+				"per_page":         limit,
 			},
 		},
 	}
 
+	search := searchRequest["searches"].([]map[string]interface{})[0]
+	if c.contentCipher != nil {
+		// Encrypted content can't match query words
+		search["query_by"], search["query_by_weights"] = "symbols,heading_path", "3,2"
+	}
+	filters := append([]string{notDeletedFilter}, filter.filters()...)
+	if m := c.matching; m != nil {
+		search["num_typos"] = m.NumTypos
+		search["prefix"] = m.Prefix
+		search["drop_tokens_threshold"] = m.DropTokensThreshold
+	}
+
 	// Add vector search if embedding provided
 	if len(embedding) > 0 {
-		searchRequest["searches"].([]map[string]interface{})[0]["vector_query"] = fmt.Sprintf("embedding:(%v)", formatEmbedding(embedding))
+		filterBy, err := c.embeddingModelFilter(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if filterBy != "" {
+			filters = append(filters, filterBy)
+		}
+		search["vector_query"] = fmt.Sprintf("embedding:(%v)", formatEmbedding(embedding))
 	}
+	search["filter_by"] = strings.Join(filters, " && ")
 
 	body, err := json.Marshal(searchRequest)
 	if err != nil {
@@ -235,7 +955,11 @@ func (c *TypesenseClient) Search(ctx context.Context, query string, embedding []
 	var searchResp struct {
 		Results []struct {
 			Hits []struct {
-				Document IndexedChunk `json:"document"`
+				Document         IndexedChunk `json:"document"`
+				TextMatch        int64        `json:"text_match"`
+				HybridSearchInfo *struct {
+					RankFusionScore float64 `json:"rank_fusion_score"`
+				} `json:"hybrid_search_info"`
 			} `json:"hits"`
 		} `json:"results"`
 	}
@@ -244,10 +968,17 @@ func (c *TypesenseClient) Search(ctx context.Context, query string, embedding []
 		return nil, fmt.Errorf("decoding response: %w", err)
 	}
 
-	var results []IndexedChunk
+	var results []SearchHit
 	if len(searchResp.Results) > 0 {
 		for _, hit := range searchResp.Results[0].Hits {
-			results = append(results, hit.Document)
+			if err := c.openContent(&hit.Document); err != nil {
+				return nil, err
+			}
+			score := float64(hit.TextMatch)
+			if hit.HybridSearchInfo != nil {
+				score = hit.HybridSearchInfo.RankFusionScore
+			}
+			results = append(results, SearchHit{IndexedChunk: hit.Document, Score: score})
 		}
 	}
 
@@ -267,31 +998,386 @@ func formatEmbedding(embedding []float32) string {
 	return buf.String()
 }
 
-// DeleteByPath removes all documents for a given file path.
-func (c *TypesenseClient) DeleteByPath(ctx context.Context, filePath string) error {
-	if filePath == "" {
+// DeleteByPath removes all documents for a given absolute file path.
+func (c *TypesenseClient) DeleteByPath(ctx context.Context, absPath string) error {
+	if absPath == "" {
 		return errors.New("file path is required")
 	}
+	_, err := c.DeleteByPaths(ctx, []string{absPath})
+	return err
+}
+
+// DeleteByPaths removes all documents for the given absolute file paths,
+// in any project, batching them like DeleteFiles, and returns how many
+// were deleted.
+func (c *TypesenseClient) DeleteByPaths(ctx context.Context, absPaths []string) (int, error) {
+	if slices.Contains(absPaths, "") {
+		return 0, errors.New("file paths cannot be empty")
+	}
+	if err := c.ensurePaths(ctx); err != nil {
+		return 0, err
+	}
+	return c.deleteIn(ctx, "abs_path", absPaths)
+}
+
+// DeleteByProject removes all documents indexed under a project path and
+// returns how many were deleted.
+func (c *TypesenseClient) DeleteByProject(ctx context.Context, projectPath string) (int, error) {
+	if projectPath == "" {
+		return 0, errors.New("project path is required")
+	}
+	return c.deleteByFilter(ctx, "project_path:="+filterValue(projectPath))
+}
+
+// CountIndexedBefore returns how many documents of the project were last
+// indexed before the given unix time.
+func (c *TypesenseClient) CountIndexedBefore(ctx context.Context, projectPath string, before int64) (int64, error) {
+	if projectPath == "" {
+		return 0, errors.New("project path is required")
+	}
+	counts, err := c.countBy(ctx, indexedBeforeFilter(projectPath, before), "project_path")
+	if err != nil {
+		return 0, err
+	}
+	return counts[projectPath], nil
+}
+
+// FilesIndexedBefore returns how many documents of each of the project's
+// files were last indexed before the given unix time, keyed by path
+// relative to the project.
+func (c *TypesenseClient) FilesIndexedBefore(ctx context.Context, projectPath string, before int64) (map[string]int64, error) {
+	if projectPath == "" {
+		return nil, errors.New("project path is required")
+	}
+	if err := c.ensurePaths(ctx); err != nil {
+		return nil, err
+	}
+	return c.countBy(ctx, indexedBeforeFilter(projectPath, before), "rel_path")
+}
+
+// DeleteIndexedBefore removes the documents of the project last indexed
+// before the given unix time and returns how many were deleted.
+func (c *TypesenseClient) DeleteIndexedBefore(ctx context.Context, projectPath string, before int64) (int, error) {
+	if projectPath == "" {
+		return 0, errors.New("project path is required")
+	}
+	return c.deleteByFilter(ctx, indexedBeforeFilter(projectPath, before))
+}
+
+func indexedBeforeFilter(projectPath string, before int64) string {
+	return fmt.Sprintf("project_path:=%s && last_indexed:<%d", filterValue(projectPath), before)
+}
+
+// DeleteByFile removes the documents for a single file within a project.
+func (c *TypesenseClient) DeleteByFile(ctx context.Context, projectPath, relPath string) (int, error) {
+	if projectPath == "" || relPath == "" {
+		return 0, errors.New("project path and file path are required")
+	}
+	if err := c.ensurePaths(ctx); err != nil {
+		return 0, err
+	}
+	return c.deleteByFilter(ctx, fmt.Sprintf("project_path:=%s && rel_path:=%s", filterValue(projectPath), filterValue(relPath)))
+}
+
+// deleteBatchSize bounds how many file paths or IDs go into one delete
+// filter
+const deleteBatchSize = 100
+
+// maxDeleteFilterLength bounds the URL-encoded length of a batched delete
+// filter, so its URL stays within what servers and proxies accept
+// (commonly 8 KB) however long the paths are
+const maxDeleteFilterLength = 6000
+
+// deleteDocsPerPass is how many documents Typesense deletes at a time
+// when deleting by filter, so a large delete doesn't hold up writes
+const deleteDocsPerPass = 500
+
+// deleteConcurrency bounds how many batched deletes are in flight at once
+const deleteConcurrency = 4
 
-	filterBy := fmt.Sprintf("file_path:=%s", filePath)
+// DeleteFiles removes the documents for several files within a project,
+// batching paths into as few filtered deletes as possible.
+func (c *TypesenseClient) DeleteFiles(ctx context.Context, projectPath string, relPaths []string) (int, error) {
+	if projectPath == "" {
+		return 0, errors.New("project path is required")
+	}
+	if err := c.ensurePaths(ctx); err != nil {
+		return 0, err
+	}
+	return c.deleteIn(ctx, "project_path:="+filterValue(projectPath)+" && rel_path", relPaths)
+}
+
+// DeleteChunks removes the documents with the given IDs, batching them
+// like DeleteFiles.
+func (c *TypesenseClient) DeleteChunks(ctx context.Context, ids []string) (int, error) {
+	return c.deleteIn(ctx, "id", ids)
+}
+
+// lineUpdate moves a document to other lines of its file
+type lineUpdate struct {
+	ID          string `json:"id"`
+	StartLine   int    `json:"start_line"`
+	EndLine     int    `json:"end_line"`
+	LastIndexed int64  `json:"last_indexed"`
+}
+
+// UpdateLines sets the lines and last indexed time of the chunks'
+// documents, leaving the rest of them, embeddings included, as they are.
+func (c *TypesenseClient) UpdateLines(ctx context.Context, chunks []IndexedChunk) error {
+	batchSize := c.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	for i := 0; i < len(chunks); i += batchSize {
+		batch := chunks[i:min(i+batchSize, len(chunks))]
+		docs := make([]any, len(batch))
+		for j, ch := range batch {
+			docs[j] = lineUpdate{ID: ch.ID, StartLine: ch.StartLine, EndLine: ch.EndLine, LastIndexed: ch.LastIndexed}
+		}
+		if err := c.importDocs(ctx, "update", docs); err != nil {
+			return fmt.Errorf("updating lines of batch %d: %w", i/batchSize, err)
+		}
+	}
+	return nil
+}
+
+// deleteIn deletes the documents whose field matches one of values, in
+// the filters of deleteFilters sent deleteConcurrency at a time. field may
+// be prefixed by other conditions, e.g. "project_path:=`/p` && rel_path".
+func (c *TypesenseClient) deleteIn(ctx context.Context, field string, values []string) (int, error) {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		total int
+		errs  []error
+	)
+	sem := make(chan struct{}, deleteConcurrency)
+	for _, filterBy := range deleteFilters(field, values) {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			n, err := c.deleteByFilter(ctx, filterBy)
+			mu.Lock()
+			defer mu.Unlock()
+			total += n
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return total, errors.Join(errs...)
+}
+
+// deleteFilters splits values into filters matching field against up to
+// deleteBatchSize of them, each at most maxDeleteFilterLength long once
+// URL-encoded unless a single value is longer
+func deleteFilters(field string, values []string) []string {
+	var filters, batch []string
+	size := 0
+	flush := func() {
+		if len(batch) > 0 {
+			filters = append(filters, fmt.Sprintf("%s:=[%s]", field, strings.Join(batch, ",")))
+			batch, size = nil, 0
+		}
+	}
+	base := len(url.QueryEscape(field + ":=[]"))
+	for _, v := range values {
+		quoted := filterValue(v)
+		n := len(url.QueryEscape(quoted)) + 3 // and an encoded comma
+		if len(batch) == deleteBatchSize || (len(batch) > 0 && base+size+n > maxDeleteFilterLength) {
+			flush()
+		}
+		batch = append(batch, quoted)
+		size += n
+	}
+	flush()
+	return filters
+}
+
+// filterValue quotes v for a filter_by expression, escaping the backticks
+// in it so a path can't end the value early
+func filterValue(v string) string {
+	return "`" + strings.ReplaceAll(v, "`", "\\`") + "`"
+}
+
+// DropCollection deletes the whole collection. It is not an error if the
+// collection doesn't exist; EnsureCollection recreates it on the next index.
+func (c *TypesenseClient) DropCollection(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.url+"/collections/"+c.collection, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("dropping collection: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+	return nil
+}
+
+// UpdateTags replaces the tags of every document under a project path in
+// place, without re-embedding them, and returns how many were updated.
+func (c *TypesenseClient) UpdateTags(ctx context.Context, projectPath string, tags []string) (int, error) {
+	if projectPath == "" {
+		return 0, errors.New("project path is required")
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	return c.updateByFilter(ctx, "project_path:="+filterValue(projectPath), map[string][]string{"tags": tags})
+}
+
+// notDeletedFilter leaves tombstones out of searches
+const notDeletedFilter = "deleted:!=true"
+
+// TombstoneFiles marks the documents of the given files in a project as
+// deleted at the given unix time, batching them like DeleteFiles, and
+// returns how many were marked. Searches leave tombstones out.
+func (c *TypesenseClient) TombstoneFiles(ctx context.Context, projectPath string, relPaths []string, deletedAt int64) (int, error) {
+	if projectPath == "" {
+		return 0, errors.New("project path is required")
+	}
+	if err := c.ensurePaths(ctx); err != nil {
+		return 0, err
+	}
+	fields := map[string]any{"deleted": true, "deleted_at": deletedAt}
+	return c.updateIn(ctx, "project_path:="+filterValue(projectPath)+" && rel_path", relPaths, fields)
+}
+
+// Undelete restores the tombstones of a project, only those of the given
+// files unless relPaths is empty, and returns how many were restored.
+func (c *TypesenseClient) Undelete(ctx context.Context, projectPath string, relPaths []string) (int, error) {
+	if projectPath == "" {
+		return 0, errors.New("project path is required")
+	}
+	fields := map[string]any{"deleted": false, "deleted_at": 0}
+	filterBy := tombstoneFilter(projectPath, 0)
+	if len(relPaths) == 0 {
+		return c.updateByFilter(ctx, filterBy, fields)
+	}
+	if err := c.ensurePaths(ctx); err != nil {
+		return 0, err
+	}
+	return c.updateIn(ctx, filterBy+" && rel_path", relPaths, fields)
+}
+
+// Tombstones returns the number of tombstones in each project marked
+// deleted before the given unix time, or at any time if before is 0.
+func (c *TypesenseClient) Tombstones(ctx context.Context, before int64) (map[string]int64, error) {
+	return c.countBy(ctx, tombstoneFilter("", before), "project_path")
+}
+
+// DeleteTombstones deletes the tombstones of a project marked deleted
+// before the given unix time and returns how many were deleted.
+func (c *TypesenseClient) DeleteTombstones(ctx context.Context, projectPath string, before int64) (int, error) {
+	if projectPath == "" {
+		return 0, errors.New("project path is required")
+	}
+	return c.deleteByFilter(ctx, tombstoneFilter(projectPath, before))
+}
+
+// tombstoneFilter matches the tombstones of projectPath, or of every
+// project if empty, marked deleted before the given unix time unless it
+// is 0
+func tombstoneFilter(projectPath string, before int64) string {
+	filterBy := "deleted:=true"
+	if projectPath != "" {
+		filterBy = "project_path:=" + filterValue(projectPath) + " && " + filterBy
+	}
+	if before > 0 {
+		filterBy += fmt.Sprintf(" && deleted_at:<%d", before)
+	}
+	return filterBy
+}
+
+// updateIn sets fields on the documents whose field matches one of
+// values, in the filters of deleteFilters, and returns how many were
+// updated. field may be prefixed by other conditions like in deleteIn.
+func (c *TypesenseClient) updateIn(ctx context.Context, field string, values []string, fields any) (int, error) {
+	total := 0
+	for _, filterBy := range deleteFilters(field, values) {
+		n, err := c.updateByFilter(ctx, filterBy, fields)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// updateByFilter sets fields on the documents matching filterBy and
+// returns how many were updated.
+func (c *TypesenseClient) updateByFilter(ctx context.Context, filterBy string, fields any) (int, error) {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling fields: %w", err)
+	}
 	endpoint := fmt.Sprintf("%s/collections/%s/documents?filter_by=%s", c.url, c.collection, url.QueryEscape(filterBy))
 
+	req, err := http.NewRequestWithContext(ctx, "PATCH", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("updating documents: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("update failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		NumUpdated int `json:"num_updated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("decoding update response: %w", err)
+	}
+	return result.NumUpdated, nil
+}
+
+func (c *TypesenseClient) deleteByFilter(ctx context.Context, filterBy string) (int, error) {
+	params := url.Values{}
+	params.Set("filter_by", filterBy)
+	params.Set("batch_size", strconv.Itoa(deleteDocsPerPass))
+	endpoint := fmt.Sprintf("%s/collections/%s/documents?%s", c.url, c.collection, params.Encode())
+
 	req, err := http.NewRequestWithContext(ctx, "DELETE", endpoint, nil)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return 0, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("X-TYPESENSE-API-KEY", c.apiKey)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("deleting documents: %w", err)
+		return 0, fmt.Errorf("deleting documents: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("delete failed with status %d: %s", resp.StatusCode, string(body))
+		return 0, fmt.Errorf("delete failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	var result struct {
+		NumDeleted int `json:"num_deleted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("decoding delete response: %w", err)
+	}
+	return result.NumDeleted, nil
 }
@@ -3,9 +3,16 @@ package indexer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -123,6 +130,117 @@ func TestEnsureCollection_AlreadyExists(t *testing.T) {
 	}
 }
 
+func TestEnsureCollection_AddsMissingFields(t *testing.T) {
+	var added []string
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/collections/test-collection":
+			// A collection created before project_root and symbols existed
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":   "test-collection",
+				"fields": []map[string]string{{"name": "file_path"}, {"name": "project_root"}, {"name": "content"}},
+			})
+		case r.Method == "GET" && r.URL.Path == "/collections/test-collection/documents/export":
+			w.Write([]byte(`{"id": "a", "project_path": "/src/api", "file_path": "cmd/main.go"}` + "\n"))
+		case r.Method == "POST" && r.URL.Path == "/collections/test-collection/documents/import":
+			if got := r.URL.Query().Get("action"); got != "update" {
+				t.Errorf("expected the paths set with action=update, got %s", got)
+			}
+			body, _ := io.ReadAll(r.Body)
+			if want := `{"id":"a","rel_path":"cmd/main.go","abs_path":"/src/api/cmd/main.go"}` + "\n"; string(body) != want {
+				t.Errorf("expected the paths of document a set, got %s", body)
+			}
+			_, _ = w.Write([]byte(`{"success":true}`))
+		case r.Method == "DELETE" && r.URL.Path == "/collections/test-collection/documents":
+			_, _ = w.Write([]byte(`{"num_deleted": 1}`))
+		case r.Method == "PATCH" && r.URL.Path == "/collections/test-collection":
+			var update struct {
+				Fields []struct {
+					Name     string `json:"name"`
+					Optional bool   `json:"optional"`
+				} `json:"fields"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				t.Errorf("decoding schema update: %v", err)
+			}
+			for _, f := range update.Fields {
+				if !f.Optional {
+					t.Errorf("expected only optional fields to be added, got %s", f.Name)
+				}
+				added = append(added, f.Name)
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := client.EnsureCollection(context.Background()); err != nil {
+		t.Fatalf("EnsureCollection failed: %v", err)
+	}
+	if want := "rel_path,abs_path,symbols,heading_path,summary,tags,embedding_model,embedding_dim,deleted,deleted_at"; strings.Join(added, ",") != want {
+		t.Errorf("expected %s to be added, got %v", want, added)
+	}
+	// Documents are migrated before the fields are added, which indexes them
+	want := "GET /collections/test-collection,GET /collections/test-collection/documents/export,POST /collections/test-collection/documents/import,PATCH /collections/test-collection"
+	if got := strings.Join(requests, ","); got != want {
+		t.Errorf("unexpected requests %s", got)
+	}
+
+	// Filters on the paths don't check the collection again
+	requests = nil
+	if _, err := client.DeleteFiles(context.Background(), "/src/api", []string{"cmd/main.go"}); err != nil || len(requests) != 1 {
+		t.Errorf("expected only the delete to be requested, got %v", requests)
+	}
+}
+
+func TestEnsurePaths_MigratesOnFirstFilter(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/collections/test-collection":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":   "test-collection",
+				"fields": []map[string]string{{"name": "file_path"}, {"name": "project_path"}},
+			})
+		case r.URL.Path == "/collections/test-collection/documents/export":
+			w.Write([]byte(`{"id": "a", "project_path": "/src/api", "file_path": "cmd/main.go"}` + "\n"))
+		case r.URL.Path == "/collections/test-collection/documents/import":
+			_, _ = w.Write([]byte(`{"success":true}`))
+		case r.Method == "DELETE":
+			if got := r.URL.Query().Get("filter_by"); got != "abs_path:=[`/src/api/cmd/main.go`]" {
+				t.Errorf("unexpected filter_by: %s", got)
+			}
+			_, _ = w.Write([]byte(`{"num_deleted": 1}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	for range 2 {
+		if _, err := client.DeleteByPaths(context.Background(), []string{"/src/api/cmd/main.go"}); err != nil {
+			t.Fatalf("DeleteByPaths failed: %v", err)
+		}
+	}
+	want := "GET /collections/test-collection,GET /collections/test-collection/documents/export,POST /collections/test-collection/documents/import," +
+		"PATCH /collections/test-collection,DELETE /collections/test-collection/documents,DELETE /collections/test-collection/documents"
+	if got := strings.Join(requests, ","); got != want {
+		t.Errorf("unexpected requests %s", got)
+	}
+}
+
 func TestUpsertChunks_SingleChunk(t *testing.T) {
 	upsertCalled := false
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -168,6 +286,27 @@ func TestUpsertChunks_SingleChunk(t *testing.T) {
 	}
 }
 
+func TestUpdateLines(t *testing.T) {
+	var action, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action = r.URL.Query().Get("action")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	moved := []IndexedChunk{{ID: "a", Content: "func main() {}", Embedding: []float32{0.1}, StartLine: 8, EndLine: 9, LastIndexed: 1700000000}}
+	if err := client.UpdateLines(context.Background(), moved); err != nil {
+		t.Fatalf("UpdateLines failed: %v", err)
+	}
+	want := `{"id":"a","start_line":8,"end_line":9,"last_indexed":1700000000}` + "\n"
+	if action != "update" || body != want {
+		t.Errorf("expected only the lines sent with action=update, got %s: %s", action, body)
+	}
+}
+
 func TestUpsertChunks_EmptySlice(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Error("no request should be made for empty slice")
@@ -236,6 +375,33 @@ func TestUpsertChunks_MultipleBatches(t *testing.T) {
 	}
 }
 
+func TestUpsertChunks_Rejected(t *testing.T) {
+	batchCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		batchCount++
+		// Typesense answers 200 with a result per document
+		_, _ = w.Write([]byte(`{"success":true}` + "\n" + `{"success":false,"error":"Field ` + "`embedding`" + ` must have 768 dimensions.","document":"{}"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	client.batchSize = 2
+
+	chunks := []IndexedChunk{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+	err = client.UpsertChunks(context.Background(), chunks)
+	var ie *ImportError
+	if !errors.As(err, &ie) {
+		t.Fatalf("expected an ImportError, got %v", err)
+	}
+	// The second batch is sent despite the first's rejected chunk
+	if batchCount != 2 || len(ie.Failed) != 2 || ie.Failed["b"] != "Field `embedding` must have 768 dimensions." || ie.Failed["d"] == "" {
+		t.Errorf("expected b and d rejected by 2 batches, got %d batches, %v", batchCount, ie.Failed)
+	}
+}
+
 func TestSearch_ReturnsResults(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" && strings.Contains(r.URL.Path, "/multi_search") {
@@ -330,6 +496,14 @@ func TestSearch_TextOnlySearch(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" && strings.Contains(r.URL.Path, "/multi_search") {
 			searchPerformed = true
+			var req struct {
+				Searches []map[string]interface{} `json:"searches"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Searches) != 1 {
+				t.Errorf("unexpected search request: %v", err)
+			} else if req.Searches[0]["query_by"] != "symbols,heading_path,content" {
+				t.Errorf("expected symbols and headings to be searched before content, got %v", req.Searches[0]["query_by"])
+			}
 			w.WriteHeader(http.StatusOK)
 			response := map[string]interface{}{
 				"results": []interface{}{
@@ -361,6 +535,129 @@ func TestSearch_TextOnlySearch(t *testing.T) {
 	}
 }
 
+func TestSearch_Matching(t *testing.T) {
+	var searches []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Searches []map[string]interface{} `json:"searches"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		searches = append(searches, req.Searches...)
+		w.Write([]byte(`{"results": [{"hits": []}]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	ctx := context.Background()
+	if _, err := client.Search(ctx, "parseConfig", nil, 10); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	client.SetMatching(Matching{NumTypos: 0, Prefix: false, DropTokensThreshold: 0})
+	if _, err := client.Search(ctx, "parseConfig", nil, 10); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if _, ok := searches[0]["num_typos"]; ok {
+		t.Errorf("expected Typesense's defaults without SetMatching, got %v", searches[0])
+	}
+	if searches[1]["num_typos"] != 0.0 || searches[1]["prefix"] != false || searches[1]["drop_tokens_threshold"] != 0.0 {
+		t.Errorf("expected strict matching parameters, got %v", searches[1])
+	}
+}
+
+func TestSearch_EmbeddingModel(t *testing.T) {
+	var models string
+	var searches []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/collections/test-collection/documents/search":
+			if got := r.URL.Query().Get("facet_by"); got != "embedding_model" {
+				t.Errorf("expected facet_by=embedding_model, got %s", got)
+			}
+			w.Write([]byte(`{"facet_counts": [{"field_name": "embedding_model", "counts": [` + models + `]}]}`))
+		case "/multi_search":
+			var req struct {
+				Searches []map[string]interface{} `json:"searches"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			searches = append(searches, req.Searches...)
+			w.Write([]byte(`{"results": [{"hits": []}]}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	search := func(model string) error {
+		client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+		client.SetEmbeddingModel(model)
+		_, err := client.Search(context.Background(), "query", []float32{0.1, 0.2}, 10)
+		return err
+	}
+
+	// Documents predating recorded models are all searched
+	models = ``
+	if err := search("text-embedding-004"); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if got := searches[0]["filter_by"]; got != "deleted:!=true" {
+		t.Errorf("expected no model filter without recorded models, got %v", got)
+	}
+
+	// Documents of another model are left out
+	models = `{"count": 3, "value": "text-embedding-004"}, {"count": 2, "value": "text-embedding-005"}`
+	if err := search("text-embedding-005"); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if got := searches[1]["filter_by"]; got != "deleted:!=true && embedding_model:=`text-embedding-005`" {
+		t.Errorf("expected a filter on the query's model, got %v", got)
+	}
+
+	// Searching a collection with none of the query's model is refused
+	models = `{"count": 3, "value": "text-embedding-004"}`
+	var mismatch *ModelMismatchError
+	if err := search("text-embedding-005"); !errors.As(err, &mismatch) {
+		t.Fatalf("expected a ModelMismatchError, got %v", err)
+	}
+	if len(mismatch.Indexed) != 1 || mismatch.Indexed[0] != "text-embedding-004" {
+		t.Errorf("unexpected indexed models %v", mismatch.Indexed)
+	}
+	if len(searches) != 2 {
+		t.Errorf("expected the refused search not to run, got %d searches", len(searches))
+	}
+}
+
+func TestSearchHits_Filter(t *testing.T) {
+	var searches []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Searches []map[string]interface{} `json:"searches"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		searches = append(searches, req.Searches...)
+		w.Write([]byte(`{"results": [{"hits": [
+			{"document": {"id": "a", "rel_path": "a.go"}, "text_match": 100, "hybrid_search_info": {"rank_fusion_score": 0.75}},
+			{"document": {"id": "b", "rel_path": "b.go"}, "text_match": 100}
+		]}]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	filter := SearchFilter{ProjectPath: "/work/api", Language: "go", Tags: []string{"backend", "pay`ments"}}
+	hits, err := client.SearchHits(context.Background(), "query", nil, 10, filter)
+	if err != nil {
+		t.Fatalf("SearchHits failed: %v", err)
+	}
+
+	want := "deleted:!=true && project_path:=`/work/api` && language:=`go` && tags:=[`backend`] && tags:=[`pay\\`ments`]"
+	if got := searches[0]["filter_by"]; got != want {
+		t.Errorf("expected filter %q, got %v", want, got)
+	}
+	if len(hits) != 2 || hits[0].RelPath != "a.go" || hits[0].Score != 0.75 || hits[1].Score != 100 {
+		t.Errorf("unexpected hits %+v", hits)
+	}
+}
+
 func TestDeleteByPath_RemovesDocuments(t *testing.T) {
 	deleteRequested := false
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -368,8 +665,8 @@ func TestDeleteByPath_RemovesDocuments(t *testing.T) {
 			deleteRequested = true
 			// Check filter_by parameter
 			filterBy := r.URL.Query().Get("filter_by")
-			if !strings.Contains(filterBy, "file_path") {
-				t.Errorf("expected filter_by to contain file_path, got: %s", filterBy)
+			if filterBy != "abs_path:=[`/path/to/file.go`]" {
+				t.Errorf("expected filter_by on abs_path, got: %s", filterBy)
 			}
 			w.WriteHeader(http.StatusOK)
 			_ = json.NewEncoder(w).Encode(map[string]int{"num_deleted": 3})
@@ -424,3 +721,800 @@ func TestDeleteByPath_EmptyPath(t *testing.T) {
 		t.Fatal("expected error for empty path")
 	}
 }
+
+func TestDeleteByProject_ReturnsCount(t *testing.T) {
+	var filterBy string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" && strings.Contains(r.URL.Path, "/documents") {
+			filterBy = r.URL.Query().Get("filter_by")
+			_ = json.NewEncoder(w).Encode(map[string]int{"num_deleted": 42})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, err := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	n, err := client.DeleteByProject(context.Background(), "/home/user/project")
+	if err != nil {
+		t.Fatalf("DeleteByProject failed: %v", err)
+	}
+	if n != 42 {
+		t.Errorf("expected 42 deleted, got %d", n)
+	}
+	if filterBy != "project_path:=`/home/user/project`" {
+		t.Errorf("unexpected filter_by: %s", filterBy)
+	}
+}
+
+func TestIndexedBefore(t *testing.T) {
+	var filters []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters = append(filters, r.URL.Query().Get("filter_by"))
+		if r.Method == "DELETE" {
+			w.Write([]byte(`{"num_deleted": 3}`))
+			return
+		}
+		w.Write([]byte(`{"facet_counts": [{"field_name": "project_path", "counts": [{"count": 3, "value": "/notes"}]}, {"field_name": "rel_path", "counts": [{"count": 3, "value": "todo.md"}]}]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	ctx := context.Background()
+	if n, err := client.CountIndexedBefore(ctx, "/notes", 1700000000); err != nil || n != 3 {
+		t.Errorf("expected 3 expired documents, got %d (%v)", n, err)
+	}
+	if n, err := client.DeleteIndexedBefore(ctx, "/notes", 1700000000); err != nil || n != 3 {
+		t.Errorf("expected 3 deleted, got %d (%v)", n, err)
+	}
+	want := "project_path:=`/notes` && last_indexed:<1700000000"
+	if len(filters) != 2 || filters[0] != want || filters[1] != want {
+		t.Errorf("expected both filtered by %q, got %v", want, filters)
+	}
+
+	files, err := client.FilesIndexedBefore(ctx, "/notes", 1700000000)
+	if err != nil || !reflect.DeepEqual(files, map[string]int64{"todo.md": 3}) {
+		t.Errorf("expected the expired files, got %v (%v)", files, err)
+	}
+	if got := filters[len(filters)-1]; got != want {
+		t.Errorf("expected files filtered by %q, got %q", want, got)
+	}
+}
+
+func TestTombstones(t *testing.T) {
+	var updates []string
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PATCH":
+			updates = append(updates, r.URL.Query().Get("filter_by"))
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			bodies = append(bodies, body)
+			w.Write([]byte(`{"num_updated": 2}`))
+		case "DELETE":
+			updates = append(updates, r.URL.Query().Get("filter_by"))
+			w.Write([]byte(`{"num_deleted": 2}`))
+		default:
+			if r.URL.Path == "/collections/test-collection" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if got := r.URL.Query().Get("filter_by"); got != "deleted:=true && deleted_at:<1700000000" {
+				t.Errorf("unexpected filter_by: %s", got)
+			}
+			w.Write([]byte(`{"facet_counts": [{"field_name": "project_path", "counts": [{"count": 2, "value": "/repo"}]}]}`))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	ctx := context.Background()
+	if n, err := client.TombstoneFiles(ctx, "/repo", []string{"a.go"}, 1690000000); err != nil || n != 2 {
+		t.Errorf("expected 2 tombstoned, got %d (%v)", n, err)
+	}
+	if counts, err := client.Tombstones(ctx, 1700000000); err != nil || counts["/repo"] != 2 {
+		t.Errorf("expected 2 tombstones in /repo, got %v (%v)", counts, err)
+	}
+	if _, err := client.Undelete(ctx, "/repo", nil); err != nil {
+		t.Errorf("Undelete failed: %v", err)
+	}
+	if _, err := client.DeleteTombstones(ctx, "/repo", 1700000000); err != nil {
+		t.Errorf("DeleteTombstones failed: %v", err)
+	}
+
+	want := []string{
+		"project_path:=`/repo` && rel_path:=[`a.go`]",
+		"project_path:=`/repo` && deleted:=true",
+		"project_path:=`/repo` && deleted:=true && deleted_at:<1700000000",
+	}
+	if strings.Join(updates, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected filters %q, got %q", want, updates)
+	}
+	if bodies[0]["deleted"] != true || bodies[0]["deleted_at"] != 1690000000.0 || bodies[1]["deleted"] != false {
+		t.Errorf("unexpected updates %v", bodies)
+	}
+}
+
+func TestDeleteByProject_EmptyPath(t *testing.T) {
+	client, _ := NewTypesenseClient("http://localhost:8108", "test-api-key", "test-collection")
+	if _, err := client.DeleteByProject(context.Background(), ""); err == nil {
+		t.Fatal("expected error for empty project path")
+	}
+}
+
+func TestDeleteByFile_FiltersOnProjectAndFile(t *testing.T) {
+	var filterBy string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filterBy = r.URL.Query().Get("filter_by")
+		_ = json.NewEncoder(w).Encode(map[string]int{"num_deleted": 2})
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	n, err := client.DeleteByFile(context.Background(), "/repo", "pkg/a.go")
+	if err != nil {
+		t.Fatalf("DeleteByFile failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 deleted, got %d", n)
+	}
+	if filterBy != "project_path:=`/repo` && rel_path:=`pkg/a.go`" {
+		t.Errorf("unexpected filter_by: %s", filterBy)
+	}
+}
+
+func TestDeleteFiles_Batches(t *testing.T) {
+	var mu sync.Mutex
+	var filters []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/collections/test-collection" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		filters = append(filters, r.URL.Query().Get("filter_by"))
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]int{"num_deleted": 1})
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+
+	paths := make([]string, deleteBatchSize+1)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("file%d.go", i)
+	}
+	n, err := client.DeleteFiles(context.Background(), "/repo", paths)
+	if err != nil {
+		t.Fatalf("DeleteFiles failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected counts summed across 2 requests, got %d", n)
+	}
+	if len(filters) != 2 {
+		t.Fatalf("expected 2 batched requests, got %d", len(filters))
+	}
+	// Batches are sent concurrently, so they may arrive in any order
+	last := "project_path:=`/repo` && rel_path:=[`file100.go`]"
+	if filters[0] != last && filters[1] != last {
+		t.Errorf("expected a batch with only the last file, got %v", filters)
+	}
+}
+
+func TestDeleteFilters(t *testing.T) {
+	// Long paths are split by the URL length of the filter, not the count
+	long := strings.Repeat("deep/", 200)
+	paths := []string{long + "a.go", long + "b.go", long + "c.go", long + "d.go", long + "e.go"}
+	filters := deleteFilters("file_path", paths)
+	if len(filters) < 2 {
+		t.Fatalf("expected long paths split over several filters, got %d", len(filters))
+	}
+	for _, f := range filters {
+		if n := len(url.QueryEscape(f)); n > maxDeleteFilterLength {
+			t.Errorf("expected filters of at most %d encoded bytes, got %d", maxDeleteFilterLength, n)
+		}
+	}
+
+	// A value longer than the limit goes alone rather than nowhere
+	huge := strings.Repeat("x", maxDeleteFilterLength)
+	if filters := deleteFilters("id", []string{"a", huge, "b"}); len(filters) != 3 {
+		t.Errorf("expected the huge value in a filter of its own, got %d filters", len(filters))
+	}
+
+	if got := deleteFilters("file_path", []string{"we`ird.go", "ok.go"}); len(got) != 1 || got[0] != "file_path:=[`we\\`ird.go`,`ok.go`]" {
+		t.Errorf("expected backticks escaped, got %v", got)
+	}
+}
+
+func TestDeleteByPaths(t *testing.T) {
+	var filter, batchSize string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, batchSize = r.URL.Query().Get("filter_by"), r.URL.Query().Get("batch_size")
+		_ = json.NewEncoder(w).Encode(map[string]int{"num_deleted": 3})
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	n, err := client.DeleteByPaths(context.Background(), []string{"a b.go", "c&d.go"})
+	if err != nil {
+		t.Fatalf("DeleteByPaths failed: %v", err)
+	}
+	if n != 3 || filter != "abs_path:=[`a b.go`,`c&d.go`]" {
+		t.Errorf("unexpected delete %q (%d deleted)", filter, n)
+	}
+	if batchSize != strconv.Itoa(deleteDocsPerPass) {
+		t.Errorf("expected batch_size %d, got %q", deleteDocsPerPass, batchSize)
+	}
+	if _, err := client.DeleteByPaths(context.Background(), []string{"a.go", ""}); err == nil {
+		t.Error("expected an empty path to be rejected")
+	}
+}
+
+func TestUpdateTags(t *testing.T) {
+	var method, filter string
+	var body map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, filter = r.Method, r.URL.Query().Get("filter_by")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"num_updated": 4}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	n, err := client.UpdateTags(context.Background(), "/repo", nil)
+	if err != nil {
+		t.Fatalf("UpdateTags failed: %v", err)
+	}
+	if n != 4 || method != "PATCH" || filter != "project_path:=`/repo`" {
+		t.Errorf("unexpected update %s %q (%d updated)", method, filter, n)
+	}
+	if tags, ok := body["tags"]; !ok || tags == nil || len(tags) != 0 {
+		t.Errorf("expected removing tags to send an empty list, got %v", body)
+	}
+}
+
+func TestExportProject(t *testing.T) {
+	var path, filter string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, filter = r.URL.Path, r.URL.Query().Get("filter_by")
+		w.Write([]byte(`{"id":"a","file_path":"main.go","project_path":"/repo","embedding":[0.5,1]}` + "\n" +
+			`{"id":"b","file_path":"lib.go","project_path":"/repo","embedding":[1,0.5]}` + "\n"))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	var docs []IndexedChunk
+	err := client.ExportProject(context.Background(), "/repo", func(c IndexedChunk) error {
+		docs = append(docs, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportProject failed: %v", err)
+	}
+	if path != "/collections/test-collection/documents/export" || filter != "project_path:=`/repo`" {
+		t.Errorf("unexpected export %s %q", path, filter)
+	}
+	if len(docs) != 2 || docs[1].FilePath != "lib.go" || len(docs[1].Embedding) != 2 {
+		t.Errorf("expected both documents with their embeddings, got %+v", docs)
+	}
+
+	stop := errors.New("stop")
+	if err := client.ExportProject(context.Background(), "/repo", func(IndexedChunk) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("expected the callback's error, got %v", err)
+	}
+}
+
+func TestDeleteChunks(t *testing.T) {
+	var filterBy string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filterBy = r.URL.Query().Get("filter_by")
+		_ = json.NewEncoder(w).Encode(map[string]int{"num_deleted": 2})
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	n, err := client.DeleteChunks(context.Background(), []string{"a1", "b2"})
+	if err != nil {
+		t.Fatalf("DeleteChunks failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 deleted, got %d", n)
+	}
+	if filterBy != "id:=[`a1`,`b2`]" {
+		t.Errorf("unexpected filter_by: %s", filterBy)
+	}
+}
+
+func TestProjectFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/collections/test-collection" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got := r.URL.Query().Get("facet_by"); got != "rel_path" {
+			t.Errorf("expected facet_by=rel_path, got %s", got)
+		}
+		w.Write([]byte(`{"found": 3, "facet_counts": [{"field_name": "rel_path", "counts": [{"count": 2, "value": "a.go"}, {"count": 1, "value": "b.md"}]}]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	files, err := client.ProjectFiles(context.Background(), "/repo")
+	if err != nil {
+		t.Fatalf("ProjectFiles failed: %v", err)
+	}
+	if files["a.go"] != 2 || files["b.md"] != 1 {
+		t.Errorf("unexpected file counts: %v", files)
+	}
+}
+
+func TestProjectFiles_ExportsWhenFacetsAreCapped(t *testing.T) {
+	defer func(n int) { maxProjectFacetValues = n }(maxProjectFacetValues)
+	maxProjectFacetValues = 2
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/collections/test-collection":
+			w.WriteHeader(http.StatusNotFound)
+		case "/collections/test-collection/documents/search":
+			w.Write([]byte(`{"found": 4, "facet_counts": [{"field_name": "rel_path", "counts": [{"count": 2, "value": "a.go"}, {"count": 1, "value": "b.md"}]}]}`))
+		case "/collections/test-collection/documents/export":
+			if got := r.URL.Query().Get("include_fields"); got != "rel_path" {
+				t.Errorf("expected include_fields=rel_path, got %s", got)
+			}
+			if got := r.URL.Query().Get("filter_by"); got != "project_path:=`/repo` && chunk_type:!=[commit,diff_hunk] && deleted:!=true" {
+				t.Errorf("unexpected filter_by: %s", got)
+			}
+			w.Write([]byte("{\"rel_path\":\"a.go\"}\n{\"rel_path\":\"a.go\"}\n{\"rel_path\":\"b.md\"}\n{\"rel_path\":\"c.txt\"}\n"))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	files, err := client.ProjectFiles(context.Background(), "/repo")
+	if err != nil {
+		t.Fatalf("ProjectFiles failed: %v", err)
+	}
+	want := map[string]int64{"a.go": 2, "b.md": 1, "c.txt": 1}
+	if len(files) != len(want) || files["a.go"] != 2 || files["c.txt"] != 1 {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
+
+func TestListCollections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/collections" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`[{"name": "swarm-index"}, {"name": "other"}]`))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	names, err := client.ListCollections(context.Background())
+	if err != nil {
+		t.Fatalf("ListCollections failed: %v", err)
+	}
+	if strings.Join(names, ",") != "swarm-index,other" {
+		t.Errorf("unexpected collections: %v", names)
+	}
+}
+
+func TestDropCollection(t *testing.T) {
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err := client.DropCollection(context.Background()); err != nil {
+		t.Fatalf("DropCollection should tolerate a missing collection: %v", err)
+	}
+	if method != "DELETE" || path != "/collections/test-collection" {
+		t.Errorf("unexpected request %s %s", method, path)
+	}
+}
+
+func TestCollectionStats_ReturnsDocumentCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/collections/test-collection" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":          "test-collection",
+				"num_documents": 42,
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, err := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	stats, err := client.CollectionStats(context.Background())
+	if err != nil {
+		t.Fatalf("CollectionStats failed: %v", err)
+	}
+	if stats.NumDocuments != 42 {
+		t.Errorf("expected 42 documents, got %d", stats.NumDocuments)
+	}
+	if stats.Name != "test-collection" {
+		t.Errorf("expected name 'test-collection', got %q", stats.Name)
+	}
+}
+
+func TestCollectionStats_MissingCollection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, err := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	if _, err := client.CollectionStats(context.Background()); err == nil {
+		t.Error("expected error for missing collection")
+	}
+}
+
+func TestCollectionStats_EmbeddingDim(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":          "test-collection",
+			"num_documents": 3,
+			"fields": []map[string]interface{}{
+				{"name": "content", "type": "string"},
+				{"name": "embedding", "type": "float[]", "num_dim": 768},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client, err := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	stats, err := client.CollectionStats(context.Background())
+	if err != nil {
+		t.Fatalf("CollectionStats failed: %v", err)
+	}
+	if stats.EmbeddingDim != 768 {
+		t.Errorf("expected embedding dim 768, got %d", stats.EmbeddingDim)
+	}
+}
+
+func TestProjectFacets_ParsesCounts(t *testing.T) {
+	var gotFilter, gotFacets string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/collections/test-collection/documents/search" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotFilter = r.URL.Query().Get("filter_by")
+		gotFacets = r.URL.Query().Get("facet_by")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"found": 5,
+			"facet_counts": []map[string]interface{}{
+				{"field_name": "language", "counts": []map[string]interface{}{
+					{"value": "go", "count": 4},
+					{"value": "yaml", "count": 1},
+				}},
+				{"field_name": "chunk_type", "counts": []map[string]interface{}{
+					{"value": "function", "count": 5},
+				}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client, err := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	facets, err := client.ProjectFacets(context.Background(), "/home/me/my project")
+	if err != nil {
+		t.Fatalf("ProjectFacets failed: %v", err)
+	}
+
+	if gotFilter != "project_path:=`/home/me/my project`" {
+		t.Errorf("unexpected filter_by: %q", gotFilter)
+	}
+	if gotFacets != "language,chunk_type" {
+		t.Errorf("unexpected facet_by: %q", gotFacets)
+	}
+	if facets.NumDocuments != 5 {
+		t.Errorf("expected 5 documents, got %d", facets.NumDocuments)
+	}
+	if facets.Languages["go"] != 4 || facets.Languages["yaml"] != 1 {
+		t.Errorf("unexpected language counts: %v", facets.Languages)
+	}
+	if facets.ChunkTypes["function"] != 5 {
+		t.Errorf("unexpected chunk type counts: %v", facets.ChunkTypes)
+	}
+}
+
+func TestPing_MissingCollectionIsHealthy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("expected no error for missing collection, got %v", err)
+	}
+}
+
+func TestPing_Unauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "bad-key", "test-collection")
+	err := client.Ping(context.Background())
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if !apiErr.IsAuthError() {
+		t.Error("expected 401 to be an auth error")
+	}
+}
+
+func TestProjectPaths_ReturnsCounts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("facet_by") != "project_path" {
+			t.Errorf("expected facet_by=project_path, got %q", r.URL.Query().Get("facet_by"))
+		}
+		if r.URL.Query().Has("filter_by") {
+			t.Error("expected no filter for project path facets")
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"found": 3,
+			"facet_counts": []map[string]interface{}{
+				{"field_name": "project_path", "counts": []map[string]interface{}{
+					{"value": "/a", "count": 2},
+					{"value": "/b", "count": 1},
+				}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	paths, err := client.ProjectPaths(context.Background())
+	if err != nil {
+		t.Fatalf("ProjectPaths failed: %v", err)
+	}
+	if paths["/a"] != 2 || paths["/b"] != 1 {
+		t.Errorf("unexpected project path counts: %v", paths)
+	}
+}
+
+func TestSynonyms(t *testing.T) {
+	var requests []string
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "PUT":
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Write([]byte(`{}`))
+		case "GET":
+			w.Write([]byte(`{"synonyms": [{"id": "k8s-kubernetes", "synonyms": ["k8s", "kubernetes"]}, {"id": "db", "root": "db", "synonyms": ["postgres"]}]}`))
+		case "DELETE":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	ctx := context.Background()
+	if err := client.UpsertSynonym(ctx, Synonym{ID: "db", Root: "db", Synonyms: []string{"postgres"}}); err != nil {
+		t.Fatalf("UpsertSynonym failed: %v", err)
+	}
+	if body["root"] != "db" || body["id"] != nil {
+		t.Errorf("unexpected synonym body: %v", body)
+	}
+
+	synonyms, err := client.ListSynonyms(ctx)
+	if err != nil {
+		t.Fatalf("ListSynonyms failed: %v", err)
+	}
+	if len(synonyms) != 2 || synonyms[0].ID != "k8s-kubernetes" || synonyms[1].Root != "db" {
+		t.Errorf("unexpected synonyms: %+v", synonyms)
+	}
+
+	err = client.DeleteSynonym(ctx, "missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsNotFound() {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	want := "PUT /collections/test-collection/synonyms/db,GET /collections/test-collection/synonyms,DELETE /collections/test-collection/synonyms/missing"
+	if got := strings.Join(requests, ","); got != want {
+		t.Errorf("unexpected requests %s", got)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	var requests []string
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "PUT":
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Write([]byte(`{}`))
+		case "GET":
+			w.Write([]byte(`{"overrides": [{"id": "deploy", "rule": {"query": "deploy", "match": "contains"},
+				"includes": [{"id": "b", "position": 2}, {"id": "a", "position": 1}], "excludes": [{"id": "c"}]}]}`))
+		case "DELETE":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	ctx := context.Background()
+	o := Override{ID: "deploy", Query: "deployment process", Pinned: []string{"a", "b"}, Hidden: []string{"c"}}
+	if err := client.UpsertOverride(ctx, o); err != nil {
+		t.Fatalf("UpsertOverride failed: %v", err)
+	}
+	rule, _ := body["rule"].(map[string]interface{})
+	includes, _ := body["includes"].([]interface{})
+	if rule["query"] != "deployment process" || rule["match"] != "exact" || len(includes) != 2 {
+		t.Errorf("unexpected override body: %v", body)
+	}
+	if second, _ := includes[1].(map[string]interface{}); second["id"] != "b" || second["position"] != float64(2) {
+		t.Errorf("expected pins positioned in order, got %v", includes)
+	}
+
+	overrides, err := client.ListOverrides(ctx)
+	if err != nil {
+		t.Fatalf("ListOverrides failed: %v", err)
+	}
+	if len(overrides) != 1 || !overrides[0].Contains || strings.Join(overrides[0].Pinned, ",") != "a,b" ||
+		strings.Join(overrides[0].Hidden, ",") != "c" {
+		t.Errorf("unexpected overrides: %+v", overrides)
+	}
+
+	err = client.DeleteOverride(ctx, "missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsNotFound() {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	want := "PUT /collections/test-collection/overrides/deploy,GET /collections/test-collection/overrides,DELETE /collections/test-collection/overrides/missing"
+	if got := strings.Join(requests, ","); got != want {
+		t.Errorf("unexpected requests %s", got)
+	}
+}
+
+func TestFileChunkIDs(t *testing.T) {
+	var filter string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filter_by")
+		w.Write([]byte(`{"id": "second", "start_line": 40}` + "\n" + `{"id": "first", "start_line": 1}` + "\n"))
+	}))
+	defer server.Close()
+
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	ids, err := client.FileChunkIDs(context.Background(), "/src/api", "docs/deploy.md")
+	if err != nil {
+		t.Fatalf("FileChunkIDs failed: %v", err)
+	}
+	if strings.Join(ids, ",") != "first,second" {
+		t.Errorf("expected the chunks in line order, got %v", ids)
+	}
+	if want := "project_path:=`/src/api` && rel_path:=`docs/deploy.md` && deleted:!=true"; filter != want {
+		t.Errorf("unexpected filter %q", filter)
+	}
+}
+
+func TestContentKey(t *testing.T) {
+	var stored []IndexedChunk
+	var queryBy string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/documents/import"):
+			dec := json.NewDecoder(r.Body)
+			for {
+				var doc IndexedChunk
+				if dec.Decode(&doc) != nil {
+					break
+				}
+				stored = append(stored, doc)
+			}
+		case r.URL.Path == "/multi_search":
+			var req struct {
+				Searches []map[string]interface{} `json:"searches"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			queryBy, _ = req.Searches[0]["query_by"].(string)
+			hits := []map[string]interface{}{}
+			for _, doc := range stored {
+				hits = append(hits, map[string]interface{}{"document": doc})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": []interface{}{map[string]interface{}{"hits": hits}}})
+		case strings.HasSuffix(r.URL.Path, "/documents/export"):
+			enc := json.NewEncoder(w)
+			for _, doc := range stored {
+				_ = enc.Encode(doc)
+			}
+		}
+	}))
+	defer server.Close()
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	client, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if err := client.SetContentKey(key[:16]); err == nil {
+		t.Error("expected a short key to be rejected")
+	}
+	if err := client.SetContentKey(key); err != nil {
+		t.Fatalf("SetContentKey failed: %v", err)
+	}
+	ctx := context.Background()
+	chunks := []IndexedChunk{{ID: "a", ProjectPath: "/p", Content: "func secret() {}", Summary: "Returns the secret"}}
+	if err := client.UpsertChunks(ctx, chunks); err != nil {
+		t.Fatalf("UpsertChunks failed: %v", err)
+	}
+	if chunks[0].Content != "func secret() {}" {
+		t.Error("expected the caller's chunks left in plain text")
+	}
+	if len(stored) != 1 || strings.Contains(stored[0].Content, "secret") || !strings.HasPrefix(stored[0].Content, encryptedPrefix) {
+		t.Fatalf("expected the stored content encrypted, got %+v", stored)
+	}
+	if strings.Contains(stored[0].Summary, "secret") || !strings.HasPrefix(stored[0].Summary, encryptedPrefix) {
+		t.Fatalf("expected the stored summary encrypted, got %q", stored[0].Summary)
+	}
+	stored = append(stored, IndexedChunk{ID: "b", ProjectPath: "/p", Content: "plain"})
+
+	results, err := client.Search(ctx, "secret", nil, 10)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Content != "func secret() {}" || results[0].Summary != "Returns the secret" || results[1].Content != "plain" {
+		t.Errorf("expected the results decrypted, got %+v", results)
+	}
+	if queryBy != "symbols,heading_path" {
+		t.Errorf("expected content left out of keyword matching, got %q", queryBy)
+	}
+	var exported []string
+	err = client.ExportProject(ctx, "/p", func(c IndexedChunk) error {
+		exported = append(exported, c.Content+c.Summary)
+		return nil
+	})
+	if err != nil || strings.Join(exported, ",") != "func secret() {}Returns the secret,plain" {
+		t.Errorf("expected the export decrypted, got %v (%v)", exported, err)
+	}
+
+	// Content sealed for one document can't be read as another's
+	stored[1].Content = stored[0].Content
+	if _, err := client.Search(ctx, "secret", nil, 10); err == nil {
+		t.Error("expected moved content to fail to decrypt")
+	}
+	other, _ := NewTypesenseClient(server.URL, "test-api-key", "test-collection")
+	if _, err := other.Search(ctx, "secret", nil, 10); err == nil || !strings.Contains(err.Error(), "no content key") {
+		t.Errorf("expected searching without the key to fail, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+// Package manifest reads the repository lists index --manifest indexes.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dvaida/swarm-indexer/internal/remote"
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// Repo is one repository of a manifest: a local directory or a git URL,
+// and the settings it is indexed with.
+type Repo struct {
+	Path string `yaml:"path,omitempty"`
+	URL  string `yaml:"url,omitempty"`
+	// Ref is the branch, tag or commit of URL to index; empty means its
+	// default branch
+	Ref string `yaml:"ref,omitempty"`
+	// Tags replace the project's tags when set, and an empty list removes
+	// them; nil keeps the tags it has
+	Tags []string `yaml:"tags"`
+	// Exclude lists globs of files left out, like skip_files
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// Target returns the path or URL the repository is indexed from.
+func (r Repo) Target() string {
+	if r.URL != "" {
+		return r.URL
+	}
+	return r.Path
+}
+
+// Excludes returns the repository's exclude globs as skip patterns.
+func (r Repo) Excludes() secrets.SkipPatterns {
+	var patterns secrets.SkipPatterns
+	for _, e := range r.Exclude {
+		p, _ := secrets.ParseSkipPatterns(e)
+		patterns = append(patterns, p...)
+	}
+	return patterns
+}
+
+// Manifest is a manifest file:
+//
+//	repos:
+//	  - path: ~/src/api
+//	    tags: [payments]
+//	    exclude: ["**/testdata/**", "*.pb.go"]
+//	  - url: https://github.com/acme/web.git
+//	    ref: main
+type Manifest struct {
+	Repos []Repo `yaml:"repos"`
+}
+
+// Load reads and validates the manifest at path. Relative paths and a
+// leading ~ in repository paths are resolved against the manifest's
+// directory and the home directory.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var m Manifest
+	if err := dec.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(m.Repos) == 0 {
+		return nil, fmt.Errorf("%s lists no repos", path)
+	}
+
+	base := filepath.Dir(path)
+	seen := map[string]bool{}
+	var errs []error
+	for i := range m.Repos {
+		r := &m.Repos[i]
+		if err := r.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("repo %d: %w", i+1, err))
+			continue
+		}
+		if r.Path != "" {
+			if r.Path, err = resolve(base, r.Path); err != nil {
+				errs = append(errs, fmt.Errorf("repo %d: %w", i+1, err))
+				continue
+			}
+		}
+		if seen[r.Target()] {
+			errs = append(errs, fmt.Errorf("repo %d: %s is listed twice", i+1, r.Target()))
+		}
+		seen[r.Target()] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func (r *Repo) validate() error {
+	switch {
+	case r.Path == "" && r.URL == "":
+		return errors.New("path or url is required")
+	case r.Path != "" && r.URL != "":
+		return errors.New("path and url can't both be set")
+	case r.URL != "" && !remote.IsURL(r.URL):
+		return fmt.Errorf("%q is not a repository URL", r.URL)
+	case r.Path != "" && remote.IsURL(r.Path):
+		return fmt.Errorf("%q is a URL; list it as url", r.Path)
+	case r.Ref != "" && r.URL == "":
+		return errors.New("ref needs a url")
+	}
+	for _, e := range r.Exclude {
+		if _, err := secrets.ParseSkipPatterns(e); err != nil {
+			return fmt.Errorf("exclude: %w", err)
+		}
+	}
+	return nil
+}
+
+// resolve makes path absolute, relative to base or, with a leading ~, to
+// the home directory
+func resolve(base, path string) (string, error) {
+	if path == "~" || len(path) > 1 && path[0] == '~' && os.IsPathSeparator(path[1]) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, path[1:]), nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,73 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "repos.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := writeManifest(t, `repos:
+  - path: api
+    tags: [payments, team-a]
+    exclude: ["**/testdata/**", "*.pb.go"]
+  - path: ~/src/web
+    tags: []
+  - url: https://github.com/acme/docs.git
+    ref: main
+`)
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(m.Repos) != 3 {
+		t.Fatalf("expected 3 repos, got %d", len(m.Repos))
+	}
+
+	api, web, docs := m.Repos[0], m.Repos[1], m.Repos[2]
+	if want := filepath.Join(filepath.Dir(path), "api"); api.Path != want {
+		t.Errorf("expected a relative path resolved against the manifest, got %q, want %q", api.Path, want)
+	}
+	if len(api.Tags) != 2 || len(api.Excludes()) != 2 {
+		t.Errorf("unexpected repo %+v", api)
+	}
+	if want := filepath.Join(home, "src", "web"); web.Path != want {
+		t.Errorf("expected ~ expanded, got %q, want %q", web.Path, want)
+	}
+	if web.Tags == nil || len(web.Tags) != 0 {
+		t.Errorf("expected an empty tag list to remove tags, got %#v", web.Tags)
+	}
+	if docs.Target() != "https://github.com/acme/docs.git" || docs.Ref != "main" || docs.Tags != nil {
+		t.Errorf("unexpected repo %+v", docs)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"empty":           "",
+		"no repos":        "repos: []\n",
+		"no target":       "repos:\n  - tags: [a]\n",
+		"path and url":    "repos:\n  - path: api\n    url: https://github.com/acme/api.git\n",
+		"url as path":     "repos:\n  - path: https://github.com/acme/api.git\n",
+		"not a url":       "repos:\n  - url: api\n",
+		"ref without url": "repos:\n  - path: api\n    ref: main\n",
+		"bad exclude":     "repos:\n  - path: api\n    exclude: [\"[\"]\n",
+		"listed twice":    "repos:\n  - path: api\n  - path: ./api\n",
+		"unknown field":   "repos:\n  - path: api\n    branch: main\n",
+	} {
+		if _, err := Load(writeManifest(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -6,32 +6,141 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/dvaida/swarm-indexer/internal/walker"
 )
 
-// MetadataFileName is the name of the metadata file in each indexed directory.
+// MetadataFileName is the name of the metadata file older versions wrote
+// into each indexed directory. It is read once when the state database has
+// no record of the directory, moved into it and removed; it is never
+// indexed.
 const MetadataFileName = ".swarm-indexer-metadata.json"
 
-// Metadata stores indexing state for a directory.
+// projectsDir is the directory under the data dir where versions before
+// the state database kept one metadata file per project.
+const projectsDir = "projects"
+
+// Metadata stores indexing state for a directory. It is kept in the state
+// database (see package state) under the data dir.
 type Metadata struct {
+	// Project is the absolute path of the indexed directory, set on save
+	Project      string            `json:"project"`
 	LastIndexed  int64             `json:"last_indexed"`
 	FileCount    int               `json:"file_count"`
-	ContentHash  string            `json:"content_hash"`
 	ProjectType  string            `json:"project_type"`
 	Languages    []string          `json:"languages"`
 	Dependencies map[string]string `json:"dependencies"`
+	// HistoryHead is the newest commit indexed by index --with-history
+	HistoryHead string `json:"history_head,omitempty"`
+	// Commit is the commit a remote repository's clone was indexed at, or
+	// the SHA-256 of an unpacked archive
+	Commit string `json:"commit,omitempty"`
+	// Tags are stored on every document of the project, set by index --tag
+	Tags []string `json:"tags,omitempty"`
+
+	// Files is the per-file state at the last index, keyed by path
+	// relative to the indexed directory. Only files whose state differs
+	// are re-indexed. Empty for metadata written before file-level state
+	// was recorded.
+	Files map[string]FileState `json:"files,omitempty"`
+}
+
+// FileState records what a file looked like when it was last indexed.
+type FileState = state.File
+
+// Changes lists files that differ between two file states.
+type Changes struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+}
+
+// Empty reports whether no files changed.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Modified) == 0 && len(c.Deleted) == 0
+}
+
+// jsonPath returns where versions before the state database kept the
+// metadata of the directory at dirPath:
+// $DATA_DIR/projects/<sha256 of the absolute path>.json.
+func jsonPath(dataDir, abs string) string {
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dataDir, projectsDir, hex.EncodeToString(sum[:])+".json")
 }
 
-// Load reads metadata from the given directory.
-// Returns empty metadata if file doesn't exist.
-// Returns error if file exists but is corrupt.
+// openState opens the state database in the data dir (see config.DataDir)
+// and resolves dirPath to the absolute path projects are keyed by. URLs,
+// such as those of crawled sites, are used as they are.
+func openState(dirPath string) (db *state.DB, abs, dataDir string, err error) {
+	if strings.Contains(dirPath, "://") {
+		abs = dirPath
+	} else if abs, err = walker.Abs(dirPath); err != nil {
+		return nil, "", "", err
+	}
+	if dataDir, err = config.DataDir(); err != nil {
+		return nil, "", "", fmt.Errorf("resolving data dir: %w", err)
+	}
+	db, err = state.Open(dataDir)
+	return db, abs, dataDir, err
+}
+
+// Load reads metadata for the given directory. Metadata files written by
+// older versions are upgraded into the state database on the first load.
+// Returns empty metadata if there is none, or if it is corrupt, in which
+// case the directory is indexed from scratch and a warning is logged.
 func Load(dirPath string) (*Metadata, error) {
-	metaPath := filepath.Join(dirPath, MetadataFileName)
+	db, abs, dataDir, err := openState(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	p, err := db.Project(abs)
+	if err == nil && p == nil {
+		return upgradeJSON(db, dataDir, abs)
+	}
+	var files map[string]FileState
+	if err == nil {
+		files, err = db.Files(abs)
+	}
+	if errors.Is(err, state.ErrCorrupt) {
+		slog.Warn("ignoring corrupt metadata, indexing from scratch; run `swarm-indexer state repair` to clean up", "path", abs, "error", err)
+		return &Metadata{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Metadata{
+		Project:      p.Path,
+		LastIndexed:  p.LastIndexed,
+		FileCount:    p.FileCount,
+		ProjectType:  p.ProjectType,
+		Languages:    p.Languages,
+		Dependencies: p.Dependencies,
+		HistoryHead:  p.HistoryHead,
+		Commit:       p.Commit,
+		Tags:         p.Tags,
+		Files:        files,
+	}, nil
+}
 
-	data, err := os.ReadFile(metaPath)
+// upgradeJSON reads metadata from the files older versions wrote, in the
+// data dir or else in the directory itself, and moves it into the state
+// database
+func upgradeJSON(db *state.DB, dataDir, abs string) (*Metadata, error) {
+	data, err := os.ReadFile(jsonPath(dataDir, abs))
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = os.ReadFile(filepath.Join(abs, MetadataFileName))
+	}
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return &Metadata{}, nil
@@ -41,90 +150,198 @@ func Load(dirPath string) (*Metadata, error) {
 
 	var m Metadata
 	if err := json.Unmarshal(data, &m); err != nil {
-		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+		slog.Warn("ignoring corrupt metadata file, indexing from scratch", "path", abs, "error", err)
+		return &Metadata{}, nil
+	}
+	if err := m.save(db, dataDir, abs); err != nil {
+		return nil, fmt.Errorf("upgrading metadata: %w", err)
 	}
-
 	return &m, nil
 }
 
-// Save writes metadata to the given directory atomically.
-func (m *Metadata) Save(dirPath string) error {
-	metaPath := filepath.Join(dirPath, MetadataFileName)
+// Exists reports whether metadata has been saved for the given directory.
+// To check many directories, use an Index.
+func Exists(dirPath string) bool {
+	ix, err := OpenIndex()
+	if err != nil {
+		return false
+	}
+	return ix.Exists(dirPath)
+}
+
+// Index tells which directories have metadata saved, reading the state
+// database once: it holds the projects recorded when it was opened.
+type Index struct {
+	dataDir  string
+	projects map[string]bool
+}
+
+// OpenIndex reads the projects recorded in the state database.
+func OpenIndex() (*Index, error) {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return nil, fmt.Errorf("resolving data dir: %w", err)
+	}
+	db, err := state.Open(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
 
-	data, err := json.MarshalIndent(m, "", "  ")
+	paths, err := db.Projects()
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, fmt.Errorf("listing projects: %w", err)
 	}
+	ix := &Index{dataDir: dataDir, projects: make(map[string]bool, len(paths))}
+	for _, p := range paths {
+		ix.projects[p] = true
+	}
+	return ix, nil
+}
+
+// Exists reports whether metadata had been saved for the given directory
+// when the index was opened, in the state database or in a file left by
+// an older version.
+func (ix *Index) Exists(dirPath string) bool {
+	abs := dirPath
+	if !strings.Contains(dirPath, "://") {
+		var err error
+		if abs, err = walker.Abs(dirPath); err != nil {
+			return false
+		}
+	}
+	if ix.projects[abs] {
+		return true
+	}
+	for _, path := range []string{jsonPath(ix.dataDir, abs), filepath.Join(abs, MetadataFileName)} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
 
-	// Write to temp file first for atomic save
-	tmpPath := metaPath + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temp metadata file: %w", err)
+// Save writes metadata for the given directory in a single transaction,
+// removing any metadata files older versions wrote.
+func (m *Metadata) Save(dirPath string) error {
+	db, abs, dataDir, err := openState(dirPath)
+	if err != nil {
+		return err
 	}
+	defer db.Close()
+	return m.save(db, dataDir, abs)
+}
 
-	// Rename for atomic update
-	if err := os.Rename(tmpPath, metaPath); err != nil {
-		os.Remove(tmpPath) // Clean up temp file on failure
-		return fmt.Errorf("failed to rename metadata file: %w", err)
+func (m *Metadata) save(db *state.DB, dataDir, abs string) error {
+	m.Project = abs
+	err := db.SaveProject(&state.Project{
+		Path:         abs,
+		LastIndexed:  m.LastIndexed,
+		FileCount:    m.FileCount,
+		ProjectType:  m.ProjectType,
+		Languages:    m.Languages,
+		Dependencies: m.Dependencies,
+		HistoryHead:  m.HistoryHead,
+		Commit:       m.Commit,
+		Tags:         m.Tags,
+	}, m.Files)
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
 	}
 
-	return nil
+	return removeJSON(dataDir, abs)
 }
 
-// ComputeHash computes a hash of file paths and mtimes in the directory.
-// This is used for change detection.
-func ComputeHash(dirPath string) (string, error) {
-	var entries []string
+// Remove deletes the metadata of the given directory.
+// It is not an error if there is none.
+func Remove(dirPath string) error {
+	db, abs, dataDir, err := openState(dirPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	if err := db.DeleteProject(abs); err != nil {
+		return fmt.Errorf("failed to remove metadata: %w", err)
+	}
+	return removeJSON(dataDir, abs)
+}
 
-		// Skip directories
-		if d.IsDir() {
-			return nil
+func removeJSON(dataDir, abs string) error {
+	for _, path := range []string{jsonPath(dataDir, abs), filepath.Join(abs, MetadataFileName)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove metadata file: %w", err)
 		}
+	}
+	return nil
+}
 
-		// Skip the metadata file itself
-		if d.Name() == MetadataFileName {
-			return nil
-		}
+// ScanFiles returns the current state of every file the walker yields in
+// the directory, keyed by path relative to dirPath: gitignored files and
+// hidden directories, such as .git, are left out, as they are when
+// indexing.
+func ScanFiles(dirPath string) (map[string]FileState, error) {
+	root, err := walker.Abs(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+	ch, err := walker.Walk(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
 
-		// Get relative path for consistent hashing
-		relPath, err := filepath.Rel(dirPath, path)
-		if err != nil {
-			return err
+	files := make(map[string]FileState)
+	for fi := range ch {
+		// Skip a metadata file left by older versions
+		if filepath.Base(fi.Path) == MetadataFileName {
+			continue
 		}
-
-		info, err := d.Info()
+		relPath, err := filepath.Rel(root, fi.Path)
 		if err != nil {
-			return err
+			continue
 		}
+		files[relPath] = FileState{ModTime: fi.ModTime.UnixNano(), Size: fi.Size}
+	}
+	return files, nil
+}
 
-		// Include path and mtime in hash input
-		entry := fmt.Sprintf("%s:%d", relPath, info.ModTime().UnixNano())
-		entries = append(entries, entry)
-
-		return nil
-	})
+// HashFile returns the hex SHA-256 of a file's contents.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to walk directory: %w", err)
+		return "", err
 	}
+	defer f.Close()
 
-	// Sort for consistent ordering
-	sort.Strings(entries)
-
-	// Compute hash
 	h := sha256.New()
-	for _, entry := range entries {
-		h.Write([]byte(entry))
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
 	}
-
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// HasChanged returns true if the current hash differs from the stored hash.
-func (m *Metadata) HasChanged(currentHash string) bool {
-	return m.ContentHash != currentHash
+// DiffFiles compares the stored file state against the current one. Files
+// are compared by mtime and size only; callers that recorded hashes can
+// rule out modified files whose contents are the same.
+func DiffFiles(stored, current map[string]FileState) Changes {
+	var changes Changes
+	for path, cur := range current {
+		old, ok := stored[path]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, path)
+		case old.ModTime != cur.ModTime || old.Size != cur.Size:
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	for path := range stored {
+		if _, ok := current[path]; !ok {
+			changes.Deleted = append(changes.Deleted, path)
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Deleted)
+	return changes
 }
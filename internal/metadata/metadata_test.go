@@ -4,10 +4,21 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/dvaida/swarm-indexer/internal/testenv"
+	bolt "go.etcd.io/bbolt"
 )
 
+// TestMain keeps metadata written by the tests out of the user's data dir.
+func TestMain(m *testing.M) {
+	testenv.Main(m)
+}
+
 func TestLoad_NonExistentFile(t *testing.T) {
 	// Create a temp directory without metadata file
 	tmpDir := t.TempDir()
@@ -25,8 +36,8 @@ func TestLoad_NonExistentFile(t *testing.T) {
 	if m.FileCount != 0 {
 		t.Errorf("expected FileCount=0, got %d", m.FileCount)
 	}
-	if m.ContentHash != "" {
-		t.Errorf("expected ContentHash='', got %q", m.ContentHash)
+	if m.Files != nil {
+		t.Errorf("expected Files=nil, got %v", m.Files)
 	}
 	if m.ProjectType != "" {
 		t.Errorf("expected ProjectType='', got %q", m.ProjectType)
@@ -41,12 +52,15 @@ func TestLoad_NonExistentFile(t *testing.T) {
 
 func TestLoad_ValidFile(t *testing.T) {
 	tmpDir := t.TempDir()
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
 
-	// Create a valid metadata file
+	// Create a valid metadata file in the data dir, as the version before
+	// the state database wrote it
 	meta := Metadata{
 		LastIndexed:  1700000000,
 		FileCount:    42,
-		ContentHash:  "abc123",
+		Files:        map[string]FileState{"main.go": {ModTime: 1, Size: 2, Hash: "abc123"}},
 		ProjectType:  "go",
 		Languages:    []string{"go", "markdown"},
 		Dependencies: map[string]string{"cobra": "v1.8.0"},
@@ -57,7 +71,10 @@ func TestLoad_ValidFile(t *testing.T) {
 		t.Fatalf("failed to marshal test metadata: %v", err)
 	}
 
-	metaPath := filepath.Join(tmpDir, ".swarm-indexer-metadata.json")
+	metaPath := jsonPath(dataDir, tmpDir)
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		t.Fatal(err)
+	}
 	if err := os.WriteFile(metaPath, data, 0644); err != nil {
 		t.Fatalf("failed to write test metadata file: %v", err)
 	}
@@ -74,8 +91,8 @@ func TestLoad_ValidFile(t *testing.T) {
 	if loaded.FileCount != meta.FileCount {
 		t.Errorf("FileCount: expected %d, got %d", meta.FileCount, loaded.FileCount)
 	}
-	if loaded.ContentHash != meta.ContentHash {
-		t.Errorf("ContentHash: expected %q, got %q", meta.ContentHash, loaded.ContentHash)
+	if !reflect.DeepEqual(loaded.Files["main.go"], meta.Files["main.go"]) {
+		t.Errorf("Files: expected %v, got %v", meta.Files, loaded.Files)
 	}
 	if loaded.ProjectType != meta.ProjectType {
 		t.Errorf("ProjectType: expected %q, got %q", meta.ProjectType, loaded.ProjectType)
@@ -92,15 +109,51 @@ func TestLoad_CorruptJSON(t *testing.T) {
 	tmpDir := t.TempDir()
 
 	// Create a corrupt JSON file
-	metaPath := filepath.Join(tmpDir, ".swarm-indexer-metadata.json")
+	metaPath := filepath.Join(tmpDir, MetadataFileName)
 	if err := os.WriteFile(metaPath, []byte("{invalid json"), 0644); err != nil {
 		t.Fatalf("failed to write corrupt metadata file: %v", err)
 	}
 
-	// Load should return an error
-	_, err := Load(tmpDir)
-	if err == nil {
-		t.Fatal("Load() should return error for corrupt JSON")
+	// Load should ignore it so the directory is indexed from scratch
+	m, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Load() returned error for corrupt JSON: %v", err)
+	}
+	if m.LastIndexed != 0 || m.Files != nil {
+		t.Errorf("expected empty metadata, got %+v", m)
+	}
+}
+
+func TestLoad_CorruptRecord(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
+	tmpDir := t.TempDir()
+
+	if err := (&Metadata{LastIndexed: 1700000000, Files: map[string]FileState{"a.go": {Size: 1}}}).Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	// Overwrite the project record with something that isn't JSON
+	db, err := bolt.Open(filepath.Join(dataDir, state.FileName), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("projects")).Put([]byte(tmpDir), []byte("{"))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Load() returned error for a corrupt record: %v", err)
+	}
+	if m.LastIndexed != 0 || m.Files != nil {
+		t.Errorf("expected empty metadata, got %+v", m)
+	}
+	if !Exists(tmpDir) {
+		t.Error("expected a corrupt record to count as existing")
 	}
 }
 
@@ -110,7 +163,6 @@ func TestSave_CreatesFile(t *testing.T) {
 	meta := &Metadata{
 		LastIndexed:  1700000000,
 		FileCount:    10,
-		ContentHash:  "hash123",
 		ProjectType:  "python",
 		Languages:    []string{"python"},
 		Dependencies: map[string]string{"requests": "2.31.0"},
@@ -120,16 +172,15 @@ func TestSave_CreatesFile(t *testing.T) {
 		t.Fatalf("Save() returned error: %v", err)
 	}
 
-	// Verify file exists and has correct content
-	metaPath := filepath.Join(tmpDir, ".swarm-indexer-metadata.json")
-	data, err := os.ReadFile(metaPath)
+	// Verify the record exists and has correct content
+	db, err := state.Open(os.Getenv("SWARM_INDEXER_DATA_DIR"))
 	if err != nil {
-		t.Fatalf("failed to read saved metadata file: %v", err)
+		t.Fatal(err)
 	}
-
-	var loaded Metadata
-	if err := json.Unmarshal(data, &loaded); err != nil {
-		t.Fatalf("failed to parse saved metadata: %v", err)
+	defer db.Close()
+	loaded, err := db.Project(tmpDir)
+	if err != nil || loaded == nil {
+		t.Fatalf("expected a project record, got %v, %v", loaded, err)
 	}
 
 	if loaded.LastIndexed != meta.LastIndexed {
@@ -138,8 +189,16 @@ func TestSave_CreatesFile(t *testing.T) {
 	if loaded.FileCount != meta.FileCount {
 		t.Errorf("FileCount: expected %d, got %d", meta.FileCount, loaded.FileCount)
 	}
-	if loaded.ContentHash != meta.ContentHash {
-		t.Errorf("ContentHash: expected %q, got %q", meta.ContentHash, loaded.ContentHash)
+	if loaded.ProjectType != meta.ProjectType {
+		t.Errorf("ProjectType: expected %q, got %q", meta.ProjectType, loaded.ProjectType)
+	}
+	if loaded.Path != tmpDir {
+		t.Errorf("Path: expected %q, got %q", tmpDir, loaded.Path)
+	}
+
+	// Nothing is written into the indexed directory
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Errorf("expected the indexed directory to be left alone, got %v", entries)
 	}
 }
 
@@ -150,7 +209,7 @@ func TestSave_OverwritesExisting(t *testing.T) {
 	meta1 := &Metadata{
 		LastIndexed: 1000000000,
 		FileCount:   5,
-		ContentHash: "old_hash",
+		ProjectType: "go",
 	}
 	if err := meta1.Save(tmpDir); err != nil {
 		t.Fatalf("first Save() returned error: %v", err)
@@ -160,7 +219,7 @@ func TestSave_OverwritesExisting(t *testing.T) {
 	meta2 := &Metadata{
 		LastIndexed: 2000000000,
 		FileCount:   15,
-		ContentHash: "new_hash",
+		ProjectType: "rust",
 	}
 	if err := meta2.Save(tmpDir); err != nil {
 		t.Fatalf("second Save() returned error: %v", err)
@@ -178,18 +237,19 @@ func TestSave_OverwritesExisting(t *testing.T) {
 	if loaded.FileCount != meta2.FileCount {
 		t.Errorf("FileCount: expected %d, got %d", meta2.FileCount, loaded.FileCount)
 	}
-	if loaded.ContentHash != meta2.ContentHash {
-		t.Errorf("ContentHash: expected %q, got %q", meta2.ContentHash, loaded.ContentHash)
+	if loaded.ProjectType != meta2.ProjectType {
+		t.Errorf("ProjectType: expected %q, got %q", meta2.ProjectType, loaded.ProjectType)
 	}
 }
 
 func TestSave_AtomicWrite(t *testing.T) {
 	tmpDir := t.TempDir()
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
 
 	meta := &Metadata{
 		LastIndexed: 1700000000,
 		FileCount:   10,
-		ContentHash: "hash123",
 	}
 
 	// Save the metadata
@@ -198,167 +258,263 @@ func TestSave_AtomicWrite(t *testing.T) {
 	}
 
 	// Verify no temp files are left behind
-	entries, err := os.ReadDir(tmpDir)
+	entries, err := os.ReadDir(dataDir)
 	if err != nil {
 		t.Fatalf("failed to read directory: %v", err)
 	}
 
 	for _, entry := range entries {
-		if entry.Name() != ".swarm-indexer-metadata.json" {
+		if entry.Name() != state.FileName {
 			t.Errorf("unexpected file left behind: %s", entry.Name())
 		}
 	}
 
-	// Verify exactly one file exists (the metadata file)
+	// Verify exactly one file exists (the state database)
 	if len(entries) != 1 {
 		t.Errorf("expected 1 file, got %d", len(entries))
 	}
 }
 
-func TestComputeHash_EmptyDir(t *testing.T) {
+func TestRemove(t *testing.T) {
 	tmpDir := t.TempDir()
 
-	hash1, err := ComputeHash(tmpDir)
-	if err != nil {
-		t.Fatalf("ComputeHash() returned error: %v", err)
+	m := &Metadata{FileCount: 1}
+	if err := m.Save(tmpDir); err != nil {
+		t.Fatalf("Save() failed: %v", err)
 	}
 
-	// Hash should be non-empty
-	if hash1 == "" {
-		t.Error("ComputeHash() returned empty hash for empty directory")
+	if !Exists(tmpDir) {
+		t.Fatal("expected metadata to exist after Save()")
 	}
-
-	// Hash should be consistent
-	hash2, err := ComputeHash(tmpDir)
-	if err != nil {
-		t.Fatalf("ComputeHash() returned error on second call: %v", err)
+	if err := Remove(tmpDir); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+	if Exists(tmpDir) {
+		t.Error("metadata file should be removed")
 	}
 
-	if hash1 != hash2 {
-		t.Errorf("ComputeHash() not consistent: %q != %q", hash1, hash2)
+	// Removing again is not an error
+	if err := Remove(tmpDir); err != nil {
+		t.Errorf("Remove() on missing file returned error: %v", err)
 	}
 }
 
-func TestComputeHash_WithFiles(t *testing.T) {
+func TestLoad_LegacyFile(t *testing.T) {
 	tmpDir := t.TempDir()
+	legacy := filepath.Join(tmpDir, MetadataFileName)
+	if err := os.WriteFile(legacy, []byte(`{"last_indexed": 1700000000, "file_count": 3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
 
-	// Create some files
-	if err := os.WriteFile(filepath.Join(tmpDir, "file1.txt"), []byte("content1"), 0644); err != nil {
-		t.Fatalf("failed to create file1: %v", err)
+	m, err := Load(tmpDir)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if m.LastIndexed != 1700000000 || m.FileCount != 3 {
+		t.Errorf("expected the legacy metadata to be read, got %+v", m)
 	}
-	if err := os.WriteFile(filepath.Join(tmpDir, "file2.txt"), []byte("content2"), 0644); err != nil {
-		t.Fatalf("failed to create file2: %v", err)
+	if !Exists(tmpDir) {
+		t.Error("expected legacy metadata to count as existing")
 	}
 
-	hash1, err := ComputeHash(tmpDir)
-	if err != nil {
-		t.Fatalf("ComputeHash() returned error: %v", err)
+	// Loading upgrades it into the state database
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("expected the legacy metadata file to be removed")
+	}
+	if m, err := Load(tmpDir); err != nil || m.FileCount != 3 {
+		t.Errorf("expected migrated metadata, got %+v, %v", m, err)
+	}
+}
+
+func TestLoad_KeyedByAbsolutePath(t *testing.T) {
+	if err := (&Metadata{FileCount: 1}).Save("/work/a"); err != nil {
+		t.Fatal(err)
+	}
+	if !Exists("/work/a/../a/") {
+		t.Error("expected equivalent paths to share metadata")
 	}
+	if Exists("/work/b") {
+		t.Error("expected different projects to have separate metadata")
+	}
+}
 
-	if hash1 == "" {
-		t.Error("ComputeHash() returned empty hash")
+func TestIndex_Exists(t *testing.T) {
+	if err := (&Metadata{FileCount: 1}).Save("/work/indexed"); err != nil {
+		t.Fatal(err)
+	}
+	legacy := t.TempDir()
+	if err := os.WriteFile(filepath.Join(legacy, MetadataFileName), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
 	}
 
-	// Hash should be consistent for same files
-	hash2, err := ComputeHash(tmpDir)
+	ix, err := OpenIndex()
 	if err != nil {
-		t.Fatalf("ComputeHash() returned error on second call: %v", err)
+		t.Fatalf("OpenIndex failed: %v", err)
 	}
-
-	if hash1 != hash2 {
-		t.Errorf("ComputeHash() not consistent: %q != %q", hash1, hash2)
+	if !ix.Exists("/work/indexed/") || !ix.Exists(legacy) || ix.Exists("/work/other") {
+		t.Error("expected the recorded project and the legacy metadata file to be found, and nothing else")
+	}
+	// Projects saved later aren't seen
+	if err := (&Metadata{FileCount: 1}).Save("/work/other"); err != nil {
+		t.Fatal(err)
+	}
+	if ix.Exists("/work/other") {
+		t.Error("expected the index to hold the projects recorded when opened")
 	}
 }
 
-func TestComputeHash_DetectsChanges(t *testing.T) {
+func TestHashFile(t *testing.T) {
 	tmpDir := t.TempDir()
-
-	// Create a file
-	filePath := filepath.Join(tmpDir, "file.txt")
-	if err := os.WriteFile(filePath, []byte("content"), 0644); err != nil {
-		t.Fatalf("failed to create file: %v", err)
+	path := filepath.Join(tmpDir, "file.txt")
+	if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
 	}
 
-	hash1, err := ComputeHash(tmpDir)
+	hash1, err := HashFile(path)
 	if err != nil {
-		t.Fatalf("ComputeHash() returned error: %v", err)
+		t.Fatalf("HashFile() returned error: %v", err)
+	}
+	// sha256("content")
+	if want := "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73"; hash1 != want {
+		t.Errorf("expected %s, got %s", want, hash1)
 	}
 
-	// Wait to ensure mtime will be different
-	time.Sleep(10 * time.Millisecond)
-
-	// Modify the file (changing mtime)
+	// Touching the file doesn't change the hash
 	newTime := time.Now().Add(time.Hour)
-	if err := os.Chtimes(filePath, newTime, newTime); err != nil {
-		t.Fatalf("failed to change file mtime: %v", err)
+	if err := os.Chtimes(path, newTime, newTime); err != nil {
+		t.Fatal(err)
+	}
+	hash2, err := HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash1 != hash2 {
+		t.Error("HashFile() should depend on contents only")
 	}
 
-	hash2, err := ComputeHash(tmpDir)
+	if err := os.WriteFile(path, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash3, err := HashFile(path)
 	if err != nil {
-		t.Fatalf("ComputeHash() returned error after modification: %v", err)
+		t.Fatal(err)
+	}
+	if hash1 == hash3 {
+		t.Error("HashFile() should change with the contents")
 	}
 
-	if hash1 == hash2 {
-		t.Error("ComputeHash() should return different hash after file modification")
+	if _, err := HashFile(filepath.Join(tmpDir, "missing")); err == nil {
+		t.Error("expected an error for a missing file")
 	}
 }
 
-func TestComputeHash_IgnoresMetadataFile(t *testing.T) {
+func TestScanFiles_RecordsState(t *testing.T) {
 	tmpDir := t.TempDir()
-
-	// Create a regular file
-	if err := os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("content"), 0644); err != nil {
-		t.Fatalf("failed to create file: %v", err)
+	if err := os.MkdirAll(filepath.Join(tmpDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
 	}
-
-	hash1, err := ComputeHash(tmpDir)
-	if err != nil {
-		t.Fatalf("ComputeHash() returned error: %v", err)
+	if err := os.WriteFile(filepath.Join(tmpDir, "sub", "a.go"), []byte("package a"), 0644); err != nil {
+		t.Fatal(err)
 	}
-
-	// Create metadata file - this should not change the hash
-	meta := &Metadata{LastIndexed: 123}
-	if err := meta.Save(tmpDir); err != nil {
-		t.Fatalf("Save() returned error: %v", err)
+	if err := os.WriteFile(filepath.Join(tmpDir, MetadataFileName), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
 	}
 
-	hash2, err := ComputeHash(tmpDir)
+	files, err := ScanFiles(tmpDir)
 	if err != nil {
-		t.Fatalf("ComputeHash() returned error after adding metadata: %v", err)
+		t.Fatalf("ScanFiles failed: %v", err)
 	}
 
-	if hash1 != hash2 {
-		t.Error("ComputeHash() should ignore .swarm-indexer-metadata.json")
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file (metadata excluded), got %v", files)
 	}
-}
-
-func TestHasChanged_True(t *testing.T) {
-	meta := &Metadata{
-		ContentHash: "old_hash",
+	state, ok := files[filepath.Join("sub", "a.go")]
+	if !ok {
+		t.Fatalf("expected relative path sub/a.go, got %v", files)
 	}
-
-	if !meta.HasChanged("new_hash") {
-		t.Error("HasChanged() should return true when hashes differ")
+	if state.Size != int64(len("package a")) {
+		t.Errorf("expected size %d, got %d", len("package a"), state.Size)
+	}
+	if state.ModTime == 0 {
+		t.Error("expected mtime to be recorded")
 	}
 }
 
-func TestHasChanged_False(t *testing.T) {
-	meta := &Metadata{
-		ContentHash: "same_hash",
+func TestScanFiles_WalkerRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":         "build/\n*.log\n",
+		"main.go":            "package main",
+		"debug.log":          "log",
+		"build/out.go":       "package build",
+		".git/HEAD":          "ref: refs/heads/main",
+		".cache/tmp.go":      "package cache",
+		"sub/.gitignore":     "gen.go\n",
+		"sub/gen.go":         "package sub",
+		"sub/handwritten.go": "package sub",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	if meta.HasChanged("same_hash") {
-		t.Error("HasChanged() should return false when hashes match")
+	files, err := ScanFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	var got []string
+	for rel := range files {
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	want := []string{".gitignore", "main.go", "sub/.gitignore", "sub/handwritten.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected gitignored files and hidden directories left out, got %v", got)
 	}
 }
 
-func TestHasChanged_EmptyStoredHash(t *testing.T) {
-	meta := &Metadata{
-		ContentHash: "",
+func TestDiffFiles(t *testing.T) {
+	stored := map[string]FileState{
+		"same.go":    {ModTime: 1, Size: 10},
+		"changed.go": {ModTime: 1, Size: 10},
+		"resized.go": {ModTime: 1, Size: 10},
+		"gone.go":    {ModTime: 1, Size: 10},
 	}
+	current := map[string]FileState{
+		"same.go":    {ModTime: 1, Size: 10},
+		"changed.go": {ModTime: 2, Size: 10},
+		"resized.go": {ModTime: 1, Size: 11},
+		"new.go":     {ModTime: 1, Size: 10},
+	}
+
+	changes := DiffFiles(stored, current)
 
-	// Empty stored hash means first index, should indicate change needed
-	if !meta.HasChanged("any_hash") {
-		t.Error("HasChanged() should return true when stored hash is empty")
+	if len(changes.Added) != 1 || changes.Added[0] != "new.go" {
+		t.Errorf("expected added [new.go], got %v", changes.Added)
+	}
+	if len(changes.Modified) != 2 || changes.Modified[0] != "changed.go" || changes.Modified[1] != "resized.go" {
+		t.Errorf("expected modified [changed.go resized.go], got %v", changes.Modified)
+	}
+	if len(changes.Deleted) != 1 || changes.Deleted[0] != "gone.go" {
+		t.Errorf("expected deleted [gone.go], got %v", changes.Deleted)
+	}
+	if changes.Empty() {
+		t.Error("expected changes not to be empty")
+	}
+	// Hashes aren't compared
+	hashed := map[string]FileState{}
+	for path, state := range stored {
+		state.Hash = "abc"
+		hashed[path] = state
+	}
+	if !DiffFiles(hashed, stored).Empty() {
+		t.Error("expected stored hashes to be ignored")
+	}
+	if !DiffFiles(stored, stored).Empty() {
+		t.Error("expected no changes when comparing state to itself")
 	}
 }
@@ -0,0 +1,114 @@
+// Package notify posts the outcome of index runs to a webhook, as a Slack
+// message or as generic JSON, so failing runs get noticed.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/state"
+)
+
+// Run outcomes
+const (
+	StatusSucceeded = "succeeded"
+	StatusPartial   = "partial" // finished, but some files failed
+	StatusFailed    = "failed"
+	StatusAborted   = "aborted"
+)
+
+// Event is a finished run as posted to a generic webhook: the run's
+// summary as recorded in the state database, plus its outcome.
+type Event struct {
+	Command string `json:"command"` // e.g. "swarm-indexer index"
+	Status  string `json:"status"`
+	Host    string `json:"host"`
+	Error   string `json:"error,omitempty"` // why the run failed, if it did
+	state.Run
+}
+
+// Status returns the outcome of run, which ended with err.
+func Status(run *state.Run, err error) string {
+	switch {
+	case run.Aborted:
+		return StatusAborted
+	case err != nil:
+		return StatusFailed
+	case run.Failed > 0:
+		return StatusPartial
+	default:
+		return StatusSucceeded
+	}
+}
+
+// Failure reports whether status is an outcome worth alerting on even
+// when only failures are notified.
+func Failure(status string) bool {
+	return status != StatusSucceeded
+}
+
+// Send posts e to the webhook at url. Slack incoming webhooks
+// (hooks.slack.com) get a message; other URLs get e as JSON.
+func Send(ctx context.Context, client *http.Client, url string, e Event) error {
+	var body any = e
+	if isSlack(url) {
+		body = map[string]string{"text": e.Text()}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL may hold a token, as Slack's do, so it stays out of errors
+		var uerr *neturl.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("posting to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("posting to webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// Text summarizes e in a line or two for chat, e.g.
+// "swarm-indexer index failed on build-01 after 1m2s: ...".
+func (e Event) Text() string {
+	var sb strings.Builder
+	duration := time.Duration(e.End-e.Start) * time.Second
+	fmt.Fprintf(&sb, "%s %s on %s after %s", e.Command, e.Status, e.Host, duration)
+	if e.Error != "" {
+		fmt.Fprintf(&sb, ": %s", e.Error)
+	}
+	fmt.Fprintf(&sb, "\n%d files, %d chunks indexed", e.Files, e.Chunks)
+	if e.Failed > 0 {
+		fmt.Fprintf(&sb, ", %d files failed", e.Failed)
+	}
+	if len(e.Paths) > 0 {
+		fmt.Fprintf(&sb, " (%s)", strings.Join(e.Paths, ", "))
+	}
+	return sb.String()
+}
+
+func isSlack(url string) bool {
+	u, err := neturl.Parse(url)
+	return err == nil && u.Host == "hooks.slack.com"
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/state"
+)
+
+func TestStatus(t *testing.T) {
+	for _, tc := range []struct {
+		run  state.Run
+		err  error
+		want string
+	}{
+		{state.Run{Files: 3}, nil, StatusSucceeded},
+		{state.Run{Files: 3, Failed: 1}, nil, StatusPartial},
+		{state.Run{}, errors.New("typesense down"), StatusFailed},
+		{state.Run{Aborted: true}, context.Canceled, StatusAborted},
+	} {
+		if got := Status(&tc.run, tc.err); got != tc.want {
+			t.Errorf("Status(%+v, %v) = %s, want %s", tc.run, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestSend_JSON(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected %s request with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	e := Event{
+		Command: "swarm-indexer index",
+		Status:  StatusPartial,
+		Host:    "build-01",
+		Run:     state.Run{Start: 100, End: 162, Paths: []string{"/src/app"}, Files: 12, Chunks: 340, Failed: 1},
+	}
+	if err := Send(context.Background(), server.Client(), server.URL, e); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got["status"] != StatusPartial || got["command"] != "swarm-indexer index" || got["chunks"] != float64(340) {
+		t.Errorf("unexpected payload %v", got)
+	}
+	if _, ok := got["error"]; ok {
+		t.Error("expected no error field for a run without one")
+	}
+}
+
+func TestSend_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := Send(context.Background(), server.Client(), server.URL+"/hooks/secret-token", Event{})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected a 403 error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("expected the URL to stay out of the error, got %v", err)
+	}
+}
+
+func TestText(t *testing.T) {
+	e := Event{
+		Command: "swarm-indexer index",
+		Status:  StatusFailed,
+		Host:    "build-01",
+		Error:   "ensuring collection: connection refused",
+		Run:     state.Run{Start: 100, End: 162, Paths: []string{"/src/app"}, Files: 2, Chunks: 40, Failed: 1},
+	}
+	want := "swarm-indexer index failed on build-01 after 1m2s: ensuring collection: connection refused\n" +
+		"2 files, 40 chunks indexed, 1 files failed (/src/app)"
+	if got := e.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+	if !isSlack("https://hooks.slack.com/services/T0/B0/x") || isSlack("https://example.com/hook") {
+		t.Error("expected only hooks.slack.com URLs to be Slack webhooks")
+	}
+}
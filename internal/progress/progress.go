@@ -0,0 +1,311 @@
+// Package progress reports indexing progress: a live bar with rate and ETA
+// on terminals, or periodic log lines when output isn't a terminal.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLogInterval is how often the log reporter emits a line.
+	DefaultLogInterval = 5 * time.Second
+
+	barWidth       = 30
+	redrawInterval = 100 * time.Millisecond
+	maxFileWidth   = 40
+)
+
+// Snapshot is the state of the current project's run.
+type Snapshot struct {
+	Project string
+	Total   int
+	Done    int
+	Failed  int
+	Chunks  int
+	Current string
+	Elapsed time.Duration
+	// Queues are the depths of the pipeline's queues, in the order they
+	// were first reported
+	Queues []Queue
+}
+
+// Queue is the depth of one of the pipeline's bounded queues. A queue
+// that stays full is in front of the bottleneck.
+type Queue struct {
+	Name string
+	Len  int
+	Cap  int
+}
+
+// formatQueues renders the queue depths as e.g. "embed 3/8 upsert 0/2"
+func formatQueues(queues []Queue) string {
+	parts := make([]string, len(queues))
+	for i, q := range queues {
+		parts[i] = fmt.Sprintf("%s %d/%d", q.Name, q.Len, q.Cap)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Rate returns files processed per second.
+func (s Snapshot) Rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Done) / s.Elapsed.Seconds()
+}
+
+// ETA estimates the time left from the current rate. It is zero until
+// the first file is done.
+func (s Snapshot) ETA() time.Duration {
+	rate := s.Rate()
+	if rate == 0 || s.Done >= s.Total {
+		return 0
+	}
+	return time.Duration(float64(s.Total-s.Done) / rate * float64(time.Second))
+}
+
+// tracker holds the counters shared by both reporters.
+type tracker struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	start time.Time
+	snap  Snapshot
+}
+
+func (t *tracker) begin(project string, total int) {
+	t.start = t.now()
+	t.snap = Snapshot{Project: project, Total: total}
+}
+
+func (t *tracker) snapshot() Snapshot {
+	s := t.snap
+	s.Elapsed = t.now().Sub(t.start)
+	s.Queues = append([]Queue(nil), s.Queues...)
+	return s
+}
+
+// queueDepth records the depth of the named queue; callers must hold t.mu.
+func (t *tracker) queueDepth(name string, n, capacity int) {
+	for i := range t.snap.Queues {
+		if t.snap.Queues[i].Name == name {
+			t.snap.Queues[i].Len, t.snap.Queues[i].Cap = n, capacity
+			return
+		}
+	}
+	t.snap.Queues = append(t.snap.Queues, Queue{Name: name, Len: n, Cap: capacity})
+}
+
+// Bar draws a single, continuously redrawn progress line.
+type Bar struct {
+	tracker
+	w      io.Writer
+	drawn  time.Time
+	active bool
+}
+
+// NewBar returns a bar that draws to w, which should be a terminal.
+func NewBar(w io.Writer) *Bar {
+	return &Bar{tracker: tracker{now: time.Now}, w: w}
+}
+
+func (b *Bar) Start(project string, total int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.begin(project, total)
+	b.active = true
+	b.draw(true)
+}
+
+func (b *Bar) FileStarted(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.snap.Current = path
+	b.draw(false)
+}
+
+func (b *Bar) FileDone(path string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.snap.Done++
+	if err != nil {
+		b.snap.Failed++
+	}
+	b.draw(false)
+}
+
+func (b *Bar) ChunksEmbedded(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.snap.Chunks += n
+	b.draw(false)
+}
+
+func (b *Bar) QueueDepth(name string, n, capacity int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.queueDepth(name, n, capacity)
+	b.draw(false)
+}
+
+func (b *Bar) Finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.active {
+		return
+	}
+	b.snap.Current = ""
+	b.draw(true)
+	fmt.Fprintln(b.w)
+	b.active = false
+}
+
+// draw redraws the line, at most every redrawInterval unless forced.
+// Callers must hold b.mu.
+func (b *Bar) draw(force bool) {
+	now := b.now()
+	if !force && now.Sub(b.drawn) < redrawInterval {
+		return
+	}
+	b.drawn = now
+	fmt.Fprintf(b.w, "\r\033[K%s", FormatBar(b.snapshot()))
+}
+
+// FormatBar renders s as a one-line progress bar.
+func FormatBar(s Snapshot) string {
+	filled := barWidth
+	if s.Total > 0 {
+		filled = s.Done * barWidth / s.Total
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+
+	line := fmt.Sprintf("[%s] %d/%d files  %d chunks  %.1f files/s", bar, s.Done, s.Total, s.Chunks, s.Rate())
+	if s.Failed > 0 {
+		line += fmt.Sprintf("  %d failed", s.Failed)
+	}
+	if eta := s.ETA(); eta > 0 {
+		line += "  ETA " + formatDuration(eta)
+	}
+	if len(s.Queues) > 0 {
+		line += "  queued " + formatQueues(s.Queues)
+	}
+	if s.Current != "" {
+		line += "  " + shorten(s.Current, maxFileWidth)
+	}
+	return line
+}
+
+// Log emits a progress log line at most once per interval, for output
+// that isn't a terminal (CI logs, files, pipes).
+type Log struct {
+	tracker
+	logger   *slog.Logger
+	interval time.Duration
+	logged   time.Time
+}
+
+// NewLog returns a reporter that logs to logger every interval.
+func NewLog(logger *slog.Logger, interval time.Duration) *Log {
+	return &Log{tracker: tracker{now: time.Now}, logger: logger, interval: interval}
+}
+
+func (l *Log) Start(project string, total int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.begin(project, total)
+	l.logged = l.start
+}
+
+func (l *Log) FileStarted(path string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.snap.Current = path
+}
+
+func (l *Log) FileDone(path string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.snap.Done++
+	if err != nil {
+		l.snap.Failed++
+	}
+	l.maybeLog()
+}
+
+func (l *Log) ChunksEmbedded(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.snap.Chunks += n
+}
+
+func (l *Log) QueueDepth(name string, n, capacity int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queueDepth(name, n, capacity)
+}
+
+// Finish is a no-op; the indexer logs its own summary.
+func (l *Log) Finish() {}
+
+// maybeLog logs the snapshot once the interval has passed; callers must
+// hold l.mu.
+func (l *Log) maybeLog() {
+	now := l.now()
+	if now.Sub(l.logged) < l.interval {
+		return
+	}
+	l.logged = now
+	s := l.snapshot()
+	attrs := []any{
+		"project", s.Project,
+		"processed", s.Done,
+		"total", s.Total,
+		"failed", s.Failed,
+		"chunks", s.Chunks,
+		"files_per_sec", fmt.Sprintf("%.1f", s.Rate()),
+		"eta", s.ETA().Round(time.Second).String(),
+		"current", s.Current,
+	}
+	if len(s.Queues) > 0 {
+		attrs = append(attrs, "queued", formatQueues(s.Queues))
+	}
+	l.logger.Info("progress", attrs...)
+}
+
+// IsTerminal reports whether w is a character device such as a terminal.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	s := int(d % time.Minute / time.Second)
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// shorten keeps the end of path, which is the most telling part.
+func shorten(path string, width int) string {
+	r := []rune(path)
+	if len(r) <= width {
+		return path
+	}
+	return "…" + string(r[len(r)-width+1:])
+}
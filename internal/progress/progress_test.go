@@ -0,0 +1,121 @@
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestSnapshot_RateAndETA(t *testing.T) {
+	s := Snapshot{Total: 100, Done: 20, Elapsed: 10 * time.Second}
+	if s.Rate() != 2 {
+		t.Errorf("expected 2 files/s, got %v", s.Rate())
+	}
+	if s.ETA() != 40*time.Second {
+		t.Errorf("expected 40s ETA, got %v", s.ETA())
+	}
+
+	if (Snapshot{Total: 10}).ETA() != 0 {
+		t.Error("expected no ETA before any file is done")
+	}
+}
+
+func TestFormatBar(t *testing.T) {
+	line := FormatBar(Snapshot{
+		Total:   10,
+		Done:    5,
+		Failed:  1,
+		Chunks:  42,
+		Current: "/very/long/path/to/some/deeply/nested/package/source_file.go",
+		Elapsed: 5 * time.Second,
+		Queues:  []Queue{{Name: "embed", Len: 8, Cap: 8}, {Name: "upsert", Len: 0, Cap: 2}},
+	})
+
+	for _, want := range []string{
+		"[" + strings.Repeat("=", 15) + strings.Repeat(" ", 15) + "]",
+		"5/10 files",
+		"42 chunks",
+		"1.0 files/s",
+		"1 failed",
+		"ETA 0:05",
+		"queued embed 8/8 upsert 0/2",
+		"…",
+		"source_file.go",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+}
+
+func TestBar_ThrottlesAndFinishes(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	var buf bytes.Buffer
+	b := NewBar(&buf)
+	b.now = clock.now
+
+	b.Start("/repo", 3)
+	b.FileStarted("a.go")
+	b.FileDone("a.go", nil)
+	if n := strings.Count(buf.String(), "\r"); n != 1 {
+		t.Errorf("expected redraws within the interval to be skipped, got %d draws", n)
+	}
+
+	clock.advance(time.Second)
+	b.FileDone("b.go", errors.New("boom"))
+	b.ChunksEmbedded(7)
+	b.Finish()
+
+	out := buf.String()
+	if !strings.HasSuffix(out, "\n") {
+		t.Error("expected Finish to end the line")
+	}
+	last := out[strings.LastIndex(out, "\r"):]
+	if !strings.Contains(last, "2/3 files") || !strings.Contains(last, "7 chunks") || !strings.Contains(last, "1 failed") {
+		t.Errorf("unexpected final line %q", last)
+	}
+}
+
+func TestLog_Periodic(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	var buf bytes.Buffer
+	l := NewLog(slog.New(slog.NewTextHandler(&buf, nil)), 5*time.Second)
+	l.now = clock.now
+
+	l.Start("/repo", 10)
+	for i := 0; i < 3; i++ {
+		l.FileDone("f.go", nil)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no log line before the interval, got %q", buf.String())
+	}
+
+	clock.advance(6 * time.Second)
+	l.ChunksEmbedded(12)
+	l.QueueDepth("embed", 3, 8)
+	l.QueueDepth("upsert", 0, 2)
+	l.QueueDepth("embed", 5, 8)
+	l.FileDone("g.go", nil)
+
+	out := buf.String()
+	for _, want := range []string{"msg=progress", "project=/repo", "processed=4", "total=10", "chunks=12", "eta=9s", `queued="embed 5/8 upsert 0/2"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	if IsTerminal(&bytes.Buffer{}) {
+		t.Error("a buffer is not a terminal")
+	}
+}
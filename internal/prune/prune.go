@@ -0,0 +1,252 @@
+// Package prune finds and removes indexed documents whose source is gone:
+// projects that were deleted or unregistered, and files that no longer
+// exist under a registered project. It also expires the documents of
+// registered projects with a TTL, and purges tombstones once their grace
+// period is over.
+package prune
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/archive"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/remote"
+)
+
+// Reasons a target is pruned
+const (
+	ReasonMissing      = "missing"      // project directory no longer exists
+	ReasonUnregistered = "unregistered" // project is not in the registry
+	ReasonDeleted      = "deleted"      // file no longer exists in the project
+	ReasonExpired      = "expired"      // documents last indexed longer than the project's TTL ago
+	ReasonTombstoned   = "tombstoned"   // tombstones older than the delete grace period
+)
+
+// Store is the search backend prune reads from and deletes in
+type Store interface {
+	ProjectPaths(ctx context.Context) (map[string]int64, error)
+	ProjectFiles(ctx context.Context, projectPath string) (map[string]int64, error)
+	DeleteByProject(ctx context.Context, projectPath string) (int, error)
+	DeleteFiles(ctx context.Context, projectPath string, relPaths []string) (int, error)
+	CountIndexedBefore(ctx context.Context, projectPath string, before int64) (int64, error)
+	FilesIndexedBefore(ctx context.Context, projectPath string, before int64) (map[string]int64, error)
+	DeleteIndexedBefore(ctx context.Context, projectPath string, before int64) (int, error)
+	Tombstones(ctx context.Context, before int64) (map[string]int64, error)
+	DeleteTombstones(ctx context.Context, projectPath string, before int64) (int, error)
+}
+
+// Target is a set of stale documents: a whole project when FilePath is
+// empty, otherwise one file within the project. Expired targets are the
+// project's documents last indexed before IndexedBefore, and tombstoned
+// ones its tombstones marked deleted before DeletedBefore.
+type Target struct {
+	ProjectPath   string `json:"project_path"`
+	FilePath      string `json:"file_path,omitempty"`
+	Reason        string `json:"reason"`
+	NumDocuments  int64  `json:"num_documents"`
+	IndexedBefore int64  `json:"indexed_before,omitempty"` // unix time
+	DeletedBefore int64  `json:"deleted_before,omitempty"` // unix time
+}
+
+// Find returns everything in the store that prune would remove, sorted
+// by project and file path.
+func Find(ctx context.Context, store Store, registered []string) ([]Target, error) {
+	projects, err := store.ProjectPaths(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+
+	known := make(map[string]bool, len(registered))
+	for _, p := range registered {
+		known[p] = true
+	}
+
+	var targets []Target
+	for projectPath, count := range projects {
+		// Remote repositories are refreshed by indexing them again, and
+		// documents have nothing on disk to compare against
+		if remote.IsURL(projectPath) || projectPath == indexer.DocProjectPath {
+			continue
+		}
+		if !exists(projectPath) {
+			targets = append(targets, Target{ProjectPath: projectPath, Reason: ReasonMissing, NumDocuments: count})
+			continue
+		}
+		// An archive's files are checked when it is indexed again
+		if archive.IsArchive(projectPath) {
+			continue
+		}
+		if !known[projectPath] {
+			targets = append(targets, Target{ProjectPath: projectPath, Reason: ReasonUnregistered, NumDocuments: count})
+			continue
+		}
+
+		files, err := store.ProjectFiles(ctx, projectPath)
+		if err != nil {
+			return nil, fmt.Errorf("listing files for %s: %w", projectPath, err)
+		}
+		for rel, n := range files {
+			if !exists(filepath.Join(projectPath, rel)) {
+				targets = append(targets, Target{ProjectPath: projectPath, FilePath: rel, Reason: ReasonDeleted, NumDocuments: n})
+			}
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].ProjectPath != targets[j].ProjectPath {
+			return targets[i].ProjectPath < targets[j].ProjectPath
+		}
+		return targets[i].FilePath < targets[j].FilePath
+	})
+	return targets, nil
+}
+
+// FindExpired returns a target for each project in ttls whose documents
+// include some last indexed longer than its TTL before now, sorted by
+// project. Projects no longer on disk are left to Find.
+func FindExpired(ctx context.Context, store Store, ttls map[string]time.Duration, now time.Time) ([]Target, error) {
+	projects := make([]string, 0, len(ttls))
+	for p := range ttls {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+
+	var targets []Target
+	for _, projectPath := range projects {
+		if !exists(projectPath) {
+			continue
+		}
+		before := now.Add(-ttls[projectPath]).Unix()
+		n, err := store.CountIndexedBefore(ctx, projectPath, before)
+		if err != nil {
+			return nil, fmt.Errorf("counting expired documents in %s: %w", projectPath, err)
+		}
+		if n > 0 {
+			targets = append(targets, Target{ProjectPath: projectPath, Reason: ReasonExpired, NumDocuments: n, IndexedBefore: before})
+		}
+	}
+	return targets, nil
+}
+
+// Expire deletes the expired documents of the projects in ttls, as found
+// by FindExpired, and returns how many were deleted.
+func Expire(ctx context.Context, store Store, ttls map[string]time.Duration, now time.Time) (int, error) {
+	targets, err := FindExpired(ctx, store, ttls, now)
+	if err != nil {
+		return 0, err
+	}
+	return Apply(ctx, store, targets)
+}
+
+// FindTombstones returns a target for each project with tombstones marked
+// deleted longer than grace before now, sorted by project.
+func FindTombstones(ctx context.Context, store Store, grace time.Duration, now time.Time) ([]Target, error) {
+	before := now.Add(-grace).Unix()
+	counts, err := store.Tombstones(ctx, before)
+	if err != nil {
+		return nil, fmt.Errorf("counting tombstones: %w", err)
+	}
+	var targets []Target
+	for projectPath, n := range counts {
+		targets = append(targets, Target{ProjectPath: projectPath, Reason: ReasonTombstoned, NumDocuments: n, DeletedBefore: before})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].ProjectPath < targets[j].ProjectPath })
+	return targets, nil
+}
+
+// Apply deletes the targets' documents, batching file deletes per project,
+// and returns how many documents were deleted. Projects removed as a whole
+// lose their metadata as well; expired and tombstoned targets delete only
+// the documents before their cutoff, and expired ones forget the state of
+// the files they came from so the next index run indexes them again.
+func Apply(ctx context.Context, store Store, targets []Target) (int, error) {
+	files := map[string][]string{}
+	var order []string
+	total := 0
+
+	for _, t := range targets {
+		if t.Reason == ReasonExpired {
+			expired, err := store.FilesIndexedBefore(ctx, t.ProjectPath, t.IndexedBefore)
+			if err != nil {
+				return total, fmt.Errorf("listing expired files in %s: %w", t.ProjectPath, err)
+			}
+			n, err := store.DeleteIndexedBefore(ctx, t.ProjectPath, t.IndexedBefore)
+			total += n
+			if err != nil {
+				return total, fmt.Errorf("expiring documents in %s: %w", t.ProjectPath, err)
+			}
+			if err := forgetFiles(t.ProjectPath, expired); err != nil {
+				return total, err
+			}
+			continue
+		}
+		if t.Reason == ReasonTombstoned {
+			n, err := store.DeleteTombstones(ctx, t.ProjectPath, t.DeletedBefore)
+			total += n
+			if err != nil {
+				return total, fmt.Errorf("purging tombstones in %s: %w", t.ProjectPath, err)
+			}
+			continue
+		}
+		if t.FilePath == "" {
+			n, err := store.DeleteByProject(ctx, t.ProjectPath)
+			total += n
+			if err != nil {
+				return total, fmt.Errorf("deleting %s: %w", t.ProjectPath, err)
+			}
+			// Without its documents the project must be indexed from
+			// scratch if it comes back
+			if err := metadata.Remove(t.ProjectPath); err != nil {
+				return total, err
+			}
+			continue
+		}
+		if _, ok := files[t.ProjectPath]; !ok {
+			order = append(order, t.ProjectPath)
+		}
+		files[t.ProjectPath] = append(files[t.ProjectPath], t.FilePath)
+	}
+
+	for _, projectPath := range order {
+		n, err := store.DeleteFiles(ctx, projectPath, files[projectPath])
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("deleting files in %s: %w", projectPath, err)
+		}
+	}
+	return total, nil
+}
+
+// forgetFiles clears the recorded state of the given files of the project
+// at path, keeping that of its other files and the rest of its metadata,
+// such as its tags
+func forgetFiles(path string, files map[string]int64) error {
+	meta, err := metadata.Load(path)
+	if err != nil {
+		return err
+	}
+	forgotten := false
+	for rel := range files {
+		if _, ok := meta.Files[rel]; ok {
+			delete(meta.Files, rel)
+			forgotten = true
+		}
+	}
+	if !forgotten {
+		return nil
+	}
+	return meta.Save(path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,415 @@
+package prune
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+)
+
+type fakeStore struct {
+	projects map[string]int64
+	files    map[string]map[string]int64
+
+	// expired counts each project's documents last indexed before the
+	// cutoff recorded in cutoffs, and expiredFiles those of each file
+	expired      map[string]int64
+	expiredFiles map[string]map[string]int64
+	cutoffs      map[string]int64
+
+	// tombstones counts each project's tombstones, all older than the
+	// cutoff
+	tombstones map[string]int64
+
+	deletedProjects []string
+	deletedFiles    map[string][]string
+	expiredBefore   map[string]int64
+	purgedBefore    map[string]int64
+}
+
+func (f *fakeStore) ProjectPaths(ctx context.Context) (map[string]int64, error) {
+	return f.projects, nil
+}
+
+func (f *fakeStore) ProjectFiles(ctx context.Context, projectPath string) (map[string]int64, error) {
+	return f.files[projectPath], nil
+}
+
+func (f *fakeStore) DeleteByProject(ctx context.Context, projectPath string) (int, error) {
+	f.deletedProjects = append(f.deletedProjects, projectPath)
+	return int(f.projects[projectPath]), nil
+}
+
+func (f *fakeStore) DeleteFiles(ctx context.Context, projectPath string, relPaths []string) (int, error) {
+	if f.deletedFiles == nil {
+		f.deletedFiles = map[string][]string{}
+	}
+	f.deletedFiles[projectPath] = append(f.deletedFiles[projectPath], relPaths...)
+	n := 0
+	for _, p := range relPaths {
+		n += int(f.files[projectPath][p])
+	}
+	return n, nil
+}
+
+func (f *fakeStore) CountIndexedBefore(ctx context.Context, projectPath string, before int64) (int64, error) {
+	if f.cutoffs == nil {
+		f.cutoffs = map[string]int64{}
+	}
+	f.cutoffs[projectPath] = before
+	return f.expired[projectPath], nil
+}
+
+func (f *fakeStore) FilesIndexedBefore(ctx context.Context, projectPath string, before int64) (map[string]int64, error) {
+	return f.expiredFiles[projectPath], nil
+}
+
+func (f *fakeStore) DeleteIndexedBefore(ctx context.Context, projectPath string, before int64) (int, error) {
+	if f.expiredBefore == nil {
+		f.expiredBefore = map[string]int64{}
+	}
+	f.expiredBefore[projectPath] = before
+	return int(f.expired[projectPath]), nil
+}
+
+func (f *fakeStore) Tombstones(ctx context.Context, before int64) (map[string]int64, error) {
+	return f.tombstones, nil
+}
+
+func (f *fakeStore) DeleteTombstones(ctx context.Context, projectPath string, before int64) (int, error) {
+	if f.purgedBefore == nil {
+		f.purgedBefore = map[string]int64{}
+	}
+	f.purgedBefore[projectPath] = before
+	return int(f.tombstones[projectPath]), nil
+}
+
+func setup(t *testing.T) (*fakeStore, string, string) {
+	t.Helper()
+	registered := t.TempDir()
+	unregistered := t.TempDir()
+	if err := os.WriteFile(filepath.Join(registered, "kept.go"), []byte("package x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	release := filepath.Join(registered, "release.zip")
+	if err := os.WriteFile(release, []byte("PK"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &fakeStore{
+		projects: map[string]int64{
+			registered:      5,
+			unregistered:    2,
+			"/gone/project": 4,
+			// Never on disk, refreshed by indexing it again
+			"https://github.com/org/repo.git": 3,
+			"doc:":                            2,
+			// Archives are kept while they exist
+			release:            6,
+			"/gone/old.tar.gz": 1,
+		},
+		files: map[string]map[string]int64{
+			registered: {"kept.go": 2, "removed.go": 2, "old/notes.md": 1},
+			release:    {"release.zip!/docs/readme.md": 6},
+		},
+	}
+	return store, registered, unregistered
+}
+
+func TestFind(t *testing.T) {
+	store, registered, unregistered := setup(t)
+
+	targets, err := Find(context.Background(), store, []string{registered})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+
+	want := []Target{
+		{ProjectPath: "/gone/old.tar.gz", Reason: ReasonMissing, NumDocuments: 1},
+		{ProjectPath: "/gone/project", Reason: ReasonMissing, NumDocuments: 4},
+		{ProjectPath: registered, FilePath: "old/notes.md", Reason: ReasonDeleted, NumDocuments: 1},
+		{ProjectPath: registered, FilePath: "removed.go", Reason: ReasonDeleted, NumDocuments: 2},
+		{ProjectPath: unregistered, Reason: ReasonUnregistered, NumDocuments: 2},
+	}
+	// Sort order depends on the temp dir names
+	got := map[string]Target{}
+	for _, tg := range targets {
+		got[tg.ProjectPath+"|"+tg.FilePath] = tg
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("expected %d targets, got %+v", len(want), targets)
+	}
+	for _, w := range want {
+		if g, ok := got[w.ProjectPath+"|"+w.FilePath]; !ok || g != w {
+			t.Errorf("expected target %+v, got %+v", w, g)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_DATA_DIR", t.TempDir())
+	store, registered, _ := setup(t)
+	if err := (&metadata.Metadata{FileCount: 4}).Save("/gone/project"); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&metadata.Metadata{FileCount: 1}).Save(registered); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := []Target{
+		{ProjectPath: "/gone/project", Reason: ReasonMissing},
+		{ProjectPath: registered, FilePath: "removed.go", Reason: ReasonDeleted},
+		{ProjectPath: registered, FilePath: "old/notes.md", Reason: ReasonDeleted},
+	}
+	n, err := Apply(context.Background(), store, targets)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if n != 7 {
+		t.Errorf("expected 7 documents deleted, got %d", n)
+	}
+	if !reflect.DeepEqual(store.deletedProjects, []string{"/gone/project"}) {
+		t.Errorf("unexpected project deletes: %v", store.deletedProjects)
+	}
+	if !reflect.DeepEqual(store.deletedFiles[registered], []string{"removed.go", "old/notes.md"}) {
+		t.Errorf("expected file deletes batched per project, got %v", store.deletedFiles)
+	}
+	if metadata.Exists("/gone/project") {
+		t.Error("expected metadata of the removed project to be deleted")
+	}
+	if !metadata.Exists(registered) {
+		t.Error("expected metadata of the kept project to remain")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_DATA_DIR", t.TempDir())
+	notes, fresh := t.TempDir(), t.TempDir()
+	store := &fakeStore{expired: map[string]int64{notes: 3}, expiredFiles: map[string]map[string]int64{notes: {"old.md": 3}}}
+	meta := &metadata.Metadata{Files: map[string]metadata.FileState{"old.md": {Size: 1}, "new.md": {Size: 2}}}
+	if err := meta.Save(notes); err != nil {
+		t.Fatal(err)
+	}
+	ttls := map[string]time.Duration{notes: 24 * time.Hour, fresh: time.Hour, "/gone/scratch": time.Hour}
+	now := time.Unix(1_700_000_000, 0)
+
+	targets, err := FindExpired(context.Background(), store, ttls, now)
+	if err != nil {
+		t.Fatalf("FindExpired failed: %v", err)
+	}
+	before := now.Add(-24 * time.Hour).Unix()
+	want := []Target{{ProjectPath: notes, Reason: ReasonExpired, NumDocuments: 3, IndexedBefore: before}}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("expected %+v, got %+v", want, targets)
+	}
+	if _, ok := store.cutoffs["/gone/scratch"]; ok {
+		t.Error("expected a missing project to be left to Find")
+	}
+	if got := store.cutoffs[fresh]; got != now.Add(-time.Hour).Unix() {
+		t.Errorf("expected each project's own TTL, got cutoff %d", got)
+	}
+
+	n, err := Expire(context.Background(), store, ttls, now)
+	if err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if n != 3 || store.expiredBefore[notes] != before || len(store.deletedProjects) != 0 {
+		t.Errorf("expected only documents before the cutoff deleted, got %d, %v, %v", n, store.expiredBefore, store.deletedProjects)
+	}
+	meta, err = metadata.Load(notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := meta.Files["new.md"]; !ok || len(meta.Files) != 1 {
+		t.Errorf("expected only the expired file's state forgotten, got %v", meta.Files)
+	}
+}
+
+func TestFindTombstones(t *testing.T) {
+	store := &fakeStore{tombstones: map[string]int64{"/b": 1, "/a": 2}}
+	now := time.Unix(1_700_000_000, 0)
+
+	targets, err := FindTombstones(context.Background(), store, 72*time.Hour, now)
+	if err != nil {
+		t.Fatalf("FindTombstones failed: %v", err)
+	}
+	before := now.Add(-72 * time.Hour).Unix()
+	want := []Target{
+		{ProjectPath: "/a", Reason: ReasonTombstoned, NumDocuments: 2, DeletedBefore: before},
+		{ProjectPath: "/b", Reason: ReasonTombstoned, NumDocuments: 1, DeletedBefore: before},
+	}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("expected %+v, got %+v", want, targets)
+	}
+
+	n, err := Apply(context.Background(), store, targets)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if n != 3 || store.purgedBefore["/a"] != before || len(store.deletedProjects) != 0 {
+		t.Errorf("expected only tombstones purged, got %d, %v, %v", n, store.purgedBefore, store.deletedProjects)
+	}
+}
+
+// memStore keeps documents in memory, for both prune and the indexer
+type memStore struct {
+	mu   sync.Mutex
+	docs map[string]indexer.IndexedChunk
+}
+
+func (m *memStore) remove(match func(indexer.IndexedChunk) bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, d := range m.docs {
+		if match(d) {
+			delete(m.docs, id)
+			n++
+		}
+	}
+	return n
+}
+
+func (m *memStore) count(match func(indexer.IndexedChunk) bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, d := range m.docs {
+		if match(d) {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *memStore) UpsertChunks(ctx context.Context, chunks []indexer.IndexedChunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range chunks {
+		m.docs[c.ID] = c
+	}
+	return nil
+}
+
+func (m *memStore) DeleteByProject(ctx context.Context, projectPath string) (int, error) {
+	return m.remove(func(d indexer.IndexedChunk) bool { return d.ProjectPath == projectPath }), nil
+}
+
+func (m *memStore) DeleteFiles(ctx context.Context, projectPath string, relPaths []string) (int, error) {
+	return m.remove(func(d indexer.IndexedChunk) bool {
+		return d.ProjectPath == projectPath && slices.Contains(relPaths, d.RelPath)
+	}), nil
+}
+
+func (m *memStore) DeleteChunks(ctx context.Context, ids []string) (int, error) {
+	return m.remove(func(d indexer.IndexedChunk) bool { return slices.Contains(ids, d.ID) }), nil
+}
+
+func (m *memStore) UpdateLines(ctx context.Context, chunks []indexer.IndexedChunk) error {
+	return nil
+}
+
+func (m *memStore) UpdateTags(ctx context.Context, projectPath string, tags []string) (int, error) {
+	return 0, nil
+}
+
+func (m *memStore) TombstoneFiles(ctx context.Context, projectPath string, relPaths []string, deletedAt int64) (int, error) {
+	return m.DeleteFiles(ctx, projectPath, relPaths)
+}
+
+func (m *memStore) ExportProject(ctx context.Context, projectPath string, fn func(indexer.IndexedChunk) error) error {
+	return nil
+}
+
+func (m *memStore) ProjectPaths(ctx context.Context) (map[string]int64, error) {
+	return nil, nil
+}
+
+func (m *memStore) ProjectFiles(ctx context.Context, projectPath string) (map[string]int64, error) {
+	return nil, nil
+}
+
+func (m *memStore) CountIndexedBefore(ctx context.Context, projectPath string, before int64) (int64, error) {
+	return m.count(func(d indexer.IndexedChunk) bool { return d.ProjectPath == projectPath && d.LastIndexed < before }), nil
+}
+
+func (m *memStore) FilesIndexedBefore(ctx context.Context, projectPath string, before int64) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := map[string]int64{}
+	for _, d := range m.docs {
+		if d.ProjectPath == projectPath && d.LastIndexed < before {
+			files[d.RelPath]++
+		}
+	}
+	return files, nil
+}
+
+func (m *memStore) DeleteIndexedBefore(ctx context.Context, projectPath string, before int64) (int, error) {
+	return m.remove(func(d indexer.IndexedChunk) bool { return d.ProjectPath == projectPath && d.LastIndexed < before }), nil
+}
+
+func (m *memStore) Tombstones(ctx context.Context, before int64) (map[string]int64, error) {
+	return nil, nil
+}
+
+func (m *memStore) DeleteTombstones(ctx context.Context, projectPath string, before int64) (int, error) {
+	return 0, nil
+}
+
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i := range texts {
+		vectors[i] = []float32{1, 0}
+	}
+	return vectors, nil
+}
+
+func TestExpire_IndexedAgain(t *testing.T) {
+	t.Setenv("SWARM_INDEXER_DATA_DIR", t.TempDir())
+	notes := t.TempDir()
+	if err := os.WriteFile(filepath.Join(notes, "todo.md"), []byte("# Todo\n\nShip the release.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := &memStore{docs: map[string]indexer.IndexedChunk{}}
+	ctx := context.Background()
+	index := func() {
+		t.Helper()
+		idx := indexer.NewIndexer(&config.Config{}, store, fakeEmbedder{})
+		if err := idx.IndexPaths(ctx, []string{notes}); err != nil {
+			t.Fatalf("IndexPaths failed: %v", err)
+		}
+	}
+	all := func(indexer.IndexedChunk) bool { return true }
+
+	index()
+	if store.count(all) == 0 {
+		t.Fatal("expected the notes to be indexed")
+	}
+
+	// A day later, everything indexed is older than the TTL
+	ttls := map[string]time.Duration{notes: time.Hour}
+	if _, err := Expire(ctx, store, ttls, time.Now().Add(24*time.Hour)); err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if n := store.count(all); n != 0 {
+		t.Fatalf("expected the notes' documents to expire, %d left", n)
+	}
+
+	index()
+	if store.count(all) == 0 {
+		t.Error("expected the unchanged notes to be indexed again after expiring")
+	}
+}
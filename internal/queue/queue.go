@@ -0,0 +1,148 @@
+// Package queue carries file jobs from an index run's coordinator to
+// worker processes, possibly on other machines, and their results back,
+// through Redis lists.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/usage"
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix namespaces the lists in Redis
+const keyPrefix = "swarm-indexer:"
+
+// jobsKey is the list every worker takes jobs from
+const jobsKey = keyPrefix + "jobs"
+
+// resultsTTL is how long a run's results are kept once reported, so the
+// results of a coordinator that went away don't pile up
+const resultsTTL = 24 * time.Hour
+
+// Job is a file for a worker to index. Workers read it at Path, so the
+// project must be at the same path on every machine, e.g. a shared mount.
+type Job struct {
+	Run  string `json:"run"`  // the coordinator's run, whose results list the result goes to
+	Root string `json:"root"` // project directory
+	Path string `json:"path"` // absolute
+	// Prev holds the chunk hashes by ID recorded for the file, so only
+	// its new and changed chunks are embedded
+	Prev map[string]string `json:"prev,omitempty"`
+	// Tags are stored on the file's chunks
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Result is a worker's report on a job, once the file's chunks are
+// upserted and the chunks it no longer has are deleted.
+type Result struct {
+	Path       string            `json:"path"`
+	Language   string            `json:"language,omitempty"`
+	SkipReason string            `json:"skip_reason,omitempty"`
+	Chunks     map[string]string `json:"chunks,omitempty"` // chunk hashes by ID
+	Usage      usage.Stats       `json:"usage"`            // of embedding the file's chunks
+	// Op and Error are set when the file couldn't be indexed
+	Op    string `json:"op,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Queue is where coordinators put jobs and workers take them.
+type Queue interface {
+	// Push adds jobs for any worker to take.
+	Push(ctx context.Context, jobs []Job) error
+	// Pop takes the next job, waiting up to timeout for one. It returns
+	// nil without error when none came.
+	Pop(ctx context.Context, timeout time.Duration) (*Job, error)
+	// Report sends the result of a job of run to its coordinator.
+	Report(ctx context.Context, run string, r Result) error
+	// Result takes the next result reported for run, waiting up to
+	// timeout for one. It returns nil without error when none came.
+	Result(ctx context.Context, run string, timeout time.Duration) (*Result, error)
+	Close() error
+}
+
+// Open connects to the queue at url, a redis:// or rediss:// URL such as
+// redis://:password@queue.internal:6379/0.
+func Open(url string) (Queue, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue URL: %w", err)
+	}
+	return &redisQueue{client: redis.NewClient(opts)}, nil
+}
+
+type redisQueue struct {
+	client *redis.Client
+}
+
+func resultsKey(run string) string {
+	return keyPrefix + "results:" + run
+}
+
+func (q *redisQueue) Push(ctx context.Context, jobs []Job) error {
+	values := make([]any, len(jobs))
+	for i, j := range jobs {
+		data, err := json.Marshal(j)
+		if err != nil {
+			return err
+		}
+		values[i] = data
+	}
+	return q.client.LPush(ctx, jobsKey, values...).Err()
+}
+
+func (q *redisQueue) Pop(ctx context.Context, timeout time.Duration) (*Job, error) {
+	var j Job
+	ok, err := q.pop(ctx, jobsKey, timeout, &j)
+	if !ok {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (q *redisQueue) Report(ctx context.Context, run string, r Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	key := resultsKey(run)
+	pipe := q.client.TxPipeline()
+	pipe.LPush(ctx, key, data)
+	pipe.Expire(ctx, key, resultsTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (q *redisQueue) Result(ctx context.Context, run string, timeout time.Duration) (*Result, error) {
+	var r Result
+	ok, err := q.pop(ctx, resultsKey(run), timeout, &r)
+	if !ok {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// pop takes the oldest value of the list at key into v, reporting
+// whether there was one within timeout
+func (q *redisQueue) pop(ctx context.Context, key string, timeout time.Duration, v any) (bool, error) {
+	values, err := q.client.BRPop(ctx, timeout, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// BRPOP returns the key followed by the value
+	if err := json.Unmarshal([]byte(values[1]), v); err != nil {
+		return false, fmt.Errorf("decoding %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (q *redisQueue) Close() error {
+	return q.client.Close()
+}
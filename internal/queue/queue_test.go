@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func openTest(t *testing.T) (Queue, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	q, err := Open("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q, server
+}
+
+func TestQueue_JobsInOrder(t *testing.T) {
+	q, _ := openTest(t)
+	ctx := context.Background()
+
+	jobs := []Job{
+		{Run: "r1", Root: "/src/app", Path: "/src/app/main.go", Prev: map[string]string{"c1": "h1"}},
+		{Run: "r1", Root: "/src/app", Path: "/src/app/util.go"},
+	}
+	if err := q.Push(ctx, jobs); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	for _, want := range jobs {
+		j, err := q.Pop(ctx, time.Second)
+		if err != nil || j == nil {
+			t.Fatalf("Pop: got %v, %v", j, err)
+		}
+		if j.Path != want.Path || j.Prev["c1"] != want.Prev["c1"] {
+			t.Errorf("expected %+v, got %+v", want, j)
+		}
+	}
+	if j, err := q.Pop(ctx, 50*time.Millisecond); j != nil || err != nil {
+		t.Errorf("expected no job once the queue is empty, got %v, %v", j, err)
+	}
+}
+
+func TestQueue_ResultsByRun(t *testing.T) {
+	q, server := openTest(t)
+	ctx := context.Background()
+
+	if err := q.Report(ctx, "r1", Result{Path: "/src/app/main.go", Language: "go"}); err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if err := q.Report(ctx, "r2", Result{Path: "/src/lib/lib.go", Op: "read", Error: "permission denied"}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL(resultsKey("r1")); ttl != resultsTTL {
+		t.Errorf("expected results to expire after %s, got %s", resultsTTL, ttl)
+	}
+
+	r, err := q.Result(ctx, "r2", time.Second)
+	if err != nil || r == nil || r.Path != "/src/lib/lib.go" || r.Error != "permission denied" {
+		t.Fatalf("expected r2's result, got %+v, %v", r, err)
+	}
+	if r, err := q.Result(ctx, "r2", 50*time.Millisecond); r != nil || err != nil {
+		t.Errorf("expected no other result for r2, got %+v, %v", r, err)
+	}
+}
+
+func TestOpen_InvalidURL(t *testing.T) {
+	if _, err := Open("nats://queue:4222"); err == nil {
+		t.Error("expected a non-Redis URL to be rejected")
+	}
+}
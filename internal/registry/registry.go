@@ -0,0 +1,152 @@
+// Package registry persists the set of paths swarm-indexer has indexed so
+// commands can operate on them without the user passing every path.
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/walker"
+)
+
+// FileName is the name of the registry file in the config dir.
+const FileName = "registry.json"
+
+// Entry is a single registered path.
+type Entry struct {
+	Path    string `json:"path"`
+	AddedAt int64  `json:"added_at"`
+	// TTL is how long after they were last indexed the path's documents
+	// expire, for scratch and notes directories; 0 means never
+	TTL time.Duration `json:"ttl,omitempty"`
+}
+
+// Registry is the persisted list of registered paths.
+type Registry struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Load reads the registry from the given config directory.
+// Returns an empty registry if the file doesn't exist.
+func Load(dir string) (*Registry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Registry{}, nil
+		}
+		return nil, err
+	}
+
+	var r Registry
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse registry: %w", err)
+	}
+	return &r, nil
+}
+
+// Save writes the registry to the given config directory atomically.
+func (r *Registry) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal registry: %w", err)
+	}
+
+	path := filepath.Join(dir, FileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp registry file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename registry file: %w", err)
+	}
+	return nil
+}
+
+// Add registers path, returning false if it was already registered.
+// Paths are stored in absolute, cleaned form.
+func (r *Registry) Add(path string) (bool, error) {
+	abs, err := walker.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	if r.Contains(abs) {
+		return false, nil
+	}
+	r.Entries = append(r.Entries, Entry{Path: abs, AddedAt: time.Now().Unix()})
+	sort.Slice(r.Entries, func(i, j int) bool {
+		return r.Entries[i].Path < r.Entries[j].Path
+	})
+	return true, nil
+}
+
+// Remove unregisters path, returning false if it wasn't registered.
+func (r *Registry) Remove(path string) (bool, error) {
+	abs, err := walker.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	for i, e := range r.Entries {
+		if e.Path == abs {
+			r.Entries = append(r.Entries[:i], r.Entries[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetTTL sets the TTL of the registered path, 0 removing it, returning
+// false if the path isn't registered.
+func (r *Registry) SetTTL(path string, ttl time.Duration) (bool, error) {
+	abs, err := walker.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	for i := range r.Entries {
+		if r.Entries[i].Path == abs {
+			r.Entries[i].TTL = ttl
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TTLs returns the TTL of each registered path that has one.
+func (r *Registry) TTLs() map[string]time.Duration {
+	ttls := map[string]time.Duration{}
+	for _, e := range r.Entries {
+		if e.TTL > 0 {
+			ttls[e.Path] = e.TTL
+		}
+	}
+	return ttls
+}
+
+// Contains reports whether the absolute path is registered.
+func (r *Registry) Contains(absPath string) bool {
+	for _, e := range r.Entries {
+		if e.Path == absPath {
+			return true
+		}
+	}
+	return false
+}
+
+// Paths returns all registered paths in sorted order.
+func (r *Registry) Paths() []string {
+	paths := make([]string, len(r.Entries))
+	for i, e := range r.Entries {
+		paths[i] = e.Path
+	}
+	return paths
+}
@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_NonExistentFile(t *testing.T) {
+	r, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(r.Entries) != 0 {
+		t.Errorf("expected empty registry, got %d entries", len(r.Entries))
+	}
+}
+
+func TestLoad_CorruptJSON(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("{invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(dir); err == nil {
+		t.Error("expected error for corrupt registry")
+	}
+}
+
+func TestAddSave_RoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config")
+	projectB := t.TempDir()
+	projectA := t.TempDir()
+
+	r := &Registry{}
+	for _, p := range []string{projectB, projectA} {
+		added, err := r.Add(p)
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if !added {
+			t.Errorf("expected %s to be newly added", p)
+		}
+	}
+	if err := r.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	paths := loaded.Paths()
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 paths, got %v", paths)
+	}
+	if paths[0] > paths[1] {
+		t.Errorf("expected sorted paths, got %v", paths)
+	}
+	if loaded.Entries[0].AddedAt == 0 {
+		t.Error("expected AddedAt to be set")
+	}
+}
+
+func TestAdd_Duplicate(t *testing.T) {
+	project := t.TempDir()
+	r := &Registry{}
+
+	if _, err := r.Add(project); err != nil {
+		t.Fatal(err)
+	}
+	added, err := r.Add(project + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added {
+		t.Error("expected duplicate path not to be added")
+	}
+	if len(r.Entries) != 1 {
+		t.Errorf("expected 1 entry, got %d", len(r.Entries))
+	}
+}
+
+func TestAdd_RelativePath(t *testing.T) {
+	r := &Registry{}
+	if _, err := r.Add("."); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, _ := os.Getwd()
+	if r.Entries[0].Path != wd {
+		t.Errorf("expected relative path to resolve to %s, got %s", wd, r.Entries[0].Path)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	project := t.TempDir()
+	r := &Registry{}
+	if _, err := r.Add(project); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := r.Remove(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !removed {
+		t.Error("expected path to be removed")
+	}
+
+	removed, err = r.Remove(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed {
+		t.Error("expected second remove to report false")
+	}
+}
+
+func TestSetTTL(t *testing.T) {
+	notes, code := t.TempDir(), t.TempDir()
+	dir := t.TempDir()
+	r := &Registry{}
+	for _, p := range []string{notes, code} {
+		if _, err := r.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, err := r.SetTTL(notes, 24*time.Hour); err != nil || !ok {
+		t.Fatalf("SetTTL failed: %v, %v", ok, err)
+	}
+	if ok, _ := r.SetTTL(t.TempDir(), time.Hour); ok {
+		t.Error("expected an unregistered path to be reported")
+	}
+	if err := r.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttls := loaded.TTLs()
+	if len(ttls) != 1 || ttls[notes] != 24*time.Hour {
+		t.Errorf("expected only the notes TTL, got %v", ttls)
+	}
+
+	if _, err := loaded.SetTTL(notes, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttls := loaded.TTLs(); len(ttls) != 0 {
+		t.Errorf("expected the TTL removed, got %v", ttls)
+	}
+}
@@ -0,0 +1,114 @@
+// Package remote keeps shallow clones of remote git repositories under the
+// data dir, so they can be indexed like local directories.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+)
+
+// reposDir is the directory under the data dir holding the clones
+const reposDir = "repos"
+
+// schemes are the URL schemes git can clone from
+var schemes = []string{"https://", "http://", "ssh://", "git://", "file://"}
+
+// scpPattern matches git's scp-like syntax, e.g. git@github.com:org/repo.git
+var scpPattern = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^/\\]`)
+
+// IsURL reports whether arg names a remote git repository rather than a
+// local path.
+func IsURL(arg string) bool {
+	for _, s := range schemes {
+		if strings.HasPrefix(arg, s) {
+			return true
+		}
+	}
+	return scpPattern.MatchString(arg)
+}
+
+// ProjectPath returns the project path a repository's documents are
+// stored under: its URL without any credentials in it.
+func ProjectPath(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil || u.User == nil || u.Scheme == "" {
+		return url
+	}
+	u.User = nil
+	return u.String()
+}
+
+// Dir returns where the clone of url is kept:
+// $DATA_DIR/repos/<sha256 of its project path>.
+func Dir(url string) (string, error) {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving data dir: %w", err)
+	}
+	sum := sha256.Sum256([]byte(ProjectPath(url)))
+	return filepath.Join(dataDir, reposDir, hex.EncodeToString(sum[:8])), nil
+}
+
+// Sync makes the clone of url hold ref, a branch, tag or commit (the
+// remote's default branch when empty), cloning it on first use. Only the
+// one commit is fetched. It returns the clone's directory and the commit
+// checked out.
+func Sync(ctx context.Context, url, ref string) (dir, commit string, err error) {
+	if dir, err = Dir(url); err != nil {
+		return "", "", err
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", "", err
+		}
+		if _, err := git(ctx, dir, "init", "--quiet"); err != nil {
+			return "", "", err
+		}
+	}
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := git(ctx, dir, "fetch", "--quiet", "--depth=1", "--no-tags", url, ref); err != nil {
+		return "", "", err
+	}
+	if _, err := git(ctx, dir, "checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return "", "", err
+	}
+	// Nothing but the checkout should be indexed
+	if _, err := git(ctx, dir, "clean", "--quiet", "--force", "-d", "-x"); err != nil {
+		return "", "", err
+	}
+	if commit, err = git(ctx, dir, "rev-parse", "HEAD"); err != nil {
+		return "", "", err
+	}
+	return dir, commit, nil
+}
+
+// git runs git in dir without prompting for credentials and returns its
+// trimmed output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,93 @@
+package search
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Boost multiplies the score of results under a project or path prefix.
+type Boost struct {
+	Prefix string  `json:"prefix"`
+	Weight float64 `json:"weight"`
+}
+
+// ParseBoost parses a "prefix=weight" specification.
+func ParseBoost(spec string) (Boost, error) {
+	idx := strings.LastIndex(spec, "=")
+	if idx <= 0 {
+		return Boost{}, fmt.Errorf("invalid boost %q: expected prefix=weight", spec)
+	}
+
+	weight, err := strconv.ParseFloat(spec[idx+1:], 64)
+	if err != nil || weight <= 0 {
+		return Boost{}, fmt.Errorf("invalid boost weight in %q: must be a positive number", spec)
+	}
+
+	return Boost{Prefix: filepath.Clean(spec[:idx]), Weight: weight}, nil
+}
+
+// ParseBoosts parses a list of boost specifications, ignoring empty entries.
+func ParseBoosts(specs []string) ([]Boost, error) {
+	var boosts []Boost
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		b, err := ParseBoost(spec)
+		if err != nil {
+			return nil, err
+		}
+		boosts = append(boosts, b)
+	}
+	return boosts, nil
+}
+
+// ApplyBoosts multiplies each result's score by the weight of the longest
+// boost prefix matching its project or file path, then re-sorts by score.
+func ApplyBoosts(results []SearchResult, boosts []Boost) []SearchResult {
+	if len(boosts) == 0 {
+		return results
+	}
+
+	for i := range results {
+		if w, ok := boostWeight(results[i], boosts); ok {
+			results[i].Score *= w
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
+
+// boostWeight returns the weight of the longest prefix matching r
+func boostWeight(r SearchResult, boosts []Boost) (float64, bool) {
+	bestLen := -1
+	var weight float64
+	for _, b := range boosts {
+		if !hasPathPrefix(r.ProjectPath, b.Prefix) && !hasPathPrefix(r.AbsPath, b.Prefix) && !hasPathPrefix(r.FilePath, b.Prefix) {
+			continue
+		}
+		if len(b.Prefix) > bestLen {
+			bestLen = len(b.Prefix)
+			weight = b.Weight
+		}
+	}
+	return weight, bestLen >= 0
+}
+
+// hasPathPrefix reports whether path equals prefix or lies beneath it
+func hasPathPrefix(path, prefix string) bool {
+	if path == "" || prefix == "" {
+		return false
+	}
+	if path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
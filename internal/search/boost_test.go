@@ -0,0 +1,124 @@
+package search_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/search"
+)
+
+// TestParseBoost tests parsing of prefix=weight specifications
+func TestParseBoost(t *testing.T) {
+	b, err := search.ParseBoost("/home/me/work/api/=2.5")
+	if err != nil {
+		t.Fatalf("ParseBoost failed: %v", err)
+	}
+	if b.Prefix != "/home/me/work/api" {
+		t.Errorf("expected cleaned prefix '/home/me/work/api', got %q", b.Prefix)
+	}
+	if b.Weight != 2.5 {
+		t.Errorf("expected weight 2.5, got %f", b.Weight)
+	}
+}
+
+// TestParseBoost_Invalid tests that malformed specifications are rejected
+func TestParseBoost_Invalid(t *testing.T) {
+	for _, spec := range []string{"/path", "=2", "/path=abc", "/path=0", "/path=-1"} {
+		if _, err := search.ParseBoost(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+// TestParseBoosts_SkipsEmpty tests that empty entries are ignored
+func TestParseBoosts_SkipsEmpty(t *testing.T) {
+	boosts, err := search.ParseBoosts([]string{"", " /a=2 ", ""})
+	if err != nil {
+		t.Fatalf("ParseBoosts failed: %v", err)
+	}
+	if len(boosts) != 1 || boosts[0].Prefix != "/a" {
+		t.Errorf("expected one boost for /a, got %+v", boosts)
+	}
+}
+
+// TestApplyBoosts_Reorders tests that boosted projects rank first
+func TestApplyBoosts_Reorders(t *testing.T) {
+	results := []search.SearchResult{
+		{FilePath: "dep.go", ProjectPath: "/go/pkg/mod/dep", Score: 0.9},
+		{FilePath: "main.go", ProjectPath: "/work/api", Score: 0.6},
+		{FilePath: "other.go", ProjectPath: "/work/apiserver", Score: 0.5},
+	}
+
+	boosted := search.ApplyBoosts(results, []search.Boost{{Prefix: "/work/api", Weight: 2}})
+
+	if boosted[0].FilePath != "main.go" {
+		t.Errorf("expected boosted main.go first, got %s", boosted[0].FilePath)
+	}
+	if boosted[0].Score != 1.2 {
+		t.Errorf("expected boosted score 1.2, got %f", boosted[0].Score)
+	}
+	// Prefix must match on path boundaries only
+	for _, r := range boosted {
+		if r.FilePath == "other.go" && r.Score != 0.5 {
+			t.Errorf("expected /work/apiserver to be unboosted, got score %f", r.Score)
+		}
+	}
+}
+
+// TestApplyBoosts_LongestPrefixWins tests that the most specific boost applies
+func TestApplyBoosts_LongestPrefixWins(t *testing.T) {
+	results := []search.SearchResult{
+		{FilePath: "a.go", ProjectPath: "/work/api", Score: 1},
+	}
+
+	boosted := search.ApplyBoosts(results, []search.Boost{
+		{Prefix: "/work", Weight: 3},
+		{Prefix: "/work/api", Weight: 0.5},
+	})
+
+	if boosted[0].Score != 0.5 {
+		t.Errorf("expected most specific weight 0.5 to apply, got %f", boosted[0].Score)
+	}
+}
+
+// TestApplyBoosts_AbsolutePath tests that a prefix under a project boosts
+// the results of the files beneath it
+func TestApplyBoosts_AbsolutePath(t *testing.T) {
+	results := []search.SearchResult{
+		{FilePath: "cmd/main.go", AbsPath: "/work/api/cmd/main.go", ProjectPath: "/work/api", Score: 1},
+		{FilePath: "internal/auth/token.go", AbsPath: "/work/api/internal/auth/token.go", ProjectPath: "/work/api", Score: 0.8},
+	}
+
+	boosted := search.ApplyBoosts(results, []search.Boost{{Prefix: "/work/api/internal", Weight: 2}})
+
+	if boosted[0].FilePath != "internal/auth/token.go" || boosted[0].Score != 1.6 {
+		t.Errorf("expected internal/auth/token.go boosted first, got %+v", boosted[0])
+	}
+	if boosted[1].Score != 1 {
+		t.Errorf("expected cmd/main.go unboosted, got score %f", boosted[1].Score)
+	}
+}
+
+// TestSearch_BoostPromotesBeyondLimit tests that boosts can pull in results
+// that would have fallen outside the unboosted limit
+func TestSearch_BoostPromotesBeyondLimit(t *testing.T) {
+	mockSearcher := &search.MockSearcher{
+		Results: []search.SearchResult{
+			{FilePath: "dep1.go", ProjectPath: "/deps", Score: 0.9},
+			{FilePath: "dep2.go", ProjectPath: "/deps", Score: 0.8},
+			{FilePath: "mine.go", ProjectPath: "/work", Score: 0.5},
+		},
+	}
+
+	results, err := search.Search(context.Background(), mockSearcher, "q", search.Options{
+		Limit:  1,
+		Boosts: []search.Boost{{Prefix: "/work", Weight: 4}},
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if len(results) != 1 || results[0].FilePath != "mine.go" {
+		t.Errorf("expected boosted mine.go as the only result, got %+v", results)
+	}
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// SavedSearchesFileName is the name of the saved searches file in the config dir.
+const SavedSearchesFileName = "saved-searches.json"
+
+// SavedSearch is a named query together with its filters and output flags.
+type SavedSearch struct {
+	Query   string  `json:"query"`
+	Options Options `json:"options"`
+	JSON    bool    `json:"json,omitempty"`
+}
+
+// LoadSaved reads all saved searches from the given config directory.
+// Returns an empty map if the file doesn't exist.
+func LoadSaved(dir string) (map[string]SavedSearch, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SavedSearchesFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]SavedSearch{}, nil
+		}
+		return nil, err
+	}
+
+	saved := map[string]SavedSearch{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse saved searches: %w", err)
+	}
+	return saved, nil
+}
+
+// GetSaved returns the saved search with the given name.
+func GetSaved(dir, name string) (SavedSearch, error) {
+	saved, err := LoadSaved(dir)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	s, ok := saved[name]
+	if !ok {
+		return SavedSearch{}, fmt.Errorf("no saved search named %q", name)
+	}
+	return s, nil
+}
+
+// SaveSearch stores s under name, replacing any existing entry.
+func SaveSearch(dir, name string, s SavedSearch) error {
+	if name == "" {
+		return errors.New("saved search name is required")
+	}
+
+	saved, err := LoadSaved(dir)
+	if err != nil {
+		return err
+	}
+	saved[name] = s
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal saved searches: %w", err)
+	}
+
+	path := filepath.Join(dir, SavedSearchesFileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write saved searches: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename saved searches file: %w", err)
+	}
+	return nil
+}
+
+// SavedNames returns the names of all saved searches in sorted order.
+func SavedNames(saved map[string]SavedSearch) []string {
+	names := make([]string, 0, len(saved))
+	for name := range saved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,93 @@
+package search_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/search"
+)
+
+// TestLoadSaved_NoFile tests that a missing file yields no saved searches
+func TestLoadSaved_NoFile(t *testing.T) {
+	saved, err := search.LoadSaved(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadSaved failed: %v", err)
+	}
+	if len(saved) != 0 {
+		t.Errorf("expected no saved searches, got %d", len(saved))
+	}
+}
+
+// TestSaveSearch_RoundTrip tests that a saved search can be read back
+func TestSaveSearch_RoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "config")
+
+	want := search.SavedSearch{
+		Query:   "auth middleware",
+		Options: search.Options{Limit: 5, Language: "go", ProjectPath: "/src/api"},
+		JSON:    true,
+	}
+	if err := search.SaveSearch(dir, "my-auth-flows", want); err != nil {
+		t.Fatalf("SaveSearch failed: %v", err)
+	}
+
+	got, err := search.GetSaved(dir, "my-auth-flows")
+	if err != nil {
+		t.Fatalf("GetSaved failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+// TestSaveSearch_Replaces tests that saving under an existing name overwrites it
+func TestSaveSearch_Replaces(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := search.SaveSearch(dir, "q", search.SavedSearch{Query: "old"}); err != nil {
+		t.Fatalf("SaveSearch failed: %v", err)
+	}
+	if err := search.SaveSearch(dir, "other", search.SavedSearch{Query: "other"}); err != nil {
+		t.Fatalf("SaveSearch failed: %v", err)
+	}
+	if err := search.SaveSearch(dir, "q", search.SavedSearch{Query: "new"}); err != nil {
+		t.Fatalf("SaveSearch failed: %v", err)
+	}
+
+	saved, err := search.LoadSaved(dir)
+	if err != nil {
+		t.Fatalf("LoadSaved failed: %v", err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("expected 2 saved searches, got %d", len(saved))
+	}
+	if saved["q"].Query != "new" {
+		t.Errorf("expected query 'new', got %q", saved["q"].Query)
+	}
+
+	names := search.SavedNames(saved)
+	if names[0] != "other" || names[1] != "q" {
+		t.Errorf("expected sorted names [other q], got %v", names)
+	}
+}
+
+// TestGetSaved_Unknown tests that an unknown name returns an error
+func TestGetSaved_Unknown(t *testing.T) {
+	if _, err := search.GetSaved(t.TempDir(), "missing"); err == nil {
+		t.Error("expected error for unknown saved search")
+	}
+}
+
+// TestLoadSaved_Corrupt tests that an unparsable file returns an error
+func TestLoadSaved_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, search.SavedSearchesFileName), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := search.LoadSaved(dir); err == nil {
+		t.Error("expected error for corrupt saved searches file")
+	}
+}
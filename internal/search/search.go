@@ -4,24 +4,61 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // SearchResult represents a single search result
 type SearchResult struct {
-	FilePath    string  `json:"file_path"`
-	ProjectPath string  `json:"project_path"`
-	Language    string  `json:"language"`
-	ChunkType   string  `json:"chunk_type"`
-	Content     string  `json:"content"`
-	StartLine   int     `json:"start_line"`
-	EndLine     int     `json:"end_line"`
-	Score       float64 `json:"score"`
+	FilePath    string   `json:"file_path"`          // relative to ProjectPath
+	AbsPath     string   `json:"abs_path,omitempty"` // ProjectPath and FilePath joined
+	ProjectPath string   `json:"project_path"`
+	Language    string   `json:"language"`
+	ChunkType   string   `json:"chunk_type"`
+	HeadingPath string   `json:"heading_path,omitempty"` // enclosing markdown headings
+	Tags        []string `json:"tags,omitempty"`
+	Content     string   `json:"content"`
+	StartLine   int      `json:"start_line"`
+	EndLine     int      `json:"end_line"`
+	Score       float64  `json:"score"`
+}
+
+// Options holds the limit and filters applied to a search
+type Options struct {
+	Limit       int    `json:"limit,omitempty"`
+	Language    string `json:"language,omitempty"`
+	ChunkType   string `json:"chunk_type,omitempty"`
+	ProjectPath string `json:"project_path,omitempty"`
+	// Tags only match results with every one of them
+	Tags   []string `json:"tags,omitempty"`
+	Boosts []Boost  `json:"boosts,omitempty"`
+}
+
+// Matches reports whether a result satisfies the filters in opts
+func (o Options) Matches(r SearchResult) bool {
+	if o.Language != "" && r.Language != o.Language {
+		return false
+	}
+	if o.ChunkType != "" && r.ChunkType != o.ChunkType {
+		return false
+	}
+	if o.ProjectPath != "" && r.ProjectPath != o.ProjectPath {
+		return false
+	}
+	for _, tag := range o.Tags {
+		if !slices.Contains(r.Tags, tag) {
+			return false
+		}
+	}
+	return true
 }
 
 // Searcher interface for performing searches
 type Searcher interface {
-	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+	Search(ctx context.Context, query string, opts Options) ([]SearchResult, error)
 	IsEmpty(ctx context.Context) (bool, error)
 }
 
@@ -32,23 +69,58 @@ type MockSearcher struct {
 	Err        error
 }
 
-func (m *MockSearcher) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+func (m *MockSearcher) Search(ctx context.Context, query string, opts Options) ([]SearchResult, error) {
 	if m.Err != nil {
 		return nil, m.Err
 	}
-	if limit > 0 && limit < len(m.Results) {
-		return m.Results[:limit], nil
+	results := []SearchResult{}
+	for _, r := range m.Results {
+		if opts.Matches(r) {
+			results = append(results, r)
+		}
 	}
-	return m.Results, nil
+	if opts.Limit > 0 && opts.Limit < len(results) {
+		return results[:opts.Limit], nil
+	}
+	return results, nil
 }
 
 func (m *MockSearcher) IsEmpty(ctx context.Context) (bool, error) {
 	return m.EmptyIndex, nil
 }
 
-// Search performs a hybrid search using the provided searcher
-func Search(ctx context.Context, searcher Searcher, query string, limit int) ([]SearchResult, error) {
-	return searcher.Search(ctx, query, limit)
+// boostCandidateFactor is how many more results than the limit are fetched
+// when boosts are configured, so boosted results outside the raw top-N can
+// still be promoted into the final page.
+const boostCandidateFactor = 3
+
+// Search performs a hybrid search using the provided searcher.
+// Configured boosts are applied to the scores before the limit is enforced.
+func Search(ctx context.Context, searcher Searcher, query string, opts Options) (results []SearchResult, err error) {
+	ctx, span := tracing.StartSpan(ctx, "search", attribute.Int("limit", opts.Limit))
+	defer func() {
+		span.SetAttributes(attribute.Int("results", len(results)))
+		tracing.End(span, err)
+	}()
+
+	if len(opts.Boosts) == 0 {
+		return searcher.Search(ctx, query, opts)
+	}
+
+	fetch := opts
+	if opts.Limit > 0 {
+		fetch.Limit = opts.Limit * boostCandidateFactor
+	}
+	results, err = searcher.Search(ctx, query, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	results = ApplyBoosts(results, opts.Boosts)
+	if opts.Limit > 0 && opts.Limit < len(results) {
+		results = results[:opts.Limit]
+	}
+	return results, nil
 }
 
 // FormatResults formats search results as text or JSON
@@ -66,6 +138,9 @@ func FormatResults(results []SearchResult, asJSON bool) string {
 	for i, r := range results {
 		sb.WriteString(fmt.Sprintf("[%d] %s:%d-%d (%s) score: %.2f\n",
 			i+1, r.FilePath, r.StartLine, r.EndLine, r.ChunkType, r.Score))
+		if r.HeadingPath != "" {
+			sb.WriteString("    Section: " + r.HeadingPath + "\n")
+		}
 
 		content := r.Content
 		const maxLen = 200
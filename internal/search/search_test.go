@@ -29,7 +29,7 @@ func TestSearch_ReturnsResults(t *testing.T) {
 		},
 	}
 
-	results, err := search.Search(ctx, mockSearcher, "authentication middleware", 10)
+	results, err := search.Search(ctx, mockSearcher, "authentication middleware", search.Options{Limit: 10})
 	if err != nil {
 		t.Fatalf("Search failed: %v", err)
 	}
@@ -67,7 +67,7 @@ func TestSearch_WithLimit(t *testing.T) {
 		},
 	}
 
-	results, err := search.Search(ctx, mockSearcher, "test query", 3)
+	results, err := search.Search(ctx, mockSearcher, "test query", search.Options{Limit: 3})
 	if err != nil {
 		t.Fatalf("Search failed: %v", err)
 	}
@@ -85,7 +85,7 @@ func TestSearch_NoResults(t *testing.T) {
 		Results: []search.SearchResult{},
 	}
 
-	results, err := search.Search(ctx, mockSearcher, "nonexistent query xyz", 10)
+	results, err := search.Search(ctx, mockSearcher, "nonexistent query xyz", search.Options{Limit: 10})
 	if err != nil {
 		t.Fatalf("Search should not error on no results: %v", err)
 	}
@@ -104,7 +104,7 @@ func TestSearch_EmptyIndex(t *testing.T) {
 		Results:    []search.SearchResult{},
 	}
 
-	results, err := search.Search(ctx, mockSearcher, "any query", 10)
+	results, err := search.Search(ctx, mockSearcher, "any query", search.Options{Limit: 10})
 	if err != nil {
 		t.Fatalf("Search should not error on empty index: %v", err)
 	}
@@ -163,6 +163,31 @@ func TestFormatResults_TextFormat(t *testing.T) {
 }
 
 // TestFormatResults_JSONFormat tests JSON output formatting
+func TestFormatResults_HeadingPath(t *testing.T) {
+	results := []search.SearchResult{
+		{
+			FilePath:    "docs/guide.md",
+			ChunkType:   "header",
+			HeadingPath: "Getting Started > Installation",
+			Content:     "## Installation\n\nRun go install.",
+			StartLine:   5,
+			EndLine:     7,
+			Score:       0.8,
+		},
+	}
+
+	if output := search.FormatResults(results, false); !strings.Contains(output, "Section: Getting Started > Installation") {
+		t.Errorf("expected the heading path in the output, got:\n%s", output)
+	}
+	var parsed []search.SearchResult
+	if err := json.Unmarshal([]byte(search.FormatResults(results, true)), &parsed); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if len(parsed) != 1 || parsed[0].HeadingPath != "Getting Started > Installation" {
+		t.Errorf("expected heading_path in the JSON output, got %+v", parsed)
+	}
+}
+
 func TestFormatResults_JSONFormat(t *testing.T) {
 	results := []search.SearchResult{
 		{
@@ -241,3 +266,43 @@ func TestFormatResults_TruncatesLongContent(t *testing.T) {
 		t.Error("expected truncated content to have '...'")
 	}
 }
+
+// TestSearch_Filters tests that language and project filters narrow results
+func TestSearch_Filters(t *testing.T) {
+	ctx := context.Background()
+
+	mockSearcher := &search.MockSearcher{
+		Results: []search.SearchResult{
+			{FilePath: "a.go", Language: "go", ProjectPath: "/api"},
+			{FilePath: "b.py", Language: "python", ProjectPath: "/api"},
+			{FilePath: "c.go", Language: "go", ProjectPath: "/web"},
+		},
+	}
+
+	results, err := search.Search(ctx, mockSearcher, "query", search.Options{Language: "go", ProjectPath: "/api"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if len(results) != 1 || results[0].FilePath != "a.go" {
+		t.Fatalf("expected only a.go, got %+v", results)
+	}
+}
+
+func TestSearch_TagFilter(t *testing.T) {
+	mockSearcher := &search.MockSearcher{
+		Results: []search.SearchResult{
+			{FilePath: "a.go", Tags: []string{"backend", "payments"}},
+			{FilePath: "b.go", Tags: []string{"backend"}},
+			{FilePath: "c.go"},
+		},
+	}
+
+	results, err := search.Search(context.Background(), mockSearcher, "query", search.Options{Tags: []string{"payments", "backend"}})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].FilePath != "a.go" {
+		t.Fatalf("expected only a.go, which has both tags, got %+v", results)
+	}
+}
@@ -0,0 +1,136 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// BaselineFileName is the name of the secrets baseline in a project root.
+const BaselineFileName = ".swarm-indexer-secrets-baseline"
+
+// Baseline lists findings accepted as false positives, such as fake keys
+// in test fixtures, so they are neither redacted nor reported again.
+type Baseline struct {
+	Entries []Fingerprint `json:"entries"`
+}
+
+// Fingerprint identifies a finding by rule, file and a hash of the match,
+// so the baseline never holds the secret itself. Line numbers are left
+// out so that edits elsewhere in the file don't invalidate it.
+type Fingerprint struct {
+	Rule string `json:"rule"`
+	Path string `json:"path"` // slash-separated, relative to the project root
+	Hash string `json:"hash"` // hex SHA-256 of the match
+}
+
+// NewFingerprint fingerprints a finding in the file at path, relative to
+// the project root.
+func NewFingerprint(path string, f Finding) Fingerprint {
+	sum := sha256.Sum256([]byte(f.Match))
+	return Fingerprint{
+		Rule: f.Type,
+		Path: filepath.ToSlash(path),
+		Hash: hex.EncodeToString(sum[:]),
+	}
+}
+
+// NewBaseline returns a baseline accepting every finding in files.
+func NewBaseline(files []FileFindings) *Baseline {
+	b := &Baseline{Entries: []Fingerprint{}}
+	seen := map[Fingerprint]bool{}
+	for _, ff := range files {
+		for _, f := range ff.Findings {
+			fp := NewFingerprint(ff.Path, f)
+			if !seen[fp] {
+				seen[fp] = true
+				b.Entries = append(b.Entries, fp)
+			}
+		}
+	}
+	sort.Slice(b.Entries, func(i, j int) bool {
+		x, y := b.Entries[i], b.Entries[j]
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		if x.Rule != y.Rule {
+			return x.Rule < y.Rule
+		}
+		return x.Hash < y.Hash
+	})
+	return b
+}
+
+// LoadBaseline reads the baseline from the given directory.
+// Returns an empty baseline if the file doesn't exist.
+func LoadBaseline(dirPath string) (*Baseline, error) {
+	data, err := os.ReadFile(filepath.Join(dirPath, BaselineFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Baseline{}, nil
+		}
+		return nil, err
+	}
+
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", BaselineFileName, err)
+	}
+	return &b, nil
+}
+
+// Save writes the baseline to the given directory atomically.
+func (b *Baseline) Save(dirPath string) error {
+	path := filepath.Join(dirPath, BaselineFileName)
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal baseline: %w", err)
+	}
+	data = append(data, '\n')
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp baseline file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename baseline file: %w", err)
+	}
+	return nil
+}
+
+// Contains reports whether the baseline accepts fp.
+func (b *Baseline) Contains(fp Fingerprint) bool {
+	for _, e := range b.Entries {
+		if e == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter drops findings in the file at path (relative to the project
+// root) that the baseline accepts. ShouldSkip and SkipRule are
+// recomputed from the findings that remain.
+func (s *Scanner) Filter(result *ScanResult, b *Baseline, path string) *ScanResult {
+	if b == nil || len(b.Entries) == 0 {
+		return result
+	}
+	filtered := &ScanResult{}
+	for _, f := range result.Findings {
+		if b.Contains(NewFingerprint(path, f)) {
+			continue
+		}
+		filtered.Findings = append(filtered.Findings, f)
+		if r := s.rule(f.Type); r != nil && r.SkipFile && !filtered.ShouldSkip {
+			filtered.ShouldSkip, filtered.SkipRule = true, r.ID
+		}
+	}
+	return filtered
+}
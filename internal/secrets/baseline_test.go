@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBaseline_SaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	b := NewBaseline([]FileFindings{
+		{Path: "testdata/keys.txt", Findings: []Finding{{Line: 3, Match: fakeAWSKey, Type: "aws-access-token"}}},
+		{Path: "a.go", Findings: []Finding{{Line: 1, Match: fakeGitHubPAT, Type: "github-pat"}, {Line: 9, Match: fakeGitHubPAT, Type: "github-pat"}}},
+	})
+	if len(b.Entries) != 2 || b.Entries[0].Path != "a.go" {
+		t.Fatalf("expected 2 sorted, deduplicated entries, got %+v", b.Entries)
+	}
+	if err := b.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, BaselineFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{fakeAWSKey, fakeGitHubPAT} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("baseline holds the secret %q", secret)
+		}
+	}
+
+	loaded, err := LoadBaseline(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := NewFingerprint("testdata/keys.txt", Finding{Line: 40, Match: fakeAWSKey, Type: "aws-access-token"})
+	if !loaded.Contains(fp) {
+		t.Error("expected fingerprint to survive a line change")
+	}
+}
+
+func TestLoadBaseline_Missing(t *testing.T) {
+	b, err := LoadBaseline(t.TempDir())
+	if err != nil || len(b.Entries) != 0 {
+		t.Errorf("expected empty baseline, got %+v (err %v)", b, err)
+	}
+}
+
+func TestScanner_Filter(t *testing.T) {
+	s := New()
+	content := "a = " + fakeGitHubPAT + "\nb = " + fakeAWSKey + "\n"
+	result, err := s.ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBaseline([]FileFindings{{Path: "fixtures/x.txt", Findings: result.Findings[:1]}})
+
+	filtered := s.Filter(result, b, filepath.FromSlash("fixtures/x.txt"))
+	if len(filtered.Findings) != 1 || filtered.Findings[0].Type != "aws-access-token" {
+		t.Errorf("expected only the AWS finding, got %+v", filtered.Findings)
+	}
+	// The same secret in another file isn't accepted
+	if other := s.Filter(result, b, "other.txt"); len(other.Findings) != 2 {
+		t.Errorf("expected baseline to be per file, got %+v", other.Findings)
+	}
+}
+
+func TestScanDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	writeTestFile(t, filepath.Join(dir, "fixtures", "keys.txt"), "key: "+fakeGitHubPAT+"\n")
+	writeTestFile(t, filepath.Join(dir, "ignored", "keys.txt"), "key: "+fakeGitHubPAT+"\n")
+	writeTestFile(t, filepath.Join(dir, ".gitignore"), "ignored/\n")
+
+	files, err := New().ScanDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Path != "fixtures/keys.txt" || len(files[0].Findings) != 1 {
+		t.Errorf("unexpected findings %+v", files)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
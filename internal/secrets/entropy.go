@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"strings"
+	"unicode"
+)
+
+// EntropyRuleID is the finding type reported by the entropy detector
+const EntropyRuleID = "high-entropy-string"
+
+// Defaults for EntropyDetector fields left zero
+const (
+	DefaultEntropyMinLength = 20
+	DefaultEntropyCharset   = "base64"
+)
+
+// defaultThresholds are per charset, as a smaller alphabet caps entropy
+// lower: 16 hex digits can't exceed 4 bits per character.
+var defaultThresholds = map[string]float64{
+	"base64":       4.5,
+	"hex":          3.0,
+	"alphanumeric": 4.2,
+}
+
+// Charsets maps the charset names the entropy detector accepts to the
+// characters a candidate token may consist of.
+var Charsets = map[string]string{
+	"base64":       "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/_-",
+	"hex":          "0123456789abcdefABCDEF",
+	"alphanumeric": "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+}
+
+// EntropyDetector flags random-looking tokens that no rule recognises,
+// such as keys for internal services. A token is a maximal run of Charset
+// characters at least MinLength long; it is reported when its Shannon
+// entropy reaches Threshold and it mixes letters and digits. Zero fields
+// take the defaults, with the threshold depending on the charset.
+type EntropyDetector struct {
+	Threshold float64
+	MinLength int
+	Charset   string // a key of Charsets
+}
+
+// find returns the spans of high-entropy tokens in content
+func (d *EntropyDetector) find(content string) []span {
+	name := d.Charset
+	if _, ok := Charsets[name]; !ok {
+		name = DefaultEntropyCharset
+	}
+	charset := Charsets[name]
+	threshold := d.Threshold
+	if threshold <= 0 {
+		threshold = defaultThresholds[name]
+	}
+	minLength := d.MinLength
+	if minLength <= 0 {
+		minLength = DefaultEntropyMinLength
+	}
+
+	var spans []span
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		token := content[start:end]
+		if len(token) >= minLength && mixesLettersAndDigits(token) && shannonEntropy(token) >= threshold {
+			spans = append(spans, span{start: start, end: end, rule: EntropyRuleID})
+		}
+		start = -1
+	}
+	for i := 0; i < len(content); i++ {
+		if strings.IndexByte(charset, content[i]) >= 0 {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(content))
+	return spans
+}
+
+// mixesLettersAndDigits filters out long identifiers and numbers, which
+// are the bulk of false positives in source code.
+func mixesLettersAndDigits(s string) bool {
+	return strings.ContainsFunc(s, unicode.IsDigit) && strings.ContainsFunc(s, unicode.IsLetter)
+}
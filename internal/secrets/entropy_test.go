@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+)
+
+// fakeRandomToken has no provider prefix, so only the entropy detector
+// can catch it.
+var fakeRandomToken = "Q7x" + "9Lk2VfR8mZp4TsW1nYb6Hc3Jd5"
+
+func TestScanContent_Entropy(t *testing.T) {
+	content := "INTERNAL_SERVICE=" + fakeRandomToken + "\n"
+	result, err := New().ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Findings) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", result.Findings)
+	}
+	f := result.Findings[0]
+	if f.Type != EntropyRuleID || f.Match != fakeRandomToken || f.Column != len("INTERNAL_SERVICE=")+1 {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestScanContent_EntropyIgnoresCode(t *testing.T) {
+	content := strings.Join([]string{
+		"func TestIndexPaths_RedactsSecretsFromEveryChunk(t *testing.T) {",
+		`	sum := "0000000000000000000000000000000000000000"`,
+		"	// github.com/dvaida/swarm-indexer/internal/secrets",
+		"}",
+	}, "\n")
+	result, err := New().ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Findings) != 0 {
+		t.Errorf("expected no findings, got %+v", result.Findings)
+	}
+}
+
+func TestScanContent_RulesWinOverEntropy(t *testing.T) {
+	result, err := New().ScanContent("token: " + fakeGitHubPAT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Findings) != 1 || result.Findings[0].Type != "github-pat" {
+		t.Errorf("expected a single github-pat finding, got %+v", result.Findings)
+	}
+}
+
+func TestEntropyDetector_Settings(t *testing.T) {
+	hexDigest := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b"
+	content := "digest " + hexDigest + " token " + fakeRandomToken
+
+	tests := []struct {
+		name     string
+		detector *EntropyDetector
+		want     []string
+	}{
+		// hex can't reach the base64 threshold
+		{"base64 default", &EntropyDetector{}, []string{fakeRandomToken}},
+		{"hex", &EntropyDetector{Charset: "hex"}, []string{hexDigest}},
+		{"low threshold", &EntropyDetector{Threshold: 3}, []string{hexDigest, fakeRandomToken}},
+		{"high threshold", &EntropyDetector{Threshold: 5}, nil},
+		{"long minimum", &EntropyDetector{Charset: "alphanumeric", Threshold: 3, MinLength: 35}, []string{hexDigest}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			s.SetEntropy(tt.detector)
+			result, err := s.ScanContent(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range result.Findings {
+				got = append(got, f.Match)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanContent_EntropyDisabled(t *testing.T) {
+	s := New()
+	s.SetEntropy(nil)
+	result, err := s.ScanContent("INTERNAL_SERVICE=" + fakeRandomToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Findings) != 0 {
+		t.Errorf("expected no findings with the detector disabled, got %+v", result.Findings)
+	}
+}
+
+func TestNewFromConfig_EntropyEnabled(t *testing.T) {
+	content := "INTERNAL_SERVICE=" + fakeRandomToken
+	for _, enabled := range []bool{false, true} {
+		s := NewFromConfig(&config.Config{EntropyEnabled: enabled, EntropyMinLength: 20, EntropyCharset: "base64"})
+		result, err := s.ScanContent(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found := len(result.Findings) == 1; found != enabled {
+			t.Errorf("enabled=%v: got findings %+v", enabled, result.Findings)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"sort"
+	"strings"
+)
+
+// Redact replaces each finding in content with its rule's replacement,
+// by default a [REDACTED:<type>] marker. Line breaks inside a secret are
+// kept, so line numbers computed on the redacted content match the
+// original; with SetPreserveLength, byte columns match too. Findings that
+// no longer match content at their position are ignored.
+func (s *Scanner) Redact(content string, findings []Finding) string {
+	var spans []span
+	for _, f := range findings {
+		start, ok := offset(content, f.Line, f.Column)
+		if !ok || !strings.HasPrefix(content[start:], f.Match) {
+			continue
+		}
+		sp := span{start: start, end: start + len(f.Match), rule: f.Type}
+		if !overlapsAny(spans, sp) {
+			spans = append(spans, sp)
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, sp := range spans {
+		b.WriteString(content[last:sp.start])
+		b.WriteString(s.redaction(content[sp.start:sp.end], s.replacement(sp.rule)))
+		last = sp.end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// replacement returns the text that replaces a secret found by rule id
+func (s *Scanner) replacement(id string) string {
+	if r := s.rule(id); r != nil && r.Replacement != "" {
+		return r.Replacement
+	}
+	return "[REDACTED:" + id + "]"
+}
+
+// redaction returns what replaces secret: marker on its first line, with
+// the secret's line breaks (\n or \r\n) kept. When preserving length,
+// each line of the secret is replaced by the same number of bytes: the
+// marker padded with '*', or only '*' if the marker doesn't fit.
+func (s *Scanner) redaction(secret, marker string) string {
+	marker = strings.NewReplacer("\r", " ", "\n", " ").Replace(marker)
+	lines := strings.Split(secret, "\n")
+	var b strings.Builder
+	for i, line := range lines {
+		eol := ""
+		if i < len(lines)-1 {
+			eol = "\n"
+			if strings.HasSuffix(line, "\r") {
+				line, eol = line[:len(line)-1], "\r\n"
+			}
+		}
+		switch {
+		case !s.preserveLength:
+			if i == 0 {
+				b.WriteString(marker)
+			}
+		case len(marker) <= len(line) && i == 0:
+			b.WriteString(marker + strings.Repeat("*", len(line)-len(marker)))
+		default:
+			b.WriteString(strings.Repeat("*", len(line)))
+		}
+		b.WriteString(eol)
+	}
+	return b.String()
+}
+
+// position converts a byte offset into a 1-based line and byte column
+func position(content string, off int) (line, col int) {
+	line = 1 + strings.Count(content[:off], "\n")
+	lineStart := strings.LastIndexByte(content[:off], '\n') + 1
+	return line, off - lineStart + 1
+}
+
+// offset converts a 1-based line and byte column into a byte offset
+func offset(content string, line, col int) (int, bool) {
+	if line < 1 || col < 1 {
+		return 0, false
+	}
+	start := 0
+	for l := 1; l < line; l++ {
+		i := strings.IndexByte(content[start:], '\n')
+		if i < 0 {
+			return 0, false
+		}
+		start += i + 1
+	}
+	off := start + col - 1
+	if off > len(content) {
+		return 0, false
+	}
+	return off, true
+}
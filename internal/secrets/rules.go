@@ -0,0 +1,240 @@
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Rule detects one kind of secret. The rules below are adapted from the
+// gitleaks default configuration (https://github.com/gitleaks/gitleaks).
+type Rule struct {
+	ID          string
+	Description string
+	Regex       *regexp.Regexp
+	// SecretGroup is the capture group holding the secret; 0 means the
+	// whole match.
+	SecretGroup int
+	// Keywords are lowercase strings one of which must appear in the
+	// content before the regex is run. Empty means always run.
+	Keywords []string
+	// Entropy is the minimum Shannon entropy of the secret; lower values
+	// are treated as placeholders such as "changeme" or "xxxxxxxx".
+	Entropy float64
+	// RequireDigit drops secrets without a digit, which are almost always
+	// identifiers (config.APIKey) rather than credentials.
+	RequireDigit bool
+	// Replacement is the text Redact puts in place of the secret; empty
+	// means [REDACTED:<ID>].
+	Replacement string
+	// SkipFile marks a match as grounds for leaving the whole file out of
+	// the index rather than redacting it.
+	SkipFile bool
+}
+
+// DefaultRules returns the built-in rules, most specific first so that a
+// provider-specific match wins over the generic rule.
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			// The whole block is the secret: partial key material is still
+			// a leak. A block cut off before its END line runs to the end
+			// of the content.
+			ID:          "private-key",
+			Description: "PEM private key block",
+			Regex:       regexp.MustCompile(`(?s)-----BEGIN[ A-Z0-9_-]{0,100}PRIVATE KEY(?: BLOCK)?-----.*?(?:-----END[ A-Z0-9_-]{0,100}PRIVATE KEY(?: BLOCK)?-----|\z)`),
+			Keywords:    []string{"private key"},
+		},
+		{
+			ID:          "aws-access-token",
+			Description: "AWS access key ID",
+			Regex:       regexp.MustCompile(`\b((?:A3T[A-Z0-9]|AKIA|ASIA|ABIA|ACCA)[A-Z2-7]{16})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"a3t", "akia", "asia", "abia", "acca"},
+		},
+		{
+			ID:          "github-pat",
+			Description: "GitHub personal access token",
+			Regex:       regexp.MustCompile(`ghp_[0-9a-zA-Z]{36}`),
+			Keywords:    []string{"ghp_"},
+		},
+		{
+			ID:          "github-fine-grained-pat",
+			Description: "GitHub fine-grained personal access token",
+			Regex:       regexp.MustCompile(`github_pat_\w{82}`),
+			Keywords:    []string{"github_pat_"},
+		},
+		{
+			ID:          "github-oauth",
+			Description: "GitHub OAuth, app or refresh token",
+			Regex:       regexp.MustCompile(`(?:gho|ghu|ghs|ghr)_[0-9a-zA-Z]{36}`),
+			Keywords:    []string{"gho_", "ghu_", "ghs_", "ghr_"},
+		},
+		{
+			ID:          "gitlab-pat",
+			Description: "GitLab personal access token",
+			Regex:       regexp.MustCompile(`glpat-[\w-]{20}`),
+			Keywords:    []string{"glpat-"},
+		},
+		{
+			ID:          "slack-token",
+			Description: "Slack bot, user or app token",
+			Regex:       regexp.MustCompile(`xox[abposer]-(?:\d+-)+[0-9a-zA-Z-]{10,}`),
+			Keywords:    []string{"xoxa-", "xoxb-", "xoxp-", "xoxo-", "xoxs-", "xoxe-", "xoxr-"},
+		},
+		{
+			ID:          "slack-webhook-url",
+			Description: "Slack incoming webhook URL",
+			Regex:       regexp.MustCompile(`(?:https?://)?hooks\.slack\.com/(?:services|workflows)/[A-Za-z0-9+/]{43,46}`),
+			Keywords:    []string{"hooks.slack.com"},
+		},
+		{
+			ID:          "stripe-access-token",
+			Description: "Stripe secret or restricted key",
+			Regex:       regexp.MustCompile(`\b((?:sk|rk)_(?:test|live|prod)_[a-zA-Z0-9]{10,99})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"sk_test", "sk_live", "sk_prod", "rk_test", "rk_live", "rk_prod"},
+		},
+		{
+			ID:          "gcp-api-key",
+			Description: "Google Cloud API key",
+			Regex:       regexp.MustCompile(`\b(AIza[\w-]{35})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"aiza"},
+		},
+		{
+			ID:          "openai-api-key",
+			Description: "OpenAI API key",
+			Regex:       regexp.MustCompile(`\b(sk-(?:(?:proj|svcacct|admin)-[A-Za-z0-9_-]{20,74})?[A-Za-z0-9_-]{0,74}T3BlbkFJ[A-Za-z0-9_-]{20,74})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"t3blbkfj"},
+		},
+		{
+			ID:          "anthropic-api-key",
+			Description: "Anthropic API key",
+			Regex:       regexp.MustCompile(`\b(sk-ant-(?:api|admin)\d{2}-[a-zA-Z0-9_-]{80,100})`),
+			SecretGroup: 1,
+			Keywords:    []string{"sk-ant-"},
+		},
+		{
+			ID:          "npm-access-token",
+			Description: "npm access token",
+			Regex:       regexp.MustCompile(`(?i)\b(npm_[a-z0-9]{36})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"npm_"},
+		},
+		{
+			ID:          "pypi-upload-token",
+			Description: "PyPI upload token",
+			Regex:       regexp.MustCompile(`pypi-AgEIcHlwaS5vcmc[\w-]{50,1000}`),
+			Keywords:    []string{"pypi-ageichlwas5vcmc"},
+		},
+		{
+			ID:          "sendgrid-api-token",
+			Description: "SendGrid API token",
+			Regex:       regexp.MustCompile(`(?i)\b(SG\.[a-z0-9=_.-]{66})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"sg."},
+		},
+		{
+			ID:          "twilio-api-key",
+			Description: "Twilio API key",
+			Regex:       regexp.MustCompile(`\bSK[0-9a-fA-F]{32}\b`),
+			Keywords:    []string{"sk"},
+		},
+		{
+			ID:          "digitalocean-pat",
+			Description: "DigitalOcean personal access token",
+			Regex:       regexp.MustCompile(`\b(do[opr]_v1_[a-f0-9]{64})\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"dop_v1_", "doo_v1_", "dor_v1_"},
+		},
+		{
+			ID:          "shopify-token",
+			Description: "Shopify access token",
+			Regex:       regexp.MustCompile(`shp(?:at|ca|pa|ss)_[a-fA-F0-9]{32}`),
+			Keywords:    []string{"shpat_", "shpca_", "shppa_", "shpss_"},
+		},
+		{
+			ID:          "vault-service-token",
+			Description: "HashiCorp Vault service token",
+			Regex:       regexp.MustCompile(`\b(hvs\.[\w-]{90,120})`),
+			SecretGroup: 1,
+			Keywords:    []string{"hvs."},
+		},
+		{
+			ID:          "databricks-api-token",
+			Description: "Databricks API token",
+			Regex:       regexp.MustCompile(`\b(dapi[a-f0-9]{32}(?:-\d)?)\b`),
+			SecretGroup: 1,
+			Keywords:    []string{"dapi"},
+		},
+		{
+			ID:          "jwt",
+			Description: "JSON Web Token",
+			Regex:       regexp.MustCompile(`\b(ey[a-zA-Z0-9]{17,}\.ey[a-zA-Z0-9/_-]{17,}\.(?:[a-zA-Z0-9/_-]{10,}={0,2})?)`),
+			SecretGroup: 1,
+			Keywords:    []string{"ey"},
+			Entropy:     3,
+		},
+		{
+			ID:          "generic-api-key",
+			Description: "Generic API key, token, secret or password assignment",
+			Regex: regexp.MustCompile(`(?im)(?:access|auth|api|credential|creds|key|passw(?:or)?d|secret|token)[\w.-]{0,20}[\s'"]{0,3}` +
+				`(?:=|>|:{1,3}=|\|\||:|=>|\?=|,)[\x60'"\s=]{0,5}([\w.=+/-]{10,150})(?:[\x60'"\s;,]|\\[nr]|$)`),
+			SecretGroup:  1,
+			Keywords:     []string{"access", "auth", "api", "credential", "creds", "key", "passw", "secret", "token"},
+			Entropy:      3.5,
+			RequireDigit: true,
+		},
+	}
+}
+
+// mergeRules puts custom rules ahead of the built-in ones, so their
+// matches win on overlap. A custom rule replaces a built-in rule with the
+// same ID.
+func mergeRules(builtin, custom []Rule) []Rule {
+	replaced := map[string]bool{}
+	for _, r := range custom {
+		replaced[r.ID] = true
+	}
+	merged := append([]Rule(nil), custom...)
+	for _, r := range builtin {
+		if !replaced[r.ID] {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+// matches reports whether the content contains one of the rule's keywords
+func (r *Rule) matches(lower string) bool {
+	if len(r.Keywords) == 0 {
+		return true
+	}
+	for _, k := range r.Keywords {
+		if strings.Contains(lower, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
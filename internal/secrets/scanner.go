@@ -1,45 +1,260 @@
 package secrets
 
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+)
+
 // Finding represents a detected secret
 type Finding struct {
-	Line    int
-	Column  int
-	Match   string
-	Type    string
+	Line   int    // 1-based line of the secret's first byte
+	Column int    // 1-based byte column within that line
+	Match  string // the secret itself
+	Type   string // ID of the rule that found it
 }
 
 // ScanResult contains the results of a secret scan
 type ScanResult struct {
 	Findings   []Finding
-	ShouldSkip bool // Type A: entire file should be skipped
+	ShouldSkip bool   // a SkipFile rule matched: leave the entire file out
+	SkipRule   string // ID of the first SkipFile rule that matched
 }
 
 // Scanner scans content for secrets
-type Scanner struct{}
+type Scanner struct {
+	rules          []Rule
+	entropy        *EntropyDetector
+	skip           SkipPatterns
+	preserveLength bool
+}
 
-// New creates a new Scanner
+// New creates a Scanner using the built-in rules and the entropy detector
+// with its defaults. It skips no files until SetSkipPatterns is called.
 func New() *Scanner {
-	return &Scanner{}
+	return &Scanner{rules: DefaultRules(), entropy: &EntropyDetector{}}
+}
+
+// NewFromConfig creates a Scanner with the skip patterns, entropy
+// settings and custom rules from cfg. The entropy detector, which
+// redacts any random-looking token, IDs and hashes included, runs only
+// if cfg enables it. Patterns and custom rule regexes were validated when
+// cfg was loaded.
+func NewFromConfig(cfg *config.Config) *Scanner {
+	s := New()
+	s.skip, _ = ParseSkipPatterns(cfg.SkipFiles)
+	s.SetEntropy(nil)
+	if cfg.EntropyEnabled {
+		s.SetEntropy(&EntropyDetector{
+			Threshold: cfg.EntropyThreshold,
+			MinLength: cfg.EntropyMinLength,
+			Charset:   cfg.EntropyCharset,
+		})
+	}
+	s.SetPreserveLength(cfg.Redaction == config.RedactionPadded)
+
+	rules := make([]Rule, 0, len(cfg.SecretRules))
+	for _, c := range cfg.SecretRules {
+		keywords := make([]string, len(c.Keywords))
+		for i, k := range c.Keywords {
+			keywords[i] = strings.ToLower(k)
+		}
+		rules = append(rules, Rule{
+			ID:          c.ID,
+			Description: c.Description,
+			Regex:       regexp.MustCompile(c.Regex),
+			SecretGroup: c.SecretGroup,
+			Keywords:    keywords,
+			Entropy:     c.Entropy,
+			Replacement: c.Replacement,
+			SkipFile:    c.Action == config.RuleActionSkipFile,
+		})
+	}
+	s.AddRules(rules)
+	return s
+}
+
+// AddRules merges custom rules into the scanner's rules. They take
+// precedence over the built-in rules, replacing any with the same ID.
+func (s *Scanner) AddRules(rules []Rule) {
+	s.rules = mergeRules(s.rules, rules)
+}
+
+// rule returns the rule with the given ID, or nil
+func (s *Scanner) rule(id string) *Rule {
+	for i := range s.rules {
+		if s.rules[i].ID == id {
+			return &s.rules[i]
+		}
+	}
+	return nil
+}
+
+// SetSkipPatterns sets the patterns ShouldSkipFile matches.
+func (s *Scanner) SetSkipPatterns(p SkipPatterns) {
+	s.skip = p
+}
+
+// SetPreserveLength makes Redact replace each secret with as many bytes as
+// it had, so byte columns in the redacted content stay accurate.
+func (s *Scanner) SetPreserveLength(preserve bool) {
+	s.preserveLength = preserve
+}
+
+// SetEntropy replaces the entropy detector; nil disables it.
+func (s *Scanner) SetEntropy(d *EntropyDetector) {
+	s.entropy = d
 }
 
-// ScanFile checks if a file should be skipped entirely (Type A secrets)
-func (s *Scanner) ScanFile(path string) (*ScanResult, error) {
-	return &ScanResult{
-		Findings:   nil,
-		ShouldSkip: false,
-	}, nil
+// ShouldSkipFile reports whether the file at rel, relative to the project
+// root, is a secret file (Type A) that must not be read at all, and the
+// skip pattern it matched.
+func (s *Scanner) ShouldSkipFile(rel string) (string, bool) {
+	return s.skip.Match(rel)
 }
 
-// ScanContent scans content for inline secrets (Type B)
+// ScanContent scans content for inline secrets (Type B). Findings are
+// ordered by position; where rules overlap, the earlier (more specific)
+// rule wins, and any rule wins over the entropy detector. ShouldSkip is
+// set when a SkipFile rule matched.
 func (s *Scanner) ScanContent(content string) (*ScanResult, error) {
-	return &ScanResult{
-		Findings:   nil,
-		ShouldSkip: false,
-	}, nil
+	lower := strings.ToLower(content)
+	var spans []span
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if !rule.matches(lower) {
+			continue
+		}
+		for _, m := range rule.Regex.FindAllStringSubmatchIndex(content, -1) {
+			start, end := m[2*rule.SecretGroup], m[2*rule.SecretGroup+1]
+			if start < 0 {
+				continue
+			}
+			secret := content[start:end]
+			if rule.Entropy > 0 && shannonEntropy(secret) < rule.Entropy {
+				continue
+			}
+			if rule.RequireDigit && !strings.ContainsFunc(secret, unicode.IsDigit) {
+				continue
+			}
+			sp := span{start: start, end: end, rule: rule.ID}
+			if overlapsAny(spans, sp) {
+				continue
+			}
+			spans = append(spans, sp)
+		}
+	}
+	if s.entropy != nil {
+		for _, sp := range s.entropy.find(content) {
+			if !overlapsAny(spans, sp) {
+				spans = append(spans, sp)
+			}
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	result := &ScanResult{}
+	for _, sp := range spans {
+		if r := s.rule(sp.rule); r != nil && r.SkipFile && !result.ShouldSkip {
+			result.ShouldSkip, result.SkipRule = true, r.ID
+		}
+		line, col := position(content, sp.start)
+		result.Findings = append(result.Findings, Finding{
+			Line:   line,
+			Column: col,
+			Match:  content[sp.start:sp.end],
+			Type:   sp.rule,
+		})
+	}
+	return result, nil
+}
+
+// FileFindings are the findings in one file
+type FileFindings struct {
+	Path     string // slash-separated, relative to the scanned directory
+	Findings []Finding
+	Skip     bool // a SkipFile rule matched, so indexing leaves the file out
+}
+
+// ScanDir scans every file under root that indexing would read: files
+// ignored by .gitignore, binary files, files matching a skip pattern and
+// swarm-indexer's own files are left out. Only files with findings are
+// returned, in walk order.
+func (s *Scanner) ScanDir(root string) ([]FileFindings, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := walker.Walk(absRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []FileFindings
+	var errs []error
+	for fi := range ch {
+		if name := filepath.Base(fi.Path); name == metadata.MetadataFileName || name == BaselineFileName {
+			continue
+		}
+		rel, err := filepath.Rel(absRoot, fi.Path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, skip := s.ShouldSkipFile(rel); skip {
+			continue
+		}
+		ff, err := s.scanPath(fi.Path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ff == nil {
+			continue
+		}
+		ff.Path = filepath.ToSlash(rel)
+		results = append(results, *ff)
+	}
+	return results, errors.Join(errs...)
+}
+
+// scanPath scans one file, returning nil if it has no findings or isn't
+// scanned at all
+func (s *Scanner) scanPath(path string) (*FileFindings, error) {
+	binary, err := walker.IsBinary(path)
+	if err != nil || binary {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.ScanContent(string(data))
+	if err != nil || len(result.Findings) == 0 {
+		return nil, err
+	}
+	return &FileFindings{Findings: result.Findings, Skip: result.ShouldSkip}, nil
+}
+
+// span is a byte range of content holding a secret
+type span struct {
+	start, end int
+	rule       string
 }
 
-// Redact removes secrets from content
-func (s *Scanner) Redact(content string, findings []Finding) string {
-	// For now, return content unchanged
-	return content
+func overlapsAny(spans []span, sp span) bool {
+	for _, o := range spans {
+		if sp.start < o.end && o.start < sp.end {
+			return true
+		}
+	}
+	return false
 }
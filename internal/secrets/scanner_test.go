@@ -0,0 +1,285 @@
+package secrets
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Test tokens are assembled at runtime so the source itself doesn't trip
+// secret scanners.
+var (
+	fakeGitHubPAT = "ghp_" + strings.Repeat("aB3", 12)
+	fakeAWSKey    = "AKIA" + "QWERTYUIOPASDFGH"
+	fakeGCPKey    = "AIza" + "Sy" + strings.Repeat("x7Z", 11)
+	fakeGeneric   = "s3cr3t-" + "Zq8vR2mW9kLp"
+)
+
+func TestScanContent_Rules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		rule    string
+		match   string
+	}{
+		{"github pat", "token: " + fakeGitHubPAT + "\n", "github-pat", fakeGitHubPAT},
+		{"aws key", "aws_access_key_id = " + fakeAWSKey, "aws-access-token", fakeAWSKey},
+		{"gcp key", `const key = "` + fakeGCPKey + `"`, "gcp-api-key", fakeGCPKey},
+		{"generic", `DB_PASSWORD="` + fakeGeneric + `"`, "generic-api-key", fakeGeneric},
+	}
+
+	s := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.ScanContent(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Findings) != 1 {
+				t.Fatalf("expected 1 finding, got %+v", result.Findings)
+			}
+			f := result.Findings[0]
+			if f.Type != tt.rule || f.Match != tt.match {
+				t.Errorf("expected %s %q, got %s %q", tt.rule, tt.match, f.Type, f.Match)
+			}
+		})
+	}
+}
+
+func TestScanContent_Position(t *testing.T) {
+	content := "package main\n\nvar token = \"" + fakeGitHubPAT + "\"\n"
+	result, err := New().ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Findings) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", result.Findings)
+	}
+	if f := result.Findings[0]; f.Line != 3 || f.Column != 14 {
+		t.Errorf("expected line 3 column 14, got line %d column %d", f.Line, f.Column)
+	}
+}
+
+func TestScanContent_NoFalsePositives(t *testing.T) {
+	content := `package main
+
+func connect(cfg *Config) {
+	client := NewClient(cfg.TypesenseURL, cfg.TypesenseAPIKey)
+	password := "changeme"
+	token := os.Getenv("GITHUB_TOKEN")
+	apiKey: config.GeminiAPIKey,
+}
+`
+	result, err := New().ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Findings) != 0 {
+		t.Errorf("expected no findings in ordinary code, got %+v", result.Findings)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	content := "a = " + fakeAWSKey + "\nb = \"" + fakeGitHubPAT + "\"\n"
+	s := New()
+	result, err := s.ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := s.Redact(content, result.Findings)
+	if strings.Contains(redacted, fakeAWSKey) || strings.Contains(redacted, fakeGitHubPAT) {
+		t.Errorf("secrets left in redacted content:\n%s", redacted)
+	}
+	want := "a = [REDACTED:aws-access-token]\nb = \"[REDACTED:github-pat]\"\n"
+	if redacted != want {
+		t.Errorf("got %q, want %q", redacted, want)
+	}
+}
+
+func TestRedact_PreserveLength(t *testing.T) {
+	content := "a = " + fakeAWSKey + "\nb = \"" + fakeGitHubPAT + "\"\n"
+	s := New()
+	s.SetPreserveLength(true)
+	result, err := s.ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redacted := s.Redact(content, result.Findings)
+	if len(redacted) != len(content) {
+		t.Errorf("length changed from %d to %d: %q", len(content), len(redacted), redacted)
+	}
+	// The AWS key is 20 bytes, too short for its marker
+	want := "a = " + strings.Repeat("*", 20) + "\nb = \"[REDACTED:github-pat]" + strings.Repeat("*", 40-21) + "\"\n"
+	if redacted != want {
+		t.Errorf("got %q, want %q", redacted, want)
+	}
+}
+
+func TestRedact_KeepsLineBreaks(t *testing.T) {
+	s := New()
+	s.AddRules([]Rule{{ID: "block", Regex: regexp.MustCompile(`(?s)BEGIN.*?END`)}})
+	content := "x\nBEGIN secret\r\nmore secret\nEND\ny\n"
+	result, err := s.ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := s.Redact(content, result.Findings), "x\n[REDACTED:block]\r\n\n\ny\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	s.SetPreserveLength(true)
+	if got, want := s.Redact(content, result.Findings), "x\n************\r\n***********\n***\ny\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRedact_IgnoresStaleFindings(t *testing.T) {
+	content := "nothing to see here\n"
+	stale := []Finding{{Line: 1, Column: 1, Match: fakeGitHubPAT, Type: "github-pat"}, {Line: 9, Column: 1, Match: "x"}}
+	if got := New().Redact(content, stale); got != content {
+		t.Errorf("expected content unchanged, got %q", got)
+	}
+}
+
+func TestShannonEntropy(t *testing.T) {
+	if e := shannonEntropy("aaaaaaaa"); e != 0 {
+		t.Errorf("expected 0 for a repeated character, got %v", e)
+	}
+	if e := shannonEntropy("abcdefgh"); e != 3 {
+		t.Errorf("expected 3 bits for 8 distinct characters, got %v", e)
+	}
+}
+
+func TestAddRules(t *testing.T) {
+	ticket := "TKT-" + strings.Repeat("A1", 12)
+	s := New()
+	s.AddRules([]Rule{
+		{
+			ID:          "ticket-token",
+			Regex:       regexp.MustCompile(`\bTKT-[A-Z0-9]{24}\b`),
+			Keywords:    []string{"tkt-"},
+			Replacement: "[TICKET]",
+		},
+		// Replaces the built-in rule of the same ID
+		{ID: "github-pat", Regex: regexp.MustCompile(`ghp_[0-9a-zA-Z]{36}`), Replacement: "[GITHUB]"},
+	})
+
+	content := "ticket " + ticket + "\ntoken " + fakeGitHubPAT + "\n"
+	result, err := s.ScanContent(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Findings) != 2 || result.ShouldSkip {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	got := s.Redact(content, result.Findings)
+	if want := "ticket [TICKET]\ntoken [GITHUB]\n"; got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+}
+
+func TestAddRules_SkipFile(t *testing.T) {
+	s := New()
+	s.AddRules([]Rule{{ID: "customer-export", Regex: regexp.MustCompile(`CUST-\d{8}`), SkipFile: true}})
+
+	result, err := s.ScanContent("id,name\nCUST-00012345,Ada\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ShouldSkip {
+		t.Errorf("expected ShouldSkip, got %+v", result)
+	}
+}
+
+func TestSkipPatterns(t *testing.T) {
+	patterns, err := ParseSkipPatterns(".env, *.pem,credentials.*,**/secrets/*.yaml,/deploy/prod.env,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		".env":                      ".env",
+		"api/.env":                  ".env",
+		"certs/server.pem":          "*.pem",
+		"credentials.json":          "credentials.*",
+		"secrets/db.yaml":           "**/secrets/*.yaml",
+		"deploy/k8s/secrets/x.yaml": "**/secrets/*.yaml",
+		"deploy/prod.env":           "deploy/prod.env", // leading slash dropped
+		"other/deploy/prod.env":     "",
+		"secrets/nested/x.yaml":     "",
+		"main.go":                   "",
+		"env.go":                    "",
+	}
+	s := New()
+	s.SetSkipPatterns(patterns)
+	for rel, want := range cases {
+		got, skip := s.ShouldSkipFile(filepath.FromSlash(rel))
+		if skip != (want != "") || got != want {
+			t.Errorf("ShouldSkipFile(%q) = %q, %v; want %q", rel, got, skip, want)
+		}
+	}
+
+	if _, err := ParseSkipPatterns("[unclosed"); err == nil {
+		t.Error("expected an invalid pattern error")
+	}
+}
+
+func TestScanContent_PrivateKeyBlock(t *testing.T) {
+	// Assembled at runtime, see the fake tokens above
+	begin, end := "-----BEGIN RSA "+"PRIVATE KEY-----", "-----END RSA "+"PRIVATE KEY-----"
+	block := begin + "\nMIIEowIBAAKCAQEA7bq1\nx9Yk2mQ3vF8pLw==\n" + end
+	s := New()
+
+	t.Run("whole block", func(t *testing.T) {
+		content := "key = <<EOF\n" + block + "\nEOF\n"
+		result, err := s.ScanContent(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Findings) != 1 || result.Findings[0].Type != "private-key" || result.Findings[0].Match != block {
+			t.Fatalf("expected the whole block as one finding, got %+v", result.Findings)
+		}
+		want := "key = <<EOF\n[REDACTED:private-key]\n\n\n\nEOF\n"
+		if got := s.Redact(content, result.Findings); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("escaped in JSON", func(t *testing.T) {
+		escaped := strings.ReplaceAll(block, "\n", `\n`)
+		content := `{"private_key": "` + escaped + `\n", "client_email": "x@example.com"}`
+		result, err := s.ScanContent(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Findings) != 1 || result.Findings[0].Match != escaped {
+			t.Errorf("expected the escaped block, got %+v", result.Findings)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		content := "-----BEGIN " + "PRIVATE KEY-----\nMIIEvQIBADANBgkqhkiG9w0B\nAQEFAASCBKcwggSj"
+		result, err := s.ScanContent(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Findings) != 1 || result.Findings[0].Match != content {
+			t.Errorf("expected the rest of the content, got %+v", result.Findings)
+		}
+	})
+
+	t.Run("public key", func(t *testing.T) {
+		content := "-----BEGIN PUBLIC KEY-----\nMFkwEwYHKoZIzj0CAQYIKoZI\n-----END PUBLIC KEY-----\n"
+		result, err := s.ScanContent(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range result.Findings {
+			if f.Type == "private-key" {
+				t.Errorf("public key reported as private: %+v", f)
+			}
+		}
+	})
+}
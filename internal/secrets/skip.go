@@ -0,0 +1,51 @@
+package secrets
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// SkipPatterns match secret files (Type A) that are never read, such as
+// .env files and private keys. A pattern without a slash matches the file
+// name in any directory (*.pem); one with a slash matches the path
+// relative to the project root, with ** standing for any number of
+// directories (**/secrets/*.yaml, deploy/prod.env).
+type SkipPatterns []string
+
+// ParseSkipPatterns parses a comma-separated pattern list such as
+// SWARM_INDEXER_SKIP_FILES. Blank entries are ignored.
+func ParseSkipPatterns(list string) (SkipPatterns, error) {
+	var patterns SkipPatterns
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "/")
+		if p == "" {
+			continue
+		}
+		if !doublestar.ValidatePattern(p) {
+			return nil, fmt.Errorf("invalid pattern %q", p)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// Match returns the first pattern matching rel, a path relative to the
+// project root.
+func (p SkipPatterns) Match(rel string) (string, bool) {
+	rel = filepath.ToSlash(rel)
+	name := path.Base(rel)
+	for _, pattern := range p {
+		subject := rel
+		if !strings.Contains(pattern, "/") {
+			subject = name
+		}
+		if ok, _ := doublestar.Match(pattern, subject); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
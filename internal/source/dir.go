@@ -0,0 +1,69 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+	"github.com/dvaida/swarm-indexer/internal/walker"
+)
+
+// Dir is a directory on disk. Files are listed like index lists them,
+// honouring .gitignore and skipping hidden directories, and fingerprinted
+// by their SHA-256, as recorded in metadata.FileState.Hash.
+type Dir struct {
+	root string
+}
+
+// NewDir returns the source for the directory at root.
+func NewDir(root string) (*Dir, error) {
+	abs, err := walker.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Dir{root: abs}, nil
+}
+
+// Project returns the directory's absolute path.
+func (d *Dir) Project() string { return d.root }
+
+// ListFiles returns the paths of the directory's files.
+func (d *Dir) ListFiles(ctx context.Context) ([]string, error) {
+	ch, err := walker.Walk(d.root)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for fi := range ch {
+		// Drain the walk even when cancelled so it can finish
+		if ctx.Err() != nil {
+			continue
+		}
+		name := filepath.Base(fi.Path)
+		if name == metadata.MetadataFileName || name == secrets.BaselineFileName {
+			continue
+		}
+		rel, err := filepath.Rel(d.root, fi.Path)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths, ctx.Err()
+}
+
+// Fingerprint returns the SHA-256 of the file's content.
+func (d *Dir) Fingerprint(ctx context.Context, path string) (string, error) {
+	return metadata.HashFile(d.path(path))
+}
+
+// ReadFile reads the file.
+func (d *Dir) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	return os.ReadFile(d.path(path))
+}
+
+func (d *Dir) path(rel string) string {
+	return filepath.Join(d.root, filepath.FromSlash(rel))
+}
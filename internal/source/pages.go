@@ -0,0 +1,73 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/dvaida/swarm-indexer/internal/crawl"
+)
+
+// Pages are the pages of a crawled site, converted to markdown, each
+// under its URL. Pages that couldn't be fetched fail to be read, so they
+// keep their documents until a later crawl fetches them.
+type Pages struct {
+	site  string
+	order []string
+	pages map[string]crawl.Page
+}
+
+// NewPages returns the source for the pages of a crawl started at site.
+func NewPages(site string, pages []crawl.Page) *Pages {
+	p := &Pages{site: site, pages: make(map[string]crawl.Page, len(pages))}
+	for _, page := range pages {
+		if _, ok := p.pages[page.URL]; !ok {
+			p.order = append(p.order, page.URL)
+		}
+		p.pages[page.URL] = page
+	}
+	return p
+}
+
+// Project returns the URL the crawl started at.
+func (p *Pages) Project() string { return p.site }
+
+// ProjectType returns "web".
+func (p *Pages) ProjectType() string { return "web" }
+
+// Language returns "markdown": every page is converted to it.
+func (p *Pages) Language(path string) string { return "markdown" }
+
+// ListFiles returns the URLs of the pages, in crawl order.
+func (p *Pages) ListFiles(ctx context.Context) ([]string, error) {
+	return p.order, nil
+}
+
+// Fingerprint returns the SHA-256 of the page's markdown.
+func (p *Pages) Fingerprint(ctx context.Context, path string) (string, error) {
+	data, err := p.ReadFile(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ReadFile returns the page as markdown, headed by its title unless the
+// page has a heading of its own, or why it couldn't be fetched.
+func (p *Pages) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	page, ok := p.pages[path]
+	if !ok {
+		return nil, fmt.Errorf("%s: page not crawled", path)
+	}
+	if page.Err != nil {
+		return nil, page.Err
+	}
+	text := page.Text
+	if page.Title != "" && !strings.HasPrefix(text, "# ") {
+		text = "# " + page.Title + "\n\n" + text
+	}
+	return []byte(text), nil
+}
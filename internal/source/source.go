@@ -0,0 +1,34 @@
+// Package source abstracts where indexed content comes from, so every
+// connector (a directory, a crawled site, and later ones such as S3 or
+// Confluence) feeds the same indexing pipeline, Indexer.IndexSource. A
+// new connector only needs to list its files, fingerprint them and read
+// them.
+package source
+
+import "context"
+
+// Source is a collection of files to index.
+type Source interface {
+	// Project returns the project path the source's documents are stored
+	// under, such as a directory's absolute path or a site's URL.
+	Project() string
+	// ListFiles returns the paths of the files to index: slash-separated
+	// and relative to the source, or URLs.
+	ListFiles(ctx context.Context) ([]string, error)
+	// Fingerprint returns a value that changes whenever the content of the
+	// file at path does, such as a content hash or an ETag. Files whose
+	// fingerprint is the same as at the last index aren't read again.
+	Fingerprint(ctx context.Context, path string) (string, error)
+	// ReadFile returns the content of the file at path.
+	ReadFile(ctx context.Context, path string) ([]byte, error)
+}
+
+// Typed is implemented by sources that know what their files are better
+// than their paths tell, such as crawled pages converted to markdown.
+type Typed interface {
+	// ProjectType returns the project type of the source's documents.
+	ProjectType() string
+	// Language returns the language of the file at path, or "" to detect
+	// it from the path and content.
+	Language(path string) string
+}
@@ -0,0 +1,85 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/crawl"
+	"github.com/dvaida/swarm-indexer/internal/secrets"
+)
+
+func TestDir(t *testing.T) {
+	root := t.TempDir()
+	for rel, content := range map[string]string{
+		"main.go":                "package main\n",
+		"docs/guide.md":          "# Guide\n",
+		"build/out.txt":          "generated\n",
+		".gitignore":             "build/\n",
+		secrets.BaselineFileName: "{}\n",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src, err := NewDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	paths, err := src.ListFiles(ctx)
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	got := strings.Join(paths, " ")
+	for _, want := range []string{"main.go", "docs/guide.md"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %s in %v", want, paths)
+		}
+	}
+	if strings.Contains(got, "build/") || strings.Contains(got, secrets.BaselineFileName) {
+		t.Errorf("expected ignored and own files to be left out, got %v", paths)
+	}
+
+	data, err := src.ReadFile(ctx, "docs/guide.md")
+	if err != nil || string(data) != "# Guide\n" {
+		t.Errorf("unexpected content %q, %v", data, err)
+	}
+	before, _ := src.Fingerprint(ctx, "main.go")
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package app\n"), 0644)
+	if after, _ := src.Fingerprint(ctx, "main.go"); after == before || after == "" {
+		t.Errorf("expected the fingerprint to change with the content")
+	}
+}
+
+func TestPages(t *testing.T) {
+	src := NewPages("https://docs.example.com/", []crawl.Page{
+		{URL: "https://docs.example.com/", Title: "Docs", Text: "Welcome."},
+		{URL: "https://docs.example.com/api", Title: "API", Text: "# API reference"},
+		{URL: "https://docs.example.com/down", Err: errors.New("503 Service Unavailable")},
+	})
+	ctx := context.Background()
+
+	paths, _ := src.ListFiles(ctx)
+	if len(paths) != 3 || paths[0] != "https://docs.example.com/" {
+		t.Errorf("expected the pages in crawl order, got %v", paths)
+	}
+	if data, _ := src.ReadFile(ctx, "https://docs.example.com/"); string(data) != "# Docs\n\nWelcome." {
+		t.Errorf("expected the title to head the page, got %q", data)
+	}
+	if data, _ := src.ReadFile(ctx, "https://docs.example.com/api"); string(data) != "# API reference" {
+		t.Errorf("expected the page's own heading to be kept, got %q", data)
+	}
+	if _, err := src.Fingerprint(ctx, "https://docs.example.com/down"); err == nil {
+		t.Error("expected a page that couldn't be fetched to fail")
+	}
+	if src.Language("https://docs.example.com/api") != "markdown" || src.ProjectType() != "web" {
+		t.Error("expected pages to be markdown of a web project")
+	}
+}
@@ -0,0 +1,171 @@
+package state
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockFileName is the name of the index lock file in the data dir.
+const LockFileName = "index.lock"
+
+// LocksDir is the directory under the data dir holding a lock file per
+// project, named by the SHA-256 of the project path.
+const LocksDir = "locks"
+
+// lockPollInterval is how often a waiting Lock retries
+const lockPollInterval = 200 * time.Millisecond
+
+// LockedError is returned by Lock when another process holds the lock.
+type LockedError struct {
+	PID int // 0 if unknown
+	// Project is the project another run is indexing, or empty if it
+	// holds the whole index lock
+	Project string
+}
+
+func (e *LockedError) Error() string {
+	if e.Project != "" {
+		if e.PID == 0 {
+			return "another indexer is running on " + e.Project
+		}
+		return fmt.Sprintf("another indexer is running (pid %d) on %s", e.PID, e.Project)
+	}
+	if e.PID == 0 {
+		return "another swarm-indexer run holds the index lock"
+	}
+	return fmt.Sprintf("another swarm-indexer run (pid %d) holds the index lock", e.PID)
+}
+
+// Lock is an advisory lock held for the length of a run that writes
+// state, so concurrent runs can't interleave their writes or embed the
+// same files twice: either the whole index lock, or a share of it and the
+// locks of the projects the run indexes.
+type Lock struct {
+	files []lockFile
+}
+
+type lockFile struct {
+	f         *os.File
+	exclusive bool
+}
+
+// AcquireLock takes the index lock in dir, excluding every other run.
+// When another process holds it or a project lock, AcquireLock returns a
+// *LockedError unless wait is set, in which case it waits for the lock
+// until ctx is done.
+func AcquireLock(ctx context.Context, dir string, wait bool) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
+	l := &Lock{}
+	if err := l.acquire(ctx, filepath.Join(dir, LockFileName), "", true, wait); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// LockProjects takes the locks of projects in dir, along with a share of
+// the index lock, so runs on different projects proceed side by side
+// while runs on the same project take turns. When another process holds
+// one of them, LockProjects returns a *LockedError naming the project
+// unless wait is set, in which case it waits until ctx is done. Locks are
+// taken in path order so overlapping waiting runs can't deadlock.
+func LockProjects(ctx context.Context, dir string, projects []string, wait bool) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Join(dir, LocksDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock dir: %w", err)
+	}
+	projects = slices.Clone(projects)
+	slices.Sort(projects)
+	projects = slices.Compact(projects)
+
+	l := &Lock{}
+	if err := l.acquire(ctx, filepath.Join(dir, LockFileName), "", false, wait); err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		sum := sha256.Sum256([]byte(project))
+		path := filepath.Join(dir, LocksDir, hex.EncodeToString(sum[:])+".lock")
+		if err := l.acquire(ctx, path, project, true, wait); err != nil {
+			l.Release()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// acquire takes the lock file at path, of project if it is a project's,
+// and adds it to l
+func (l *Lock) acquire(ctx context.Context, path, project string, exclusive, wait bool) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	for {
+		ok, err := tryLock(f, exclusive)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if ok {
+			break
+		}
+		if !wait {
+			f.Close()
+			return &LockedError{PID: lockHolder(path), Project: project}
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	// Record the holder for the error other runs report; a shared lock
+	// has several
+	if exclusive {
+		if err := f.Truncate(0); err == nil {
+			f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+		}
+	}
+	l.files = append(l.files, lockFile{f: f, exclusive: exclusive})
+	return nil
+}
+
+// Release gives up the lock.
+func (l *Lock) Release() error {
+	var firstErr error
+	for i := len(l.files) - 1; i >= 0; i-- {
+		lf := l.files[i]
+		if lf.exclusive {
+			lf.f.Truncate(0)
+		}
+		if err := unlock(lf.f); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := lf.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	l.files = nil
+	return firstErr
+}
+
+// lockHolder returns the PID recorded in the lock file, or 0
+func lockHolder(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
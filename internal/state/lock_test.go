@@ -0,0 +1,94 @@
+//go:build unix
+
+package state
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := AcquireLock(context.Background(), dir, false)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+
+	// A second run fails right away, naming the holder
+	_, err = AcquireLock(context.Background(), dir, false)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("expected a LockedError, got %v", err)
+	}
+	if locked.PID != os.Getpid() {
+		t.Errorf("expected holder pid %d, got %d", os.Getpid(), locked.PID)
+	}
+
+	// A waiting run gives up when its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := AcquireLock(ctx, dir, true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to time out, got %v", err)
+	}
+
+	// ...and gets the lock once it is released
+	acquired := make(chan error, 1)
+	go func() {
+		l, err := AcquireLock(context.Background(), dir, true)
+		if err == nil {
+			err = l.Release()
+		}
+		acquired <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("waiting AcquireLock failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting AcquireLock didn't get the released lock")
+	}
+}
+
+func TestLockProjects(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	lock, err := LockProjects(ctx, dir, []string{"/a", "/b", "/a"}, false)
+	if err != nil {
+		t.Fatalf("LockProjects failed: %v", err)
+	}
+
+	// A run on one of the projects fails, naming it and the holder
+	_, err = LockProjects(ctx, dir, []string{"/c", "/b"}, false)
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Project != "/b" || locked.PID != os.Getpid() {
+		t.Fatalf("expected a LockedError for /b, got %v", err)
+	}
+	// ...and gives back the locks it took
+	other, err := LockProjects(ctx, dir, []string{"/c"}, false)
+	if err != nil {
+		t.Fatalf("expected another project to lock alongside, got %v", err)
+	}
+
+	// The whole index lock waits for every project
+	if _, err := AcquireLock(ctx, dir, false); !errors.As(err, &locked) || locked.Project != "" {
+		t.Errorf("expected the index lock to be held, got %v", err)
+	}
+	lock.Release()
+	other.Release()
+	whole, err := AcquireLock(ctx, dir, false)
+	if err != nil {
+		t.Fatalf("AcquireLock failed after the projects were released: %v", err)
+	}
+	if _, err := LockProjects(ctx, dir, []string{"/a"}, false); !errors.As(err, &locked) {
+		t.Errorf("expected projects to wait for the index lock, got %v", err)
+	}
+	whole.Release()
+}
@@ -0,0 +1,98 @@
+package state
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// migrations upgrade the schema one version at a time; migrations[i]
+// brings a database from version i to version i+1. Append new ones, never
+// change released ones.
+var migrations = []func(tx *bolt.Tx) error{
+	// 1: initial buckets
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{projectsBucket, filesBucket, runsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// ErrNewerSchema is returned by Open for a database written by a newer
+// swarm-indexer.
+var ErrNewerSchema = errors.New("state database was written by a newer swarm-indexer")
+
+// SchemaVersion is the schema version this build reads and writes.
+var SchemaVersion = len(migrations)
+
+// Version returns the schema version of the database.
+func (db *DB) Version() (int, error) {
+	v := 0
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		v = version(tx)
+		return nil
+	})
+	return v, err
+}
+
+// migrate applies the migrations the database hasn't seen yet, each in
+// its own transaction. A database already at SchemaVersion is only read.
+func (db *DB) migrate() error {
+	v, err := db.Version()
+	if err != nil {
+		return err
+	}
+	if v > len(migrations) {
+		return fmt.Errorf("%w: schema version %d, this version supports %d", ErrNewerSchema, v, len(migrations))
+	}
+	if v == len(migrations) {
+		return nil
+	}
+	for {
+		done := false
+		err := db.bolt.Update(func(tx *bolt.Tx) error {
+			v := version(tx)
+			if v > len(migrations) {
+				return fmt.Errorf("%w: schema version %d, this version supports %d", ErrNewerSchema, v, len(migrations))
+			}
+			if v == len(migrations) {
+				done = true
+				return nil
+			}
+			if err := migrations[v](tx); err != nil {
+				return fmt.Errorf("migrating state database to version %d: %w", v+1, err)
+			}
+			return setVersion(tx, v+1)
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+func version(tx *bolt.Tx) int {
+	b := tx.Bucket(metaBucket)
+	if b == nil {
+		return 0
+	}
+	data := b.Get(versionKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(data))
+}
+
+func setVersion(tx *bolt.Tx, v int) error {
+	b, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(v))
+	return b.Put(versionKey, data)
+}
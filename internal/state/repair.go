@@ -0,0 +1,169 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// RepairReport says what Repair changed.
+type RepairReport struct {
+	Path    string // the state database
+	Version int    // its schema version after the repair
+
+	// Rebuilt is set when the database couldn't be read at all and was
+	// replaced by an empty one; Backup is where the old one was moved
+	// and Reason why it was unreadable
+	Rebuilt bool
+	Backup  string
+	Reason  string
+
+	// Records removed because they couldn't be decoded. A project's file
+	// records go with it; file records of projects that have no record
+	// are counted in Files.
+	Projects int
+	Files    int
+	Runs     int
+}
+
+// Repaired reports whether Repair changed anything.
+func (r *RepairReport) Repaired() bool {
+	return r.Rebuilt || r.Projects+r.Files+r.Runs > 0
+}
+
+// Repair checks the state database in dir and removes the records it
+// can't decode, so the projects they belonged to are indexed from scratch
+// on their next run. A database that can't be opened or fails bbolt's
+// consistency check is moved aside and replaced by an empty one. A
+// database written by a newer version is left alone.
+func Repair(dir string) (*RepairReport, error) {
+	r := &RepairReport{Path: filepath.Join(dir, FileName)}
+
+	db, err := Open(dir)
+	if err == nil {
+		if err = db.check(); err != nil {
+			db.Close()
+		}
+	}
+	if errors.Is(err, ErrLocked) || errors.Is(err, ErrNewerSchema) {
+		return nil, err
+	}
+	if err != nil {
+		if _, statErr := os.Stat(r.Path); errors.Is(statErr, fs.ErrNotExist) {
+			return nil, err
+		}
+		r.Rebuilt, r.Reason = true, err.Error()
+		r.Backup = r.Path + ".corrupt-" + time.Now().Format("20060102-150405")
+		if err := os.Rename(r.Path, r.Backup); err != nil {
+			return nil, fmt.Errorf("moving unreadable state database aside: %w", err)
+		}
+		if db, err = Open(dir); err != nil {
+			return nil, err
+		}
+	} else if err := db.bolt.Update(r.removeCorrupt); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("removing corrupt records: %w", err)
+	}
+	defer db.Close()
+
+	if r.Version, err = db.Version(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// check runs bbolt's consistency check over the whole database. Damaged
+// pages can make bbolt panic, which is reported as an error too.
+func (db *DB) check() (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("state database is damaged: %v", p)
+		}
+	}()
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		var first error
+		// Drain the channel so the checking goroutine finishes before
+		// the transaction ends
+		for cerr := range tx.Check() {
+			if first == nil {
+				first = fmt.Errorf("state database is damaged: %w", cerr)
+			}
+		}
+		return first
+	})
+}
+
+// removeCorrupt deletes the records that don't decode, counting them in r.
+func (r *RepairReport) removeCorrupt(tx *bolt.Tx) error {
+	projects := tx.Bucket(projectsBucket)
+	files := tx.Bucket(filesBucket)
+
+	// Keys are collected first; bbolt doesn't allow deleting while
+	// iterating
+	bad := corruptKeys(projects, &Project{})
+	for _, k := range bad {
+		if err := projects.Delete(k); err != nil {
+			return err
+		}
+		if files.Bucket(k) != nil {
+			if err := files.DeleteBucket(k); err != nil {
+				return err
+			}
+		}
+	}
+	r.Projects = len(bad)
+
+	var nested [][]byte
+	files.ForEach(func(k, v []byte) error {
+		if v == nil {
+			nested = append(nested, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	for _, k := range nested {
+		b := files.Bucket(k)
+		if projects.Get(k) == nil {
+			r.Files += b.Stats().KeyN
+			if err := files.DeleteBucket(k); err != nil {
+				return err
+			}
+			continue
+		}
+		bad := corruptKeys(b, &File{})
+		for _, fk := range bad {
+			if err := b.Delete(fk); err != nil {
+				return err
+			}
+		}
+		r.Files += len(bad)
+	}
+
+	runs := tx.Bucket(runsBucket)
+	bad = corruptKeys(runs, &Run{})
+	for _, k := range bad {
+		if err := runs.Delete(k); err != nil {
+			return err
+		}
+	}
+	r.Runs = len(bad)
+	return nil
+}
+
+// corruptKeys returns the keys of b whose values don't decode into v.
+// Nested buckets are skipped.
+func corruptKeys(b *bolt.Bucket, v any) [][]byte {
+	var keys [][]byte
+	b.ForEach(func(k, data []byte) error {
+		if data != nil && json.Unmarshal(data, v) != nil {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	return keys
+}
@@ -0,0 +1,122 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRepair_RemovesCorruptRecords(t *testing.T) {
+	db, dir := openTest(t)
+	files := map[string]File{"a.go": {Size: 1}, "b.go": {Size: 2}}
+	for _, p := range []string{"/work/good", "/work/bad"} {
+		if err := db.SaveProject(&Project{Path: p}, files); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.AddRun(&Run{Start: 1}); err != nil {
+		t.Fatal(err)
+	}
+	err := db.bolt.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(projectsBucket).Put([]byte("/work/bad"), []byte("{")); err != nil {
+			return err
+		}
+		if err := tx.Bucket(filesBucket).Bucket([]byte("/work/good")).Put([]byte("b.go"), []byte("not json")); err != nil {
+			return err
+		}
+		// File records left behind by a project without a record
+		orphan, err := tx.Bucket(filesBucket).CreateBucket([]byte("/work/gone"))
+		if err != nil {
+			return err
+		}
+		if err := orphan.Put([]byte("c.go"), []byte(`{"size":3}`)); err != nil {
+			return err
+		}
+		return tx.Bucket(runsBucket).Put(runKey(2), []byte("}"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Project("/work/bad"); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt reading a corrupt project, got %v", err)
+	}
+	db.Close()
+
+	r, err := Repair(dir)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if r.Rebuilt || r.Projects != 1 || r.Files != 2 || r.Runs != 1 {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if r.Version != SchemaVersion {
+		t.Errorf("expected schema version %d, got %d", SchemaVersion, r.Version)
+	}
+
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if paths, _ := db.Projects(); len(paths) != 1 || paths[0] != "/work/good" {
+		t.Errorf("expected only the good project to remain, got %v", paths)
+	}
+	if got, err := db.Files("/work/good"); err != nil || len(got) != 1 {
+		t.Errorf("expected the good file record to remain, got %v, %v", got, err)
+	}
+	if runs, err := db.Runs(0); err != nil || len(runs) != 1 {
+		t.Errorf("expected the good run to remain, got %v, %v", runs, err)
+	}
+
+	// A second repair finds nothing to do
+	db.Close()
+	if r, err := Repair(dir); err != nil || r.Repaired() {
+		t.Errorf("expected nothing to repair, got %+v, %v", r, err)
+	}
+}
+
+func TestRepair_RebuildsUnreadableDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte("not a bbolt database"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir); err == nil {
+		t.Fatal("expected Open to fail on an unreadable database")
+	}
+
+	r, err := Repair(dir)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if !r.Rebuilt || r.Reason == "" {
+		t.Errorf("expected the database to be rebuilt, got %+v", r)
+	}
+	if data, err := os.ReadFile(r.Backup); err != nil || string(data) != "not a bbolt database" {
+		t.Errorf("expected the old database to be kept at %s, got %q, %v", r.Backup, data, err)
+	}
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("expected a usable database after repair: %v", err)
+	}
+	db.Close()
+}
+
+func TestRepair_NewerSchema(t *testing.T) {
+	db, dir := openTest(t)
+	if err := db.bolt.Update(func(tx *bolt.Tx) error { return setVersion(tx, SchemaVersion+1) }); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err := Repair(dir); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("expected ErrNewerSchema, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, FileName)); err != nil {
+		t.Errorf("expected the database to be left in place: %v", err)
+	}
+}
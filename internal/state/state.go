@@ -0,0 +1,266 @@
+// Package state keeps swarm-indexer's local indexing state in a bbolt
+// database under the data dir: per-project and per-file records and run
+// history.
+package state
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// FileName is the name of the state database in the data dir.
+const FileName = "state.db"
+
+// openTimeout bounds how long Open waits for another process holding the
+// database
+const openTimeout = 10 * time.Second
+
+// ErrLocked is returned by Open when another process keeps the database
+// open for longer than the open timeout.
+var ErrLocked = errors.New("state database is in use by another swarm-indexer process")
+
+// ErrCorrupt is wrapped by errors reading records that can't be decoded.
+// Repair removes such records.
+var ErrCorrupt = errors.New("corrupt state record")
+
+var (
+	metaBucket     = []byte("meta")
+	projectsBucket = []byte("projects")
+	filesBucket    = []byte("files") // one nested bucket per project
+	runsBucket     = []byte("runs")
+
+	versionKey = []byte("version")
+)
+
+// DB is an open state database.
+type DB struct {
+	bolt *bolt.DB
+}
+
+// Project is what was recorded about a project at its last index.
+type Project struct {
+	Path         string            `json:"path"`
+	LastIndexed  int64             `json:"last_indexed"`
+	FileCount    int               `json:"file_count"`
+	ProjectType  string            `json:"project_type"`
+	Languages    []string          `json:"languages"`
+	Dependencies map[string]string `json:"dependencies"`
+	// HistoryHead is the newest commit whose history was indexed
+	HistoryHead string `json:"history_head,omitempty"`
+	// Commit is the commit a remote repository's clone was indexed at, or
+	// the SHA-256 of an unpacked archive
+	Commit string `json:"commit,omitempty"`
+	// Tags are stored on every document of the project
+	Tags []string `json:"tags,omitempty"`
+}
+
+// File records what a file looked like when it was last indexed.
+type File struct {
+	ModTime int64 `json:"mtime"` // unix nanoseconds
+	Size    int64 `json:"size"`
+	// Hash is the SHA-256 of the file's contents, recorded for indexed
+	// files so a touched but unchanged file isn't re-indexed
+	Hash string `json:"hash,omitempty"`
+	// Language is the detected language of an indexed file; empty for
+	// files that were skipped
+	Language string `json:"language,omitempty"`
+	// Chunks maps the IDs of the file's chunks to the hashes of their
+	// content and lines, so a modified file only re-embeds the chunks that
+	// changed and updates the lines of those that moved
+	Chunks map[string]string `json:"chunks,omitempty"`
+}
+
+// Run is one indexing run's statistics.
+type Run struct {
+	ID      uint64   `json:"id"`
+	Start   int64    `json:"start"` // unix seconds
+	End     int64    `json:"end"`
+	Paths   []string `json:"paths"`
+	Files   int64    `json:"files"`
+	Chunks  int64    `json:"chunks"`
+	Tokens  int64    `json:"tokens"`
+	Failed  int64    `json:"failed"`
+	Aborted bool     `json:"aborted,omitempty"`
+}
+
+// Open opens the state database in dir, creating it and dir if needed and
+// migrating it to the current schema.
+func Open(dir string) (*DB, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
+	b, err := bolt.Open(filepath.Join(dir, FileName), 0644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("failed to open state database (run `swarm-indexer state repair` if it is corrupt): %w", err)
+	}
+	db := &DB{bolt: b}
+	if err := db.migrate(); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// Project returns the record of the project at path, or nil if it has
+// none.
+func (db *DB) Project(path string) (*Project, error) {
+	var p *Project
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(projectsBucket).Get([]byte(path))
+		if data == nil {
+			return nil
+		}
+		p = &Project{}
+		if err := json.Unmarshal(data, p); err != nil {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading project %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Projects returns the paths of all recorded projects, sorted.
+func (db *DB) Projects() ([]string, error) {
+	var paths []string
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(projectsBucket).ForEach(func(k, _ []byte) error {
+			paths = append(paths, string(k))
+			return nil
+		})
+	})
+	return paths, err
+}
+
+// SaveProject stores p and replaces the project's file records with files.
+func (db *DB) SaveProject(p *Project, files map[string]File) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(projectsBucket).Put([]byte(p.Path), data); err != nil {
+			return err
+		}
+		fb := tx.Bucket(filesBucket)
+		if fb.Bucket([]byte(p.Path)) != nil {
+			if err := fb.DeleteBucket([]byte(p.Path)); err != nil {
+				return err
+			}
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		b, err := fb.CreateBucket([]byte(p.Path))
+		if err != nil {
+			return err
+		}
+		for rel, f := range files {
+			v, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(rel), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteProject removes everything recorded about the project at path.
+func (db *DB) DeleteProject(path string) error {
+	key := []byte(path)
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(projectsBucket).Delete(key); err != nil {
+			return err
+		}
+		if tx.Bucket(filesBucket).Bucket(key) != nil {
+			return tx.Bucket(filesBucket).DeleteBucket(key)
+		}
+		return nil
+	})
+}
+
+// Files returns the file records of the project at path, keyed by path
+// relative to the project. It is nil when none are recorded.
+func (db *DB) Files(path string) (map[string]File, error) {
+	var files map[string]File
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(filesBucket).Bucket([]byte(path))
+		if b == nil {
+			return nil
+		}
+		files = map[string]File{}
+		return b.ForEach(func(k, v []byte) error {
+			var f File
+			if err := json.Unmarshal(v, &f); err != nil {
+				return fmt.Errorf("%w: file %s: %v", ErrCorrupt, k, err)
+			}
+			files[string(k)] = f
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading files of %s: %w", path, err)
+	}
+	return files, nil
+}
+
+// AddRun records r, assigning its ID.
+func (db *DB) AddRun(r *Run) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(runsBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return b.Put(runKey(id), data)
+	})
+}
+
+// Runs returns up to limit runs, newest first. A limit of 0 returns all.
+func (db *DB) Runs(limit int) ([]Run, error) {
+	var runs []Run
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(runsBucket).Cursor()
+		for k, v := c.Last(); k != nil && (limit == 0 || len(runs) < limit); k, v = c.Prev() {
+			var r Run
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("%w: run %d: %v", ErrCorrupt, binary.BigEndian.Uint64(k), err)
+			}
+			runs = append(runs, r)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// runKey encodes id big-endian so runs sort by ID
+func runKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
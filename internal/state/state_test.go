@@ -0,0 +1,142 @@
+package state
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTest(t *testing.T) (*DB, string) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, dir
+}
+
+func TestOpen_Migrates(t *testing.T) {
+	db, _ := openTest(t)
+	v, err := db.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != SchemaVersion {
+		t.Errorf("expected schema version %d, got %d", SchemaVersion, v)
+	}
+}
+
+func TestOpen_CurrentSchemaIsReadOnly(t *testing.T) {
+	txID := func(db *DB) int {
+		id := 0
+		db.bolt.View(func(tx *bolt.Tx) error {
+			id = tx.ID()
+			return nil
+		})
+		return id
+	}
+	db, dir := openTest(t)
+	before := txID(db)
+	db.Close()
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if after := txID(db); after != before {
+		t.Errorf("expected reopening a current database not to write, transaction ID went from %d to %d", before, after)
+	}
+}
+
+func TestOpen_NewerSchema(t *testing.T) {
+	db, dir := openTest(t)
+	err := db.bolt.Update(func(tx *bolt.Tx) error {
+		return setVersion(tx, SchemaVersion+1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err := Open(dir); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("expected a newer schema error, got %v", err)
+	}
+}
+
+func TestProjects(t *testing.T) {
+	db, _ := openTest(t)
+
+	p := &Project{Path: "/work/a", LastIndexed: 1700000000, FileCount: 2, ProjectType: "go", Languages: []string{"go"}}
+	files := map[string]File{
+		"main.go":    {ModTime: 1, Size: 10, Hash: "abc"},
+		"pkg/lib.go": {ModTime: 2, Size: 20},
+	}
+	if err := db.SaveProject(p, files); err != nil {
+		t.Fatalf("SaveProject failed: %v", err)
+	}
+
+	got, err := db.Project("/work/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("Project = %+v, want %+v", got, p)
+	}
+	gotFiles, err := db.Files("/work/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotFiles, files) {
+		t.Errorf("Files = %v, want %v", gotFiles, files)
+	}
+
+	// Saving again replaces the file records
+	if err := db.SaveProject(p, map[string]File{"main.go": {ModTime: 3, Size: 11}}); err != nil {
+		t.Fatal(err)
+	}
+	if gotFiles, _ := db.Files("/work/a"); len(gotFiles) != 1 || gotFiles["main.go"].ModTime != 3 {
+		t.Errorf("expected file records to be replaced, got %v", gotFiles)
+	}
+
+	if missing, err := db.Project("/work/b"); err != nil || missing != nil {
+		t.Errorf("expected no record for an unknown project, got %v, %v", missing, err)
+	}
+	if paths, _ := db.Projects(); !reflect.DeepEqual(paths, []string{"/work/a"}) {
+		t.Errorf("Projects = %v", paths)
+	}
+
+	if err := db.DeleteProject("/work/a"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.Project("/work/a"); got != nil {
+		t.Error("expected the project to be deleted")
+	}
+	if gotFiles, _ := db.Files("/work/a"); gotFiles != nil {
+		t.Errorf("expected the file records to be deleted, got %v", gotFiles)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	db, _ := openTest(t)
+	for i := int64(1); i <= 3; i++ {
+		if err := db.AddRun(&Run{Start: i, Files: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := db.Runs(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != 3 || runs[1].ID != 2 || runs[0].Files != 3 {
+		t.Errorf("expected the newest 2 runs, got %+v", runs)
+	}
+	if all, _ := db.Runs(0); len(all) != 3 {
+		t.Errorf("expected all 3 runs, got %d", len(all))
+	}
+}
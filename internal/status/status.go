@@ -1,12 +1,527 @@
 package status
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/config"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/state"
 )
 
+const separator = "─────────────────────────────────────────────────────────────"
+
+// Store provides collection statistics from the search backend
+type Store interface {
+	CollectionStats(ctx context.Context) (*indexer.CollectionStats, error)
+	ProjectFacets(ctx context.Context, projectPath string) (*indexer.ProjectFacets, error)
+	ProjectPaths(ctx context.Context) (map[string]int64, error)
+}
+
+// bytesPerDimension is the storage size of one float32 embedding component
+const bytesPerDimension = 4
+
+// Pinger checks connectivity and credentials for a remote service
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Service is a remote dependency whose connectivity status reports on
+type Service struct {
+	Name      string
+	Pinger    Pinger
+	QuotaHint string // configured limits, shown when no quota error is seen
+}
+
+// Options configures a status run
+type Options struct {
+	Store         Store  // nil when Typesense isn't configured
+	StoreErr      error  // why Store is nil, reported to the user
+	Collection    string // collection name shown in the report
+	CollectionURL string // Typesense URL shown in the report
+	Services      []Service
+	ShowChanges   bool          // list added/modified/deleted files for changed paths
+	ReindexAfter  time.Duration // flags paths last indexed longer ago; 0 flags none
+	Registered    []string      // registered paths; nil skips orphan detection
+	JSON          bool
+}
+
+// Report is the machine-readable result of a status run
+type Report struct {
+	Paths        []PathStatus     `json:"paths"`
+	Collection   CollectionStatus `json:"collection"`
+	Connectivity []ServiceStatus  `json:"connectivity"`
+	Orphans      []Orphan         `json:"orphans,omitempty"`
+	LastRun      *state.Run       `json:"last_run,omitempty"`
+}
+
+// Orphan is a project path with documents in the collection that no
+// longer corresponds to a registered path on disk
+type Orphan struct {
+	ProjectPath  string `json:"project_path"`
+	NumDocuments int64  `json:"num_documents"`
+	Reason       string `json:"reason"` // "unregistered" or "missing"
+}
+
+// ServiceStatus is the result of pinging a remote service
+type ServiceStatus struct {
+	Name      string `json:"name"`
+	Reachable bool   `json:"reachable"`
+	AuthValid bool   `json:"auth_valid"`
+	LatencyMS int64  `json:"latency_ms"`
+	QuotaHint string `json:"quota_hint,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// authError is implemented by API errors that can tell rejected credentials apart
+type authError interface {
+	IsAuthError() bool
+}
+
+// quotaError is implemented by API errors that can tell rate/quota rejections apart
+type quotaError interface {
+	IsQuotaError() bool
+}
+
+// PathStatus describes the indexing state of a single path
+type PathStatus struct {
+	Path        string            `json:"path"`
+	Indexed     bool              `json:"indexed"`
+	LastIndexed int64             `json:"last_indexed,omitempty"`
+	FileCount   int               `json:"file_count"`
+	ProjectType string            `json:"project_type,omitempty"`
+	Languages   []string          `json:"languages,omitempty"`
+	Changed     bool              `json:"changed"`
+	ReindexDue  bool              `json:"reindex_due"` // last indexed longer than reindex_after ago
+	Error       string            `json:"error,omitempty"`
+	Breakdown   *Breakdown        `json:"breakdown,omitempty"`
+	Changes     *metadata.Changes `json:"changes,omitempty"`
+}
+
+// Breakdown describes what a path contributed to the collection
+type Breakdown struct {
+	Chunks         int64            `json:"chunks"`
+	Languages      map[string]int64 `json:"languages"`
+	ChunkTypes     map[string]int64 `json:"chunk_types"`
+	EmbeddingBytes int64            `json:"embedding_bytes"`
+	Error          string           `json:"error,omitempty"`
+}
+
+// CollectionStatus describes the Typesense collection and its connectivity
+type CollectionStatus struct {
+	Name         string `json:"name,omitempty"`
+	URL          string `json:"url,omitempty"`
+	Reachable    bool   `json:"reachable"`
+	NumDocuments int64  `json:"num_documents"`
+	EmbeddingDim int    `json:"embedding_dim,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
 // Run executes the status command for the given paths
-func Run(paths []string, w io.Writer) error {
-	fmt.Fprintln(w, "Status command not yet implemented")
+func Run(ctx context.Context, paths []string, opts Options, w io.Writer) error {
+	report := Collect(ctx, paths, opts)
+	if opts.JSON {
+		return WriteJSON(w, report)
+	}
+	WriteText(w, report)
 	return nil
 }
+
+// Collect gathers metadata for each path and statistics for the collection
+func Collect(ctx context.Context, paths []string, opts Options) *Report {
+	report := &Report{Paths: make([]PathStatus, 0, len(paths))}
+	for _, path := range paths {
+		report.Paths = append(report.Paths, pathStatus(path, opts))
+	}
+	report.LastRun = lastRun()
+	report.Collection = collectionStatus(ctx, opts)
+	report.Connectivity = make([]ServiceStatus, 0, len(opts.Services))
+	for _, svc := range opts.Services {
+		report.Connectivity = append(report.Connectivity, ping(ctx, svc))
+	}
+
+	if report.Collection.Reachable {
+		for i := range report.Paths {
+			if report.Paths[i].Indexed {
+				report.Paths[i].Breakdown = breakdown(ctx, opts.Store, report.Paths[i].Path, report.Collection.EmbeddingDim)
+			}
+		}
+		if opts.Registered != nil {
+			orphans, err := findOrphans(ctx, opts.Store, opts.Registered)
+			if err != nil {
+				report.Collection.Error = fmt.Sprintf("orphan detection failed: %v", err)
+			}
+			report.Orphans = orphans
+		}
+	}
+	return report
+}
+
+// lastRun returns the most recent index run recorded in the state
+// database, or nil if there is none or it can't be read
+func lastRun() *state.Run {
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return nil
+	}
+	db, err := state.Open(dataDir)
+	if err != nil {
+		return nil
+	}
+	defer db.Close()
+	runs, err := db.Runs(1)
+	if err != nil || len(runs) == 0 {
+		return nil
+	}
+	return &runs[0]
+}
+
+// ChangedPaths returns how many paths have changes since they were last
+// indexed. Paths that were never indexed or are due a reindex count as
+// changed.
+func (r *Report) ChangedPaths() int {
+	n := 0
+	for _, ps := range r.Paths {
+		if ps.Changed || ps.ReindexDue || (!ps.Indexed && ps.Error == "") {
+			n++
+		}
+	}
+	return n
+}
+
+// findOrphans returns project paths in the store that are no longer
+// registered or no longer exist on disk
+func findOrphans(ctx context.Context, store Store, registered []string) ([]Orphan, error) {
+	projects, err := store.ProjectPaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(registered))
+	for _, p := range registered {
+		known[p] = true
+	}
+
+	var orphans []Orphan
+	for projectPath, count := range projects {
+		switch {
+		case !pathExists(projectPath):
+			orphans = append(orphans, Orphan{ProjectPath: projectPath, NumDocuments: count, Reason: "missing"})
+		case !known[projectPath]:
+			orphans = append(orphans, Orphan{ProjectPath: projectPath, NumDocuments: count, Reason: "unregistered"})
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].ProjectPath < orphans[j].ProjectPath
+	})
+	return orphans, nil
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func breakdown(ctx context.Context, store Store, path string, embeddingDim int) *Breakdown {
+	b := &Breakdown{}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		b.Error = err.Error()
+		return b
+	}
+
+	facets, err := store.ProjectFacets(ctx, absPath)
+	if err != nil {
+		b.Error = err.Error()
+		return b
+	}
+
+	b.Chunks = facets.NumDocuments
+	b.Languages = facets.Languages
+	b.ChunkTypes = facets.ChunkTypes
+	b.EmbeddingBytes = facets.NumDocuments * int64(embeddingDim) * bytesPerDimension
+	return b
+}
+
+func pathStatus(path string, opts Options) PathStatus {
+	ps := PathStatus{Path: path}
+
+	meta, err := metadata.Load(path)
+	if err != nil {
+		ps.Error = err.Error()
+		return ps
+	}
+	if meta.LastIndexed == 0 {
+		return ps
+	}
+
+	ps.Indexed = true
+	ps.LastIndexed = meta.LastIndexed
+	ps.FileCount = meta.FileCount
+	ps.ProjectType = meta.ProjectType
+	ps.Languages = meta.Languages
+	ps.ReindexDue = indexer.ReindexDue(meta.LastIndexed, opts.ReindexAfter)
+
+	files, err := metadata.ScanFiles(path)
+	if err != nil {
+		ps.Error = err.Error()
+		return ps
+	}
+	// A touched file counts as changed here even though indexing, which
+	// compares content hashes, may find nothing to do
+	changes := metadata.DiffFiles(meta.Files, files)
+	ps.Changed = !changes.Empty()
+
+	// Listing changes needs the file-level state recorded at index time
+	if ps.Changed && opts.ShowChanges && meta.Files != nil {
+		ps.Changes = &changes
+	}
+
+	return ps
+}
+
+func collectionStatus(ctx context.Context, opts Options) CollectionStatus {
+	cs := CollectionStatus{Name: opts.Collection, URL: opts.CollectionURL}
+
+	if opts.Store == nil {
+		if opts.StoreErr != nil {
+			cs.Error = opts.StoreErr.Error()
+		} else {
+			cs.Error = "Typesense not configured"
+		}
+		return cs
+	}
+
+	stats, err := opts.Store.CollectionStats(ctx)
+	if err != nil {
+		cs.Error = err.Error()
+		return cs
+	}
+
+	cs.Reachable = true
+	cs.NumDocuments = stats.NumDocuments
+	cs.EmbeddingDim = stats.EmbeddingDim
+	if stats.Name != "" {
+		cs.Name = stats.Name
+	}
+	return cs
+}
+
+func ping(ctx context.Context, svc Service) ServiceStatus {
+	ss := ServiceStatus{Name: svc.Name, QuotaHint: svc.QuotaHint}
+
+	start := time.Now()
+	err := svc.Pinger.Ping(ctx)
+	ss.LatencyMS = time.Since(start).Milliseconds()
+
+	if err == nil {
+		ss.Reachable = true
+		ss.AuthValid = true
+		return ss
+	}
+
+	ss.Error = err.Error()
+
+	// An API error means the server answered, so it is reachable
+	var ae authError
+	if errors.As(err, &ae) {
+		ss.Reachable = true
+		ss.AuthValid = !ae.IsAuthError()
+	}
+	var qe quotaError
+	if errors.As(err, &qe) && qe.IsQuotaError() {
+		ss.QuotaHint = "quota or rate limit exhausted (HTTP 429)"
+	}
+	return ss
+}
+
+// WriteJSON writes the report as indented JSON
+func WriteJSON(w io.Writer, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// WriteText writes the report in human-readable form
+func WriteText(w io.Writer, report *Report) {
+	fmt.Fprintln(w, "Indexed Paths:")
+	fmt.Fprintln(w, separator)
+
+	if len(report.Paths) == 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "No registered paths. Pass a path or index one first.")
+	}
+
+	for _, ps := range report.Paths {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "📁 %s\n", ps.Path)
+
+		if !ps.Indexed {
+			if ps.Error != "" {
+				fmt.Fprintf(w, "   Status: ✗ %s\n", ps.Error)
+			} else {
+				fmt.Fprintln(w, "   Status: Not indexed")
+			}
+			continue
+		}
+
+		projectType := ps.ProjectType
+		if projectType == "" {
+			projectType = "unknown"
+		}
+		fmt.Fprintf(w, "   Type: %s | Files: %d | Languages: %s\n",
+			projectType, ps.FileCount, strings.Join(ps.Languages, ", "))
+		fmt.Fprintf(w, "   Last indexed: %s\n", time.Unix(ps.LastIndexed, 0).Format("2006-01-02 15:04:05"))
+
+		if b := ps.Breakdown; b != nil {
+			if b.Error != "" {
+				fmt.Fprintf(w, "   Breakdown: unavailable (%s)\n", b.Error)
+			} else {
+				fmt.Fprintf(w, "   Chunks: %d | Embeddings: %s\n", b.Chunks, formatBytes(b.EmbeddingBytes))
+				fmt.Fprintf(w, "   By language: %s\n", formatCounts(b.Languages))
+				fmt.Fprintf(w, "   By chunk type: %s\n", formatCounts(b.ChunkTypes))
+			}
+		}
+
+		switch {
+		case ps.Error != "":
+			fmt.Fprintf(w, "   Status: ✗ %s\n", ps.Error)
+		case ps.Changed:
+			fmt.Fprintln(w, "   Status: ⚠ Changes detected (re-index needed)")
+			if ps.Changes != nil {
+				writeChangedFiles(w, "+", ps.Changes.Added)
+				writeChangedFiles(w, "~", ps.Changes.Modified)
+				writeChangedFiles(w, "-", ps.Changes.Deleted)
+			}
+		case ps.ReindexDue:
+			fmt.Fprintln(w, "   Status: ⚠ Last indexed longer than reindex_after ago (re-index due)")
+		default:
+			fmt.Fprintln(w, "   Status: ✓ Up to date")
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, separator)
+
+	if r := report.LastRun; r != nil {
+		fmt.Fprintf(w, "Last run: %s (%s)", time.Unix(r.Start, 0).Format("2006-01-02 15:04:05"), time.Duration(r.End-r.Start)*time.Second)
+		if r.Aborted {
+			fmt.Fprint(w, ", interrupted")
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "   Files: %d | Chunks: %d | Tokens: %d | Failed: %d\n", r.Files, r.Chunks, r.Tokens, r.Failed)
+		fmt.Fprintln(w, "   Run `swarm-indexer stats` for the run history.")
+		fmt.Fprintln(w)
+	}
+
+	cs := report.Collection
+	fmt.Fprintf(w, "Typesense Collection: %s\n", cs.Name)
+	if cs.Reachable {
+		fmt.Fprintf(w, "   Documents: %d\n", cs.NumDocuments)
+	} else {
+		fmt.Fprintf(w, "   Error: %s\n", cs.Error)
+	}
+	if cs.URL != "" {
+		fmt.Fprintf(w, "   URL: %s\n", cs.URL)
+	}
+
+	if len(report.Orphans) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "⚠ Orphaned documents:")
+		for _, o := range report.Orphans {
+			reason := "path not registered"
+			if o.Reason == "missing" {
+				reason = "path no longer exists"
+			}
+			fmt.Fprintf(w, "   %s (%d documents, %s)\n", o.ProjectPath, o.NumDocuments, reason)
+		}
+		fmt.Fprintln(w, "   Run `swarm-indexer prune` to remove them.")
+	}
+
+	if len(report.Connectivity) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Connectivity:")
+	for _, ss := range report.Connectivity {
+		switch {
+		case ss.Error == "":
+			fmt.Fprintf(w, "   %s: ✓ reachable, auth ok (%dms)\n", ss.Name, ss.LatencyMS)
+		case !ss.Reachable:
+			fmt.Fprintf(w, "   %s: ✗ unreachable: %s\n", ss.Name, ss.Error)
+		case !ss.AuthValid:
+			fmt.Fprintf(w, "   %s: ✗ auth rejected (%dms): %s\n", ss.Name, ss.LatencyMS, ss.Error)
+		default:
+			// Reachable with valid credentials but failing for another reason (e.g. quota)
+			fmt.Fprintf(w, "   %s: ⚠ %s (%dms)\n", ss.Name, ss.Error, ss.LatencyMS)
+		}
+		if ss.QuotaHint != "" {
+			fmt.Fprintf(w, "      Quota: %s\n", ss.QuotaHint)
+		}
+	}
+}
+
+// maxListedChanges caps how many files of each kind are listed in text output
+const maxListedChanges = 20
+
+func writeChangedFiles(w io.Writer, marker string, paths []string) {
+	for i, p := range paths {
+		if i == maxListedChanges {
+			fmt.Fprintf(w, "      %s ... and %d more\n", marker, len(paths)-maxListedChanges)
+			return
+		}
+		fmt.Fprintf(w, "      %s %s\n", marker, p)
+	}
+}
+
+// formatCounts renders counts as "key: n" pairs, largest first
+func formatCounts(counts map[string]int64) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s: %d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatBytes renders a byte count using binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
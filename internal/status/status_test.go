@@ -0,0 +1,564 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvaida/swarm-indexer/internal/embeddings"
+	"github.com/dvaida/swarm-indexer/internal/indexer"
+	"github.com/dvaida/swarm-indexer/internal/metadata"
+	"github.com/dvaida/swarm-indexer/internal/state"
+	"github.com/dvaida/swarm-indexer/internal/testenv"
+)
+
+// TestMain keeps metadata written by the tests out of the user's data dir.
+func TestMain(m *testing.M) {
+	testenv.Main(m)
+}
+
+type fakeStore struct {
+	stats    *indexer.CollectionStats
+	err      error
+	facets   map[string]*indexer.ProjectFacets
+	facetErr error
+	projects map[string]int64
+}
+
+func (f *fakeStore) CollectionStats(ctx context.Context) (*indexer.CollectionStats, error) {
+	return f.stats, f.err
+}
+
+func (f *fakeStore) ProjectPaths(ctx context.Context) (map[string]int64, error) {
+	return f.projects, nil
+}
+
+func (f *fakeStore) ProjectFacets(ctx context.Context, projectPath string) (*indexer.ProjectFacets, error) {
+	if f.facetErr != nil {
+		return nil, f.facetErr
+	}
+	if facets, ok := f.facets[projectPath]; ok {
+		return facets, nil
+	}
+	return &indexer.ProjectFacets{}, nil
+}
+
+// indexedDir creates a directory with a file and metadata matching its current state
+func indexedDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := metadata.ScanFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &metadata.Metadata{
+		LastIndexed: time.Now().Unix(),
+		FileCount:   1,
+		Files:       files,
+		ProjectType: "go",
+		Languages:   []string{"go"},
+	}
+	if err := m.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRun_ShowsPathMetadata(t *testing.T) {
+	dir := indexedDir(t)
+	var buf bytes.Buffer
+
+	err := Run(context.Background(), []string{dir}, Options{}, &buf)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{dir, "Type: go", "Files: 1", "Languages: go", "Up to date"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestRun_LastRun(t *testing.T) {
+	dataDir := t.TempDir()
+	t.Setenv("SWARM_INDEXER_DATA_DIR", dataDir)
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), nil, Options{}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "Last run") {
+		t.Errorf("expected no last run without history, got:\n%s", buf.String())
+	}
+
+	db, err := state.Open(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddRun(&state.Run{Start: 1700000000, End: 1700000012, Files: 5, Chunks: 20, Tokens: 800, Failed: 2, Aborted: true})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := Run(context.Background(), nil, Options{}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, want := range []string{"Last run:", "(12s), interrupted", "Files: 5 | Chunks: 20 | Tokens: 800 | Failed: 2"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	report := Collect(context.Background(), nil, Options{})
+	if report.LastRun == nil || report.LastRun.Files != 5 {
+		t.Errorf("expected the last run in the report, got %+v", report.LastRun)
+	}
+}
+
+func TestRun_DetectsChanges(t *testing.T) {
+	dir := indexedDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+
+	if err := Run(context.Background(), []string{dir}, Options{}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Changes detected") {
+		t.Errorf("expected 'Changes detected', got:\n%s", buf.String())
+	}
+}
+
+func TestReport_ChangedPaths(t *testing.T) {
+	clean := indexedDir(t)
+	changed := indexedDir(t)
+	if err := os.WriteFile(filepath.Join(changed, "new.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	never := t.TempDir()
+
+	report := Collect(context.Background(), []string{clean}, Options{})
+	if n := report.ChangedPaths(); n != 0 {
+		t.Errorf("expected no changed paths, got %d", n)
+	}
+
+	report = Collect(context.Background(), []string{clean, changed, never}, Options{})
+	if n := report.ChangedPaths(); n != 2 {
+		t.Errorf("expected changed and never-indexed paths to count, got %d", n)
+	}
+}
+
+func TestRun_ReindexDue(t *testing.T) {
+	dir := indexedDir(t)
+	meta, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.LastIndexed = time.Now().Add(-200 * time.Hour).Unix()
+	if err := meta.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	report := Collect(context.Background(), []string{dir}, Options{})
+	if report.Paths[0].ReindexDue || report.ChangedPaths() != 0 {
+		t.Errorf("expected no reindex due without reindex_after, got %+v", report.Paths[0])
+	}
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), []string{dir}, Options{ReindexAfter: 168 * time.Hour}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "re-index due") {
+		t.Errorf("expected 're-index due', got:\n%s", buf.String())
+	}
+	report = Collect(context.Background(), []string{dir}, Options{ReindexAfter: 168 * time.Hour})
+	if !report.Paths[0].ReindexDue || report.ChangedPaths() != 1 {
+		t.Errorf("expected the path to be due a reindex, got %+v", report.Paths[0])
+	}
+}
+
+func TestRun_MissingMetadata(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := Run(context.Background(), []string{t.TempDir()}, Options{}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Not indexed") {
+		t.Errorf("expected 'Not indexed', got:\n%s", buf.String())
+	}
+}
+
+func TestRun_TypesenseStats(t *testing.T) {
+	store := &fakeStore{stats: &indexer.CollectionStats{Name: "swarm-index", NumDocuments: 45678}}
+	var buf bytes.Buffer
+
+	err := Run(context.Background(), nil, Options{Store: store, CollectionURL: "http://localhost:8108"}, &buf)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{"Typesense Collection: swarm-index", "Documents: 45678", "URL: http://localhost:8108"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestRun_TypesenseConnectionError(t *testing.T) {
+	store := &fakeStore{err: errors.New("connection refused")}
+	var buf bytes.Buffer
+
+	if err := Run(context.Background(), nil, Options{Store: store}, &buf); err != nil {
+		t.Fatalf("Run should not fail on connection errors: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "connection refused") {
+		t.Errorf("expected connection error in output, got:\n%s", buf.String())
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	upToDate := indexedDir(t)
+	changed := indexedDir(t)
+	if err := os.WriteFile(filepath.Join(changed, "new.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeStore{stats: &indexer.CollectionStats{Name: "swarm-index", NumDocuments: 7}}
+	var buf bytes.Buffer
+
+	err := Run(context.Background(), []string{upToDate, changed}, Options{Store: store, JSON: true}, &buf)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	if len(report.Paths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(report.Paths))
+	}
+	if !report.Paths[0].Indexed || report.Paths[0].Changed {
+		t.Errorf("expected first path indexed and unchanged, got %+v", report.Paths[0])
+	}
+	if !report.Paths[1].Changed {
+		t.Errorf("expected second path changed, got %+v", report.Paths[1])
+	}
+	if report.Paths[0].ProjectType != "go" {
+		t.Errorf("expected project type 'go', got %q", report.Paths[0].ProjectType)
+	}
+	if !report.Collection.Reachable || report.Collection.NumDocuments != 7 {
+		t.Errorf("expected reachable collection with 7 documents, got %+v", report.Collection)
+	}
+}
+
+func TestRun_JSONUnconfiguredStore(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := Run(context.Background(), nil, Options{StoreErr: errors.New("TYPESENSE_API_KEY is required"), JSON: true}, &buf)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if report.Collection.Reachable {
+		t.Error("expected collection to be unreachable")
+	}
+	if !strings.Contains(report.Collection.Error, "TYPESENSE_API_KEY") {
+		t.Errorf("expected config error, got %q", report.Collection.Error)
+	}
+	if report.Paths == nil {
+		t.Error("expected paths to be an empty array, not null")
+	}
+}
+
+func TestRun_Breakdown(t *testing.T) {
+	dir := indexedDir(t)
+	store := &fakeStore{
+		stats: &indexer.CollectionStats{Name: "swarm-index", NumDocuments: 10, EmbeddingDim: 768},
+		facets: map[string]*indexer.ProjectFacets{
+			dir: {
+				NumDocuments: 10,
+				Languages:    map[string]int64{"go": 7, "markdown": 3},
+				ChunkTypes:   map[string]int64{"function": 6, "header": 3, "preamble": 1},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), []string{dir}, Options{Store: store}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{"Chunks: 10", "go: 7, markdown: 3", "function: 6, header: 3, preamble: 1", "Embeddings: 30.0 KiB"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	if err := Run(context.Background(), []string{dir}, Options{Store: store, JSON: true}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	b := report.Paths[0].Breakdown
+	if b == nil {
+		t.Fatal("expected breakdown in JSON report")
+	}
+	if b.EmbeddingBytes != 10*768*4 {
+		t.Errorf("expected %d embedding bytes, got %d", 10*768*4, b.EmbeddingBytes)
+	}
+	if b.Languages["go"] != 7 {
+		t.Errorf("expected 7 go chunks, got %d", b.Languages["go"])
+	}
+}
+
+func TestRun_BreakdownError(t *testing.T) {
+	dir := indexedDir(t)
+	store := &fakeStore{
+		stats:    &indexer.CollectionStats{Name: "swarm-index"},
+		facetErr: errors.New("facet search failed"),
+	}
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), []string{dir}, Options{Store: store}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Breakdown: unavailable (facet search failed)") {
+		t.Errorf("expected breakdown error, got:\n%s", buf.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		512:             "512 B",
+		1536:            "1.5 KiB",
+		3 * 1024 * 1024: "3.0 MiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+type fakePinger struct {
+	err error
+}
+
+func (f *fakePinger) Ping(ctx context.Context) error {
+	return f.err
+}
+
+func TestRun_Connectivity(t *testing.T) {
+	services := []Service{
+		{Name: "Typesense", Pinger: &fakePinger{}},
+		{Name: "Gemini", Pinger: &fakePinger{err: &embeddings.APIError{StatusCode: 400, Message: "API key not valid"}}, QuotaHint: "60 requests/min configured"},
+		{Name: "Other", Pinger: &fakePinger{err: errors.New("dial tcp: connection refused")}},
+	}
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), nil, Options{Services: services, JSON: true}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if len(report.Connectivity) != 3 {
+		t.Fatalf("expected 3 services, got %d", len(report.Connectivity))
+	}
+
+	ts := report.Connectivity[0]
+	if !ts.Reachable || !ts.AuthValid || ts.Error != "" {
+		t.Errorf("expected healthy Typesense, got %+v", ts)
+	}
+
+	gem := report.Connectivity[1]
+	if !gem.Reachable || gem.AuthValid {
+		t.Errorf("expected reachable Gemini with rejected auth, got %+v", gem)
+	}
+	if gem.QuotaHint != "60 requests/min configured" {
+		t.Errorf("expected configured quota hint, got %q", gem.QuotaHint)
+	}
+
+	other := report.Connectivity[2]
+	if other.Reachable {
+		t.Errorf("expected unreachable service, got %+v", other)
+	}
+}
+
+func TestRun_ConnectivityQuotaExhausted(t *testing.T) {
+	services := []Service{
+		{Name: "Gemini", Pinger: &fakePinger{err: &embeddings.APIError{StatusCode: 429, Message: "Resource exhausted"}}, QuotaHint: "60 requests/min configured"},
+	}
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), nil, Options{Services: services}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "Gemini: ⚠") {
+		t.Errorf("expected warning for Gemini, got:\n%s", output)
+	}
+	if !strings.Contains(output, "quota or rate limit exhausted") {
+		t.Errorf("expected quota hint, got:\n%s", output)
+	}
+}
+
+func TestRun_ConnectivityTypesenseAuth(t *testing.T) {
+	services := []Service{
+		{Name: "Typesense", Pinger: &fakePinger{err: &indexer.APIError{StatusCode: 401, Message: "Forbidden - a valid `x-typesense-api-key` header must be sent."}}},
+	}
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), nil, Options{Services: services}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Typesense: ✗ auth rejected") {
+		t.Errorf("expected auth rejection, got:\n%s", buf.String())
+	}
+}
+
+func TestRun_ShowChanges(t *testing.T) {
+	dir := indexedDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "main.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), []string{dir}, Options{ShowChanges: true}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, "+ new.go") {
+		t.Errorf("expected added file in output, got:\n%s", output)
+	}
+	if !strings.Contains(output, "- main.go") {
+		t.Errorf("expected deleted file in output, got:\n%s", output)
+	}
+
+	// Without the flag, only the summary line is shown
+	buf.Reset()
+	if err := Run(context.Background(), []string{dir}, Options{}, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "+ new.go") {
+		t.Errorf("expected no file list without ShowChanges, got:\n%s", buf.String())
+	}
+}
+
+func TestRun_ShowChangesWithoutFileState(t *testing.T) {
+	dir := indexedDir(t)
+	m, err := metadata.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Files = nil
+	if err := m.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := Collect(context.Background(), []string{dir}, Options{ShowChanges: true})
+	if !report.Paths[0].Changed {
+		t.Fatal("expected path to be changed")
+	}
+	if report.Paths[0].Changes != nil {
+		t.Errorf("expected no change list without stored file state, got %+v", report.Paths[0].Changes)
+	}
+}
+
+func TestRun_Orphans(t *testing.T) {
+	registered := t.TempDir()
+	unregistered := t.TempDir()
+	missing := filepath.Join(t.TempDir(), "deleted-project")
+
+	store := &fakeStore{
+		stats: &indexer.CollectionStats{Name: "swarm-index"},
+		projects: map[string]int64{
+			registered:   10,
+			unregistered: 4,
+			missing:      2,
+		},
+	}
+
+	var buf bytes.Buffer
+	opts := Options{Store: store, Registered: []string{registered}, JSON: true}
+	if err := Run(context.Background(), nil, opts, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if len(report.Orphans) != 2 {
+		t.Fatalf("expected 2 orphans, got %+v", report.Orphans)
+	}
+	reasons := map[string]string{}
+	for _, o := range report.Orphans {
+		reasons[o.ProjectPath] = o.Reason
+	}
+	if reasons[unregistered] != "unregistered" {
+		t.Errorf("expected %s to be unregistered, got %q", unregistered, reasons[unregistered])
+	}
+	if reasons[missing] != "missing" {
+		t.Errorf("expected %s to be missing, got %q", missing, reasons[missing])
+	}
+
+	buf.Reset()
+	opts.JSON = false
+	if err := Run(context.Background(), nil, opts, &buf); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "swarm-indexer prune") {
+		t.Errorf("expected pointer to prune command, got:\n%s", buf.String())
+	}
+}
+
+func TestRun_OrphansSkippedWithoutRegistry(t *testing.T) {
+	store := &fakeStore{
+		stats:    &indexer.CollectionStats{Name: "swarm-index"},
+		projects: map[string]int64{"/gone": 1},
+	}
+
+	report := Collect(context.Background(), nil, Options{Store: store})
+	if len(report.Orphans) != 0 {
+		t.Errorf("expected no orphan detection without registry, got %+v", report.Orphans)
+	}
+}
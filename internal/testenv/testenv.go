@@ -0,0 +1,23 @@
+// Package testenv sets up the environment the tests of other packages run
+// in.
+package testenv
+
+import (
+	"os"
+	"testing"
+)
+
+// Main runs the tests of m with the data dir (see config.DataDir) in a
+// temporary directory, so metadata and state the tests write stay out of
+// the user's, then exits with their result. Packages whose tests index
+// or record projects call it from their TestMain.
+func Main(m *testing.M) {
+	dir, err := os.MkdirTemp("", "swarm-indexer-data")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("SWARM_INDEXER_DATA_DIR", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,160 @@
+// Package tracing sets up OpenTelemetry tracing of indexing runs and
+// searches, exported over OTLP/HTTP when an endpoint is configured, so
+// deployments can see where the time of a run goes.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// name identifies the instrumentation and the service in traces
+const name = "swarm-indexer"
+
+// instrumentation is the tracer's instrumentation scope
+const instrumentation = "github.com/dvaida/swarm-indexer"
+
+var (
+	mu       sync.Mutex
+	provider *sdktrace.TracerProvider // nil until Start installs one
+)
+
+// Start exports spans to the OTLP/HTTP collector at endpoint, e.g.
+// http://localhost:4318, from then on. An empty endpoint leaves tracing
+// off: spans are then no-ops and cost next to nothing. Starting again
+// once tracing is on does nothing.
+func Start(ctx context.Context, endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if provider != nil {
+		return nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid OTLP endpoint %q: must be an http or https URL", endpoint)
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	// A bare endpoint gets the standard traces path
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", name)))
+	if err != nil {
+		return err
+	}
+	install(sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)))
+	return nil
+}
+
+// Install makes tp the provider of all spans in place of Start's
+// exporter; tests use it to record spans in memory.
+func Install(tp *sdktrace.TracerProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	install(tp)
+}
+
+func install(tp *sdktrace.TracerProvider) {
+	provider = tp
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Shutdown exports the spans still buffered and stops tracing. It does
+// nothing when tracing isn't on.
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	provider = nil
+	otel.SetTracerProvider(noop.NewTracerProvider())
+	return err
+}
+
+// Tracer returns the tracer the pipeline's spans are started with.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// StartSpan starts a span called name as a child of the span in ctx, if
+// any.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, context.Canceled) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Transport wraps rt so each request is a client span and carries the
+// trace context in its headers, linking the run's spans to those of
+// Typesense, Gemini or a collector proxy that honours them.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{next: rt}
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.path", req.URL.Path),
+		))
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		End(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	span.End()
+	return resp, nil
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	Install(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { Shutdown(context.Background()) })
+	return recorder
+}
+
+func TestStart_Endpoint(t *testing.T) {
+	if err := Start(context.Background(), ""); err != nil {
+		t.Fatalf("expected no endpoint to leave tracing off, got %v", err)
+	}
+	if provider != nil {
+		t.Error("expected no provider without an endpoint")
+	}
+	if err := Start(context.Background(), "localhost:4318"); err == nil {
+		t.Error("expected an endpoint without a scheme to be rejected")
+	}
+
+	if err := Start(context.Background(), "http://localhost:4318"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer Shutdown(context.Background())
+	if provider == nil {
+		t.Error("expected a provider to be installed")
+	}
+}
+
+func TestEnd_RecordsError(t *testing.T) {
+	recorder := record(t)
+
+	_, span := StartSpan(context.Background(), "embed")
+	End(span, errors.New("quota exceeded"))
+	_, span = StartSpan(context.Background(), "upsert")
+	End(span, context.Canceled)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error || spans[0].Status().Description != "quota exceeded" {
+		t.Errorf("expected an error status, got %+v", spans[0].Status())
+	}
+	if spans[1].Status().Code == codes.Error {
+		t.Error("expected cancellation not to be recorded as an error")
+	}
+}
+
+func TestTransport_PropagatesContext(t *testing.T) {
+	recorder := record(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	ctx, parent := StartSpan(context.Background(), "index.project")
+	client := &http.Client{Transport: Transport(nil)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/health", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "HTTP GET" {
+		t.Fatalf("expected an HTTP GET span, got %d spans", len(spans))
+	}
+	if spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the request span to be a child of the caller's span")
+	}
+	want := "00-" + spans[0].SpanContext().TraceID().String() + "-" + spans[0].SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("expected traceparent %q, got %q", want, traceparent)
+	}
+}
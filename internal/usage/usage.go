@@ -0,0 +1,119 @@
+// Package usage keeps cumulative embedding usage counters across runs so
+// API spend can be estimated.
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the name of the usage file in the data dir.
+const FileName = "usage.json"
+
+// DefaultPricePerMillion is the Gemini embedding list price in USD per
+// million input tokens, used when no price is configured.
+const DefaultPricePerMillion = 0.15
+
+// charsPerToken is the rough characters-per-token ratio used to estimate
+// tokens without calling a tokenizer
+const charsPerToken = 4
+
+// Stats holds cumulative embedding usage.
+type Stats struct {
+	Runs       int64 `json:"runs"`
+	EmbedCalls int64 `json:"embed_calls"`
+	Chunks     int64 `json:"chunks"`
+	ChunkBytes int64 `json:"chunk_bytes"`
+	Tokens     int64 `json:"tokens"` // estimated from ChunkBytes
+	Since      int64 `json:"since,omitempty"`
+	UpdatedAt  int64 `json:"updated_at,omitempty"`
+}
+
+// EstimateTokens returns a rough token count for text.
+func EstimateTokens(text string) int64 {
+	return int64((len(text) + charsPerToken - 1) / charsPerToken)
+}
+
+// Add accumulates o into s.
+func (s *Stats) Add(o Stats) {
+	s.Runs += o.Runs
+	s.EmbedCalls += o.EmbedCalls
+	s.Chunks += o.Chunks
+	s.ChunkBytes += o.ChunkBytes
+	s.Tokens += o.Tokens
+}
+
+// AvgChunkBytes returns the mean size of an embedded chunk.
+func (s Stats) AvgChunkBytes() float64 {
+	if s.Chunks == 0 {
+		return 0
+	}
+	return float64(s.ChunkBytes) / float64(s.Chunks)
+}
+
+// Cost returns the estimated spend in USD at the given price per million
+// tokens.
+func (s Stats) Cost(pricePerMillion float64) float64 {
+	return float64(s.Tokens) / 1e6 * pricePerMillion
+}
+
+// Load reads usage from the given data directory.
+// Returns zero stats if the file doesn't exist.
+func Load(dir string) (*Stats, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Stats{}, nil
+		}
+		return nil, err
+	}
+
+	var s Stats
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse usage: %w", err)
+	}
+	return &s, nil
+}
+
+// Save writes usage to the given data directory atomically.
+func (s *Stats) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+
+	path := filepath.Join(dir, FileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp usage file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename usage file: %w", err)
+	}
+	return nil
+}
+
+// Record adds run to the usage stored in dir.
+func Record(dir string, run Stats) error {
+	s, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	s.Add(run)
+	now := time.Now().Unix()
+	if s.Since == 0 {
+		s.Since = now
+	}
+	s.UpdatedAt = now
+	return s.Save(dir)
+}
@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	cases := map[string]int64{"": 0, "abc": 1, "abcd": 1, "abcde": 2}
+	for text, want := range cases {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestRecord_Accumulates(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := Record(dir, Stats{Runs: 1, EmbedCalls: 2, Chunks: 10, ChunkBytes: 4000, Tokens: 1000}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := Record(dir, Stats{Runs: 1, EmbedCalls: 1, Chunks: 5, ChunkBytes: 500, Tokens: 125}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if s.Runs != 2 || s.EmbedCalls != 3 || s.Chunks != 15 || s.ChunkBytes != 4500 || s.Tokens != 1125 {
+		t.Errorf("unexpected totals: %+v", s)
+	}
+	if s.Since == 0 || s.UpdatedAt < s.Since {
+		t.Errorf("expected timestamps to be set, got since=%d updated=%d", s.Since, s.UpdatedAt)
+	}
+	if s.AvgChunkBytes() != 300 {
+		t.Errorf("expected average chunk of 300 bytes, got %f", s.AvgChunkBytes())
+	}
+}
+
+func TestCost(t *testing.T) {
+	s := Stats{Tokens: 2_000_000}
+	if got := s.Cost(0.15); math.Abs(got-0.30) > 1e-9 {
+		t.Errorf("expected $0.30, got %f", got)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	s, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if s.Runs != 0 {
+		t.Errorf("expected zero stats, got %+v", s)
+	}
+}
+
+func TestLoad_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("expected error for corrupt usage file")
+	}
+}
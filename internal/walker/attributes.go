@@ -0,0 +1,126 @@
+package walker
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Attributes looks up the attributes the .gitattributes files of a tree
+// give its files, such as linguist-generated. The files are read as they
+// are needed. It is safe for concurrent use.
+type Attributes struct {
+	root  string
+	mu    sync.Mutex
+	rules map[string][]attrRule // of the .gitattributes in each directory, relative to root
+}
+
+// attrRule is a line of a .gitattributes file: a pattern and the states
+// it gives the attributes of the files matching it
+type attrRule struct {
+	pattern string
+	// base is set for patterns without a slash, which match file names
+	// at any depth; the others match paths from the file's directory
+	base   bool
+	states map[string]string
+}
+
+// NewAttributes returns the attributes of the tree at root.
+func NewAttributes(root string) *Attributes {
+	return &Attributes{root: root, rules: map[string][]attrRule{}}
+}
+
+// Get returns the state of the attribute name for the file at rel,
+// relative to the root: "true" if it is set, "false" if unset ("-name"),
+// its value if given one ("name=value"), or "" if unspecified. As in git,
+// the last matching line wins, and .gitattributes files deeper in the tree
+// take precedence.
+func (a *Attributes) Get(rel, name string) string {
+	rel = filepath.ToSlash(rel)
+	dirs := []string{""}
+	if dir := path.Dir(rel); dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+		}
+	}
+
+	state := ""
+	for _, dir := range dirs {
+		sub := rel
+		if dir != "" {
+			sub = rel[len(dir)+1:]
+		}
+		for _, r := range a.dirRules(dir) {
+			subject := sub
+			if r.base {
+				subject = path.Base(sub)
+			}
+			if ok, _ := doublestar.Match(r.pattern, subject); !ok {
+				continue
+			}
+			if s, ok := r.states[name]; ok {
+				state = s
+			}
+		}
+	}
+	return state
+}
+
+// dirRules returns the rules of the .gitattributes in dir, loading it on
+// first use
+func (a *Attributes) dirRules(dir string) []attrRule {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if rules, ok := a.rules[dir]; ok {
+		return rules
+	}
+	rules := readAttributes(filepath.Join(a.root, filepath.FromSlash(dir), ".gitattributes"))
+	a.rules[dir] = rules
+	return rules
+}
+
+// readAttributes parses the .gitattributes file at path; a missing or
+// unreadable file has no rules
+func readAttributes(name string) []attrRule {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []attrRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Patterns naming directories never match files, and macros
+		// ("[attr]name") define attributes rather than set them
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") ||
+			strings.HasSuffix(fields[0], "/") || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+		pattern := fields[0]
+		r := attrRule{pattern: strings.TrimPrefix(pattern, "/"), base: !strings.Contains(pattern, "/"), states: map[string]string{}}
+		for _, attr := range fields[1:] {
+			switch {
+			case strings.HasPrefix(attr, "-"):
+				r.states[attr[1:]] = "false"
+			case strings.HasPrefix(attr, "!"):
+				r.states[attr[1:]] = ""
+			default:
+				name, value, ok := strings.Cut(attr, "=")
+				if !ok {
+					value = "true"
+				}
+				r.states[name] = value
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
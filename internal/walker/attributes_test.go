@@ -0,0 +1,54 @@
+package walker_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dvaida/swarm-indexer/internal/walker"
+)
+
+func TestAttributes(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitattributes", `# generated code
+*.pb.go linguist-generated
+/dist/** linguist-vendored=true
+gen/*.go linguist-generated -diff
+keep.pb.go -linguist-generated
+vendor/ linguist-vendored
+[attr]noise -diff
+`)
+	write("api/.gitattributes", "legacy.pb.go !linguist-generated\n*.md linguist-documentation\n")
+
+	attrs := walker.NewAttributes(root)
+	for _, tc := range []struct {
+		rel, name, want string
+	}{
+		{"a.pb.go", "linguist-generated", "true"},
+		{"deep/down/b.pb.go", "linguist-generated", "true"},
+		{"keep.pb.go", "linguist-generated", "false"},
+		{"main.go", "linguist-generated", ""},
+		{"dist/app.min.js", "linguist-vendored", "true"},
+		{"web/dist/app.min.js", "linguist-vendored", ""},
+		{"gen/x.go", "diff", "false"},
+		{"sub/gen/x.go", "linguist-generated", ""},
+		{"vendor/lib.go", "linguist-vendored", ""},
+		{"api/legacy.pb.go", "linguist-generated", ""},
+		{"api/docs/guide.md", "linguist-documentation", "true"},
+		{"README.md", "linguist-documentation", ""},
+	} {
+		if got := attrs.Get(filepath.FromSlash(tc.rel), tc.name); got != tc.want {
+			t.Errorf("Get(%s, %s) = %q, want %q", tc.rel, tc.name, got, tc.want)
+		}
+	}
+}
@@ -32,11 +32,14 @@ func IsBinary(path string) (bool, error) {
 		return false, err
 	}
 
-	// Empty file is not binary
-	if n == 0 {
-		return false, nil
-	}
+	return IsBinaryContent(buf[:n]), nil
+}
 
-	// Check for null bytes in the buffer
-	return bytes.Contains(buf[:n], []byte{0}), nil
+// IsBinaryContent is IsBinary for content already read: it looks for null
+// bytes in the first 8KB. Empty content is not binary.
+func IsBinaryContent(data []byte) bool {
+	if len(data) > binaryCheckSize {
+		data = data[:binaryCheckSize]
+	}
+	return bytes.IndexByte(data, 0) >= 0
 }
@@ -0,0 +1,19 @@
+//go:build !unix && !windows
+
+package walker
+
+import "path/filepath"
+
+// ID returns the identity of the file or directory at path where there
+// are no inodes to go by: its path with symlinks resolved.
+func ID(path string) (FileID, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return FileID{}, err
+	}
+	return FileID{path: real}, nil
+}
+
+func trimLongPathPrefix(path string) string {
+	return path
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package walker
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ID returns the identity of the file or directory at path, following
+// links: its device and inode.
+func ID(path string) (FileID, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileID{}, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return FileID{}, fmt.Errorf("%s: no inode", path)
+	}
+	return FileID{volume: uint64(st.Dev), index: uint64(st.Ino)}, nil
+}
+
+func trimLongPathPrefix(path string) string {
+	return path
+}
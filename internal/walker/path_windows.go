@@ -0,0 +1,67 @@
+//go:build windows
+
+package walker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// ID returns the identity of the file or directory at path, following
+// links and junctions: its volume serial number and file index. Windows
+// has no inodes, and junctions can't be resolved like symlinks.
+func ID(path string) (FileID, error) {
+	p, err := syscall.UTF16PtrFromString(longPath(path))
+	if err != nil {
+		return FileID{}, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	// FILE_FLAG_BACKUP_SEMANTICS is needed to open directories
+	h, err := syscall.CreateFile(p, 0,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return FileID{}, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer syscall.CloseHandle(h)
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &info); err != nil {
+		return FileID{}, &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	return FileID{
+		volume: uint64(info.VolumeSerialNumber),
+		index:  uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow),
+	}, nil
+}
+
+const (
+	longPathPrefix = `\\?\`
+	longUNCPrefix  = `\\?\UNC\`
+)
+
+// longPath prefixes an absolute path with \\?\ so the Windows API calls
+// made directly, which the os package doesn't fix up, accept it past
+// MAX_PATH
+func longPath(path string) string {
+	if strings.HasPrefix(path, longPathPrefix) || !filepath.IsAbs(path) {
+		return path
+	}
+	if strings.HasPrefix(path, `\\`) {
+		return longUNCPrefix + path[2:]
+	}
+	return longPathPrefix + path
+}
+
+// trimLongPathPrefix turns \\?\C:\dir into C:\dir and \\?\UNC\host\share
+// into \\host\share
+func trimLongPathPrefix(path string) string {
+	if rest, ok := strings.CutPrefix(path, longUNCPrefix); ok {
+		return `\\` + rest
+	}
+	if rest, ok := strings.CutPrefix(path, longPathPrefix); ok && filepath.VolumeName(rest) != "" {
+		return rest
+	}
+	return path
+}
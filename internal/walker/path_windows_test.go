@@ -0,0 +1,25 @@
+//go:build windows
+
+package walker
+
+import "testing"
+
+func TestLongPaths(t *testing.T) {
+	tests := []struct {
+		path, long, trimmed string
+	}{
+		{`C:\src\api`, `\\?\C:\src\api`, `C:\src\api`},
+		{`\\host\share\api`, `\\?\UNC\host\share\api`, `\\host\share\api`},
+		{`\\?\C:\src\api`, `\\?\C:\src\api`, `C:\src\api`},
+		{`\\?\UNC\host\share\api`, `\\?\UNC\host\share\api`, `\\host\share\api`},
+		{`src\api`, `src\api`, `src\api`},
+	}
+	for _, tt := range tests {
+		if got := longPath(tt.path); got != tt.long {
+			t.Errorf("longPath(%q) = %q, want %q", tt.path, got, tt.long)
+		}
+		if got := trimLongPathPrefix(tt.path); got != tt.trimmed {
+			t.Errorf("trimLongPathPrefix(%q) = %q, want %q", tt.path, got, tt.trimmed)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package walker
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,11 +19,29 @@ type FileInfo struct {
 	IsDir   bool
 }
 
+// FileID identifies a file or directory however it's reached: through a
+// symlink, a Windows junction or its own path. See ID.
+type FileID struct {
+	volume, index uint64
+	path          string // where there are no file IDs
+}
+
+// Abs is filepath.Abs, but drops the \\?\ prefix of Windows long paths so
+// a directory has a single path however it was given.
+func Abs(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return trimLongPathPrefix(abs), nil
+}
+
 // Walk recursively traverses the directory tree starting at root,
 // respecting .gitignore patterns and skipping hidden directories.
-// It returns a channel of FileInfo for each discovered file.
+// Links to directories are not followed. It returns a channel of FileInfo
+// for each discovered file.
 func Walk(root string) (<-chan FileInfo, error) {
-	absRoot, err := filepath.Abs(root)
+	absRoot, err := Abs(root)
 	if err != nil {
 		return nil, err
 	}
@@ -42,25 +61,26 @@ func Walk(root string) (<-chan FileInfo, error) {
 	go func() {
 		defer close(ch)
 
-		// Track visited directories by their real path to detect symlink loops
-		visited := make(map[string]bool)
+		// Track visited directories by their ID to detect loops, such as
+		// through reparse points on Windows that list as directories
+		visited := make(map[FileID]bool)
 
 		// Stack of gitignore matchers (accumulated through directory descent)
 		var gitignores []*ignore.GitIgnore
 
 		var walkFn func(dir string) error
 		walkFn = func(dir string) error {
-			// Check for symlink loop
-			realPath, err := filepath.EvalSymlinks(dir)
+			// Check for a loop
+			id, err := ID(dir)
 			if err != nil {
-				// Skip if we can't resolve symlink
+				// Skip if we can't identify it
 				return nil
 			}
-			if visited[realPath] {
+			if visited[id] {
 				// Already visited, skip to avoid infinite loop
 				return nil
 			}
-			visited[realPath] = true
+			visited[id] = true
 
 			// Load .gitignore if present in this directory
 			gitignorePath := filepath.Join(dir, ".gitignore")
@@ -85,6 +105,13 @@ func Walk(root string) (<-chan FileInfo, error) {
 				if entry.IsDir() && strings.HasPrefix(name, ".") {
 					continue
 				}
+				// Skip links to directories (and junctions, which list as
+				// symlinks), which can't be read as files
+				if entry.Type()&fs.ModeSymlink != 0 {
+					if info, err := os.Stat(fullPath); err == nil && info.IsDir() {
+						continue
+					}
+				}
 
 				// Check gitignore patterns
 				relPath, _ := filepath.Rel(absRoot, fullPath)
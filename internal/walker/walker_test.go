@@ -422,3 +422,53 @@ func TestWalk_GlobPatterns(t *testing.T) {
 		}
 	}
 }
+
+func TestWalk_DirectoryLinksNotYielded(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := t.TempDir()
+	if err := os.WriteFile(filepath.Join(target, "outside.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(tmpDir, "linked_dir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(tmpDir, "file.txt"), filepath.Join(tmpDir, "linked_file.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := walker.Walk(tmpDir)
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	paths := getPaths(collectFiles(ch))
+	want := []string{filepath.Join(tmpDir, "file.txt"), filepath.Join(tmpDir, "linked_file.txt")}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, paths)
+	}
+}
+
+func TestID(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := walker.ID(dir)
+	if err != nil {
+		t.Fatalf("ID() error = %v", err)
+	}
+	if linked, err := walker.ID(link); err != nil || linked != id {
+		t.Errorf("expected a link to have its target's ID, got %v (err %v)", linked, err)
+	}
+	if otherID, err := walker.ID(other); err != nil || otherID == id {
+		t.Errorf("expected another directory to have another ID, got %v (err %v)", otherID, err)
+	}
+	if _, err := walker.ID(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}